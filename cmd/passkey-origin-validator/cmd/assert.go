@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/developmeh/passkey-origin-validator/internal/policy"
+	"github.com/spf13/cobra"
+)
+
+// assertCmd represents the assert command
+var assertCmd = &cobra.Command{
+	Use:   "assert <policy-file>",
+	Short: "Check live .well-known/webauthn endpoints against a policy file",
+	Long: `Check live .well-known/webauthn endpoints against a policy file.
+
+The policy file, in YAML or JSON, declares the expected state of each domain's document:
+the exact set of origins it lists, caller origins it must authorize, the maximum number
+of labels and the content type it is served with. Every check is optional:
+
+  domains:
+    - domain: example.com
+      origins: [https://example.com, https://example.co.uk]
+      authorize: [https://example.co.uk]
+      max_labels: 5
+      content_type: application/json
+
+Each domain is fetched and compared with its policy, and any drift is printed as a diff.
+The command exits with status 3 when any domain violates its policy.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		p, err := policy.Load(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		failed := 0
+		for _, d := range p.Domains {
+			if debug {
+				fmt.Printf("Debug: Testing domain: %s\n", d.Domain)
+			}
+
+			// A fetch error is reported as a violation like any other drift
+			result, err := counter.CountLabelsWithOptions(d.Domain, fetchOptions())
+			if err != nil {
+				result = &counter.LabelCount{ErrorMessage: err.Error()}
+			}
+
+			violations := policy.Evaluate(d, result)
+			if len(violations) == 0 {
+				fmt.Printf("PASS %s\n", d.Domain)
+				continue
+			}
+			failed++
+			fmt.Printf("FAIL %s\n", d.Domain)
+			fmt.Print(policy.FormatViolations(violations))
+		}
+
+		fmt.Printf("\n%d of %d domains match the policy\n", len(p.Domains)-failed, len(p.Domains))
+		exitOn(exitcode.Findings{Invalid: failed > 0})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(assertCmd)
+}
@@ -0,0 +1,425 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/developmeh/passkey-origin-validator/internal/forecast"
+	"github.com/developmeh/passkey-origin-validator/internal/orgs"
+	"github.com/developmeh/passkey-origin-validator/internal/store"
+	"github.com/developmeh/passkey-origin-validator/internal/tally"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	// concurrency is the number of domains processed in parallel
+	concurrency int
+	// resultsFile is the path of the JSON Lines file results are written to
+	resultsFile string
+	// summaryLimit is the number of records needing attention listed in the summary
+	summaryLimit int
+	// spillDir is the directory for the summary spill file
+	spillDir string
+	// maxFailures is the number or percentage of failed domains tolerated
+	maxFailures string
+	// shardSpec selects one partition of the domain list, as "i/n"
+	shardSpec string
+	// sampleSize is the number of domains randomly sampled from the list; 0 scans every domain
+	sampleSize int
+	// sampleSeed seeds the sample so that it can be reproduced
+	sampleSeed uint64
+	// urlTemplate is the URL of each tenant's document, with {tenant} for the tenant name
+	urlTemplate string
+	// storeSpec is the database every result is saved to, as "sqlite:path"
+	storeSpec string
+	// batchOutput is the format results are printed in
+	batchOutput string
+	// orgsFile is the organizations file whose organizations the summary is rolled up to
+	orgsFile string
+	// summaryJSON is the path the run summary block is written to as JSON
+	summaryJSON string
+)
+
+// batchCmd represents the batch command
+var batchCmd = &cobra.Command{
+	Use:   "batch <file>",
+	Short: "Count labels (and optionally validate an origin) for a list of domains",
+	Long: `Count labels (and optionally validate an origin) for a list of domains.
+
+This command reads one domain per line from the given file (or stdin when the file
+is "-"), fetches each domain's .well-known/webauthn endpoint, and prints one line
+per domain followed by a summary. With --origin, the caller origin is also validated
+against every domain. With --results, every result is written as a JSON Lines record.
+
+Use --max-requests, --max-bytes and --max-runtime to cap the resources a run may use.
+Use --shard i/n to process only one of n deterministic partitions of the list, so that
+several workers can split a large scan and merge their --results files afterwards.
+Use --sample n to scan a random subset of n domains; the same --seed selects the same
+subset again, so a quick trial run can be reproduced before committing to the full list.
+Use --max-failures to tolerate a number ("3") or percentage ("1%") of domains that fail
+to fetch before the run as a whole is marked failed.
+Use --store sqlite:path to also save every result to a SQLite database, which keeps the
+history of every scan for queries, trend reports and dashboards.
+
+For platforms that serve a document per tenant behind a shared host, use --url-template
+with a URL containing {tenant}, such as "https://{tenant}.example.com/.well-known/webauthn"
+or "https://login.example.com/{tenant}/.well-known/webauthn". Each line of the file is
+then a tenant name, and its document is fetched from the expanded URL.
+
+With --output csv, results are printed as CSV, one row per domain and caller origin with
+its status, label count, exceeds-limit flag, matched origin and error, for review in a
+spreadsheet. The summary is then printed to stderr.
+
+With --orgs, the summary is also rolled up to the organizations that own the domains,
+as declared in a YAML or JSON file:
+
+  organizations:
+    - name: Example Corp
+      domains: [example.com, example.co.uk]
+
+Each organization's report counts its domains that were checked, exceed the label
+limit, have an unauthorized caller origin, failed or are missing from the run, totals
+the labels used across its documents, and lists origins shared by several of them.
+
+With a forecast section in the configuration file, the summary and the organization
+reports also project when each document will use every label or grow past the size
+browsers read, from the origins and new labels expected each quarter:
+
+  forecast:
+    origins_per_quarter: 4
+    new_labels_per_quarter: 0.5
+    horizon_quarters: 8`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if batchOutput != "text" && batchOutput != "csv" {
+			fmt.Fprintf(os.Stderr, "Error: unknown output format %q: expected text or csv\n", batchOutput)
+			os.Exit(1)
+		}
+		if quiet && batchOutput == "csv" {
+			fmt.Fprintf(os.Stderr, "Error: --quiet cannot be combined with --output csv\n")
+			os.Exit(1)
+		}
+		// Keep stdout for the CSV rows, and report everything else on stderr; with --quiet,
+		// keep it for the verdicts alone
+		var report io.Writer = os.Stdout
+		var csvWriter *batch.CSVWriter
+		switch {
+		case batchOutput == "csv":
+			report = os.Stderr
+			csvWriter = batch.NewCSVWriter(os.Stdout)
+		case quiet:
+			report = io.Discard
+		}
+
+		failureBudget, err := batch.ParseFailureBudget(maxFailures)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var template batch.URLTemplate
+		if urlTemplate != "" {
+			template, err = batch.ParseURLTemplate(urlTemplate)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		var growth forecast.Growth
+		if err := viper.UnmarshalKey("forecast", &growth); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid forecast: %v\n", err)
+			os.Exit(1)
+		}
+		if err := growth.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		start := time.Now().UTC()
+
+		var rollup *orgs.Rollup
+		if orgsFile != "" {
+			o, err := orgs.Load(orgsFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			rollup = orgs.NewRollup(o)
+			if growth.Enabled() {
+				rollup.Forecast(growth, start)
+			}
+		}
+
+		domains, err := readLines(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Sample the list before sharding it, so that every worker shards the same subset
+		if sampleSize > 0 {
+			if !cmd.Flags().Changed("seed") {
+				sampleSeed = rand.Uint64()
+			}
+			total := len(domains)
+			domains = batch.Sample(domains, sampleSize, sampleSeed)
+			fmt.Fprintf(report, "Sampled %d of %d domains (--seed %d)\n", len(domains), total, sampleSeed)
+		}
+
+		// Keep only this worker's partition of the list
+		if shardSpec != "" {
+			shard, err := batch.ParseShard(shardSpec)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			total := len(domains)
+			domains = shard.Filter(domains)
+			if debug {
+				fmt.Printf("Debug: Shard %s has %d of %d domains\n", shard, len(domains), total)
+			}
+		}
+
+		if debug {
+			fmt.Printf("Debug: Processing %d domains with concurrency %d\n", len(domains), concurrency)
+		}
+
+		opts := batch.Options{
+			Concurrency:  concurrency,
+			Origin:       origin,
+			Fetch:        fetchOptions(),
+			Budget:       budget(),
+			URLTemplate:  template,
+			ModelVersion: modelVersion(),
+		}
+
+		// Keep only aggregate counters in memory; records needing attention spill to disk
+		aggregator, err := batch.NewAggregator(spillDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer aggregator.Close()
+		if growth.Enabled() {
+			aggregator.Forecast(growth, start)
+		}
+
+		// Stream every record to the JSON Lines results file as it completes
+		var encoder *json.Encoder
+		if resultsFile != "" {
+			f, err := os.Create(resultsFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to create results file: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			encoder = json.NewEncoder(f)
+		}
+
+		// Record the scan in the results database
+		db, scanID := openStore("batch")
+		if db != nil {
+			defer db.Close()
+		}
+
+		// Warnings are not counted in the summary, but may fail the run; the run summary
+		// block counts them
+		warned := false
+		runTally := tally.Start(start)
+		err = batch.Run(context.Background(), domains, opts, func(record batch.Record) error {
+			if len(record.Warnings) > 0 {
+				warned = true
+			}
+			runTally.Add(record.Outcome())
+			if encoder != nil {
+				if err := encoder.Encode(record); err != nil {
+					return fmt.Errorf("failed to write results file: %w", err)
+				}
+			}
+			if db != nil {
+				if err := db.Save(scanID, record); err != nil {
+					return err
+				}
+			}
+			switch {
+			case csvWriter != nil:
+				if err := csvWriter.Write(record); err != nil {
+					return fmt.Errorf("failed to write CSV: %w", err)
+				}
+			case quiet:
+				fmt.Println(batch.FormatVerdict(record))
+			default:
+				fmt.Println(batch.FormatRecord(record))
+			}
+			if rollup != nil {
+				rollup.Add(record)
+			}
+			return aggregator.Add(record)
+		})
+		if err == nil && csvWriter != nil {
+			err = csvWriter.Flush()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if db != nil {
+			if err := db.FinishScan(scanID, time.Now()); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		// Print the summary
+		summary := aggregator.Summary()
+		fmt.Fprintln(report)
+		fmt.Fprint(report, batch.FormatSummary(summary))
+
+		attention, total, err := aggregator.Attention(summaryLimit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if total > 0 {
+			fmt.Fprintf(report, "Needs attention (%d):\n", total)
+			for _, line := range attention {
+				fmt.Fprintf(report, "  %s\n", line)
+			}
+			if total > len(attention) {
+				fmt.Fprintf(report, "  ... and %d more\n", total-len(attention))
+			}
+		}
+		if rollup != nil {
+			fmt.Fprintln(report, "Organizations:")
+			fmt.Fprint(report, orgs.FormatReports(rollup.Reports()))
+			if n := rollup.Ungrouped(); n > 0 {
+				fmt.Fprintf(report, "Domains in no organization: %d\n", n)
+			}
+		}
+
+		if b := budget(); b != nil {
+			if debug {
+				fmt.Printf("Debug: Resources used: %s\n", b.Usage())
+			}
+			if err := b.Exhausted(); err != nil && summary.Skipped > 0 {
+				fmt.Fprintf(os.Stderr, "Error: %v; %d domains were skipped\n", err, summary.Skipped)
+			}
+		}
+
+		// Failures within the failure budget do not fail the run
+		tolerated := failureBudget.Tolerates(summary)
+		if summary.Failed > 0 {
+			verdict := "exceeding"
+			if tolerated {
+				verdict = "within"
+			}
+			fmt.Fprintf(report, "Failures: %d of %d domains, %s the failure budget of %d (--max-failures %s)\n",
+				summary.Failed, summary.Total, verdict, failureBudget.Allowed(summary.Total), failureBudget)
+		}
+
+		// End with the headline of the run, for people and dashboards
+		runTally.Finish(time.Now())
+		fmt.Fprintln(report)
+		fmt.Fprint(report, runTally.Format("domains"))
+		if summaryJSON != "" {
+			data, err := json.MarshalIndent(runTally, "", "  ")
+			if err == nil {
+				err = os.WriteFile(summaryJSON, append(data, '\n'), 0o644)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to write run summary: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		// Exit with the most severe status found
+		code := exitPolicy.Code(exitcode.Findings{
+			Error:   !tolerated || summary.Skipped > 0,
+			Invalid: summary.Invalid > 0,
+			Limit:   summary.ExceedsLimit > 0,
+			Warn:    warned,
+		})
+		if code != exitcode.OK {
+			aggregator.Close()
+			if db != nil {
+				db.Close()
+			}
+			os.Exit(code)
+		}
+	},
+}
+
+// openStore opens the --store database and records the start of a scan by command.
+// It returns a nil store when --store is not set.
+func openStore(command string) (*store.Store, int64) {
+	if storeSpec == "" {
+		return nil, 0
+	}
+	db, err := openStoreDB()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	scanID, err := db.StartScan(command, time.Now())
+	if err != nil {
+		db.Close()
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return db, scanID
+}
+
+// openStoreDB opens the --store database with the key from storeKey.
+func openStoreDB() (*store.Store, error) {
+	key, err := storeKey()
+	if err != nil {
+		return nil, err
+	}
+	return store.OpenWithOptions(storeSpec, store.Options{Key: key})
+}
+
+// storeKey returns the key of an encrypted --store, read base64-encoded from the
+// STORE_KEY environment variable or from the file named by STORE_KEY_FILE, such as a
+// secret mounted from a key management service. It returns nil when neither is set.
+func storeKey() ([]byte, error) {
+	text := viper.GetString("store_key")
+	if path := viper.GetString("store_key_file"); text == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read store key: %w", err)
+		}
+		text = string(data)
+	}
+	if text == "" {
+		return nil, nil
+	}
+	return store.ParseKey(text)
+}
+
+func init() {
+	rootCmd.AddCommand(batchCmd)
+
+	// Local flags
+	batchCmd.Flags().IntVar(&concurrency, "concurrency", 4, "Number of domains to process in parallel")
+	batchCmd.Flags().StringVar(&origin, "origin", "", "Caller origin to validate against every domain")
+	batchCmd.Flags().StringVar(&resultsFile, "results", "", "Write results to this file as JSON Lines")
+	batchCmd.Flags().IntVar(&summaryLimit, "summary-limit", 20, "Number of domains needing attention to list in the summary")
+	batchCmd.Flags().StringVar(&shardSpec, "shard", "", "Process only shard i of n (i/n) of the domain list")
+	batchCmd.Flags().IntVar(&sampleSize, "sample", 0, "Scan a random sample of this many domains from the list")
+	batchCmd.Flags().Uint64Var(&sampleSeed, "seed", 0, "Seed for --sample; the same seed selects the same domains (default is random)")
+	batchCmd.Flags().StringVar(&maxFailures, "max-failures", "0", "Failed domains tolerated before the run fails, as a count (3) or a percentage (1%)")
+	batchCmd.Flags().StringVar(&urlTemplate, "url-template", "", "Fetch each line of the file as a tenant from this URL, with {tenant} for the tenant name")
+	batchCmd.Flags().StringVar(&storeSpec, "store", "", "Save every result to this database (sqlite:path)")
+	batchCmd.Flags().StringVar(&batchOutput, "output", "text", "Output format: text or csv")
+	batchCmd.Flags().StringVar(&orgsFile, "orgs", "", "Roll up the summary to the organizations declared in this file")
+	batchCmd.Flags().StringVar(&summaryJSON, "summary-json", "", "Write the run summary block to this file as JSON")
+	batchCmd.Flags().StringVar(&spillDir, "spill-dir", "", "Directory for the temporary summary spill file (default is the system temp directory)")
+}
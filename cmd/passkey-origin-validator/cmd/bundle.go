@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+	"github.com/developmeh/passkey-origin-validator/internal/bundle"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/developmeh/passkey-origin-validator/internal/tally"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// bundleOut is the path the bundle is written to
+	bundleOut string
+	// bundleResults is the path of the JSON Lines file verified results are written to
+	bundleResults string
+)
+
+// bundleCmd represents the bundle command
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Capture domains' documents for an offline audit",
+	Long: `Capture domains' documents for an offline audit.
+
+Use "bundle create" on a host with internet access to fetch a list of domains and
+capture every response into a single file, then carry the file into a network with no
+internet egress and use "bundle verify" there to validate the domains from it.
+
+A bundle is a gzip-compressed tarball holding a manifest of the responses, with their
+status, headers and the SHA-256 digest of their body, and the bodies themselves. Every
+response is captured, including redirects and failed connections, so that the offline
+run sees exactly what the connected one did.`,
+}
+
+// bundleCreateCmd represents the bundle create command
+var bundleCreateCmd = &cobra.Command{
+	Use:   "create <file>",
+	Short: "Fetch a list of domains and capture the responses into a bundle",
+	Long: `Fetch a list of domains and capture the responses into a bundle.
+
+This command reads one domain per line from the given file (or stdin when the file is
+"-"), fetches each domain's .well-known/webauthn endpoint, bypassing the response
+cache, and prints one line per domain as the batch command does. Every response is
+captured, and the bundle is written to --out.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		domains, err := readLines(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// A bundle must capture what is served now, so skip the cache
+		b := bundle.New(domains, version, time.Now())
+		opts := batch.Options{
+			Concurrency:  concurrency,
+			Origin:       origin,
+			Fetch:        fetchOptions(),
+			Budget:       budget(),
+			ModelVersion: modelVersion(),
+		}
+		opts.Fetch.Transport = b.Record(newTransport())
+
+		runTally := tally.Start(time.Now())
+		err = batch.Run(context.Background(), domains, opts, func(record batch.Record) error {
+			runTally.Add(record.Outcome())
+			fmt.Println(batch.FormatRecord(record))
+			return nil
+		})
+		if err == nil {
+			err = b.Save(bundleOut)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("\nWrote %s: %d domains, %d responses (%d failed)\n", bundleOut, len(domains), len(b.Responses), b.Failed())
+		runTally.Finish(time.Now())
+		fmt.Println()
+		fmt.Print(runTally.Format("domains"))
+	},
+}
+
+// bundleVerifyCmd represents the bundle verify command
+var bundleVerifyCmd = &cobra.Command{
+	Use:   "verify <bundle>",
+	Short: "Validate the domains of a bundle offline",
+	Long: `Validate the domains of a bundle offline.
+
+The bundle is checked to be complete and untampered, each body against its digest, and
+then every domain it was created for is validated from the captured responses, as the
+batch command validates them, without any network access: the label count and limit,
+how the document was served, and with --origin whether a caller origin is authorized.
+A request the bundle has no response for fails as not captured.
+
+The command exits with the same status as the batch command would for the same results.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		b, err := bundle.Load(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Bundle %s: created %s", args[0], b.Created.Format(time.RFC3339))
+		if b.ToolVersion != "" {
+			fmt.Printf(" by version %s", b.ToolVersion)
+		}
+		fmt.Printf(", %d domains, %d responses\n\n", len(b.Domains), len(b.Responses))
+
+		opts := batch.Options{
+			Concurrency:  concurrency,
+			Origin:       origin,
+			Fetch:        fetchOptions(),
+			ModelVersion: modelVersion(),
+		}
+		opts.Fetch.Transport = b.Transport()
+
+		var encoder *json.Encoder
+		if bundleResults != "" {
+			f, err := os.Create(bundleResults)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to create results file: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			encoder = json.NewEncoder(f)
+		}
+
+		var found exitcode.Findings
+		runTally := tally.Start(time.Now())
+		err = batch.Run(context.Background(), b.Domains, opts, func(record batch.Record) error {
+			runTally.Add(record.Outcome())
+			found.Error = found.Error || record.Failed()
+			found.Invalid = found.Invalid || record.Invalid()
+			found.Limit = found.Limit || record.ExceedsLimit
+			found.Warn = found.Warn || len(record.Warnings) > 0
+			if encoder != nil {
+				if err := encoder.Encode(record); err != nil {
+					return fmt.Errorf("failed to write results file: %w", err)
+				}
+			}
+			fmt.Println(batch.FormatRecord(record))
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		runTally.Finish(time.Now())
+		fmt.Println()
+		fmt.Print(runTally.Format("domains"))
+		exitOn(found)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bundleCmd)
+	bundleCmd.AddCommand(bundleCreateCmd)
+	bundleCmd.AddCommand(bundleVerifyCmd)
+
+	// Local flags
+	bundleCmd.PersistentFlags().IntVar(&concurrency, "concurrency", 4, "Number of domains to process in parallel")
+	bundleCmd.PersistentFlags().StringVar(&origin, "origin", "", "Caller origin to validate against every domain")
+	bundleCreateCmd.Flags().StringVarP(&bundleOut, "out", "o", "bundle.tar.gz", "Path the bundle is written to")
+	bundleVerifyCmd.Flags().StringVar(&bundleResults, "results", "", "Write results to this file as JSON Lines")
+}
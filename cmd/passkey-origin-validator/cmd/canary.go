@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/docdiff"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// canaryOrigins are caller origins the candidate must authorize
+	canaryOrigins []string
+	// canaryWait is how long to wait for the live endpoint to serve the candidate
+	canaryWait time.Duration
+	// canaryPoll is the interval between checks of the live endpoint
+	canaryPoll time.Duration
+)
+
+// canaryCmd represents the canary command
+var canaryCmd = &cobra.Command{
+	Use:   "canary <candidate-file> [domain]",
+	Short: "Approve a candidate document and verify its rollout",
+	Long: `Approve a candidate document and verify its rollout.
+
+Before deployment, the candidate file is checked with the same rules as the lint
+command, and each caller origin given with --origin must be authorized by it. The
+command exits with status 3 if the candidate has errors.
+
+After deployment, give the domain the candidate was deployed to. Once the candidate is
+approved, the live .well-known/webauthn endpoint is fetched and compared with it at the
+semantic level, like the diff command. With --wait, the endpoint is polled every --poll
+until it serves the candidate or the wait is over, so that the command can follow a
+rollout that takes a while to reach every server or cache. The command exits with
+status 3 if the live document still diverges from the candidate. Responses are always
+fetched live, bypassing the response cache.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		candidateFile := args[0]
+
+		// Approve the candidate
+		candidate, err := counter.CountLabelsFromFileWithOptions(candidateFile, fetchOptions())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		document := []byte(candidate.RawJSON)
+		findings := lint.Check(document, lint.Options{CallerOrigins: canaryOrigins, Source: candidateFile, MaxLabels: maxLabels})
+		fmt.Printf("Checking candidate %s\n", candidateFile)
+		fmt.Print(lint.FormatFindings(findings))
+		if lint.HasErrors(findings) {
+			fmt.Fprintf(os.Stderr, "Error: the candidate is not approved until the findings above are fixed\n")
+			os.Exit(3)
+		}
+		fmt.Println("Candidate approved")
+		if len(args) < 2 {
+			return
+		}
+		domain := args[1]
+
+		// A rollout must be seen as soon as it is served, so skip the cache
+		fetch := fetchOptions()
+		fetch.Transport = newTransport()
+
+		// Stop cleanly on interrupt
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		runDNSPreflight(domain)
+		deadline := time.Now().Add(canaryWait)
+		for {
+			if debug {
+				fmt.Printf("Debug: Testing domain: %s\n", domain)
+			}
+			d, err := compareLive(domain, document, fetch)
+			if err == nil && d.Empty() {
+				fmt.Printf("%s serves the candidate\n", domain)
+				return
+			}
+
+			// Give up once the wait is over
+			if !time.Now().Add(canaryPoll).Before(deadline) {
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("%s diverges from the candidate:\n", domain)
+				fmt.Printf("--- %s\n+++ %s\n", candidateFile, domain)
+				fmt.Print(docdiff.Format(d))
+				os.Exit(3)
+			}
+
+			if err != nil {
+				fmt.Printf("Waiting for %s: %v\n", domain, err)
+			} else {
+				fmt.Printf("Waiting for %s: the live document diverges from the candidate\n", domain)
+			}
+			select {
+			case <-ctx.Done():
+				fmt.Fprintf(os.Stderr, "Error: interrupted before %s served the candidate\n", domain)
+				os.Exit(1)
+			case <-time.After(canaryPoll):
+			}
+		}
+	},
+}
+
+// compareLive fetches the live document of domain and compares the candidate with it.
+func compareLive(domain string, candidate []byte, fetch counter.Options) (*docdiff.Diff, error) {
+	result, err := counter.CountLabelsWithOptions(domain, fetch)
+	if err != nil {
+		return nil, err
+	}
+	if result.ErrorMessage != "" && result.RawJSON == "" {
+		return nil, fmt.Errorf("%s", result.ErrorMessage)
+	}
+	return docdiff.CompareWithMaxLabels(candidate, []byte(result.RawJSON), canaryOrigins, maxLabels)
+}
+
+func init() {
+	rootCmd.AddCommand(canaryCmd)
+
+	// Local flags
+	canaryCmd.Flags().StringSliceVar(&canaryOrigins, "origin", nil, "Caller origin the candidate must authorize (repeatable)")
+	canaryCmd.Flags().DurationVar(&canaryWait, "wait", 0, "How long to wait for the live endpoint to serve the candidate (0 checks once)")
+	canaryCmd.Flags().DurationVar(&canaryPoll, "poll", 10*time.Second, "Interval between checks of the live endpoint while waiting")
+}
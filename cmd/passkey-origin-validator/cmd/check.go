@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/developmeh/passkey-origin-validator/internal/rpid"
+	"github.com/spf13/cobra"
+)
+
+// checkOrigin is the caller origin to check
+var checkOrigin string
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check <rp-id>",
+	Short: "Check whether a caller origin may use an RP ID at all",
+	Long: `Check whether a caller origin may use an RP ID at all.
+
+This command answers the question a browser asks: may this caller origin use this RP
+ID? It first applies the standard RP ID rules, under which the RP ID must be the
+caller's effective domain or a registrable domain suffix of it (see the rpid command).
+Only when the RP ID is another site does it fetch the RP ID's .well-known/webauthn
+document and validate the caller against its related origins. The output reports which
+path, if any, allows the caller.
+
+If the --file flag is provided, the document is read from the specified file instead
+of being fetched.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		rpID := args[0]
+		var documentURL string
+		unsupported := relatedOriginsUnsupported()
+		related := func(rpID, callerOrigin string) (counter.AuthenticatorStatus, error) {
+			// The pinned Chromium milestone never fetches the document
+			if unsupported != "" {
+				return counter.StatusBadRelyingPartyIDNoJSONMatch, nil
+			}
+			var result *counter.LabelCount
+			var err error
+			if file != "" {
+				result, err = counter.CountLabelsFromFileWithOptions(file, fetchOptions())
+			} else {
+				runDNSPreflight(rpID)
+				result, err = counter.CountLabelsWithOptions(rpID, fetchOptions())
+			}
+			if err != nil {
+				return 0, err
+			}
+			documentURL = result.URL
+			if result.ErrorMessage != "" {
+				return 0, errors.New(result.ErrorMessage)
+			}
+			return counter.ValidateWellKnownJSONWithMaxLabels(callerOrigin, []byte(result.RawJSON), maxLabels), nil
+		}
+
+		fmt.Printf("Checking whether %s may use RP ID %s\n", checkOrigin, rpID)
+		printModel()
+		decision, err := rpid.Decide(checkOrigin, rpID, related)
+		fmt.Printf("RP ID rules: %s (%s)\n", decision.RPID.Status, decision.RPID.Reason)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to check related origins: %v\n", err)
+			exitOn(exitcode.Findings{Error: true})
+			return
+		}
+		switch {
+		case decision.RelatedOriginsChecked && unsupported != "":
+			fmt.Printf("Related origins: not checked (%s)\n", unsupported)
+		case decision.RelatedOriginsChecked:
+			fmt.Printf("Related origins: %s (%s)\n", decision.RelatedOrigins, documentURL)
+		}
+
+		switch decision.Path {
+		case rpid.PathRPID:
+			fmt.Println("Allowed: yes, by the RP ID rules; no .well-known/webauthn document is needed")
+		case rpid.PathRelatedOrigins:
+			fmt.Println("Allowed: yes, as a related origin")
+		default:
+			fmt.Println("Allowed: no")
+		}
+
+		// Exit with non-zero status if the caller may not use the RP ID
+		exitOn(exitcode.Findings{Invalid: !decision.Allowed})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	// Local flags for the check command
+	checkCmd.Flags().StringVar(&checkOrigin, "origin", "", "The caller origin to check (required)")
+	checkCmd.MarkFlagRequired("origin")
+}
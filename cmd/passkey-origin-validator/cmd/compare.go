@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// compareOrigin is the first caller origin to compare
+	compareOrigin string
+	// compareOther is the second caller origin to compare
+	compareOther string
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare [rp-id]",
+	Short: "Explain why one caller origin validates and another does not",
+	Long: `Explain why one caller origin validates and another does not.
+
+This command fetches the .well-known/webauthn endpoint of the relying party once and
+checks both caller origins against it, as the explain command does for one. It prints a
+side-by-side trace with what a browser does with each entry of the origins array for
+each caller (match, no match, ignored because of the label limit, skipped or not
+examined), the status each caller reaches, why, and how the two caller origins differ
+in scheme, host, port and label.
+
+If no RP ID is provided, it uses the default domain (webauthn.io).
+If the --file flag is provided, it reads from the specified file instead.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var result *counter.LabelCount
+		var err error
+
+		// Check if we're reading from a file
+		if file != "" {
+			if debug {
+				fmt.Printf("Debug: Reading from file: %s\n", file)
+			}
+			result, err = counter.CountLabelsFromFileWithOptions(file, fetchOptions())
+		} else {
+			// Get the RP ID from command-line arguments or use the default
+			domain := "https://webauthn.io"
+			if len(args) > 0 {
+				domain = args[0]
+			}
+
+			if debug {
+				fmt.Printf("Debug: Testing domain: %s\n", domain)
+			}
+
+			runDNSPreflight(domain)
+			result, err = counter.CountLabelsWithOptions(domain, fetchOptions())
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exitOn(exitcode.Findings{Error: true})
+			return
+		}
+
+		// No browser reaches the origins of a document it refused
+		if result.ErrorMessage != "" && result.RawJSON == "" {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", result.ErrorMessage)
+			if result.Remediation != "" {
+				fmt.Fprintf(os.Stderr, "Remediation: %s\n", result.Remediation)
+			}
+			exitOn(exitcode.Findings{Error: true})
+			return
+		}
+
+		comparison := counter.CompareWithMaxLabels(compareOrigin, compareOther, []byte(result.RawJSON), maxLabels)
+		fmt.Printf("Comparing caller origins %s and %s against %s\n", compareOrigin, compareOther, result.URL)
+		printModel()
+		fmt.Println()
+
+		// Print the side-by-side trace
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "ENTRY\t%s\t%s\n", compareOrigin, compareOther)
+		for i, entry := range comparison.Origins {
+			fmt.Fprintf(w, "origins[%d] %s\t%s\t%s\n", i, entry, comparison.A.Entries[i], comparison.B.Entries[i])
+		}
+		fmt.Fprintf(w, "Labels charged\t%d of %d\t%d of %d\n", len(comparison.A.Labels), comparison.A.MaxLabels, len(comparison.B.Labels), comparison.B.MaxLabels)
+		fmt.Fprintf(w, "Status\t%s\t%s\n", comparison.A.Status, comparison.B.Status)
+		w.Flush()
+
+		fmt.Println("\nWhy:")
+		fmt.Printf("- %s: %s\n", compareOrigin, comparison.ReasonA)
+		fmt.Printf("- %s: %s\n", compareOther, comparison.ReasonB)
+		if len(comparison.Differences) > 0 {
+			fmt.Println("\nThe caller origins differ in:")
+			for _, difference := range comparison.Differences {
+				fmt.Printf("- %s\n", difference)
+			}
+		}
+
+		// Exit with non-zero status if either caller origin is not authorized
+		exitOn(exitcode.Findings{Invalid: comparison.A.Status != counter.StatusSuccess || comparison.B.Status != counter.StatusSuccess})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+
+	// Local flags for the compare command
+	compareCmd.Flags().StringVar(&compareOrigin, "origin", "", "The first caller origin to compare (required)")
+	compareCmd.Flags().StringVar(&compareOther, "other", "", "The second caller origin to compare (required)")
+	compareCmd.MarkFlagRequired("origin")
+	compareCmd.MarkFlagRequired("other")
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/developmeh/passkey-origin-validator/internal/browser"
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+// compatOrigin is the caller origin to evaluate
+var compatOrigin string
+
+// compatCmd represents the compat command
+var compatCmd = &cobra.Command{
+	Use:   "compat [domain]",
+	Short: "Print which browsers authorize a caller origin as a related origin",
+	Long: `Print which browsers authorize a caller origin as a related origin.
+
+This command fetches the .well-known/webauthn endpoint for a given domain and evaluates
+the caller origin with every browser profile at once (chromium, safari, firefox and
+spec, as with validate --browser). It prints a compatibility matrix with the outcome in
+each browser, followed by a one-line summary such as "Works in Chrome 128+ and Safari
+18+; unsupported in Firefox".
+
+A browser that does not implement related origin requests is reported as unsupported,
+but does not make the command fail; only a browser that implements them and rejects
+the caller origin does.
+
+If no domain is provided, it uses the default domain (webauthn.io).
+If the --file flag is provided, it reads from the specified file instead.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var result *counter.LabelCount
+		var err error
+
+		// Check if we're reading from a file
+		if file != "" {
+			if debug {
+				fmt.Printf("Debug: Reading from file: %s\n", file)
+			}
+			result, err = counter.CountLabelsFromFileWithOptions(file, fetchOptions())
+		} else {
+			// Get the domain from command-line arguments or use the default
+			domain := "https://webauthn.io"
+			if len(args) > 0 {
+				domain = args[0]
+			}
+
+			if debug {
+				fmt.Printf("Debug: Testing domain: %s\n", domain)
+			}
+
+			runDNSPreflight(domain)
+			result, err = counter.CountLabelsWithOptions(domain, fetchOptions())
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exitOn(exitcode.Findings{Error: true})
+			return
+		}
+
+		// No browser reaches the origins of a document that could not be read
+		if result.ErrorMessage != "" && result.RawJSON == "" {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", result.ErrorMessage)
+			if result.Remediation != "" {
+				fmt.Fprintf(os.Stderr, "Remediation: %s\n", result.Remediation)
+			}
+			exitOn(exitcode.Findings{Error: true})
+			return
+		}
+
+		profiles, err := browser.WithChromium(browser.Profiles(), chromiumVersion)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		outcomes := browser.Evaluate(profiles, compatOrigin, result)
+
+		// Print the matrix
+		fmt.Printf("Compatibility of caller origin %s with %s\n", compatOrigin, result.URL)
+		printModel()
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BROWSER\tVERSIONS\tLABEL LIMIT\tOUTCOME")
+		rejected := false
+		for i, outcome := range outcomes {
+			limit := "-"
+			if profiles[i].Supported() {
+				limit = fmt.Sprint(profiles[i].MaxLabels())
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", outcome.Browser, profiles[i].Description(), limit, outcome)
+			rejected = rejected || (outcome.Supported && !outcome.Allowed())
+		}
+		w.Flush()
+		fmt.Printf("\n%s\n", browser.Summarize(outcomes))
+
+		// Exit with non-zero status if a browser that supports related origins rejects the caller
+		exitOn(exitcode.Findings{Invalid: rejected})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(compatCmd)
+
+	// Local flags for the compat command
+	compatCmd.Flags().StringVar(&compatOrigin, "origin", "", "The caller origin to evaluate (required)")
+	compatCmd.MarkFlagRequired("origin")
+}
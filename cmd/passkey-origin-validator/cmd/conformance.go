@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/developmeh/passkey-origin-validator/internal/conformance"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+// conformanceCorpus is the path of a corpus to run instead of the built-in one
+var conformanceCorpus string
+
+// conformanceCmd represents the conformance command
+var conformanceCmd = &cobra.Command{
+	Use:   "conformance",
+	Short: "Check that the validator reproduces browser test vectors",
+	Long: `Check that the validator reproduces browser test vectors.
+
+This command runs the validator against a corpus of test vectors transcribed from
+Chromium's unit tests for .well-known/webauthn validation and for registrable domains:
+the status a document yields for a caller origin, such as when the label limit is hit,
+and the label each origin counts towards. It prints the outcome of every vector and
+the share the validator reproduces, so you can tell how closely its results follow
+what a browser does.
+
+With --corpus, the vectors are read from a JSON file in the same format instead, such
+as to add cases of your own. No network requests are made.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var corpus *conformance.Corpus
+		var err error
+		if conformanceCorpus != "" {
+			corpus, err = conformance.Load(conformanceCorpus)
+		} else {
+			corpus, err = conformance.Parse(conformance.Builtin)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		report := conformance.Run(corpus)
+		printModel()
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RESULT\tCASE\tEXPECTED\tGOT")
+		for _, result := range report.Results {
+			outcome := "PASS"
+			if !result.Passed() {
+				outcome = "FAIL"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", outcome, result.Case, result.Expected, result.Got)
+		}
+		w.Flush()
+		fmt.Println()
+		fmt.Print(conformance.Format(report))
+
+		// Exit with non-zero status if a vector is not reproduced
+		exitOn(exitcode.Findings{Invalid: len(report.Failures()) > 0})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(conformanceCmd)
+
+	// Local flags for the conformance command
+	conformanceCmd.Flags().StringVar(&conformanceCorpus, "corpus", "", "JSON file of test vectors to run instead of the built-in corpus")
+}
@@ -5,9 +5,14 @@ import (
 	"os"
 
 	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
 	"github.com/spf13/cobra"
 )
 
+// countOrder also reports which entries browsers reach before the label budget is exhausted
+var countOrder bool
+
 // countCmd represents the count command
 var countCmd = &cobra.Command{
 	Use:   "count [domain]",
@@ -17,6 +22,13 @@ var countCmd = &cobra.Command{
 This command fetches the .well-known/webauthn endpoint for a given domain,
 parses the JSON response, and counts the number of unique labels.
 
+With --order, it also reports, for each entry of the origins array, whether a browser
+reaches it before the label budget is exhausted. Browsers stop charging new labels once
+the budget is used, so the order of the array decides which entries are dead: an entry
+whose label is first seen after that point is ignored, and is reported along with where
+it would have to move to be counted. An entry that repeats an earlier origin is
+reported as shadowed, since matching stops at the first.
+
 If no domain is provided, it uses the default domain (webauthn.io).
 If the --file flag is provided, it reads from the specified file instead.`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -34,7 +46,7 @@ If the --file flag is provided, it reads from the specified file instead.`,
 			if debug {
 				fmt.Printf("Debug: Reading from file: %s\n", file)
 			}
-			result, err = counter.CountLabelsFromFile(file)
+			result, err = counter.CountLabelsFromFileWithOptions(file, fetchOptions())
 		} else {
 			// Get the domain from command-line arguments or use the default
 			domain := "https://webauthn.io"
@@ -44,34 +56,84 @@ If the --file flag is provided, it reads from the specified file instead.`,
 
 			if debug {
 				fmt.Printf("Debug: Testing domain: %s\n", domain)
-				fmt.Printf("Debug: Max labels allowed: %d\n", counter.MaxLabels)
+				fmt.Printf("Debug: Max labels allowed: %d\n", maxLabels)
 			}
 
-			result, err = counter.CountLabels(domain)
+			runDNSPreflight(domain)
+			result, err = counter.CountLabelsWithOptions(domain, fetchOptions())
 		}
 
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			if quiet {
+				printVerdict(args, nil, err, "")
+			} else {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			exitOn(exitcode.Findings{Error: true})
+			return
 		}
 
 		// Debug logging
 		if debug && result.ErrorMessage == "" {
 			fmt.Printf("Debug: Found %d unique labels\n", result.Count)
 			fmt.Printf("Debug: Labels: %v\n", result.LabelsFound)
+			fmt.Printf("Debug: Android app origins: %v\n", result.AndroidOrigins)
 			fmt.Printf("Debug: Exceeds limit: %v\n", result.ExceedsLimit)
 		}
 
-		// Print the results
-		fmt.Println(counter.FormatResults(result))
+		// Find the origins beyond the label limit and the problems with how the document
+		// was served, at their configured severities
+		findings := lint.ServingFindings(result.Warnings, result.URL)
+		for _, finding := range lint.Check([]byte(result.RawJSON), lint.Options{Source: result.URL, MaxLabels: maxLabels}) {
+			if finding.Rule == lint.RuleLabelLimit {
+				findings = append(findings, finding)
+			}
+		}
+		findings = applySeverity(findings)
 
-		// Exit with non-zero status if the number of labels exceeds the limit
-		if result.ExceedsLimit {
-			os.Exit(2)
+		// Print the results, with only the warnings at or above --min-severity
+		displayed := *result
+		displayed.Warnings = nil
+		for _, finding := range findings {
+			if finding.Rule == lint.RuleServing {
+				displayed.Warnings = append(displayed.Warnings, finding.Message)
+			}
 		}
+		if quiet {
+			printVerdict(args, result, nil, "")
+		} else {
+			fmt.Println(counter.FormatResults(&displayed))
+			if diagnose && result.ParseError != nil {
+				printDiagnosis(os.Stdout, []byte(result.RawJSON))
+			}
+			printModel()
+			if countOrder && result.RawJSON != "" {
+				if analysis, err := counter.AnalyzeOrder([]byte(result.RawJSON), maxLabels); err == nil {
+					fmt.Println()
+					fmt.Print(counter.FormatOrder(analysis))
+				}
+			}
+		}
+
+		// Exit with non-zero status if the document failed or exceeds the label limit
+		found := exitcode.Findings{Error: result.ErrorMessage != ""}
+		for _, finding := range findings {
+			switch {
+			case finding.Severity == lint.SeverityError && finding.Rule == lint.RuleLabelLimit:
+				found.Limit = true
+			case finding.Severity == lint.SeverityError:
+				found.Invalid = true
+			case finding.Severity == lint.SeverityWarning:
+				found.Warn = true
+			}
+		}
+		exitOn(found)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(countCmd)
+
+	// Local flags
+	countCmd.Flags().BoolVar(&countOrder, "order", false, "Report which entries browsers reach before the label budget is exhausted")
 }
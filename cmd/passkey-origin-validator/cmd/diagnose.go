@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// printDiagnosis prints what tolerant parsing finds in a document that is not valid
+// JSON. The document is still reported as browsers see it, so the diagnosis only
+// explains the failure and never changes the exit status.
+func printDiagnosis(w io.Writer, document []byte) {
+	d := counter.Diagnose(document)
+	fmt.Fprintln(w, "\nDiagnosis: browsers reject this document. Parsed tolerantly, it has these problems:")
+	for _, repair := range d.Repairs {
+		fmt.Fprintf(w, "  %s\n", repair)
+	}
+	if len(d.Repairs) == 0 {
+		fmt.Fprintln(w, "  none that tolerant parsing recognizes")
+	}
+	switch {
+	case d.Remaining != "" && len(d.Repairs) > 0:
+		fmt.Fprintf(w, "Even with these fixed, the repaired document cannot be parsed: %s\n", d.Remaining)
+	case d.Remaining != "":
+		fmt.Fprintf(w, "It cannot be parsed: %s\n", d.Remaining)
+	default:
+		labels := strings.Join(d.Labels, ", ")
+		if labels == "" {
+			labels = "none"
+		}
+		fmt.Fprintf(w, "With these fixed, it parses, with %d origins and the labels %s\n", len(d.Origins), labels)
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/docdiff"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+// diffOrigins are caller origins whose validation outcome is compared
+var diffOrigins []string
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <before> <after>",
+	Short: "Compare two .well-known/webauthn documents",
+	Long: `Compare two .well-known/webauthn documents.
+
+Each source is either a local file or a domain or URL whose .well-known/webauthn
+endpoint is fetched, so a file can be compared with another file, with the live
+document, or two live documents with each other.
+
+The documents are compared at the semantic level, so reordering or reformatting
+them is not a difference. The report lists the origins and labels added and removed,
+and the origins whose validation outcome changed: every origin either document lists
+is checked, along with any caller origins given with --origin.
+
+The command exits with status 3 when an origin that was authorized before is not
+authorized after.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		before, err := loadDocument(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		after, err := loadDocument(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		d, err := docdiff.CompareWithMaxLabels(before, after, diffOrigins, maxLabels)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("--- %s\n+++ %s\n", args[0], args[1])
+		fmt.Print(docdiff.Format(d))
+
+		// Exit with non-zero status if the change takes an origin's authorization away
+		exitOn(exitcode.Findings{Invalid: d.Regressed()})
+	},
+}
+
+// loadDocument reads the document at source, which is a local file if one exists at
+// that path and a domain or URL to fetch otherwise.
+func loadDocument(source string) ([]byte, error) {
+	var result *counter.LabelCount
+	var err error
+	if info, statErr := os.Stat(source); statErr == nil && info.Mode().IsRegular() {
+		if debug {
+			fmt.Printf("Debug: Reading from file: %s\n", source)
+		}
+		result, err = counter.CountLabelsFromFileWithOptions(source, fetchOptions())
+	} else {
+		if debug {
+			fmt.Printf("Debug: Testing domain: %s\n", source)
+		}
+		runDNSPreflight(source)
+		result, err = counter.CountLabelsWithOptions(source, fetchOptions())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A document that was not served as JSON cannot be compared
+	if result.ErrorMessage != "" && result.RawJSON == "" {
+		return nil, fmt.Errorf("%s: %s", source, result.ErrorMessage)
+	}
+	return []byte(result.RawJSON), nil
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	// Local flags
+	diffCmd.Flags().StringSliceVar(&diffOrigins, "origin", nil, "Caller origin whose validation outcome is compared (repeatable)")
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/developmeh/passkey-origin-validator/internal/doctor"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// checkDualStack enables the IPv4/IPv6 comparison check
+	checkDualStack bool
+	// checkAllIPs enables the per-address replica comparison check
+	checkAllIPs bool
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [domain]",
+	Short: "Diagnose how a domain serves its .well-known/webauthn endpoint",
+	Long: `Diagnose how a domain serves its .well-known/webauthn endpoint.
+
+This command probes the .well-known/webauthn endpoint in several ways and reports
+anything that could cause a browser to see a different document than this tool,
+such as responses that vary on the Accept, User-Agent or Cookie request headers.
+
+If no domain is provided, it uses the default domain (webauthn.io).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get the domain from command-line arguments or use the default
+		domain := "https://webauthn.io"
+		if len(args) > 0 {
+			domain = args[0]
+		}
+
+		if debug {
+			fmt.Printf("Debug: Diagnosing domain: %s\n", domain)
+		}
+
+		runDNSPreflight(domain)
+
+		// The checks dial their own way, but with the transport settings of every fetch
+		transport, dialer := newBaseTransport()
+		report, err := doctor.Diagnose(domain, doctor.Options{
+			Timeout:        timeout,
+			Transport:      transport,
+			Dialer:         dialer,
+			Resolver:       resolver(),
+			CheckDualStack: checkDualStack,
+			CheckAllIPs:    checkAllIPs,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Print the report
+		fmt.Print(doctor.FormatReport(report))
+
+		// Exit with non-zero status if any check failed
+		if report.Failed() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	// Local flags
+	doctorCmd.Flags().BoolVar(&checkDualStack, "check-dualstack", false, "Fetch the endpoint over IPv4 and IPv6 separately and compare the responses")
+	doctorCmd.Flags().BoolVar(&checkAllIPs, "check-all-ips", false, "Fetch the endpoint from every resolved address and verify all replicas serve identical content")
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+// explainOrigin is the caller origin to explain
+var explainOrigin string
+
+// explainCmd represents the explain command
+var explainCmd = &cobra.Command{
+	Use:   "explain [rp-id]",
+	Short: "Narrate what a browser does to check a caller origin against a relying party",
+	Long: `Narrate what a browser does to check a caller origin against a relying party.
+
+This command fetches the .well-known/webauthn endpoint of the relying party, as a
+browser would for a caller origin that is not within the RP ID, and prints every step
+the browser takes: the URL it fetches, the response it receives, each origin it parses,
+each label it charges against the limit of 5, and where matching stops. Each step
+names the rule of the WebAuthn specification or Chromium that applies, and the last
+line is the status a browser would reach.
+
+If no RP ID is provided, it uses the default domain (webauthn.io).
+If the --file flag is provided, it reads from the specified file instead.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var result *counter.LabelCount
+		var err error
+
+		// Check if we're reading from a file
+		if file != "" {
+			if debug {
+				fmt.Printf("Debug: Reading from file: %s\n", file)
+			}
+			result, err = counter.CountLabelsFromFileWithOptions(file, fetchOptions())
+		} else {
+			// Get the RP ID from command-line arguments or use the default
+			domain := "https://webauthn.io"
+			if len(args) > 0 {
+				domain = args[0]
+			}
+
+			if debug {
+				fmt.Printf("Debug: Testing domain: %s\n", domain)
+			}
+
+			runDNSPreflight(domain)
+			result, err = counter.CountLabelsWithOptions(domain, fetchOptions())
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exitOn(exitcode.Findings{Error: true})
+			return
+		}
+
+		fmt.Printf("Explaining caller origin %s against %s\n", explainOrigin, result.URL)
+		printModel()
+		fmt.Println()
+		steps := counter.FetchSteps(result)
+
+		// A browser never reaches the origins of a document it refused
+		if result.ErrorMessage != "" && result.RawJSON == "" {
+			fmt.Print(counter.FormatSteps(steps))
+			if result.Remediation != "" {
+				fmt.Printf("Remediation: %s\n", result.Remediation)
+			}
+			exitOn(exitcode.Findings{Error: true})
+			return
+		}
+
+		explanation := counter.ExplainWithMaxLabels(explainOrigin, []byte(result.RawJSON), maxLabels)
+		fmt.Print(counter.FormatSteps(append(steps, explanation.Steps...)))
+		fmt.Printf("\nStatus: %s\n", explanation.Status)
+
+		// Exit with non-zero status if the caller origin is not authorized
+		exitOn(exitcode.Findings{Invalid: explanation.Status != counter.StatusSuccess})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+
+	// Local flags for the explain command
+	explainCmd.Flags().StringVar(&explainOrigin, "origin", "", "The caller origin to explain (required)")
+	explainCmd.MarkFlagRequired("origin")
+}
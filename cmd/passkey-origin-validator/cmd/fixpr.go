@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/developmeh/passkey-origin-validator/internal/fixpr"
+	"github.com/developmeh/passkey-origin-validator/internal/issues"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	// fixPRPath is the path of the .well-known/webauthn source file in the repository
+	fixPRPath string
+	// fixPRRemote is the Git remote the fix is pushed to
+	fixPRRemote string
+	// fixPRBase is the branch the pull request targets
+	fixPRBase string
+	// fixPRBranch is the branch the fix is committed on
+	fixPRBranch string
+	// fixPRGitHub is the GitHub repository the pull request is opened in
+	fixPRGitHub string
+	// fixPROrigins are caller origins the document must authorize
+	fixPROrigins []string
+)
+
+// fixPRCmd represents the fix-pr command
+var fixPRCmd = &cobra.Command{
+	Use:   "fix-pr <repo>",
+	Short: "Open a pull request that applies lint --fix to a .well-known/webauthn source file",
+	Long: `Open a pull request that applies lint --fix to a .well-known/webauthn source file.
+
+This command reads the source file at --path in the local Git repository <repo> and
+rewrites it in canonical form, as lint --fix does. If that changes the file, the fix is
+committed on a new branch, pushed to --remote and proposed as a pull request against
+--base, with the changes, the findings the fix resolves and the findings left to fix by
+hand as the description. The checkout of <repo> is left untouched: the fix is committed
+in a temporary worktree.
+
+Branches are named after the fixed content, so running the command again for a fix
+that was already proposed fails instead of opening a duplicate pull request.
+
+The pull request is opened in the GitHub repository of the remote, or in --github. The
+token is read from GITHUB_TOKEN, or from github_token in the configuration file.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		repo := args[0]
+
+		githubRepo := fixPRGitHub
+		if githubRepo == "" {
+			var err error
+			if githubRepo, err = fixpr.RemoteRepo(ctx, repo, fixPRRemote); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		token := viper.GetString("github_token")
+		if token == "" {
+			fmt.Fprintf(os.Stderr, "Error: a GitHub token is required to open pull requests in %s\n", githubRepo)
+			os.Exit(1)
+		}
+		host, err := issues.NewGitHub(newClient(), issues.GitHubAPI, githubRepo, token)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		result, err := fixpr.Propose(ctx, host, fixpr.Options{
+			Repo:          repo,
+			Path:          fixPRPath,
+			Remote:        fixPRRemote,
+			Base:          fixPRBase,
+			Branch:        fixPRBranch,
+			CallerOrigins: fixPROrigins,
+			MaxLabels:     maxLabels,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if !result.Changed {
+			fmt.Printf("%s is already in canonical form; no pull request opened\n", fixPRPath)
+		} else {
+			fmt.Printf("Opened %s (%s into %s)\n", result.URL, result.Branch, result.Base)
+			fmt.Printf("Findings fixed: %d\n", len(result.Fixed))
+		}
+		if len(result.Remaining) > 0 {
+			fmt.Println("Findings left to fix by hand:")
+			fmt.Print(lint.FormatFindings(result.Remaining))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fixPRCmd)
+
+	// Local flags for the fix-pr command
+	fixPRCmd.Flags().StringVar(&fixPRPath, "path", "", "Path of the .well-known/webauthn source file, relative to the repository (required)")
+	fixPRCmd.Flags().StringVar(&fixPRRemote, "remote", "origin", "Git remote to push the fix to")
+	fixPRCmd.Flags().StringVar(&fixPRBase, "base", "", "Branch the pull request targets (default is the current branch)")
+	fixPRCmd.Flags().StringVar(&fixPRBranch, "branch", "", "Branch to commit the fix on (default is named after the fixed content)")
+	fixPRCmd.Flags().StringVar(&fixPRGitHub, "github", "", "GitHub repository (owner/repo) to open the pull request in (default is the remote's)")
+	fixPRCmd.Flags().StringSliceVar(&fixPROrigins, "origin", nil, "Caller origin the document must authorize (repeatable)")
+	fixPRCmd.MarkFlagRequired("path")
+}
@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/appleapp"
+	"github.com/developmeh/passkey-origin-validator/internal/assetlinks"
+	"github.com/developmeh/passkey-origin-validator/internal/generate"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// originsFile is a file listing the origins to generate a document for, one per line
+	originsFile string
+	// generateOutput is the path the document is written to; stdout when empty
+	generateOutput string
+	// generateCallerOrigins are caller origins the generated document must authorize
+	generateCallerOrigins []string
+	// strict also refuses documents with warnings
+	strict bool
+	// interactive prompts for origins one at a time
+	interactive bool
+	// importApple are the apple-app-site-association files to import origins from, as
+	// domain or domain=path
+	importApple []string
+	// importAssetLinks are the assetlinks.json files to import origins from, as domain or
+	// domain=path
+	importAssetLinks []string
+	// importAndroidOrigins also imports the Android app origins of the assetlinks.json files
+	importAndroidOrigins bool
+)
+
+// generateCmd represents the generate command
+var generateCmd = &cobra.Command{
+	Use:   "generate [origin]...",
+	Short: "Generate a .well-known/webauthn document from a list of origins",
+	Long: `Generate a .well-known/webauthn document from a list of origins.
+
+Origins are taken from the arguments and from --origins-file (one per line, "-" for
+stdin); with neither, they are read from stdin. With --interactive, origins are entered
+one at a time and the label budget is shown after each, with a warning before an origin
+would cross the label limit. Each origin is normalized the way a
+browser serializes it (lowercase, https when no scheme is given, no default port or
+path) and duplicates are dropped, keeping the first occurrence so that the order that
+decides which origins fall within the label limit is preserved.
+
+The generated document is checked with the same rules as the lint command before it is
+written. With --origin, each given caller origin must also be authorized. A document
+with errors is not written and the findings are printed instead; with --strict, the
+same applies to warnings. When the origins exceed the label limit, suggestions are
+printed for which origins to remove.
+
+To bootstrap a document from the app association files a team already maintains,
+--import-apple and --import-assetlinks import candidate origins from the
+apple-app-site-association and assetlinks.json files of a domain, given as
+"domain=path" to read a local file or "domain" to fetch it: the origin of the domain,
+if its file shares credentials with an app, and the websites its assetlinks.json shares
+credentials with. With --android-origins, the Android app origins of the certificates
+of its assetlinks.json apps are imported too. Imported origins follow those of the
+arguments and --origins-file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Collect the origins from the arguments and the origins file
+		origins := append([]string{}, args...)
+		if interactive && originsFile == "-" {
+			fmt.Fprintf(os.Stderr, "Error: --interactive reads answers from stdin and cannot be combined with --origins-file -\n")
+			os.Exit(1)
+		}
+		imported, err := importAppOrigins()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if originsFile != "" || (len(args) == 0 && !interactive && len(imported) == 0) {
+			path := originsFile
+			if path == "" {
+				path = "-"
+			}
+			lines, err := readLines(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			origins = append(origins, lines...)
+		}
+		origins = append(origins, imported...)
+
+		// Let the user add origins one at a time, watching the label budget
+		if interactive {
+			prompted, err := promptOrigins(os.Stdin, os.Stderr, origins)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			origins = prompted
+		}
+
+		result, err := generate.Generate(origins)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Report how the input was changed
+		for _, rewrite := range result.Rewritten {
+			fmt.Fprintf(os.Stderr, "Normalized %s to %s\n", rewrite.From, rewrite.To)
+		}
+		for _, duplicate := range result.Duplicates {
+			fmt.Fprintf(os.Stderr, "Dropped duplicate %s\n", duplicate)
+		}
+		if debug {
+			fmt.Fprintf(os.Stderr, "Debug: Generated %d origins from %d inputs\n", len(result.Origins), len(origins))
+		}
+
+		// Check the document before writing it
+		findings := lint.Check(result.JSON, lint.Options{CallerOrigins: generateCallerOrigins, Source: generateOutput, MaxLabels: maxLabels})
+		fmt.Fprint(os.Stderr, lint.FormatFindings(findings))
+		if lint.HasErrors(findings) || (strict && len(findings) > 0) {
+			// Suggest how to get a list over the label limit back within it
+			if suggestions := generate.SuggestWithMaxLabels(result.Origins, maxLabels); len(suggestions) > 0 {
+				fmt.Fprintln(os.Stderr, "Suggestions:")
+				for _, suggestion := range suggestions {
+					fmt.Fprintf(os.Stderr, "  - %s\n", suggestion)
+				}
+			}
+			fmt.Fprintln(os.Stderr, "Error: refusing to write the document until the findings above are fixed")
+			os.Exit(3)
+		}
+
+		// Write the document to the output file or stdout
+		if generateOutput == "" {
+			os.Stdout.Write(result.JSON)
+			return
+		}
+		if err := os.WriteFile(generateOutput, result.JSON, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write document: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d origins to %s\n", len(result.Origins), generateOutput)
+	},
+}
+
+// importAppOrigins returns the origins imported from the files of --import-apple and
+// --import-assetlinks, in the order they are given, and reports each on stderr.
+func importAppOrigins() ([]string, error) {
+	var origins []string
+	add := func(source string, imported []string) {
+		for _, origin := range imported {
+			fmt.Fprintf(os.Stderr, "Imported %s from %s\n", origin, source)
+		}
+		origins = append(origins, imported...)
+	}
+
+	for _, spec := range importApple {
+		domain, path, err := parseImport(spec)
+		if err != nil {
+			return nil, err
+		}
+		association := &appleapp.Association{Domain: domain, URL: path, Found: true}
+		if path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read apple-app-site-association: %w", err)
+			}
+			if association.Apps, err = appleapp.Parse(data); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		} else if association, err = appleapp.Fetch(context.Background(), domain, fetchOptions()); err != nil {
+			return nil, err
+		}
+		if !association.Found {
+			return nil, fmt.Errorf("%s does not serve an apple-app-site-association file at %s", domain, association.URL)
+		}
+		add(association.URL, association.Origins())
+	}
+
+	for _, spec := range importAssetLinks {
+		domain, path, err := parseImport(spec)
+		if err != nil {
+			return nil, err
+		}
+		links := &assetlinks.Links{Domain: domain, URL: path, Found: true}
+		if path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read assetlinks.json: %w", err)
+			}
+			if links.Apps, links.Sites, err = assetlinks.Parse(data); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		} else if links, err = assetlinks.Fetch(context.Background(), domain, fetchOptions()); err != nil {
+			return nil, err
+		}
+		if !links.Found {
+			return nil, fmt.Errorf("%s does not serve an assetlinks.json file at %s", domain, links.URL)
+		}
+		add(links.URL, links.Origins())
+		if importAndroidOrigins {
+			add(links.URL, links.AndroidOrigins())
+		}
+	}
+	return origins, nil
+}
+
+// parseImport parses an import specification of the form "domain=path" or "domain".
+func parseImport(spec string) (domain, path string, err error) {
+	domain, path, _ = strings.Cut(spec, "=")
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(domain, "https://"), "/"))
+	if domain == "" || strings.ContainsAny(domain, "/:") {
+		return "", "", fmt.Errorf("invalid import %q: expected domain or domain=path", spec)
+	}
+	return domain, path, nil
+}
+
+// promptOrigins asks for origins one at a time, starting from the given ones, until an
+// empty line or the end of input. After each origin it prints the label budget, and it
+// asks for confirmation before adding an origin that browsers would ignore because the
+// label limit has been reached.
+func promptOrigins(in io.Reader, out io.Writer, origins []string) ([]string, error) {
+	var normalized []string
+	for _, origin := range origins {
+		if n, err := generate.Normalize(origin); err == nil {
+			origin = n
+		}
+		normalized = append(normalized, origin)
+	}
+	budget := generate.NewBudgetWithMaxLabels(normalized, maxLabels)
+	seen := make(map[string]bool)
+	for _, origin := range normalized {
+		seen[origin] = true
+	}
+
+	fmt.Fprintf(out, "Enter origins one per line, and an empty line to finish. Label budget: %s\n", budget)
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "Origin: ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			break
+		}
+		input := strings.TrimSpace(scanner.Text())
+		if input == "" {
+			break
+		}
+
+		origin, err := generate.Normalize(input)
+		if err != nil {
+			fmt.Fprintf(out, "  %v\n", err)
+			continue
+		}
+		if seen[origin] {
+			fmt.Fprintf(out, "  %s is already listed\n", origin)
+			continue
+		}
+		label, overLimit, ok := budget.Check(origin)
+		if !ok {
+			fmt.Fprintf(out, "  %s has no registrable domain; browsers would ignore it\n", origin)
+			continue
+		}
+
+		// Warn before the limit is crossed
+		if overLimit {
+			fmt.Fprintf(out, "  Warning: %s adds label %q, but all %d labels are used (%s); browsers would ignore it.\n",
+				origin, label, budget.MaxLabels(), strings.Join(budget.Labels(), ", "))
+			fmt.Fprint(out, "  Add it anyway? [y/N] ")
+			if !scanner.Scan() {
+				fmt.Fprintln(out)
+				break
+			}
+			if answer := strings.ToLower(strings.TrimSpace(scanner.Text())); answer != "y" && answer != "yes" {
+				continue
+			}
+		}
+
+		normalized = append(normalized, origin)
+		seen[origin] = true
+		budget.Add(origin)
+		fmt.Fprintf(out, "  Added %s (label %q). Label budget: %s\n", origin, label, budget)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	return normalized, nil
+}
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+
+	// Local flags
+	generateCmd.Flags().StringVar(&originsFile, "origins-file", "", "File listing the origins, one per line (\"-\" for stdin)")
+	generateCmd.Flags().StringVarP(&generateOutput, "out", "o", "", "Write the document to this file (default is stdout)")
+	generateCmd.Flags().StringSliceVar(&generateCallerOrigins, "origin", nil, "Caller origin the document must authorize (repeatable)")
+	generateCmd.Flags().BoolVar(&interactive, "interactive", false, "Prompt for origins one at a time, showing the label budget")
+	generateCmd.Flags().BoolVar(&strict, "strict", false, "Also refuse to write a document with warnings")
+	generateCmd.Flags().StringArrayVar(&importApple, "import-apple", nil, "Import origins from the apple-app-site-association of a domain, as domain=path or domain to fetch it (repeatable)")
+	generateCmd.Flags().StringArrayVar(&importAssetLinks, "import-assetlinks", nil, "Import origins from the assetlinks.json of a domain, as domain=path or domain to fetch it (repeatable)")
+	generateCmd.Flags().BoolVar(&importAndroidOrigins, "android-origins", false, "Also import the Android app origins of the --import-assetlinks files")
+}
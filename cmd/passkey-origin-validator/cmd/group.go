@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/developmeh/passkey-origin-validator/internal/group"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// groupCanonical is the RP ID every member of the group is meant to use
+	groupCanonical string
+	// groupOutput is the format the report is printed in
+	groupOutput string
+)
+
+// groupCmd represents the group command
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Check groups of domains that share passkeys",
+	Long: `Check groups of domains that share passkeys.
+
+Use "group check" to verify that the members of a group authorize each other as
+intended.`,
+}
+
+// groupCheckCmd represents the group check command
+var groupCheckCmd = &cobra.Command{
+	Use:   "check <domain>...",
+	Short: "Verify that a group of domains authorize each other as intended",
+	Long: `Verify that a group of domains authorize each other as intended.
+
+Each domain's .well-known/webauthn document is fetched, and the origin of every member,
+https:// followed by the domain, is validated against the RP ID of every other: it can
+use the RP ID when the document lists it, or when the RP ID is a registrable domain
+suffix of its host. The result is printed as a table with a row for each RP ID and a
+column for each origin.
+
+With --canonical, every member is meant to use the canonical RP ID, and each member
+that cannot is reported as group-missing; a member whose own document lists the
+canonical RP instead is pointed out, since the relationship is then backwards. Without
+it, every member is meant to be able to use the RP ID of every other: a pair that
+authorizes neither way is reported as group-missing for both, and a pair that only
+authorizes one way as group-one-way.
+
+With --output json, the domains, every relationship and the findings are printed as
+JSON, and each finding carries a remediation. With --output dot or mermaid, the graph
+is printed as a Graphviz or Mermaid diagram instead, with an edge from each RP ID to
+every member that can use it, labeled with the label the member's origin consumes. Each
+RP ID is colored by the label budget its document consumes: green while it has labels to
+spare, orange when one is left and red when none is; an edge that needs no label, since
+the RP ID is a suffix of the member, is dashed. The findings still set the exit status.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		switch groupOutput {
+		case "text", "json", "dot", "mermaid":
+		default:
+			fmt.Fprintf(os.Stderr, "Error: unknown output format %q: expected text, json, dot or mermaid\n", groupOutput)
+			os.Exit(1)
+		}
+
+		// Every member is compared by its host, once
+		var domains []string
+		seen := make(map[string]bool)
+		for _, arg := range append(args, groupCanonical) {
+			domain := strings.ToLower(hostOf(arg))
+			if arg != "" && !seen[domain] {
+				seen[domain] = true
+				domains = append(domains, domain)
+			}
+		}
+		canonical := ""
+		if groupCanonical != "" {
+			canonical = strings.ToLower(hostOf(groupCanonical))
+		}
+
+		fetch := fetchOptions()
+		var docs []group.Document
+		for _, domain := range domains {
+			if debug {
+				fmt.Printf("Debug: Testing domain: %s\n", domain)
+			}
+			runDNSPreflight(domain)
+			doc := group.Document{Domain: domain}
+			result, err := counter.CountLabelsWithOptions(domain, fetch)
+			switch {
+			case err != nil:
+				doc.Error = err.Error()
+			case result.ErrorMessage != "" && result.RawJSON == "":
+				doc.URL, doc.Error = result.URL, result.ErrorMessage
+			default:
+				doc.URL, doc.JSON = result.URL, []byte(result.RawJSON)
+			}
+			docs = append(docs, doc)
+		}
+
+		report := group.Check(docs, group.Options{Canonical: canonical, MaxLabels: maxLabels})
+		report.Findings = applySeverity(report.Findings)
+		if report.Findings == nil {
+			report.Findings = []lint.Finding{}
+		}
+
+		switch groupOutput {
+		case "json":
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+		case "dot":
+			fmt.Print(report.DOT())
+		case "mermaid":
+			fmt.Print(report.Mermaid())
+		default:
+			if canonical != "" {
+				fmt.Printf("Authorization graph of %d domains (canonical RP ID %s)\n\n", len(report.Domains), canonical)
+			} else {
+				fmt.Printf("Authorization graph of %d domains\n\n", len(report.Domains))
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "RP ID\t%s\n", strings.ToUpper(strings.Join(report.Domains, "\t")))
+			for _, rpID := range report.Domains {
+				cells := []string{rpID}
+				for _, member := range report.Domains {
+					rel, ok := report.Relationship(rpID, member)
+					if !ok {
+						cells = append(cells, "-")
+						continue
+					}
+					cells = append(cells, map[string]string{
+						group.StatusListed:        "listed",
+						group.StatusRPIDSuffix:    "RP ID suffix",
+						group.StatusNotAuthorized: "no",
+						group.StatusUnreadable:    "unreadable",
+					}[rel.Status])
+				}
+				fmt.Fprintln(w, strings.Join(cells, "\t"))
+			}
+			w.Flush()
+			fmt.Println()
+			if len(report.Findings) == 0 {
+				fmt.Println("The group authorizes each other as intended")
+			}
+			fmt.Print(lint.FormatFindings(report.Findings))
+		}
+
+		exitOn(exitcode.Findings{
+			Invalid: lint.HasErrors(report.Findings),
+			Warn:    lint.HasWarnings(report.Findings),
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(groupCmd)
+	groupCmd.AddCommand(groupCheckCmd)
+
+	// Local flags for the group check command
+	groupCheckCmd.Flags().StringVar(&groupCanonical, "canonical", "", "RP ID every member is meant to use (default is for every member to authorize every other)")
+	groupCheckCmd.Flags().StringVar(&groupOutput, "output", "text", "Output format: text, json, dot or mermaid")
+}
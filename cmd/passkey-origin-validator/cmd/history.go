@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+	"github.com/developmeh/passkey-origin-validator/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// keepDays is the number of days of results kept in the --store database; 0 keeps all
+	keepDays int
+	// exportFormat is the format of history export; only ndjson is supported
+	exportFormat string
+	// exportDir is the directory history export writes its partitions to
+	exportDir string
+	// exportSince is the first day history export writes, as YYYY-MM-DD
+	exportSince string
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Maintain the results database written with --store",
+	Long: `Maintain the results database written by batch and watch with --store.
+
+Use "history import" to add results files to it, "history prune" to roll up old
+results into daily summaries, and "history export" to load them into a data warehouse.`,
+}
+
+// historyPruneCmd represents the history prune command
+var historyPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Roll up results older than the retention period into daily summaries",
+	Long: `Roll up results older than the retention period into daily summaries.
+
+This command keeps the results of the last --keep-days days in the --store database and
+replaces older results with one summary per domain and day in the daily table: the
+number of checks that passed, exceeded the label limit, had an unauthorized caller
+origin, failed or were skipped, and the range of label counts. Whole UTC days are rolled
+up at once, and finished scans with no results left are deleted.
+
+A long-running watch can prune its database itself with --keep-days.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if keepDays < 1 {
+			fmt.Fprintf(os.Stderr, "Error: --keep-days must be at least 1\n")
+			os.Exit(1)
+		}
+		db, err := openStoreDB()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+
+		cutoff := store.RetentionCutoff(time.Now(), keepDays)
+		result, err := db.Prune(cutoff)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Rolled up %d results before %s into %d daily summaries and deleted %d scans\n",
+			result.Results, cutoff.Format(time.DateOnly), result.Days, result.Scans)
+	},
+}
+
+// historyExportCmd represents the history export command
+var historyExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export results and daily summaries as newline-delimited JSON partitioned by day",
+	Long: `Export results and daily summaries as newline-delimited JSON partitioned by day.
+
+This command writes the results in the --store database to --out-dir as one JSON Lines
+file per UTC day, in the Hive partitioning layout that BigQuery, Athena and Spark load
+directly:
+
+  <out-dir>/results/date=YYYY-MM-DD/results.jsonl
+  <out-dir>/daily/date=YYYY-MM-DD/daily.jsonl
+
+Results have the fields of batch --results records and the ID of their scan. Daily
+summaries of results rolled up by "history prune" are exported too. With --since, only
+that day and later ones are exported; exporting a day again replaces its files, so a
+daily job can export yesterday without duplicating rows.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if exportFormat != "ndjson" {
+			fmt.Fprintf(os.Stderr, "Error: unsupported export format %q (supported: ndjson)\n", exportFormat)
+			os.Exit(1)
+		}
+		var since time.Time
+		if exportSince != "" {
+			var err error
+			since, err = time.Parse(time.DateOnly, exportSince)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid --since %q: expected YYYY-MM-DD\n", exportSince)
+				os.Exit(1)
+			}
+		}
+		db, err := openStoreDB()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+
+		result, err := db.Export(exportDir, since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported %d results and %d daily summaries to %d partitions in %s\n",
+			result.Results, result.Daily, result.Partitions, exportDir)
+	},
+}
+
+// historyImportCmd represents the history import command
+var historyImportCmd = &cobra.Command{
+	Use:   "import <file>...",
+	Short: "Import results files into the results database",
+	Long: `Import results files into the results database.
+
+This command adds the JSON Lines results files written by batch --results, such as the
+files of the workers of a sharded scan or of runs without --store, and those written by
+older versions, to the --store database as one scan. Every record is validated and
+normalized first: records from a newer schema version, records that are missing a
+domain or timestamp, and records with an unknown status are rejected, and nothing is
+imported unless every file is valid. Domains are trimmed and lowercased, and times are
+converted to UTC. Records already in the database, with the same domain, timestamp and
+caller origin, are skipped, so a file can be imported more than once.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Validate every file before importing any, so that a bad file imports nothing
+		for _, path := range args {
+			if err := readImportFile(path, func(batch.Record) error { return nil }); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+				os.Exit(1)
+			}
+		}
+
+		db, err := openStoreDB()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		scanID, err := db.StartScan("import", time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		imported, duplicates := 0, 0
+		for _, path := range args {
+			err := readImportFile(path, func(record batch.Record) error {
+				contains, err := db.Contains(record)
+				if err != nil {
+					return err
+				}
+				if contains {
+					duplicates++
+					return nil
+				}
+				imported++
+				return db.Save(scanID, record)
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+				os.Exit(1)
+			}
+		}
+		if err := db.FinishScan(scanID, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Imported %d results from %d files as scan %d, skipping %d already in the store\n",
+			imported, len(args), scanID, duplicates)
+	},
+}
+
+// readImportFile reads the records of the named results file and calls fn with each one
+// once it has been normalized.
+func readImportFile(path string, fn func(batch.Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	n := 0
+	return batch.ReadRecords(f, func(record batch.Record) error {
+		n++
+		record, err := batch.Normalize(record)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		return fn(record)
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyPruneCmd)
+	historyCmd.AddCommand(historyExportCmd)
+	historyCmd.AddCommand(historyImportCmd)
+
+	// Local flags
+	historyPruneCmd.Flags().StringVar(&storeSpec, "store", "", "Database to prune (sqlite:path)")
+	historyPruneCmd.Flags().IntVar(&keepDays, "keep-days", 30, "Number of days of results to keep")
+	historyPruneCmd.MarkFlagRequired("store")
+
+	historyExportCmd.Flags().StringVar(&storeSpec, "store", "", "Database to export (sqlite:path)")
+	historyExportCmd.Flags().StringVar(&exportFormat, "format", "ndjson", "Export format (ndjson)")
+	historyExportCmd.Flags().StringVar(&exportDir, "out-dir", "", "Directory to write the partitions to")
+	historyExportCmd.Flags().StringVar(&exportSince, "since", "", "Export only this day and later ones (YYYY-MM-DD, UTC)")
+	historyExportCmd.MarkFlagRequired("store")
+	historyExportCmd.MarkFlagRequired("out-dir")
+
+	historyImportCmd.Flags().StringVar(&storeSpec, "store", "", "Database to import into (sqlite:path)")
+	historyImportCmd.MarkFlagRequired("store")
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/developmeh/passkey-origin-validator/internal/browser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// limitsOutput is the format the limits are printed in
+	limitsOutput string
+	// limitsBrowsers are the browser profiles to print the limits of
+	limitsBrowsers []string
+)
+
+// limitsReport is the JSON form of the limits command's output.
+type limitsReport struct {
+	Browsers  []browser.Limits `json:"browsers"`
+	Strictest browser.Limits   `json:"strictest"`
+}
+
+// limitsCmd represents the limits command
+var limitsCmd = &cobra.Command{
+	Use:   "limits",
+	Short: "Print the constraints each browser enforces on related origin requests",
+	Long: `Print the constraints each browser enforces on related origin requests.
+
+For each browser profile (chromium, safari, firefox and spec, as with validate
+--browser), this command prints whether the browser supports related origins, how many
+labels it counts, the largest response it reads, whether it requires an
+application/json content type and whether it follows redirects of the request for the
+document. A last row combines the strictest of them, among the browsers that support
+related origins: a document within those limits, served that way, is read the same way
+by all of them.
+
+With --output json, the same limits are printed as a JSON object with a browsers array
+and the strictest combination, for tools that design documents to them. The chromium
+profile follows --chromium-version.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if limitsOutput != "text" && limitsOutput != "json" {
+			fmt.Fprintf(os.Stderr, "Error: unknown output format %q: expected text or json\n", limitsOutput)
+			os.Exit(1)
+		}
+		profiles, err := browser.Parse(limitsBrowsers)
+		if err == nil {
+			profiles, err = browser.WithChromium(profiles, chromiumVersion)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		report := limitsReport{Browsers: []browser.Limits{}}
+		for _, p := range profiles {
+			report.Browsers = append(report.Browsers, p.Limits())
+		}
+		report.Strictest = browser.Strictest(report.Browsers)
+
+		if limitsOutput == "json" {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BROWSER\tRELATED ORIGINS\tLABEL LIMIT\tBODY SIZE\tCONTENT TYPE\tREDIRECTS")
+		for _, limits := range append(report.Browsers, report.Strictest) {
+			if !limits.Supported {
+				fmt.Fprintf(w, "%s\tunsupported\t-\t-\t-\t-\n", limits.Browser)
+				continue
+			}
+			contentType := "any"
+			if limits.JSONContentType {
+				contentType = "application/json"
+			}
+			fmt.Fprintf(w, "%s\tsupported\t%d\t%s\t%s\t%s\n", limits.Browser, limits.MaxLabels,
+				browser.FormatBodySize(limits.MaxBodySize), contentType, limits.Redirects)
+		}
+		w.Flush()
+		fmt.Printf("\nStrictest: %s\n", report.Strictest.Description)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(limitsCmd)
+
+	// Local flags for the limits command
+	limitsCmd.Flags().StringVar(&limitsOutput, "output", "text", "Output format: text or json")
+	limitsCmd.Flags().StringSliceVar(&limitsBrowsers, "browser", []string{"all"}, "Browser profiles to print the limits of: chromium, safari, firefox, spec or all")
+}
@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/developmeh/passkey-origin-validator/internal/generate"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+	"github.com/developmeh/passkey-origin-validator/internal/rego"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// lintOrigins are caller origins the document must authorize
+	lintOrigins []string
+	// outputFormat is the format results are printed in
+	outputFormat string
+	// lintFix rewrites the document in canonical form
+	lintFix bool
+	// lintRPID is the RP ID the document is served for, if not the host it is fetched from
+	lintRPID string
+	// lintStrictOrigins reports origins that only match after normalization
+	lintStrictOrigins bool
+	// lintSecureOrigins are http origins the browser is configured to treat as secure
+	lintSecureOrigins []string
+	// lintApps cross-checks the apps of the apple-app-site-association and assetlinks.json
+	// files of the RP ID and the origins' hosts
+	lintApps bool
+	// lintAppDomains are the other domains the RP's apps are associated with
+	lintAppDomains []string
+	// policyBundle is a directory of Rego policies the document is checked against
+	policyBundle string
+	// policyNamespace is the package the Rego rules are read from
+	policyNamespace string
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint [domain]",
+	Short: "Check a .well-known/webauthn document for problems",
+	Long: `Check a .well-known/webauthn document for problems.
+
+This command fetches the .well-known/webauthn endpoint for a given domain and reports
+origins that browsers ignore (invalid origins, origins over the label limit) as errors,
+and entries that are likely mistakes (http origins, paths, duplicates) as warnings.
+With --origin, each given caller origin must also be authorized by the document.
+
+Each finding carries a fingerprint, derived from its rule, the document's URL or path
+and the normalized origin, that stays the same across runs so that baselines,
+suppression lists and trackers can refer to it.
+
+The host the document is fetched from, or --rp-id for a --file, is taken as the RP ID.
+Origins that could use that RP ID on their own, because it is a registrable domain
+suffix of their host, are reported as redundant, along with the label removing them
+would free for a cross-site origin. A document of only such origins is reported as
+unnecessary: browsers only consult it for callers that cannot.
+
+With --strict-origins, origins that browsers only match after normalizing them, such as
+https://Example.com:443, are reported too, along with --origin values that need it.
+
+An --origin that is not a secure context, an http origin on a host other than a
+loopback host, is reported as insecure-caller, since browsers refuse its requests
+before consulting the document; --secure-origin lists origins the browser is
+configured to treat as secure. A loopback --origin, such as http://localhost:3000, is
+reported as loopback-caller unless it can use the RP ID: it has no registrable domain,
+so no document can authorize it. A loopback entry is invalid for the same reason.
+
+With --output annotated, the document is reprinted with each finding as a comment at
+the end of the line of the origin it is about. With --output sarif, the findings are
+printed as a SARIF 2.1.0 log for GitHub Code Scanning and other SARIF consumers. With
+--output json, they are printed as a JSON report in which each finding carries a
+remediation: the kind of change that fixes it, the origin or document it applies to and
+the suggested value, so that automation can propose the fix.
+
+With --fix, the document is rewritten in canonical form: origins are normalized (no
+path or default port, lowercase host), duplicates are removed and origins are sorted,
+unless they exceed the label limit, in which case their order is kept because it
+decides which origins browsers ignore. A --file is rewritten in place; a fetched
+document is printed. Findings that cannot be fixed automatically are then reported.
+
+With --policy-bundle, the Rego policies of a directory are also run against the parsed
+document and how it was fetched, following the conventions of conftest: the deny and
+violation rules of the main package (or --policy-namespace) report errors and its warn
+rules warnings, as findings of the policy rule. The policies are evaluated in-process;
+with --sandbox, the builtins that reach the network, such as http.send, are refused.
+
+With --apps, the apple-app-site-association and assetlinks.json files of the RP ID and
+of the hosts of the document's https origins are fetched too, and the iOS, macOS and
+Android apps they share credentials with compared: an origin whose host shares
+credentials with apps that the RP ID does not is reported as app-rp-mismatch, since
+those apps cannot use the passkeys its website uses. --app-domain names the other
+domains of the apps' associated domains: one that shares an app with the RP ID, or a
+website the RP ID's assetlinks.json shares credentials with, whose origin is not listed
+and cannot use the RP ID on its own, is reported as app-origin-missing, since its
+website cannot use the passkeys the app creates. An Android app origin whose
+certificate the RP ID's assetlinks.json does not link is reported as
+android-origin-unlinked. --app-domain implies --apps; the sharing command prints the
+same comparison as a table.
+
+If no domain is provided, it uses the default domain (webauthn.io).
+If the --file flag is provided, it reads from the specified file instead.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if outputFormat != "text" && outputFormat != "annotated" && outputFormat != "sarif" && outputFormat != "json" {
+			fmt.Fprintf(os.Stderr, "Error: unknown output format %q: expected text, annotated, sarif or json\n", outputFormat)
+			os.Exit(1)
+		}
+		// A fixed document is printed when it was fetched, leaving no room for the log
+		if lintFix && (outputFormat == "sarif" || outputFormat == "json") && file == "" {
+			fmt.Fprintf(os.Stderr, "Error: --output %s can only be combined with --fix for a --file\n", outputFormat)
+			os.Exit(1)
+		}
+		if len(lintAppDomains) > 0 {
+			lintApps = true
+		}
+		if lintApps && file != "" && lintRPID == "" {
+			fmt.Fprintf(os.Stderr, "Error: --apps needs --rp-id for a --file\n")
+			os.Exit(1)
+		}
+
+		var result *counter.LabelCount
+		var err error
+
+		// Check if we're reading from a file
+		if file != "" {
+			if debug {
+				fmt.Printf("Debug: Reading from file: %s\n", file)
+			}
+			result, err = counter.CountLabelsFromFileWithOptions(file, fetchOptions())
+		} else {
+			// Get the domain from command-line arguments or use the default
+			domain := "https://webauthn.io"
+			if len(args) > 0 {
+				domain = args[0]
+			}
+
+			if debug {
+				fmt.Printf("Debug: Testing domain: %s\n", domain)
+			}
+
+			runDNSPreflight(domain)
+			result, err = counter.CountLabelsWithOptions(domain, fetchOptions())
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exitOn(exitcode.Findings{Error: true})
+			return
+		}
+
+		// A document that was not served as JSON cannot be linted
+		if result.ErrorMessage != "" && result.RawJSON == "" {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", result.ErrorMessage)
+			if result.Remediation != "" {
+				fmt.Fprintf(os.Stderr, "Remediation: %s\n", result.Remediation)
+			}
+			exitOn(exitcode.Findings{Error: true})
+			return
+		}
+
+		document := []byte(result.RawJSON)
+
+		// Rewrite the document and lint what is left to fix by hand
+		if lintFix {
+			fixed, sorted, err := generate.FixWithMaxLabels(document, maxLabels)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			for _, rewrite := range fixed.Rewritten {
+				fmt.Fprintf(os.Stderr, "Fixed: normalized %s to %s\n", rewrite.From, rewrite.To)
+			}
+			for _, duplicate := range fixed.Duplicates {
+				fmt.Fprintf(os.Stderr, "Fixed: removed duplicate %s\n", duplicate)
+			}
+			if !sorted {
+				fmt.Fprintf(os.Stderr, "Note: origins were not sorted because they exceed the label limit and their order decides which are ignored\n")
+			}
+
+			// Write a local file back in place; print a fetched document
+			if file != "" {
+				info, err := os.Stat(file)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				if err := os.WriteFile(file, fixed.JSON, info.Mode().Perm()); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: failed to write fixed document: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Fprintf(os.Stderr, "Wrote %s\n", file)
+			} else {
+				fmt.Print(string(fixed.JSON))
+			}
+			document = fixed.JSON
+		}
+
+		// Report how the document was served along with its contents, at the configured
+		// severities
+		findings := lint.ServingFindings(result.Warnings, result.URL)
+		rpID := lintRPID
+		if sourceURL, err := url.Parse(result.URL); err == nil && rpID == "" {
+			rpID = sourceURL.Hostname()
+		}
+		findings = append(findings, lint.Check(document, lint.Options{CallerOrigins: lintOrigins, Source: result.URL, MaxLabels: maxLabels, RPID: rpID, StrictOrigins: lintStrictOrigins, SecureOrigins: lintSecureOrigins})...)
+		if policyBundle != "" {
+			// Policies see the document as it was read, before any --fix
+			violations, err := rego.Evaluate(context.Background(), result, rego.Options{Bundle: policyBundle, Namespace: policyNamespace, Evaluator: rego.OPA{DenyNetwork: sandboxed != nil}})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			findings = append(findings, violations...)
+		}
+		if lintApps {
+			findings = append(findings, checkApps(newAppFiles(), document, rpID, result.URL, lintAppDomains)...)
+		}
+		findings = applySeverity(findings)
+
+		// Print the results
+		switch {
+		case outputFormat == "sarif":
+			printSARIF(findings, result.URL, document)
+		case outputFormat == "json":
+			printReport(findings, result.URL)
+		case lintFix:
+			// The fixed document may already be on stdout, so report what is left on stderr
+			fmt.Fprint(os.Stderr, lint.FormatFindings(findings))
+		case outputFormat == "annotated":
+			fmt.Print(lint.Annotate(document, findings))
+		default:
+			fmt.Printf("Linting %s\n", result.URL)
+			if len(findings) == 0 {
+				fmt.Println("No problems found")
+			}
+			fmt.Print(lint.FormatFindings(findings))
+			if diagnose && result.ParseError != nil {
+				printDiagnosis(os.Stdout, document)
+			}
+		}
+
+		// Exit with non-zero status if browsers would ignore or reject part of the document
+		exitOn(exitcode.Findings{
+			Invalid: lint.HasErrors(findings),
+			Warn:    lint.HasWarnings(findings),
+		})
+	},
+}
+
+// printSARIF prints findings about the document from source as a SARIF log.
+func printSARIF(findings []lint.Finding, source string, document []byte) {
+	data, err := lint.SARIF(findings, lint.SARIFOptions{ToolVersion: version, Source: source, Document: document, ModelVersion: modelVersion()})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(string(data))
+}
+
+// printReport prints findings about the document at source as a JSON report.
+func printReport(findings []lint.Finding, source string) {
+	report := lint.Report{Source: source, ModelVersion: modelVersion(), Findings: findings, Counts: lint.CountRules(findings)}
+	if report.Findings == nil {
+		report.Findings = []lint.Finding{}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	// Local flags
+	lintCmd.Flags().StringVar(&outputFormat, "output", "text", "Output format: text, annotated, sarif or json")
+	lintCmd.Flags().BoolVar(&lintFix, "fix", false, "Rewrite the document in canonical form (in place with --file)")
+	lintCmd.Flags().StringSliceVar(&lintOrigins, "origin", nil, "Caller origin the document must authorize (repeatable)")
+	lintCmd.Flags().StringVar(&lintRPID, "rp-id", "", "RP ID the document is served for (default is the host it is fetched from)")
+	lintCmd.Flags().BoolVar(&lintStrictOrigins, "strict-origins", false, "Report origins that only match after normalizing their case or default port")
+	lintCmd.Flags().StringSliceVar(&lintSecureOrigins, "secure-origin", nil, "An http origin the browser is configured to treat as a secure context (repeatable)")
+	lintCmd.Flags().BoolVar(&lintApps, "apps", false, "Cross-check the apps of the apple-app-site-association and assetlinks.json files of the RP ID and the origins' hosts")
+	lintCmd.Flags().StringSliceVar(&lintAppDomains, "app-domain", nil, "Another domain the RP's apps are associated with, whose origin must be listed if it shares an app (repeatable, implies --apps)")
+	lintCmd.Flags().StringVar(&policyBundle, "policy-bundle", "", "Directory of Rego policies to run against the document")
+	lintCmd.Flags().StringVar(&policyNamespace, "policy-namespace", rego.DefaultNamespace, "Package the Rego rules are read from")
+}
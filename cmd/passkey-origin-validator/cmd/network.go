@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/dnscheck"
+	"github.com/developmeh/passkey-origin-validator/internal/har"
+	"github.com/developmeh/passkey-origin-validator/internal/httpcache"
+	"github.com/developmeh/passkey-origin-validator/internal/limits"
+)
+
+var (
+	// budgetOnce guards the creation of runBudget
+	budgetOnce sync.Once
+	// runBudget tracks resource usage across every request made during this run
+	runBudget *limits.Budget
+
+	// hostsOnce guards the loading of hostOverrides
+	hostsOnce sync.Once
+	// hostOverrides are the addresses from --hosts-file
+	hostOverrides dnscheck.Hosts
+
+	// recorderOnce guards the creation of httpRecorder
+	recorderOnce sync.Once
+	// httpRecorder records every exchange of this run for --har and --dump-http
+	httpRecorder *har.Recorder
+)
+
+// budget returns the run budget configured by the global flags, or nil if no limits are set.
+func budget() *limits.Budget {
+	budgetOnce.Do(func() {
+		if maxRequests > 0 || maxBytes > 0 || maxRuntime > 0 {
+			runBudget = limits.NewBudget(limits.Limits{
+				MaxRequests: maxRequests,
+				MaxBytes:    maxBytes,
+				MaxRuntime:  maxRuntime,
+			})
+		}
+	})
+	return runBudget
+}
+
+// hosts returns the host overrides loaded from --hosts-file, or nil if none is set.
+func hosts() dnscheck.Hosts {
+	hostsOnce.Do(func() {
+		if hostsFile == "" {
+			return
+		}
+		loaded, err := dnscheck.LoadHosts(hostsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		hostOverrides = loaded
+	})
+	return hostOverrides
+}
+
+// resolver returns the resolver configured by --resolver and --hosts-file, which every
+// connection the tool makes resolves host names with.
+func resolver() dnscheck.Resolver {
+	return hosts().Resolver(dnscheck.NewResolver(resolverAddr))
+}
+
+// lookupHTTPS returns the HTTPS records published at name, which --https-records connects
+// to the endpoints of. Hosts from --hosts-file have none, so that their mapping stands.
+func lookupHTTPS(ctx context.Context, name string) ([]dnscheck.ServiceRecord, error) {
+	host := name
+	if _, after, ok := strings.Cut(name, "._https."); ok {
+		host = after
+	}
+	if hosts().Lookup(host) != nil {
+		return nil, nil
+	}
+	records, err := dnscheck.LookupHTTPS(ctx, resolverAddr, name)
+	if debug {
+		fmt.Printf("Debug: HTTPS records of %s: %v (error: %v)\n", name, records, err)
+	}
+	return records, err
+}
+
+// newBaseTransport returns a transport and the dialer for its connections, configured with
+// --timeout and the http settings of the config file, before any dialing is set up.
+func newBaseTransport() (*http.Transport, *net.Dialer) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+	}
+	httpSettings.Apply(transport, dialer)
+	return transport, dialer
+}
+
+// newClient returns the client of the services the tool reports to, such as alert and
+// webhook endpoints, issue trackers and GitHub, configured with --timeout and the http
+// settings of the config file.
+func newClient() *http.Client {
+	transport, dialer := newBaseTransport()
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// newHTTPTransport returns a base transport configured with the global resolver settings
+// and the http settings of the config file.
+func newHTTPTransport() *http.Transport {
+	transport, dialer := newBaseTransport()
+	dial := dnscheck.DialContext(resolver(), dialer)
+	if httpsRecords {
+		dial = dnscheck.HTTPSDialContext(lookupHTTPS, dial)
+	}
+	transport.DialContext = dial
+	// A sandboxed run only connects to its targets, never to a proxy from the environment
+	if sandboxed != nil {
+		transport.Proxy = nil
+	}
+	return transport
+}
+
+// wrapTransport charges every request made through rt against the run budget, and
+// refuses those a --sandbox run does not allow.
+func wrapTransport(rt http.RoundTripper) http.RoundTripper {
+	if sandboxed != nil {
+		rt = sandboxed.Transport(rt)
+	}
+	if b := budget(); b != nil {
+		return b.Transport(rt)
+	}
+	return rt
+}
+
+// recorder returns the recorder of the exchanges of this run, or nil if neither --har
+// nor --dump-http is set. The HAR file is saved after every exchange, since commands
+// exit without returning, so that it is complete however the run ends.
+func recorder() *har.Recorder {
+	recorderOnce.Do(func() {
+		if harFile == "" && !dumpHTTP {
+			return
+		}
+		httpRecorder = har.New("passkey-origin-validator", version)
+		httpRecorder.OnEntry = func(entry har.Entry) {
+			if dumpHTTP {
+				har.WriteTranscript(os.Stderr, entry)
+			}
+			if harFile != "" {
+				if err := httpRecorder.Save(harFile); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+		}
+	})
+	return httpRecorder
+}
+
+// record records every exchange made through rt for --har and --dump-http.
+func record(rt http.RoundTripper) http.RoundTripper {
+	if r := recorder(); r != nil {
+		return r.Transport(rt)
+	}
+	return rt
+}
+
+// newTransport returns the HTTP transport configured by the global flags.
+func newTransport() http.RoundTripper {
+	return wrapTransport(record(newHTTPTransport()))
+}
+
+// newCachingTransport returns newTransport wrapped with the persistent response cache,
+// unless caching is disabled with --no-cache. Cache hits are not charged against the budget.
+// Recording with --har or --dump-http also bypasses the cache, so that what is recorded is
+// what the server sends now.
+func newCachingTransport() http.RoundTripper {
+	transport := newTransport()
+	if noCache || recorder() != nil {
+		return transport
+	}
+
+	cache, err := httpcache.New(cacheDir)
+	if err != nil {
+		// Caching is an optimization; fall back to uncached requests
+		if debug {
+			fmt.Printf("Debug: Response cache disabled: %v\n", err)
+		}
+		return transport
+	}
+	return cache.Transport(transport)
+}
+
+// hostOf returns the host name of a domain argument, which may be a bare name or a URL.
+func hostOf(domain string) string {
+	if !strings.HasPrefix(domain, "https://") && !strings.HasPrefix(domain, "http://") {
+		domain = "https://" + domain
+	}
+	parsedURL, err := url.Parse(domain)
+	if err != nil {
+		return domain
+	}
+	return parsedURL.Hostname()
+}
+
+// runDNSPreflight resolves the domain and prints a DNS report when --dns-check is set.
+func runDNSPreflight(domain string) {
+	if !dnsCheck {
+		return
+	}
+
+	host := hostOf(domain)
+	if debug {
+		fmt.Printf("Debug: Running DNS preflight for %s\n", host)
+	}
+
+	// A host from --hosts-file is never resolved
+	if report := hosts().Report(host); report != nil {
+		fmt.Println(dnscheck.FormatReport(report))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	fmt.Println(dnscheck.FormatReport(dnscheck.Check(ctx, host, resolverAddr)))
+}
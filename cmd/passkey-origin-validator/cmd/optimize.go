@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/developmeh/passkey-origin-validator/internal/generate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// optimizeOrigins are the caller origins the optimized document must authorize
+	optimizeOrigins []string
+	// optimizeOriginsFile is a file listing the required caller origins, one per line
+	optimizeOriginsFile string
+	// optimizeRPID is the RP ID the document is served for, if not the host it is fetched from
+	optimizeRPID string
+	// optimizeOut is the path the optimized document is written to
+	optimizeOut string
+	// optimizeOutput is the format the optimization is printed in
+	optimizeOutput string
+)
+
+// optimizeCmd represents the optimize command
+var optimizeCmd = &cobra.Command{
+	Use:   "optimize [domain]",
+	Short: "Suggest how to fit a document in the label budget",
+	Long: `Suggest how to fit a document in the label budget.
+
+This command reads a .well-known/webauthn document, fetched from a domain or read from
+--file, and the caller origins that must stay authorized, given with --origin or
+--origins-file (one per line, "-" for stdin). It suggests a list of origins within the
+label limit that keeps every one of them matched, and prints what it does with each
+origin:
+
+  keep         the origin is required, shares a label with one that is, or fits
+  drop         the origin is not required and its label does not fit, or it has no
+               registrable domain, so browsers ignore it anyway
+  add          a required caller origin is not listed
+  rp-id        a required caller origin can use the RP ID on its own, so it needs no
+               entry
+  consolidate  the required caller origins need more labels than the limit, and this
+               one's label does not fit: serve it from a subdomain of the RP ID, which
+               needs no entry, and keep the old host redirecting
+
+The labels with the most required callers are kept, and the other listed origins fill
+the labels left in the order they are listed. The origins of the required callers'
+labels are placed first, so that origins added later can never push them out of the
+budget.
+
+The host the document is fetched from, or --rp-id for a --file, is taken as the RP ID.
+The optimized document is printed, or written to --out; with --output json, the
+changes are printed as JSON instead. The command exits with status 3 when a required
+caller would still not be authorized.
+
+If no domain is provided, it uses the default domain (webauthn.io).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if optimizeOutput != "text" && optimizeOutput != "json" {
+			fmt.Fprintf(os.Stderr, "Error: unknown output format %q: expected text or json\n", optimizeOutput)
+			os.Exit(1)
+		}
+		required := append([]string{}, optimizeOrigins...)
+		if optimizeOriginsFile != "" {
+			lines, err := readLines(optimizeOriginsFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			required = append(required, lines...)
+		}
+
+		var result *counter.LabelCount
+		var err error
+		if file != "" {
+			if debug {
+				fmt.Printf("Debug: Reading from file: %s\n", file)
+			}
+			result, err = counter.CountLabelsFromFileWithOptions(file, fetchOptions())
+		} else {
+			domain := "https://webauthn.io"
+			if len(args) > 0 {
+				domain = args[0]
+			}
+			if debug {
+				fmt.Printf("Debug: Testing domain: %s\n", domain)
+			}
+			runDNSPreflight(domain)
+			result, err = counter.CountLabelsWithOptions(domain, fetchOptions())
+		}
+		if err == nil && result.ErrorMessage != "" && result.RawJSON == "" {
+			err = fmt.Errorf("%s", result.ErrorMessage)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var document counter.WebAuthnResponse
+		if err := json.Unmarshal([]byte(result.RawJSON), &document); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to parse JSON: %v\n", err)
+			os.Exit(1)
+		}
+		rpID := optimizeRPID
+		if sourceURL, err := url.Parse(result.URL); err == nil && rpID == "" && file == "" {
+			rpID = sourceURL.Hostname()
+		}
+
+		opt, err := generate.Optimize(document.Origins, required, rpID, maxLabels)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if optimizeOut != "" {
+			if err := os.WriteFile(optimizeOut, opt.JSON, 0o644); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to write document: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if optimizeOutput == "json" {
+			data, err := json.MarshalIndent(opt, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+		} else {
+			fmt.Printf("Optimizing %s: %d labels, limit %d\n\n", result.URL, opt.LabelsBefore, opt.MaxLabels)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ACTION\tORIGIN\tLABEL\tREASON")
+			for _, change := range opt.Changes {
+				label, reason := change.Label, change.Reason
+				if label == "" {
+					label = "-"
+				}
+				if change.Suggestion != "" {
+					reason += fmt.Sprintf("; serve it from %s instead", change.Suggestion)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", change.Kind, change.Origin, label, reason)
+			}
+			w.Flush()
+			fmt.Println()
+
+			if opt.Reordered {
+				fmt.Println("The origins were reordered so that the labels of the required callers come first")
+			}
+			if len(opt.Unmatched) > 0 {
+				fmt.Printf("Still not authorized: %s\n", strings.Join(opt.Unmatched, ", "))
+			}
+			budget := generate.NewBudgetWithMaxLabels(opt.Origins, opt.MaxLabels)
+			if optimizeOut != "" {
+				fmt.Printf("Wrote %d origins to %s (%s)\n", len(opt.Origins), optimizeOut, budget)
+			} else {
+				fmt.Printf("Optimized document (%s):\n", budget)
+				os.Stdout.Write(opt.JSON)
+			}
+		}
+
+		exitOn(exitcode.Findings{Invalid: len(opt.Unmatched) > 0})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(optimizeCmd)
+
+	// Local flags
+	optimizeCmd.Flags().StringSliceVar(&optimizeOrigins, "origin", nil, "Caller origin the document must authorize (repeatable)")
+	optimizeCmd.Flags().StringVar(&optimizeOriginsFile, "origins-file", "", "File listing the caller origins the document must authorize, one per line (\"-\" for stdin)")
+	optimizeCmd.Flags().StringVar(&optimizeRPID, "rp-id", "", "RP ID the document is served for (default is the host it is fetched from)")
+	optimizeCmd.Flags().StringVarP(&optimizeOut, "out", "o", "", "Write the optimized document to this file")
+	optimizeCmd.Flags().StringVar(&optimizeOutput, "output", "text", "Output format: text or json")
+}
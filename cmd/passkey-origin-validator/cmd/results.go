@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// mergeOutput is the path the merged results are written to; stdout when empty
+	mergeOutput string
+)
+
+// resultsCmd represents the results command
+var resultsCmd = &cobra.Command{
+	Use:   "results",
+	Short: "Work with JSON Lines results files written by batch",
+	Long: `Work with JSON Lines results files written by batch --results.
+
+Use "results merge" to combine the results files of a sharded or resumed scan.`,
+}
+
+// resultsMergeCmd represents the results merge command
+var resultsMergeCmd = &cobra.Command{
+	Use:   "merge <file>...",
+	Short: "Merge results files, keeping one record per domain",
+	Long: `Merge results files, keeping one record per domain.
+
+This command reads the JSON Lines results files written by batch --results, such as
+the files of the workers of a sharded scan or of the runs of a resumed scan, and writes
+one record per domain and caller origin. When a domain appears more than once, a record
+that was checked supersedes one that was skipped, and otherwise the record with the
+latest timestamp wins. Records with the same timestamp are duplicates and collapse into
+one. A file given as "-" is read from stdin.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		merger := batch.NewMerger()
+		for _, path := range args {
+			if err := readResultsFile(path, merger.Add); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+				os.Exit(1)
+			}
+		}
+
+		// Write the merged records to the output file or stdout
+		var w io.Writer = os.Stdout
+		if mergeOutput != "" {
+			f, err := os.Create(mergeOutput)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to create output file: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		records := merger.Records()
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to write merged results: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if debug {
+			fmt.Fprintf(os.Stderr, "Debug: Merged %d files into %d records, dropping %d duplicates\n",
+				len(args), len(records), merger.Duplicates())
+		}
+	},
+}
+
+// readResultsFile reads the records of the named results file, or of stdin when path is "-".
+func readResultsFile(path string, add func(batch.Record)) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	return batch.ReadRecords(r, func(record batch.Record) error {
+		add(record)
+		return nil
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(resultsCmd)
+	resultsCmd.AddCommand(resultsMergeCmd)
+
+	// Local flags
+	resultsMergeCmd.Flags().StringVarP(&mergeOutput, "out", "o", "", "Write the merged results to this file (default is stdout)")
+}
@@ -3,7 +3,16 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+	"github.com/developmeh/passkey-origin-validator/internal/browser"
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/developmeh/passkey-origin-validator/internal/httpcache"
+	"github.com/developmeh/passkey-origin-validator/internal/httpconfig"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -29,6 +38,63 @@ var (
 	file    string
 	example bool
 
+	// HTTP fetch limits
+	timeout     time.Duration
+	maxBodySize int64
+
+	// maxLabels is the number of unique labels counted before origins are ignored
+	maxLabels int
+
+	// contentTypePolicy is the name of the content type acceptance policy
+	contentTypePolicy string
+
+	// Per-run resource limits
+	maxRequests int64
+	maxBytes    int64
+	maxRuntime  time.Duration
+
+	// Persistent response cache
+	noCache  bool
+	cacheDir string
+
+	// httpSettings are the transport settings of the config file's http section, read
+	// once before the command runs
+	httpSettings httpconfig.Settings
+
+	// DNS preflight and resolution
+	dnsCheck     bool
+	resolverAddr string
+	hostsFile    string
+	httpsRecords bool
+
+	// Exit code policy
+	failOn           []string
+	warningsAsErrors bool
+	exitPolicy       exitcode.Policy
+
+	// Severities of findings: the lowest severity shown and counted, and the per-rule
+	// overrides of the config file
+	minSeverity       string
+	minSeverityLevel  lint.Severity
+	severityOverrides map[string]lint.Severity
+
+	// quiet prints a single verdict line per domain and nothing else
+	quiet bool
+
+	// diagnose parses a document that is not valid JSON tolerantly, to report what is
+	// wrong with it
+	diagnose bool
+
+	// harFile is the HAR file the HTTP exchanges of the run are recorded to
+	harFile string
+	// dumpHTTP prints every HTTP exchange of the run to stderr
+	dumpHTTP bool
+
+	// chromiumVersion pins the Chromium behavior model to a milestone; 0 is the latest
+	chromiumVersion int
+	// chromiumProfile is the chromium profile of chromiumVersion
+	chromiumProfile browser.Profile
+
 	// rootCmd represents the base command when called without any subcommands
 	rootCmd = &cobra.Command{
 		Use:   "passkey-origin-validator",
@@ -39,6 +105,46 @@ var (
  It can fetch the .well-known/webauthn endpoint for a given domain, parse the JSON response,
  and count the number of unique labels. It can also validate if a caller origin is authorized
  by a relying party's .well-known/webauthn file, following the same constraints as browsers.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if exitPolicy, err = exitcode.ParsePolicy(failOn, warningsAsErrors); err != nil {
+				return err
+			}
+			if minSeverityLevel, err = lint.ParseSeverity(minSeverity); err != nil {
+				return err
+			}
+			if chromiumProfile, err = browser.Chromium(chromiumVersion); err != nil {
+				return err
+			}
+			// The flags, when given, take precedence over the config file
+			maxLabels = viper.GetInt("max_labels")
+			maxBodySize = viper.GetInt64("max_body_size")
+			if timeout, err = configTimeout(); err != nil {
+				return err
+			}
+			if maxLabels < 1 {
+				return fmt.Errorf("invalid --max-labels %d: must be at least 1", maxLabels)
+			}
+			if maxBodySize < 1 {
+				return fmt.Errorf("invalid --max-body-size %d: must be at least 1", maxBodySize)
+			}
+			if severityOverrides, err = lint.ParseOverrides(viper.GetStringMapString("severity")); err != nil {
+				return err
+			}
+			if err := viper.UnmarshalKey("http", &httpSettings); err != nil {
+				return fmt.Errorf("invalid http settings: %w", err)
+			}
+			if err := httpSettings.Validate(); err != nil {
+				return err
+			}
+			if sandboxMode {
+				return enterSandbox(cmd)
+			}
+			if sandboxDir != "" {
+				return fmt.Errorf("--sandbox-dir requires --sandbox")
+			}
+			return nil
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			// Check if version flag is provided
 			versionFlag, _ := cmd.Flags().GetBool("version")
@@ -77,6 +183,131 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&file, "file", "", "Use a local JSON file instead of fetching from a domain")
 	rootCmd.PersistentFlags().BoolVar(&example, "example", false, "Run with example data for testing")
 	rootCmd.PersistentFlags().BoolP("version", "v", false, "Print version information and exit")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", counter.Timeout, "Timeout for fetching the .well-known/webauthn endpoint")
+	rootCmd.PersistentFlags().Int64Var(&maxBodySize, "max-body-size", counter.MaxBodySize, "Maximum number of bytes to read from the response body or file")
+	rootCmd.PersistentFlags().IntVar(&maxLabels, "max-labels", counter.MaxLabels, "Number of unique labels counted before further origins are ignored")
+	rootCmd.PersistentFlags().StringVar(&contentTypePolicy, "content-type-policy", "strict", "Content type acceptance policy: strict (browser behavior) or lenient (accept JSON served as text/plain with a warning)")
+	rootCmd.PersistentFlags().Int64Var(&maxRequests, "max-requests", 0, "Maximum number of HTTP requests for the whole run (0 for no limit)")
+	rootCmd.PersistentFlags().Int64Var(&maxBytes, "max-bytes", 0, "Maximum number of response bytes downloaded for the whole run (0 for no limit)")
+	rootCmd.PersistentFlags().DurationVar(&maxRuntime, "max-runtime", 0, "Maximum runtime for the whole run (0 for no limit)")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Disable the persistent response cache")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", httpcache.DefaultDir(), "Directory for the persistent response cache")
+	rootCmd.PersistentFlags().BoolVar(&dnsCheck, "dns-check", false, "Resolve the domain and report its DNS records before fetching")
+	rootCmd.PersistentFlags().StringVar(&resolverAddr, "resolver", "", "DNS server (host[:port]) to use instead of the system resolver")
+	rootCmd.PersistentFlags().StringSliceVar(&failOn, "fail-on", exitcode.DefaultFailOn, "Kinds of findings that make the command fail: error, limit, invalid, warn or none")
+	rootCmd.PersistentFlags().BoolVar(&warningsAsErrors, "warnings-as-errors", false, "Make warnings fail the command, like --fail-on warn")
+	rootCmd.PersistentFlags().StringVar(&minSeverity, "min-severity", "info", "Lowest severity of findings to show and to count for the exit status: info, warn or error")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Print only one line per domain: the domain, its verdict and its label count")
+	rootCmd.PersistentFlags().BoolVar(&diagnose, "diagnose", false, "When a document is not valid JSON, parse it tolerantly to report everything wrong with it")
+	rootCmd.PersistentFlags().StringVar(&harFile, "har", "", "Record every HTTP request and response, with headers, body and timings, to this HAR file")
+	rootCmd.PersistentFlags().BoolVar(&dumpHTTP, "dump-http", false, "Print every HTTP request and response, with headers, body and timings, to stderr")
+	rootCmd.PersistentFlags().StringVar(&hostsFile, "hosts-file", "", "Hosts file mapping host names to addresses, used instead of DNS for this tool's connections only")
+	rootCmd.PersistentFlags().BoolVar(&httpsRecords, "https-records", false, "Connect to the endpoints of the domain's DNS HTTPS records, like browsers")
+	rootCmd.PersistentFlags().BoolVar(&sandboxMode, "sandbox", false, "Only send GET requests to the targets and only write files under --sandbox-dir")
+	rootCmd.PersistentFlags().StringVar(&sandboxDir, "sandbox-dir", "", "The only directory a --sandbox run may write to (default is to write nothing)")
+	rootCmd.PersistentFlags().IntVar(&chromiumVersion, "chromium-version", 0, "Validate with Chromium's rules as of this milestone (default is the latest modeled)")
+
+	// Config file keys that set a flag when it is not given
+	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	viper.BindPFlag("max_body_size", rootCmd.PersistentFlags().Lookup("max-body-size"))
+	viper.BindPFlag("max_labels", rootCmd.PersistentFlags().Lookup("max-labels"))
+}
+
+// fetchOptions returns the counter options configured by the global flags.
+func fetchOptions() counter.Options {
+	opts := counter.DefaultOptions()
+	opts.Timeout = timeout
+	opts.MaxBodySize = maxBodySize
+	opts.MaxLabels = maxLabels
+	opts.Transport = newCachingTransport()
+
+	policy, err := counter.ParseContentTypePolicy(contentTypePolicy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts.ContentTypePolicy = policy
+
+	return opts
+}
+
+// exitOn exits with the code the --fail-on policy gives the findings of a run, and
+// returns if none of them make it fail.
+func exitOn(found exitcode.Findings) {
+	if code := exitPolicy.Code(found); code != exitcode.OK {
+		os.Exit(code)
+	}
+}
+
+// applySeverity returns findings with the severity overrides of the config file applied,
+// without those below --min-severity.
+func applySeverity(findings []lint.Finding) []lint.Finding {
+	return lint.Apply(findings, severityOverrides, minSeverityLevel)
+}
+
+// modelVersion names the behavior model selected by --chromium-version, such as
+// "chromium-128".
+func modelVersion() string {
+	return browser.ModelVersion(chromiumVersion)
+}
+
+// printModel prints the behavior model a result was made with, so that it can be
+// reproduced.
+func printModel() {
+	fmt.Printf("Model: %s\n", modelVersion())
+}
+
+// relatedOriginsUnsupported returns why the Chromium milestone of --chromium-version
+// authorizes no related origin, or "" if it implements related origin requests.
+func relatedOriginsUnsupported() string {
+	if chromiumProfile.Supported() {
+		return ""
+	}
+	return fmt.Sprintf("Chromium %d does not implement related origin requests, which shipped in Chromium 128", chromiumVersion)
+}
+
+// printVerdict prints the --quiet line of a command that checks a single document: the
+// --file or the domain argument, the verdict and the label count. err is the error that
+// kept the document from being fetched, if any.
+func printVerdict(args []string, result *counter.LabelCount, err error, callerOrigin string) {
+	domain := file
+	if domain == "" {
+		domain = "https://webauthn.io"
+		if len(args) > 0 {
+			domain = args[0]
+		}
+	}
+	record := batch.Record{Domain: domain}
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		record = batch.NewRecord(domain, result, callerOrigin)
+	}
+	fmt.Println(batch.FormatVerdict(record))
+}
+
+// configTimeout returns --timeout, or the timeout of the config file when the flag is not
+// given: a number of seconds, or a duration such as "30s".
+func configTimeout() (time.Duration, error) {
+	switch value := viper.Get("timeout").(type) {
+	case time.Duration:
+		return value, nil
+	case int:
+		return time.Duration(value) * time.Second, nil
+	case float64:
+		return time.Duration(value * float64(time.Second)), nil
+	case string:
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+			return time.Duration(seconds * float64(time.Second)), nil
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timeout %q: must be a number of seconds or a duration", value)
+		}
+		return duration, nil
+	default:
+		return 0, fmt.Errorf("invalid timeout %v: must be a number of seconds or a duration", value)
+	}
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/developmeh/passkey-origin-validator/internal/rpid"
+	"github.com/spf13/cobra"
+)
+
+// rpidOrigin is the caller origin the RP ID is checked for
+var rpidOrigin string
+
+// rpidCmd represents the rpid command
+var rpidCmd = &cobra.Command{
+	Use:   "rpid <rp-id>",
+	Short: "Check whether an RP ID is valid for a caller origin without related origins",
+	Long: `Check whether an RP ID is valid for a caller origin without related origins.
+
+Under the standard WebAuthn rules, a caller may use an RP ID that is its effective
+domain or a registrable domain suffix of it, such as example.com from
+https://login.example.com, as long as the RP ID is not a public suffix. This command
+applies those rules with the Public Suffix List, so you can tell whether a caller
+needs the RP's .well-known/webauthn document at all. When it does, check the document
+with the validate or explain command.
+
+No network requests are made.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result := rpid.Check(rpidOrigin, args[0])
+
+		fmt.Printf("Checking RP ID %s for caller origin %s\n", args[0], rpidOrigin)
+		if result.EffectiveDomain != "" {
+			fmt.Printf("Effective domain: %s\n", result.EffectiveDomain)
+		}
+		if result.RegistrableDomain != "" {
+			fmt.Printf("Registrable domain: %s (public suffix %s)\n", result.RegistrableDomain, result.PublicSuffix)
+		}
+		fmt.Printf("Status: %s\n", result.Status)
+		fmt.Println(result.Reason)
+
+		switch result.Status {
+		case rpid.Valid:
+			fmt.Println("No .well-known/webauthn document is needed for this caller.")
+		case rpid.NotSuffix:
+			fmt.Printf("The caller can only use this RP ID as a related origin listed in https://%s/.well-known/webauthn; check it with:\n", args[0])
+			fmt.Printf("  passkey-origin-validator explain %s --origin %s\n", args[0], rpidOrigin)
+		}
+
+		// Exit with non-zero status if the RP ID is not valid for the caller
+		exitOn(exitcode.Findings{Invalid: result.Status != rpid.Valid})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rpidCmd)
+
+	// Local flags for the rpid command
+	rpidCmd.Flags().StringVar(&rpidOrigin, "origin", "", "The caller origin to check the RP ID for (required)")
+	rpidCmd.MarkFlagRequired("origin")
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/sandbox"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// sandboxMode confines the run to GET requests and writes under sandboxDir
+	sandboxMode bool
+	// sandboxDir is the only directory a sandboxed run may write to
+	sandboxDir string
+	// sandboxed is the sandbox of the run, or nil if --sandbox is not set
+	sandboxed *sandbox.Sandbox
+)
+
+// refusedInSandbox are the commands that cannot run sandboxed, and why.
+var refusedInSandbox = map[string]string{
+	"doctor": "it probes endpoints with HEAD and OPTIONS requests and its own connections",
+	"fix-pr": "it pushes branches and opens pull requests",
+	"serve":  "it fetches documents for the domains its clients request",
+}
+
+// enterSandbox sets up the sandbox of --sandbox and checks that cmd, with its flags,
+// neither sends anything but GET requests to its targets nor writes outside --sandbox-dir.
+func enterSandbox(cmd *cobra.Command) error {
+	var err error
+	if sandboxed, err = sandbox.New(sandboxDir); err != nil {
+		return err
+	}
+	refuse := func(what string) error {
+		return fmt.Errorf("%w: %s", sandbox.ErrRefused, what)
+	}
+
+	if why, ok := refusedInSandbox[cmd.Name()]; ok {
+		return refuse(fmt.Sprintf("the %s command cannot run sandboxed, since %s", cmd.Name(), why))
+	}
+	if example {
+		return refuse("--example writes a temporary file")
+	}
+
+	// Sinks and anything else that sends data somewhere other than the targets
+	for flag, what := range map[string]string{
+		"webhook":    "--webhook sends transitions to a webhook",
+		"alerts":     "--alerts routes transitions to receivers",
+		"issues":     "--issues opens issues in a tracker",
+		"proxy":      "--proxy fetches through a proxy instead of from the targets",
+		"proxy-file": "--proxy-file fetches through proxies instead of from the targets",
+	} {
+		if f := cmd.Flags().Lookup(flag); f != nil && f.Changed {
+			return refuse(what)
+		}
+	}
+	if httpSettings.ProxySet() {
+		return refuse("the http.proxy setting of the config file fetches through a proxy instead of from the targets")
+	}
+
+	// Files written, which must be under --sandbox-dir
+	var paths []string
+	switch cmd {
+	case batchCmd:
+		// The summary spill file is temporary, but is a write all the same
+		if spillDir == "" {
+			spillDir = sandboxed.Dir()
+		}
+		if spillDir == "" {
+			return refuse("the batch command writes a temporary summary spill file, which needs --sandbox-dir")
+		}
+		paths = append(paths, resultsFile, summaryJSON, spillDir)
+	case bundleCreateCmd:
+		paths = append(paths, bundleOut)
+	case bundleVerifyCmd:
+		paths = append(paths, bundleResults)
+	case generateCmd:
+		paths = append(paths, generateOutput)
+	case optimizeCmd:
+		paths = append(paths, optimizeOut)
+	case resultsMergeCmd:
+		paths = append(paths, mergeOutput)
+	case historyExportCmd:
+		paths = append(paths, exportDir)
+	case snapshotSaveCmd:
+		paths = append(paths, snapshotDir)
+	case lintCmd:
+		if lintFix {
+			paths = append(paths, file)
+		}
+	}
+	paths = append(paths, harFile)
+	if f := cmd.Flags().Lookup("store"); f != nil && f.Value.String() != "" {
+		// Opening a database may write to it, even to read it
+		_, path, _ := strings.Cut(f.Value.String(), ":")
+		paths = append(paths, path)
+	}
+	for _, path := range paths {
+		if path == "" || path == "-" {
+			continue
+		}
+		if err := sandboxed.Writable(path); err != nil {
+			return err
+		}
+	}
+
+	// The response cache is only used if it is under --sandbox-dir
+	if !noCache && sandboxed.Writable(cacheDir) != nil {
+		noCache = true
+	}
+	return nil
+}
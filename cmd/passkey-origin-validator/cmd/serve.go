@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/breaker"
+	"github.com/developmeh/passkey-origin-validator/internal/grpcserver"
+	"github.com/developmeh/passkey-origin-validator/internal/listen"
+	"github.com/developmeh/passkey-origin-validator/internal/server"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+var (
+	// listenAddr is the address the REST API listens on
+	listenAddr string
+	// grpcListenAddr is the address the gRPC service listens on; empty disables it
+	grpcListenAddr string
+	// cacheTTL is how long successfully fetched documents are cached
+	cacheTTL time.Duration
+	// negativeTTL is how long fetch failures and unauthorized origins are cached
+	negativeTTL time.Duration
+	// breakerThreshold is the number of consecutive failures that opens a domain's circuit
+	breakerThreshold int
+	// breakerCooldown is how long an open circuit refuses fetches
+	breakerCooldown time.Duration
+	// accessLogPath is where REST API requests are logged; "-" is stdout and empty disables it
+	accessLogPath string
+	// logRedact lists additional query parameters and headers whose values are not logged
+	logRedact []string
+	// logHeaders lists request headers whose values are logged
+	logHeaders []string
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a REST API for validating origins and counting labels",
+	Long: `Serve a REST API for validating origins and counting labels.
+
+Endpoints:
+  GET /v1/validate?domain=<domain>&origin=<origin>
+  GET /v1/count?domain=<domain>
+  GET /healthz
+
+Fetched documents are cached in memory for --cache-ttl. Fetch failures and origins that a
+document does not authorize are cached for the shorter --negative-ttl, so that a client
+repeating a bad request does not cause repeated upstream fetches. After
+--breaker-threshold consecutive failures, a domain is not fetched again until
+--breaker-cooldown has passed, and requests for it are answered with "circuit open".
+
+With --grpc-listen, the same API is also served over gRPC (validator.v1.ValidatorService),
+sharing the caches and circuit breaker with the REST API.
+
+Both --listen and --grpc-listen accept a TCP address (":8080"), a Unix domain socket
+("unix:/run/passkey-origin-validator.sock"), an inherited file descriptor ("fd:3"), or a
+socket passed by systemd socket activation ("systemd", or "systemd:name" to select one
+by its FileDescriptorName= when several are passed).
+
+With --access-log, every REST API request is logged as one JSON line. The values of query
+parameters and headers that carry credentials (api_key, token, Authorization, Cookie,
+X-Api-Key and similar) are replaced with REDACTED; --log-redact adds more names.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// The server keeps its own cache, so fetch through the uncached transport
+		fetch := fetchOptions()
+		fetch.Transport = newTransport()
+
+		api := server.New(server.Options{
+			Fetch:       fetch,
+			TTL:         cacheTTL,
+			NegativeTTL: negativeTTL,
+			Breaker:     breaker.New(breakerThreshold, breakerCooldown),
+		})
+		var handler http.Handler = api
+		if accessLogPath != "" {
+			out, err := openAccessLog(accessLogPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if out != os.Stdout {
+				defer out.Close()
+			}
+			handler = server.AccessLog(api, slog.New(slog.NewJSONHandler(out, nil)), server.AccessLogOptions{
+				Headers:       logHeaders,
+				RedactParams:  logRedact,
+				RedactHeaders: logRedact,
+			})
+		}
+		srv := &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		listener, err := listen.Listen(listenAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Serve gRPC from the same state when requested
+		var grpcSrv *grpc.Server
+		if grpcListenAddr != "" {
+			grpcListener, err := listen.Listen(grpcListenAddr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			grpcSrv = grpc.NewServer()
+			grpcserver.Register(grpcSrv, api)
+			go func() {
+				fmt.Printf("gRPC listening on %s\n", listen.Describe(grpcListener))
+				if err := grpcSrv.Serve(grpcListener); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}()
+		}
+
+		// Shut down gracefully on interrupt
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if grpcSrv != nil {
+				grpcSrv.GracefulStop()
+			}
+			srv.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Printf("Listening on %s\n", listen.Describe(listener))
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// openAccessLog opens the access log at path for appending, or returns stdout for "-".
+func openAccessLog(path string) (*os.File, error) {
+	if path == "-" {
+		return os.Stdout, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open access log: %w", err)
+	}
+	return f, nil
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	// Local flags
+	serveCmd.Flags().StringVar(&listenAddr, "listen", ":8080", "Address to listen on: host:port, unix:path, fd:N or systemd[:name]")
+	serveCmd.Flags().StringVar(&grpcListenAddr, "grpc-listen", "", "Address to serve the gRPC API on, in the same forms as --listen (disabled when empty)")
+	serveCmd.Flags().DurationVar(&cacheTTL, "cache-ttl", server.DefaultTTL, "How long successfully fetched documents are cached")
+	serveCmd.Flags().IntVar(&breakerThreshold, "breaker-threshold", breaker.DefaultThreshold, "Consecutive failures that stop fetching a domain (0 to disable)")
+	serveCmd.Flags().DurationVar(&breakerCooldown, "breaker-cooldown", breaker.DefaultCooldown, "How long a failing domain is not fetched")
+	serveCmd.Flags().DurationVar(&negativeTTL, "negative-ttl", server.DefaultNegativeTTL, "How long fetch failures and unauthorized origins are cached")
+	serveCmd.Flags().StringVar(&accessLogPath, "access-log", "", "File to write JSON access logs of REST API requests to (\"-\" for stdout, disabled when empty)")
+	serveCmd.Flags().StringSliceVar(&logRedact, "log-redact", nil, "Additional query parameters and headers whose values are redacted from access logs (repeatable)")
+	serveCmd.Flags().StringSliceVar(&logHeaders, "log-header", nil, "Request headers whose values are included in access logs (repeatable)")
+}
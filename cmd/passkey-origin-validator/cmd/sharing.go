@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/developmeh/passkey-origin-validator/internal/appleapp"
+	"github.com/developmeh/passkey-origin-validator/internal/assetlinks"
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+	"github.com/developmeh/passkey-origin-validator/internal/rpid"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// sharingRPID is the RP ID the document is served for, if not the host it is fetched from
+	sharingRPID string
+	// sharingAppDomains are the other domains the RP's apps are associated with
+	sharingAppDomains []string
+	// sharingOutput is the format the report is printed in
+	sharingOutput string
+)
+
+// appFiles fetches the apple-app-site-association and assetlinks.json files of domains,
+// and remembers them so that each is fetched once per run.
+type appFiles struct {
+	opts    counter.Options
+	apple   map[string]appleFile
+	android map[string]androidFile
+}
+
+// appleFile is a fetched apple-app-site-association file, or why it could not be.
+type appleFile struct {
+	association *appleapp.Association
+	err         error
+}
+
+// androidFile is a fetched assetlinks.json file, or why it could not be.
+type androidFile struct {
+	links *assetlinks.Links
+	err   error
+}
+
+// newAppFiles returns an appFiles that fetches with the options of the global flags.
+func newAppFiles() *appFiles {
+	return &appFiles{opts: fetchOptions(), apple: make(map[string]appleFile), android: make(map[string]androidFile)}
+}
+
+// Apple returns the apple-app-site-association file of domain.
+func (f *appFiles) Apple(ctx context.Context, domain string) (*appleapp.Association, error) {
+	if file, ok := f.apple[domain]; ok {
+		return file.association, file.err
+	}
+	association, err := appleapp.Fetch(ctx, domain, f.opts)
+	f.apple[domain] = appleFile{association, err}
+	return association, err
+}
+
+// Android returns the assetlinks.json file of domain.
+func (f *appFiles) Android(ctx context.Context, domain string) (*assetlinks.Links, error) {
+	if file, ok := f.android[domain]; ok {
+		return file.links, file.err
+	}
+	links, err := assetlinks.Fetch(ctx, domain, f.opts)
+	f.android[domain] = androidFile{links, err}
+	return links, err
+}
+
+// checkApps cross-checks document with the app files of the RP ID, the origins' hosts
+// and appDomains. A domain missing from both files is reported once.
+func checkApps(files *appFiles, document []byte, rpID, source string, appDomains []string) []lint.Finding {
+	ctx := context.Background()
+	findings := appleapp.Check(ctx, document, appleapp.Options{RPID: rpID, Source: source, AppDomains: appDomains, Fetch: files.Apple})
+	seen := make(map[string]bool)
+	for _, finding := range findings {
+		seen[finding.Fingerprint] = true
+	}
+	for _, finding := range assetlinks.Check(ctx, document, assetlinks.Options{RPID: rpID, Source: source, AppDomains: appDomains, Fetch: files.Android}) {
+		if !seen[finding.Fingerprint] {
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// sharingDomain is a row of the credential sharing report.
+type sharingDomain struct {
+	Domain string `json:"domain"`
+	// Webauthn is how the document covers the domain: "rp-id", "listed", "rp-id-suffix"
+	// for a domain that can use the RP ID on its own, or "not-listed".
+	Webauthn string `json:"webauthn"`
+	// Index is the position of the domain's first origin in the document, if it is listed.
+	Index *int `json:"index,omitempty"`
+	// AppleApps are the app IDs of the domain's apple-app-site-association file.
+	AppleApps []string `json:"apple_apps"`
+	// AndroidApps are the package names of the domain's assetlinks.json file.
+	AndroidApps []string `json:"android_apps"`
+	// Sites are the websites of the domain's assetlinks.json file.
+	Sites []string `json:"sites,omitempty"`
+	// AppleError and AndroidError are why a file could not be read, if it could not.
+	AppleError   string `json:"apple_error,omitempty"`
+	AndroidError string `json:"android_error,omitempty"`
+}
+
+// sharingReport is the JSON form of the sharing command's output.
+type sharingReport struct {
+	Source   string          `json:"source"`
+	RPID     string          `json:"rp_id"`
+	Domains  []sharingDomain `json:"domains"`
+	Findings []lint.Finding  `json:"findings"`
+}
+
+// sharingCmd represents the sharing command
+var sharingCmd = &cobra.Command{
+	Use:   "sharing [domain]",
+	Short: "Report how the webauthn, apple-app-site-association and assetlinks.json files share credentials",
+	Long: `Report how the webauthn, apple-app-site-association and assetlinks.json files share credentials.
+
+Three well-known documents govern which apps and websites can use the passkeys of an
+RP ID: .well-known/webauthn lists the related origins of other websites,
+apple-app-site-association the iOS and macOS apps of its webcredentials section, and
+assetlinks.json the Android apps and websites granted
+delegate_permission/common.get_login_creds. This command fetches the webauthn document
+of a given domain, then the other two files of the RP ID, of the hosts of its https
+origins, of the websites of the RP ID's assetlinks.json and of each --app-domain, and
+prints a table of what each domain declares, followed by the inconsistencies between
+them, as the findings of lint --apps:
+
+  app-rp-mismatch          a listed host shares credentials with apps the RP ID does not
+  app-origin-missing       a domain shares apps or credentials with the RP ID, but its
+                           origin is not listed
+  android-origin-unlinked  an Android app origin of the document is not linked in the
+                           RP ID's assetlinks.json
+  app-site-association     an apple-app-site-association file cannot be read
+  asset-links              an assetlinks.json file cannot be read
+
+The host the document is fetched from, or --rp-id for a --file, is taken as the RP ID.
+With --output json, the table and findings are printed as a JSON object.
+
+If no domain is provided, it uses the default domain (webauthn.io).
+If the --file flag is provided, it reads from the specified file instead.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if sharingOutput != "text" && sharingOutput != "json" {
+			fmt.Fprintf(os.Stderr, "Error: unknown output format %q: expected text or json\n", sharingOutput)
+			os.Exit(1)
+		}
+		if file != "" && sharingRPID == "" {
+			fmt.Fprintf(os.Stderr, "Error: --rp-id is required with --file\n")
+			os.Exit(1)
+		}
+
+		var result *counter.LabelCount
+		var err error
+
+		// Check if we're reading from a file
+		if file != "" {
+			if debug {
+				fmt.Printf("Debug: Reading from file: %s\n", file)
+			}
+			result, err = counter.CountLabelsFromFileWithOptions(file, fetchOptions())
+		} else {
+			// Get the domain from command-line arguments or use the default
+			domain := "https://webauthn.io"
+			if len(args) > 0 {
+				domain = args[0]
+			}
+
+			if debug {
+				fmt.Printf("Debug: Testing domain: %s\n", domain)
+			}
+
+			runDNSPreflight(domain)
+			result, err = counter.CountLabelsWithOptions(domain, fetchOptions())
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exitOn(exitcode.Findings{Error: true})
+			return
+		}
+
+		// The origins of a document that could not be read cannot be compared
+		if result.ErrorMessage != "" && result.RawJSON == "" {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", result.ErrorMessage)
+			if result.Remediation != "" {
+				fmt.Fprintf(os.Stderr, "Remediation: %s\n", result.Remediation)
+			}
+			exitOn(exitcode.Findings{Error: true})
+			return
+		}
+
+		rpID := strings.ToLower(sharingRPID)
+		if sourceURL, err := url.Parse(result.URL); err == nil && rpID == "" {
+			rpID = sourceURL.Hostname()
+		}
+		files := newAppFiles()
+		report := sharingReport{Source: result.URL, RPID: rpID, Domains: []sharingDomain{}}
+		report.Findings = applySeverity(checkApps(files, []byte(result.RawJSON), rpID, result.URL, sharingAppDomains))
+		if report.Findings == nil {
+			report.Findings = []lint.Finding{}
+		}
+		for _, domain := range sharingDomains(files, result, rpID, sharingAppDomains) {
+			report.Domains = append(report.Domains, describeSharing(files, result, rpID, domain))
+		}
+
+		if sharingOutput == "json" {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(data))
+		} else {
+			fmt.Printf("Credential sharing of RP ID %s (%s)\n\n", rpID, result.URL)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "DOMAIN\tWEBAUTHN\tAPPLE APPS\tANDROID APPS")
+			for _, d := range report.Domains {
+				webauthn := map[string]string{"rp-id": "RP ID", "rp-id-suffix": "RP ID suffix", "not-listed": "not listed"}[d.Webauthn]
+				if d.Index != nil {
+					webauthn = fmt.Sprintf("origins[%d]", *d.Index)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Domain, webauthn, sharingApps(d.AppleApps, d.AppleError), sharingApps(d.AndroidApps, d.AndroidError))
+			}
+			w.Flush()
+			fmt.Println()
+			if len(report.Findings) == 0 {
+				fmt.Println("The three files are consistent")
+			}
+			fmt.Print(lint.FormatFindings(report.Findings))
+		}
+
+		exitOn(exitcode.Findings{
+			Invalid: lint.HasErrors(report.Findings),
+			Warn:    lint.HasWarnings(report.Findings),
+		})
+	},
+}
+
+// sharingDomains returns the domains of the report: the RP ID, the hosts of the https
+// origins of the document in order, then the websites of the RP ID's assetlinks.json
+// and the app domains that are not among them.
+func sharingDomains(files *appFiles, result *counter.LabelCount, rpID string, appDomains []string) []string {
+	domains := []string{rpID}
+	seen := map[string]bool{rpID: true}
+	add := func(domain string) {
+		if domain != "" && !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+	for _, origin := range result.Origins {
+		if originURL, err := url.Parse(origin); err == nil && strings.EqualFold(originURL.Scheme, "https") {
+			add(strings.ToLower(originURL.Hostname()))
+		}
+	}
+	if links, err := files.Android(context.Background(), rpID); err == nil {
+		for _, site := range links.Sites {
+			if siteURL, err := url.Parse(site); err == nil {
+				add(strings.ToLower(siteURL.Hostname()))
+			}
+		}
+	}
+	for _, domain := range appDomains {
+		add(strings.ToLower(strings.TrimPrefix(domain, "https://")))
+	}
+	return domains
+}
+
+// describeSharing returns the report row of domain.
+func describeSharing(files *appFiles, result *counter.LabelCount, rpID, domain string) sharingDomain {
+	d := sharingDomain{Domain: domain, Webauthn: "not-listed", AppleApps: []string{}, AndroidApps: []string{}}
+	for i, origin := range result.Origins {
+		if originURL, err := url.Parse(origin); err == nil && strings.EqualFold(originURL.Scheme, "https") && strings.EqualFold(originURL.Hostname(), domain) {
+			d.Webauthn, d.Index = "listed", &i
+			break
+		}
+	}
+	switch {
+	case domain == rpID:
+		d.Webauthn, d.Index = "rp-id", nil
+	case d.Index == nil && rpid.Check("https://"+domain, rpID).Status == rpid.Valid:
+		d.Webauthn = "rp-id-suffix"
+	}
+
+	ctx := context.Background()
+	if association, err := files.Apple(ctx, domain); err != nil {
+		d.AppleError = err.Error()
+	} else {
+		d.AppleApps = append(d.AppleApps, association.Apps...)
+	}
+	if links, err := files.Android(ctx, domain); err != nil {
+		d.AndroidError = err.Error()
+	} else {
+		packages := make(map[string]bool)
+		for _, app := range links.Apps {
+			packages[app.Package] = true
+		}
+		for name := range packages {
+			d.AndroidApps = append(d.AndroidApps, name)
+		}
+		sort.Strings(d.AndroidApps)
+		d.Sites = links.Sites
+	}
+	return d
+}
+
+// sharingApps formats the apps of a report row for the table.
+func sharingApps(apps []string, err string) string {
+	switch {
+	case err != "":
+		return "unreadable"
+	case len(apps) == 0:
+		return "-"
+	}
+	return strings.Join(apps, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(sharingCmd)
+
+	// Local flags for the sharing command
+	sharingCmd.Flags().StringVar(&sharingRPID, "rp-id", "", "RP ID the document is served for (default is the host it is fetched from; required with --file)")
+	sharingCmd.Flags().StringSliceVar(&sharingAppDomains, "app-domain", nil, "Another domain the RP's apps are associated with (repeatable)")
+	sharingCmd.Flags().StringVar(&sharingOutput, "output", "text", "Output format: text or json")
+}
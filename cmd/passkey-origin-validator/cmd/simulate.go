@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/developmeh/passkey-origin-validator/internal/browser"
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/developmeh/passkey-origin-validator/internal/simulate"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// simulateAdd are the origins the simulation adds to the document
+	simulateAdd []string
+	// simulateRemove are the origins the simulation removes from the document
+	simulateRemove []string
+	// simulatePatch is a file of +origin and -origin lines to apply
+	simulatePatch string
+	// simulateOrigins are caller origins whose outcomes are always reported
+	simulateOrigins []string
+)
+
+// simulateCmd represents the simulate command
+var simulateCmd = &cobra.Command{
+	Use:   "simulate [domain]",
+	Short: "Report what proposed edits to a document would change",
+	Long: `Report what proposed edits to a document would change.
+
+This command reads a .well-known/webauthn document, fetched from a domain or read from
+--file, applies proposed edits to it in memory and compares the document as it is with
+the document as it would be. Nothing is written, so edits can be tried before the live
+file is touched.
+
+Edits are given with --add and --remove, or with --patch, a file of lines of the form
++origin to add an origin and -origin to remove one ("-" for stdin). A unified diff of
+the document can be used as a patch too. Added origins are appended to the end of the
+list, where the order decides whether their label still fits, and removed origins are
+matched once normalized, so that example.com removes https://example.com.
+
+The report lists the edits, the labels the document would count, the caller origins
+that would be newly authorized or broken, and what each browser would decide for them:
+for every --origin, and for every origin either document lists whose outcome changes in
+some browser. The edited document is evaluated as if it were served the same way.
+
+The command exits with status 3 when an edit would take an origin's authorization
+away, and with status 2 when the edited document would exceed the label limit.
+
+If no domain is provided, it uses the default domain (webauthn.io).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		edit := simulate.Edit{Add: simulateAdd, Remove: simulateRemove}
+		if simulatePatch != "" {
+			f := os.Stdin
+			if simulatePatch != "-" {
+				var err error
+				if f, err = os.Open(simulatePatch); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: failed to open patch: %v\n", err)
+					os.Exit(1)
+				}
+				defer f.Close()
+			}
+			patch, err := simulate.ParsePatch(f)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			edit.Add = append(edit.Add, patch.Add...)
+			edit.Remove = append(edit.Remove, patch.Remove...)
+		}
+		if len(edit.Add) == 0 && len(edit.Remove) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no edits to simulate: give --add, --remove or --patch\n")
+			os.Exit(1)
+		}
+
+		var result *counter.LabelCount
+		var err error
+		if file != "" {
+			if debug {
+				fmt.Printf("Debug: Reading from file: %s\n", file)
+			}
+			result, err = counter.CountLabelsFromFileWithOptions(file, fetchOptions())
+		} else {
+			domain := "https://webauthn.io"
+			if len(args) > 0 {
+				domain = args[0]
+			}
+			if debug {
+				fmt.Printf("Debug: Testing domain: %s\n", domain)
+			}
+			runDNSPreflight(domain)
+			result, err = counter.CountLabelsWithOptions(domain, fetchOptions())
+		}
+		if err == nil && result.ErrorMessage != "" && result.RawJSON == "" {
+			err = fmt.Errorf("%s", result.ErrorMessage)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		profiles, err := browser.WithChromium(browser.Profiles(), chromiumVersion)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		sim, err := simulate.Simulate(result, edit, simulateOrigins, profiles)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Simulating edits to %s (nothing is written)\n", result.URL)
+		printModel()
+		fmt.Println()
+
+		fmt.Println("Edits:")
+		for _, origin := range sim.Applied.Added {
+			fmt.Printf("  + %s\n", origin)
+		}
+		for _, origin := range sim.Applied.Removed {
+			fmt.Printf("  - %s\n", origin)
+		}
+		for _, origin := range sim.Applied.AlreadyListed {
+			fmt.Printf("  Note: %s is already listed, so adding it changes nothing\n", origin)
+		}
+		for _, origin := range sim.Applied.NotListed {
+			fmt.Printf("  Note: %s is not listed, so removing it changes nothing\n", origin)
+		}
+
+		fmt.Printf("\nLabels: %d -> %d (limit %d)\n", sim.Before.Count, sim.After.Count, sim.After.MaxLabels)
+		for _, label := range sim.Diff.LabelsAdded {
+			fmt.Printf("  + %s\n", label)
+		}
+		for _, label := range sim.Diff.LabelsRemoved {
+			fmt.Printf("  - %s\n", label)
+		}
+		if sim.After.ExceedsLimit {
+			fmt.Printf("  WARNING: the edited document would exceed the limit of %d labels\n", sim.After.MaxLabels)
+		}
+
+		removed := make(map[string]bool)
+		for _, origin := range sim.Applied.Removed {
+			removed[origin] = true
+		}
+		var authorized, broken []string
+		for _, change := range sim.Diff.Outcomes {
+			switch {
+			case change.Lost() && removed[change.Origin]:
+				broken = append(broken, fmt.Sprintf("%s (removed)", change.Origin))
+			case change.Lost():
+				broken = append(broken, fmt.Sprintf("%s (%s)", change.Origin, change.After))
+			case change.After == counter.StatusSuccess:
+				authorized = append(authorized, change.Origin)
+			}
+		}
+		if len(authorized) > 0 {
+			fmt.Printf("\nNewly authorized:\n  %s\n", strings.Join(authorized, "\n  "))
+		}
+		if len(broken) > 0 {
+			fmt.Printf("\nBroken:\n  %s\n", strings.Join(broken, "\n  "))
+		}
+
+		if len(sim.Callers) > 0 {
+			fmt.Println("\nBrowser outcomes:")
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			header := []string{"ORIGIN"}
+			for _, p := range profiles {
+				header = append(header, strings.ToUpper(p.Name()))
+			}
+			fmt.Fprintln(w, strings.Join(header, "\t"))
+			for _, outcomes := range sim.Callers {
+				cells := []string{outcomes.Origin}
+				for i := range outcomes.Before {
+					before, after := simulatedOutcome(outcomes.Before[i]), simulatedOutcome(outcomes.After[i])
+					if before != after {
+						before += " -> " + after
+					}
+					cells = append(cells, before)
+				}
+				fmt.Fprintln(w, strings.Join(cells, "\t"))
+			}
+			w.Flush()
+		} else {
+			fmt.Println("\nNo browser decides differently for any origin")
+		}
+
+		// Exit with non-zero status if the edits would break an origin or the label limit
+		exitOn(exitcode.Findings{Invalid: sim.Diff.Regressed(), Limit: sim.After.ExceedsLimit})
+	},
+}
+
+// simulatedOutcome returns a one-word form of what a browser decides.
+func simulatedOutcome(outcome browser.Outcome) string {
+	switch {
+	case !outcome.Supported:
+		return "unsupported"
+	case outcome.Refused != "":
+		return "refused"
+	case outcome.Allowed():
+		return "allowed"
+	case outcome.Status == counter.StatusBadRelyingPartyIDNoJSONMatchHitLimits:
+		return "label-limit"
+	default:
+		return "rejected"
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(simulateCmd)
+
+	// Local flags
+	simulateCmd.Flags().StringSliceVar(&simulateAdd, "add", nil, "Origin to add to the end of the document (repeatable)")
+	simulateCmd.Flags().StringSliceVar(&simulateRemove, "remove", nil, "Origin to remove from the document (repeatable)")
+	simulateCmd.Flags().StringVar(&simulatePatch, "patch", "", "File of +origin and -origin lines to apply (\"-\" for stdin)")
+	simulateCmd.Flags().StringSliceVar(&simulateOrigins, "origin", nil, "Caller origin whose outcomes are always reported (repeatable)")
+}
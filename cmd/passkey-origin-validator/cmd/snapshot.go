@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/snapshot"
+	"github.com/developmeh/passkey-origin-validator/internal/tally"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// snapshotDir is the directory snapshots are stored in
+	snapshotDir string
+	// snapshotOrigins are caller origins whose validation outcome is tracked over time
+	snapshotOrigins []string
+)
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Store fetched documents and review their history",
+	Long: `Store fetched .well-known/webauthn documents and review their history.
+
+Use "snapshot save" to fetch and store domains' documents, for example from a scheduled
+job, and "snapshot diff" to see how a domain's origins evolved and when a regression was
+introduced.
+
+Snapshots are kept in --snapshot-dir. Documents are stored by the SHA-256 of their
+content, so a document that does not change between saves is stored once.`,
+}
+
+// snapshotSaveCmd represents the snapshot save command
+var snapshotSaveCmd = &cobra.Command{
+	Use:   "save <domain>...",
+	Short: "Fetch and store domains' documents",
+	Long: `Fetch and store domains' documents.
+
+Each domain's .well-known/webauthn endpoint is fetched, bypassing the response cache,
+and stored with the time and fetch metadata such as the URL, content type and any
+warnings. A fetch that fails is stored too, so that outages show up in the history.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := snapshot.Open(snapshotDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// A snapshot must record what is served now, so skip the cache
+		fetch := fetchOptions()
+		fetch.Transport = newTransport()
+
+		failed := false
+		runTally := tally.Start(time.Now())
+		for _, domain := range args {
+			if debug {
+				fmt.Printf("Debug: Testing domain: %s\n", domain)
+			}
+			runDNSPreflight(domain)
+			result, err := counter.CountLabelsWithOptions(domain, fetch)
+			if err != nil {
+				result = &counter.LabelCount{ErrorMessage: err.Error()}
+			}
+
+			history, err := store.History(domain)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			snap, err := store.Save(domain, result, time.Now())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Say whether the document changed since the previous snapshot
+			switch {
+			case snap.Digest == "":
+				failed = true
+				fmt.Printf("Saved %s: fetch failed: %s\n", snap.Domain, snap.Error)
+			case len(history) > 0 && history[len(history)-1].Digest == snap.Digest:
+				fmt.Printf("Saved %s: %s, unchanged since %s\n", snap.Domain, snap.ShortDigest(), history[len(history)-1].Time.Format(time.RFC3339))
+			default:
+				fmt.Printf("Saved %s: %s, %d origins\n", snap.Domain, snap.ShortDigest(), len(result.Origins))
+			}
+			switch {
+			case snap.Digest == "":
+				runTally.Add(tally.Errored)
+			case len(result.Warnings) > 0:
+				runTally.Add(tally.Warned)
+			default:
+				runTally.Add(tally.Passed)
+			}
+		}
+
+		runTally.Finish(time.Now())
+		fmt.Println()
+		fmt.Print(runTally.Format("domains"))
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// snapshotDiffCmd represents the snapshot diff command
+var snapshotDiffCmd = &cobra.Command{
+	Use:   "diff <domain>",
+	Short: "Show how a domain's document changed over time",
+	Long: `Show how a domain's document changed over time.
+
+Every change between consecutive snapshots is printed, oldest first, with the origins
+and labels added and removed and the origins whose validation outcome changed. Snapshots
+of the same document are not changes, and neither are documents that were only
+reordered or reformatted. A change that took an origin's authorization away, including
+the endpoint failing, is marked as a REGRESSION; with --origin, the outcome of each
+given caller origin is followed too.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := snapshot.Open(snapshotDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		history, err := store.History(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(history) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no snapshots of %s; save one with \"snapshot save\"\n", args[0])
+			os.Exit(1)
+		}
+
+		changes, err := store.Changes(args[0], snapshotOrigins, maxLabels)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("%s: %d snapshots from %s to %s, %d changes\n", history[0].Domain, len(history),
+			history[0].Time.Format(time.RFC3339), history[len(history)-1].Time.Format(time.RFC3339), len(changes))
+		fmt.Print(snapshot.FormatChanges(changes))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotSaveCmd)
+	snapshotCmd.AddCommand(snapshotDiffCmd)
+
+	// Local flags
+	snapshotCmd.PersistentFlags().StringVar(&snapshotDir, "snapshot-dir", snapshot.DefaultDir(), "Directory snapshots are stored in")
+	snapshotDiffCmd.Flags().StringSliceVar(&snapshotOrigins, "origin", nil, "Caller origin whose validation outcome is followed (repeatable)")
+}
@@ -3,15 +3,29 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 
+	"github.com/developmeh/passkey-origin-validator/internal/browser"
 	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/exitcode"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
 	"github.com/spf13/cobra"
 )
 
 var (
 	// Origin is the caller origin to validate
 	origin string
+	// validateOutput is the format the result is printed in
+	validateOutput string
+	// validateBrowsers are the browser profiles to report outcomes for
+	validateBrowsers []string
+	// validateStrictOrigins reports origins that only match after normalization
+	validateStrictOrigins bool
+	// validateSecureOrigins are http origins the browser is configured to treat as secure
+	validateSecureOrigins []string
+	// androidCert is the SHA-256 fingerprint of the signing certificate of an Android app caller
+	androidCert string
 )
 
 // validateCmd represents the validate command
@@ -23,16 +37,71 @@ var validateCmd = &cobra.Command{
 This command fetches the .well-known/webauthn endpoint for a given domain,
 parses the JSON response, and checks if the specified caller origin is authorized.
 
+With --browser, the outcome in each selected browser is reported too, following its
+support for related origins, its label limit and its response constraints; "all"
+selects chromium, safari, firefox and spec.
+
+Origins are compared as browsers compare them: the scheme and host in lowercase,
+without the default port. The caller origin must be an origin, without a path. With
+--strict-origins, origins that only match after this normalization, such as
+https://Example.com:443, are reported as warnings. Ports are compared exactly, so
+https://example.com:8443 only matches an entry with the same port.
+
+Browsers only allow WebAuthn in a secure context: an https origin, or an http origin
+on a loopback host such as http://localhost:3000 or http://127.0.0.1:8080. Any other
+http caller origin is reported as insecure-caller, unless it is listed with
+--secure-origin, for browsers started with a flag such as Chrome's
+--unsafely-treat-insecure-origin-as-secure. A loopback host has no registrable domain,
+so a loopback caller is never authorized as a related origin and is reported as
+loopback-caller; local development uses the RP ID localhost instead.
+
+With --output sarif, a caller origin that is not authorized is reported as a
+not-authorized result in a SARIF 2.1.0 log, for GitHub Code Scanning and other SARIF
+consumers. With --output json, it is reported in a JSON report, together with how the
+document was served, and each finding carries a machine-readable remediation.
+
+An Android app is validated by its origin, android:apk-key-hash: followed by the
+unpadded base64url SHA-256 hash of its signing certificate, or with --android-cert by
+the fingerprint of that certificate as keytool and apksigner print it. Android app
+origins in the document have no label, so they never count towards the label limit,
+and only authorize the app they name.
+
 If no domain is provided, it uses the default domain (webauthn.io).
 If the --file flag is provided, it reads from the specified file instead.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if androidCert != "" {
+			if origin != "" {
+				fmt.Fprintf(os.Stderr, "Error: --origin and --android-cert cannot be combined\n")
+				os.Exit(1)
+			}
+			var err error
+			if origin, err = counter.AndroidOrigin(androidCert); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
 		if origin == "" {
-			fmt.Fprintf(os.Stderr, "Error: --origin flag is required\n")
+			fmt.Fprintf(os.Stderr, "Error: --origin or --android-cert flag is required\n")
+			os.Exit(1)
+		}
+		if validateOutput != "text" && validateOutput != "sarif" && validateOutput != "json" {
+			fmt.Fprintf(os.Stderr, "Error: unknown output format %q: expected text, sarif or json\n", validateOutput)
+			os.Exit(1)
+		}
+		profiles, err := browser.Parse(validateBrowsers)
+		if err == nil {
+			profiles, err = browser.WithChromium(profiles, chromiumVersion)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if quiet && validateOutput != "text" {
+			fmt.Fprintf(os.Stderr, "Error: --quiet cannot be combined with --output %s\n", validateOutput)
 			os.Exit(1)
 		}
 
 		var result *counter.LabelCount
-		var err error
 
 		// Check if we're reading from a file
 		if file != "" {
@@ -40,7 +109,7 @@ If the --file flag is provided, it reads from the specified file instead.`,
 				fmt.Printf("Debug: Reading from file: %s\n", file)
 				fmt.Printf("Debug: Validating caller origin: %s\n", origin)
 			}
-			result, err = counter.CountLabelsFromFile(file)
+			result, err = counter.CountLabelsFromFileWithOptions(file, fetchOptions())
 		} else {
 			// Get the domain from command-line arguments or use the default
 			domain := "https://webauthn.io"
@@ -53,17 +122,38 @@ If the --file flag is provided, it reads from the specified file instead.`,
 				fmt.Printf("Debug: Validating caller origin: %s\n", origin)
 			}
 
-			result, err = counter.CountLabels(domain)
+			runDNSPreflight(domain)
+			result, err = counter.CountLabelsWithOptions(domain, fetchOptions())
 		}
 
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			if quiet {
+				printVerdict(args, nil, err, origin)
+			} else {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			exitOn(exitcode.Findings{Error: true})
+			return
 		}
 
+		if result.ErrorMessage != "" && quiet {
+			printVerdict(args, result, nil, origin)
+			exitOn(exitcode.Findings{Error: true})
+			return
+		}
 		if result.ErrorMessage != "" {
 			fmt.Fprintf(os.Stderr, "Error: %s\n", result.ErrorMessage)
-			os.Exit(1)
+			if result.ParseError != nil {
+				fmt.Fprintln(os.Stderr, counter.FormatSnippet(result.ParseError))
+				if diagnose {
+					printDiagnosis(os.Stderr, []byte(result.RawJSON))
+				}
+			}
+			if result.Remediation != "" {
+				fmt.Fprintf(os.Stderr, "Remediation: %s\n", result.Remediation)
+			}
+			exitOn(exitcode.Findings{Error: true})
+			return
 		}
 
 		// Parse the JSON response
@@ -74,23 +164,97 @@ If the --file flag is provided, it reads from the specified file instead.`,
 		}
 
 		// Validate the caller origin
-		status := counter.ValidateWellKnownJSON(origin, []byte(result.RawJSON))
+		status := counter.ValidateWellKnownJSONWithMaxLabels(origin, []byte(result.RawJSON), maxLabels)
+
+		// Find the problems with how the document was served, whether the caller origin is
+		// authorized and can make requests at all, look-alike origins, a missing RP's own
+		// origin and, with --strict-origins, origins that need normalizing, but not the rest
+		// of the document's findings, at their configured severities
+		var rpID string
+		if sourceURL, err := url.Parse(result.URL); err == nil {
+			rpID = sourceURL.Hostname()
+		}
+		findings := lint.ServingFindings(result.Warnings, result.URL)
+		for _, finding := range lint.Check([]byte(result.RawJSON), lint.Options{CallerOrigins: []string{origin}, Source: result.URL, MaxLabels: maxLabels,
+			RPID: rpID, StrictOrigins: validateStrictOrigins, SecureOrigins: validateSecureOrigins}) {
+			switch finding.Rule {
+			case lint.RuleNotAuthorized, lint.RuleInsecureCaller, lint.RuleLoopbackCaller, lint.RuleConfusableOrigin, lint.RuleNonCanonicalOrigin,
+				lint.RuleSelfOriginMissing, lint.RuleThirdPartyOnly:
+				findings = append(findings, finding)
+			}
+		}
+		findings = applySeverity(findings)
 
 		// Print the results
-		fmt.Printf("Validating caller origin: %s against domain: %s\n", origin, result.URL)
-		fmt.Printf("Status: %s\n", status)
+		switch {
+		case quiet:
+			printVerdict(args, result, nil, origin)
+		case validateOutput == "sarif":
+			printSARIF(findings, result.URL, []byte(result.RawJSON))
+		case validateOutput == "json":
+			printReport(findings, result.URL)
+		default:
+			for _, finding := range findings {
+				switch finding.Rule {
+				case lint.RuleServing, lint.RuleSelfOriginMissing, lint.RuleThirdPartyOnly:
+					fmt.Fprintf(os.Stderr, "%s: %s\n", severityLabels[finding.Severity], finding.Message)
+				case lint.RuleInsecureCaller, lint.RuleLoopbackCaller:
+					fmt.Fprintf(os.Stderr, "%s: %s: %s\n", severityLabels[finding.Severity], finding.Origin, finding.Message)
+				case lint.RuleConfusableOrigin, lint.RuleNonCanonicalOrigin:
+					if finding.Index < 0 {
+						fmt.Fprintf(os.Stderr, "%s: %s: %s\n", severityLabels[finding.Severity], finding.Origin, finding.Message)
+					} else {
+						fmt.Fprintf(os.Stderr, "%s: origins[%d] %s: %s\n", severityLabels[finding.Severity], finding.Index, finding.Origin, finding.Message)
+					}
+				}
+			}
+			fmt.Printf("Validating caller origin: %s against domain: %s\n", origin, result.URL)
+			printModel()
+			fmt.Printf("Status: %s\n", status)
+		}
 
-		// Exit with non-zero status if the validation failed
-		if status != counter.StatusSuccess {
-			os.Exit(3)
+		// The pinned Chromium milestone may not implement related origin requests at all
+		unsupported := relatedOriginsUnsupported()
+		if unsupported != "" && !quiet {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", unsupported)
+		}
+
+		// Report the outcome in each selected browser
+		outcomes := browser.Evaluate(profiles, origin, result)
+		if len(outcomes) > 0 && !quiet && validateOutput == "text" {
+			fmt.Println("Browsers:")
+			for _, outcome := range outcomes {
+				fmt.Printf("  %-9s %s\n", outcome.Browser, outcome)
+			}
+		}
+		rejected := false
+		for _, outcome := range outcomes {
+			rejected = rejected || !outcome.Allowed()
 		}
+
+		// Exit with non-zero status if the validation failed
+		exitOn(exitcode.Findings{
+			Invalid: lint.HasErrors(findings) || rejected || unsupported != "",
+			Warn:    lint.HasWarnings(findings),
+		})
 	},
 }
 
+// severityLabels prefix the messages printed for findings of each severity.
+var severityLabels = map[lint.Severity]string{
+	lint.SeverityInfo:    "Info",
+	lint.SeverityWarning: "Warning",
+	lint.SeverityError:   "Error",
+}
+
 func init() {
 	rootCmd.AddCommand(validateCmd)
 
 	// Local flags
-	validateCmd.Flags().StringVar(&origin, "origin", "", "The caller origin to validate (required)")
-	validateCmd.MarkFlagRequired("origin")
+	validateCmd.Flags().StringVar(&origin, "origin", "", "The caller origin to validate (required unless --android-cert is given)")
+	validateCmd.Flags().StringVar(&validateOutput, "output", "text", "Output format: text, sarif or json")
+	validateCmd.Flags().StringSliceVar(&validateBrowsers, "browser", nil, "Browser profiles to report outcomes for: chromium, safari, firefox, spec or all")
+	validateCmd.Flags().BoolVar(&validateStrictOrigins, "strict-origins", false, "Warn about origins that only match after normalizing their case or default port")
+	validateCmd.Flags().StringSliceVar(&validateSecureOrigins, "secure-origin", nil, "An http origin the browser is configured to treat as a secure context (repeatable)")
+	validateCmd.Flags().StringVar(&androidCert, "android-cert", "", "SHA-256 fingerprint of the signing certificate of an Android app to validate instead of --origin")
 }
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/developmeh/passkey-origin-validator/internal/vantage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// proxies are the vantage point specifications given with --proxy
+	proxies []string
+	// proxyFile is a file containing one vantage point specification per line
+	proxyFile string
+	// includeDirect adds a direct connection from the local host as a vantage point
+	includeDirect bool
+)
+
+// vantageCmd represents the vantage command
+var vantageCmd = &cobra.Command{
+	Use:   "vantage [domain]",
+	Short: "Compare a .well-known/webauthn endpoint as seen through several proxies",
+	Long: `Compare a .well-known/webauthn endpoint as seen through several proxies.
+
+This command fetches the same .well-known/webauthn URL through a list of HTTP proxies,
+typically one per region or CDN, and flags vantage points that see a different result.
+Each proxy is given as "name=proxy-url" or just "proxy-url".
+
+If no domain is provided, it uses the default domain (webauthn.io).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get the domain from command-line arguments or use the default
+		domain := "https://webauthn.io"
+		if len(args) > 0 {
+			domain = args[0]
+		}
+
+		// Collect the vantage points from flags and the proxy file
+		specs := proxies
+		if proxyFile != "" {
+			lines, err := readLines(proxyFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			specs = append(specs, lines...)
+		}
+
+		var vantages []vantage.Vantage
+		if includeDirect {
+			vantages = append(vantages, vantage.Vantage{Name: "direct"})
+		}
+		for _, spec := range specs {
+			v, err := vantage.ParseVantage(spec)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			vantages = append(vantages, v)
+		}
+		if len(vantages) < 2 {
+			fmt.Fprintf(os.Stderr, "Error: at least two vantage points are required (use --proxy, --proxy-file or --include-direct)\n")
+			os.Exit(1)
+		}
+
+		if debug {
+			fmt.Printf("Debug: Testing domain: %s\n", domain)
+			fmt.Printf("Debug: Vantage points: %v\n", vantages)
+		}
+
+		// Vantage points must never share cached responses, so build uncached transports
+		factory := func(proxy func(*http.Request) (*url.URL, error)) http.RoundTripper {
+			transport := newHTTPTransport()
+			transport.Proxy = proxy
+			return wrapTransport(record(transport))
+		}
+		report, err := vantage.Compare(domain, vantages, fetchOptions(), factory)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Print the report
+		fmt.Print(vantage.FormatReport(report))
+
+		// Exit with non-zero status if the vantage points disagree
+		if !report.Consistent() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(vantageCmd)
+
+	// Local flags
+	vantageCmd.Flags().StringArrayVar(&proxies, "proxy", nil, "Proxy to fetch through, as name=proxy-url (repeatable)")
+	vantageCmd.Flags().StringVar(&proxyFile, "proxy-file", "", "File with one proxy per line, as name=proxy-url")
+	vantageCmd.Flags().BoolVar(&includeDirect, "include-direct", false, "Also fetch directly from this host and compare")
+}
@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/alert"
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+	"github.com/developmeh/passkey-origin-validator/internal/breaker"
+	"github.com/developmeh/passkey-origin-validator/internal/issues"
+	"github.com/developmeh/passkey-origin-validator/internal/store"
+	"github.com/developmeh/passkey-origin-validator/internal/watch"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	// interval is the watch schedule, as a duration or a cron expression
+	interval string
+	// watchFile is a file containing one domain per line to watch
+	watchFile string
+	// webhookURL receives every transition as a JSON POST
+	webhookURL string
+	// issueTracker opens an issue for every persistent finding
+	issueTracker string
+	// issueAfter is the number of consecutive checks a finding must persist for
+	issueAfter int
+	// alertsFile tags domains and routes transitions to receivers by tag and severity
+	alertsFile string
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch [domain...]",
+	Short: "Re-validate domains on a schedule and report changes",
+	Long: `Re-validate domains on a schedule and report changes.
+
+This command checks each domain's .well-known/webauthn endpoint immediately and then on
+the schedule given by --interval, either a duration ("5m", "@every 1h") or a five-field
+cron expression ("*/15 * * * *"). After each check it compares every domain with the
+previous check and logs transitions: origins added or removed, the --origin status
+flipping, the label count crossing the limit, and the endpoint failing or recovering.
+With --webhook, each transition is also POSTed to the given URL as JSON. When the
+WEBHOOK_SECRET environment variable is set, each request is signed with an HMAC-SHA256
+of its timestamp and body, in the X-Passkey-Origin-Validator-Signature and
+X-Passkey-Origin-Validator-Timestamp headers.
+
+With --alerts, transitions are routed to webhook, Slack and PagerDuty receivers by the
+tags of the domain and the severity of the transition (info, warning or error), as
+declared in a YAML or JSON file:
+
+  domains:
+    - domain: pay.example.com
+      tags: [payments]
+  receivers:
+    oncall: {type: pagerduty, routing_key: "${PAGERDUTY_ROUTING_KEY}"}
+    chat: {type: slack, url: "${SLACK_WEBHOOK_URL}"}
+  routes:
+    - {tags: [payments], severity: [error], receiver: oncall}
+    - {severity: [info]}
+    - {receiver: chat}
+
+Each transition goes to the receiver of the first route it matches; a route without a
+receiver drops it. The domains of the file are watched too.
+
+With --issues, every finding that lint would report for a domain, or a failing check,
+that persists for --issue-after consecutive checks gets an issue in a GitHub repository
+(github:owner/repo) or a Jira project (jira:https://example.atlassian.net/KEY). The issue
+is commented on when the finding changes and closed once it is no longer reported. Issues
+are found again by the finding's fingerprint, so a restarted watch does not open
+duplicates. Credentials are read from GITHUB_TOKEN, or JIRA_EMAIL and JIRA_API_TOKEN,
+or the same keys in the config file.
+
+With --store sqlite:path, every result of every check is saved to a SQLite database,
+as one scan that lasts as long as the watch.
+
+After --breaker-threshold consecutive failures, a domain is not checked again until
+--breaker-cooldown has passed and is reported as "circuit open".
+
+Responses are always fetched live, bypassing the response cache. The command runs until
+it is interrupted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var router *alert.Router
+		if alertsFile != "" {
+			if webhookURL != "" {
+				fmt.Fprintf(os.Stderr, "Error: --webhook cannot be combined with --alerts; declare a webhook receiver instead\n")
+				os.Exit(1)
+			}
+			config, err := alert.Load(alertsFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			router = alert.NewRouter(config, newClient(), []byte(viper.GetString("webhook_secret")))
+		}
+
+		// Collect the domains from arguments, the domains file and the alerts file
+		domains := args
+		if watchFile != "" {
+			lines, err := readLines(watchFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			domains = append(domains, lines...)
+		}
+		if router != nil {
+			for _, domain := range router.Domains() {
+				if !slices.Contains(domains, domain) {
+					domains = append(domains, domain)
+				}
+			}
+		}
+		if len(domains) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: at least one domain is required (as an argument or with --domains-file)\n")
+			os.Exit(1)
+		}
+
+		schedule, err := watch.ParseSchedule(interval)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// A monitor must see changes as soon as they are served, so skip the cache
+		fetch := fetchOptions()
+		fetch.Transport = newTransport()
+
+		notify := func(t watch.Transition) error {
+			fmt.Println(t)
+			return nil
+		}
+		if webhookURL != "" {
+			// Sign notifications when a shared secret is configured
+			secret := []byte(viper.GetString("webhook_secret"))
+			webhook := watch.WebhookNotifier(newClient(), webhookURL, secret)
+			notify = func(t watch.Transition) error {
+				fmt.Println(t)
+				return webhook(t)
+			}
+		}
+		if router != nil {
+			notify = func(t watch.Transition) error {
+				fmt.Println(t)
+				return router.Notify(t)
+			}
+		}
+
+		// Open issues for persistent findings
+		var syncer *issues.Syncer
+		if issueTracker != "" {
+			tracker, err := issues.NewTracker(newClient(), issueTracker, issues.Credentials{
+				GitHubToken: viper.GetString("github_token"),
+				JiraEmail:   viper.GetString("jira_email"),
+				JiraToken:   viper.GetString("jira_api_token"),
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			syncer = issues.NewSyncer(tracker, issueAfter)
+		}
+
+		// Record the whole watch as one scan in the results database
+		db, scanID := openStore("watch")
+		if db != nil {
+			defer db.Close()
+			defer db.FinishScan(scanID, time.Now())
+		}
+		// Roll up old results at most once an hour, so the database does not grow without bound
+		var lastPrune time.Time
+		prune := func() {
+			if db == nil || keepDays < 1 || time.Since(lastPrune) < time.Hour {
+				return
+			}
+			lastPrune = time.Now()
+			result, err := db.Prune(store.RetentionCutoff(lastPrune, keepDays))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return
+			}
+			if debug && result.Results > 0 {
+				fmt.Printf("Debug: Rolled up %d results into %d daily summaries\n", result.Results, result.Days)
+			}
+		}
+		prune()
+
+		// Stop cleanly on interrupt
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		watcher := watch.New(watch.Options{
+			Schedule: schedule,
+			Batch: batch.Options{
+				Concurrency:  concurrency,
+				Origin:       origin,
+				ModelVersion: modelVersion(),
+				Fetch:        fetch,
+				Budget:       budget(),
+				Breaker:      breaker.New(breakerThreshold, breakerCooldown),
+			},
+			OnRecord: func(record batch.Record) {
+				if debug {
+					fmt.Printf("Debug: %s %s\n", record.Timestamp.Format(time.RFC3339), batch.FormatRecord(record))
+				}
+				if db != nil {
+					if err := db.Save(scanID, record); err != nil {
+						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					}
+					prune()
+				}
+				if syncer != nil {
+					if err := syncer.Observe(ctx, record); err != nil {
+						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					}
+				}
+			},
+			Notify: notify,
+			OnError: func(err error) {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			},
+		})
+
+		fmt.Printf("Watching %d domains (%v)\n", len(domains), schedule)
+		if err := watcher.Run(ctx, domains); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	// Local flags
+	watchCmd.Flags().StringVar(&interval, "interval", "5m", "Check schedule, as a duration (5m, @every 1h) or a cron expression (*/15 * * * *)")
+	watchCmd.Flags().StringVar(&watchFile, "domains-file", "", "File containing one domain per line to watch (\"-\" for stdin)")
+	watchCmd.Flags().StringVar(&origin, "origin", "", "Caller origin to validate against every domain")
+	watchCmd.Flags().IntVar(&concurrency, "concurrency", 4, "Number of domains to check in parallel")
+	watchCmd.Flags().IntVar(&breakerThreshold, "breaker-threshold", breaker.DefaultThreshold, "Consecutive failures that stop checking a domain (0 to disable)")
+	watchCmd.Flags().DurationVar(&breakerCooldown, "breaker-cooldown", breaker.DefaultCooldown, "How long a failing domain is not checked")
+	watchCmd.Flags().StringVar(&webhookURL, "webhook", "", "POST every transition as JSON to this URL")
+	watchCmd.Flags().StringVar(&alertsFile, "alerts", "", "Route transitions to receivers by domain tag and severity, as declared in this file")
+	watchCmd.Flags().StringVar(&storeSpec, "store", "", "Save every result to this database (sqlite:path)")
+	watchCmd.Flags().IntVar(&keepDays, "keep-days", 0, "Roll up results older than this many days in the --store database into daily summaries (0 keeps all)")
+	watchCmd.Flags().StringVar(&issueTracker, "issues", "", "Open issues for persistent findings in github:owner/repo or jira:URL/PROJECT")
+	watchCmd.Flags().IntVar(&issueAfter, "issue-after", issues.DefaultPersist, "Consecutive checks a finding must persist for before an issue is opened")
+}
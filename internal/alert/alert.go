@@ -0,0 +1,300 @@
+// Package alert routes the transitions reported by watch to receivers chosen by the
+// tags of the domain and the severity of the transition, so that one monitor can serve
+// several teams with different escalation needs.
+//
+// An alerts file tags domains, declares receivers and lists routes:
+//
+//	domains:
+//	  - domain: pay.example.com
+//	    tags: [payments]
+//	receivers:
+//	  oncall:
+//	    type: pagerduty
+//	    routing_key: ${PAGERDUTY_ROUTING_KEY}
+//	  chat:
+//	    type: slack
+//	    url: ${SLACK_WEBHOOK_URL}
+//	routes:
+//	  - tags: [payments]
+//	    severity: [error]
+//	    receiver: oncall
+//	  - severity: [info]
+//	  - receiver: chat
+//
+// Each transition goes to the receiver of the first route that matches it. A route
+// matches when the domain has any of its tags, and the transition has any of its
+// severities and kinds; an empty list matches everything. A route without a receiver
+// drops the transitions it matches, and transitions that match no route are dropped.
+// URLs and routing keys may refer to environment variables as ${NAME}, so that secrets
+// stay out of the file. Alerts files may be written in YAML or JSON.
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/watch"
+	"gopkg.in/yaml.v3"
+)
+
+// Receiver types.
+const (
+	TypeWebhook   = "webhook"
+	TypeSlack     = "slack"
+	TypePagerDuty = "pagerduty"
+)
+
+// PagerDutyURL is the PagerDuty Events API v2 endpoint alerts are sent to by default.
+const PagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// Config is the contents of an alerts file.
+type Config struct {
+	Domains   []Domain            `yaml:"domains"`
+	Receivers map[string]Receiver `yaml:"receivers"`
+	Routes    []Route             `yaml:"routes"`
+}
+
+// Domain tags one watched domain.
+type Domain struct {
+	Domain string   `yaml:"domain"`
+	Tags   []string `yaml:"tags"`
+}
+
+// Receiver is a destination for alerts.
+type Receiver struct {
+	// Type is TypeWebhook, TypeSlack or TypePagerDuty.
+	Type string `yaml:"type"`
+	// URL is the webhook or Slack incoming webhook URL. For PagerDuty, it overrides
+	// PagerDutyURL.
+	URL string `yaml:"url"`
+	// RoutingKey is the integration key of a PagerDuty service.
+	RoutingKey string `yaml:"routing_key"`
+}
+
+// Route sends the transitions it matches to a receiver.
+type Route struct {
+	Tags     []string `yaml:"tags"`
+	Severity []string `yaml:"severity"`
+	Kinds    []string `yaml:"kinds"`
+	// Receiver is the name of the receiver, or empty to drop the transitions.
+	Receiver string `yaml:"receiver"`
+}
+
+// Load reads an alerts file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alerts: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses an alerts file written in YAML or JSON.
+func Parse(data []byte) (*Config, error) {
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse alerts: %w", err)
+	}
+	for i, d := range c.Domains {
+		if d.Domain == "" {
+			return nil, fmt.Errorf("failed to parse alerts: domains[%d] has no domain", i)
+		}
+	}
+	for name, r := range c.Receivers {
+		switch r.Type {
+		case TypeWebhook, TypeSlack:
+			if r.URL == "" {
+				return nil, fmt.Errorf("failed to parse alerts: receiver %q has no url", name)
+			}
+		case TypePagerDuty:
+			if r.RoutingKey == "" {
+				return nil, fmt.Errorf("failed to parse alerts: receiver %q has no routing_key", name)
+			}
+		default:
+			return nil, fmt.Errorf("failed to parse alerts: receiver %q has unknown type %q: expected webhook, slack or pagerduty", name, r.Type)
+		}
+	}
+	for i, route := range c.Routes {
+		if _, ok := c.Receivers[route.Receiver]; route.Receiver != "" && !ok {
+			return nil, fmt.Errorf("failed to parse alerts: routes[%d] has unknown receiver %q", i, route.Receiver)
+		}
+		for _, name := range route.Severity {
+			if _, err := watch.ParseSeverity(name); err != nil {
+				return nil, fmt.Errorf("failed to parse alerts: routes[%d]: %w", i, err)
+			}
+		}
+		for _, name := range route.Kinds {
+			if !validKind(name) {
+				return nil, fmt.Errorf("failed to parse alerts: routes[%d] has unknown kind %q", i, name)
+			}
+		}
+	}
+	return &c, nil
+}
+
+// validKind reports whether name is the name of a TransitionKind.
+func validKind(name string) bool {
+	for k := watch.TransitionOriginsAdded; k <= watch.TransitionCircuitOpened; k++ {
+		if k.String() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// domainKey returns the form domains are matched in.
+func domainKey(domain string) string {
+	return strings.ToLower(strings.TrimSpace(domain))
+}
+
+// Router sends transitions to the receivers of the routes they match.
+type Router struct {
+	config    *Config
+	tags      map[string][]string
+	receivers map[string]watch.Notifier
+}
+
+// NewRouter returns a Router for config that sends requests with client. Webhook
+// requests are signed with secret, if it is not empty.
+func NewRouter(config *Config, client *http.Client, secret []byte) *Router {
+	r := &Router{config: config, tags: make(map[string][]string), receivers: make(map[string]watch.Notifier)}
+	for _, d := range config.Domains {
+		r.tags[domainKey(d.Domain)] = d.Tags
+	}
+	for name, receiver := range config.Receivers {
+		url := os.ExpandEnv(receiver.URL)
+		switch receiver.Type {
+		case TypeWebhook:
+			r.receivers[name] = watch.WebhookNotifier(client, url, secret)
+		case TypeSlack:
+			r.receivers[name] = slackNotifier(client, url)
+		case TypePagerDuty:
+			if url == "" {
+				url = PagerDutyURL
+			}
+			r.receivers[name] = pagerDutyNotifier(client, url, os.ExpandEnv(receiver.RoutingKey))
+		}
+	}
+	return r
+}
+
+// Domains returns the domains the alerts file tags, in the order they are listed.
+func (r *Router) Domains() []string {
+	domains := make([]string, 0, len(r.config.Domains))
+	for _, d := range r.config.Domains {
+		domains = append(domains, d.Domain)
+	}
+	return domains
+}
+
+// Route returns the name of the receiver of t, or an empty string if t is dropped.
+func (r *Router) Route(t watch.Transition) string {
+	tags := r.tags[domainKey(t.Domain)]
+	for _, route := range r.config.Routes {
+		if matches(route.Tags, tags) && matches(route.Severity, []string{t.Severity.String()}) &&
+			matches(route.Kinds, []string{t.Kind.String()}) {
+			return route.Receiver
+		}
+	}
+	return ""
+}
+
+// matches reports whether want is empty or shares a value with have.
+func matches(want, have []string) bool {
+	if len(want) == 0 {
+		return true
+	}
+	for _, v := range have {
+		if slices.Contains(want, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify sends t to the receiver of the first route it matches. It is a watch.Notifier.
+func (r *Router) Notify(t watch.Transition) error {
+	name := r.Route(t)
+	if name == "" {
+		return nil
+	}
+	if err := r.receivers[name](t); err != nil {
+		return fmt.Errorf("receiver %s: %w", name, err)
+	}
+	return nil
+}
+
+// slackNotifier returns a Notifier that posts each transition as a message to a Slack
+// incoming webhook.
+func slackNotifier(client *http.Client, url string) watch.Notifier {
+	return func(t watch.Transition) error {
+		text := fmt.Sprintf("[%s] %s: %s", strings.ToUpper(t.Severity.String()), t.Domain, t.Message)
+		return post(client, url, map[string]string{"text": text})
+	}
+}
+
+// pagerDutyNotifier returns a Notifier that sends each transition as an event to the
+// PagerDuty Events API v2. Transitions to a domain's problems are deduplicated into one
+// incident per domain and problem, which the transition back resolves.
+func pagerDutyNotifier(client *http.Client, url, routingKey string) watch.Notifier {
+	return func(t watch.Transition) error {
+		action := "trigger"
+		switch {
+		case t.Kind == watch.TransitionRecovered, t.Kind == watch.TransitionLimitRestored,
+			t.Kind == watch.TransitionStatusChanged && !t.Current.Invalid():
+			action = "resolve"
+		}
+		event := map[string]any{
+			"routing_key":  routingKey,
+			"event_action": action,
+			"dedup_key":    "passkey-origin-validator/" + t.Domain + "/" + problem(t.Kind),
+			"payload": map[string]any{
+				"summary":        t.Domain + ": " + t.Message,
+				"source":         t.Domain,
+				"severity":       t.Severity.String(),
+				"timestamp":      t.Time,
+				"component":      "passkey-origin-validator",
+				"class":          t.Kind.String(),
+				"custom_details": t,
+			},
+		}
+		return post(client, url, event)
+	}
+}
+
+// problem returns the problem a transition of kind starts or ends, so that the two are
+// deduplicated together.
+func problem(kind watch.TransitionKind) string {
+	switch kind {
+	case watch.TransitionFailed, watch.TransitionRecovered, watch.TransitionCircuitOpened:
+		return "availability"
+	case watch.TransitionLimitExceeded, watch.TransitionLimitRestored:
+		return "limit"
+	case watch.TransitionStatusChanged:
+		return "status"
+	default:
+		return "origins"
+	}
+}
+
+// post POSTs v as JSON to url. A non-2xx response is reported as an error.
+func post(client *http.Client, url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("alert returned status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+	"github.com/developmeh/passkey-origin-validator/internal/watch"
+)
+
+// TestParse tests validating alerts files.
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"Unknown type", `{"receivers": {"a": {"type": "email"}}}`, "unknown type"},
+		{"Missing url", `{"receivers": {"a": {"type": "slack"}}}`, "has no url"},
+		{"Missing routing key", `{"receivers": {"a": {"type": "pagerduty"}}}`, "has no routing_key"},
+		{"Unknown receiver", `{"routes": [{"receiver": "a"}]}`, "unknown receiver"},
+		{"Unknown severity", `{"routes": [{"severity": ["critical"]}]}`, "unknown severity"},
+		{"Unknown kind", `{"routes": [{"kinds": ["changed"]}]}`, "unknown kind"},
+		{"Missing domain", `{"domains": [{"tags": ["a"]}]}`, "has no domain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// TestRouter tests routing transitions to Slack and PagerDuty receivers.
+func TestRouter(t *testing.T) {
+	var slack []string
+	var pagerDuty []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/slack":
+			slack = append(slack, body["text"].(string))
+		case "/pagerduty":
+			pagerDuty = append(pagerDuty, body)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("TEST_ROUTING_KEY", "key")
+
+	config, err := Parse([]byte(`
+domains:
+  - domain: Pay.example.com
+    tags: [payments]
+receivers:
+  oncall:
+    type: pagerduty
+    url: ` + server.URL + `/pagerduty
+    routing_key: ${TEST_ROUTING_KEY}
+  chat:
+    type: slack
+    url: ` + server.URL + `/slack
+routes:
+  - tags: [payments]
+    severity: [error]
+    receiver: oncall
+  - tags: [payments]
+    kinds: [recovered]
+    receiver: oncall
+  - severity: [info]
+  - receiver: chat
+`))
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+	router := NewRouter(config, server.Client(), nil)
+	if domains := router.Domains(); len(domains) != 1 || domains[0] != "Pay.example.com" {
+		t.Errorf("Unexpected domains %v", domains)
+	}
+
+	for _, transition := range []watch.Transition{
+		{Domain: "pay.example.com", Kind: watch.TransitionFailed, Severity: watch.SeverityError, Message: "check failed: timeout"},
+		{Domain: "pay.example.com", Kind: watch.TransitionRecovered, Severity: watch.SeverityInfo, Message: "check recovered"},
+		{Domain: "www.example.com", Kind: watch.TransitionFailed, Severity: watch.SeverityError, Message: "check failed: timeout"},
+		{Domain: "www.example.com", Kind: watch.TransitionLimitRestored, Severity: watch.SeverityInfo, Message: "label count fell"},
+		{Domain: "pay.example.com", Kind: watch.TransitionOriginsAdded, Severity: watch.SeverityWarning, Message: "origins added: https://a.com",
+			Current: batch.Record{Origins: []string{"https://a.com"}}},
+	} {
+		if err := router.Notify(transition); err != nil {
+			t.Errorf("Notify returned an error: %v", err)
+		}
+	}
+
+	if len(pagerDuty) != 2 {
+		t.Fatalf("Expected 2 PagerDuty events, got %v", pagerDuty)
+	}
+	if pagerDuty[0]["routing_key"] != "key" || pagerDuty[0]["event_action"] != "trigger" ||
+		pagerDuty[0]["dedup_key"] != "passkey-origin-validator/pay.example.com/availability" {
+		t.Errorf("Unexpected trigger event %v", pagerDuty[0])
+	}
+	if pagerDuty[1]["event_action"] != "resolve" || pagerDuty[1]["dedup_key"] != pagerDuty[0]["dedup_key"] {
+		t.Errorf("Expected the recovery to resolve the incident, got %v", pagerDuty[1])
+	}
+	expected := []string{
+		"[ERROR] www.example.com: check failed: timeout",
+		"[WARNING] pay.example.com: origins added: https://a.com",
+	}
+	if strings.Join(slack, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected Slack messages %q, got %q", expected, slack)
+	}
+}
@@ -0,0 +1,272 @@
+// Package appleapp cross-checks the apps a relying party shares passkeys with against
+// the related origins of its .well-known/webauthn document.
+//
+// An iOS or macOS app can use the passkeys of an RP ID when the app lists the domain in
+// its associated domains entitlement, as webcredentials:example.com, and the domain
+// lists the app in the webcredentials section of its apple-app-site-association file:
+//
+//	{"webcredentials": {"apps": ["ABCDE12345.com.example.app"]}}
+//
+// A team that ships an app and websites on several domains usually wants the websites
+// to use the same passkeys as the app, which takes both files: the app associated with
+// each domain, and each domain's origin listed as a related origin of the RP ID.
+package appleapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+	"github.com/developmeh/passkey-origin-validator/internal/rpid"
+)
+
+// Path is the path the apple-app-site-association file is served at.
+const Path = "/.well-known/apple-app-site-association"
+
+// MaxSize is the largest apple-app-site-association file Apple devices read, in bytes.
+const MaxSize = 128 * 1024
+
+// appIDPattern matches an app ID: a 10-character team ID and a bundle ID.
+var appIDPattern = regexp.MustCompile(`^[A-Z0-9]{10}\.[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*$`)
+
+// Association is the apple-app-site-association file of a domain.
+type Association struct {
+	// Domain is the domain the file was fetched for.
+	Domain string
+	// URL is where the file was fetched from.
+	URL string
+	// Found is false if the domain serves no file, which associates no apps.
+	Found bool
+	// Apps are the app IDs of the webcredentials section, as written.
+	Apps []string
+}
+
+// Origins returns the origins a .well-known/webauthn document can list for a: the origin
+// of its domain, if it associates apps for web credentials, since the website of an
+// associated domain uses the passkeys the apps create.
+func (a *Association) Origins() []string {
+	for _, app := range a.Apps {
+		if ValidAppID(app) == nil {
+			return []string{"https://" + a.Domain}
+		}
+	}
+	return nil
+}
+
+// FetchFunc returns the apple-app-site-association file of domain.
+type FetchFunc func(ctx context.Context, domain string) (*Association, error)
+
+// Parse returns the app IDs of the webcredentials section of an apple-app-site-association
+// file, which has none if the section is missing.
+func Parse(data []byte) ([]string, error) {
+	var file struct {
+		WebCredentials *struct {
+			Apps []string `json:"apps"`
+		} `json:"webcredentials"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse apple-app-site-association: %w", err)
+	}
+	if file.WebCredentials == nil {
+		return nil, nil
+	}
+	return file.WebCredentials.Apps, nil
+}
+
+// ValidAppID returns an error if app is not an app ID: a team ID of 10 uppercase letters
+// and digits, a period and a bundle ID, such as ABCDE12345.com.example.app.
+func ValidAppID(app string) error {
+	if !appIDPattern.MatchString(app) {
+		return fmt.Errorf("%q is not an app ID: it must be a 10-character team ID, a period and a bundle ID, such as ABCDE12345.com.example.app", app)
+	}
+	return nil
+}
+
+// Fetch fetches the apple-app-site-association file of domain with the timeout and
+// transport of opts. Like Apple devices, it does not follow redirects, and reads at most
+// MaxSize bytes.
+func Fetch(ctx context.Context, domain string, opts counter.Options) (*Association, error) {
+	fileURL := "https://" + domain + Path
+	client := &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: opts.Transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid domain %q: %w", domain, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", fileURL, err)
+	}
+	defer resp.Body.Close()
+
+	association := &Association{Domain: domain, URL: fileURL}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return association, nil
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		return nil, fmt.Errorf("%s redirects to %s, which Apple devices do not follow", fileURL, resp.Header.Get("Location"))
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s returned status code %d", fileURL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", fileURL, err)
+	}
+	if len(body) > MaxSize {
+		return nil, fmt.Errorf("%s is larger than the %d bytes Apple devices read", fileURL, MaxSize)
+	}
+	if association.Apps, err = Parse(body); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileURL, err)
+	}
+	association.Found = true
+	return association, nil
+}
+
+// Options configures a cross-check.
+type Options struct {
+	// RPID is the RP ID the document is served for, whose apps the others are compared with.
+	RPID string
+	// Source is where the document came from. It is part of each finding's fingerprint.
+	Source string
+	// AppDomains are the other domains the RP's apps are associated with, from their
+	// associated domains entitlement. The website of each one that shares an app with the
+	// RP ID needs its origin in the document.
+	AppDomains []string
+	// Fetch fetches the apple-app-site-association file of a domain.
+	Fetch FetchFunc
+}
+
+// Check compares the webcredentials apps of the RP ID, the hosts of the https origins of
+// document and opts.AppDomains. It reports an origin whose host associates apps that the
+// RP ID does not as lint.RuleAppRPMismatch, and an app domain that shares apps with the
+// RP ID but has no origin in the document as lint.RuleAppOriginMissing. Files that cannot
+// be fetched or parsed, and invalid app IDs, are reported as lint.RuleAppSiteAssociation.
+func Check(ctx context.Context, document []byte, opts Options) []lint.Finding {
+	var findings []lint.Finding
+	report := func(rule string, index int, origin, key, message string) *lint.Finding {
+		findings = append(findings, lint.Finding{
+			Rule:        rule,
+			Severity:    lint.SeverityWarning,
+			Index:       index,
+			Origin:      origin,
+			Message:     message,
+			Fingerprint: lint.Fingerprint(rule, opts.Source, key),
+		})
+		return &findings[len(findings)-1]
+	}
+	// apps fetches the file of domain and returns its valid app IDs, reporting anything
+	// wrong with it; ok is false if it could not be read. The findings about a file are
+	// fingerprinted by its domain and app ID, which are not origins to normalize
+	apps := func(domain string) (map[string]bool, bool) {
+		association, err := opts.Fetch(ctx, domain)
+		if err != nil {
+			report(lint.RuleAppSiteAssociation, -1, "", "apple-app-site-association "+domain, err.Error())
+			return nil, false
+		}
+		valid := make(map[string]bool)
+		for _, app := range association.Apps {
+			if err := ValidAppID(app); err != nil {
+				report(lint.RuleAppSiteAssociation, -1, "", "apple-app-site-association "+domain+" "+app, fmt.Sprintf("%s: %v", association.URL, err))
+				continue
+			}
+			valid[app] = true
+		}
+		return valid, true
+	}
+
+	rpApps, ok := apps(opts.RPID)
+	if !ok {
+		return findings
+	}
+
+	// The first https origin of each host, in the order of the document. A document that
+	// is not valid JSON is reported by lint.Check, and has no hosts to compare here
+	var doc struct {
+		Origins []string `json:"origins"`
+	}
+	_ = json.Unmarshal(document, &doc)
+	var hosts []string
+	indexes := make(map[string]int)
+	for i, origin := range doc.Origins {
+		originURL, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(originURL.Scheme, "https") || originURL.Hostname() == "" {
+			continue
+		}
+		host := strings.ToLower(originURL.Hostname())
+		if _, seen := indexes[host]; !seen {
+			indexes[host] = i
+			hosts = append(hosts, host)
+		}
+	}
+
+	for _, host := range hosts {
+		if host == strings.ToLower(opts.RPID) {
+			continue
+		}
+		hostApps, ok := apps(host)
+		if !ok {
+			continue
+		}
+		if extra := difference(hostApps, rpApps); len(extra) > 0 {
+			origin := doc.Origins[indexes[host]]
+			report(lint.RuleAppRPMismatch, indexes[host], origin, origin,
+				fmt.Sprintf("%s associates %s for web credentials, but RP ID %s does not; the apps cannot use the passkeys of RP ID %s that %s uses as a related origin", host, strings.Join(extra, ", "), opts.RPID, opts.RPID, origin))
+		}
+	}
+
+	for _, domain := range opts.AppDomains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "https://"))
+		origin := "https://" + domain
+		if _, listed := indexes[domain]; listed || rpid.Check(origin, opts.RPID).Status == rpid.Valid {
+			continue
+		}
+		domainApps, ok := apps(domain)
+		if !ok {
+			continue
+		}
+		if shared := intersection(domainApps, rpApps); len(shared) > 0 {
+			finding := report(lint.RuleAppOriginMissing, -1, origin, origin,
+				fmt.Sprintf("%s shares %s with RP ID %s for web credentials, but %s is not listed, so its website cannot use the passkeys the apps create", domain, strings.Join(shared, ", "), opts.RPID, origin))
+			finding.Remediation = &lint.Remediation{Action: lint.ActionAddOrigin, Target: opts.Source, Value: origin}
+		}
+	}
+	return findings
+}
+
+// difference returns the apps of a that are not in b, sorted.
+func difference(a, b map[string]bool) []string {
+	var apps []string
+	for app := range a {
+		if !b[app] {
+			apps = append(apps, app)
+		}
+	}
+	sort.Strings(apps)
+	return apps
+}
+
+// intersection returns the apps of a that are also in b, sorted.
+func intersection(a, b map[string]bool) []string {
+	var apps []string
+	for app := range a {
+		if b[app] {
+			apps = append(apps, app)
+		}
+	}
+	sort.Strings(apps)
+	return apps
+}
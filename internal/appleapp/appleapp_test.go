@@ -0,0 +1,184 @@
+package appleapp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+)
+
+// TestParse tests reading the webcredentials apps of a file.
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{"apps", `{"webcredentials": {"apps": ["ABCDE12345.com.example.app"]}, "applinks": {}}`, []string{"ABCDE12345.com.example.app"}, false},
+		{"no webcredentials", `{"applinks": {"details": []}}`, nil, false},
+		{"invalid JSON", `not json`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidAppID tests the format of app IDs.
+func TestValidAppID(t *testing.T) {
+	tests := []struct {
+		app  string
+		want bool
+	}{
+		{"ABCDE12345.com.example.app", true},
+		{"ABCDE12345.app-name", true},
+		{"abcde12345.com.example.app", false},
+		{"ABCDE1234.com.example.app", false},
+		{"com.example.app", false},
+		{"ABCDE12345.", false},
+	}
+	for _, tt := range tests {
+		if got := ValidAppID(tt.app) == nil; got != tt.want {
+			t.Errorf("ValidAppID(%q) valid = %v, want %v", tt.app, got, tt.want)
+		}
+	}
+}
+
+// TestFetch tests fetching a file, without following redirects.
+func TestFetch(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != Path {
+			http.NotFound(w, r)
+			return
+		}
+		if status == http.StatusFound {
+			http.Redirect(w, r, "https://www.example.com"+Path, status)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"webcredentials": {"apps": ["ABCDE12345.com.example.app"]}}`))
+	}))
+	defer server.Close()
+	domain := strings.TrimPrefix(server.URL, "https://")
+	opts := counter.DefaultOptions()
+	opts.Transport = server.Client().Transport
+
+	association, err := Fetch(context.Background(), domain, opts)
+	if err != nil {
+		t.Fatalf("Fetch returned an error: %v", err)
+	}
+	if !association.Found || !reflect.DeepEqual(association.Apps, []string{"ABCDE12345.com.example.app"}) {
+		t.Errorf("Fetch() = %+v, want the file's app", association)
+	}
+
+	status = http.StatusNotFound
+	if association, err = Fetch(context.Background(), domain, opts); err != nil || association.Found {
+		t.Errorf("Fetch() of a missing file = %+v, %v; want not found", association, err)
+	}
+
+	status = http.StatusFound
+	if _, err = Fetch(context.Background(), domain, opts); err == nil || !strings.Contains(err.Error(), "do not follow") {
+		t.Errorf("Fetch() of a redirect error = %v, want one about redirects", err)
+	}
+}
+
+// TestCheck tests cross-checking the apps of the RP ID, the origins and the app domains.
+func TestCheck(t *testing.T) {
+	files := map[string][]string{
+		"example.com":       {"ABCDE12345.com.example.app"},
+		"example.co.uk":     {"ABCDE12345.com.example.app"},
+		"example.de":        {"ABCDE12345.com.example.app", "ABCDE12345.com.example.de"},
+		"example.fr":        {"ABCDE12345.com.example.app"},
+		"example.nl":        {"FGHIJ67890.com.other.app"},
+		"login.example.com": {"ABCDE12345.com.example.app"},
+		"bad.example":       {"com.example.app"},
+	}
+	fetch := func(ctx context.Context, domain string) (*Association, error) {
+		if domain == "down.example" {
+			return nil, errors.New("connection refused")
+		}
+		apps, ok := files[domain]
+		return &Association{Domain: domain, URL: "https://" + domain + Path, Found: ok, Apps: apps}, nil
+	}
+	document := []byte(`{"origins": ["https://example.co.uk", "https://example.de", "https://bad.example", "http://example.nl", "https://none.example"]}`)
+
+	findings := Check(context.Background(), document, Options{
+		RPID:       "example.com",
+		Source:     "https://example.com/.well-known/webauthn",
+		AppDomains: []string{"example.fr", "example.nl", "login.example.com", "example.co.uk", "down.example"},
+		Fetch:      fetch,
+	})
+
+	type finding struct {
+		Rule   string
+		Index  int
+		Origin string
+	}
+	var got []finding
+	for _, f := range findings {
+		got = append(got, finding{f.Rule, f.Index, f.Origin})
+		if f.Severity != lint.SeverityWarning {
+			t.Errorf("finding %s has severity %s, want warning", f.Rule, f.Severity)
+		}
+	}
+	want := []finding{
+		{lint.RuleAppRPMismatch, 1, "https://example.de"},
+		{lint.RuleAppSiteAssociation, -1, ""},
+		{lint.RuleAppOriginMissing, -1, "https://example.fr"},
+		{lint.RuleAppSiteAssociation, -1, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Check() = %+v, want %+v", got, want)
+	}
+	if !strings.Contains(findings[0].Message, "ABCDE12345.com.example.de") {
+		t.Errorf("mismatch message %q does not name the extra app", findings[0].Message)
+	}
+	if r := findings[2].Remediation; r == nil || r.Action != lint.ActionAddOrigin || r.Value != "https://example.fr" {
+		t.Errorf("missing origin remediation = %+v, want to add https://example.fr", r)
+	}
+	if findings[1].Fingerprint == findings[3].Fingerprint {
+		t.Errorf("findings about different files share fingerprint %s", findings[1].Fingerprint)
+	}
+
+	// Nothing is compared when the RP ID's file cannot be read
+	findings = Check(context.Background(), document, Options{RPID: "down.example", Fetch: fetch})
+	if len(findings) != 1 || findings[0].Rule != lint.RuleAppSiteAssociation {
+		t.Errorf("Check() with an unreadable RP ID file = %+v, want one app-site-association finding", findings)
+	}
+}
+
+// TestAssociationOrigins tests the origins imported from a file.
+func TestAssociationOrigins(t *testing.T) {
+	tests := []struct {
+		name string
+		apps []string
+		want []string
+	}{
+		{"apps", []string{"ABCDE12345.com.example.app"}, []string{"https://example.co.uk"}},
+		{"only invalid apps", []string{"com.example.app"}, nil},
+		{"no apps", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			association := &Association{Domain: "example.co.uk", Found: true, Apps: tt.apps}
+			if got := association.Origins(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Origins() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
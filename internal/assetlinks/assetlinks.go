@@ -0,0 +1,415 @@
+// Package assetlinks cross-checks the Android apps and websites a relying party shares
+// credentials with, as declared in its Digital Asset Links file, against the related
+// origins of its .well-known/webauthn document.
+//
+// An Android app can use the passkeys of an RP ID when the domain's assetlinks.json
+// grants the app the delegate_permission/common.get_login_creds relation, naming its
+// package and the SHA-256 fingerprints of its signing certificates:
+//
+//	[{
+//	  "relation": ["delegate_permission/common.get_login_creds"],
+//	  "target": {"namespace": "android_app", "package_name": "com.example.app",
+//	             "sha256_cert_fingerprints": ["AB:CD:..."]}
+//	}]
+//
+// The same relation with a web target, {"namespace": "web", "site": "https://example.de"},
+// declares that the RP shares credentials with another website, which needs its origin
+// listed as a related origin to use the RP ID's passkeys.
+package assetlinks
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+	"github.com/developmeh/passkey-origin-validator/internal/rpid"
+)
+
+// Path is the path the Digital Asset Links file is served at.
+const Path = "/.well-known/assetlinks.json"
+
+// RelationLoginCreds is the relation that shares a domain's credentials with a target.
+const RelationLoginCreds = "delegate_permission/common.get_login_creds"
+
+// packagePattern matches an Android package name.
+var packagePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\.[A-Za-z][A-Za-z0-9_]*)+$`)
+
+// App is an Android app a domain shares credentials with.
+type App struct {
+	// Package is the app's package name, such as com.example.app.
+	Package string `json:"package_name"`
+	// Fingerprints are the SHA-256 fingerprints of the app's signing certificates, as
+	// written in the file.
+	Fingerprints []string `json:"sha256_cert_fingerprints"`
+}
+
+// Links are the statements of a domain's assetlinks.json that share its credentials.
+type Links struct {
+	// Domain is the domain the file was fetched for.
+	Domain string
+	// URL is where the file was fetched from.
+	URL string
+	// Found is false if the domain serves no file, which shares no credentials.
+	Found bool
+	// Apps are the Android apps the domain shares credentials with.
+	Apps []App
+	// Sites are the websites the domain shares credentials with, as written.
+	Sites []string
+}
+
+// FetchFunc returns the Digital Asset Links of domain.
+type FetchFunc func(ctx context.Context, domain string) (*Links, error)
+
+// statement is a statement of an assetlinks.json file.
+type statement struct {
+	Relation []string `json:"relation"`
+	Target   struct {
+		Namespace    string   `json:"namespace"`
+		PackageName  string   `json:"package_name"`
+		Fingerprints []string `json:"sha256_cert_fingerprints"`
+		Site         string   `json:"site"`
+	} `json:"target"`
+}
+
+// Parse returns the apps and sites an assetlinks.json file shares credentials with, from
+// its statements with the RelationLoginCreds relation. Statements that include other
+// files are not followed.
+func Parse(data []byte) (apps []App, sites []string, err error) {
+	var statements []statement
+	if err := json.Unmarshal(data, &statements); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse assetlinks.json: %w", err)
+	}
+	for _, s := range statements {
+		shares := false
+		for _, relation := range s.Relation {
+			shares = shares || relation == RelationLoginCreds
+		}
+		if !shares {
+			continue
+		}
+		switch s.Target.Namespace {
+		case "android_app":
+			apps = append(apps, App{Package: s.Target.PackageName, Fingerprints: s.Target.Fingerprints})
+		case "web":
+			sites = append(sites, s.Target.Site)
+		}
+	}
+	return apps, sites, nil
+}
+
+// Origins returns the web origins a .well-known/webauthn document can list for l: the
+// origin of its domain, if it shares credentials with an Android app, since its website
+// uses the passkeys the app creates, then the site of each web target, in the order of
+// the file.
+func (l *Links) Origins() []string {
+	var origins []string
+	for _, app := range l.Apps {
+		if ValidPackage(app.Package) == nil {
+			origins = append(origins, "https://"+l.Domain)
+			break
+		}
+	}
+	return append(origins, l.Sites...)
+}
+
+// AndroidOrigins returns the Android app origin of each valid certificate fingerprint of
+// the apps l shares credentials with, in the order of the file.
+func (l *Links) AndroidOrigins() []string {
+	var origins []string
+	for _, app := range l.Apps {
+		if ValidPackage(app.Package) != nil {
+			continue
+		}
+		for _, fingerprint := range app.Fingerprints {
+			if origin, err := counter.AndroidOrigin(fingerprint); err == nil {
+				origins = append(origins, origin)
+			}
+		}
+	}
+	return origins
+}
+
+// NormalizeFingerprint returns a SHA-256 certificate fingerprint in the form assetlinks.json
+// files use, uppercase hex pairs separated by colons, or an error if it is not 32 bytes of hex.
+func NormalizeFingerprint(fingerprint string) (string, error) {
+	hash, err := hex.DecodeString(strings.ReplaceAll(fingerprint, ":", ""))
+	if err != nil || len(hash) != 32 {
+		return "", fmt.Errorf("%q is not a SHA-256 certificate fingerprint: it must be 32 bytes of hex, such as AB:CD:…", fingerprint)
+	}
+	pairs := make([]string, len(hash))
+	for i, b := range hash {
+		pairs[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(pairs, ":"), nil
+}
+
+// ValidPackage returns an error if name is not an Android package name.
+func ValidPackage(name string) error {
+	if !packagePattern.MatchString(name) {
+		return fmt.Errorf("%q is not an Android package name, such as com.example.app", name)
+	}
+	return nil
+}
+
+// Fetch fetches the assetlinks.json file of domain with the timeout, transport and body
+// size limit of opts. Like Android's verification, it does not follow redirects, and
+// requires an application/json content type.
+func Fetch(ctx context.Context, domain string, opts counter.Options) (*Links, error) {
+	fileURL := "https://" + domain + Path
+	client := &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: opts.Transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid domain %q: %w", domain, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", fileURL, err)
+	}
+	defer resp.Body.Close()
+
+	links := &Links{Domain: domain, URL: fileURL}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return links, nil
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		return nil, fmt.Errorf("%s redirects to %s, which Android does not follow", fileURL, resp.Header.Get("Location"))
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s returned status code %d", fileURL, resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "application/json" {
+		return nil, fmt.Errorf("%s is served as %q, but Android requires application/json", fileURL, resp.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, opts.MaxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", fileURL, err)
+	}
+	if int64(len(body)) > opts.MaxBodySize {
+		return nil, fmt.Errorf("%s is larger than the maximum of %d bytes", fileURL, opts.MaxBodySize)
+	}
+	if links.Apps, links.Sites, err = Parse(body); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileURL, err)
+	}
+	links.Found = true
+	return links, nil
+}
+
+// Options configures a cross-check.
+type Options struct {
+	// RPID is the RP ID the document is served for, whose links the others are compared with.
+	RPID string
+	// Source is where the document came from. It is part of each finding's fingerprint.
+	Source string
+	// AppDomains are the other domains the RP's apps are associated with. The website of
+	// each one that shares an app with the RP ID needs its origin in the document.
+	AppDomains []string
+	// Fetch fetches the Digital Asset Links of a domain.
+	Fetch FetchFunc
+}
+
+// Check compares the credential sharing statements of the RP ID, the hosts of the https
+// origins of document and opts.AppDomains. It reports:
+//
+//   - an origin whose host shares credentials with apps that the RP ID does not, as
+//     lint.RuleAppRPMismatch;
+//   - a web target of the RP ID, or an app domain that shares an app with it, whose
+//     origin the document does not list, as lint.RuleAppOriginMissing;
+//   - an Android app origin of the document whose certificate no app of the RP ID is
+//     signed with, as lint.RuleAndroidOriginUnlinked.
+//
+// Files that cannot be fetched or parsed, and invalid packages and fingerprints, are
+// reported as lint.RuleAssetLinks.
+func Check(ctx context.Context, document []byte, opts Options) []lint.Finding {
+	var findings []lint.Finding
+	report := func(rule string, index int, origin, key, message string) *lint.Finding {
+		findings = append(findings, lint.Finding{
+			Rule:        rule,
+			Severity:    lint.SeverityWarning,
+			Index:       index,
+			Origin:      origin,
+			Message:     message,
+			Fingerprint: lint.Fingerprint(rule, opts.Source, key),
+		})
+		return &findings[len(findings)-1]
+	}
+	// links fetches the file of domain and returns its valid apps, as package and
+	// fingerprint pairs, and its sites, reporting anything wrong with it; ok is false if
+	// it could not be read. The findings about a file are fingerprinted by its domain and
+	// the value at fault, which are not origins to normalize
+	links := func(domain string) (apps map[string]bool, sites []string, ok bool) {
+		l, err := opts.Fetch(ctx, domain)
+		if err != nil {
+			report(lint.RuleAssetLinks, -1, "", "assetlinks.json "+domain, err.Error())
+			return nil, nil, false
+		}
+		apps = make(map[string]bool)
+		for _, app := range l.Apps {
+			if err := ValidPackage(app.Package); err != nil {
+				report(lint.RuleAssetLinks, -1, "", "assetlinks.json "+domain+" "+app.Package, fmt.Sprintf("%s: %v", l.URL, err))
+				continue
+			}
+			for _, fingerprint := range app.Fingerprints {
+				normalized, err := NormalizeFingerprint(fingerprint)
+				if err != nil {
+					report(lint.RuleAssetLinks, -1, "", "assetlinks.json "+domain+" "+app.Package+" "+fingerprint, fmt.Sprintf("%s: %s: %v", l.URL, app.Package, err))
+					continue
+				}
+				apps[app.Package+" "+normalized] = true
+			}
+		}
+		return apps, l.Sites, true
+	}
+
+	rpApps, rpSites, ok := links(opts.RPID)
+	if !ok {
+		return findings
+	}
+
+	// The first https origin of each host, in the order of the document, and the Android
+	// app origins. A document that is not valid JSON is reported by lint.Check, and has
+	// nothing to compare here
+	var doc struct {
+		Origins []string `json:"origins"`
+	}
+	_ = json.Unmarshal(document, &doc)
+	var hosts []string
+	indexes := make(map[string]int)
+	for i, origin := range doc.Origins {
+		if counter.IsAndroidOrigin(origin) {
+			continue
+		}
+		originURL, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(originURL.Scheme, "https") || originURL.Hostname() == "" {
+			continue
+		}
+		host := strings.ToLower(originURL.Hostname())
+		if _, seen := indexes[host]; !seen {
+			indexes[host] = i
+			hosts = append(hosts, host)
+		}
+	}
+
+	// Android app origins must be of a certificate the RP ID shares its credentials with
+	linked := make(map[string]bool)
+	for app := range rpApps {
+		_, fingerprint, _ := strings.Cut(app, " ")
+		if origin, err := counter.AndroidOrigin(fingerprint); err == nil {
+			linked[origin] = true
+		}
+	}
+	for i, origin := range doc.Origins {
+		if !counter.IsAndroidOrigin(origin) {
+			continue
+		}
+		if _, err := counter.ParseAndroidOrigin(origin); err != nil || linked[origin] {
+			continue
+		}
+		report(lint.RuleAndroidOriginUnlinked, i, origin, origin,
+			fmt.Sprintf("no app signed with this certificate is granted %s by https://%s%s, so the app cannot use the passkeys of RP ID %s", RelationLoginCreds, opts.RPID, Path, opts.RPID))
+	}
+
+	for _, host := range hosts {
+		if host == strings.ToLower(opts.RPID) {
+			continue
+		}
+		hostApps, _, ok := links(host)
+		if !ok {
+			continue
+		}
+		if extra := difference(hostApps, rpApps); len(extra) > 0 {
+			origin := doc.Origins[indexes[host]]
+			report(lint.RuleAppRPMismatch, indexes[host], origin, origin,
+				fmt.Sprintf("%s shares credentials with Android apps %s, but RP ID %s does not; the apps cannot use the passkeys of RP ID %s that %s uses as a related origin", host, strings.Join(extra, ", "), opts.RPID, opts.RPID, origin))
+		}
+	}
+
+	// missing reports the origin of a domain that is neither listed nor able to use the
+	// RP ID, once even if it is both a web target and an app domain
+	reported := make(map[string]bool)
+	missing := func(domain, message string) {
+		reported[domain] = true
+		origin := "https://" + domain
+		finding := report(lint.RuleAppOriginMissing, -1, origin, origin, message)
+		finding.Remediation = &lint.Remediation{Action: lint.ActionAddOrigin, Target: opts.Source, Value: origin}
+	}
+	unlisted := func(domain string) bool {
+		_, listed := indexes[domain]
+		return !listed && !reported[domain] && rpid.Check("https://"+domain, opts.RPID).Status != rpid.Valid
+	}
+	for _, site := range rpSites {
+		siteURL, err := url.Parse(site)
+		if err != nil || siteURL.Hostname() == "" {
+			report(lint.RuleAssetLinks, -1, "", "assetlinks.json "+opts.RPID+" "+site, fmt.Sprintf("https://%s%s: web target %q is not an origin", opts.RPID, Path, site))
+			continue
+		}
+		if domain := strings.ToLower(siteURL.Hostname()); unlisted(domain) {
+			missing(domain, fmt.Sprintf("RP ID %s shares credentials with %s in its assetlinks.json, but https://%s is not listed, so its website cannot use the passkeys of RP ID %s", opts.RPID, site, domain, opts.RPID))
+		}
+	}
+	for _, domain := range opts.AppDomains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "https://"))
+		if !unlisted(domain) {
+			continue
+		}
+		domainApps, _, ok := links(domain)
+		if !ok {
+			continue
+		}
+		if shared := intersection(domainApps, rpApps); len(shared) > 0 {
+			missing(domain, fmt.Sprintf("%s shares credentials with Android apps %s, like RP ID %s, but https://%s is not listed, so its website cannot use the passkeys the apps create", domain, strings.Join(shared, ", "), opts.RPID, domain))
+		}
+	}
+	return findings
+}
+
+// difference returns the packages of the apps of a that are not in b, sorted and
+// without duplicates.
+func difference(a, b map[string]bool) []string {
+	packages := make(map[string]bool)
+	for app := range a {
+		if !b[app] {
+			name, _, _ := strings.Cut(app, " ")
+			packages[name] = true
+		}
+	}
+	return sorted(packages)
+}
+
+// intersection returns the packages of the apps of a that are also in b, sorted and
+// without duplicates.
+func intersection(a, b map[string]bool) []string {
+	packages := make(map[string]bool)
+	for app := range a {
+		if b[app] {
+			name, _, _ := strings.Cut(app, " ")
+			packages[name] = true
+		}
+	}
+	return sorted(packages)
+}
+
+// sorted returns the keys of set in order.
+func sorted(set map[string]bool) []string {
+	var keys []string
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,183 @@
+package assetlinks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+)
+
+// certFingerprint is the SHA-256 fingerprint of the certificate "cert", whose Android
+// app origin is android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI.
+const certFingerprint = "06:29:84:32:E8:06:6B:29:E2:22:3B:CC:23:AA:95:04:B5:6A:E5:08:FA:BF:34:35:50:88:69:B9:C3:19:0E:22"
+
+// TestParse tests reading the apps and sites a file shares credentials with.
+func TestParse(t *testing.T) {
+	data := `[
+		{"relation": ["delegate_permission/common.handle_all_urls", "delegate_permission/common.get_login_creds"],
+		 "target": {"namespace": "android_app", "package_name": "com.example.app", "sha256_cert_fingerprints": ["AA:BB"]}},
+		{"relation": ["delegate_permission/common.handle_all_urls"],
+		 "target": {"namespace": "android_app", "package_name": "com.example.links", "sha256_cert_fingerprints": ["CC:DD"]}},
+		{"relation": ["delegate_permission/common.get_login_creds"],
+		 "target": {"namespace": "web", "site": "https://example.de"}},
+		{"include": "https://example.com/other.json"}
+	]`
+	apps, sites, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+	if want := []App{{Package: "com.example.app", Fingerprints: []string{"AA:BB"}}}; !reflect.DeepEqual(apps, want) {
+		t.Errorf("Parse() apps = %+v, want %+v", apps, want)
+	}
+	if want := []string{"https://example.de"}; !reflect.DeepEqual(sites, want) {
+		t.Errorf("Parse() sites = %v, want %v", sites, want)
+	}
+
+	if _, _, err := Parse([]byte(`{"relation": []}`)); err == nil {
+		t.Error("Parse() of an object returned no error, want one: the file is a list of statements")
+	}
+}
+
+// TestNormalizeFingerprint tests the fingerprint forms accepted.
+func TestNormalizeFingerprint(t *testing.T) {
+	tests := []struct {
+		fingerprint string
+		want        string
+		wantErr     bool
+	}{
+		{certFingerprint, certFingerprint, false},
+		{strings.ToLower(certFingerprint), certFingerprint, false},
+		{strings.ReplaceAll(certFingerprint, ":", ""), certFingerprint, false},
+		{certFingerprint[3:], "", true},
+		{"not hex", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeFingerprint(tt.fingerprint)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeFingerprint(%q) = %q, %v; want %q, error %v", tt.fingerprint, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestFetch tests fetching a file, which must be JSON and is not followed through redirects.
+func TestFetch(t *testing.T) {
+	contentType := "application/json"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != Path {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(`[{"relation": ["delegate_permission/common.get_login_creds"], "target": {"namespace": "web", "site": "https://example.de"}}]`))
+	}))
+	defer server.Close()
+	domain := strings.TrimPrefix(server.URL, "https://")
+	opts := counter.DefaultOptions()
+	opts.Transport = server.Client().Transport
+
+	links, err := Fetch(context.Background(), domain, opts)
+	if err != nil {
+		t.Fatalf("Fetch returned an error: %v", err)
+	}
+	if !links.Found || !reflect.DeepEqual(links.Sites, []string{"https://example.de"}) {
+		t.Errorf("Fetch() = %+v, want the file's site", links)
+	}
+
+	contentType = "text/plain"
+	if _, err := Fetch(context.Background(), domain, opts); err == nil || !strings.Contains(err.Error(), "application/json") {
+		t.Errorf("Fetch() of a text/plain file error = %v, want one about the content type", err)
+	}
+}
+
+// TestCheck tests cross-checking the links of the RP ID, the origins and the app domains.
+func TestCheck(t *testing.T) {
+	app := func(name string) App {
+		return App{Package: name, Fingerprints: []string{certFingerprint}}
+	}
+	files := map[string]*Links{
+		"example.com":   {Apps: []App{app("com.example.app")}, Sites: []string{"https://example.de", "https://example.co.uk"}},
+		"example.co.uk": {Apps: []App{app("com.example.app")}},
+		"example.nl":    {Apps: []App{app("com.example.nl")}},
+		"example.fr":    {Apps: []App{app("com.example.app")}},
+		"bad.example":   {Apps: []App{{Package: "com.example.app", Fingerprints: []string{"AA:BB"}}}},
+	}
+	fetch := func(ctx context.Context, domain string) (*Links, error) {
+		if domain == "down.example" {
+			return nil, errors.New("connection refused")
+		}
+		links, ok := files[domain]
+		if !ok {
+			return &Links{Domain: domain}, nil
+		}
+		links.Found = true
+		return links, nil
+	}
+	// The first Android app origin is of the certificate the RP ID links, the second of another
+	document := []byte(`{"origins": ["https://example.co.uk", "https://example.nl", "https://bad.example",
+		"android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI", "android:apk-key-hash:2SmKENGwc1g33EvYXaxkGw887yekfl1TpU8vP1svz_o"]}`)
+
+	findings := Check(context.Background(), document, Options{
+		RPID:       "example.com",
+		Source:     "https://example.com/.well-known/webauthn",
+		AppDomains: []string{"example.fr", "example.de", "down.example"},
+		Fetch:      fetch,
+	})
+
+	type finding struct {
+		Rule   string
+		Index  int
+		Origin string
+	}
+	var got []finding
+	for _, f := range findings {
+		got = append(got, finding{f.Rule, f.Index, f.Origin})
+	}
+	want := []finding{
+		{lint.RuleAndroidOriginUnlinked, 4, "android:apk-key-hash:2SmKENGwc1g33EvYXaxkGw887yekfl1TpU8vP1svz_o"},
+		{lint.RuleAppRPMismatch, 1, "https://example.nl"},
+		{lint.RuleAssetLinks, -1, ""},
+		{lint.RuleAppOriginMissing, -1, "https://example.de"},
+		{lint.RuleAppOriginMissing, -1, "https://example.fr"},
+		{lint.RuleAssetLinks, -1, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Check() = %+v, want %+v", got, want)
+	}
+	if !strings.Contains(findings[1].Message, "com.example.nl") {
+		t.Errorf("mismatch message %q does not name the extra app", findings[1].Message)
+	}
+	if r := findings[3].Remediation; r == nil || r.Action != lint.ActionAddOrigin || r.Value != "https://example.de" {
+		t.Errorf("missing origin remediation = %+v, want to add https://example.de", r)
+	}
+}
+
+// TestLinksOrigins tests the origins imported from a file.
+func TestLinksOrigins(t *testing.T) {
+	links := &Links{
+		Domain: "example.de",
+		Found:  true,
+		Apps: []App{
+			{Package: "com.example.app", Fingerprints: []string{certFingerprint, "AA:BB"}},
+			{Package: "not a package", Fingerprints: []string{certFingerprint}},
+		},
+		Sites: []string{"https://example.co.uk"},
+	}
+	if got, want := links.Origins(), []string{"https://example.de", "https://example.co.uk"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Origins() = %v, want %v", got, want)
+	}
+	if got, want := links.AndroidOrigins(), []string{"android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AndroidOrigins() = %v, want %v", got, want)
+	}
+
+	// A file that only shares credentials with websites does not make its domain one
+	links.Apps = nil
+	if got, want := links.Origins(), []string{"https://example.co.uk"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Origins() without apps = %v, want %v", got, want)
+	}
+}
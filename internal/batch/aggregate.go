@@ -0,0 +1,192 @@
+package batch
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/forecast"
+)
+
+// Summary holds aggregate counts for a batch run.
+type Summary struct {
+	Total        int
+	Passed       int
+	ExceedsLimit int
+	Invalid      int
+	Failed       int
+	Skipped      int
+	// LabelHistogram maps a label count to the number of domains with that count.
+	LabelHistogram map[int]int
+	// Forecast counts the domains projected to reach a limit, if a growth forecast is
+	// configured.
+	Forecast *ForecastSummary
+}
+
+// ForecastSummary counts the domains projected to reach each limit, by the number of
+// quarters from Start until they do.
+type ForecastSummary struct {
+	Growth forecast.Growth
+	Start  time.Time
+	// LabelLimit maps a number of quarters to the number of domains that use every label
+	// by then.
+	LabelLimit map[int]int
+	// SizeLimit maps a number of quarters to the number of domains whose document grows
+	// past the size browsers read by then.
+	SizeLimit map[int]int
+}
+
+// Aggregator accumulates a Summary from streamed records while keeping only counters in memory.
+// Records that need attention (anything other than a pass) are spilled to a temporary file so
+// that they can be listed in the summary without holding them in memory.
+type Aggregator struct {
+	summary Summary
+	spill   *os.File
+	writer  *bufio.Writer
+	spilled int
+}
+
+// NewAggregator returns an Aggregator that spills to a temporary file in dir
+// (or the default temporary directory when dir is empty).
+func NewAggregator(dir string) (*Aggregator, error) {
+	spill, err := os.CreateTemp(dir, "passkey-origin-validator-summary-*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create summary spill file: %w", err)
+	}
+	return &Aggregator{
+		summary: Summary{LabelHistogram: make(map[int]int)},
+		spill:   spill,
+		writer:  bufio.NewWriter(spill),
+	}, nil
+}
+
+// Add records a single result in the summary.
+func (a *Aggregator) Add(record Record) error {
+	a.summary.Total++
+	switch {
+	case record.Skipped:
+		a.summary.Skipped++
+	case record.Failed():
+		a.summary.Failed++
+	case record.Invalid():
+		a.summary.Invalid++
+	case record.ExceedsLimit:
+		a.summary.ExceedsLimit++
+	default:
+		a.summary.Passed++
+	}
+	if !record.Failed() && !record.Skipped {
+		a.summary.LabelHistogram[record.Count]++
+		if f := a.summary.Forecast; f != nil {
+			projection := forecast.Project(record.Origins, record.Count, record.MaxLabels, f.Growth)
+			if projection.LabelLimit != forecast.Never {
+				f.LabelLimit[projection.LabelLimit]++
+			}
+			if projection.SizeLimit != forecast.Never {
+				f.SizeLimit[projection.SizeLimit]++
+			}
+		}
+	}
+
+	// Spill everything that is not a clean pass
+	if record.Skipped || record.Failed() || record.Invalid() || record.ExceedsLimit {
+		if _, err := fmt.Fprintln(a.writer, FormatRecord(record)); err != nil {
+			return fmt.Errorf("failed to write summary spill file: %w", err)
+		}
+		a.spilled++
+	}
+	return nil
+}
+
+// Forecast makes the summary count the domains projected to reach a limit with growth,
+// in quarters from start. It must be called before any record is added.
+func (a *Aggregator) Forecast(growth forecast.Growth, start time.Time) {
+	a.summary.Forecast = &ForecastSummary{
+		Growth:     growth,
+		Start:      start,
+		LabelLimit: make(map[int]int),
+		SizeLimit:  make(map[int]int),
+	}
+}
+
+// Summary returns the aggregate counts collected so far.
+func (a *Aggregator) Summary() Summary {
+	return a.summary
+}
+
+// Attention returns up to limit formatted records that need attention, in the order they
+// were added, along with the total number of such records.
+func (a *Aggregator) Attention(limit int) ([]string, int, error) {
+	if err := a.writer.Flush(); err != nil {
+		return nil, 0, fmt.Errorf("failed to flush summary spill file: %w", err)
+	}
+
+	f, err := os.Open(a.spill.Name())
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read summary spill file: %w", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() && len(lines) < limit {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, a.spilled, scanner.Err()
+}
+
+// Close removes the spill file.
+func (a *Aggregator) Close() error {
+	a.spill.Close()
+	return os.Remove(a.spill.Name())
+}
+
+// FormatSummary formats the summary of a batch run into a human-readable string.
+func FormatSummary(summary Summary) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Domains: %d\n", summary.Total))
+	sb.WriteString(fmt.Sprintf("Passed: %d\n", summary.Passed))
+	sb.WriteString(fmt.Sprintf("Exceeds limit: %d\n", summary.ExceedsLimit))
+	sb.WriteString(fmt.Sprintf("Invalid origin: %d\n", summary.Invalid))
+	sb.WriteString(fmt.Sprintf("Failed: %d\n", summary.Failed))
+	sb.WriteString(fmt.Sprintf("Skipped: %d\n", summary.Skipped))
+
+	if len(summary.LabelHistogram) > 0 {
+		counts := make([]int, 0, len(summary.LabelHistogram))
+		for count := range summary.LabelHistogram {
+			counts = append(counts, count)
+		}
+		sort.Ints(counts)
+		sb.WriteString("Label counts:\n")
+		for _, count := range counts {
+			sb.WriteString(fmt.Sprintf("  %d labels: %d domains\n", count, summary.LabelHistogram[count]))
+		}
+	}
+
+	if f := summary.Forecast; f != nil {
+		sb.WriteString(fmt.Sprintf("Forecast (%s):\n", f.Growth))
+		formatForecast(&sb, "Label limit", f.LabelLimit, f.Start)
+		formatForecast(&sb, "Size limit", f.SizeLimit, f.Start)
+	}
+	return sb.String()
+}
+
+// formatForecast writes how many domains reach a limit in each quarter.
+func formatForecast(sb *strings.Builder, limit string, domains map[int]int, start time.Time) {
+	if len(domains) == 0 {
+		sb.WriteString(fmt.Sprintf("  %s: no domains\n", limit))
+		return
+	}
+	quarters := make([]int, 0, len(domains))
+	for quarter := range domains {
+		quarters = append(quarters, quarter)
+	}
+	sort.Ints(quarters)
+	sb.WriteString(fmt.Sprintf("  %s:\n", limit))
+	for _, quarter := range quarters {
+		sb.WriteString(fmt.Sprintf("    %s: %d domains\n", forecast.When(start, quarter), domains[quarter]))
+	}
+}
@@ -0,0 +1,334 @@
+// Package batch runs label counting and origin validation across a list of domains.
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/breaker"
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/limits"
+	"github.com/developmeh/passkey-origin-validator/internal/tally"
+)
+
+// Record is the result for a single domain in a batch run.
+type Record struct {
+	// SchemaVersion is the SchemaVersion of the format the record was written in.
+	SchemaVersion int       `json:"schema_version,omitempty"`
+	Domain        string    `json:"domain"`
+	URL           string    `json:"url,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+	Count         int       `json:"label_count"`
+	Labels        []string  `json:"labels,omitempty"`
+	Origins       []string  `json:"origins,omitempty"`
+	// AndroidOrigins are the valid Android app origins among Origins, which have no
+	// label and are not counted.
+	AndroidOrigins []string `json:"android_origins,omitempty"`
+	ExceedsLimit   bool     `json:"exceeds_limit"`
+	// MaxLabels is the label limit the count was checked against, if it is not
+	// counter.MaxLabels.
+	MaxLabels int    `json:"max_labels,omitempty"`
+	Origin    string `json:"origin,omitempty"`
+	Status    string `json:"status,omitempty"`
+	// MatchedOrigin is the entry of the document that authorizes Origin, if any.
+	MatchedOrigin string `json:"matched_origin,omitempty"`
+	Error         string `json:"error,omitempty"`
+	// ParseError locates the error when the document is not valid JSON.
+	ParseError  *counter.ParseError `json:"parse_error,omitempty"`
+	Warnings    []string            `json:"warnings,omitempty"`
+	Skipped     bool                `json:"skipped,omitempty"`
+	CircuitOpen bool                `json:"circuit_open,omitempty"`
+	// ModelVersion names the browser behavior model the domain was checked with, such as
+	// "chromium-128".
+	ModelVersion string `json:"model_version,omitempty"`
+	// Timings are how long each stage of processing the domain took. They are nil for
+	// records that were not timed, such as those read from a database.
+	Timings *Timings `json:"timings,omitempty"`
+}
+
+// Timings are how long each stage of processing a domain took, in milliseconds.
+type Timings struct {
+	// FetchMS is the time spent fetching the document, including failed fetches.
+	FetchMS float64 `json:"fetch_ms"`
+	// ParseMS is the time spent parsing the document and counting its labels.
+	ParseMS float64 `json:"parse_ms"`
+	// ValidateMS is the time spent validating the caller origin, if one was given.
+	ValidateMS float64 `json:"validate_ms,omitempty"`
+}
+
+// milliseconds returns d in milliseconds, to the microsecond.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Failed reports whether the domain could not be fetched or parsed.
+func (r Record) Failed() bool {
+	return r.Error != "" && !r.Skipped
+}
+
+// LabelLimit returns the label limit the count was checked against.
+func (r Record) LabelLimit() int {
+	if r.MaxLabels == 0 {
+		return counter.MaxLabels
+	}
+	return r.MaxLabels
+}
+
+// Invalid reports whether the caller origin was checked and is not authorized.
+func (r Record) Invalid() bool {
+	return r.Status != "" && r.Status != counter.StatusSuccess.String()
+}
+
+// Outcome returns the most severe result of the domain, for the summary block of a run:
+// an error if it failed, is over the label limit or does not authorize the caller
+// origin, and a warning if it was served in a way some browsers reject.
+func (r Record) Outcome() tally.Outcome {
+	switch {
+	case r.Skipped:
+		return tally.Skipped
+	case r.Failed() || r.Invalid() || r.ExceedsLimit:
+		return tally.Errored
+	case len(r.Warnings) > 0:
+		return tally.Warned
+	default:
+		return tally.Passed
+	}
+}
+
+// Options configures a batch run.
+type Options struct {
+	// Concurrency is the number of domains processed in parallel.
+	Concurrency int
+	// Origin is an optional caller origin to validate against every domain.
+	Origin string
+	// Fetch configures how each domain's document is fetched.
+	Fetch counter.Options
+	// Budget, if set, stops the run once a resource limit is reached.
+	// Fetch.Transport should already be wrapped with Budget.Transport.
+	Budget *limits.Budget
+	// Breaker, if set, stops fetching domains that fail repeatedly until a cooldown has passed.
+	Breaker *breaker.Breaker
+	// URLTemplate, if set, makes every domain a tenant name whose document is fetched
+	// from the expanded template instead of the domain's .well-known/webauthn path.
+	URLTemplate URLTemplate
+	// ModelVersion is recorded in every record of a domain that was processed.
+	ModelVersion string
+}
+
+// Process fetches a single domain and builds its Record.
+func Process(domain string, opts Options) Record {
+	record := Record{
+		SchemaVersion: SchemaVersion,
+		Domain:        domain,
+		Timestamp:     time.Now().UTC(),
+		Origin:        opts.Origin,
+		ModelVersion:  opts.ModelVersion,
+	}
+
+	// Do not fetch a domain whose circuit is open
+	if err := opts.Breaker.Allow(domain); err != nil {
+		record.Error = err.Error()
+		record.CircuitOpen = true
+		return record
+	}
+
+	var result *counter.LabelCount
+	var err error
+	start := time.Now()
+	if opts.URLTemplate != "" {
+		var wellKnownURL string
+		wellKnownURL, err = opts.URLTemplate.Expand(domain)
+		if err == nil {
+			result, err = counter.CountLabelsFromURLWithOptions(wellKnownURL, opts.Fetch)
+		}
+	} else {
+		result, err = counter.CountLabelsWithOptions(domain, opts.Fetch)
+	}
+	if err != nil {
+		record.Error = err.Error()
+		// A request refused by the budget was never made, so the domain was not checked
+		record.Skipped = errors.Is(err, limits.ErrBudgetExhausted)
+		if !record.Skipped {
+			record.Timings = &Timings{FetchMS: milliseconds(time.Since(start))}
+			opts.Breaker.Failure(domain)
+		}
+		return record
+	}
+
+	record = NewRecord(domain, result, opts.Origin)
+	record.ModelVersion = opts.ModelVersion
+	if record.Error != "" {
+		opts.Breaker.Failure(domain)
+	} else {
+		opts.Breaker.Success(domain)
+	}
+	return record
+}
+
+// NewRecord builds the record of domain from the result of fetching its document, and
+// validates origin against the document if origin is set.
+func NewRecord(domain string, result *counter.LabelCount, origin string) Record {
+	record := Record{
+		SchemaVersion: SchemaVersion,
+		Domain:        domain,
+		URL:           result.URL,
+		Timestamp:     time.Now().UTC(),
+		Origin:        origin,
+		Warnings:      result.Warnings,
+		Timings: &Timings{
+			FetchMS: milliseconds(result.Timings.Fetch),
+			ParseMS: milliseconds(result.Timings.Parse),
+		},
+	}
+	if result.ErrorMessage != "" {
+		record.Error = result.ErrorMessage
+		record.ParseError = result.ParseError
+		return record
+	}
+
+	record.Count = result.Count
+	record.Labels = result.LabelsFound
+	record.Origins = result.Origins
+	record.AndroidOrigins = result.AndroidOrigins
+	record.ExceedsLimit = result.ExceedsLimit
+	if result.MaxLabels != counter.MaxLabels {
+		record.MaxLabels = result.MaxLabels
+	}
+	if origin != "" {
+		start := time.Now()
+		record.Status = counter.ValidateWellKnownJSONWithMaxLabels(origin, []byte(result.RawJSON), result.MaxLabels).String()
+		record.Timings.ValidateMS = milliseconds(time.Since(start))
+		if compiled, err := counter.CompileWithMaxLabels([]byte(result.RawJSON), result.MaxLabels); err == nil {
+			record.MatchedOrigin = compiled.MatchedOrigin(origin)
+		}
+	}
+	return record
+}
+
+// Sink receives each record as soon as its domain has been processed.
+// Records arrive in completion order, not input order. Sink is never called concurrently.
+type Sink func(Record) error
+
+// Run processes every domain and streams each record to sink, so that memory use does not
+// grow with the number of domains. Once the budget is exhausted or ctx is done, the remaining
+// domains are streamed as skipped. Run stops early and returns the error if sink fails.
+func Run(ctx context.Context, domains []string, opts Options, sink Sink) error {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan string)
+	results := make(chan Record)
+	var wg sync.WaitGroup
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for domain := range jobs {
+				results <- Process(domain, opts)
+			}
+		}()
+	}
+
+	// Dispatch domains from a separate goroutine so results can be drained as they arrive
+	done := make(chan struct{})
+	go func() {
+		defer close(results)
+		defer wg.Wait()
+		defer close(jobs)
+		for _, domain := range domains {
+			// Stop dispatching work once the run may no longer continue
+			if reason := stopReason(ctx, opts.Budget); reason != nil {
+				select {
+				case results <- Record{
+					SchemaVersion: SchemaVersion,
+					Domain:        domain,
+					Timestamp:     time.Now().UTC(),
+					Error:         reason.Error(),
+					Skipped:       true,
+				}:
+				case <-done:
+					return
+				}
+				continue
+			}
+			select {
+			case jobs <- domain:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var sinkErr error
+	for record := range results {
+		if sinkErr != nil {
+			continue
+		}
+		if err := sink(record); err != nil {
+			sinkErr = err
+			close(done)
+		}
+	}
+	return sinkErr
+}
+
+// stopReason returns why a run must stop, or nil if it may continue.
+func stopReason(ctx context.Context, budget *limits.Budget) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if budget != nil {
+		return budget.Exhausted()
+	}
+	return nil
+}
+
+// Verdict returns the outcome of a record as one word: SKIPPED, ERROR, INVALID,
+// EXCEEDS_LIMIT or OK, in the order the categories of a summary are checked.
+func (r Record) Verdict() string {
+	switch {
+	case r.Skipped:
+		return "SKIPPED"
+	case r.Failed():
+		return "ERROR"
+	case r.Invalid():
+		return "INVALID"
+	case r.ExceedsLimit:
+		return "EXCEEDS_LIMIT"
+	default:
+		return "OK"
+	}
+}
+
+// FormatVerdict formats a record as a single line of the domain, its verdict and its
+// label count, separated by spaces, for logs that must stay readable at scale.
+func FormatVerdict(record Record) string {
+	return fmt.Sprintf("%s %s %d", record.Domain, record.Verdict(), record.Count)
+}
+
+// FormatRecord formats a single record as one human-readable line.
+func FormatRecord(record Record) string {
+	switch {
+	case record.Skipped:
+		return fmt.Sprintf("%s: SKIPPED (%s)", record.Domain, record.Error)
+	case record.CircuitOpen:
+		return fmt.Sprintf("%s: CIRCUIT OPEN (%s)", record.Domain, record.Error)
+	case record.Error != "":
+		return fmt.Sprintf("%s: ERROR %s", record.Domain, record.Error)
+	}
+
+	line := fmt.Sprintf("%s: %d labels", record.Domain, record.Count)
+	if record.ExceedsLimit {
+		line += fmt.Sprintf(" (exceeds limit of %d)", record.LabelLimit())
+	}
+	if record.Status != "" {
+		line += fmt.Sprintf(", %s %s", record.Origin, record.Status)
+	}
+	return line
+}
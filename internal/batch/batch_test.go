@@ -0,0 +1,506 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/breaker"
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/forecast"
+	"github.com/developmeh/passkey-origin-validator/internal/limits"
+	"github.com/developmeh/passkey-origin-validator/internal/tally"
+)
+
+// newServer returns a test server that serves the given document at every path.
+func newServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+}
+
+// TestRun tests the Run function.
+func TestRun(t *testing.T) {
+	small := newServer(`{"origins": ["https://example.com"]}`)
+	defer small.Close()
+	large := newServer(`{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://f.com"]}`)
+	defer large.Close()
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	// collect runs a batch and returns the records keyed by domain along with the aggregate summary
+	collect := func(t *testing.T, domains []string, opts Options) (map[string]Record, *Aggregator) {
+		t.Helper()
+		aggregator, err := NewAggregator(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewAggregator returned an error: %v", err)
+		}
+		t.Cleanup(func() { aggregator.Close() })
+
+		records := make(map[string]Record)
+		err = Run(context.Background(), domains, opts, func(record Record) error {
+			records[record.Domain] = record
+			return aggregator.Add(record)
+		})
+		if err != nil {
+			t.Fatalf("Run returned an error: %v", err)
+		}
+		return records, aggregator
+	}
+
+	t.Run("Mixed results", func(t *testing.T) {
+		opts := Options{Concurrency: 2, Origin: "https://example.com", Fetch: counter.DefaultOptions(), ModelVersion: "chromium-128"}
+		records, aggregator := collect(t, []string{small.URL, large.URL, missing.URL}, opts)
+
+		if len(records) != 3 {
+			t.Fatalf("Expected 3 records, got %d", len(records))
+		}
+		if records[small.URL].Status != "SUCCESS" || records[small.URL].MatchedOrigin != "https://example.com" {
+			t.Errorf("Expected small document to succeed, got %+v", records[small.URL])
+		}
+		if records[large.URL].MatchedOrigin != "" {
+			t.Errorf("Expected no matched origin in the large document, got %+v", records[large.URL])
+		}
+		if !records[large.URL].ExceedsLimit {
+			t.Errorf("Expected large document to exceed the limit, got %+v", records[large.URL])
+		}
+		if !records[missing.URL].Failed() {
+			t.Errorf("Expected missing document to fail, got %+v", records[missing.URL])
+		}
+		for domain, record := range records {
+			if record.Timings == nil || record.Timings.FetchMS <= 0 {
+				t.Errorf("Expected %s to record how long its fetch took, got %+v", domain, record.Timings)
+			}
+			if record.ModelVersion != "chromium-128" {
+				t.Errorf("Expected %s to record the model version, got %q", domain, record.ModelVersion)
+			}
+		}
+
+		summary := aggregator.Summary()
+		if summary.Passed != 1 || summary.Invalid != 1 || summary.Failed != 1 {
+			t.Errorf("Unexpected summary %+v", summary)
+		}
+		if summary.LabelHistogram[1] != 1 || summary.LabelHistogram[6] != 1 {
+			t.Errorf("Unexpected label histogram %v", summary.LabelHistogram)
+		}
+
+		attention, total, err := aggregator.Attention(1)
+		if err != nil {
+			t.Fatalf("Attention returned an error: %v", err)
+		}
+		if total != 2 || len(attention) != 1 {
+			t.Errorf("Expected 1 of 2 records needing attention, got %d of %d", len(attention), total)
+		}
+	})
+
+	t.Run("Max labels", func(t *testing.T) {
+		fetch := counter.DefaultOptions()
+		fetch.MaxLabels = 6
+		record := Process(large.URL, Options{Origin: "https://f.com", Fetch: fetch})
+		if record.ExceedsLimit || record.Status != "SUCCESS" || record.MaxLabels != 6 {
+			t.Errorf("Expected a limit of 6 labels to authorize the sixth label, got %+v", record)
+		}
+
+		fetch.MaxLabels = 2
+		record = Process(large.URL, Options{Fetch: fetch})
+		if got := FormatRecord(record); !strings.Contains(got, "(exceeds limit of 2)") {
+			t.Errorf("Expected the record to name the configured limit, got %q", got)
+		}
+		if record := Process(small.URL, Options{Fetch: counter.DefaultOptions()}); record.MaxLabels != 0 {
+			t.Errorf("Expected the default limit to be omitted, got %d", record.MaxLabels)
+		}
+	})
+
+	t.Run("Budget exhausted", func(t *testing.T) {
+		budget := limits.NewBudget(limits.Limits{MaxRequests: 1})
+		fetch := counter.DefaultOptions()
+		fetch.Transport = budget.Transport(nil)
+
+		opts := Options{Concurrency: 1, Fetch: fetch, Budget: budget}
+		_, aggregator := collect(t, []string{small.URL, small.URL + "/", small.URL + "//"}, opts)
+
+		summary := aggregator.Summary()
+		if summary.Passed != 1 || summary.Skipped != 2 {
+			t.Errorf("Expected 1 passed and 2 skipped, got %+v", summary)
+		}
+		attention, _, _ := aggregator.Attention(10)
+		if len(attention) != 2 || !strings.Contains(attention[0], "SKIPPED") {
+			t.Errorf("Expected skipped records to need attention, got %v", attention)
+		}
+	})
+
+	t.Run("Circuit breaker", func(t *testing.T) {
+		opts := Options{Concurrency: 1, Fetch: counter.DefaultOptions(), Breaker: breaker.New(2, time.Hour)}
+
+		var records []Record
+		for i := 0; i < 3; i++ {
+			records = append(records, Process(missing.URL, opts))
+		}
+		if records[1].CircuitOpen || !records[2].CircuitOpen {
+			t.Errorf("Expected the third attempt to find the circuit open, got %+v", records)
+		}
+		if !strings.Contains(FormatRecord(records[2]), "CIRCUIT OPEN") {
+			t.Errorf("Expected the record to be formatted as circuit open, got %q", FormatRecord(records[2]))
+		}
+	})
+
+	t.Run("Sink error stops the run", func(t *testing.T) {
+		opts := Options{Concurrency: 2, Fetch: counter.DefaultOptions()}
+		calls := 0
+		err := Run(context.Background(), []string{small.URL, small.URL, small.URL, small.URL}, opts, func(Record) error {
+			calls++
+			return errors.New("disk full")
+		})
+		if err == nil || calls != 1 {
+			t.Errorf("Expected the first sink error to stop the run, got %v after %d calls", err, calls)
+		}
+	})
+}
+
+// TestFailureBudget tests the ParseFailureBudget function and FailureBudget.Tolerates.
+func TestFailureBudget(t *testing.T) {
+	tests := []struct {
+		spec     string
+		failed   int
+		total    int
+		expected bool
+	}{
+		{"0", 0, 100, true},
+		{"0", 1, 100, false},
+		{"3", 3, 100, true},
+		{"3", 4, 100, false},
+		{"1%", 10, 1000, true},
+		{"1%", 11, 1000, false},
+		{"0.5%", 1, 100, false},
+		{"100%", 5, 5, true},
+	}
+
+	for _, tt := range tests {
+		budget, err := ParseFailureBudget(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseFailureBudget(%q) returned an error: %v", tt.spec, err)
+		}
+		summary := Summary{Total: tt.total, Failed: tt.failed}
+		if got := budget.Tolerates(summary); got != tt.expected {
+			t.Errorf("%s: Tolerates(%d of %d) = %v, want %v", tt.spec, tt.failed, tt.total, got, tt.expected)
+		}
+	}
+
+	for _, spec := range []string{"", "-1", "abc", "101%", "x%"} {
+		if _, err := ParseFailureBudget(spec); err == nil {
+			t.Errorf("ParseFailureBudget(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+// TestShard tests that shards partition a domain list.
+func TestShard(t *testing.T) {
+	var domains []string
+	for i := 0; i < 1000; i++ {
+		domains = append(domains, fmt.Sprintf("domain%d.example.com", i))
+	}
+
+	seen := make(map[string]int)
+	for i := 1; i <= 4; i++ {
+		shard, err := ParseShard(fmt.Sprintf("%d/4", i))
+		if err != nil {
+			t.Fatalf("ParseShard returned an error: %v", err)
+		}
+		filtered := shard.Filter(domains)
+		if len(filtered) < 150 || len(filtered) > 350 {
+			t.Errorf("Shard %v has %d domains, expected roughly 250", shard, len(filtered))
+		}
+		for _, domain := range filtered {
+			seen[domain]++
+		}
+	}
+	for _, domain := range domains {
+		if seen[domain] != 1 {
+			t.Errorf("Domain %s is in %d shards, expected exactly 1", domain, seen[domain])
+		}
+	}
+
+	// Membership does not depend on case or surrounding whitespace
+	shard := Shard{Index: 2, Count: 4}
+	if shard.Contains("Example.com") != shard.Contains(" example.com ") {
+		t.Errorf("Expected membership to ignore case and whitespace")
+	}
+
+	for _, spec := range []string{"", "1", "0/4", "5/4", "a/4", "1/0"} {
+		if _, err := ParseShard(spec); err == nil {
+			t.Errorf("ParseShard(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+// TestMerger tests merging the records of several results files.
+func TestMerger(t *testing.T) {
+	first := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	later := first.Add(time.Hour)
+
+	// Two shards and a resumed run that checked a previously skipped domain again
+	input := strings.Join([]string{
+		`{"domain":"a.com","timestamp":"2024-01-01T00:00:00Z","label_count":1}`,
+		`{"domain":"b.com","timestamp":"2024-01-01T00:00:00Z","error":"budget exhausted","skipped":true}`,
+		``,
+		`{"domain":"c.com","timestamp":"2024-01-01T00:00:00Z","label_count":2}`,
+		`{"domain":"B.com","timestamp":"2023-12-31T00:00:00Z","label_count":3}`,
+		`{"domain":"a.com","timestamp":"2024-01-01T01:00:00Z","label_count":4}`,
+		`{"domain":"c.com","timestamp":"2024-01-01T00:00:00Z","label_count":5}`,
+		`{"domain":"a.com","timestamp":"2024-01-01T00:00:00Z","label_count":6,"origin":"https://example.com"}`,
+	}, "\n")
+
+	merger := NewMerger()
+	err := ReadRecords(strings.NewReader(input), func(record Record) error {
+		merger.Add(record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadRecords returned an error: %v", err)
+	}
+
+	records := merger.Records()
+	expected := []struct {
+		domain    string
+		count     int
+		timestamp time.Time
+	}{
+		{"a.com", 4, later},
+		{"b.com", 3, first.Add(-24 * time.Hour)},
+		{"c.com", 2, first},
+		{"a.com", 6, first},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d records, got %d: %+v", len(expected), len(records), records)
+	}
+	for i, want := range expected {
+		got := records[i]
+		if !strings.EqualFold(got.Domain, want.domain) || got.Count != want.count || !got.Timestamp.Equal(want.timestamp) {
+			t.Errorf("Record %d: expected %s with %d labels at %s, got %+v", i, want.domain, want.count, want.timestamp, got)
+		}
+	}
+	if got := merger.Duplicates(); got != 3 {
+		t.Errorf("Expected 3 duplicates, got %d", got)
+	}
+
+	if err := ReadRecords(strings.NewReader("{not json}\n"), func(Record) error { return nil }); err == nil {
+		t.Error("Expected an error for an invalid record")
+	}
+}
+
+// TestSample tests that samples are reproducible subsets of a domain list.
+func TestSample(t *testing.T) {
+	var domains []string
+	for i := 0; i < 1000; i++ {
+		domains = append(domains, fmt.Sprintf("domain%d.example.com", i))
+	}
+
+	sample := Sample(domains, 100, 42)
+	if len(sample) != 100 {
+		t.Fatalf("Expected 100 domains, got %d", len(sample))
+	}
+	if again := Sample(domains, 100, 42); strings.Join(again, ",") != strings.Join(sample, ",") {
+		t.Error("Expected the same seed to select the same domains")
+	}
+	if other := Sample(domains, 100, 43); strings.Join(other, ",") == strings.Join(sample, ",") {
+		t.Error("Expected a different seed to select different domains")
+	}
+
+	// The sample keeps the original order and has no duplicates
+	position := make(map[string]int)
+	for i, domain := range domains {
+		position[domain] = i
+	}
+	for i := 1; i < len(sample); i++ {
+		if position[sample[i]] <= position[sample[i-1]] {
+			t.Errorf("Sample is not in list order at %d: %s after %s", i, sample[i], sample[i-1])
+		}
+	}
+
+	if got := Sample(domains, 5000, 42); len(got) != len(domains) {
+		t.Errorf("Expected the whole list when the sample is larger, got %d domains", len(got))
+	}
+}
+
+// TestURLTemplate tests fetching per-tenant documents from a URL template.
+func TestURLTemplate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/acme/.well-known/webauthn" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"origins": ["https://acme.example.com"]}`))
+	}))
+	defer server.Close()
+
+	template, err := ParseURLTemplate(server.URL + "/{tenant}/.well-known/webauthn")
+	if err != nil {
+		t.Fatalf("ParseURLTemplate returned an error: %v", err)
+	}
+	opts := Options{Origin: "https://acme.example.com", Fetch: counter.DefaultOptions(), URLTemplate: template}
+
+	record := Process("acme", opts)
+	if record.Domain != "acme" || record.URL != server.URL+"/acme/.well-known/webauthn" || record.Status != "SUCCESS" {
+		t.Errorf("Unexpected record %+v", record)
+	}
+	if record := Process("other", opts); !record.Failed() {
+		t.Errorf("Expected an unknown tenant to fail, got %+v", record)
+	}
+	if record := Process("acme/../other", opts); !record.Failed() || record.URL != "" {
+		t.Errorf("Expected an invalid tenant to fail without a fetch, got %+v", record)
+	}
+
+	for _, spec := range []string{"https://example.com/.well-known/webauthn", "{tenant}.example.com", "ftp://{tenant}.example.com/"} {
+		if _, err := ParseURLTemplate(spec); err == nil {
+			t.Errorf("ParseURLTemplate(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+// TestCSVWriter tests writing records as CSV rows.
+func TestCSVWriter(t *testing.T) {
+	at := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var sb strings.Builder
+	w := NewCSVWriter(&sb)
+	for _, record := range []Record{
+		{Domain: "a.com", Timestamp: at, Count: 6, ExceedsLimit: true, Origin: "https://login.a.com", Status: "SUCCESS",
+			MatchedOrigin: "https://login.a.com", URL: "https://a.com/.well-known/webauthn"},
+		{Domain: "b.com", Timestamp: at, Origin: "https://login.a.com", Error: "failed to fetch, with a comma"},
+		{Domain: "c.com", Timestamp: at, Error: "budget exhausted", Skipped: true},
+		{Domain: "=HYPERLINK(\"https://evil.example\")", Timestamp: at},
+	} {
+		if err := w.Write(record); err != nil {
+			t.Fatalf("Write returned an error: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush returned an error: %v", err)
+	}
+
+	expected := "domain,caller_origin,status,label_count,exceeds_limit,matched_origin,error,url,timestamp\n" +
+		"a.com,https://login.a.com,SUCCESS,6,true,https://login.a.com,,https://a.com/.well-known/webauthn,2024-01-01T00:00:00Z\n" +
+		"b.com,https://login.a.com,ERROR,0,false,,\"failed to fetch, with a comma\",,2024-01-01T00:00:00Z\n" +
+		"c.com,,SKIPPED,0,false,,budget exhausted,,2024-01-01T00:00:00Z\n" +
+		"\"'=HYPERLINK(\"\"https://evil.example\"\")\",,,0,false,,,,2024-01-01T00:00:00Z\n"
+	if sb.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, sb.String())
+	}
+}
+
+// TestFormatVerdict tests the single-line verdicts of records.
+func TestFormatVerdict(t *testing.T) {
+	tests := []struct {
+		record   Record
+		expected string
+	}{
+		{Record{Domain: "a.com", Count: 3}, "a.com OK 3"},
+		{Record{Domain: "a.com", Count: 6, ExceedsLimit: true}, "a.com EXCEEDS_LIMIT 6"},
+		{Record{Domain: "a.com", Count: 6, ExceedsLimit: true, Status: "BAD_RELYING_PARTY_ID_NO_JSON_MATCH"}, "a.com INVALID 6"},
+		{Record{Domain: "a.com", Count: 2, Status: "SUCCESS"}, "a.com OK 2"},
+		{Record{Domain: "a.com", Error: "HTTP request failed with status code: 404"}, "a.com ERROR 0"},
+		{Record{Domain: "a.com", Error: "budget exhausted", Skipped: true}, "a.com SKIPPED 0"},
+	}
+	for _, tt := range tests {
+		if got := FormatVerdict(tt.record); got != tt.expected {
+			t.Errorf("FormatVerdict(%+v) = %q, expected %q", tt.record, got, tt.expected)
+		}
+	}
+}
+
+// TestOutcome tests the outcome each record counts as in the summary block of a run.
+func TestOutcome(t *testing.T) {
+	tests := []struct {
+		record   Record
+		expected tally.Outcome
+	}{
+		{Record{Count: 3}, tally.Passed},
+		{Record{Count: 3, Status: "SUCCESS"}, tally.Passed},
+		{Record{Count: 3, Warnings: []string{"served as text/plain"}}, tally.Warned},
+		{Record{Count: 6, ExceedsLimit: true, Warnings: []string{"served as text/plain"}}, tally.Errored},
+		{Record{Count: 2, Status: "BAD_RELYING_PARTY_ID_NO_JSON_MATCH"}, tally.Errored},
+		{Record{Error: "HTTP request failed with status code: 404"}, tally.Errored},
+		{Record{Error: "budget exhausted", Skipped: true}, tally.Skipped},
+	}
+	for _, tt := range tests {
+		if got := tt.record.Outcome(); got != tt.expected {
+			t.Errorf("Outcome(%+v) = %v, expected %v", tt.record, got, tt.expected)
+		}
+	}
+}
+
+// TestForecast tests counting the domains projected to reach a limit in the summary.
+func TestForecast(t *testing.T) {
+	aggregator, err := NewAggregator(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAggregator returned an error: %v", err)
+	}
+	defer aggregator.Close()
+	aggregator.Forecast(forecast.Growth{OriginsPerQuarter: 2, NewLabelsPerQuarter: 1}, time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC))
+	for _, record := range []Record{
+		{Domain: "a.com", Count: 3, Origins: []string{"https://a.com", "https://b.com", "https://c.com"}},
+		{Domain: "b.com", Count: 4, Origins: []string{"https://a.com", "https://b.com", "https://c.com", "https://d.com"}},
+		{Domain: "c.com", Count: 4, Origins: []string{"https://a.com", "https://b.com", "https://c.com", "https://d.com"}},
+		{Domain: "d.com", Count: 6, ExceedsLimit: true},
+		{Domain: "e.com", Error: "timeout"},
+	} {
+		aggregator.Add(record)
+	}
+
+	text := FormatSummary(aggregator.Summary())
+	expected := `Forecast (2 origins and 1 new labels per quarter):
+  Label limit:
+    now: 1 domains
+    2027 Q1 (in 1 quarter): 2 domains
+    2027 Q2 (in 2 quarters): 1 domains
+  Size limit: no domains
+`
+	if !strings.HasSuffix(text, expected) {
+		t.Errorf("Expected summary to end with:\n%s\ngot:\n%s", expected, text)
+	}
+}
+
+// TestNormalize tests validating and normalizing records read from results files.
+func TestNormalize(t *testing.T) {
+	at := time.Date(2024, time.January, 1, 1, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	// A record written before schema versions were introduced
+	record, err := Normalize(Record{Domain: " Example.COM ", Timestamp: at, Count: 2, Labels: []string{}, Origin: "https://a.com", Status: "SUCCESS"})
+	if err != nil {
+		t.Fatalf("Normalize returned an error: %v", err)
+	}
+	if record.SchemaVersion != SchemaVersion || record.Domain != "example.com" || record.Timestamp.Location() != time.UTC ||
+		!record.Timestamp.Equal(at) || record.Labels != nil {
+		t.Errorf("Unexpected normalized record %+v", record)
+	}
+
+	tests := []struct {
+		name   string
+		record Record
+		err    string
+	}{
+		{"Newer schema", Record{SchemaVersion: SchemaVersion + 1, Domain: "a.com", Timestamp: at}, "newer than the supported version"},
+		{"Missing domain", Record{Timestamp: at}, "missing domain"},
+		{"Missing timestamp", Record{Domain: "a.com"}, "missing timestamp"},
+		{"Negative count", Record{Domain: "a.com", Timestamp: at, Count: -1}, "invalid label count"},
+		{"Negative label limit", Record{Domain: "a.com", Timestamp: at, MaxLabels: -1}, "invalid label limit"},
+		{"Unknown status", Record{Domain: "a.com", Timestamp: at, Origin: "https://a.com", Status: "OK"}, "unknown status"},
+		{"Status without origin", Record{Domain: "a.com", Timestamp: at, Status: "SUCCESS"}, "without a caller origin"},
+		{"Skipped without error", Record{Domain: "a.com", Timestamp: at, Skipped: true}, "without an error"},
+		{"Negative timing", Record{Domain: "a.com", Timestamp: at, Timings: &Timings{FetchMS: -1}}, "negative timing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Normalize(tt.record)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
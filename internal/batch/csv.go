@@ -0,0 +1,75 @@
+package batch
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVHeader names the columns written by CSVWriter.
+var CSVHeader = []string{"domain", "caller_origin", "status", "label_count", "exceeds_limit", "matched_origin", "error", "url", "timestamp"}
+
+// CSVWriter writes records as CSV, one row per domain and caller origin, for review in
+// spreadsheets.
+type CSVWriter struct {
+	w *csv.Writer
+	// wroteHeader is true once the header row has been written.
+	wroteHeader bool
+}
+
+// NewCSVWriter returns a CSVWriter that writes to w. The header row is written before
+// the first record.
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// Write writes one record as a row. The status column holds the caller origin's status,
+// or SKIPPED or ERROR for a domain that was not checked or could not be fetched.
+func (c *CSVWriter) Write(record Record) error {
+	if !c.wroteHeader {
+		if err := c.w.Write(CSVHeader); err != nil {
+			return err
+		}
+		c.wroteHeader = true
+	}
+
+	status := record.Status
+	switch {
+	case record.Skipped:
+		status = "SKIPPED"
+	case record.Failed():
+		status = "ERROR"
+	}
+	row := []string{
+		record.Domain,
+		record.Origin,
+		status,
+		strconv.Itoa(record.Count),
+		strconv.FormatBool(record.ExceedsLimit),
+		record.MatchedOrigin,
+		record.Error,
+		record.URL,
+		record.Timestamp.Format(time.RFC3339),
+	}
+	for i, cell := range row {
+		row[i] = escapeFormula(cell)
+	}
+	return c.w.Write(row)
+}
+
+// Flush writes any buffered rows and returns the first error that occurred.
+func (c *CSVWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// escapeFormula prefixes a cell that a spreadsheet would evaluate as a formula with a
+// quote, since documents and error messages come from servers that are not trusted.
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
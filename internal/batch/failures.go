@@ -0,0 +1,56 @@
+package batch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FailureBudget is the number of failed domains a batch run tolerates before the run as a
+// whole is considered failed. The zero value tolerates no failures.
+type FailureBudget struct {
+	// Count is the absolute number of failures tolerated; used when Percent is zero.
+	Count int
+	// Percent is the share of domains, from 0 to 100, that may fail.
+	Percent float64
+}
+
+// ParseFailureBudget parses a failure budget given as a count ("3") or as a percentage
+// of the domains in the run ("0.5%").
+func ParseFailureBudget(s string) (FailureBudget, error) {
+	s = strings.TrimSpace(s)
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		percent, err := strconv.ParseFloat(pct, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return FailureBudget{}, fmt.Errorf("invalid failure budget %q: percentage must be between 0%% and 100%%", s)
+		}
+		return FailureBudget{Percent: percent}, nil
+	}
+
+	count, err := strconv.Atoi(s)
+	if err != nil || count < 0 {
+		return FailureBudget{}, fmt.Errorf("invalid failure budget %q: must be a non-negative count or a percentage", s)
+	}
+	return FailureBudget{Count: count}, nil
+}
+
+// Allowed returns the number of failures tolerated in a run of total domains.
+func (b FailureBudget) Allowed(total int) int {
+	if b.Percent > 0 {
+		return int(float64(total) * b.Percent / 100)
+	}
+	return b.Count
+}
+
+// Tolerates reports whether the failures in summary are within the budget.
+func (b FailureBudget) Tolerates(summary Summary) bool {
+	return summary.Failed <= b.Allowed(summary.Total)
+}
+
+// String returns the budget in the form accepted by ParseFailureBudget.
+func (b FailureBudget) String() string {
+	if b.Percent > 0 {
+		return strconv.FormatFloat(b.Percent, 'f', -1, 64) + "%"
+	}
+	return strconv.Itoa(b.Count)
+}
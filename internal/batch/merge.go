@@ -0,0 +1,100 @@
+package batch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadRecords reads a JSON Lines results file and calls fn for each record. Blank lines
+// are ignored. ReadRecords stops and returns the error if fn fails.
+func ReadRecords(r io.Reader, fn func(Record) error) error {
+	scanner := bufio.NewScanner(r)
+	// Records with many origins can be long
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return fmt.Errorf("line %d: invalid record: %w", line, err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// mergeKey identifies the records that describe the same check.
+type mergeKey struct {
+	domain string
+	origin string
+}
+
+// Merger combines the records of several results files, such as the files written by
+// the workers of a sharded scan or by the runs of a resumed scan, keeping one record per
+// domain and caller origin.
+type Merger struct {
+	records map[mergeKey]Record
+	// order holds the keys in the order they were first seen.
+	order []mergeKey
+	// duplicates is the number of records that were replaced or discarded.
+	duplicates int
+}
+
+// NewMerger returns an empty Merger.
+func NewMerger() *Merger {
+	return &Merger{records: make(map[mergeKey]Record)}
+}
+
+// Add adds a record. When a record for the same domain and caller origin was already
+// added, the one that supersedes the other is kept: a checked record supersedes one that
+// was skipped, and otherwise the record with the later timestamp wins. Records with the
+// same timestamp are duplicates of each other, and the first one is kept.
+func (m *Merger) Add(record Record) {
+	key := mergeKey{
+		domain: strings.ToLower(strings.TrimSpace(record.Domain)),
+		origin: record.Origin,
+	}
+	existing, ok := m.records[key]
+	if !ok {
+		m.records[key] = record
+		m.order = append(m.order, key)
+		return
+	}
+
+	m.duplicates++
+	if supersedes(record, existing) {
+		m.records[key] = record
+	}
+}
+
+// supersedes reports whether record should replace existing.
+func supersedes(record, existing Record) bool {
+	if record.Skipped != existing.Skipped {
+		return existing.Skipped
+	}
+	return record.Timestamp.After(existing.Timestamp)
+}
+
+// Records returns the merged records, in the order their domains were first seen.
+func (m *Merger) Records() []Record {
+	records := make([]Record, 0, len(m.order))
+	for _, key := range m.order {
+		records = append(records, m.records[key])
+	}
+	return records
+}
+
+// Duplicates returns the number of records that were merged into another record.
+func (m *Merger) Duplicates() int {
+	return m.duplicates
+}
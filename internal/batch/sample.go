@@ -0,0 +1,38 @@
+package batch
+
+import (
+	"math/rand/v2"
+	"sort"
+)
+
+// Sample returns a random subset of n domains, in their original order. The subset
+// depends only on the list and the seed, so a run with the same list and seed scans the
+// same domains. When n is at least the length of the list, every domain is returned.
+func Sample(domains []string, n int, seed uint64) []string {
+	if n >= len(domains) {
+		return domains
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	// Partially shuffle the indexes and keep the first n
+	// PCG's output is fixed by the seed across Go releases, unlike the global generator
+	rng := rand.New(rand.NewPCG(seed, seed))
+	indexes := make([]int, len(domains))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	for i := 0; i < n; i++ {
+		j := i + rng.IntN(len(indexes)-i)
+		indexes[i], indexes[j] = indexes[j], indexes[i]
+	}
+	chosen := indexes[:n]
+	sort.Ints(chosen)
+
+	sampled := make([]string, n)
+	for i, index := range chosen {
+		sampled[i] = domains[index]
+	}
+	return sampled
+}
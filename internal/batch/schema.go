@@ -0,0 +1,73 @@
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// SchemaVersion is the version of the Record format written to results files. Records
+// without a schema_version were written before it was introduced, and have the same
+// fields as version 1.
+const SchemaVersion = 1
+
+// statuses are the names of the validation statuses a record may have.
+var statuses = map[string]bool{
+	counter.StatusSuccess.String():                               true,
+	counter.StatusBadRelyingPartyIDJSONParseError.String():       true,
+	counter.StatusBadRelyingPartyIDNoJSONMatch.String():          true,
+	counter.StatusBadRelyingPartyIDNoJSONMatchHitLimits.String(): true,
+}
+
+// Normalize validates a record read from a results file, which may have been written by
+// an older version or produced by another tool, and returns it in the form this version
+// writes: the current schema version, a trimmed lowercase domain, a UTC timestamp, and
+// no empty lists. Records from a newer schema version are rejected, since their fields
+// may mean something else.
+func Normalize(record Record) (Record, error) {
+	if record.SchemaVersion > SchemaVersion {
+		return record, fmt.Errorf("schema version %d is newer than the supported version %d", record.SchemaVersion, SchemaVersion)
+	}
+	if record.SchemaVersion < 0 {
+		return record, fmt.Errorf("invalid schema version %d", record.SchemaVersion)
+	}
+	record.SchemaVersion = SchemaVersion
+
+	record.Domain = strings.ToLower(strings.TrimSpace(record.Domain))
+	if record.Domain == "" {
+		return record, errors.New("missing domain")
+	}
+	if record.Timestamp.IsZero() {
+		return record, errors.New("missing timestamp")
+	}
+	record.Timestamp = record.Timestamp.UTC()
+	if record.Count < 0 {
+		return record, fmt.Errorf("invalid label count %d", record.Count)
+	}
+	if record.MaxLabels < 0 {
+		return record, fmt.Errorf("invalid label limit %d", record.MaxLabels)
+	}
+	if record.Status != "" {
+		if !statuses[record.Status] {
+			return record, fmt.Errorf("unknown status %q", record.Status)
+		}
+		if record.Origin == "" {
+			return record, errors.New("status without a caller origin")
+		}
+	}
+	if t := record.Timings; t != nil && (t.FetchMS < 0 || t.ParseMS < 0 || t.ValidateMS < 0) {
+		return record, errors.New("negative timing")
+	}
+	if record.Skipped && record.Error == "" {
+		return record, errors.New("skipped record without an error")
+	}
+
+	for _, list := range []*[]string{&record.Labels, &record.Origins, &record.Warnings} {
+		if len(*list) == 0 {
+			*list = nil
+		}
+	}
+	return record, nil
+}
@@ -0,0 +1,63 @@
+package batch
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Shard selects one of Count deterministic partitions of a domain list, so that several
+// workers can split a scan without coordinating. Index is 1-based.
+type Shard struct {
+	Index int
+	Count int
+}
+
+// ParseShard parses a shard given as "i/n", where i is between 1 and n.
+func ParseShard(s string) (Shard, error) {
+	index, count, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Shard{}, fmt.Errorf("invalid shard %q: expected i/n", s)
+	}
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard %q: expected i/n", s)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 {
+		return Shard{}, fmt.Errorf("invalid shard %q: n must be at least 1", s)
+	}
+	if i < 1 || i > n {
+		return Shard{}, fmt.Errorf("invalid shard %q: i must be between 1 and %d", s, n)
+	}
+	return Shard{Index: i, Count: n}, nil
+}
+
+// Contains reports whether domain belongs to the shard. A domain's shard depends only on
+// the domain itself, not on its position in the list, so every worker agrees on the
+// partition even when their lists are ordered differently.
+func (s Shard) Contains(domain string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(strings.TrimSpace(domain))))
+	return int(h.Sum32()%uint32(s.Count)) == s.Index-1
+}
+
+// Filter returns the domains that belong to the shard, in their original order.
+func (s Shard) Filter(domains []string) []string {
+	var filtered []string
+	for _, domain := range domains {
+		if s.Contains(domain) {
+			filtered = append(filtered, domain)
+		}
+	}
+	return filtered
+}
+
+// String returns the shard in the form accepted by ParseShard.
+func (s Shard) String() string {
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
@@ -0,0 +1,42 @@
+package batch
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// TenantPlaceholder is replaced with each tenant name when a URLTemplate is expanded.
+const TenantPlaceholder = "{tenant}"
+
+// URLTemplate is the URL of a per-tenant .well-known/webauthn document, with
+// TenantPlaceholder where the tenant name goes, such as
+// "https://{tenant}.example.com/.well-known/webauthn" or
+// "https://login.example.com/{tenant}/.well-known/webauthn".
+type URLTemplate string
+
+// ParseURLTemplate parses a URL template. The template must contain TenantPlaceholder
+// and be an http or https URL once it is expanded.
+func ParseURLTemplate(s string) (URLTemplate, error) {
+	if !strings.Contains(s, TenantPlaceholder) {
+		return "", fmt.Errorf("invalid URL template %q: it must contain %s", s, TenantPlaceholder)
+	}
+	t := URLTemplate(s)
+	u, err := url.Parse(strings.ReplaceAll(s, TenantPlaceholder, "tenant"))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL template %q: %w", s, err)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", fmt.Errorf("invalid URL template %q: expected an http or https URL", s)
+	}
+	return t, nil
+}
+
+// Expand returns the URL of tenant's document. Tenant names cannot contain characters
+// that would change which host or path the URL refers to.
+func (t URLTemplate) Expand(tenant string) (string, error) {
+	if tenant == "" || strings.ContainsAny(tenant, "/\\?#@:% \t") {
+		return "", fmt.Errorf("invalid tenant %q", tenant)
+	}
+	return strings.ReplaceAll(string(t), TenantPlaceholder, tenant), nil
+}
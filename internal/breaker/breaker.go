@@ -0,0 +1,155 @@
+// Package breaker provides a per-domain circuit breaker for long-running modes.
+//
+// When a domain fails repeatedly, fetching it again on every request or check wastes
+// resources on both sides. A Breaker opens the circuit for a domain after a number of
+// consecutive failures and refuses further attempts until a cooldown has passed, after
+// which a single trial attempt decides whether the circuit closes again.
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultThreshold is the number of consecutive failures that opens a circuit.
+	DefaultThreshold = 5
+	// DefaultCooldown is how long an open circuit refuses attempts.
+	DefaultCooldown = time.Minute
+)
+
+// ErrCircuitOpen is returned (wrapped) when an attempt is refused because the circuit is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// State is the state of the circuit for one domain.
+type State int
+
+const (
+	// StateClosed indicates that attempts are allowed.
+	StateClosed State = iota
+	// StateOpen indicates that attempts are refused until the cooldown has passed.
+	StateOpen
+	// StateHalfOpen indicates that the cooldown has passed and one trial attempt is allowed.
+	StateHalfOpen
+)
+
+// String returns a string representation of the State.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("UNKNOWN_STATE(%d)", s)
+	}
+}
+
+// circuit is the failure history of one domain.
+type circuit struct {
+	failures int
+	openedAt time.Time
+	// trial is true while the single half-open trial attempt is in flight.
+	trial bool
+}
+
+// Breaker tracks a circuit per domain. It is safe for concurrent use.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// New returns a Breaker that opens a circuit after threshold consecutive failures and
+// keeps it open for cooldown. A threshold of zero or less disables the breaker.
+func New(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+// state returns the state of c. The caller must hold b.mu.
+func (b *Breaker) state(c *circuit) State {
+	if c == nil || c.failures < b.threshold {
+		return StateClosed
+	}
+	if b.now().Sub(c.openedAt) < b.cooldown {
+		return StateOpen
+	}
+	return StateHalfOpen
+}
+
+// State returns the state of the circuit for key.
+func (b *Breaker) State(key string) State {
+	if b == nil || b.threshold <= 0 {
+		return StateClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state(b.circuits[key])
+}
+
+// Allow returns an error wrapping ErrCircuitOpen if an attempt for key must not be made.
+// Once the cooldown has passed, only one trial attempt is allowed until it is reported
+// with Success or Failure.
+func (b *Breaker) Allow(key string) error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[key]
+	switch b.state(c) {
+	case StateOpen:
+		retry := c.openedAt.Add(b.cooldown).Sub(b.now()).Round(time.Second)
+		return fmt.Errorf("%w after %d consecutive failures; retrying in %s", ErrCircuitOpen, c.failures, retry)
+	case StateHalfOpen:
+		if c.trial {
+			return fmt.Errorf("%w after %d consecutive failures; a trial attempt is in progress", ErrCircuitOpen, c.failures)
+		}
+		c.trial = true
+	}
+	return nil
+}
+
+// Success records a successful attempt for key and closes its circuit.
+func (b *Breaker) Success(key string) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.circuits, key)
+}
+
+// Failure records a failed attempt for key, opening its circuit once the threshold of
+// consecutive failures is reached. A failed trial attempt reopens the circuit.
+func (b *Breaker) Failure(key string) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[key]
+	if c == nil {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	c.failures++
+	c.trial = false
+	if c.failures >= b.threshold {
+		c.openedAt = b.now()
+	}
+}
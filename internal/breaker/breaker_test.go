@@ -0,0 +1,75 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestBreaker tests the circuit state transitions.
+func TestBreaker(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	b := New(3, time.Minute)
+	b.now = func() time.Time { return now }
+
+	t.Run("Opens after the threshold", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if err := b.Allow("example.com"); err != nil {
+				t.Fatalf("Attempt %d refused: %v", i, err)
+			}
+			b.Failure("example.com")
+		}
+		if state := b.State("example.com"); state != StateOpen {
+			t.Errorf("Expected %v, got %v", StateOpen, state)
+		}
+		if err := b.Allow("example.com"); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("Expected ErrCircuitOpen, got %v", err)
+		}
+
+		// Other domains are unaffected
+		if err := b.Allow("example.org"); err != nil {
+			t.Errorf("Expected example.org to be allowed, got %v", err)
+		}
+	})
+
+	t.Run("Allows one trial after the cooldown", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		if state := b.State("example.com"); state != StateHalfOpen {
+			t.Errorf("Expected %v, got %v", StateHalfOpen, state)
+		}
+		if err := b.Allow("example.com"); err != nil {
+			t.Fatalf("Expected a trial attempt, got %v", err)
+		}
+		if err := b.Allow("example.com"); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("Expected a second concurrent attempt to be refused, got %v", err)
+		}
+	})
+
+	t.Run("Failed trial reopens the circuit", func(t *testing.T) {
+		b.Failure("example.com")
+		if state := b.State("example.com"); state != StateOpen {
+			t.Errorf("Expected %v, got %v", StateOpen, state)
+		}
+	})
+
+	t.Run("Successful trial closes the circuit", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		if err := b.Allow("example.com"); err != nil {
+			t.Fatalf("Expected a trial attempt, got %v", err)
+		}
+		b.Success("example.com")
+		if state := b.State("example.com"); state != StateClosed {
+			t.Errorf("Expected %v, got %v", StateClosed, state)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		disabled := New(0, time.Minute)
+		for i := 0; i < 10; i++ {
+			disabled.Failure("example.com")
+		}
+		if err := disabled.Allow("example.com"); err != nil {
+			t.Errorf("Expected a disabled breaker to allow attempts, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,254 @@
+// Package browser models how browsers implement related origin requests, so that a
+// document can be checked against each of them: whether the browser supports related
+// origins at all, how many eTLD+1 labels it counts, and what it requires of the
+// response before it reads the origins.
+//
+// The profiles encode the behavior documented by each project at the time of writing.
+// Where a browser documents no limit of its own, its profile follows the WebAuthn
+// specification's defaults.
+package browser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// Profile is the behavior of one browser towards related origin requests.
+type Profile interface {
+	// Name is the name the profile is selected by, such as "chromium".
+	Name() string
+	// Description names the browsers and versions the profile stands for.
+	Description() string
+	// Label is a short form of the description for sentences, such as "Chrome 128+".
+	Label() string
+	// Supported reports whether the browser implements related origin requests.
+	Supported() bool
+	// MaxLabels is the number of distinct eTLD+1 labels the browser counts before it
+	// ignores origins with further labels.
+	MaxLabels() int
+	// Limits are all the constraints the browser enforces on related origin requests.
+	Limits() Limits
+	// Evaluate returns what the browser decides for callerOrigin given the document of
+	// result.
+	Evaluate(callerOrigin string, result *counter.LabelCount) Outcome
+}
+
+// Outcome is what one browser decides for a caller origin.
+type Outcome struct {
+	Browser string
+	// Label is the short description of the profile, as used in sentences.
+	Label string
+	// Supported is false when the browser does not implement related origin requests,
+	// in which case it never consults the document.
+	Supported bool
+	// Refused explains why the browser refuses the response before reading its
+	// origins, if it does.
+	Refused string
+	// Status is the status the browser reaches for the origins of the document.
+	Status counter.AuthenticatorStatus
+}
+
+// Allowed reports whether the browser authorizes the caller origin.
+func (o Outcome) Allowed() bool {
+	return o.Supported && o.Refused == "" && o.Status == counter.StatusSuccess
+}
+
+// String returns the status of the outcome, or why the document was not consulted.
+func (o Outcome) String() string {
+	switch {
+	case !o.Supported:
+		return "UNSUPPORTED (related origin requests are not implemented)"
+	case o.Refused != "":
+		return fmt.Sprintf("REFUSED (%s)", o.Refused)
+	default:
+		return o.Status.String()
+	}
+}
+
+// model is a Profile defined by its limits, evaluated with the algorithm of the
+// WebAuthn specification.
+type model struct {
+	name        string
+	description string
+	label       string
+	supported   bool
+	maxLabels   int
+	// maxBodySize is the largest response the browser reads, or 0 for no limit.
+	maxBodySize int64
+	// jsonContentType is true when the browser refuses a response that is not served
+	// as application/json.
+	jsonContentType bool
+	// redirects is how the browser handles a redirect of the request for the document.
+	redirects RedirectPolicy
+}
+
+func (m model) Name() string        { return m.name }
+func (m model) Description() string { return m.description }
+func (m model) Label() string       { return m.label }
+func (m model) Supported() bool     { return m.supported }
+func (m model) MaxLabels() int      { return m.maxLabels }
+
+// Limits returns the constraints of the model.
+func (m model) Limits() Limits {
+	return Limits{
+		Browser:         m.name,
+		Description:     m.description,
+		Supported:       m.supported,
+		MaxLabels:       m.maxLabels,
+		MaxBodySize:     m.maxBodySize,
+		JSONContentType: m.jsonContentType,
+		Redirects:       m.redirects,
+	}
+}
+
+// Evaluate applies the browser's response constraints, then validates the caller origin
+// against the document's origins with the browser's label limit.
+func (m model) Evaluate(callerOrigin string, result *counter.LabelCount) Outcome {
+	outcome := Outcome{Browser: m.name, Label: m.label, Supported: m.supported}
+	if !m.supported {
+		return outcome
+	}
+
+	// Local files have no content type to check
+	if m.jsonContentType && result.ContentType != "" && !counter.IsJSONContentType(result.ContentType) {
+		outcome.Refused = fmt.Sprintf("served as %s instead of application/json", result.ContentType)
+		return outcome
+	}
+	if m.maxBodySize > 0 && int64(len(result.RawJSON)) > m.maxBodySize {
+		outcome.Refused = fmt.Sprintf("body is larger than %d bytes", m.maxBodySize)
+		return outcome
+	}
+
+	outcome.Status = counter.ValidateWellKnownJSONWithMaxLabels(callerOrigin, []byte(result.RawJSON), m.maxLabels)
+	return outcome
+}
+
+// profiles are the built-in profiles, in the order they are reported.
+var profiles = []Profile{
+	latestChromium(),
+	model{
+		name:            "safari",
+		description:     "Safari 18 and later",
+		label:           "Safari 18+",
+		supported:       true,
+		maxLabels:       counter.MaxLabels,
+		maxBodySize:     counter.MaxBodySize,
+		jsonContentType: true,
+		redirects:       RedirectFollow,
+	},
+	model{
+		name:        "firefox",
+		description: "Firefox, which does not implement related origin requests",
+		label:       "Firefox",
+		supported:   false,
+	},
+	model{
+		name:            "spec",
+		description:     "The WebAuthn Level 3 specification, with its minimum of 5 labels",
+		label:           "the WebAuthn specification",
+		supported:       true,
+		maxLabels:       5,
+		jsonContentType: true,
+		redirects:       RedirectFollow,
+	},
+}
+
+// latestChromium returns the chromium profile of LatestChromium.
+func latestChromium() Profile {
+	p, _ := Chromium(LatestChromium)
+	return p
+}
+
+// Profiles returns the built-in profiles.
+func Profiles() []Profile {
+	return append([]Profile(nil), profiles...)
+}
+
+// Lookup returns the profile named name.
+func Lookup(name string) (Profile, error) {
+	for _, p := range profiles {
+		if p.Name() == strings.ToLower(strings.TrimSpace(name)) {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown browser %q: expected chromium, safari, firefox, spec or all", name)
+}
+
+// Parse returns the profiles named by names, such as the values of a --browser flag.
+// "all" selects every profile. Each profile is returned once, in the order of Profiles.
+func Parse(names []string) ([]Profile, error) {
+	selected := make(map[string]bool)
+	for _, name := range names {
+		if strings.ToLower(strings.TrimSpace(name)) == "all" {
+			return Profiles(), nil
+		}
+		p, err := Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		selected[p.Name()] = true
+	}
+	var parsed []Profile
+	for _, p := range profiles {
+		if selected[p.Name()] {
+			parsed = append(parsed, p)
+		}
+	}
+	return parsed, nil
+}
+
+// Evaluate returns the outcome of each profile for callerOrigin given the document of
+// result, in the order of the profiles.
+func Evaluate(profiles []Profile, callerOrigin string, result *counter.LabelCount) []Outcome {
+	outcomes := make([]Outcome, 0, len(profiles))
+	for _, p := range profiles {
+		outcomes = append(outcomes, p.Evaluate(callerOrigin, result))
+	}
+	return outcomes
+}
+
+// Summarize describes outcomes in a sentence grouped by result, in the order of the
+// outcomes, such as "Works in Chrome 128+ and Safari 18+; unsupported in Firefox".
+func Summarize(outcomes []Outcome) string {
+	var works, rejected, unsupported []string
+	for _, outcome := range outcomes {
+		label := outcome.Label
+		if label == "" {
+			label = outcome.Browser
+		}
+		switch {
+		case !outcome.Supported:
+			unsupported = append(unsupported, label)
+		case outcome.Allowed():
+			works = append(works, label)
+		default:
+			rejected = append(rejected, label)
+		}
+	}
+
+	var parts []string
+	if len(works) > 0 {
+		parts = append(parts, "works in "+joinLabels(works))
+	}
+	if len(rejected) > 0 {
+		parts = append(parts, "fails in "+joinLabels(rejected))
+	}
+	if len(unsupported) > 0 {
+		parts = append(parts, "unsupported in "+joinLabels(unsupported))
+	}
+	if len(parts) == 0 {
+		return "No browsers were evaluated"
+	}
+	sentence := strings.Join(parts, "; ")
+	return strings.ToUpper(sentence[:1]) + sentence[1:]
+}
+
+// joinLabels joins labels as a list in a sentence: "a", "a and b", "a, b and c".
+func joinLabels(labels []string) string {
+	if len(labels) == 1 {
+		return labels[0]
+	}
+	return strings.Join(labels[:len(labels)-1], ", ") + " and " + labels[len(labels)-1]
+}
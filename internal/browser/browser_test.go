@@ -0,0 +1,176 @@
+package browser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// TestEvaluate tests the outcomes of the built-in profiles.
+func TestEvaluate(t *testing.T) {
+	document := `{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://foo.com"]}`
+	all, err := Parse([]string{"all"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	t.Run("Label limit", func(t *testing.T) {
+		outcomes := Evaluate(all, "https://foo.com", &counter.LabelCount{RawJSON: document, ContentType: "application/json"})
+		expected := map[string]string{
+			"chromium": "BAD_RELYING_PARTY_ID_NO_JSON_MATCH_HIT_LIMITS",
+			"safari":   "BAD_RELYING_PARTY_ID_NO_JSON_MATCH_HIT_LIMITS",
+			"firefox":  "UNSUPPORTED (related origin requests are not implemented)",
+			"spec":     "BAD_RELYING_PARTY_ID_NO_JSON_MATCH_HIT_LIMITS",
+		}
+		if len(outcomes) != len(expected) {
+			t.Fatalf("Expected %d outcomes, got %v", len(expected), outcomes)
+		}
+		for _, outcome := range outcomes {
+			if outcome.String() != expected[outcome.Browser] || outcome.Allowed() {
+				t.Errorf("%s: expected %s, got %s", outcome.Browser, expected[outcome.Browser], outcome)
+			}
+		}
+	})
+
+	t.Run("Content type", func(t *testing.T) {
+		chromium, _ := Lookup("chromium")
+		outcome := chromium.Evaluate("https://a.com", &counter.LabelCount{RawJSON: document, ContentType: "text/plain"})
+		if outcome.Allowed() || !strings.HasPrefix(outcome.String(), "REFUSED (served as text/plain") {
+			t.Errorf("Expected the document to be refused, got %s", outcome)
+		}
+		// A local file has no content type
+		if outcome := chromium.Evaluate("https://a.com", &counter.LabelCount{RawJSON: document}); !outcome.Allowed() {
+			t.Errorf("Expected a local file to be allowed, got %s", outcome)
+		}
+	})
+
+	t.Run("Profile label limit", func(t *testing.T) {
+		six := model{name: "six", supported: true, maxLabels: 6}
+		if outcome := six.Evaluate("https://foo.com", &counter.LabelCount{RawJSON: document}); !outcome.Allowed() {
+			t.Errorf("Expected a limit of 6 labels to authorize the sixth label, got %s", outcome)
+		}
+	})
+}
+
+// TestParse tests selecting profiles by name.
+func TestParse(t *testing.T) {
+	profiles, err := Parse([]string{"spec", "Chromium", "chromium"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(profiles) != 2 || profiles[0].Name() != "chromium" || profiles[1].Name() != "spec" {
+		t.Errorf("Expected chromium and spec in order, got %v", profiles)
+	}
+	if _, err := Parse([]string{"netscape"}); err == nil {
+		t.Error("Expected an error for an unknown browser")
+	}
+}
+
+// TestSummarize tests describing outcomes in a sentence.
+func TestSummarize(t *testing.T) {
+	all := Profiles()
+	tests := []struct {
+		name     string
+		document string
+		expected string
+	}{
+		{"Listed", `{"origins": ["https://foo.com"]}`, "Works in Chrome 128+, Safari 18+ and the WebAuthn specification; unsupported in Firefox"},
+		{"Not listed", `{"origins": ["https://bar.com"]}`, "Fails in Chrome 128+, Safari 18+ and the WebAuthn specification; unsupported in Firefox"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcomes := Evaluate(all, "https://foo.com", &counter.LabelCount{RawJSON: tt.document, ContentType: "application/json"})
+			if got := Summarize(outcomes); got != tt.expected {
+				t.Errorf("Summarize() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+
+	chromium, _ := Lookup("chromium")
+	outcomes := Evaluate([]Profile{chromium}, "https://foo.com", &counter.LabelCount{RawJSON: `{"origins": ["https://foo.com"]}`})
+	if got := Summarize(outcomes); got != "Works in Chrome 128+" {
+		t.Errorf("Summarize() = %q for a single browser", got)
+	}
+	if got := Summarize(nil); got != "No browsers were evaluated" {
+		t.Errorf("Summarize(nil) = %q", got)
+	}
+}
+
+// TestChromium tests pinning the chromium profile to a milestone.
+func TestChromium(t *testing.T) {
+	document := &counter.LabelCount{RawJSON: `{"origins": ["https://foo.com"]}`, ContentType: "application/json"}
+	tests := []struct {
+		milestone int
+		allowed   bool
+		version   string
+	}{
+		{0, true, "chromium-128"},
+		{127, false, "chromium-127"},
+		{128, true, "chromium-128"},
+		{135, true, "chromium-135"},
+	}
+	for _, tt := range tests {
+		p, err := Chromium(tt.milestone)
+		if err != nil {
+			t.Fatalf("Chromium(%d) returned an error: %v", tt.milestone, err)
+		}
+		if outcome := p.Evaluate("https://foo.com", document); outcome.Allowed() != tt.allowed {
+			t.Errorf("Chromium(%d): expected allowed=%v, got %s", tt.milestone, tt.allowed, outcome)
+		}
+		if got := ModelVersion(tt.milestone); got != tt.version {
+			t.Errorf("ModelVersion(%d) = %q, expected %q", tt.milestone, got, tt.version)
+		}
+	}
+	if _, err := Chromium(-1); err == nil {
+		t.Error("Expected an error for a negative milestone")
+	}
+
+	pinned, err := WithChromium(Profiles(), 127)
+	if err != nil {
+		t.Fatalf("WithChromium returned an error: %v", err)
+	}
+	if len(pinned) != len(profiles) || pinned[0].Supported() || !profiles[0].Supported() {
+		t.Errorf("Expected only the pinned copy of chromium to be unsupported, got %v", pinned)
+	}
+	pinned, _ = WithChromium(Profiles(), 130)
+	outcomes := Evaluate(pinned[:1], "https://foo.com", document)
+	if got := Summarize(outcomes); got != "Works in Chrome 130" {
+		t.Errorf("Expected the summary to name the pinned milestone, got %q", got)
+	}
+}
+
+// TestStrictest tests combining the limits of the profiles into the strictest ones.
+func TestStrictest(t *testing.T) {
+	var limits []Limits
+	for _, p := range Profiles() {
+		limits = append(limits, p.Limits())
+	}
+	strictest := Strictest(limits)
+	if !strictest.Supported || strictest.MaxLabels != 5 || strictest.MaxBodySize != counter.MaxBodySize ||
+		!strictest.JSONContentType || strictest.Redirects != RedirectError {
+		t.Errorf("Unexpected strictest limits %+v", strictest)
+	}
+	if strictest.Description != "The limits of chromium, safari and spec combined" {
+		t.Errorf("Unexpected description %q", strictest.Description)
+	}
+
+	// Browsers without related origins and without a body limit impose nothing
+	lenient := Strictest([]Limits{
+		{Browser: "a", Supported: true, MaxLabels: 8, Redirects: RedirectFollow},
+		{Browser: "b", Supported: true, MaxLabels: 6, MaxBodySize: 1024, Redirects: RedirectFollow},
+		{Browser: "c", Supported: false, MaxLabels: 1, Redirects: RedirectError},
+	})
+	if lenient.MaxLabels != 6 || lenient.MaxBodySize != 1024 || lenient.JSONContentType || lenient.Redirects != RedirectFollow {
+		t.Errorf("Unexpected strictest limits %+v", lenient)
+	}
+	if Strictest(nil).Supported {
+		t.Error("Expected no support without browsers")
+	}
+
+	for size, expected := range map[int64]string{0: "none", 262144: "256 KiB", 1000: "1000 bytes"} {
+		if got := FormatBodySize(size); got != expected {
+			t.Errorf("FormatBodySize(%d) = %q, expected %q", size, got, expected)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package browser
+
+import (
+	"fmt"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// LatestChromium is the latest Chromium milestone whose handling of related origin
+// requests is modeled. Later milestones are assumed to behave the same.
+const LatestChromium = 128
+
+// chromiumModels are the milestones at which Chromium's handling of related origin
+// requests changed, oldest first. A milestone follows the last model at or before it.
+var chromiumModels = []struct {
+	milestone int
+	supported bool
+}{
+	// Related origin requests are not implemented
+	{milestone: 1, supported: false},
+	// Related origin requests ship, with a limit of 5 labels and 256 KiB responses, and
+	// the request fails on a redirect
+	{milestone: 128, supported: true},
+}
+
+// Chromium returns the chromium profile with the rules of the given milestone, or of
+// LatestChromium if milestone is 0.
+func Chromium(milestone int) (Profile, error) {
+	if milestone == 0 {
+		milestone = LatestChromium
+	}
+	if milestone < 0 {
+		return nil, fmt.Errorf("invalid Chromium version %d", milestone)
+	}
+
+	supported := false
+	for _, m := range chromiumModels {
+		if m.milestone <= milestone {
+			supported = m.supported
+		}
+	}
+	if !supported {
+		return model{
+			name:        "chromium",
+			description: fmt.Sprintf("Chromium %d, before related origin requests shipped in Chromium 128", milestone),
+			label:       fmt.Sprintf("Chrome %d", milestone),
+			supported:   false,
+		}, nil
+	}
+	// Only the latest model stands for the milestones after it
+	description, label := fmt.Sprintf("Chromium %d (Chrome, Edge, Opera)", milestone), fmt.Sprintf("Chrome %d", milestone)
+	if milestone == LatestChromium {
+		description, label = fmt.Sprintf("Chromium %d and later (Chrome, Edge, Opera)", milestone), fmt.Sprintf("Chrome %d+", milestone)
+	}
+	return model{
+		name:            "chromium",
+		description:     description,
+		label:           label,
+		supported:       true,
+		maxLabels:       counter.MaxLabels,
+		maxBodySize:     counter.MaxBodySize,
+		jsonContentType: true,
+		redirects:       RedirectError,
+	}, nil
+}
+
+// ModelVersion names the behavior model of the given Chromium milestone, or of
+// LatestChromium if milestone is 0, such as "chromium-128". Results report it so that
+// they can be reproduced with the same rules.
+func ModelVersion(milestone int) string {
+	if milestone == 0 {
+		milestone = LatestChromium
+	}
+	return fmt.Sprintf("chromium-%d", milestone)
+}
+
+// WithChromium returns profiles with the chromium profile replaced by the one of the
+// given milestone.
+func WithChromium(profiles []Profile, milestone int) ([]Profile, error) {
+	chromium, err := Chromium(milestone)
+	if err != nil {
+		return nil, err
+	}
+	pinned := make([]Profile, len(profiles))
+	for i, p := range profiles {
+		if p.Name() == "chromium" {
+			p = chromium
+		}
+		pinned[i] = p
+	}
+	return pinned, nil
+}
@@ -0,0 +1,94 @@
+package browser
+
+import "fmt"
+
+// RedirectPolicy is how a browser handles a redirect of the request for the
+// .well-known/webauthn document.
+type RedirectPolicy string
+
+const (
+	// RedirectFollow follows redirects, and requires the final response to have status
+	// 200, as the WebAuthn specification does.
+	RedirectFollow RedirectPolicy = "follow"
+	// RedirectError fails the request at the first redirect, so the document must be
+	// served from https://<RP ID>/.well-known/webauthn itself.
+	RedirectError RedirectPolicy = "error"
+)
+
+// Limits are the constraints a browser enforces on related origin requests, for
+// designing a document that every browser accepts.
+type Limits struct {
+	Browser     string `json:"browser"`
+	Description string `json:"description"`
+	// Supported is false when the browser does not implement related origin requests,
+	// in which case it enforces none of the other limits.
+	Supported bool `json:"supported"`
+	// MaxLabels is the number of distinct eTLD+1 labels counted before origins with
+	// further labels are ignored.
+	MaxLabels int `json:"max_labels,omitempty"`
+	// MaxBodySize is the largest response read, in bytes, or 0 for no limit.
+	MaxBodySize int64 `json:"max_body_size"`
+	// JSONContentType is true when a response not served as application/json is refused.
+	JSONContentType bool `json:"json_content_type"`
+	// Redirects is how a redirect of the request is handled.
+	Redirects RedirectPolicy `json:"redirects,omitempty"`
+}
+
+// Strictest returns the strictest combination of the limits of the browsers that
+// support related origin requests: the fewest labels, the smallest body, a JSON content
+// type if any browser requires one, and no redirects if any browser refuses them. A
+// document within these limits is read the same way by every one of them.
+func Strictest(limits []Limits) Limits {
+	strictest := Limits{Browser: "strictest"}
+	var browsers []string
+	for _, l := range limits {
+		if !l.Supported {
+			continue
+		}
+		browsers = append(browsers, l.Browser)
+		strictest.Supported = true
+		if strictest.MaxLabels == 0 || l.MaxLabels < strictest.MaxLabels {
+			strictest.MaxLabels = l.MaxLabels
+		}
+		if l.MaxBodySize > 0 && (strictest.MaxBodySize == 0 || l.MaxBodySize < strictest.MaxBodySize) {
+			strictest.MaxBodySize = l.MaxBodySize
+		}
+		strictest.JSONContentType = strictest.JSONContentType || l.JSONContentType
+		if strictest.Redirects == "" || l.Redirects == RedirectError {
+			strictest.Redirects = l.Redirects
+		}
+	}
+	switch len(browsers) {
+	case 0:
+		strictest.Description = "No browser supports related origin requests"
+	case 1:
+		strictest.Description = fmt.Sprintf("The limits of %s", browsers[0])
+	default:
+		strictest.Description = fmt.Sprintf("The limits of %s combined", joinLabels(browsers))
+	}
+	return strictest
+}
+
+// FormatBodySize describes a body size limit, such as "256 KiB", or "none" for 0.
+func FormatBodySize(size int64) string {
+	switch {
+	case size == 0:
+		return "none"
+	case size%1024 == 0:
+		return fmt.Sprintf("%d KiB", size/1024)
+	default:
+		return fmt.Sprintf("%d bytes", size)
+	}
+}
+
+// String describes the redirect policy for a table, such as "not followed".
+func (p RedirectPolicy) String() string {
+	switch p {
+	case RedirectFollow:
+		return "followed"
+	case RedirectError:
+		return "not followed"
+	default:
+		return string(p)
+	}
+}
@@ -0,0 +1,362 @@
+// Package bundle captures the HTTP responses of a run into a single file, so that an
+// audit can be performed offline, inside networks with no internet egress.
+//
+// A bundle is a gzip-compressed tarball. Its manifest.json lists the domains it was
+// created for and every response received while fetching them, including redirects and
+// failed requests, with their status, headers and the SHA-256 digest of their body.
+// Bodies are stored once per distinct content under bodies/, named by their digest.
+// Replaying a bundle through its Transport serves the recorded responses, so the whole
+// fetch and validation pipeline runs unchanged against them.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SchemaVersion is the version of the manifest format.
+	SchemaVersion = 1
+	// MaxBodySize is the number of bytes of a response body that are captured; the rest
+	// is dropped and the response marked truncated. It is well above the size browsers
+	// read, so that documents too large for them are still reported as such offline.
+	MaxBodySize = 4 << 20
+	// maxManifestSize is the largest manifest read from a bundle.
+	maxManifestSize = 64 << 20
+
+	manifestName = "manifest.json"
+	bodiesDir    = "bodies/"
+)
+
+// ErrNotCaptured is returned when a request is replayed that the bundle has no response for.
+var ErrNotCaptured = errors.New("not captured in the bundle")
+
+// digestPattern matches the name of a body in a bundle.
+var digestPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Response is one captured HTTP exchange.
+type Response struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Time   time.Time   `json:"time"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	// Digest is the hex SHA-256 of the body, or empty if the response had none.
+	Digest    string `json:"digest,omitempty"`
+	Size      int    `json:"size,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+	// Error is why no response was received, such as a connection or TLS failure.
+	Error string `json:"error,omitempty"`
+}
+
+// Manifest describes the contents of a bundle.
+type Manifest struct {
+	SchemaVersion int       `json:"schema_version"`
+	Created       time.Time `json:"created"`
+	// ToolVersion is the version of the tool that created the bundle.
+	ToolVersion string     `json:"tool_version,omitempty"`
+	Domains     []string   `json:"domains"`
+	Responses   []Response `json:"responses"`
+}
+
+// Bundle is a set of captured responses and the bodies they reference.
+type Bundle struct {
+	Manifest
+
+	mu     sync.Mutex
+	bodies map[string][]byte
+}
+
+// New returns an empty bundle for domains, created at the given time by toolVersion.
+func New(domains []string, toolVersion string, at time.Time) *Bundle {
+	return &Bundle{
+		Manifest: Manifest{
+			SchemaVersion: SchemaVersion,
+			Created:       at.UTC(),
+			ToolVersion:   toolVersion,
+			Domains:       domains,
+		},
+		bodies: make(map[string][]byte),
+	}
+}
+
+// add records a response and stores its body once, under its digest.
+func (b *Bundle) add(resp Response, body []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if body != nil {
+		sum := sha256.Sum256(body)
+		resp.Digest = hex.EncodeToString(sum[:])
+		resp.Size = len(body)
+		b.bodies[resp.Digest] = body
+	}
+	b.Responses = append(b.Responses, resp)
+}
+
+// Body returns the captured body of a response.
+func (b *Bundle) Body(resp Response) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bodies[resp.Digest]
+}
+
+// recorder captures every exchange made through a transport into a bundle.
+type recorder struct {
+	bundle *Bundle
+	next   http.RoundTripper
+}
+
+// RoundTrip sends the request with the next transport and captures its response, reading
+// at most MaxBodySize bytes of the body.
+func (r recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	captured := Response{Method: req.Method, URL: req.URL.String(), Time: time.Now().UTC()}
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		captured.Error = err.Error()
+		r.bundle.add(captured, nil)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxBodySize+1))
+	if err != nil {
+		captured.Error = fmt.Sprintf("failed to read response body: %s", err)
+		r.bundle.add(captured, nil)
+		return nil, err
+	}
+	if len(body) > MaxBodySize {
+		body = body[:MaxBodySize]
+		captured.Truncated = true
+	}
+	captured.Status = resp.StatusCode
+	captured.Header = resp.Header.Clone()
+	r.bundle.add(captured, body)
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
+
+// Record returns a transport that sends requests with next and captures every exchange
+// into the bundle.
+func (b *Bundle) Record(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return recorder{bundle: b, next: next}
+}
+
+// Transport returns a transport that answers requests with the responses captured for
+// their method and URL, the first one if there are several, without any network access.
+// A captured failure is returned as an error, and a request that was not captured fails
+// with ErrNotCaptured.
+func (b *Bundle) Transport() http.RoundTripper {
+	return replayer{bundle: b}
+}
+
+// replayer serves the responses of a bundle.
+type replayer struct {
+	bundle *Bundle
+}
+
+// RoundTrip returns the captured response of the request.
+func (r replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	url := req.URL.String()
+	for _, captured := range r.bundle.Responses {
+		if captured.Method != req.Method || captured.URL != url {
+			continue
+		}
+		if captured.Error != "" {
+			return nil, fmt.Errorf("captured failure: %s", captured.Error)
+		}
+		body := r.bundle.Body(captured)
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", captured.Status, http.StatusText(captured.Status)),
+			StatusCode:    captured.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        captured.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, ErrNotCaptured
+}
+
+// Write writes the bundle to w as a gzip-compressed tarball.
+func (b *Bundle) Write(w io.Writer) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	manifest, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	writeFile := func(name string, data []byte) error {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0o644,
+			Size:    int64(len(data)),
+			ModTime: b.Created,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if err := writeFile(manifestName, manifest); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	// Write the bodies in the order of the responses, so that bundles are reproducible
+	written := make(map[string]bool)
+	for _, resp := range b.Responses {
+		if resp.Digest == "" || written[resp.Digest] {
+			continue
+		}
+		written[resp.Digest] = true
+		if err := writeFile(bodiesDir+resp.Digest, b.bodies[resp.Digest]); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// Save writes the bundle to the file at path.
+func (b *Bundle) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	if err := b.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// Read reads a bundle written by Write, and checks that it is complete and untampered:
+// every body must match its digest, and every response must have its body.
+func Read(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	defer gz.Close()
+
+	b := &Bundle{bodies: make(map[string][]byte)}
+	var manifest []byte
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("bundle entry %s is not a regular file", header.Name)
+		}
+
+		name := path.Clean(header.Name)
+		switch {
+		case name == manifestName:
+			if manifest, err = readEntry(tr, header, maxManifestSize); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(name, bodiesDir) && digestPattern.MatchString(strings.TrimPrefix(name, bodiesDir)):
+			data, err := readEntry(tr, header, MaxBodySize)
+			if err != nil {
+				return nil, err
+			}
+			digest := strings.TrimPrefix(name, bodiesDir)
+			if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != digest {
+				return nil, fmt.Errorf("bundle entry %s does not match its digest", header.Name)
+			}
+			b.bodies[digest] = data
+		default:
+			return nil, fmt.Errorf("unexpected bundle entry %s", header.Name)
+		}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("bundle has no %s", manifestName)
+	}
+	if err := json.Unmarshal(manifest, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	if b.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("bundle schema version %d is newer than the supported version %d", b.SchemaVersion, SchemaVersion)
+	}
+	for _, resp := range b.Responses {
+		if _, ok := b.bodies[resp.Digest]; resp.Digest != "" && !ok {
+			return nil, fmt.Errorf("bundle has no body for %s %s", resp.Method, resp.URL)
+		}
+	}
+	return b, nil
+}
+
+// readEntry reads a tar entry of at most limit bytes.
+func readEntry(r io.Reader, header *tar.Header, limit int64) ([]byte, error) {
+	if header.Size > limit {
+		return nil, fmt.Errorf("bundle entry %s is larger than %d bytes", header.Name, limit)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle entry %s: %w", header.Name, err)
+	}
+	return data, nil
+}
+
+// Load reads the bundle in the file at path.
+func Load(path string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Failed returns the number of captured exchanges that received no response.
+func (b *Bundle) Failed() int {
+	n := 0
+	for _, resp := range b.Responses {
+		if resp.Error != "" {
+			n++
+		}
+	}
+	return n
+}
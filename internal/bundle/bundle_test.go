@@ -0,0 +1,88 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// TestRecordAndReplay tests capturing the responses of a fetch, including a redirect,
+// writing and reading the bundle, and fetching again from it.
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/webauthn" {
+			http.Redirect(w, r, "/webauthn.json", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"origins": ["https://example.co.uk", "https://example.de"]}`))
+	}))
+	domain := strings.TrimPrefix(server.URL, "https://")
+
+	b := New([]string{domain}, "1.2.3", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	opts := counter.DefaultOptions()
+	opts.Transport = b.Record(server.Client().Transport)
+	live, err := counter.CountLabelsWithOptions(domain, opts)
+	if err != nil {
+		t.Fatalf("CountLabelsWithOptions returned an error: %v", err)
+	}
+	server.Close()
+	if len(b.Responses) != 2 || b.Responses[0].Status != http.StatusFound || b.Responses[1].Digest == "" {
+		t.Fatalf("Expected the redirect and the document to be captured, got %+v", b.Responses)
+	}
+
+	var buf bytes.Buffer
+	if err := b.Write(&buf); err != nil {
+		t.Fatalf("Write returned an error: %v", err)
+	}
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read returned an error: %v", err)
+	}
+	if read.ToolVersion != "1.2.3" || len(read.Domains) != 1 || len(read.Responses) != 2 {
+		t.Errorf("Unexpected manifest %+v", read.Manifest)
+	}
+
+	// The server is gone, so the document can only come from the bundle
+	opts.Transport = read.Transport()
+	offline, err := counter.CountLabelsWithOptions(domain, opts)
+	if err != nil {
+		t.Fatalf("CountLabelsWithOptions from the bundle returned an error: %v", err)
+	}
+	if offline.Count != live.Count || offline.ContentType != live.ContentType || offline.RawJSON != live.RawJSON {
+		t.Errorf("Offline result %+v differs from the live result %+v", offline, live)
+	}
+
+	if _, err := read.Transport().RoundTrip(httptest.NewRequest(http.MethodGet, "https://other.example/.well-known/webauthn", nil)); !errors.Is(err, ErrNotCaptured) {
+		t.Errorf("Expected ErrNotCaptured for a request that was not captured, got %v", err)
+	}
+}
+
+// TestReadTampered tests that a bundle whose body does not match its digest is refused.
+func TestReadTampered(t *testing.T) {
+	digest := strings.Repeat("0", 64)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range map[string]string{
+		manifestName:       `{"schema_version": 1, "domains": ["example.com"]}`,
+		bodiesDir + digest: `{"origins": []}`,
+	} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(data))
+	}
+	tw.Close()
+	gz.Close()
+
+	if _, err := Read(&buf); err == nil || !strings.Contains(err.Error(), "digest") {
+		t.Errorf("Expected an error about the digest, got %v", err)
+	}
+}
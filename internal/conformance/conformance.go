@@ -0,0 +1,201 @@
+// Package conformance runs the validator against test vectors of how browsers handle
+// related origin requests, and reports how many of them it reproduces.
+//
+// The built-in corpus is transcribed from the cases of Chromium's unit tests for
+// .well-known/webauthn validation and for registrable domains, which decide the status
+// a document yields for a caller origin and the label each entry counts towards. A
+// corpus is JSON:
+//
+//	{
+//	  "validations": [
+//	    {
+//	      "name": "sixth label is ignored",
+//	      "source": "chromium:content/browser/webauth/webauth_request_security_checker_unittest.cc",
+//	      "origin": "https://f.com",
+//	      "document": "{\"origins\": [\"https://a.com\", ..., \"https://f.com\"]}",
+//	      "expected": "BAD_RELYING_PARTY_ID_NO_JSON_MATCH_HIT_LIMITS"
+//	    }
+//	  ],
+//	  "labels": [
+//	    {"origin": "https://foo.appspot.com", "label": "foo"},
+//	    {"origin": "https://co.uk", "label": ""}
+//	  ]
+//	}
+//
+// A label case with an empty label expects the origin to have none, so that browsers
+// skip it.
+package conformance
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// Builtin is the built-in corpus.
+//
+//go:embed corpus.json
+var Builtin []byte
+
+// Corpus is a set of test vectors.
+type Corpus struct {
+	Validations []Validation `json:"validations"`
+	Labels      []Label      `json:"labels"`
+}
+
+// Validation is a test vector for the status a document yields for a caller origin.
+type Validation struct {
+	Name string `json:"name"`
+	// Source names the test the vector comes from.
+	Source string `json:"source,omitempty"`
+	// Origin is the caller origin.
+	Origin string `json:"origin"`
+	// Document is the response body, which need not be valid JSON.
+	Document string `json:"document"`
+	// Expected is the name of the expected status, such as "SUCCESS".
+	Expected string `json:"expected"`
+}
+
+// Label is a test vector for the label an origin counts towards.
+type Label struct {
+	// Source names the test the vector comes from.
+	Source string `json:"source,omitempty"`
+	Origin string `json:"origin"`
+	// Label is the expected label, or "" if the origin has none.
+	Label string `json:"label"`
+}
+
+// Result is the outcome of one test vector.
+type Result struct {
+	// Case describes the vector, such as its name or origin.
+	Case   string
+	Source string
+	// Expected and Got are what the vector expects and what the validator yields.
+	Expected string
+	Got      string
+}
+
+// Passed reports whether the validator yields what the vector expects.
+func (r Result) Passed() bool {
+	return r.Expected == r.Got
+}
+
+// Report is the outcome of a corpus.
+type Report struct {
+	Results []Result
+}
+
+// Passed returns the number of vectors the validator reproduces.
+func (r *Report) Passed() int {
+	passed := 0
+	for _, result := range r.Results {
+		if result.Passed() {
+			passed++
+		}
+	}
+	return passed
+}
+
+// Failures returns the vectors the validator does not reproduce.
+func (r *Report) Failures() []Result {
+	var failures []Result
+	for _, result := range r.Results {
+		if !result.Passed() {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// Parity returns the share of vectors the validator reproduces, as a percentage. An
+// empty corpus has full parity.
+func (r *Report) Parity() float64 {
+	if len(r.Results) == 0 {
+		return 100
+	}
+	return 100 * float64(r.Passed()) / float64(len(r.Results))
+}
+
+// statuses are the statuses a validation vector may expect.
+var statuses = []counter.AuthenticatorStatus{
+	counter.StatusSuccess,
+	counter.StatusBadRelyingPartyIDJSONParseError,
+	counter.StatusBadRelyingPartyIDNoJSONMatch,
+	counter.StatusBadRelyingPartyIDNoJSONMatchHitLimits,
+}
+
+// Load reads a corpus file.
+func Load(path string) (*Corpus, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corpus: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses a corpus, rejecting vectors that expect an unknown status.
+func Parse(data []byte) (*Corpus, error) {
+	var c Corpus
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse corpus: %w", err)
+	}
+	for i, v := range c.Validations {
+		known := false
+		for _, status := range statuses {
+			known = known || v.Expected == status.String()
+		}
+		if !known {
+			return nil, fmt.Errorf("failed to parse corpus: validations[%d] expects unknown status %q", i, v.Expected)
+		}
+	}
+	return &c, nil
+}
+
+// Run runs the validator against every vector of the corpus.
+func Run(c *Corpus) *Report {
+	report := &Report{}
+	for _, v := range c.Validations {
+		report.Results = append(report.Results, Result{
+			Case:     v.Name,
+			Source:   v.Source,
+			Expected: v.Expected,
+			Got:      counter.ValidateWellKnownJSON(v.Origin, []byte(v.Document)).String(),
+		})
+	}
+	for _, l := range c.Labels {
+		label, _ := counter.OriginLabel(l.Origin)
+		report.Results = append(report.Results, Result{
+			Case:     "label of " + l.Origin,
+			Source:   l.Source,
+			Expected: quoteLabel(l.Label),
+			Got:      quoteLabel(label),
+		})
+	}
+	return report
+}
+
+// quoteLabel returns label as it is reported, with "(none)" for an origin without one.
+func quoteLabel(label string) string {
+	if label == "" {
+		return "(none)"
+	}
+	return fmt.Sprintf("%q", label)
+}
+
+// Format returns a plain text summary of a report, listing the vectors the validator
+// does not reproduce.
+func Format(r *Report) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Parity: %d of %d cases (%.1f%%)\n", r.Passed(), len(r.Results), r.Parity()))
+	for _, failure := range r.Failures() {
+		sb.WriteString(fmt.Sprintf("FAIL %s: expected %s, got %s\n", failure.Case, failure.Expected, failure.Got))
+		if failure.Source != "" {
+			sb.WriteString(fmt.Sprintf("  Source: %s\n", failure.Source))
+		}
+	}
+	return sb.String()
+}
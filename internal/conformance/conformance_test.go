@@ -0,0 +1,71 @@
+package conformance
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestBuiltin tests that the validator reproduces every vector of the built-in corpus.
+func TestBuiltin(t *testing.T) {
+	corpus, err := Parse(Builtin)
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+	if len(corpus.Validations) == 0 || len(corpus.Labels) == 0 {
+		t.Fatalf("Expected validation and label vectors, got %d and %d", len(corpus.Validations), len(corpus.Labels))
+	}
+
+	report := Run(corpus)
+	if len(report.Results) != len(corpus.Validations)+len(corpus.Labels) {
+		t.Errorf("Expected a result per vector, got %d", len(report.Results))
+	}
+	if failures := report.Failures(); len(failures) > 0 {
+		t.Errorf("Expected full parity, got:\n%s", Format(report))
+	}
+}
+
+// TestRun tests reporting vectors the validator does not reproduce.
+func TestRun(t *testing.T) {
+	corpus, err := Parse([]byte(`{
+		"validations": [
+			{"name": "match", "origin": "https://a.com", "document": "{\"origins\": [\"https://a.com\"]}", "expected": "SUCCESS"},
+			{"name": "wrong", "source": "test", "origin": "https://b.com", "document": "{\"origins\": [\"https://a.com\"]}", "expected": "SUCCESS"}
+		],
+		"labels": [
+			{"origin": "https://www.a.co.uk", "label": "a"},
+			{"origin": "https://co.uk", "label": "co"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+
+	report := Run(corpus)
+	if report.Passed() != 2 || report.Parity() != 50 {
+		t.Errorf("Expected 2 of 4 vectors to pass, got %d (%.1f%%)", report.Passed(), report.Parity())
+	}
+	formatted := Format(report)
+	for _, want := range []string{
+		"Parity: 2 of 4 cases (50.0%)",
+		"FAIL wrong: expected SUCCESS, got BAD_RELYING_PARTY_ID_NO_JSON_MATCH\n  Source: test",
+		`FAIL label of https://co.uk: expected "co", got (none)`,
+	} {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Expected the report to contain %q, got:\n%s", want, formatted)
+		}
+	}
+
+	if empty := Run(&Corpus{}); empty.Parity() != 100 {
+		t.Errorf("Expected an empty corpus to have full parity, got %.1f%%", empty.Parity())
+	}
+}
+
+// TestParse tests rejecting corpora that cannot be run.
+func TestParse(t *testing.T) {
+	if _, err := Parse([]byte(`{"validations": [{"name": "x", "expected": "ALLOWED"}]}`)); err == nil || !strings.Contains(err.Error(), `unknown status "ALLOWED"`) {
+		t.Errorf("Expected an error for an unknown status, got %v", err)
+	}
+	if _, err := Parse([]byte(`{"validations": {}}`)); err == nil {
+		t.Error("Expected an error for a malformed corpus")
+	}
+}
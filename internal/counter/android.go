@@ -0,0 +1,68 @@
+package counter
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// AndroidOriginPrefix starts the origin of an Android app, which FIDO clients on Android
+// use as the caller origin of an app instead of a web origin. It is followed by the
+// SHA-256 hash of the certificate the app is signed with, in unpadded base64url, such
+// as android:apk-key-hash:z4v3V2Hh0Iz0wZ9b4w3dG1jqXb1gE9ruB9mmFeUu3xY.
+const AndroidOriginPrefix = "android:apk-key-hash:"
+
+// androidScheme is the scheme Android app origins are compared under, with the hash of
+// their certificate in place of a host.
+const androidScheme = "android:apk-key-hash"
+
+// IsAndroidOrigin reports whether origin is written as an Android app origin, whether or
+// not its hash is valid.
+func IsAndroidOrigin(origin string) bool {
+	return strings.HasPrefix(origin, AndroidOriginPrefix)
+}
+
+// ParseAndroidOrigin returns the SHA-256 certificate hash of an Android app origin. It
+// returns an error if origin is not an Android app origin, or its hash is not 32 bytes
+// in unpadded base64url.
+func ParseAndroidOrigin(origin string) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(origin, AndroidOriginPrefix)
+	if !ok {
+		return nil, fmt.Errorf("%q is not an Android app origin: it does not start with %s", origin, AndroidOriginPrefix)
+	}
+	if strings.ContainsAny(encoded, "+/=") {
+		return nil, fmt.Errorf("invalid Android app origin %q: the hash must be unpadded base64url, without +, / or =", origin)
+	}
+	hash, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Android app origin %q: the hash is not base64url: %w", origin, err)
+	}
+	if len(hash) != 32 {
+		return nil, fmt.Errorf("invalid Android app origin %q: the hash is %d bytes, not the 32 of a SHA-256 hash", origin, len(hash))
+	}
+	return hash, nil
+}
+
+// AndroidOrigin returns the origin of the Android app signed with the certificate whose
+// SHA-256 fingerprint is given in hex, with or without colons, as keytool and apksigner
+// print it.
+func AndroidOrigin(fingerprint string) (string, error) {
+	hash, err := hex.DecodeString(strings.ReplaceAll(fingerprint, ":", ""))
+	if err != nil {
+		return "", fmt.Errorf("invalid certificate fingerprint %q: %w", fingerprint, err)
+	}
+	if len(hash) != 32 {
+		return "", fmt.Errorf("invalid certificate fingerprint %q: it is %d bytes, not the 32 of a SHA-256 fingerprint", fingerprint, len(hash))
+	}
+	return AndroidOriginPrefix + base64.RawURLEncoding.EncodeToString(hash), nil
+}
+
+// parseAndroidOrigin returns the scheme and hash an Android app origin is compared by.
+func parseAndroidOrigin(origin string) (scheme, hash string, err error) {
+	decoded, err := ParseAndroidOrigin(origin)
+	if err != nil {
+		return "", "", err
+	}
+	return androidScheme, base64.RawURLEncoding.EncodeToString(decoded), nil
+}
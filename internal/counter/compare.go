@@ -0,0 +1,125 @@
+package counter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Comparison contrasts how a browser treats two caller origins against the same
+// .well-known/webauthn document, entry by entry.
+type Comparison struct {
+	// Origins is the origins array of the document, or nil if it cannot be parsed.
+	Origins []string
+	A, B    *Explanation
+	// ReasonA and ReasonB explain why the document authorizes or rejects each caller
+	// origin: the entry that matched it, or the nearest it came to a match.
+	ReasonA, ReasonB string
+	// Differences are how the caller origins themselves differ: scheme, host, port and
+	// label.
+	Differences []string
+}
+
+// Compare explains both caller origins against a .well-known/webauthn document and
+// contrasts the two decisions.
+func Compare(originA, originB string, jsonData []byte) *Comparison {
+	return CompareWithMaxLabels(originA, originB, jsonData, MaxLabels)
+}
+
+// CompareWithMaxLabels is like Compare but counts at most maxLabels unique labels instead
+// of MaxLabels. A maxLabels that is not positive means MaxLabels.
+func CompareWithMaxLabels(originA, originB string, jsonData []byte, maxLabels int) *Comparison {
+	c := &Comparison{
+		A: ExplainWithMaxLabels(originA, jsonData, maxLabels),
+		B: ExplainWithMaxLabels(originB, jsonData, maxLabels),
+	}
+	var webAuthnResp WebAuthnResponse
+	if err := json.Unmarshal(jsonData, &webAuthnResp); err == nil {
+		c.Origins = webAuthnResp.Origins
+	}
+	c.ReasonA = reason(c.A, c.Origins)
+	c.ReasonB = reason(c.B, c.Origins)
+	c.Differences = callerDifferences(originA, originB)
+	return c
+}
+
+// reason explains the status of an explanation in a sentence.
+func reason(e *Explanation, origins []string) string {
+	if e.Status == StatusBadRelyingPartyIDJSONParseError {
+		return "the document cannot be parsed, so no caller origin is authorized"
+	}
+	callerURL, err := parseCallerURL(e.CallerOrigin)
+	if err != nil {
+		return "the caller origin cannot be parsed"
+	}
+
+	if e.Status == StatusSuccess {
+		for i, outcome := range e.Entries {
+			if outcome != EntryMatch {
+				continue
+			}
+			if callerURL.Scheme == androidScheme {
+				return fmt.Sprintf("authorized by origins[%d] %s, an Android app origin, which has no label", i, origins[i])
+			}
+			originURL, _ := url.Parse(origins[i])
+			label, _ := getLabel(originURL.Host)
+			for position, l := range e.Labels {
+				if l == label {
+					return fmt.Sprintf("authorized by origins[%d] %s, whose label %q is label %d of %d", i, origins[i], label, position+1, e.MaxLabels)
+				}
+			}
+		}
+	}
+
+	// Find the entry that comes closest to the caller: the same origin ignored because of
+	// the label limit, or the same host with another scheme or port
+	nearest := -1
+	for i, originStr := range origins {
+		originURL, err := url.Parse(originStr)
+		if err != nil || originURL.Host == "" {
+			continue
+		}
+		originURL = canonical(originURL)
+		if originURL.Scheme == callerURL.Scheme && originURL.Host == callerURL.Host && e.Entries[i] == EntryIgnored {
+			label, _ := getLabel(originURL.Host)
+			return fmt.Sprintf("listed at origins[%d] %s, but its label %q would be label %d of %d, so browsers ignore it", i, originStr, label, e.MaxLabels+1, e.MaxLabels)
+		}
+		if nearest == -1 && originURL.Hostname() == callerURL.Hostname() {
+			nearest = i
+		}
+	}
+	if nearest != -1 {
+		originURL, _ := url.Parse(origins[nearest])
+		return fmt.Sprintf("not listed; the nearest entry, origins[%d] %s, does not match: %s", nearest, origins[nearest], mismatch(canonical(originURL), callerURL))
+	}
+	if e.Status == StatusBadRelyingPartyIDNoJSONMatchHitLimits {
+		return "not listed among the counted entries, and entries were ignored because of the label limit"
+	}
+	return "not listed in the document"
+}
+
+// callerDifferences describes how two caller origins differ.
+func callerDifferences(originA, originB string) []string {
+	a, errA := parseCallerURL(originA)
+	b, errB := parseCallerURL(originB)
+	if errA != nil || errB != nil {
+		return nil
+	}
+
+	var differences []string
+	if a.Scheme != b.Scheme {
+		differences = append(differences, fmt.Sprintf("scheme: %q vs %q", a.Scheme, b.Scheme))
+	}
+	if a.Hostname() != b.Hostname() {
+		differences = append(differences, fmt.Sprintf("host: %q vs %q", a.Hostname(), b.Hostname()))
+	}
+	if a.Port() != b.Port() {
+		differences = append(differences, fmt.Sprintf("port: %q vs %q", a.Port(), b.Port()))
+	}
+	labelA, _ := getLabel(a.Host)
+	labelB, _ := getLabel(b.Host)
+	if labelA != labelB {
+		differences = append(differences, fmt.Sprintf("label: %q vs %q", labelA, labelB))
+	}
+	return differences
+}
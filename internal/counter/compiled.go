@@ -0,0 +1,127 @@
+package counter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// CompiledWellKnown is a .well-known/webauthn document that has been parsed and indexed
+// once, so that many caller origins can be validated against it with a single map lookup.
+// It is intended for services that cache a relying party's document and validate every
+// caller against it. A CompiledWellKnown is immutable and safe for concurrent use.
+type CompiledWellKnown struct {
+	// authorized maps the origins whose labels fall within MaxLabels, as scheme://host keys,
+	// to the first entry of the document that authorizes them.
+	authorized map[string]string
+	// labels are the unique eTLD+1 labels that were counted, in document order.
+	labels []string
+	// hitLimits is true when some origin was ignored because MaxLabels was reached.
+	hitLimits bool
+}
+
+// originKey returns the key used to index an origin by scheme and host.
+func originKey(scheme, host string) string {
+	return scheme + "://" + host
+}
+
+// Compile parses and indexes a .well-known/webauthn document. It returns an error when
+// the document is not valid JSON or has no origins array, which is the case in which
+// ValidateWellKnownJSON returns StatusBadRelyingPartyIDJSONParseError.
+func Compile(jsonData []byte) (*CompiledWellKnown, error) {
+	return CompileWithMaxLabels(jsonData, MaxLabels)
+}
+
+// CompileWithMaxLabels is like Compile but counts at most maxLabels unique labels instead
+// of MaxLabels, as ValidateWellKnownJSONWithMaxLabels does. A maxLabels that is not
+// positive means MaxLabels.
+func CompileWithMaxLabels(jsonData []byte, maxLabels int) (*CompiledWellKnown, error) {
+	maxLabels = labelLimit(maxLabels)
+
+	// Parse the JSON
+	var webAuthnResp WebAuthnResponse
+	if err := json.Unmarshal(jsonData, &webAuthnResp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	// Check if the origins array exists
+	if webAuthnResp.Origins == nil {
+		return nil, errors.New("failed to parse JSON: missing origins array")
+	}
+
+	compiled := &CompiledWellKnown{
+		authorized: make(map[string]string, len(webAuthnResp.Origins)),
+	}
+	uniqueLabels := make(map[string]bool)
+
+	// Index the origins in document order, applying the label limit as a browser would
+	for _, originStr := range webAuthnResp.Origins {
+		origin := lookupOrigin(originStr)
+		// Android app origins have no label, so they never count towards the limit
+		if origin.android {
+			key := originKey(origin.scheme, origin.host)
+			if _, ok := compiled.authorized[key]; !ok {
+				compiled.authorized[key] = originStr
+			}
+			continue
+		}
+		if !origin.ok {
+			continue
+		}
+
+		if !uniqueLabels[origin.label] {
+			if len(uniqueLabels) >= maxLabels {
+				compiled.hitLimits = true
+				continue
+			}
+			uniqueLabels[origin.label] = true
+			compiled.labels = append(compiled.labels, origin.label)
+		}
+
+		key := originKey(origin.scheme, origin.host)
+		if _, ok := compiled.authorized[key]; !ok {
+			compiled.authorized[key] = originStr
+		}
+	}
+
+	return compiled, nil
+}
+
+// Labels returns the unique eTLD+1 labels counted towards MaxLabels, in document order.
+func (c *CompiledWellKnown) Labels() []string {
+	return append([]string(nil), c.labels...)
+}
+
+// HitLimits reports whether some origins were ignored because the document has more than
+// MaxLabels unique labels.
+func (c *CompiledWellKnown) HitLimits() bool {
+	return c.hitLimits
+}
+
+// Validate reports whether the caller origin is authorized by the compiled document.
+// It returns the same status as ValidateWellKnownJSON would for the original document.
+func (c *CompiledWellKnown) Validate(callerOrigin string) AuthenticatorStatus {
+	// Parse the caller origin
+	scheme, host, err := parseCallerOrigin(callerOrigin)
+	if err != nil {
+		return StatusBadRelyingPartyIDNoJSONMatch
+	}
+
+	if _, ok := c.authorized[originKey(scheme, host)]; ok {
+		return StatusSuccess
+	}
+	if c.hitLimits {
+		return StatusBadRelyingPartyIDNoJSONMatchHitLimits
+	}
+	return StatusBadRelyingPartyIDNoJSONMatch
+}
+
+// MatchedOrigin returns the entry of the document that authorizes the caller origin, as
+// it is written in the document, or "" if the caller origin is not authorized.
+func (c *CompiledWellKnown) MatchedOrigin(callerOrigin string) string {
+	scheme, host, err := parseCallerOrigin(callerOrigin)
+	if err != nil {
+		return ""
+	}
+	return c.authorized[originKey(scheme, host)]
+}
@@ -3,9 +3,9 @@ package counter
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -16,16 +16,67 @@ import (
 )
 
 const (
-	// MaxLabels is the maximum number of unique labels allowed in a .well-known/webauthn endpoint.
+	// MaxLabels is the maximum number of unique labels allowed in a .well-known/webauthn endpoint,
+	// as the WebAuthn specification and browsers define it.
 	MaxLabels = 5
 	// WellKnownPath is the path to the .well-known/webauthn endpoint.
 	WellKnownPath = "/.well-known/webauthn"
-	// MaxBodySize is the maximum size of the response body in bytes.
+	// MaxBodySize is the maximum size of the response body in bytes that a browser will accept.
 	MaxBodySize = 1 << 18 // 256KB
 	// Timeout is the timeout for the HTTP request.
 	Timeout = 10 * time.Second
 )
 
+// Options configures how .well-known/webauthn documents are fetched and read.
+type Options struct {
+	// Timeout is the timeout for the HTTP request. If not positive, Timeout is used.
+	Timeout time.Duration
+	// MaxBodySize is the maximum number of bytes read from the response body or file.
+	// Bodies larger than this are truncated and reported with a warning. If not positive,
+	// MaxBodySize is used.
+	MaxBodySize int64
+	// Transport is the HTTP transport used for requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+	// ContentTypePolicy controls which content types are accepted. The default is ContentTypeStrict.
+	ContentTypePolicy ContentTypePolicy
+	// MaxLabels is the number of unique labels a document may have before it exceeds the
+	// limit. If zero, MaxLabels is used.
+	MaxLabels int
+}
+
+// DefaultOptions returns the Options used by CountLabels and CountLabelsFromFile.
+func DefaultOptions() Options {
+	return Options{
+		Timeout:     Timeout,
+		MaxBodySize: MaxBodySize,
+		MaxLabels:   MaxLabels,
+	}
+}
+
+// labelLimit returns maxLabels, or MaxLabels if maxLabels is not positive.
+func labelLimit(maxLabels int) int {
+	if maxLabels <= 0 {
+		return MaxLabels
+	}
+	return maxLabels
+}
+
+// bodyLimit returns maxBodySize, or MaxBodySize if maxBodySize is not positive.
+func bodyLimit(maxBodySize int64) int64 {
+	if maxBodySize <= 0 {
+		return MaxBodySize
+	}
+	return maxBodySize
+}
+
+// requestTimeout returns timeout, or Timeout if timeout is not positive.
+func requestTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return Timeout
+	}
+	return timeout
+}
+
 // AuthenticatorStatus represents the status of a WebAuthn authentication request.
 type AuthenticatorStatus int
 
@@ -67,31 +118,65 @@ type LabelCount struct {
 	UniqueLabels map[string]bool
 	Count        int
 	ExceedsLimit bool
-	LabelsFound  []string
-	ErrorMessage string
-	RawJSON      string
+	// MaxLabels is the label limit the count was checked against. If zero, MaxLabels was used.
+	MaxLabels   int
+	LabelsFound []string
+	// Origins are the origins listed in the document, in document order.
+	Origins []string
+	// AndroidOrigins are the valid Android app origins listed in the document, in
+	// document order. They have no label, so they are not counted.
+	AndroidOrigins []string
+	ErrorMessage   string
+	// ParseError locates the error when the document is not valid JSON.
+	ParseError  *ParseError
+	RawJSON     string
+	Warnings    []string
+	Remediation string
+	// ContentType is the Content-Type header the document was served with, if fetched over HTTP.
+	ContentType string
+	// SniffedFormat is the format detected from the body itself, such as "json" or "html".
+	SniffedFormat string
+	// Timings are how long fetching and parsing the document took.
+	Timings Timings
 }
 
-// getLabel extracts the eTLD+1 label from a domain using the publicsuffix package.
-// This mirrors the behavior of net::registry_controlled_domains::GetDomainAndRegistry in Chromium.
-func getLabel(domain string) (string, error) {
-	// Find the first dot in the eTLD+1
-	dotIndex := strings.Index(domain, ".")
-	if dotIndex == -1 {
-		// If there's no dot, domain isn't valid and we don't care
-		return domain, errors.New("Skip Domain not valid")
+// Timings are how long each stage of checking a document took.
+type Timings struct {
+	// Fetch is the time spent fetching the document and reading its body, or reading
+	// the file.
+	Fetch time.Duration
+	// Parse is the time spent parsing the JSON and counting its labels.
+	Parse time.Duration
+}
+
+// getLabel extracts the eTLD+1 label of the host of an origin, such as "example" for
+// test.example.co.uk:8443. This mirrors Chromium, which takes the registrable domain of
+// the host with net::registry_controlled_domains::GetDomainAndRegistry, including private
+// registries, and counts the part of it before the first dot.
+func getLabel(host string) (string, error) {
+	// Compare host names as Chromium canonicalizes them: without a port or a trailing dot,
+	// and in lowercase
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
 	}
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
 
-	// Get the eTLD+1 using the publicsuffix package
-	tld, _ := publicsuffix.PublicSuffix(domain)
+	// IP addresses and public suffixes have no registrable domain
+	if host == "" || net.ParseIP(host) != nil {
+		return "", fmt.Errorf("%q has no registrable domain", host)
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return "", fmt.Errorf("%q has no registrable domain: %w", host, err)
+	}
 
-	// Extract the label (the part before the first dot)
-	label := strings.TrimSuffix(domain, tld)
+	label, _, _ := strings.Cut(domain, ".")
 	return label, nil
 }
 
-// CountLabels fetches the .well-known/webauthn endpoint for the given domain and counts the unique labels.
-func CountLabels(domain string) (*LabelCount, error) {
+// WellKnownURL builds the .well-known/webauthn URL for the given domain.
+// The domain may be a bare host name or a URL; https is assumed when no scheme is given.
+func WellKnownURL(domain string) (string, error) {
 	// Ensure domain is properly formatted
 	if !strings.HasPrefix(domain, "https://") && !strings.HasPrefix(domain, "http://") {
 		domain = "https://" + domain
@@ -100,18 +185,71 @@ func CountLabels(domain string) (*LabelCount, error) {
 	// Parse the domain to ensure it's valid
 	parsedURL, err := url.Parse(domain)
 	if err != nil {
-		return nil, fmt.Errorf("invalid domain: %w", err)
+		return "", fmt.Errorf("invalid domain: %w", err)
 	}
 
 	// Construct the well-known URL
-	wellKnownURL := parsedURL.Scheme + "://" + parsedURL.Host + WellKnownPath
+	return parsedURL.Scheme + "://" + parsedURL.Host + WellKnownPath, nil
+}
+
+// readBody reads at most opts.MaxBodySize bytes from r, or MaxBodySize if it is not positive.
+// It returns warnings when the body is larger than a browser would accept or was truncated.
+func readBody(r io.Reader, opts Options) ([]byte, []string, error) {
+	opts.MaxBodySize = bodyLimit(opts.MaxBodySize)
+	// Read one byte past the limit so that oversized bodies can be detected
+	body, err := io.ReadAll(io.LimitReader(r, opts.MaxBodySize+1))
+	if err != nil {
+		return nil, nil, err
+	}
 
+	var warnings []string
+	truncated := int64(len(body)) > opts.MaxBodySize
+
+	// Warn when a browser would refuse the body, regardless of the configured limit
+	if len(body) > MaxBodySize {
+		size := fmt.Sprintf("%d bytes", len(body))
+		if truncated {
+			size = fmt.Sprintf("more than %d bytes", opts.MaxBodySize)
+		}
+		warnings = append(warnings, fmt.Sprintf("body is %s and would be truncated by browser, which accepts at most %d bytes", size, MaxBodySize))
+	}
+
+	if truncated {
+		body = body[:opts.MaxBodySize]
+		if opts.MaxBodySize != MaxBodySize {
+			warnings = append(warnings, fmt.Sprintf("body exceeds the configured maximum of %d bytes and was truncated", opts.MaxBodySize))
+		}
+	}
+
+	return body, warnings, nil
+}
+
+// CountLabels fetches the .well-known/webauthn endpoint for the given domain and counts the unique labels.
+func CountLabels(domain string) (*LabelCount, error) {
+	return CountLabelsWithOptions(domain, DefaultOptions())
+}
+
+// CountLabelsWithOptions is like CountLabels but fetches the endpoint using the given options.
+func CountLabelsWithOptions(domain string, opts Options) (*LabelCount, error) {
+	wellKnownURL, err := WellKnownURL(domain)
+	if err != nil {
+		return nil, err
+	}
+	return CountLabelsFromURLWithOptions(wellKnownURL, opts)
+}
+
+// CountLabelsFromURLWithOptions is like CountLabelsWithOptions but fetches the document
+// from wellKnownURL exactly as given, for documents that are not served at the
+// .well-known/webauthn path of their host, such as per-tenant documents behind a shared host.
+func CountLabelsFromURLWithOptions(wellKnownURL string, opts Options) (*LabelCount, error) {
 	// Create a client with a timeout
 	client := &http.Client{
-		Timeout: Timeout,
+		Timeout:   requestTimeout(opts.Timeout),
+		Transport: opts.Transport,
 	}
 
 	// Make the request
+	start := time.Now()
 	resp, err := client.Get(wellKnownURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch well-known URL: %w", err)
@@ -123,46 +261,77 @@ func CountLabels(domain string) (*LabelCount, error) {
 		return &LabelCount{
 			URL:          wellKnownURL,
 			ErrorMessage: fmt.Sprintf("HTTP request failed with status code: %d", resp.StatusCode),
-		}, nil
-	}
-
-	// Check if the content type is JSON
-	contentType := resp.Header.Get("Content-Type")
-	if !strings.Contains(contentType, "application/json") {
-		return &LabelCount{
-			URL:          wellKnownURL,
-			ErrorMessage: fmt.Sprintf("unexpected content type: %s", contentType),
+			Timings:      Timings{Fetch: time.Since(start)},
 		}, nil
 	}
 
 	// Read the response body with a size limit
-	bodyReader := io.LimitReader(resp.Body, MaxBodySize)
-	body, err := io.ReadAll(bodyReader)
+	body, warnings, err := readBody(resp.Body, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
+	timings := Timings{Fetch: time.Since(start)}
+
+	// Compare the declared content type with what the body actually contains
+	contentType := resp.Header.Get("Content-Type")
+	sniffedFormat := SniffFormat(body)
+	problem, remediation, warning := checkContentType(contentType, sniffedFormat, opts.ContentTypePolicy)
+	if warning != "" {
+		warnings = append(warnings, warning)
+	}
+	if problem != "" {
+		return &LabelCount{
+			URL:           wellKnownURL,
+			ErrorMessage:  problem,
+			Remediation:   remediation,
+			Warnings:      warnings,
+			ContentType:   contentType,
+			SniffedFormat: sniffedFormat,
+			Timings:       timings,
+		}, nil
+	}
 
 	// Store the raw JSON
 	rawJSON := string(body)
 
 	// Parse the JSON
+	parseStart := time.Now()
 	var webAuthnResp WebAuthnResponse
 	if err := json.Unmarshal(body, &webAuthnResp); err != nil {
+		timings.Parse = time.Since(parseStart)
 		return &LabelCount{
-			URL:          wellKnownURL,
-			ErrorMessage: fmt.Sprintf("failed to parse JSON: %s", err),
-			RawJSON:      rawJSON,
+			URL:           wellKnownURL,
+			ErrorMessage:  fmt.Sprintf("failed to parse JSON: %s", parseError(body, err)),
+			ParseError:    LocateParseError(body, err),
+			RawJSON:       rawJSON,
+			Warnings:      warnings,
+			ContentType:   contentType,
+			SniffedFormat: sniffedFormat,
+			Timings:       timings,
 		}, nil
 	}
 
 	// Count unique labels
 	result := &LabelCount{
-		URL:          wellKnownURL,
-		UniqueLabels: make(map[string]bool),
-		RawJSON:      rawJSON,
+		URL:           wellKnownURL,
+		UniqueLabels:  make(map[string]bool),
+		Origins:       webAuthnResp.Origins,
+		RawJSON:       rawJSON,
+		Warnings:      warnings,
+		ContentType:   contentType,
+		SniffedFormat: sniffedFormat,
+		Timings:       timings,
 	}
 
 	for _, originStr := range webAuthnResp.Origins {
+		// Android app origins have no label, but are reported apart from web origins
+		if IsAndroidOrigin(originStr) {
+			if _, err := ParseAndroidOrigin(originStr); err == nil {
+				result.AndroidOrigins = append(result.AndroidOrigins, originStr)
+			}
+			continue
+		}
+
 		originURL, err := url.Parse(originStr)
 		if err != nil {
 			continue
@@ -187,7 +356,9 @@ func CountLabels(domain string) (*LabelCount, error) {
 	}
 
 	result.Count = len(result.UniqueLabels)
-	result.ExceedsLimit = result.Count > MaxLabels
+	result.MaxLabels = labelLimit(opts.MaxLabels)
+	result.ExceedsLimit = result.Count > result.MaxLabels
+	result.Timings.Parse = time.Since(parseStart)
 
 	return result, nil
 }
@@ -197,70 +368,27 @@ func CountLabels(domain string) (*LabelCount, error) {
 // It checks if the caller origin is in the list of authorized origins in the .well-known/webauthn file.
 // It also enforces a limit on the number of unique eTLD+1 labels (MaxLabels) that can be processed.
 // If the limit is reached before finding the caller origin, it returns StatusBadRelyingPartyIDNoJSONMatchHitLimits.
+// Callers that validate the same caller origin repeatedly should use a Validator instead.
 func ValidateWellKnownJSON(callerOrigin string, jsonData []byte) AuthenticatorStatus {
-	// Parse the JSON
-	var webAuthnResp WebAuthnResponse
-	if err := json.Unmarshal(jsonData, &webAuthnResp); err != nil {
-		return StatusBadRelyingPartyIDJSONParseError
-	}
-
-	// Check if the origins array exists
-	if webAuthnResp.Origins == nil {
-		return StatusBadRelyingPartyIDJSONParseError
-	}
-
-	// Parse the caller origin
-	callerURL, err := url.Parse(callerOrigin)
-	if err != nil {
-		return StatusBadRelyingPartyIDNoJSONMatch
-	}
-
-	// Count unique labels and check if the caller origin is authorized
-	uniqueLabels := make(map[string]bool)
-	hitLimits := false
-
-	for _, originStr := range webAuthnResp.Origins {
-		originURL, err := url.Parse(originStr)
-		if err != nil {
-			continue
-		}
-
-		// Extract the domain
-		domain := originURL.Host
-		if domain == "" {
-			continue
-		}
-
-		// Extract the eTLD+1 label using publicsuffix package
-		etldPlus1Label, err := getLabel(domain)
-		if err != nil {
-			// Skip this origin if we can't extract the label
-			continue
-		}
-
-		if !uniqueLabels[etldPlus1Label] {
-			if len(uniqueLabels) >= MaxLabels {
-				hitLimits = true
-				continue
-			}
-			uniqueLabels[etldPlus1Label] = true
-		}
-
-		// Check if the origin matches the caller origin
-		if originURL.Scheme == callerURL.Scheme && originURL.Host == callerURL.Host {
-			return StatusSuccess
-		}
-	}
+	return NewValidator(callerOrigin).Validate(jsonData)
+}
 
-	if hitLimits {
-		return StatusBadRelyingPartyIDNoJSONMatchHitLimits
-	}
-	return StatusBadRelyingPartyIDNoJSONMatch
+// ValidateWellKnownJSONWithMaxLabels is like ValidateWellKnownJSON but counts at most
+// maxLabels unique labels instead of MaxLabels. A maxLabels that is not positive means
+// MaxLabels.
+func ValidateWellKnownJSONWithMaxLabels(callerOrigin string, jsonData []byte, maxLabels int) AuthenticatorStatus {
+	return NewValidatorWithMaxLabels(callerOrigin, maxLabels).Validate(jsonData)
 }
 
 // CountLabelsFromFile reads a JSON file and counts the unique labels.
 func CountLabelsFromFile(filePath string) (*LabelCount, error) {
+	return CountLabelsFromFileWithOptions(filePath, DefaultOptions())
+}
+
+// CountLabelsFromFileWithOptions is like CountLabelsFromFile but reads the file using the given options.
+func CountLabelsFromFileWithOptions(filePath string, opts Options) (*LabelCount, error) {
 	// Open the file
+	start := time.Now()
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
@@ -268,22 +396,27 @@ func CountLabelsFromFile(filePath string) (*LabelCount, error) {
 	defer file.Close()
 
 	// Read the file content with a size limit
-	bodyReader := io.LimitReader(file, MaxBodySize)
-	body, err := io.ReadAll(bodyReader)
+	body, warnings, err := readBody(file, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
+	timings := Timings{Fetch: time.Since(start)}
 
 	// Store the raw JSON
 	rawJSON := string(body)
 
 	// Parse the JSON
+	parseStart := time.Now()
 	var webAuthnResp WebAuthnResponse
 	if err := json.Unmarshal(body, &webAuthnResp); err != nil {
+		timings.Parse = time.Since(parseStart)
 		return &LabelCount{
 			URL:          filePath,
-			ErrorMessage: fmt.Sprintf("failed to parse JSON: %s", err),
+			ErrorMessage: fmt.Sprintf("failed to parse JSON: %s", parseError(body, err)),
+			ParseError:   LocateParseError(body, err),
 			RawJSON:      rawJSON,
+			Warnings:     warnings,
+			Timings:      timings,
 		}, nil
 	}
 
@@ -291,10 +424,21 @@ func CountLabelsFromFile(filePath string) (*LabelCount, error) {
 	result := &LabelCount{
 		URL:          filePath,
 		UniqueLabels: make(map[string]bool),
+		Origins:      webAuthnResp.Origins,
 		RawJSON:      rawJSON,
+		Warnings:     warnings,
+		Timings:      timings,
 	}
 
 	for _, originStr := range webAuthnResp.Origins {
+		// Android app origins have no label, but are reported apart from web origins
+		if IsAndroidOrigin(originStr) {
+			if _, err := ParseAndroidOrigin(originStr); err == nil {
+				result.AndroidOrigins = append(result.AndroidOrigins, originStr)
+			}
+			continue
+		}
+
 		originURL, err := url.Parse(originStr)
 		if err != nil {
 			continue
@@ -320,7 +464,9 @@ func CountLabelsFromFile(filePath string) (*LabelCount, error) {
 	}
 
 	result.Count = len(result.UniqueLabels)
-	result.ExceedsLimit = result.Count > MaxLabels
+	result.MaxLabels = labelLimit(opts.MaxLabels)
+	result.ExceedsLimit = result.Count > result.MaxLabels
+	result.Timings.Parse = time.Since(parseStart)
 
 	return result, nil
 }
@@ -328,15 +474,28 @@ func CountLabelsFromFile(filePath string) (*LabelCount, error) {
 // FormatResults formats the label count results into a human-readable string.
 func FormatResults(result *LabelCount) string {
 	if result.ErrorMessage != "" {
-		return fmt.Sprintf("Error: %s\nURL: %s", result.ErrorMessage, result.URL)
+		output := fmt.Sprintf("Error: %s\nURL: %s", result.ErrorMessage, result.URL)
+		if result.ParseError != nil {
+			output += "\n" + FormatSnippet(result.ParseError)
+		}
+		if result.Remediation != "" {
+			output += fmt.Sprintf("\nRemediation: %s", result.Remediation)
+		}
+		for _, warning := range result.Warnings {
+			output += fmt.Sprintf("\nWarning: %s", warning)
+		}
+		return output
 	}
 
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("URL: %s\n", result.URL))
+	for _, warning := range result.Warnings {
+		sb.WriteString(fmt.Sprintf("Warning: %s\n", warning))
+	}
 	sb.WriteString(fmt.Sprintf("Unique labels found: %d\n", result.Count))
 
 	if result.ExceedsLimit {
-		sb.WriteString(fmt.Sprintf("WARNING: The number of unique labels exceeds the maximum limit of %d!\n", MaxLabels))
+		sb.WriteString(fmt.Sprintf("WARNING: The number of unique labels exceeds the maximum limit of %d!\n", labelLimit(result.MaxLabels)))
 	}
 
 	sb.WriteString("Labels found:\n")
@@ -344,5 +503,12 @@ func FormatResults(result *LabelCount) string {
 		sb.WriteString(fmt.Sprintf("- %s\n", label))
 	}
 
+	if len(result.AndroidOrigins) > 0 {
+		sb.WriteString(fmt.Sprintf("Android app origins found: %d (not counted as labels)\n", len(result.AndroidOrigins)))
+		for _, origin := range result.AndroidOrigins {
+			sb.WriteString(fmt.Sprintf("- %s\n", origin))
+		}
+	}
+
 	return sb.String()
 }
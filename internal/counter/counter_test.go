@@ -1,11 +1,14 @@
 package counter
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestCountLabels(t *testing.T) {
@@ -18,9 +21,9 @@ func TestCountLabels(t *testing.T) {
 			w.Write([]byte(`{
 				"origins": [
 					"https://example.com",
-					"https://test.example.org",
-					"https://another.example.net",
-					"https://subdomain.example.com"
+					"https://login.test.org",
+					"https://another.net",
+					"https://www.subdomain.co.uk"
 				]
 			}`))
 		}))
@@ -39,13 +42,13 @@ func TestCountLabels(t *testing.T) {
 		if result.ExceedsLimit {
 			t.Errorf("Expected ExceedsLimit to be false, got true")
 		}
-		if !result.UniqueLabels["test.example."] {
+		if !result.UniqueLabels["test"] {
 			t.Errorf("Expected label 'test' to be in UniqueLabels")
 		}
-		if !result.UniqueLabels["another.example."] {
+		if !result.UniqueLabels["another"] {
 			t.Errorf("Expected label 'another' to be in UniqueLabels")
 		}
-		if !result.UniqueLabels["subdomain.example."] {
+		if !result.UniqueLabels["subdomain"] {
 			t.Errorf("Expected label 'subdomain' to be in UniqueLabels")
 		}
 	})
@@ -58,12 +61,12 @@ func TestCountLabels(t *testing.T) {
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte(`{
 				"origins": [
-					"https://one.example.com",
-					"https://two.example.org",
-					"https://three.example.net",
-					"https://four.example.io",
-					"https://five.example.co",
-					"https://six.example.dev"
+					"https://www.one.com",
+					"https://login.two.org",
+					"https://three.net",
+					"https://four.io",
+					"https://five.co",
+					"https://six.dev"
 				]
 			}`))
 		}))
@@ -82,7 +85,7 @@ func TestCountLabels(t *testing.T) {
 		if !result.ExceedsLimit {
 			t.Errorf("Expected ExceedsLimit to be true, got false")
 		}
-		if !result.UniqueLabels["four.example."] {
+		if !result.UniqueLabels["four"] {
 			t.Errorf("Expected label 'four' to be in UniqueLabels")
 		}
 	})
@@ -115,7 +118,7 @@ func TestCountLabels(t *testing.T) {
 		if result.ExceedsLimit {
 			t.Errorf("Expected ExceedsLimit to be false, got true")
 		}
-		expectedLabels := []string{"one.thing.", "one.anotherthing."}
+		expectedLabels := []string{"thing", "anotherthing"}
 		for _, label := range expectedLabels {
 			if !result.UniqueLabels[label] {
 				t.Errorf("Expected label %s to be in UniqueLabels", label)
@@ -198,6 +201,191 @@ func TestCountLabels(t *testing.T) {
 	})
 }
 
+// TestCountLabelsSPAFallback tests that HTML served at the well-known path is reported as an SPA fallback.
+func TestCountLabelsSPAFallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    string
+		remediation bool
+	}{
+		{
+			name:        "HTML page with doctype",
+			contentType: "text/html; charset=utf-8",
+			body:        "\n<!DOCTYPE html><html><head><title>App</title></head><body><div id=\"root\"></div></body></html>",
+			expected:    "SPA fallback page",
+			remediation: true,
+		},
+		{
+			name:        "HTML page without content type",
+			contentType: "",
+			body:        "<html><body>App</body></html>",
+			expected:    "SPA fallback page",
+			remediation: true,
+		},
+		{
+			name:        "Plain text body",
+			contentType: "text/plain",
+			body:        "not found",
+			expected:    "unexpected content type",
+			remediation: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			result, err := CountLabels(server.URL)
+			if err != nil {
+				t.Fatalf("CountLabels returned an error: %v", err)
+			}
+			if !contains(result.ErrorMessage, tt.expected) {
+				t.Errorf("Expected error message to contain %q, got %s", tt.expected, result.ErrorMessage)
+			}
+			if (result.Remediation != "") != tt.remediation {
+				t.Errorf("Expected remediation present = %v, got %q", tt.remediation, result.Remediation)
+			}
+		})
+	}
+}
+
+// TestCountLabelsContentTypeMismatch tests that mismatches between the declared and actual content are reported distinctly.
+func TestCountLabelsContentTypeMismatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    string
+		sniffed     string
+	}{
+		{
+			name:        "JSON served as text/plain",
+			contentType: "text/plain; charset=utf-8",
+			body:        `{"origins": ["https://example.com"]}`,
+			expected:    "JSON document served as text/plain",
+			sniffed:     FormatJSON,
+		},
+		{
+			name:        "HTML served as application/json",
+			contentType: "application/json",
+			body:        `<!DOCTYPE html><html><body></body></html>`,
+			expected:    "HTML document served as application/json",
+			sniffed:     FormatHTML,
+		},
+		{
+			name:        "Empty body served as application/json",
+			contentType: "application/json",
+			body:        "",
+			expected:    "empty body served as application/json",
+			sniffed:     FormatEmpty,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			result, err := CountLabels(server.URL)
+			if err != nil {
+				t.Fatalf("CountLabels returned an error: %v", err)
+			}
+			if !contains(result.ErrorMessage, tt.expected) {
+				t.Errorf("Expected error message to contain %q, got %s", tt.expected, result.ErrorMessage)
+			}
+			if result.SniffedFormat != tt.sniffed {
+				t.Errorf("Expected sniffed format %q, got %q", tt.sniffed, result.SniffedFormat)
+			}
+		})
+	}
+}
+
+// TestCountLabelsMalformedJSON tests that a malformed document served as JSON, which does not
+// start like one, is reported as a parse error rather than as a content type mismatch.
+func TestCountLabelsMalformedJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("origins: [\"https://example.com\"]\n"))
+	}))
+	defer server.Close()
+
+	result, err := CountLabels(server.URL)
+	if err != nil {
+		t.Fatalf("CountLabels returned an error: %v", err)
+	}
+	if !strings.HasPrefix(result.ErrorMessage, "failed to parse JSON:") {
+		t.Errorf("Expected a parse error, got %q", result.ErrorMessage)
+	}
+	if result.ParseError == nil || result.ParseError.Line != 1 || result.ParseError.Column != 1 {
+		t.Errorf("Expected the parse error at line 1, column 1, got %+v", result.ParseError)
+	}
+	if result.RawJSON == "" || result.SniffedFormat != FormatText {
+		t.Errorf("Expected the body to be kept and sniffed as %q, got %q and %q", FormatText, result.RawJSON, result.SniffedFormat)
+	}
+}
+
+// TestContentTypePolicy tests that the content type policy controls acceptance of JSON served as text/plain.
+func TestContentTypePolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"origins": ["https://example.com", "https://example.org"]}`))
+	}))
+	defer server.Close()
+
+	// Test case 1: Strict policy rejects the document
+	t.Run("Strict", func(t *testing.T) {
+		result, err := CountLabelsWithOptions(server.URL, DefaultOptions())
+		if err != nil {
+			t.Fatalf("CountLabelsWithOptions returned an error: %v", err)
+		}
+		if !contains(result.ErrorMessage, "content type mismatch") {
+			t.Errorf("Expected a content type mismatch error, got %s", result.ErrorMessage)
+		}
+	})
+
+	// Test case 2: Lenient policy accepts the document with a warning
+	t.Run("Lenient", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.ContentTypePolicy = ContentTypeLenient
+
+		result, err := CountLabelsWithOptions(server.URL, opts)
+		if err != nil {
+			t.Fatalf("CountLabelsWithOptions returned an error: %v", err)
+		}
+		if result.ErrorMessage != "" {
+			t.Fatalf("Expected no error message, got %s", result.ErrorMessage)
+		}
+		if result.Count != 1 {
+			t.Errorf("Expected 1 unique label, got %d", result.Count)
+		}
+		if len(result.Warnings) != 1 || !contains(result.Warnings[0], "lenient") {
+			t.Errorf("Expected a lenient policy warning, got %v", result.Warnings)
+		}
+	})
+
+	// Test case 3: Strict matching ignores parameters but not suffixes
+	t.Run("Media type matching", func(t *testing.T) {
+		if !IsJSONContentType("Application/JSON; charset=utf-8") {
+			t.Errorf("Expected application/json with parameters to be accepted")
+		}
+		if IsJSONContentType("application/json-seq") {
+			t.Errorf("Expected application/json-seq to be rejected")
+		}
+	})
+}
+
 func TestFormatResults(t *testing.T) {
 	// Test case 1: Successful result
 	t.Run("Successful result", func(t *testing.T) {
@@ -237,7 +425,24 @@ func TestFormatResults(t *testing.T) {
 		}
 	})
 
-	// Test case 3: Error result
+	// Test case 3: Result exceeding a configured limit
+	t.Run("Result exceeding configured limit", func(t *testing.T) {
+		result := &LabelCount{
+			URL:          "https://example.com/.well-known/webauthn",
+			UniqueLabels: map[string]bool{"one": true, "two": true, "three": true},
+			Count:        3,
+			ExceedsLimit: true,
+			MaxLabels:    2,
+			LabelsFound:  []string{"one", "two", "three"},
+		}
+
+		output := FormatResults(result)
+		if !contains(output, "exceeds the maximum limit of 2!") {
+			t.Errorf("Expected output to name the limit of 2, got %s", output)
+		}
+	})
+
+	// Test case 4: Error result
 	t.Run("Error result", func(t *testing.T) {
 		result := &LabelCount{
 			URL:          "https://example.com/.well-known/webauthn",
@@ -254,6 +459,122 @@ func TestFormatResults(t *testing.T) {
 	})
 }
 
+// TestGetLabel tests label extraction against the behavior of Chromium, which counts the
+// part of the registrable domain (GetDomainAndRegistry, including private registries)
+// before its first dot.
+func TestGetLabel(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		// Subdomains share the label of their registrable domain
+		{"example.com", "example"},
+		{"www.example.com", "example"},
+		{"test.example.org", "example"},
+		{"a.b.c.example.co.uk", "example"},
+		{"example.co.uk", "example"},
+		// Private registries count as public suffixes
+		{"foo.appspot.com", "foo"},
+		{"bar.foo.github.io", "foo"},
+		// Ports, case and a trailing dot do not change the label
+		{"example.com:8443", "example"},
+		{"WWW.Example.COM", "example"},
+		{"www.example.com.", "example"},
+		// Internationalized domain names are compared in their ASCII form
+		{"xn--fiqs8s.xn--fiqz9s", "xn--fiqs8s"},
+		// Hosts on unlisted top-level domains count their second-level domain
+		{"www.foo.unlisted", "foo"},
+		// IP addresses, public suffixes and single labels have no registrable domain
+		{"192.168.0.1", ""},
+		{"[::1]:8443", ""},
+		{"co.uk", ""},
+		{"appspot.com", ""},
+		{"localhost", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			label, err := getLabel(tt.host)
+			if tt.expected == "" {
+				if err == nil {
+					t.Errorf("getLabel(%q) = %q, want an error", tt.host, label)
+				}
+				return
+			}
+			if err != nil || label != tt.expected {
+				t.Errorf("getLabel(%q) = %q, %v; want %q", tt.host, label, err, tt.expected)
+			}
+		})
+	}
+}
+
+// TestOriginChanges tests reporting what browsers change in an origin before comparing it.
+func TestOriginChanges(t *testing.T) {
+	tests := []struct {
+		origin     string
+		serialized string
+		changes    []string
+	}{
+		{"https://foo.com", "https://foo.com", nil},
+		{"https://foo.com:8443", "https://foo.com:8443", nil},
+		{"https://foo.com/login", "https://foo.com", nil},
+		{"https://Foo.com:443", "https://foo.com", []string{`host "Foo.com" is not lowercase`, "port 443 is the default port of https"}},
+		{"HTTP://foo.com:80", "http://foo.com", []string{`scheme "HTTP" is not lowercase`, "port 80 is the default port of http"}},
+		{"https://[::1]:443", "https://[::1]", []string{"port 443 is the default port of https"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			serialized, err := SerializeOrigin(tt.origin)
+			if err != nil || serialized != tt.serialized {
+				t.Errorf("SerializeOrigin(%q) = %q, %v; want %q", tt.origin, serialized, err, tt.serialized)
+			}
+			if changes := OriginChanges(tt.origin); strings.Join(changes, "; ") != strings.Join(tt.changes, "; ") {
+				t.Errorf("OriginChanges(%q) = %v, want %v", tt.origin, changes, tt.changes)
+			}
+		})
+	}
+	if _, err := SerializeOrigin("foo.com"); err == nil {
+		t.Error("Expected an error for an origin without a host")
+	}
+}
+
+// TestAndroidOrigin tests parsing Android app origins and deriving them from certificate
+// fingerprints.
+func TestAndroidOrigin(t *testing.T) {
+	const origin = "android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI"
+	tests := []struct {
+		origin string
+		valid  bool
+	}{
+		{origin, true},
+		{origin + "=", false},
+		{"android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI+", false},
+		{"android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpu", false},
+		{"android:apk-key-hash:", false},
+		{"https://foo.com", false},
+	}
+	for _, tt := range tests {
+		if _, err := ParseAndroidOrigin(tt.origin); (err == nil) != tt.valid {
+			t.Errorf("ParseAndroidOrigin(%q) error = %v, want valid %v", tt.origin, err, tt.valid)
+		}
+	}
+
+	for _, fingerprint := range []string{
+		"06:29:84:32:E8:06:6B:29:E2:22:3B:CC:23:AA:95:04:B5:6A:E5:08:FA:BF:34:35:50:88:69:B9:C3:19:0E:22",
+		"06298432e8066b29e2223bcc23aa9504b56ae508fabf3435508869b9c3190e22",
+	} {
+		if got, err := AndroidOrigin(fingerprint); err != nil || got != origin {
+			t.Errorf("AndroidOrigin(%q) = %q, %v; want %q", fingerprint, got, err, origin)
+		}
+	}
+	if _, err := AndroidOrigin("06:29:84"); err == nil {
+		t.Error("Expected an error for a fingerprint that is not SHA-256")
+	}
+	if serialized, err := SerializeOrigin(origin); err != nil || serialized != origin {
+		t.Errorf("SerializeOrigin(%q) = %q, %v", origin, serialized, err)
+	}
+}
+
 // TestValidateWellKnownJSON tests the ValidateWellKnownJSON function.
 func TestValidateWellKnownJSON(t *testing.T) {
 	tests := []struct {
@@ -346,6 +667,72 @@ func TestValidateWellKnownJSON(t *testing.T) {
 			json:         `{"origins": ["https://foo.co.uk", "https://foo.de", "https://foo.in", "https://foo.net", "https://foo.org", "https://foo.com"]}`,
 			expected:     StatusSuccess,
 		},
+		{
+			name:         "Origin with the default port",
+			callerOrigin: "https://foo.com",
+			json:         `{"origins": ["https://foo.com:443"]}`,
+			expected:     StatusSuccess,
+		},
+		{
+			name:         "Caller origin with the default port",
+			callerOrigin: "http://foo.com:80",
+			json:         `{"origins": ["http://foo.com"]}`,
+			expected:     StatusSuccess,
+		},
+		{
+			name:         "Origin with another port",
+			callerOrigin: "https://foo.com",
+			json:         `{"origins": ["https://foo.com:8443"]}`,
+			expected:     StatusBadRelyingPartyIDNoJSONMatch,
+		},
+		{
+			name:         "Origin and caller origin in mixed case",
+			callerOrigin: "HTTPS://Foo.com",
+			json:         `{"origins": ["https://FOO.COM"]}`,
+			expected:     StatusSuccess,
+		},
+		{
+			name:         "Caller origin with a trailing slash",
+			callerOrigin: "https://foo.com/",
+			json:         `{"origins": ["https://foo.com"]}`,
+			expected:     StatusSuccess,
+		},
+		{
+			name:         "Caller origin with a path",
+			callerOrigin: "https://foo.com/login",
+			json:         `{"origins": ["https://foo.com"]}`,
+			expected:     StatusBadRelyingPartyIDNoJSONMatch,
+		},
+		{
+			name:         "Android app origin",
+			callerOrigin: "android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI",
+			json:         `{"origins": ["https://foo.com", "android:apk-key-hash:2SmKENGwc1g33EvYXaxkGw887yekfl1TpU8vP1svz_o", "android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI"]}`,
+			expected:     StatusSuccess,
+		},
+		{
+			name:         "Android app origin beyond the label limit",
+			callerOrigin: "android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI",
+			json:         `{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://f.com", "android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI"]}`,
+			expected:     StatusSuccess,
+		},
+		{
+			name:         "Android app origin not listed",
+			callerOrigin: "android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI",
+			json:         `{"origins": ["https://foo.com", "android:apk-key-hash:2SmKENGwc1g33EvYXaxkGw887yekfl1TpU8vP1svz_o"]}`,
+			expected:     StatusBadRelyingPartyIDNoJSONMatch,
+		},
+		{
+			name:         "Android app origin with a padded hash",
+			callerOrigin: "android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI",
+			json:         `{"origins": ["android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI="]}`,
+			expected:     StatusBadRelyingPartyIDNoJSONMatch,
+		},
+		{
+			name:         "Web caller and an Android app origin",
+			callerOrigin: "https://foo.com",
+			json:         `{"origins": ["android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI"]}`,
+			expected:     StatusBadRelyingPartyIDNoJSONMatch,
+		},
 	}
 
 	for _, tt := range tests {
@@ -354,6 +741,26 @@ func TestValidateWellKnownJSON(t *testing.T) {
 			if result != tt.expected {
 				t.Errorf("ValidateWellKnownJSON(%q, %q) = %v, want %v", tt.callerOrigin, tt.json, result, tt.expected)
 			}
+
+			// A compiled document must agree with direct validation
+			compiled, err := Compile([]byte(tt.json))
+			if tt.expected == StatusBadRelyingPartyIDJSONParseError {
+				if err == nil {
+					t.Errorf("Compile(%q) succeeded, want an error", tt.json)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.json, err)
+			}
+			if result := compiled.Validate(tt.callerOrigin); result != tt.expected {
+				t.Errorf("CompiledWellKnown.Validate(%q) for %q = %v, want %v", tt.callerOrigin, tt.json, result, tt.expected)
+			}
+
+			// So must the explanation of the decision
+			if explained := Explain(tt.callerOrigin, []byte(tt.json)); explained.Status != tt.expected {
+				t.Errorf("Explain(%q, %q).Status = %v, want %v", tt.callerOrigin, tt.json, explained.Status, tt.expected)
+			}
 		})
 	}
 }
@@ -365,7 +772,9 @@ func TestCountLabelsFromFile(t *testing.T) {
 		"origins": [
 			"https://example.com",
 			"https://test.example.org",
-			"https://another.example.net"
+			"https://another.example.net",
+			"android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI",
+			"android:apk-key-hash:not-a-hash"
 		]
 	}`
 	validFile, err := os.CreateTemp("", "valid-*.json")
@@ -402,17 +811,23 @@ func TestCountLabelsFromFile(t *testing.T) {
 		}
 
 		// Check the results
-		if result.Count != 3 {
-			t.Errorf("Expected 3 unique label, got %d", result.Count)
+		if result.Count != 1 {
+			t.Errorf("Expected 1 unique label, got %d", result.Count)
 		}
 		if result.ExceedsLimit {
 			t.Errorf("Expected ExceedsLimit to be false, got true")
 		}
-		// With our implementation, we expect the eTLD+1 labels to be "example", "example", and "example"
-		// But since they're the same, we'll only have one unique label
-		if !result.UniqueLabels["example."] {
+		// The eTLD+1 labels of all three origins are "example", so there is one unique label
+		if !result.UniqueLabels["example"] {
 			t.Errorf("Expected label 'example' to be in UniqueLabels")
 		}
+		// Android app origins are reported apart, and only if valid
+		if len(result.AndroidOrigins) != 1 {
+			t.Errorf("Expected 1 Android app origin, got %v", result.AndroidOrigins)
+		}
+		if result.Timings.Fetch <= 0 || result.Timings.Parse <= 0 {
+			t.Errorf("Expected reading and parsing to be timed, got %+v", result.Timings)
+		}
 	})
 
 	// Test case 2: Invalid JSON file
@@ -429,9 +844,29 @@ func TestCountLabelsFromFile(t *testing.T) {
 		if !contains(result.ErrorMessage, "parse JSON") {
 			t.Errorf("Expected error message to contain 'parse JSON', got %s", result.ErrorMessage)
 		}
+		// The trailing comma is found before the missing brace
+		if result.ParseError == nil || result.ParseError.Line != 5 || !contains(result.ParseError.Hint, "trailing comma on line 4") {
+			t.Errorf("Expected the trailing comma to be located, got %+v", result.ParseError)
+		}
+	})
+
+	// Test case 3: Valid JSON that does not match the schema
+	t.Run("Wrong types", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "webauthn.json")
+		if err := os.WriteFile(path, []byte(`{"origins": ["https://example.com", null, 42]}`), 0o644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		result, err := CountLabelsFromFile(path)
+		if err != nil {
+			t.Fatalf("CountLabelsFromFile returned an error: %v", err)
+		}
+		expected := "failed to parse JSON: /origins/1: expected string, got null; /origins/2: expected string, got integer"
+		if result.ErrorMessage != expected {
+			t.Errorf("Expected error message %q, got %q", expected, result.ErrorMessage)
+		}
 	})
 
-	// Test case 3: Non-existent file
+	// Test case 4: Non-existent file
 	t.Run("Non-existent file", func(t *testing.T) {
 		_, err := CountLabelsFromFile("non-existent-file.json")
 		if err == nil {
@@ -441,9 +876,606 @@ func TestCountLabelsFromFile(t *testing.T) {
 			t.Errorf("Expected error message to contain 'failed to open file', got %s", err.Error())
 		}
 	})
+
+	// Test case 5: Zero-value options read the file with the default limits
+	t.Run("Zero-value options", func(t *testing.T) {
+		result, err := CountLabelsFromFileWithOptions(validFile.Name(), Options{})
+		if err != nil {
+			t.Fatalf("CountLabelsFromFileWithOptions returned an error: %v", err)
+		}
+		if result.ErrorMessage != "" || result.Count != 1 {
+			t.Errorf("Expected the whole file to be read, got error %q and %d labels", result.ErrorMessage, result.Count)
+		}
+	})
+}
+
+// TestCountLabelsWithOptions tests the CountLabelsWithOptions function.
+func TestCountLabelsWithOptions(t *testing.T) {
+	// Build a valid document that is larger than a browser will accept
+	padding := strings.Repeat(" ", MaxBodySize)
+	largeJSON := `{"origins": ["https://example.com"]` + padding + `}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(largeJSON))
+	}))
+	defer server.Close()
+
+	// Test case 1: Body larger than the browser limit is reported
+	t.Run("Body exceeds browser limit", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.MaxBodySize = int64(len(largeJSON))
+
+		result, err := CountLabelsWithOptions(server.URL, opts)
+		if err != nil {
+			t.Fatalf("CountLabelsWithOptions returned an error: %v", err)
+		}
+		if result.ErrorMessage != "" {
+			t.Fatalf("Expected no error message, got %s", result.ErrorMessage)
+		}
+		if result.Count != 1 {
+			t.Errorf("Expected 1 unique label, got %d", result.Count)
+		}
+		if len(result.Warnings) != 1 || !contains(result.Warnings[0], "would be truncated by browser") {
+			t.Errorf("Expected a browser truncation warning, got %v", result.Warnings)
+		}
+	})
+
+	// Test case 2: Body larger than the configured limit is truncated
+	t.Run("Body exceeds configured limit", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.MaxBodySize = 16
+
+		result, err := CountLabelsWithOptions(server.URL, opts)
+		if err != nil {
+			t.Fatalf("CountLabelsWithOptions returned an error: %v", err)
+		}
+		if !contains(result.ErrorMessage, "parse JSON") {
+			t.Errorf("Expected a JSON parse error for the truncated body, got %s", result.ErrorMessage)
+		}
+		if len(result.RawJSON) != 16 {
+			t.Errorf("Expected body to be truncated to 16 bytes, got %d", len(result.RawJSON))
+		}
+		if len(result.Warnings) != 1 || !contains(result.Warnings[0], "configured maximum") {
+			t.Errorf("Expected a truncation warning, got %v", result.Warnings)
+		}
+	})
+
+	// Test case 3: The label limit is configurable
+	t.Run("Max labels", func(t *testing.T) {
+		labels := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"origins": ["https://a.com", "https://b.com", "https://c.com"]}`))
+		}))
+		defer labels.Close()
+
+		opts := DefaultOptions()
+		opts.MaxLabels = 2
+		result, err := CountLabelsWithOptions(labels.URL, opts)
+		if err != nil {
+			t.Fatalf("CountLabelsWithOptions returned an error: %v", err)
+		}
+		if !result.ExceedsLimit || result.MaxLabels != 2 {
+			t.Errorf("Expected 3 labels to exceed a limit of 2, got ExceedsLimit %v and MaxLabels %d", result.ExceedsLimit, result.MaxLabels)
+		}
+
+		// Zero means the spec default
+		opts.MaxLabels = 0
+		result, err = CountLabelsWithOptions(labels.URL, opts)
+		if err != nil {
+			t.Fatalf("CountLabelsWithOptions returned an error: %v", err)
+		}
+		if result.ExceedsLimit || result.MaxLabels != MaxLabels {
+			t.Errorf("Expected 3 labels to be within the default limit, got ExceedsLimit %v and MaxLabels %d", result.ExceedsLimit, result.MaxLabels)
+		}
+	})
+
+	// Test case 4: A body limit that is not positive means the browser limit
+	t.Run("Body limit not positive", func(t *testing.T) {
+		small := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"origins": ["https://a.com"]}`))
+		}))
+		defer small.Close()
+
+		for _, limit := range []int64{0, -5} {
+			opts := DefaultOptions()
+			opts.MaxBodySize = limit
+			result, err := CountLabelsWithOptions(small.URL, opts)
+			if err != nil {
+				t.Fatalf("CountLabelsWithOptions returned an error for a limit of %d: %v", limit, err)
+			}
+			if result.ErrorMessage != "" || result.Count != 1 || len(result.Warnings) != 0 {
+				t.Errorf("Expected the whole body to be read for a limit of %d, got error %q, %d labels and warnings %v",
+					limit, result.ErrorMessage, result.Count, result.Warnings)
+			}
+		}
+	})
+
+	// Test case 5: Timeout is honored
+	t.Run("Timeout", func(t *testing.T) {
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+		defer slow.Close()
+
+		opts := DefaultOptions()
+		opts.Timeout = 10 * time.Millisecond
+
+		if _, err := CountLabelsWithOptions(slow.URL, opts); err == nil {
+			t.Errorf("Expected a timeout error, got nil")
+		}
+	})
+
+	// Test case 6: A timeout that is not positive means the default, not no timeout
+	t.Run("Timeout not positive", func(t *testing.T) {
+		for _, timeout := range []time.Duration{0, -time.Second} {
+			if got := requestTimeout(timeout); got != Timeout {
+				t.Errorf("Expected a timeout of %v for %v, got %v", Timeout, timeout, got)
+			}
+		}
+		if got := requestTimeout(time.Second); got != time.Second {
+			t.Errorf("Expected a timeout of %v, got %v", time.Second, got)
+		}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"origins": ["https://a.com"]}`))
+		}))
+		defer server.Close()
+
+		result, err := CountLabelsWithOptions(server.URL, Options{})
+		if err != nil {
+			t.Fatalf("CountLabelsWithOptions returned an error for zero-value options: %v", err)
+		}
+		if result.Count != 1 {
+			t.Errorf("Expected 1 label, got %d", result.Count)
+		}
+	})
+}
+
+// TestValidator tests that a reused Validator agrees with ValidateWellKnownJSON.
+func TestValidator(t *testing.T) {
+	validator := NewValidator("https://foo.com")
+
+	documents := []struct {
+		json     string
+		expected AuthenticatorStatus
+	}{
+		{`{"origins": ["https://a.com", "https://foo.com"]}`, StatusSuccess},
+		{`{"foo": "bar"}`, StatusBadRelyingPartyIDJSONParseError},
+		{`{"origins": null}`, StatusBadRelyingPartyIDJSONParseError},
+		{`{"origins": []}`, StatusBadRelyingPartyIDNoJSONMatch},
+		{`{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://foo.com"]}`, StatusBadRelyingPartyIDNoJSONMatchHitLimits},
+		{`{"origins": ["https://foo.co.uk", "https://foo.de", "https://foo.in", "https://foo.net", "https://foo.org", "https://foo.com"]}`, StatusSuccess},
+	}
+
+	// Validate every document twice so that pooled state from one call is seen by the next
+	for round := 0; round < 2; round++ {
+		for _, doc := range documents {
+			if result := validator.Validate([]byte(doc.json)); result != doc.expected {
+				t.Errorf("Validate(%q) = %v, want %v", doc.json, result, doc.expected)
+			}
+			if result := ValidateWellKnownJSON("https://foo.com", []byte(doc.json)); result != doc.expected {
+				t.Errorf("ValidateWellKnownJSON(%q) = %v, want %v", doc.json, result, doc.expected)
+			}
+		}
+	}
+
+	t.Run("Max labels", func(t *testing.T) {
+		document := []byte(`{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://foo.com"]}`)
+		tests := []struct {
+			maxLabels int
+			expected  AuthenticatorStatus
+		}{
+			{0, StatusBadRelyingPartyIDNoJSONMatchHitLimits},
+			{5, StatusBadRelyingPartyIDNoJSONMatchHitLimits},
+			{6, StatusSuccess},
+			{10, StatusSuccess},
+		}
+		for _, tt := range tests {
+			if result := NewValidatorWithMaxLabels("https://foo.com", tt.maxLabels).Validate(document); result != tt.expected {
+				t.Errorf("Validate with %d labels = %v, want %v", tt.maxLabels, result, tt.expected)
+			}
+			if result := ValidateWellKnownJSONWithMaxLabels("https://foo.com", document, tt.maxLabels); result != tt.expected {
+				t.Errorf("ValidateWellKnownJSONWithMaxLabels with %d labels = %v, want %v", tt.maxLabels, result, tt.expected)
+			}
+			compiled, err := CompileWithMaxLabels(document, tt.maxLabels)
+			if err != nil {
+				t.Fatalf("CompileWithMaxLabels failed: %v", err)
+			}
+			if result := compiled.Validate("https://foo.com"); result != tt.expected {
+				t.Errorf("CompiledWellKnown.Validate with %d labels = %v, want %v", tt.maxLabels, result, tt.expected)
+			}
+		}
+
+		// A lower limit ignores labels a browser would count
+		if result := ValidateWellKnownJSONWithMaxLabels("https://b.com", document, 1); result != StatusBadRelyingPartyIDNoJSONMatchHitLimits {
+			t.Errorf("Expected a limit of 1 label to ignore b.com, got %v", result)
+		}
+	})
+
+	t.Run("Invalid caller origin", func(t *testing.T) {
+		invalid := NewValidator("://bad")
+		if result := invalid.Validate([]byte(`{"origins": ["https://foo.com"]}`)); result != StatusBadRelyingPartyIDNoJSONMatch {
+			t.Errorf("Expected %v, got %v", StatusBadRelyingPartyIDNoJSONMatch, result)
+		}
+	})
+}
+
+// TestCompile tests the labels and limits recorded by Compile.
+func TestCompile(t *testing.T) {
+	compiled, err := Compile([]byte(`{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://f.com", "https://a.com:8443"]}`))
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	if labels := compiled.Labels(); len(labels) != MaxLabels {
+		t.Errorf("Expected %d labels, got %v", MaxLabels, labels)
+	}
+	if !compiled.HitLimits() {
+		t.Errorf("Expected HitLimits to be true")
+	}
+
+	// Origins after the limit are still authorized when their label was already counted
+	if result := compiled.Validate("https://a.com:8443"); result != StatusSuccess {
+		t.Errorf("Expected %v, got %v", StatusSuccess, result)
+	}
+	if result := compiled.Validate("https://f.com"); result != StatusBadRelyingPartyIDNoJSONMatchHitLimits {
+		t.Errorf("Expected %v, got %v", StatusBadRelyingPartyIDNoJSONMatchHitLimits, result)
+	}
+
+	// The matched origin is the entry as written in the document
+	compiled, _ = Compile([]byte(`{"origins": ["https://a.com/login", "https://a.com", "https://b.com"]}`))
+	if matched := compiled.MatchedOrigin("https://a.com"); matched != "https://a.com/login" {
+		t.Errorf("Expected https://a.com/login, got %q", matched)
+	}
+	if matched := compiled.MatchedOrigin("https://c.com"); matched != "" {
+		t.Errorf("Expected no match, got %q", matched)
+	}
+}
+
+// TestAnalyzeOrder tests which entries browsers reach before the label budget is
+// exhausted.
+func TestAnalyzeOrder(t *testing.T) {
+	analysis, err := AnalyzeOrder([]byte(`{"origins": ["https://a.com", "https://b.com", "https://a.com/login", "https://c.com", "https://d.com", "https://e.com", "https://f.com", "https://a.de", "localhost"]}`), 0)
+	if err != nil {
+		t.Fatalf("AnalyzeOrder failed: %v", err)
+	}
+
+	expected := []Reach{ReachCharged, ReachCharged, ReachShadowed, ReachCharged, ReachCharged, ReachCharged, ReachDead, ReachCounted, ReachSkipped}
+	for i, entry := range analysis.Entries {
+		if entry.Reach != expected[i] {
+			t.Errorf("Entry %d (%s): expected %v, got %v", i, entry.Origin, expected[i], entry.Reach)
+		}
+	}
+	if analysis.ExhaustedAt != 5 {
+		t.Errorf("Expected the budget to be exhausted at index 5, got %d", analysis.ExhaustedAt)
+	}
+	dead := analysis.Dead()
+	if len(dead) != 1 || dead[0].Position != 6 || !strings.Contains(dead[0].Detail, `before index 5, the first entry of label "e"`) {
+		t.Errorf("Expected f.com to be dead and moved before e.com, got %+v", dead)
+	}
+
+	// A document within the budget has no dead entries
+	analysis, _ = AnalyzeOrder([]byte(`{"origins": ["https://a.com", "https://b.com"]}`), 0)
+	if analysis.ExhaustedAt != -1 || len(analysis.Dead()) != 0 {
+		t.Errorf("Unexpected analysis %+v", analysis)
+	}
+
+	if _, err := AnalyzeOrder([]byte(`{}`), 0); err == nil {
+		t.Error("Expected an error for a document without origins")
+	}
+}
+
+// TestExplain tests that explanations reach the status of ValidateWellKnownJSON and
+// narrate where matching stopped.
+func TestExplain(t *testing.T) {
+	documents := []string{
+		`{"foo": "bar"}`,
+		`{"origins": null}`,
+		`{"origins": []}`,
+		`{"origins": ["https://foo.com"]}`,
+		`{"origins": ["http://foo.com", "https://foo.com:8443", "https://com", "not a url"]}`,
+		`{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://foo.com"]}`,
+		`{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://foo.com", "https://e.com"]}`,
+		`{"origins": ["https://foo.co.uk", "https://foo.de", "https://foo.in", "https://foo.net", "https://foo.org", "https://foo.com"]}`,
+	}
+	for _, doc := range documents {
+		for _, caller := range []string{"https://foo.com", "://bad"} {
+			explanation := Explain(caller, []byte(doc))
+			if want := ValidateWellKnownJSON(caller, []byte(doc)); explanation.Status != want {
+				t.Errorf("Explain(%q, %q) status = %v, want %v", caller, doc, explanation.Status, want)
+			}
+			if len(explanation.Steps) == 0 {
+				t.Errorf("Explain(%q, %q) has no steps", caller, doc)
+			}
+		}
+	}
+
+	t.Run("Label limit", func(t *testing.T) {
+		explanation := Explain("https://foo.com", []byte(`{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://foo.com", "https://a.com:8443"]}`))
+		if len(explanation.Labels) != MaxLabels {
+			t.Errorf("Expected %d labels charged, got %v", MaxLabels, explanation.Labels)
+		}
+		ignored := explanation.Steps[len(explanation.Steps)-4]
+		if ignored.Index != 5 || !strings.HasPrefix(ignored.Message, "Ignore:") || ignored.Rule != labelLimitRule(MaxLabels) {
+			t.Errorf("Expected origins[5] to be ignored for the label limit, got %+v", ignored)
+		}
+	})
+
+	t.Run("Configured label limit", func(t *testing.T) {
+		document := []byte(`{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com"]}`)
+		if explanation := Explain("https://c.com", document); explanation.Status != StatusSuccess {
+			t.Fatalf("Expected %v with the default limit, got %v", StatusSuccess, explanation.Status)
+		}
+		explanation := ExplainWithMaxLabels("https://c.com", document, 2)
+		if want := ValidateWellKnownJSONWithMaxLabels("https://c.com", document, 2); explanation.Status != want || want != StatusBadRelyingPartyIDNoJSONMatchHitLimits {
+			t.Errorf("Expected %v, got %v", StatusBadRelyingPartyIDNoJSONMatchHitLimits, explanation.Status)
+		}
+		if explanation.MaxLabels != 2 || len(explanation.Labels) != 2 || explanation.Entries[2] != EntryIgnored {
+			t.Errorf("Expected 2 labels charged and origins[2] ignored, got %v and %v", explanation.Labels, explanation.Entries)
+		}
+		if step := explanation.Steps[6]; step.Message != `Ignore: label "c" would be label 3 of 2` || step.Rule != labelLimitRule(2) {
+			t.Errorf("Unexpected step %+v", step)
+		}
+	})
+
+	t.Run("Stops at the match", func(t *testing.T) {
+		explanation := Explain("https://foo.com", []byte(`{"origins": ["http://foo.com", "https://foo.com", "https://bar.com"]}`))
+		var messages []string
+		for _, step := range explanation.Steps {
+			messages = append(messages, step.Message)
+		}
+		text := strings.Join(messages, "\n")
+		for _, want := range []string{`No match: scheme "http" is not "https"`, "Match: same origin as the caller", "The remaining 1 entries are not examined"} {
+			if !strings.Contains(text, want) {
+				t.Errorf("Expected %q in the explanation, got:\n%s", want, text)
+			}
+		}
+		if formatted := FormatSteps(explanation.Steps[:1]); formatted != "1. Parse the document: 3 entries in the origins array\n   Rule: "+ruleParse+"\n" {
+			t.Errorf("Unexpected formatted explanation:\n%s", formatted)
+		}
+	})
+
+	t.Run("Fetch steps", func(t *testing.T) {
+		steps := FetchSteps(&LabelCount{URL: "https://example.com/.well-known/webauthn", ContentType: "text/html", SniffedFormat: "html", ErrorMessage: "served as HTML"})
+		if len(steps) != 3 || steps[0].Message != "Fetch https://example.com/.well-known/webauthn" || steps[2].Message != "Stop: served as HTML" {
+			t.Errorf("Unexpected fetch steps %+v", steps)
+		}
+	})
+}
+
+// benchmarkDocument is a typical .well-known/webauthn document with the caller origin last.
+var benchmarkDocument = []byte(`{"origins": ["https://a.example.com", "https://b.example.co.uk", "https://shop.example.de", "https://login.example.net", "https://foo.com"]}`)
+
+// BenchmarkValidateWellKnownJSON measures validation when the caller origin is parsed on every call.
+func BenchmarkValidateWellKnownJSON(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if ValidateWellKnownJSON("https://foo.com", benchmarkDocument) != StatusSuccess {
+			b.Fatal("Expected success")
+		}
+	}
+}
+
+// BenchmarkValidator measures validation with a Validator reused across calls.
+func BenchmarkValidator(b *testing.B) {
+	validator := NewValidator("https://foo.com")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if validator.Validate(benchmarkDocument) != StatusSuccess {
+			b.Fatal("Expected success")
+		}
+	}
+}
+
+// BenchmarkCompiledWellKnown measures validation against a document compiled once.
+func BenchmarkCompiledWellKnown(b *testing.B) {
+	compiled, err := Compile(benchmarkDocument)
+	if err != nil {
+		b.Fatalf("Compile failed: %v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if compiled.Validate("https://foo.com") != StatusSuccess {
+			b.Fatal("Expected success")
+		}
+	}
+}
+
+// BenchmarkValidatorParallel measures a Validator shared between goroutines.
+func BenchmarkValidatorParallel(b *testing.B) {
+	validator := NewValidator("https://foo.com")
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if validator.Validate(benchmarkDocument) != StatusSuccess {
+				b.Fatal("Expected success")
+			}
+		}
+	})
 }
 
 // Helper function to check if a string contains a substring
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && s != substr && s != "" && substr != "" && strings.Contains(s, substr)
 }
+
+// TestCompare tests contrasting two caller origins against the same document.
+func TestCompare(t *testing.T) {
+	document := []byte(`{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://foo.com", "https://e.com"]}`)
+
+	t.Run("Label limit", func(t *testing.T) {
+		c := Compare("https://foo.com", "https://e.com", document)
+		if c.A.Status != StatusSuccess || c.B.Status != StatusBadRelyingPartyIDNoJSONMatchHitLimits {
+			t.Fatalf("Unexpected statuses %v and %v", c.A.Status, c.B.Status)
+		}
+		if c.ReasonA != `authorized by origins[4] https://foo.com, whose label "foo" is label 5 of 5` {
+			t.Errorf("Unexpected reason %q", c.ReasonA)
+		}
+		if c.ReasonB != `listed at origins[5] https://e.com, but its label "e" would be label 6 of 5, so browsers ignore it` {
+			t.Errorf("Unexpected reason %q", c.ReasonB)
+		}
+		if c.A.Entries[5] != EntryNotExamined || c.B.Entries[5] != EntryIgnored || c.B.Entries[4] != EntryNoMatch {
+			t.Errorf("Unexpected entries %v and %v", c.A.Entries, c.B.Entries)
+		}
+		if len(c.Differences) != 2 || !strings.HasPrefix(c.Differences[0], "host:") || !strings.HasPrefix(c.Differences[1], "label:") {
+			t.Errorf("Unexpected differences %v", c.Differences)
+		}
+	})
+
+	t.Run("Configured label limit", func(t *testing.T) {
+		c := CompareWithMaxLabels("https://a.com", "https://c.com", document, 2)
+		if c.A.Status != StatusSuccess || c.B.Status != StatusBadRelyingPartyIDNoJSONMatchHitLimits {
+			t.Fatalf("Unexpected statuses %v and %v", c.A.Status, c.B.Status)
+		}
+		if c.ReasonA != `authorized by origins[0] https://a.com, whose label "a" is label 1 of 2` {
+			t.Errorf("Unexpected reason %q", c.ReasonA)
+		}
+		if c.ReasonB != `listed at origins[2] https://c.com, but its label "c" would be label 3 of 2, so browsers ignore it` {
+			t.Errorf("Unexpected reason %q", c.ReasonB)
+		}
+	})
+
+	t.Run("Scheme", func(t *testing.T) {
+		c := Compare("https://a.com", "http://a.com", document)
+		if !strings.Contains(c.ReasonB, `origins[0] https://a.com, does not match: scheme "https" is not "http"`) {
+			t.Errorf("Unexpected reason %q", c.ReasonB)
+		}
+		if len(c.Differences) != 1 || c.Differences[0] != `scheme: "https" vs "http"` {
+			t.Errorf("Unexpected differences %v", c.Differences)
+		}
+	})
+
+	t.Run("Not listed", func(t *testing.T) {
+		c := Compare("https://a.com", "https://z.com", document)
+		if c.ReasonB != "not listed among the counted entries, and entries were ignored because of the label limit" {
+			t.Errorf("Unexpected reason %q", c.ReasonB)
+		}
+		if c := Compare("https://a.com", "https://z.com", []byte(`{}`)); c.Origins != nil || !strings.Contains(c.ReasonA, "cannot be parsed") {
+			t.Errorf("Unexpected comparison %+v", c)
+		}
+	})
+}
+
+// TestLocateParseError tests the location, snippet and hint of JSON syntax errors.
+func TestLocateParseError(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		line    int
+		column  int
+		snippet string
+		hint    string
+	}{
+		{
+			name:    "Trailing comma",
+			json:    "{\n  \"origins\": [\n    \"https://a.com\",\n  ]\n}\n",
+			line:    4,
+			column:  3,
+			snippet: "  ]\n  ^",
+			hint:    "remove the trailing comma on line 3; JSON does not allow one before ']'",
+		},
+		{
+			name:    "Byte order mark",
+			json:    "\xef\xbb\xbf{\"origins\": []}",
+			line:    1,
+			column:  1,
+			snippet: "\ufeff{\"origins\": []}\n^",
+			hint:    "the document starts with a UTF-8 byte order mark; save it without one",
+		},
+		{
+			name:    "Truncated",
+			json:    "{\"origins\": [\"https://a.com\"\n",
+			line:    1,
+			column:  29,
+			snippet: "{\"origins\": [\"https://a.com\"\n                            ^",
+			hint:    "the document ends before every array and object is closed; it may be truncated",
+		},
+		{
+			name:    "Missing comma",
+			json:    "{\"origins\": [\"https://a.com\" \"https://b.com\"]}",
+			line:    1,
+			column:  30,
+			snippet: "{\"origins\": [\"https://a.com\" \"https://b.com\"]}\n                             ^",
+			hint:    "a comma is missing before this character",
+		},
+		{
+			name:    "Long line",
+			json:    `{"origins": [` + strings.Repeat(`"https://a.com", `, 10) + `'https://b.com']}`,
+			line:    1,
+			column:  184,
+			snippet: `...com", "https://a.com", "https://a.com", 'https://b.com']}` + "\n" + strings.Repeat(" ", 43) + "^",
+			hint:    "JSON strings must be quoted with double quotes",
+		},
+		{
+			name:    "Empty",
+			json:    "",
+			line:    1,
+			column:  1,
+			snippet: "\n^",
+			hint:    "the document is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var webAuthnResp WebAuthnResponse
+			err := json.Unmarshal([]byte(tt.json), &webAuthnResp)
+			located := LocateParseError([]byte(tt.json), err)
+			if located == nil {
+				t.Fatalf("Expected a parse error for %q, got %v", tt.json, err)
+			}
+			if located.Line != tt.line || located.Column != tt.column {
+				t.Errorf("Expected line %d, column %d, got line %d, column %d", tt.line, tt.column, located.Line, located.Column)
+			}
+			if located.Snippet != tt.snippet {
+				t.Errorf("Expected snippet:\n%s\ngot:\n%s", tt.snippet, located.Snippet)
+			}
+			if located.Hint != tt.hint {
+				t.Errorf("Expected hint %q, got %q", tt.hint, located.Hint)
+			}
+		})
+	}
+
+	// Errors that are not syntax errors are not located
+	if located := LocateParseError([]byte(`{"origins": 1}`), json.Unmarshal([]byte(`{"origins": 1}`), &WebAuthnResponse{})); located != nil {
+		t.Errorf("Expected no location for a type error, got %+v", located)
+	}
+}
+
+// TestDiagnose tests tolerant parsing of documents browsers reject.
+func TestDiagnose(t *testing.T) {
+	doc := "\xef\xbb\xbf{\n  // Shops\n  \"origins\": [\n    'https://shop.example.com',\n    \"https://example.co.uk\", /* UK */\n  ],\n}\n"
+	d := Diagnose([]byte(doc))
+	var repairs []string
+	for _, repair := range d.Repairs {
+		repairs = append(repairs, repair.String())
+	}
+	expected := []string{
+		"line 1, column 1: UTF-8 byte order mark",
+		"line 2, column 3: comment",
+		"line 4, column 5: string quoted with single quotes",
+		"line 5, column 28: trailing comma before ']'",
+		"line 5, column 30: comment",
+		"line 6, column 4: trailing comma before '}'",
+	}
+	if strings.Join(repairs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected repairs:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(repairs, "\n"))
+	}
+	if d.Remaining != "" {
+		t.Errorf("Expected the repaired document to parse, got %s", d.Remaining)
+	}
+	if len(d.Origins) != 2 || strings.Join(d.Labels, ",") != "example" {
+		t.Errorf("Expected 2 origins with label example, got %v and %v", d.Origins, d.Labels)
+	}
+
+	// Mistakes tolerant parsing does not know about remain
+	d = Diagnose([]byte(`{"origins": ["https://a.com",, "https://b.com"]}`))
+	if len(d.Repairs) != 0 || !strings.Contains(d.Remaining, "line 1, column 30") {
+		t.Errorf("Expected no repairs and a remaining error, got %+v", d)
+	}
+}
@@ -0,0 +1,164 @@
+package counter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Repair is a mistake that tolerant parsing worked around.
+type Repair struct {
+	// Offset is the byte offset of the mistake in the document.
+	Offset int `json:"offset"`
+	// Line and Column locate the mistake, starting at 1.
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	// Problem describes the mistake, such as "trailing comma before ']'".
+	Problem string `json:"problem"`
+}
+
+// String returns the location of the repair followed by its problem.
+func (r Repair) String() string {
+	return fmt.Sprintf("line %d, column %d: %s", r.Line, r.Column, r.Problem)
+}
+
+// Diagnosis is what tolerant parsing found in a document that is not valid JSON. It
+// only explains the document: browsers parse strictly and reject it all the same.
+type Diagnosis struct {
+	// Repairs are the mistakes worked around, in document order.
+	Repairs []Repair `json:"repairs"`
+	// Repaired is the document with the repairs applied.
+	Repaired []byte `json:"-"`
+	// Remaining describes why the repaired document still cannot be parsed, located in
+	// the repaired document, or is "" if it can be.
+	Remaining string `json:"remaining,omitempty"`
+	// Origins and Labels are the origins the repaired document lists and the unique
+	// labels they count, if it can be parsed.
+	Origins []string `json:"origins,omitempty"`
+	Labels  []string `json:"labels,omitempty"`
+}
+
+// Diagnose parses a document tolerantly, working around the mistakes browsers reject a
+// document for that are the most common in hand-written files: a UTF-8 byte order mark,
+// trailing commas, strings quoted with single quotes and comments. It reports each of
+// them, and what the document would list once they are fixed.
+func Diagnose(body []byte) *Diagnosis {
+	d := &Diagnosis{}
+	repair := func(offset int, format string, args ...any) {
+		line, column := position(body, offset)
+		d.Repairs = append(d.Repairs, Repair{Offset: offset, Line: line, Column: column, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	var out bytes.Buffer
+	i := 0
+	if bytes.HasPrefix(body, []byte("\xef\xbb\xbf")) {
+		repair(0, "UTF-8 byte order mark")
+		i = 3
+	}
+	// comma is the offset of the last comma written, and commaOut its offset in out,
+	// until something other than whitespace or a comment follows it
+	comma, commaOut := -1, -1
+	for i < len(body) {
+		c := body[i]
+		next := byte(0)
+		if i+1 < len(body) {
+			next = body[i+1]
+		}
+		switch {
+		case c == '"':
+			// Copy the string as it is, escapes included
+			j := i + 1
+			for j < len(body) && body[j] != '"' {
+				if body[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(body))
+			out.Write(body[i:j])
+			i = j
+			comma = -1
+		case c == '\'':
+			repair(i, "string quoted with single quotes")
+			out.WriteByte('"')
+			j := i + 1
+			for j < len(body) && body[j] != '\'' {
+				switch {
+				case body[j] == '\\' && j+1 < len(body) && body[j+1] == '\'':
+					out.WriteByte('\'')
+					j += 2
+					continue
+				case body[j] == '\\' && j+1 < len(body):
+					out.Write(body[j : j+2])
+					j += 2
+					continue
+				case body[j] == '"':
+					out.WriteString(`\"`)
+				default:
+					out.WriteByte(body[j])
+				}
+				j++
+			}
+			out.WriteByte('"')
+			i = min(j+1, len(body))
+			comma = -1
+		case c == '/' && next == '/':
+			repair(i, "comment")
+			end := bytes.IndexByte(body[i:], '\n')
+			if end < 0 {
+				end = len(body) - i
+			}
+			i += end
+		case c == '/' && next == '*':
+			repair(i, "comment")
+			end := bytes.Index(body[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(body)
+			} else {
+				i += end + 4
+			}
+			out.WriteByte(' ')
+		case (c == ']' || c == '}') && comma >= 0:
+			repair(comma, "trailing comma before %q", c)
+			rest := append([]byte{}, out.Bytes()[commaOut+1:]...)
+			out.Truncate(commaOut)
+			out.Write(rest)
+			out.WriteByte(c)
+			i++
+			comma = -1
+		case c == ',':
+			comma, commaOut = i, out.Len()
+			out.WriteByte(c)
+			i++
+		default:
+			if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+				comma = -1
+			}
+			out.WriteByte(c)
+			i++
+		}
+	}
+	d.Repaired = out.Bytes()
+	// A trailing comma is only known once the bracket after it is seen
+	sort.SliceStable(d.Repairs, func(a, b int) bool { return d.Repairs[a].Offset < d.Repairs[b].Offset })
+
+	var webAuthnResp WebAuthnResponse
+	if err := json.Unmarshal(d.Repaired, &webAuthnResp); err != nil {
+		d.Remaining = parseError(d.Repaired, err)
+		return d
+	}
+	if webAuthnResp.Origins == nil {
+		d.Remaining = "missing origins array"
+		return d
+	}
+	d.Origins = webAuthnResp.Origins
+	seen := make(map[string]bool)
+	for _, origin := range webAuthnResp.Origins {
+		if label, ok := OriginLabel(origin); ok && !seen[label] {
+			seen[label] = true
+			d.Labels = append(d.Labels, label)
+		}
+	}
+	return d
+}
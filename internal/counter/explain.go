@@ -0,0 +1,277 @@
+package counter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Rules cited by the steps of an explanation, from the WebAuthn Level 3 algorithm for
+// validating related origins (§5.11.1) and Chromium's implementation of it.
+const (
+	ruleFetch       = "WebAuthn §5.11.1: the document is fetched from https://<RP ID>/.well-known/webauthn, without credentials or referrer"
+	ruleResponse    = "Chromium: the response must have status 200 and a JSON content type, and at most 256 KiB of it is read"
+	ruleParse       = "Chromium ValidateWellKnownJSON: the document must be a JSON object with an origins array of strings"
+	ruleCaller      = "WebAuthn §5.11.1: the caller origin is compared by scheme, host and port"
+	ruleOriginURL   = "WebAuthn §5.11.1: entries that do not parse as URLs with a host are skipped"
+	ruleLabel       = "WebAuthn §5.11.1: an entry's label is its registrable domain without the public suffix; entries without one are skipped"
+	ruleSameOrigin  = "WebAuthn §5.11.1: the caller is authorized by the first counted entry that is same origin with it"
+	ruleNoMatch     = "WebAuthn §5.11.1: when no counted entry is same origin with the caller, the request fails"
+	ruleNotExamined = "WebAuthn §5.11.1: matching stops at the first entry that authorizes the caller"
+	ruleAndroid     = "FIDO: an android:apk-key-hash entry names an Android app by the SHA-256 hash of its signing certificate; it has no label and only authorizes that app"
+)
+
+// labelLimitRule returns the rule that applies to an entry whose label is beyond a limit
+// of maxLabels.
+func labelLimitRule(maxLabels int) string {
+	return fmt.Sprintf("Chromium kMaxLabels: at most %d distinct labels are counted; entries with a further label are ignored", maxLabels)
+}
+
+// Step is one step a browser takes to check a caller origin against a relying party's
+// .well-known/webauthn document.
+type Step struct {
+	// Index is the position of the entry in the origins array, or -1 for steps about the
+	// whole document.
+	Index int
+	// Origin is the entry as written in the document, if the step is about one.
+	Origin string
+	// Message narrates what the browser does.
+	Message string
+	// Rule is the rule of the WebAuthn specification or Chromium that applies.
+	Rule string
+}
+
+// EntryOutcome is what a browser does with one entry of the origins array.
+type EntryOutcome int
+
+const (
+	// EntryNotExamined means matching stopped before the entry.
+	EntryNotExamined EntryOutcome = iota
+	// EntrySkipped means the entry has no host or no registrable domain.
+	EntrySkipped
+	// EntryIgnored means the entry's label is beyond the label limit.
+	EntryIgnored
+	// EntryNoMatch means the entry was counted but is not same origin with the caller.
+	EntryNoMatch
+	// EntryMatch means the entry authorizes the caller.
+	EntryMatch
+)
+
+// String returns a short description of the outcome.
+func (o EntryOutcome) String() string {
+	switch o {
+	case EntryNotExamined:
+		return "not examined"
+	case EntrySkipped:
+		return "skipped"
+	case EntryIgnored:
+		return "ignored (label limit)"
+	case EntryNoMatch:
+		return "no match"
+	case EntryMatch:
+		return "MATCH"
+	default:
+		return fmt.Sprintf("UNKNOWN_OUTCOME(%d)", o)
+	}
+}
+
+// Explanation narrates how a browser validates a caller origin against a document.
+type Explanation struct {
+	CallerOrigin string
+	Steps        []Step
+	// Labels are the labels charged against the label limit, in document order.
+	Labels []string
+	// MaxLabels is the number of unique labels counted before further entries are ignored.
+	MaxLabels int
+	// Entries is the outcome of each entry of the origins array, in document order.
+	Entries []EntryOutcome
+	// Status is the status ValidateWellKnownJSON returns for the caller origin.
+	Status AuthenticatorStatus
+}
+
+// add appends a step to the explanation.
+func (e *Explanation) add(index int, origin, rule, format string, args ...any) {
+	e.Steps = append(e.Steps, Step{Index: index, Origin: origin, Message: fmt.Sprintf(format, args...), Rule: rule})
+}
+
+// FetchSteps narrates how the document of result was fetched or read, up to the point
+// where it is parsed, including why a browser would refuse it. A document that was
+// received but cannot be parsed is left to Explain.
+func FetchSteps(result *LabelCount) []Step {
+	var steps []Step
+	add := func(rule, format string, args ...any) {
+		steps = append(steps, Step{Index: -1, Message: fmt.Sprintf(format, args...), Rule: rule})
+	}
+
+	if !strings.HasPrefix(result.URL, "https://") && !strings.HasPrefix(result.URL, "http://") {
+		add(ruleFetch, "Read the document from %s instead of fetching it", result.URL)
+	} else {
+		add(ruleFetch, "Fetch %s", result.URL)
+		switch {
+		case result.ContentType != "":
+			add(ruleResponse, "Received a response served as %s, whose body looks like %s", result.ContentType, result.SniffedFormat)
+		case result.SniffedFormat != "":
+			add(ruleResponse, "Received a response with no content type, whose body looks like %s", result.SniffedFormat)
+		}
+	}
+	for _, warning := range result.Warnings {
+		add(ruleResponse, "Warning: %s", warning)
+	}
+	if result.ErrorMessage != "" && result.RawJSON == "" {
+		add(ruleResponse, "Stop: %s", result.ErrorMessage)
+	}
+	return steps
+}
+
+// Explain validates callerOrigin against a .well-known/webauthn document as
+// ValidateWellKnownJSON does, and narrates every step: each entry parsed, each label
+// charged against MaxLabels, and where matching stopped.
+func Explain(callerOrigin string, jsonData []byte) *Explanation {
+	return ExplainWithMaxLabels(callerOrigin, jsonData, MaxLabels)
+}
+
+// ExplainWithMaxLabels is like Explain but charges labels against maxLabels instead of
+// MaxLabels. A maxLabels that is not positive means MaxLabels.
+func ExplainWithMaxLabels(callerOrigin string, jsonData []byte, maxLabels int) *Explanation {
+	e := &Explanation{CallerOrigin: callerOrigin, MaxLabels: labelLimit(maxLabels)}
+
+	// Parse the JSON
+	var webAuthnResp WebAuthnResponse
+	if err := json.Unmarshal(jsonData, &webAuthnResp); err != nil {
+		e.add(-1, "", ruleParse, "Stop: the document cannot be parsed: %s", parseError(jsonData, err))
+		e.Status = StatusBadRelyingPartyIDJSONParseError
+		return e
+	}
+	if webAuthnResp.Origins == nil {
+		e.add(-1, "", ruleParse, "Stop: the document has no origins array")
+		e.Status = StatusBadRelyingPartyIDJSONParseError
+		return e
+	}
+	e.add(-1, "", ruleParse, "Parse the document: %d entries in the origins array", len(webAuthnResp.Origins))
+	e.Entries = make([]EntryOutcome, len(webAuthnResp.Origins))
+
+	// Parse the caller origin
+	callerURL, err := parseCallerURL(callerOrigin)
+	if err != nil {
+		e.add(-1, "", ruleCaller, "Stop: the caller origin cannot be parsed: %v", err)
+		e.Status = StatusBadRelyingPartyIDNoJSONMatch
+		return e
+	}
+	if callerURL.Scheme == androidScheme {
+		e.add(-1, "", ruleAndroid, "Look for the Android app origin with certificate hash %q", callerURL.Host)
+	} else {
+		e.add(-1, "", ruleCaller, "Look for an entry with scheme %q and host %q", callerURL.Scheme, callerURL.Host)
+	}
+
+	hitLimits := false
+	for i, originStr := range webAuthnResp.Origins {
+		if IsAndroidOrigin(originStr) {
+			entry := lookupOrigin(originStr)
+			switch {
+			case !entry.android:
+				e.add(i, originStr, ruleAndroid, "Skip: not a valid Android app origin")
+				e.Entries[i] = EntrySkipped
+				continue
+			case entry.scheme == callerURL.Scheme && entry.host == callerURL.Host:
+				e.add(i, originStr, ruleAndroid, "Match: the Android app of the caller; no label is charged; stop here")
+				e.Entries[i] = EntryMatch
+				if rest := len(webAuthnResp.Origins) - i - 1; rest > 0 {
+					e.add(-1, "", ruleNotExamined, "The remaining %d entries are not examined", rest)
+				}
+				e.Status = StatusSuccess
+				return e
+			default:
+				e.add(i, originStr, ruleAndroid, "No match: %s; no label is charged", mismatch(&url.URL{Scheme: entry.scheme, Host: entry.host}, callerURL))
+				e.Entries[i] = EntryNoMatch
+				continue
+			}
+		}
+		originURL, err := url.Parse(originStr)
+		if err != nil || originURL.Host == "" {
+			e.add(i, originStr, ruleOriginURL, "Skip: not a URL with a host")
+			e.Entries[i] = EntrySkipped
+			continue
+		}
+		label, err := getLabel(originURL.Host)
+		if err != nil {
+			e.add(i, originStr, ruleLabel, "Skip: %s has no registrable domain", originURL.Host)
+			e.Entries[i] = EntrySkipped
+			continue
+		}
+
+		counted := false
+		for _, l := range e.Labels {
+			if l == label {
+				counted = true
+				break
+			}
+		}
+		switch {
+		case counted:
+			e.add(i, originStr, ruleLabel, "Label %q was already charged; no budget used (%d of %d)", label, len(e.Labels), e.MaxLabels)
+		case len(e.Labels) >= e.MaxLabels:
+			hitLimits = true
+			e.add(i, originStr, labelLimitRule(e.MaxLabels), "Ignore: label %q would be label %d of %d", label, len(e.Labels)+1, e.MaxLabels)
+			e.Entries[i] = EntryIgnored
+			continue
+		default:
+			e.Labels = append(e.Labels, label)
+			e.add(i, originStr, ruleLabel, "Charge label %q (%d of %d)", label, len(e.Labels), e.MaxLabels)
+		}
+
+		// Origins are compared in their serialized form: lowercase, without a default port
+		entryURL := canonical(originURL)
+		if entryURL.Scheme == callerURL.Scheme && entryURL.Host == callerURL.Host {
+			e.add(i, originStr, ruleSameOrigin, "Match: same origin as the caller; stop here")
+			e.Entries[i] = EntryMatch
+			if rest := len(webAuthnResp.Origins) - i - 1; rest > 0 {
+				e.add(-1, "", ruleNotExamined, "The remaining %d entries are not examined", rest)
+			}
+			e.Status = StatusSuccess
+			return e
+		}
+		e.add(i, originStr, ruleSameOrigin, "No match: %s", mismatch(entryURL, callerURL))
+		e.Entries[i] = EntryNoMatch
+	}
+
+	if hitLimits {
+		e.add(-1, "", ruleNoMatch, "Stop: no counted entry matches, and entries were ignored because of the label limit")
+		e.Status = StatusBadRelyingPartyIDNoJSONMatchHitLimits
+		return e
+	}
+	e.add(-1, "", ruleNoMatch, "Stop: no entry matches the caller origin")
+	e.Status = StatusBadRelyingPartyIDNoJSONMatch
+	return e
+}
+
+// mismatch describes how an entry differs from the caller origin.
+func mismatch(entry, caller *url.URL) string {
+	switch {
+	case entry.Scheme == androidScheme && caller.Scheme == androidScheme:
+		return fmt.Sprintf("certificate hash %q is not %q", entry.Host, caller.Host)
+	case entry.Scheme == androidScheme:
+		return "an Android app origin does not authorize a web origin"
+	case caller.Scheme == androidScheme:
+		return "a web origin does not authorize an Android app"
+	case entry.Host == caller.Host:
+		return fmt.Sprintf("scheme %q is not %q", entry.Scheme, caller.Scheme)
+	case entry.Hostname() == caller.Hostname():
+		return fmt.Sprintf("port of %q is not that of %q", entry.Host, caller.Host)
+	default:
+		return fmt.Sprintf("host %q is not %q", entry.Host, caller.Host)
+	}
+}
+
+// FormatSteps formats steps as a numbered list, each with the rule that applies.
+func FormatSteps(steps []Step) string {
+	var sb strings.Builder
+	for i, step := range steps {
+		location := ""
+		if step.Index >= 0 {
+			location = fmt.Sprintf("origins[%d] %s: ", step.Index, step.Origin)
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s%s\n   Rule: %s\n", i+1, location, step.Message, step.Rule))
+	}
+	return sb.String()
+}
@@ -0,0 +1,196 @@
+package counter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Reach is whether a browser reaches an entry of the origins array before the label
+// budget is exhausted.
+type Reach int
+
+const (
+	// ReachCharged means the entry is counted and charges a new label.
+	ReachCharged Reach = iota
+	// ReachCounted means the entry is counted under a label an earlier entry charged.
+	ReachCounted
+	// ReachAndroid means the entry is an Android app origin, which has no label and is
+	// always counted.
+	ReachAndroid
+	// ReachSkipped means the entry has no host or no registrable domain, so it is never
+	// counted, wherever it is listed.
+	ReachSkipped
+	// ReachShadowed means an earlier counted entry is the same origin, so matching always
+	// stops there and never reaches this one.
+	ReachShadowed
+	// ReachDead means the entry's label is only seen after the label budget is
+	// exhausted, so browsers ignore it, although it would be counted earlier in the list.
+	ReachDead
+)
+
+// String returns a short description of the reach.
+func (r Reach) String() string {
+	switch r {
+	case ReachCharged:
+		return "charged"
+	case ReachCounted:
+		return "counted"
+	case ReachAndroid:
+		return "android"
+	case ReachSkipped:
+		return "skipped"
+	case ReachShadowed:
+		return "shadowed"
+	case ReachDead:
+		return "dead"
+	default:
+		return fmt.Sprintf("UNKNOWN_REACH(%d)", r)
+	}
+}
+
+// MarshalText encodes the reach as its description, so that it reads well in JSON.
+func (r Reach) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// Unused reports whether the entry can never authorize a caller where it is listed.
+func (r Reach) Unused() bool {
+	return r == ReachSkipped || r == ReachShadowed || r == ReachDead
+}
+
+// EntryReach is how far a browser gets with one entry of the origins array.
+type EntryReach struct {
+	Index  int    `json:"index"`
+	Origin string `json:"origin"`
+	Label  string `json:"label,omitempty"`
+	// Position is the order in which the entry's label is first seen, starting at 1.
+	// Labels seen after the budget is exhausted are numbered too.
+	Position int   `json:"position,omitempty"`
+	Reach    Reach `json:"reach"`
+	// Detail explains an entry that can never authorize a caller, and for a dead entry
+	// where it would have to move to be counted.
+	Detail string `json:"detail,omitempty"`
+}
+
+// OrderAnalysis is the reach of every entry of a document's origins array.
+type OrderAnalysis struct {
+	Entries   []EntryReach `json:"entries"`
+	MaxLabels int          `json:"max_labels"`
+	// ExhaustedAt is the index of the entry that charges the last label of the budget,
+	// or -1 if the document has labels to spare.
+	ExhaustedAt int `json:"exhausted_at"`
+}
+
+// Dead returns the entries that are dead because of where they are listed.
+func (a *OrderAnalysis) Dead() []EntryReach {
+	var dead []EntryReach
+	for _, entry := range a.Entries {
+		if entry.Reach == ReachDead {
+			dead = append(dead, entry)
+		}
+	}
+	return dead
+}
+
+// AnalyzeOrder reports, for each entry of a .well-known/webauthn document, whether a
+// browser reaches it before maxLabels labels (MaxLabels if not positive) are charged, as
+// ValidateWellKnownJSON counts them. Since browsers stop charging new labels once the
+// budget is exhausted, the order of the array decides which entries are dead: an entry
+// whose label is first seen after that point is ignored, wherever its label's other
+// entries are. An entry that repeats an earlier counted origin is never reached either,
+// since matching stops at the first. It returns an error when the document is not valid
+// JSON or has no origins array.
+func AnalyzeOrder(jsonData []byte, maxLabels int) (*OrderAnalysis, error) {
+	maxLabels = labelLimit(maxLabels)
+
+	var webAuthnResp WebAuthnResponse
+	if err := json.Unmarshal(jsonData, &webAuthnResp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	if webAuthnResp.Origins == nil {
+		return nil, errors.New("failed to parse JSON: missing origins array")
+	}
+
+	analysis := &OrderAnalysis{Entries: []EntryReach{}, MaxLabels: maxLabels, ExhaustedAt: -1}
+	positions := make(map[string]int)
+	// firstIndex is the index of the first entry of each label
+	firstIndex := make(map[string]int)
+	var lastLabel string
+	counted := make(map[string]int)
+	for i, originStr := range webAuthnResp.Origins {
+		origin := lookupOrigin(originStr)
+		entry := EntryReach{Index: i, Origin: originStr, Label: origin.label}
+		key := originKey(origin.scheme, origin.host)
+
+		switch {
+		case origin.android:
+			entry.Reach = ReachAndroid
+		case !origin.ok:
+			entry.Reach = ReachSkipped
+			entry.Detail = "not a URL with a registrable domain, so it is never counted"
+		default:
+			position, seen := positions[origin.label]
+			if !seen {
+				position = len(positions) + 1
+				positions[origin.label] = position
+				firstIndex[origin.label] = i
+			}
+			entry.Position = position
+			switch {
+			case position > maxLabels:
+				entry.Reach = ReachDead
+				entry.Detail = fmt.Sprintf(
+					"label %q is label %d, seen after the budget of %d labels is exhausted at index %d; move it before index %d, the first entry of label %q, to count it in its place",
+					origin.label, position, maxLabels, analysis.ExhaustedAt, firstIndex[lastLabel], lastLabel)
+			case !seen:
+				entry.Reach = ReachCharged
+				if position == maxLabels {
+					analysis.ExhaustedAt = i
+					lastLabel = origin.label
+				}
+			default:
+				entry.Reach = ReachCounted
+			}
+		}
+
+		// A counted entry that repeats an earlier one is never matched
+		if entry.Reach == ReachCharged || entry.Reach == ReachCounted || entry.Reach == ReachAndroid {
+			if earlier, ok := counted[key]; ok {
+				entry.Reach = ReachShadowed
+				entry.Detail = fmt.Sprintf("index %d is the same origin and is matched first", earlier)
+			} else {
+				counted[key] = i
+			}
+		}
+		analysis.Entries = append(analysis.Entries, entry)
+	}
+	return analysis, nil
+}
+
+// FormatOrder returns a table of the reach of every entry, followed by a summary of the
+// entries that are dead because of where they are listed.
+func FormatOrder(a *OrderAnalysis) string {
+	var sb strings.Builder
+	if a.ExhaustedAt >= 0 {
+		fmt.Fprintf(&sb, "Label budget of %d exhausted at index %d\n", a.MaxLabels, a.ExhaustedAt)
+	} else {
+		fmt.Fprintf(&sb, "Label budget of %d not exhausted\n", a.MaxLabels)
+	}
+	for _, entry := range a.Entries {
+		label := "-"
+		if entry.Label != "" {
+			label = fmt.Sprintf("%s (%d)", entry.Label, entry.Position)
+		}
+		fmt.Fprintf(&sb, "  [%d] %s: %s, label %s", entry.Index, entry.Origin, entry.Reach, label)
+		if entry.Detail != "" {
+			fmt.Fprintf(&sb, ": %s", entry.Detail)
+		}
+		sb.WriteString("\n")
+	}
+	if dead := a.Dead(); len(dead) > 0 {
+		fmt.Fprintf(&sb, "%d of %d entries are dead because of their position in the list\n", len(dead), len(a.Entries))
+	}
+	return sb.String()
+}
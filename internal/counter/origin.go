@@ -0,0 +1,109 @@
+package counter
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// defaultPorts maps schemes to the port that is omitted from their serialized origin.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// serializeOrigin returns the scheme and host of the origin of u as browsers compare
+// them, following the URL and HTML specifications: in lowercase, with the port only if
+// it is not the default port of the scheme, and IPv6 literals in brackets.
+func serializeOrigin(u *url.URL) (scheme, host string) {
+	scheme = strings.ToLower(u.Scheme)
+	host = strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != defaultPorts[scheme] {
+		return scheme, net.JoinHostPort(host, port)
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return scheme, host
+}
+
+// SerializeOrigin returns the serialization of the origin of a URL, as browsers compare
+// it: a lowercase scheme and host without a default port, path, query or fragment. An
+// Android app origin is returned with its hash in canonical form.
+func SerializeOrigin(rawURL string) (string, error) {
+	if IsAndroidOrigin(rawURL) {
+		_, hash, err := parseAndroidOrigin(rawURL)
+		if err != nil {
+			return "", err
+		}
+		return AndroidOriginPrefix + hash, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid origin %q: %w", rawURL, err)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid origin %q: missing host", rawURL)
+	}
+	scheme, host := serializeOrigin(u)
+	return scheme + "://" + host, nil
+}
+
+// OriginChanges returns what browsers change in origin before they compare it with
+// another: an uppercase scheme or host, or a default port. It returns nil if there is
+// nothing to change or origin cannot be parsed. A path, query or fragment, which are
+// not part of an origin either, are not reported.
+func OriginChanges(origin string) []string {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	var changes []string
+	// url.Parse lowercases the scheme, so it is read from origin itself
+	if scheme, _, _ := strings.Cut(origin, ":"); scheme != u.Scheme {
+		changes = append(changes, fmt.Sprintf("scheme %q is not lowercase", scheme))
+	}
+	if u.Hostname() != strings.ToLower(u.Hostname()) {
+		changes = append(changes, fmt.Sprintf("host %q is not lowercase", u.Hostname()))
+	}
+	if port := u.Port(); port != "" && port == defaultPorts[strings.ToLower(u.Scheme)] {
+		changes = append(changes, fmt.Sprintf("port %s is the default port of %s", port, strings.ToLower(u.Scheme)))
+	}
+	return changes
+}
+
+// canonical returns the URL of the serialized origin of u, so that origins can be
+// compared by their Scheme and Host.
+func canonical(u *url.URL) *url.URL {
+	scheme, host := serializeOrigin(u)
+	return &url.URL{Scheme: scheme, Host: host}
+}
+
+// parseCallerURL is like parseCallerOrigin but returns the serialized origin as a URL.
+func parseCallerURL(callerOrigin string) (*url.URL, error) {
+	scheme, host, err := parseCallerOrigin(callerOrigin)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: scheme, Host: host}, nil
+}
+
+// parseCallerOrigin parses a caller origin into its serialized scheme and host. Unlike
+// the entries of a document, whose path browsers discard, a caller origin is an origin
+// serialization and must not have a path, query or fragment; a trailing slash is
+// tolerated. An Android app origin is parsed into its hash in place of a host.
+func parseCallerOrigin(callerOrigin string) (scheme, host string, err error) {
+	if IsAndroidOrigin(callerOrigin) {
+		return parseAndroidOrigin(callerOrigin)
+	}
+	u, err := url.Parse(callerOrigin)
+	if err != nil {
+		return "", "", err
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", "", fmt.Errorf("%q is not an origin: it has a path, query, fragment or user information", callerOrigin)
+	}
+	scheme, host = serializeOrigin(u)
+	return scheme, host, nil
+}
@@ -0,0 +1,147 @@
+package counter
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Formats returned by SniffFormat.
+const (
+	FormatJSON   = "json"
+	FormatHTML   = "html"
+	FormatXML    = "xml"
+	FormatText   = "text"
+	FormatEmpty  = "empty"
+	FormatBinary = "binary"
+)
+
+// SPAFallbackRemediation explains how to fix a single-page application serving its
+// HTML shell in place of the .well-known/webauthn document.
+const SPAFallbackRemediation = "Serve a static JSON file at " + WellKnownPath + " with \"Content-Type: application/json\" " +
+	"and exclude the path from the application's catch-all route (for example an explicit location block, " +
+	"a rewrite exception, or a file in the static assets directory that the router does not intercept)."
+
+// ContentTypeRemediation explains how to fix a JSON document served with the wrong content type.
+const ContentTypeRemediation = "Configure the server to send \"Content-Type: application/json\" for " + WellKnownPath +
+	"; browsers reject the document otherwise, even when the body is valid JSON."
+
+// trimBody removes a UTF-8 byte order mark and leading whitespace before sniffing.
+func trimBody(body []byte) []byte {
+	trimmed := bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	return bytes.TrimLeft(trimmed, " \t\r\n")
+}
+
+// SniffFormat inspects the body and returns the format it actually contains,
+// independent of any declared content type. HTML detection uses the same sniffing
+// rules browsers apply to responses without a usable content type.
+func SniffFormat(body []byte) string {
+	trimmed := trimBody(body)
+	if len(trimmed) == 0 {
+		return FormatEmpty
+	}
+
+	// JSON documents start with an object or array; malformed JSON is still reported as JSON
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		return FormatJSON
+	}
+
+	detected := http.DetectContentType(trimmed)
+	switch {
+	case strings.HasPrefix(detected, "text/html"):
+		return FormatHTML
+	case strings.HasPrefix(detected, "text/xml"):
+		return FormatXML
+	case strings.HasPrefix(detected, "text/plain"):
+		return FormatText
+	default:
+		return FormatBinary
+	}
+}
+
+// ContentTypePolicy controls which content types are accepted for the .well-known/webauthn document.
+type ContentTypePolicy int
+
+const (
+	// ContentTypeStrict accepts only application/json, exactly as browsers do.
+	ContentTypeStrict ContentTypePolicy = iota
+	// ContentTypeLenient additionally accepts JSON served as text/plain, reporting it as a warning.
+	ContentTypeLenient
+)
+
+// String returns a string representation of the ContentTypePolicy.
+func (p ContentTypePolicy) String() string {
+	switch p {
+	case ContentTypeStrict:
+		return "strict"
+	case ContentTypeLenient:
+		return "lenient"
+	default:
+		return fmt.Sprintf("UNKNOWN_CONTENT_TYPE_POLICY(%d)", p)
+	}
+}
+
+// ParseContentTypePolicy parses a content type policy name ("strict" or "lenient").
+func ParseContentTypePolicy(name string) (ContentTypePolicy, error) {
+	switch strings.ToLower(name) {
+	case "strict":
+		return ContentTypeStrict, nil
+	case "lenient":
+		return ContentTypeLenient, nil
+	default:
+		return ContentTypeStrict, fmt.Errorf("unknown content type policy %q (expected strict or lenient)", name)
+	}
+}
+
+// mediaType returns the lowercased media type of a Content-Type header value, without parameters.
+func mediaType(contentType string) string {
+	parsed, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// Fall back to everything before the first parameter
+		parsed, _, _ = strings.Cut(contentType, ";")
+	}
+	return strings.ToLower(strings.TrimSpace(parsed))
+}
+
+// IsJSONContentType reports whether a Content-Type header value is accepted as JSON by browsers.
+// Browsers compare the media type, ignoring parameters such as charset, against application/json.
+func IsJSONContentType(contentType string) bool {
+	return mediaType(contentType) == "application/json"
+}
+
+// checkContentType compares the declared content type with the sniffed body format under the
+// given policy. It returns a description of any mismatch that makes the document unusable and how
+// to fix it, and a warning for mismatches that the policy tolerates. A body served as JSON that
+// is neither HTML nor empty is left to the JSON parser, whose error locates what is wrong with it.
+func checkContentType(contentType, sniffedFormat string, policy ContentTypePolicy) (problem, remediation, warning string) {
+	declared := contentType
+	if declared == "" {
+		declared = "no content type"
+	}
+
+	if IsJSONContentType(contentType) {
+		switch sniffedFormat {
+		case FormatHTML:
+			return fmt.Sprintf("content type mismatch: HTML document served as %s", declared), SPAFallbackRemediation, ""
+		case FormatEmpty:
+			return fmt.Sprintf("content type mismatch: empty body served as %s", declared), "", ""
+		}
+		return "", "", ""
+	}
+
+	switch sniffedFormat {
+	case FormatHTML:
+		// An HTML page here almost always means an SPA fallback route answered the request
+		return fmt.Sprintf("SPA fallback page served at well-known path: received an HTML document with status 200 and content type %q", contentType), SPAFallbackRemediation, ""
+	case FormatJSON:
+		mismatch := fmt.Sprintf("content type mismatch: JSON document served as %s instead of application/json", declared)
+		if policy == ContentTypeLenient && mediaType(contentType) == "text/plain" {
+			return "", "", mismatch + "; accepted by the lenient content type policy, but browsers will reject it"
+		}
+		return mismatch, ContentTypeRemediation, ""
+	default:
+		return fmt.Sprintf("unexpected content type: %s", contentType), "", ""
+	}
+}
@@ -0,0 +1,174 @@
+package counter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/developmeh/passkey-origin-validator/internal/schema"
+)
+
+// snippetContext is how many characters of the offending line a snippet keeps on either
+// side of the offending character, so that a minified document is not printed whole.
+const snippetContext = 40
+
+// ParseError locates a JSON syntax error in a document, so that reports can point at the
+// offending character instead of only echoing encoding/json.
+type ParseError struct {
+	// Message is encoding/json's description of the error.
+	Message string `json:"message"`
+	// Offset is the byte offset of the offending character, or the length of the
+	// document when it ends too early.
+	Offset int `json:"offset"`
+	// Line and Column locate the offending character, starting at 1. Columns count
+	// characters, not bytes.
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	// Snippet is the offending line, shortened around the character if it is long,
+	// followed by a line with a caret under the character.
+	Snippet string `json:"snippet"`
+	// Hint suggests a fix for a common mistake, such as a trailing comma, if the error
+	// is recognized as one.
+	Hint string `json:"hint,omitempty"`
+}
+
+// Error returns the location of the error followed by its message and hint.
+func (e *ParseError) Error() string {
+	message := fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+	if e.Hint != "" {
+		message += fmt.Sprintf(" (%s)", e.Hint)
+	}
+	return message
+}
+
+// LocateParseError returns where the syntax error err, which encoding/json returned for
+// body, occurred, or nil if err is not a syntax error.
+func LocateParseError(body []byte, err error) *ParseError {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return nil
+	}
+
+	// encoding/json reports the offset after the offending character, or the length of
+	// the input when it ends too early
+	offset := int(min(max(syntaxErr.Offset, 0), int64(len(body))))
+	eof := strings.HasPrefix(syntaxErr.Error(), "unexpected end")
+	if eof {
+		offset = len(bytes.TrimRight(body, " \t\r\n"))
+	} else {
+		_, size := utf8.DecodeLastRune(body[:offset])
+		offset -= size
+	}
+
+	lineStart := bytes.LastIndexByte(body[:offset], '\n') + 1
+	lineEnd := len(body)
+	if i := bytes.IndexByte(body[offset:], '\n'); i >= 0 {
+		lineEnd = offset + i
+	}
+	line, column := position(body, offset)
+	return &ParseError{
+		Message: syntaxErr.Error(),
+		Offset:  offset,
+		Line:    line,
+		Column:  column,
+		Snippet: snippet([]rune(strings.TrimRight(string(body[lineStart:lineEnd]), "\r")), column-1),
+		Hint:    parseHint(body, offset, syntaxErr.Error(), eof),
+	}
+}
+
+// position returns the line and column, starting at 1, of the byte at offset. Columns
+// count characters, not bytes.
+func position(body []byte, offset int) (line, column int) {
+	lineStart := bytes.LastIndexByte(body[:offset], '\n') + 1
+	return bytes.Count(body[:offset], []byte("\n")) + 1, utf8.RuneCount(body[lineStart:offset]) + 1
+}
+
+// FormatSnippet returns the snippet of a parse error indented by two spaces, to print
+// after the error.
+func FormatSnippet(e *ParseError) string {
+	return "  " + strings.ReplaceAll(e.Snippet, "\n", "\n  ")
+}
+
+// snippet returns line, shortened to snippetContext characters around column (0-based),
+// followed by a line with a caret under it. Tabs are kept in the caret line so that the
+// caret lines up however they are displayed.
+func snippet(line []rune, column int) string {
+	start, end := max(column-snippetContext, 0), min(column+snippetContext, len(line))
+	prefix, suffix := "", ""
+	if start > 0 {
+		prefix = "..."
+	}
+	if end < len(line) {
+		suffix = "..."
+	}
+
+	var caret strings.Builder
+	caret.WriteString(strings.Repeat(" ", len(prefix)))
+	for _, r := range line[start:column] {
+		if r == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+	caret.WriteByte('^')
+	return prefix + string(line[start:end]) + suffix + "\n" + caret.String()
+}
+
+// parseHint returns a suggested fix for the syntax error message at offset when it is a
+// common mistake, or "" if it is not recognized.
+func parseHint(body []byte, offset int, message string, eof bool) string {
+	switch {
+	case len(bytes.TrimSpace(body)) == 0:
+		return "the document is empty"
+	case bytes.HasPrefix(body, []byte("\xef\xbb\xbf")) && offset == 0:
+		return "the document starts with a UTF-8 byte order mark; save it without one"
+	case eof:
+		return "the document ends before every array and object is closed; it may be truncated"
+	case offset >= len(body):
+		return ""
+	}
+
+	before := bytes.TrimRight(body[:offset], " \t\r\n")
+	switch c := body[offset]; {
+	case (c == ']' || c == '}') && bytes.HasSuffix(before, []byte(",")):
+		return fmt.Sprintf("remove the trailing comma on line %d; JSON does not allow one before %q",
+			bytes.Count(before, []byte("\n"))+1, c)
+	case c == '\'':
+		return "JSON strings must be quoted with double quotes"
+	case c == '/' || c == '#':
+		return "JSON does not allow comments"
+	case c == '<':
+		return "the document looks like HTML, not JSON"
+	case strings.HasSuffix(message, "after array element") || strings.HasSuffix(message, "after object key:value pair"):
+		return "a comma is missing before this character"
+	default:
+		return ""
+	}
+}
+
+// parseError describes why body, which encoding/json failed to parse with err, is not a
+// document: the location of a syntax error, or the JSON Pointer of each value that does
+// not match the schema, such as "/origins/2: expected string, got null".
+func parseError(body []byte, err error) string {
+	if located := LocateParseError(body, err); located != nil {
+		return located.Error()
+	}
+	violations, verr := schema.Validate(body)
+	if verr != nil {
+		return err.Error()
+	}
+	var problems []string
+	for _, violation := range violations {
+		if !violation.Unknown() {
+			problems = append(problems, violation.String())
+		}
+	}
+	if len(problems) == 0 {
+		return err.Error()
+	}
+	return strings.Join(problems, "; ")
+}
@@ -0,0 +1,211 @@
+package counter
+
+import (
+	"encoding/json"
+	"net/url"
+	"sync"
+	"sync/atomic"
+)
+
+// maxOriginCacheEntries bounds the number of parsed origins kept in memory, so that
+// validating many distinct documents cannot grow the cache without limit.
+const maxOriginCacheEntries = 4096
+
+// parsedOrigin is an origin from a .well-known/webauthn document, parsed once and
+// memoized, together with its eTLD+1 label.
+type parsedOrigin struct {
+	scheme string
+	host   string
+	label  string
+	// ok is false when the origin is not a URL, has no host, or has no eTLD+1 label.
+	ok bool
+	// android is true for a valid Android app origin, whose scheme is androidScheme and
+	// host its hash. It has no label, so ok is false.
+	android bool
+}
+
+var (
+	// originCache maps origin strings to their parsedOrigin.
+	originCache sync.Map
+	// originCacheSize is the number of entries stored in originCache.
+	originCacheSize atomic.Int64
+)
+
+// lookupOrigin parses an origin string and extracts its eTLD+1 label, reusing the result
+// from earlier calls. Relying parties serve the same document on every authentication,
+// so the URL parsing and public suffix lookup are only paid once per origin.
+func lookupOrigin(originStr string) parsedOrigin {
+	if cached, ok := originCache.Load(originStr); ok {
+		return cached.(parsedOrigin)
+	}
+
+	var origin parsedOrigin
+	if IsAndroidOrigin(originStr) {
+		if scheme, hash, err := parseAndroidOrigin(originStr); err == nil {
+			origin = parsedOrigin{scheme: scheme, host: hash, android: true}
+		}
+	} else if originURL, err := url.Parse(originStr); err == nil && originURL.Host != "" {
+		if label, err := getLabel(originURL.Host); err == nil {
+			scheme, host := serializeOrigin(originURL)
+			origin = parsedOrigin{
+				scheme: scheme,
+				host:   host,
+				label:  label,
+				ok:     true,
+			}
+		}
+	}
+
+	// Stop memoizing once the cache is full rather than evicting
+	if originCacheSize.Load() < maxOriginCacheEntries {
+		if _, loaded := originCache.LoadOrStore(originStr, origin); !loaded {
+			originCacheSize.Add(1)
+		}
+	}
+	return origin
+}
+
+// OriginLabel returns the eTLD+1 label that origin counts towards MaxLabels. ok is false
+// when origin is not a URL, has no host, or has no eTLD+1 label, in which case a browser
+// ignores it.
+func OriginLabel(origin string) (label string, ok bool) {
+	parsed := lookupOrigin(origin)
+	return parsed.label, parsed.ok
+}
+
+// originList decodes the origins array of a .well-known/webauthn document into a reused
+// slice. Unlike a plain slice, it records whether the key was present at all.
+type originList struct {
+	values  []string
+	present bool
+}
+
+// UnmarshalJSON decodes the origins array, reusing the backing array of values.
+func (l *originList) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &l.values); err != nil {
+		return err
+	}
+	// A JSON null leaves the origins array missing, as it does for WebAuthnResponse
+	l.present = l.values != nil
+	return nil
+}
+
+// pooledResponse is the pooled counterpart of WebAuthnResponse used by Validator.
+type pooledResponse struct {
+	Origins originList `json:"origins"`
+}
+
+// reset clears the response so that it can be returned to the pool.
+func (r *pooledResponse) reset() {
+	clear(r.Origins.values)
+	r.Origins.values = r.Origins.values[:0]
+	r.Origins.present = false
+}
+
+// responsePool reuses decoded responses, and the backing arrays of their origins,
+// between validations.
+var responsePool = sync.Pool{
+	New: func() any { return new(pooledResponse) },
+}
+
+// Validator checks .well-known/webauthn documents against a single caller origin.
+// The caller origin is parsed once by NewValidator rather than on every call, which
+// makes a Validator suited to embeddings that validate on every authentication.
+// A Validator is safe for concurrent use.
+type Validator struct {
+	scheme string
+	host   string
+	// maxLabels is the number of unique labels counted before origins are ignored.
+	maxLabels int
+	// err is the error from parsing the caller origin, if any.
+	err error
+}
+
+// NewValidator returns a Validator for the given caller origin.
+func NewValidator(callerOrigin string) *Validator {
+	return NewValidatorWithMaxLabels(callerOrigin, MaxLabels)
+}
+
+// NewValidatorWithMaxLabels is like NewValidator but the Validator counts at most
+// maxLabels unique labels instead of MaxLabels. A maxLabels that is not positive means
+// MaxLabels.
+func NewValidatorWithMaxLabels(callerOrigin string, maxLabels int) *Validator {
+	scheme, host, err := parseCallerOrigin(callerOrigin)
+	if err != nil {
+		return &Validator{maxLabels: labelLimit(maxLabels), err: err}
+	}
+	return &Validator{
+		scheme:    scheme,
+		host:      host,
+		maxLabels: labelLimit(maxLabels),
+	}
+}
+
+// Validate reports whether the caller origin is authorized by the given .well-known/webauthn
+// document. It returns the same status as ValidateWellKnownJSON.
+func (v *Validator) Validate(jsonData []byte) AuthenticatorStatus {
+	// Parse the JSON into a pooled response
+	webAuthnResp := responsePool.Get().(*pooledResponse)
+	defer func() {
+		webAuthnResp.reset()
+		responsePool.Put(webAuthnResp)
+	}()
+	if err := json.Unmarshal(jsonData, webAuthnResp); err != nil {
+		return StatusBadRelyingPartyIDJSONParseError
+	}
+
+	// Check if the origins array exists
+	if !webAuthnResp.Origins.present {
+		return StatusBadRelyingPartyIDJSONParseError
+	}
+
+	// The caller origin failed to parse in NewValidator
+	if v.err != nil {
+		return StatusBadRelyingPartyIDNoJSONMatch
+	}
+
+	// Track unique labels in an array on the stack, which only grows onto the heap when
+	// the limit is raised above MaxLabels
+	var labelArray [MaxLabels]string
+	uniqueLabels := labelArray[:0]
+	hitLimits := false
+
+	for _, originStr := range webAuthnResp.Origins.values {
+		origin := lookupOrigin(originStr)
+		// Android app origins have no label, so they never count towards the limit
+		if origin.android {
+			if origin.scheme == v.scheme && origin.host == v.host {
+				return StatusSuccess
+			}
+			continue
+		}
+		if !origin.ok {
+			continue
+		}
+
+		seen := false
+		for _, label := range uniqueLabels {
+			if label == origin.label {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			if len(uniqueLabels) >= v.maxLabels {
+				hitLimits = true
+				continue
+			}
+			uniqueLabels = append(uniqueLabels, origin.label)
+		}
+
+		// Check if the origin matches the caller origin
+		if origin.scheme == v.scheme && origin.host == v.host {
+			return StatusSuccess
+		}
+	}
+
+	if hitLimits {
+		return StatusBadRelyingPartyIDNoJSONMatchHitLimits
+	}
+	return StatusBadRelyingPartyIDNoJSONMatch
+}
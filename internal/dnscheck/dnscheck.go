@@ -0,0 +1,284 @@
+// Package dnscheck provides DNS preflight diagnostics for relying party domains.
+//
+// A "failed to fetch well-known URL" error is often a DNS problem in disguise. The
+// dnscheck package resolves the domain before any HTTP request is made and reports
+// what it found, so that resolution failures are visible on their own.
+package dnscheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNSSECStatus describes whether a DNS answer was DNSSEC-signed.
+type DNSSECStatus int
+
+const (
+	// DNSSECUnknown indicates that the DNSSEC status could not be determined.
+	DNSSECUnknown DNSSECStatus = iota
+	// DNSSECSigned indicates that the answer was signed and validated by the resolver.
+	DNSSECSigned
+	// DNSSECUnsigned indicates that the answer was not signed, or the resolver does not validate.
+	DNSSECUnsigned
+)
+
+// String returns a string representation of the DNSSECStatus.
+func (s DNSSECStatus) String() string {
+	switch s {
+	case DNSSECUnknown:
+		return "unknown"
+	case DNSSECSigned:
+		return "signed"
+	case DNSSECUnsigned:
+		return "unsigned"
+	default:
+		return fmt.Sprintf("UNKNOWN_DNSSEC_STATUS(%d)", s)
+	}
+}
+
+// Report is the result of resolving a single host.
+type Report struct {
+	Host     string
+	Resolver string
+	A        []string
+	AAAA     []string
+	CNAME    string
+	Latency  time.Duration
+	DNSSEC   DNSSECStatus
+	// HTTPS are the host's HTTPS records, which browsers consult before connecting
+	HTTPS  []ServiceRecord
+	Errors []string
+}
+
+// Resolved reports whether the host resolved to at least one address.
+func (r *Report) Resolved() bool {
+	return len(r.A) > 0 || len(r.AAAA) > 0
+}
+
+// normalizeAddr adds the default DNS port to a resolver address if it has none.
+func normalizeAddr(addr string) string {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return net.JoinHostPort(strings.Trim(addr, "[]"), "53")
+	}
+	return addr
+}
+
+// NewResolver returns a resolver that sends all queries to the DNS server at addr.
+// If addr is empty, the system resolver is returned.
+func NewResolver(addr string) *net.Resolver {
+	if addr == "" {
+		return net.DefaultResolver
+	}
+	addr = normalizeAddr(addr)
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// nameserverFor returns the address of the DNS server that resolverAddr names, or of the
+// system's first nameserver when it is empty. It returns "" if there is none.
+func nameserverFor(resolverAddr string) string {
+	nameserver := resolverAddr
+	if nameserver == "" {
+		nameserver = systemNameserver()
+	}
+	if nameserver == "" {
+		return ""
+	}
+	return normalizeAddr(nameserver)
+}
+
+// systemNameserver returns the first nameserver listed in /etc/resolv.conf.
+func systemNameserver() string {
+	data, err := os.ReadFile("/etc/resolv.conf")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// Check resolves host using the DNS server at resolverAddr (or the system resolver when empty)
+// and reports its A, AAAA, CNAME and HTTPS records, the resolution latency, and its DNSSEC status.
+func Check(ctx context.Context, host, resolverAddr string) *Report {
+	name := resolverAddr
+	if name == "" {
+		name = "system"
+	}
+	report := CheckResolver(ctx, host, NewResolver(resolverAddr), name)
+
+	// Ask the resolver directly whether the answer was authenticated, and for the HTTPS
+	// records the system resolver has no lookup for
+	if nameserver := nameserverFor(resolverAddr); nameserver != "" {
+		status, err := queryDNSSEC(ctx, nameserver, host)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("DNSSEC query failed: %s", err))
+		}
+		report.DNSSEC = status
+
+		records, err := queryHTTPS(ctx, nameserver, host)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("HTTPS record query failed: %s", err))
+		}
+		report.HTTPS = records
+	}
+
+	return report
+}
+
+// CheckResolver resolves host with r, reported as the resolver called name, and reports
+// its A, AAAA and CNAME records and the resolution latency. The DNSSEC status needs a
+// nameserver to query directly, so it is left unknown.
+func CheckResolver(ctx context.Context, host string, r Resolver, name string) *Report {
+	report := &Report{
+		Host:     host,
+		Resolver: name,
+	}
+
+	// Time the address lookup, since that is what a fetch waits on
+	start := time.Now()
+	addrs, err := r.LookupIPAddr(ctx, host)
+	report.Latency = time.Since(start)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("address lookup failed: %s", err))
+	}
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			report.A = append(report.A, addr.IP.String())
+		} else {
+			report.AAAA = append(report.AAAA, addr.IP.String())
+		}
+	}
+
+	// Only report the canonical name when it differs from the host itself
+	cname, err := r.LookupCNAME(ctx, host)
+	if err == nil && !strings.EqualFold(strings.TrimSuffix(cname, "."), strings.TrimSuffix(host, ".")) {
+		report.CNAME = cname
+	}
+
+	return report
+}
+
+// queryDNSSEC sends an A query with the DNSSEC OK bit set and inspects the response for the
+// Authenticated Data flag or RRSIG records.
+func queryDNSSEC(ctx context.Context, addr, host string) (DNSSECStatus, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return DNSSECUnknown, err
+	}
+
+	// Build the query with an EDNS0 OPT record requesting DNSSEC records
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(1232, dnsmessage.RCodeSuccess, true); err != nil {
+		return DNSSECUnknown, err
+	}
+	id := uint16(time.Now().UnixNano())
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true, AuthenticData: true},
+		Questions: []dnsmessage.Question{
+			{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET},
+		},
+		Additionals: []dnsmessage.Resource{
+			{Header: opt, Body: &dnsmessage.OPTResource{}},
+		},
+	}
+	resp, err := exchange(ctx, addr, msg)
+	if err != nil {
+		return DNSSECUnknown, err
+	}
+
+	if resp.Header.AuthenticData {
+		return DNSSECSigned, nil
+	}
+	for _, answer := range resp.Answers {
+		// RRSIG has no dedicated type in dnsmessage
+		if answer.Header.Type == dnsmessage.Type(46) {
+			return DNSSECSigned, nil
+		}
+	}
+	return DNSSECUnsigned, nil
+}
+
+// exchange sends msg to the DNS server at addr over UDP and returns its response.
+func exchange(ctx context.Context, addr string, msg dnsmessage.Message) (*dnsmessage.Message, error) {
+	query, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf[:n]); err != nil {
+		return nil, err
+	}
+	if resp.Header.ID != msg.Header.ID {
+		return nil, errors.New("response ID does not match query")
+	}
+	return &resp, nil
+}
+
+// FormatReport formats a DNS report into a human-readable string.
+func FormatReport(report *Report) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("DNS: %s (resolver: %s)\n", report.Host, report.Resolver))
+	if report.CNAME != "" {
+		sb.WriteString(fmt.Sprintf("  CNAME: %s\n", report.CNAME))
+	}
+	for _, a := range report.A {
+		sb.WriteString(fmt.Sprintf("  A: %s\n", a))
+	}
+	for _, aaaa := range report.AAAA {
+		sb.WriteString(fmt.Sprintf("  AAAA: %s\n", aaaa))
+	}
+	for _, record := range report.HTTPS {
+		sb.WriteString(fmt.Sprintf("  HTTPS: %s\n", record))
+	}
+	for _, note := range httpsNotes(report) {
+		sb.WriteString(fmt.Sprintf("  Note: %s\n", note))
+	}
+	sb.WriteString(fmt.Sprintf("  Latency: %s\n", report.Latency.Round(time.Millisecond)))
+	sb.WriteString(fmt.Sprintf("  DNSSEC: %s\n", report.DNSSEC))
+	for _, e := range report.Errors {
+		sb.WriteString(fmt.Sprintf("  Error: %s\n", e))
+	}
+	if !report.Resolved() {
+		sb.WriteString("  The domain did not resolve; fetching the .well-known/webauthn endpoint will fail.\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,304 @@
+package dnscheck
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// startFakeDNS starts a UDP DNS server that answers A queries with 192.0.2.10 and HTTPS
+// queries with an endpoint offering h3 and h2 with ECH, hinting 192.0.2.20, and sets the
+// Authenticated Data flag when signed is true.
+func startFakeDNS(t *testing.T, signed bool) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) == 0 {
+				continue
+			}
+
+			question := query.Questions[0]
+			resp := dnsmessage.Message{
+				Header: dnsmessage.Header{
+					ID:                 query.Header.ID,
+					Response:           true,
+					RecursionAvailable: true,
+					AuthenticData:      signed,
+				},
+				Questions: query.Questions,
+			}
+			if question.Type == dnsmessage.TypeA {
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 10}},
+				})
+			}
+			if question.Type == dnsmessage.TypeHTTPS {
+				record := dnsmessage.HTTPSResource{SVCBResource: dnsmessage.SVCBResource{Priority: 1, Target: dnsmessage.MustNewName(".")}}
+				record.SetParam(dnsmessage.SVCParamALPN, []byte("\x02h3\x02h2"))
+				record.SetParam(dnsmessage.SVCParamIPv4Hint, []byte{192, 0, 2, 20})
+				record.SetParam(dnsmessage.SVCParamECH, []byte{0, 0})
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeHTTPS, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &record,
+				})
+			}
+
+			packed, err := resp.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(packed, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// TestCheck tests the Check function.
+func TestCheck(t *testing.T) {
+	t.Run("Signed answer", func(t *testing.T) {
+		addr := startFakeDNS(t, true)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		report := Check(ctx, "example.test", addr)
+		if !report.Resolved() {
+			t.Fatalf("Expected the host to resolve, got errors %v", report.Errors)
+		}
+		if len(report.A) != 1 || report.A[0] != "192.0.2.10" {
+			t.Errorf("Expected A record 192.0.2.10, got %v", report.A)
+		}
+		if report.DNSSEC != DNSSECSigned {
+			t.Errorf("Expected DNSSEC %s, got %s", DNSSECSigned, report.DNSSEC)
+		}
+	})
+
+	t.Run("Unsigned answer", func(t *testing.T) {
+		addr := startFakeDNS(t, false)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		report := Check(ctx, "example.test", addr)
+		if report.DNSSEC != DNSSECUnsigned {
+			t.Errorf("Expected DNSSEC %s, got %s", DNSSECUnsigned, report.DNSSEC)
+		}
+		if !strings.Contains(FormatReport(report), "A: 192.0.2.10") {
+			t.Errorf("Expected report to list the A record, got %s", FormatReport(report))
+		}
+	})
+
+	t.Run("HTTPS records", func(t *testing.T) {
+		addr := startFakeDNS(t, false)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		report := Check(ctx, "example.test", addr)
+		want := ServiceRecord{Priority: 1, Target: ".", ALPN: []string{"h3", "h2"}, IPv4Hints: []string{"192.0.2.20"}, ECH: true}
+		if len(report.HTTPS) != 1 || !reflect.DeepEqual(report.HTTPS[0], want) {
+			t.Fatalf("Expected HTTPS record %+v, got %+v", want, report.HTTPS)
+		}
+		output := FormatReport(report)
+		for _, expected := range []string{"HTTPS: 1 . alpn=h3,h2 ipv4hint=192.0.2.20 ech", "hints 192.0.2.20 are not A/AAAA records", "Encrypted Client Hello"} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("Expected report to contain %q, got %s", expected, output)
+			}
+		}
+	})
+}
+
+// TestHTTPSDialContext tests connecting to the endpoints of HTTPS records.
+func TestHTTPSDialContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen returned an error: %v", err)
+	}
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	// Records for another port than 443 are published under a prefix of the host name
+	records := map[string][]ServiceRecord{
+		"_" + port + "._https.example.test": {
+			{Priority: 2, Target: ".", ALPN: []string{"h2"}, IPv4Hints: []string{"127.0.0.1"}},
+			{Priority: 1, Target: ".", ALPN: []string{"h3"}, NoDefaultALPN: true, IPv4Hints: []string{"192.0.2.1"}},
+		},
+		"_" + port + "._https.alias.test": {{Priority: 0, Target: "svc.test."}},
+		"svc.test":                        {{Priority: 1, Target: ".", IPv4Hints: []string{"127.0.0.1"}}},
+	}
+	lookup := func(_ context.Context, name string) ([]ServiceRecord, error) {
+		return records[name], nil
+	}
+	var dialed []string
+	var dialer net.Dialer
+	dial := HTTPSDialContext(lookup, func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		if host, _, _ := net.SplitHostPort(addr); net.ParseIP(host) == nil {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	})
+
+	for _, host := range []string{"example.test", "alias.test"} {
+		dialed = nil
+		conn, err := dial(context.Background(), "tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			t.Fatalf("Dial of %s returned an error: %v", host, err)
+		}
+		conn.Close()
+		// The HTTP/3-only endpoint is skipped
+		if len(dialed) != 1 || dialed[0] != listener.Addr().String() {
+			t.Errorf("Expected to dial %s for %s, dialed %v", listener.Addr(), host, dialed)
+		}
+	}
+
+	// Without records the address itself is dialed
+	dialed = nil
+	if _, err := dial(context.Background(), "tcp", net.JoinHostPort("none.test", port)); err == nil || len(dialed) != 1 {
+		t.Errorf("Expected one failed dial of the address, got %v, dialed %v", err, dialed)
+	}
+}
+
+// TestNormalizeAddr tests the normalizeAddr function.
+func TestNormalizeAddr(t *testing.T) {
+	tests := map[string]string{
+		"1.1.1.1":         "1.1.1.1:53",
+		"1.1.1.1:5353":    "1.1.1.1:5353",
+		"2606:4700::1111": "[2606:4700::1111]:53",
+		"[::1]:53":        "[::1]:53",
+	}
+	for input, expected := range tests {
+		if got := normalizeAddr(input); got != expected {
+			t.Errorf("normalizeAddr(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
+
+// TestHosts tests parsing a hosts file and dialing the addresses it maps names to.
+func TestHosts(t *testing.T) {
+	hosts, err := ParseHosts(strings.NewReader("# staging\n127.0.0.1 staging.example.com login.example.com # both\n\n::1 staging.example.com\n"))
+	if err != nil {
+		t.Fatalf("ParseHosts returned an error: %v", err)
+	}
+	if ips := hosts.Lookup("Staging.Example.com."); len(ips) != 2 || !ips[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("Unexpected addresses %v", ips)
+	}
+	if ips := hosts.Lookup("example.com"); ips != nil {
+		t.Errorf("Expected no addresses for an unmapped host, got %v", ips)
+	}
+	report := hosts.Report("staging.example.com")
+	if report == nil || len(report.A) != 1 || len(report.AAAA) != 1 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	for _, input := range []string{"127.0.0.1\n", "staging staging.example.com\n"} {
+		if _, err := ParseHosts(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseHosts(%q) succeeded, want an error", input)
+		}
+	}
+
+	// Dial a mapped host and make sure the connection goes to the mapped address
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen returned an error: %v", err)
+	}
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	var dialed []string
+	var dialer net.Dialer
+	dial := hosts.DialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return dialer.DialContext(ctx, network, addr)
+	})
+	conn, err := dial(context.Background(), "tcp", net.JoinHostPort("login.example.com", port))
+	if err != nil {
+		t.Fatalf("Dial returned an error: %v", err)
+	}
+	conn.Close()
+	if len(dialed) != 1 || dialed[0] != listener.Addr().String() {
+		t.Errorf("Expected to dial %s, dialed %v", listener.Addr(), dialed)
+	}
+}
+
+// fakeResolver resolves the names in a map and fails for every other name.
+type fakeResolver map[string][]net.IPAddr
+
+func (r fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	if addrs, ok := r[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (r fakeResolver) LookupCNAME(_ context.Context, host string) (string, error) {
+	return host + ".", nil
+}
+
+// TestResolver tests checking and dialing through a custom resolver, and layering a
+// hosts file over it.
+func TestResolver(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen returned an error: %v", err)
+	}
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	fake := fakeResolver{"rp.internal": {{IP: net.ParseIP("::1")}, {IP: net.ParseIP("127.0.0.1")}}}
+	ctx := context.Background()
+
+	report := CheckResolver(ctx, "rp.internal", fake, "discovery")
+	if report.Resolver != "discovery" || len(report.A) != 1 || len(report.AAAA) != 1 || report.CNAME != "" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if report := CheckResolver(ctx, "missing.internal", fake, "discovery"); report.Resolved() || len(report.Errors) == 0 {
+		t.Errorf("Expected an unresolved report with an error, got %+v", report)
+	}
+
+	if ips, err := LookupIP(ctx, fake, "ip4", "rp.internal"); err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("LookupIP returned %v, %v", ips, err)
+	}
+
+	// Only the IPv4 address can be dialed over tcp4
+	dial := DialContext(fake, &net.Dialer{Timeout: 5 * time.Second})
+	conn, err := dial(ctx, "tcp4", net.JoinHostPort("rp.internal", port))
+	if err != nil {
+		t.Fatalf("Dial returned an error: %v", err)
+	}
+	conn.Close()
+	if _, err := dial(ctx, "tcp", net.JoinHostPort("missing.internal", port)); err == nil {
+		t.Error("Expected an error dialing a name the resolver does not know")
+	}
+
+	// A hosts file answers for its own names and passes the rest on
+	hosts := Hosts{"staging.example.com": {net.ParseIP("127.0.0.1")}}
+	layered := hosts.Resolver(fake)
+	if ips, err := LookupIP(ctx, layered, "ip", "Staging.Example.com"); err != nil || len(ips) != 1 {
+		t.Errorf("Expected the mapped address, got %v, %v", ips, err)
+	}
+	if ips, err := LookupIP(ctx, layered, "ip", "rp.internal"); err != nil || len(ips) != 2 {
+		t.Errorf("Expected the resolver's addresses, got %v, %v", ips, err)
+	}
+	if Hosts(nil).Resolver(fake) == nil {
+		t.Error("Expected an empty hosts file to return the next resolver")
+	}
+}
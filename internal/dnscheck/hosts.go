@@ -0,0 +1,105 @@
+package dnscheck
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// Hosts maps host names to the addresses connections are made to instead of resolving
+// the names, like the system hosts file but only for this tool's own connections. It
+// lets the tool check pre-production stacks under their production names.
+type Hosts map[string][]net.IP
+
+// ParseHosts parses a file in the hosts file format: an IP address followed by one or
+// more host names on each line, with comments starting with "#". A name listed on
+// several lines maps to every address given for it, in order.
+func ParseHosts(r io.Reader) (Hosts, error) {
+	hosts := make(Hosts)
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("hosts file line %d: expected an address and at least one host name", lineNum)
+		}
+		ip := net.ParseIP(strings.Trim(fields[0], "[]"))
+		if ip == nil {
+			return nil, fmt.Errorf("hosts file line %d: invalid address %q", lineNum, fields[0])
+		}
+		for _, name := range fields[1:] {
+			name = canonicalHost(name)
+			hosts[name] = append(hosts[name], ip)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read hosts file: %w", err)
+	}
+	return hosts, nil
+}
+
+// LoadHosts reads and parses the hosts file at path.
+func LoadHosts(path string) (Hosts, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hosts file: %w", err)
+	}
+	defer f.Close()
+	return ParseHosts(f)
+}
+
+// canonicalHost returns the form host names are compared in.
+func canonicalHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// Lookup returns the addresses host is mapped to, or nil if it is not in the file.
+func (h Hosts) Lookup(host string) []net.IP {
+	return h[canonicalHost(host)]
+}
+
+// DialContext wraps dial so that connections to a mapped host are made to its addresses
+// instead, trying each in order. The host name itself is kept for everything above the
+// connection, such as the Host header and TLS server name verification.
+func (h Hosts) DialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if len(h) == 0 {
+		return dial
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return dial(ctx, network, addr)
+		}
+		ips := h.Lookup(host)
+		if ips == nil {
+			return dial(ctx, network, addr)
+		}
+
+		return dialEach(ctx, dial, network, ips, port)
+	}
+}
+
+// Report returns a report of the addresses host is mapped to, in place of a DNS check.
+// It returns nil if host is not in the file.
+func (h Hosts) Report(host string) *Report {
+	ips := h.Lookup(host)
+	if ips == nil {
+		return nil
+	}
+	report := &Report{Host: host, Resolver: "hosts file"}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			report.A = append(report.A, ip.String())
+		} else {
+			report.AAAA = append(report.AAAA, ip.String())
+		}
+	}
+	return report
+}
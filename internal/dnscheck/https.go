@@ -0,0 +1,280 @@
+package dnscheck
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxAliasHops is the number of HTTPS alias records followed before giving up, as
+// browsers limit alias chains.
+const maxAliasHops = 4
+
+// ServiceRecord is an HTTPS record (RFC 9460), which tells browsers where and how to
+// connect to a host before they resolve its addresses.
+type ServiceRecord struct {
+	// Priority orders the endpoints, lowest first; 0 makes the record an alias of Target
+	Priority uint16
+	// Target is the name the endpoint is reached at, or "." for the owner name itself
+	Target string
+	// ALPN are the protocols the endpoint offers, in addition to http/1.1 unless NoDefaultALPN
+	ALPN          []string
+	NoDefaultALPN bool
+	// Port is the port to connect to, or 0 for the default port
+	Port      uint16
+	IPv4Hints []string
+	IPv6Hints []string
+	// ECH reports whether the endpoint offers Encrypted Client Hello
+	ECH bool
+}
+
+// Alias reports whether the record is in alias mode, naming another host whose records apply.
+func (r ServiceRecord) Alias() bool {
+	return r.Priority == 0
+}
+
+// Protocols returns the protocols the endpoint offers.
+func (r ServiceRecord) Protocols() []string {
+	protocols := slices.Clone(r.ALPN)
+	if !r.NoDefaultALPN && !slices.Contains(protocols, "http/1.1") {
+		protocols = append(protocols, "http/1.1")
+	}
+	return protocols
+}
+
+// SupportsHTTP reports whether the endpoint offers HTTP/1.1 or HTTP/2, the protocols the
+// tool's connections speak. An endpoint that only offers HTTP/3 does not.
+func (r ServiceRecord) SupportsHTTP() bool {
+	protocols := r.Protocols()
+	return slices.Contains(protocols, "http/1.1") || slices.Contains(protocols, "h2")
+}
+
+// String returns the record in the zone file presentation format, without the owner name.
+func (r ServiceRecord) String() string {
+	parts := []string{strconv.Itoa(int(r.Priority)), r.Target}
+	if len(r.ALPN) > 0 {
+		parts = append(parts, "alpn="+strings.Join(r.ALPN, ","))
+	}
+	if r.NoDefaultALPN {
+		parts = append(parts, "no-default-alpn")
+	}
+	if r.Port != 0 {
+		parts = append(parts, fmt.Sprintf("port=%d", r.Port))
+	}
+	if len(r.IPv4Hints) > 0 {
+		parts = append(parts, "ipv4hint="+strings.Join(r.IPv4Hints, ","))
+	}
+	if r.ECH {
+		parts = append(parts, "ech")
+	}
+	if len(r.IPv6Hints) > 0 {
+		parts = append(parts, "ipv6hint="+strings.Join(r.IPv6Hints, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// httpsName returns the name the HTTPS records of host are published at for connections
+// to port: the host itself for the default port 443, or "_port._https.host" otherwise.
+func httpsName(host, port string) string {
+	host = strings.TrimSuffix(host, ".")
+	if port == "" || port == "443" {
+		return host
+	}
+	return "_" + port + "._https." + host
+}
+
+// LookupHTTPS returns the HTTPS records published at name, queried from the DNS server at
+// resolverAddr, or the system's first nameserver when it is empty.
+func LookupHTTPS(ctx context.Context, resolverAddr, name string) ([]ServiceRecord, error) {
+	nameserver := nameserverFor(resolverAddr)
+	if nameserver == "" {
+		return nil, errors.New("no nameserver to query for HTTPS records")
+	}
+	return queryHTTPS(ctx, nameserver, name)
+}
+
+// queryHTTPS sends an HTTPS query for name to the DNS server at addr and returns the
+// records of the answer, ordered by priority.
+func queryHTTPS(ctx context.Context, addr, name string) ([]ServiceRecord, error) {
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(1232, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: uint16(time.Now().UnixNano()), RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: qname, Type: dnsmessage.TypeHTTPS, Class: dnsmessage.ClassINET},
+		},
+		Additionals: []dnsmessage.Resource{
+			{Header: opt, Body: &dnsmessage.OPTResource{}},
+		},
+	}
+	resp, err := exchange(ctx, addr, msg)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Header.RCode != dnsmessage.RCodeSuccess && resp.Header.RCode != dnsmessage.RCodeNameError {
+		return nil, fmt.Errorf("resolver answered %s", resp.Header.RCode)
+	}
+
+	var records []ServiceRecord
+	for _, answer := range resp.Answers {
+		if body, ok := answer.Body.(*dnsmessage.HTTPSResource); ok {
+			records = append(records, serviceRecord(&body.SVCBResource))
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Priority < records[j].Priority
+	})
+	return records, nil
+}
+
+// serviceRecord decodes the parameters of an SVCB or HTTPS resource.
+func serviceRecord(r *dnsmessage.SVCBResource) ServiceRecord {
+	record := ServiceRecord{
+		Priority: r.Priority,
+		Target:   r.Target.String(),
+	}
+	if value, ok := r.GetParam(dnsmessage.SVCParamALPN); ok {
+		// A list of length-prefixed protocol IDs
+		for len(value) > 0 && int(value[0]) < len(value) {
+			record.ALPN = append(record.ALPN, string(value[1:1+value[0]]))
+			value = value[1+value[0]:]
+		}
+	}
+	_, record.NoDefaultALPN = r.GetParam(dnsmessage.SVCParamNoDefaultALPN)
+	if value, ok := r.GetParam(dnsmessage.SVCParamPort); ok && len(value) == 2 {
+		record.Port = binary.BigEndian.Uint16(value)
+	}
+	if value, ok := r.GetParam(dnsmessage.SVCParamIPv4Hint); ok {
+		for ; len(value) >= net.IPv4len; value = value[net.IPv4len:] {
+			record.IPv4Hints = append(record.IPv4Hints, net.IP(value[:net.IPv4len]).String())
+		}
+	}
+	if value, ok := r.GetParam(dnsmessage.SVCParamIPv6Hint); ok {
+		for ; len(value) >= net.IPv6len; value = value[net.IPv6len:] {
+			record.IPv6Hints = append(record.IPv6Hints, net.IP(value[:net.IPv6len]).String())
+		}
+	}
+	_, record.ECH = r.GetParam(dnsmessage.SVCParamECH)
+	return record
+}
+
+// httpsNotes explains how the HTTPS records of a report change where browsers fetch the
+// well-known file from, compared with its A and AAAA records.
+func httpsNotes(report *Report) []string {
+	var notes []string
+	addrs := append(slices.Clone(report.A), report.AAAA...)
+	var unlisted []string
+	ech := false
+	for _, record := range report.HTTPS {
+		if record.Alias() {
+			notes = append(notes, fmt.Sprintf("the HTTPS record makes %s an alias of %s, whose endpoints browsers fetch from", report.Host, record.Target))
+			continue
+		}
+		if record.Target != "." {
+			notes = append(notes, fmt.Sprintf("browsers connect to %s instead of %s", record.Target, report.Host))
+		}
+		if record.Port != 0 && record.Port != 443 {
+			notes = append(notes, fmt.Sprintf("browsers connect to port %d instead of 443", record.Port))
+		}
+		if !record.SupportsHTTP() {
+			notes = append(notes, fmt.Sprintf("the endpoint at priority %d only offers %s, which this tool does not speak", record.Priority, strings.Join(record.Protocols(), ",")))
+		}
+		for _, hint := range append(slices.Clone(record.IPv4Hints), record.IPv6Hints...) {
+			if record.Target == "." && !slices.Contains(addrs, hint) && !slices.Contains(unlisted, hint) {
+				unlisted = append(unlisted, hint)
+			}
+		}
+		ech = ech || record.ECH
+	}
+	if len(unlisted) > 0 {
+		notes = append(notes, fmt.Sprintf("the address hints %s are not A/AAAA records of %s; browsers may connect to them", strings.Join(unlisted, ", "), report.Host))
+	}
+	if ech {
+		notes = append(notes, "the domain offers Encrypted Client Hello; browsers that support it hide the server name, which this tool sends in the clear")
+	}
+	return notes
+}
+
+// HTTPSDialContext wraps dial so that connections follow the HTTPS records that lookup
+// returns for a name, like browsers: alias records are followed, and the endpoints are
+// tried in priority order, at their port, first at their address hints and then at their
+// target name. Endpoints that only offer protocols the tool does not speak, such as
+// HTTP/3, are skipped. The address itself is dialed when there are no records or no
+// endpoint answers, and the host name is kept for everything above the connection.
+func HTTPSDialContext(lookup func(ctx context.Context, name string) ([]ServiceRecord, error), dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		records, err := lookup(ctx, httpsName(host, port))
+		// Follow aliases to the name whose endpoints apply
+		for hops := 0; err == nil && len(records) > 0 && records[0].Alias() && hops < maxAliasHops; hops++ {
+			if records[0].Target == "." {
+				records = nil
+				break
+			}
+			host = strings.TrimSuffix(records[0].Target, ".")
+			records, err = lookup(ctx, host)
+		}
+		if err != nil || len(records) == 0 {
+			return dial(ctx, network, net.JoinHostPort(host, port))
+		}
+
+		var errs []error
+		for _, record := range records {
+			if record.Alias() || !record.SupportsHTTP() {
+				continue
+			}
+			target, targetPort := host, port
+			if record.Target != "." {
+				target = strings.TrimSuffix(record.Target, ".")
+			}
+			if record.Port != 0 {
+				targetPort = strconv.Itoa(int(record.Port))
+			}
+
+			var hints []net.IP
+			for _, hint := range append(slices.Clone(record.IPv4Hints), record.IPv6Hints...) {
+				if ip := net.ParseIP(hint); ip != nil && inFamily(ip, network) {
+					hints = append(hints, ip)
+				}
+			}
+			if len(hints) > 0 {
+				conn, err := dialEach(ctx, dial, network, hints, targetPort)
+				if err == nil {
+					return conn, nil
+				}
+				errs = append(errs, err)
+			}
+			conn, err := dial(ctx, network, net.JoinHostPort(target, targetPort))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, errors.Join(append(errs, err)...)
+		}
+		return conn, nil
+	}
+}
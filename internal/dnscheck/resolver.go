@@ -0,0 +1,124 @@
+package dnscheck
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
+
+// Resolver resolves host names. *net.Resolver implements it, and every lookup the tool
+// makes goes through one, so that embedders can supply their own resolution, such as
+// service discovery or a fake in tests.
+type Resolver interface {
+	// LookupIPAddr returns the addresses of host.
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	// LookupCNAME returns the canonical name of host.
+	LookupCNAME(ctx context.Context, host string) (string, error)
+}
+
+// DefaultResolver is the system resolver.
+var DefaultResolver Resolver = net.DefaultResolver
+
+// LookupIP returns the addresses of host resolved with r in the given family ("ip", "ip4"
+// or "ip6").
+func LookupIP(ctx context.Context, r Resolver, family, host string) ([]net.IP, error) {
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		if inFamily(addr.IP, family) {
+			ips = append(ips, addr.IP)
+		}
+	}
+	return ips, nil
+}
+
+// inFamily reports whether ip belongs to an address family or network such as "ip4" or
+// "tcp6". A family without a version matches every address.
+func inFamily(ip net.IP, family string) bool {
+	switch {
+	case strings.HasSuffix(family, "4"):
+		return ip.To4() != nil
+	case strings.HasSuffix(family, "6"):
+		return ip.To4() == nil
+	default:
+		return true
+	}
+}
+
+// DialContext returns a dial function that resolves host names with r before connecting
+// with dialer, trying each address in order. A *net.Resolver is handed to the dialer
+// itself, which then also races IPv4 and IPv6 addresses.
+func DialContext(r Resolver, dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if nr, ok := r.(*net.Resolver); ok {
+		d := *dialer
+		d.Resolver = nr
+		return d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		ips, err := LookupIP(ctx, r, network, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, &net.DNSError{Err: "no suitable address found", Name: host, IsNotFound: true}
+		}
+		return dialEach(ctx, dialer.DialContext, network, ips, port)
+	}
+}
+
+// dialEach dials port on each of ips in order and returns the first connection made.
+func dialEach(ctx context.Context, dial func(ctx context.Context, network, addr string) (net.Conn, error), network string, ips []net.IP, port string) (net.Conn, error) {
+	var errs []error
+	for _, ip := range ips {
+		conn, err := dial(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// hostsResolver answers for the hosts in a Hosts and passes every other lookup to next.
+type hostsResolver struct {
+	hosts Hosts
+	next  Resolver
+}
+
+// LookupIPAddr returns the mapped addresses of host, or resolves it with next.
+func (r hostsResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips := r.hosts.Lookup(host)
+	if ips == nil {
+		return r.next.LookupIPAddr(ctx, host)
+	}
+	addrs := make([]net.IPAddr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: ip})
+	}
+	return addrs, nil
+}
+
+// LookupCNAME returns a mapped host as its own canonical name, or resolves it with next.
+func (r hostsResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	if r.hosts.Lookup(host) == nil {
+		return r.next.LookupCNAME(ctx, host)
+	}
+	return canonicalHost(host) + ".", nil
+}
+
+// Resolver returns a resolver that answers with the mapped addresses of the hosts in h
+// and resolves every other host with next. It returns next if h is empty.
+func (h Hosts) Resolver(next Resolver) Resolver {
+	if len(h) == 0 {
+		return next
+	}
+	return hostsResolver{hosts: h, next: next}
+}
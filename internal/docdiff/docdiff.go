@@ -0,0 +1,172 @@
+// Package docdiff compares two .well-known/webauthn documents at the semantic level:
+// which origins and labels were added or removed, and which caller origins are
+// authorized differently as a result.
+package docdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// OutcomeChange is a caller origin whose validation status differs between documents.
+type OutcomeChange struct {
+	Origin string
+	Before counter.AuthenticatorStatus
+	After  counter.AuthenticatorStatus
+}
+
+// Lost reports whether the caller origin was authorized before and is not after.
+func (c OutcomeChange) Lost() bool {
+	return c.Before == counter.StatusSuccess && c.After != counter.StatusSuccess
+}
+
+// Diff is the semantic difference between two documents.
+type Diff struct {
+	OriginsAdded   []string
+	OriginsRemoved []string
+	LabelsAdded    []string
+	LabelsRemoved  []string
+	// Outcomes are the caller origins whose validation status changed, in sorted order.
+	Outcomes []OutcomeChange
+}
+
+// Empty reports whether the documents are semantically the same.
+func (d *Diff) Empty() bool {
+	return len(d.OriginsAdded) == 0 && len(d.OriginsRemoved) == 0 &&
+		len(d.LabelsAdded) == 0 && len(d.LabelsRemoved) == 0 && len(d.Outcomes) == 0
+}
+
+// Regressed reports whether any caller origin lost its authorization.
+func (d *Diff) Regressed() bool {
+	for _, change := range d.Outcomes {
+		if change.Lost() {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare compares two documents. Validation outcomes are compared for every origin
+// listed by either document and for the given caller origins. It returns an error when
+// either document is not valid JSON.
+func Compare(before, after []byte, callerOrigins []string) (*Diff, error) {
+	return CompareWithMaxLabels(before, after, callerOrigins, counter.MaxLabels)
+}
+
+// CompareWithMaxLabels is like Compare but validates with a limit of maxLabels labels
+// instead of MaxLabels. A maxLabels that is not positive means MaxLabels.
+func CompareWithMaxLabels(before, after []byte, callerOrigins []string, maxLabels int) (*Diff, error) {
+	beforeOrigins, err := origins(before)
+	if err != nil {
+		return nil, fmt.Errorf("before: %w", err)
+	}
+	afterOrigins, err := origins(after)
+	if err != nil {
+		return nil, fmt.Errorf("after: %w", err)
+	}
+
+	d := &Diff{
+		OriginsAdded:   difference(afterOrigins, beforeOrigins),
+		OriginsRemoved: difference(beforeOrigins, afterOrigins),
+	}
+	beforeLabels, afterLabels := labels(beforeOrigins), labels(afterOrigins)
+	d.LabelsAdded = difference(afterLabels, beforeLabels)
+	d.LabelsRemoved = difference(beforeLabels, afterLabels)
+
+	// Check every origin either document mentions, plus the requested ones
+	candidates := make(map[string]bool)
+	for _, list := range [][]string{beforeOrigins, afterOrigins, callerOrigins} {
+		for _, origin := range list {
+			candidates[origin] = true
+		}
+	}
+	for origin := range candidates {
+		statusBefore := counter.ValidateWellKnownJSONWithMaxLabels(origin, before, maxLabels)
+		statusAfter := counter.ValidateWellKnownJSONWithMaxLabels(origin, after, maxLabels)
+		if statusBefore != statusAfter {
+			d.Outcomes = append(d.Outcomes, OutcomeChange{Origin: origin, Before: statusBefore, After: statusAfter})
+		}
+	}
+	sort.Slice(d.Outcomes, func(i, j int) bool {
+		return d.Outcomes[i].Origin < d.Outcomes[j].Origin
+	})
+	return d, nil
+}
+
+// origins returns the origins of a document. A document without an origins array has none.
+func origins(jsonData []byte) ([]string, error) {
+	var webAuthnResp counter.WebAuthnResponse
+	if err := json.Unmarshal(jsonData, &webAuthnResp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	return webAuthnResp.Origins, nil
+}
+
+// labels returns the unique labels of origins, in document order.
+func labels(origins []string) []string {
+	var found []string
+	seen := make(map[string]bool)
+	for _, origin := range origins {
+		label, ok := counter.OriginLabel(origin)
+		if ok && !seen[label] {
+			seen[label] = true
+			found = append(found, label)
+		}
+	}
+	return found
+}
+
+// difference returns the entries of a that are not in b, in the order of a.
+func difference(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+	var diff []string
+	seen := make(map[string]bool)
+	for _, s := range a {
+		if !inB[s] && !seen[s] {
+			seen[s] = true
+			diff = append(diff, s)
+		}
+	}
+	return diff
+}
+
+// Format formats a diff as a human-readable report.
+func Format(d *Diff) string {
+	if d.Empty() {
+		return "No semantic differences\n"
+	}
+
+	var sb strings.Builder
+	if len(d.OriginsAdded) > 0 || len(d.OriginsRemoved) > 0 {
+		sb.WriteString("Origins:\n")
+		for _, origin := range d.OriginsAdded {
+			sb.WriteString(fmt.Sprintf("  + %s\n", origin))
+		}
+		for _, origin := range d.OriginsRemoved {
+			sb.WriteString(fmt.Sprintf("  - %s\n", origin))
+		}
+	}
+	if len(d.LabelsAdded) > 0 || len(d.LabelsRemoved) > 0 {
+		sb.WriteString("Labels:\n")
+		for _, label := range d.LabelsAdded {
+			sb.WriteString(fmt.Sprintf("  + %s\n", label))
+		}
+		for _, label := range d.LabelsRemoved {
+			sb.WriteString(fmt.Sprintf("  - %s\n", label))
+		}
+	}
+	if len(d.Outcomes) > 0 {
+		sb.WriteString("Validation outcomes:\n")
+		for _, change := range d.Outcomes {
+			sb.WriteString(fmt.Sprintf("  %s: %s -> %s\n", change.Origin, change.Before, change.After))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,80 @@
+package docdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// TestCompare tests the semantic difference between documents.
+func TestCompare(t *testing.T) {
+	before := []byte(`{"origins": ["https://example.com", "https://example.co.uk", "https://a.com", "https://b.com", "https://c.com"]}`)
+	// Reformatted, with one origin removed and two labels added, the second over the limit
+	after := []byte(`{
+		"origins": [
+			"https://example.com",
+			"https://new.com",
+			"https://a.com",
+			"https://b.com",
+			"https://c.com",
+			"https://d.com"
+		]
+	}`)
+
+	d, err := Compare(before, after, []string{"https://example.net"})
+	if err != nil {
+		t.Fatalf("Compare returned an error: %v", err)
+	}
+	if strings.Join(d.OriginsAdded, ",") != "https://new.com,https://d.com" {
+		t.Errorf("Unexpected origins added %v", d.OriginsAdded)
+	}
+	if strings.Join(d.OriginsRemoved, ",") != "https://example.co.uk" {
+		t.Errorf("Unexpected origins removed %v", d.OriginsRemoved)
+	}
+	if strings.Join(d.LabelsAdded, ",") != "new,d" || len(d.LabelsRemoved) != 0 {
+		t.Errorf("Unexpected labels added %v and removed %v", d.LabelsAdded, d.LabelsRemoved)
+	}
+
+	expected := []OutcomeChange{
+		{"https://d.com", counter.StatusBadRelyingPartyIDNoJSONMatch, counter.StatusBadRelyingPartyIDNoJSONMatchHitLimits},
+		{"https://example.co.uk", counter.StatusSuccess, counter.StatusBadRelyingPartyIDNoJSONMatchHitLimits},
+		{"https://example.net", counter.StatusBadRelyingPartyIDNoJSONMatch, counter.StatusBadRelyingPartyIDNoJSONMatchHitLimits},
+		{"https://new.com", counter.StatusBadRelyingPartyIDNoJSONMatch, counter.StatusSuccess},
+	}
+	if len(d.Outcomes) != len(expected) {
+		t.Fatalf("Expected %d outcome changes, got:\n%s", len(expected), Format(d))
+	}
+	for i, want := range expected {
+		if d.Outcomes[i] != want {
+			t.Errorf("Outcome %d: expected %+v, got %+v", i, want, d.Outcomes[i])
+		}
+	}
+	if !d.Regressed() {
+		t.Error("Expected the change to be a regression")
+	}
+
+	// Formatting alone is not a difference
+	same, err := Compare(before, []byte(strings.ReplaceAll(string(before), " ", "\n")), nil)
+	if err != nil {
+		t.Fatalf("Compare returned an error: %v", err)
+	}
+	if !same.Empty() || Format(same) != "No semantic differences\n" {
+		t.Errorf("Expected no differences, got:\n%s", Format(same))
+	}
+
+	if _, err := Compare(before, []byte(`{`), nil); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+
+	// With a larger label limit, the sixth label is reached
+	wider, err := CompareWithMaxLabels(before, after, nil, 6)
+	if err != nil {
+		t.Fatalf("CompareWithMaxLabels returned an error: %v", err)
+	}
+	for _, change := range wider.Outcomes {
+		if change.Origin == "https://d.com" && change.After != counter.StatusSuccess {
+			t.Errorf("Expected d.com to be authorized with a limit of 6, got %+v", change)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+// Package doctor provides diagnostics for .well-known/webauthn endpoints.
+//
+// Where the counter package answers "what would a browser conclude", the doctor package
+// answers "why might the browser see something different from what I see", by probing the
+// endpoint in several ways and reporting each observation as a check.
+package doctor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/dnscheck"
+)
+
+// CheckStatus represents the outcome of a single diagnostic check.
+type CheckStatus int
+
+const (
+	// CheckPass indicates that the check found no problems.
+	CheckPass CheckStatus = iota
+	// CheckWarn indicates that the check found something that may cause problems.
+	CheckWarn
+	// CheckFail indicates that the check found a problem that will break browsers.
+	CheckFail
+)
+
+// String returns a string representation of the CheckStatus.
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckPass:
+		return "PASS"
+	case CheckWarn:
+		return "WARN"
+	case CheckFail:
+		return "FAIL"
+	default:
+		return fmt.Sprintf("UNKNOWN_CHECK_STATUS(%d)", s)
+	}
+}
+
+// Check is the result of a single diagnostic check.
+type Check struct {
+	Name    string
+	Status  CheckStatus
+	Summary string
+	Details []string
+}
+
+// Report collects the checks run against a single .well-known/webauthn URL.
+type Report struct {
+	URL    string
+	Checks []Check
+}
+
+// Failed reports whether any check in the report failed.
+func (r *Report) Failed() bool {
+	for _, check := range r.Checks {
+		if check.Status == CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot captures the parts of an HTTP response that determine what a browser would see.
+type Snapshot struct {
+	StatusCode  int
+	ContentType string
+	Vary        string
+	Allow       string
+	BodyHash    string
+	BodySize    int
+}
+
+// Equal reports whether two snapshots describe the same document.
+func (s Snapshot) Equal(other Snapshot) bool {
+	return s.StatusCode == other.StatusCode &&
+		s.ContentType == other.ContentType &&
+		s.BodyHash == other.BodyHash
+}
+
+// String returns a short human-readable description of the snapshot.
+func (s Snapshot) String() string {
+	hash := s.BodyHash
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	return fmt.Sprintf("status=%d content-type=%q body=%d bytes sha256:%s", s.StatusCode, s.ContentType, s.BodySize, hash)
+}
+
+// HeaderSet is a named set of request headers used when probing an endpoint.
+type HeaderSet struct {
+	Name    string
+	Headers map[string]string
+}
+
+// BrowserHeaders approximates the headers Chromium sends when fetching .well-known/webauthn.
+// The fetch is made without credentials, so no Cookie header is included.
+var BrowserHeaders = HeaderSet{
+	Name: "browser",
+	Headers: map[string]string{
+		"Accept":     "*/*",
+		"User-Agent": "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/128.0.0.0 Safari/537.36",
+	},
+}
+
+// NegotiationHeaderSets are the header variations compared against BrowserHeaders
+// by CheckContentNegotiation.
+var NegotiationHeaderSets = []HeaderSet{
+	{
+		Name: "accept-html",
+		Headers: map[string]string{
+			"Accept":     "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			"User-Agent": BrowserHeaders.Headers["User-Agent"],
+		},
+	},
+	{
+		Name: "accept-json",
+		Headers: map[string]string{
+			"Accept":     "application/json",
+			"User-Agent": BrowserHeaders.Headers["User-Agent"],
+		},
+	},
+	{
+		Name: "cli-user-agent",
+		Headers: map[string]string{
+			"Accept":     "*/*",
+			"User-Agent": "curl/8.5.0",
+		},
+	},
+	{
+		Name: "with-cookie",
+		Headers: map[string]string{
+			"Accept":     "*/*",
+			"User-Agent": BrowserHeaders.Headers["User-Agent"],
+			"Cookie":     "session=passkey-origin-validator-probe",
+		},
+	},
+}
+
+// NewClient returns an HTTP client suitable for probing endpoints.
+func NewClient(opts Options) *http.Client {
+	return NewNetworkClient(opts, "tcp")
+}
+
+// Fetch requests the URL with the given method and headers and captures a Snapshot of the response.
+func Fetch(client *http.Client, method, targetURL string, headers map[string]string) (Snapshot, error) {
+	req, err := http.NewRequest(method, targetURL, nil)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to fetch %s: %w", targetURL, err)
+	}
+	defer resp.Body.Close()
+
+	// Read the response body with the same size limit as the counter package
+	body, err := io.ReadAll(io.LimitReader(resp.Body, counter.MaxBodySize))
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	sum := sha256.Sum256(body)
+	return Snapshot{
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Vary:        resp.Header.Get("Vary"),
+		Allow:       resp.Header.Get("Allow"),
+		BodyHash:    hex.EncodeToString(sum[:]),
+		BodySize:    len(body),
+	}, nil
+}
+
+// negotiatedHeaders are the request headers that, when listed in Vary, mean the browser
+// and this tool may be served different documents.
+var negotiatedHeaders = []string{"Accept", "User-Agent", "Cookie"}
+
+// CheckContentNegotiation probes the URL with several header sets and flags responses
+// that vary on Accept, User-Agent or Cookie.
+func CheckContentNegotiation(client *http.Client, targetURL string) Check {
+	check := Check{Name: "content-negotiation"}
+
+	baseline, err := Fetch(client, http.MethodGet, targetURL, BrowserHeaders.Headers)
+	if err != nil {
+		check.Status = CheckFail
+		check.Summary = err.Error()
+		return check
+	}
+	check.Details = append(check.Details, fmt.Sprintf("%s: %s", BrowserHeaders.Name, baseline))
+
+	// Report any Vary header entries that reference negotiated request headers
+	var varies []string
+	for _, field := range strings.Split(baseline.Vary, ",") {
+		field = strings.TrimSpace(field)
+		if field == "*" {
+			varies = append(varies, field)
+			continue
+		}
+		for _, name := range negotiatedHeaders {
+			if strings.EqualFold(field, name) {
+				varies = append(varies, name)
+			}
+		}
+	}
+
+	// Compare each header variation against the browser-like baseline
+	var divergent []string
+	for _, set := range NegotiationHeaderSets {
+		snapshot, err := Fetch(client, http.MethodGet, targetURL, set.Headers)
+		if err != nil {
+			check.Details = append(check.Details, fmt.Sprintf("%s: %s", set.Name, err))
+			divergent = append(divergent, set.Name)
+			continue
+		}
+		check.Details = append(check.Details, fmt.Sprintf("%s: %s", set.Name, snapshot))
+		if !snapshot.Equal(baseline) {
+			divergent = append(divergent, set.Name)
+		}
+	}
+
+	switch {
+	case len(divergent) > 0:
+		check.Status = CheckWarn
+		check.Summary = fmt.Sprintf("response differs from the browser-like request for: %s; browsers and this tool may see different documents", strings.Join(divergent, ", "))
+	case len(varies) > 0:
+		check.Status = CheckWarn
+		check.Summary = fmt.Sprintf("responses were identical, but the server declares Vary: %s", strings.Join(varies, ", "))
+	default:
+		check.Status = CheckPass
+		check.Summary = "response does not vary with Accept, User-Agent or Cookie"
+	}
+
+	return check
+}
+
+// CheckMethods probes the URL with HEAD and OPTIONS and compares the results with GET.
+// Static file servers answer HEAD like GET and OPTIONS with an empty response, whereas SPA
+// catch-all routes tend to answer every method with the application's HTML page.
+func CheckMethods(client *http.Client, targetURL string) Check {
+	check := Check{Name: "methods"}
+
+	get, err := Fetch(client, http.MethodGet, targetURL, BrowserHeaders.Headers)
+	if err != nil {
+		check.Status = CheckFail
+		check.Summary = err.Error()
+		return check
+	}
+	check.Details = append(check.Details, fmt.Sprintf("GET: %s", get))
+
+	var problems []string
+
+	// HEAD should describe the same resource as GET
+	head, err := Fetch(client, http.MethodHead, targetURL, BrowserHeaders.Headers)
+	if err != nil {
+		check.Details = append(check.Details, fmt.Sprintf("HEAD: %s", err))
+		problems = append(problems, "HEAD request failed")
+	} else {
+		check.Details = append(check.Details, fmt.Sprintf("HEAD: %s", head))
+		if head.StatusCode != get.StatusCode {
+			problems = append(problems, fmt.Sprintf("HEAD returned status %d while GET returned %d", head.StatusCode, get.StatusCode))
+		}
+		if head.ContentType != get.ContentType {
+			problems = append(problems, fmt.Sprintf("HEAD returned content type %q while GET returned %q", head.ContentType, get.ContentType))
+		}
+	}
+
+	// OPTIONS should either be answered without a document or rejected
+	options, err := Fetch(client, http.MethodOptions, targetURL, BrowserHeaders.Headers)
+	if err != nil {
+		check.Details = append(check.Details, fmt.Sprintf("OPTIONS: %s", err))
+		problems = append(problems, "OPTIONS request failed")
+	} else {
+		detail := fmt.Sprintf("OPTIONS: %s", options)
+		if options.Allow != "" {
+			detail += fmt.Sprintf(" allow=%q", options.Allow)
+		}
+		check.Details = append(check.Details, detail)
+		if options.StatusCode == http.StatusOK && strings.Contains(options.ContentType, "text/html") {
+			problems = append(problems, "OPTIONS returned an HTML page")
+		}
+		if options.StatusCode == http.StatusOK && options.BodyHash == get.BodyHash && options.BodySize > 0 {
+			problems = append(problems, "OPTIONS returned the same document as GET")
+		}
+		if options.StatusCode == http.StatusNotFound && get.StatusCode == http.StatusOK {
+			problems = append(problems, "OPTIONS returned 404 for a path that GET serves")
+		}
+	}
+
+	if len(problems) > 0 {
+		check.Status = CheckWarn
+		check.Summary = strings.Join(problems, "; ") + "; the route may be served by an SPA catch-all rather than a static file"
+		return check
+	}
+
+	check.Status = CheckPass
+	check.Summary = "HEAD and OPTIONS are handled consistently with GET"
+	return check
+}
+
+// Options configures which checks Diagnose runs and how requests are made.
+type Options struct {
+	// Timeout bounds each request made by the checks.
+	Timeout time.Duration
+	// Resolver is used for all name resolution. If nil, the system resolver is used.
+	Resolver dnscheck.Resolver
+	// Hosts, if set, maps host names to addresses that are used instead of resolving them.
+	Hosts dnscheck.Hosts
+	// Transport is the transport the clients of the checks are cloned from, for its TLS,
+	// proxy and connection settings. Its DialContext is replaced, since each check dials
+	// its own way. If nil, http.DefaultTransport is used.
+	Transport *http.Transport
+	// Dialer connects the clients of the checks. If nil, a dialer with Timeout is used.
+	Dialer *net.Dialer
+	// CheckDualStack enables the dualstack check, which fetches over IPv4 and IPv6 separately.
+	CheckDualStack bool
+	// CheckAllIPs enables the replicas check, which fetches from every resolved address.
+	CheckAllIPs bool
+}
+
+// transport returns a clone of the transport the clients of the checks are built from.
+func (o Options) transport() *http.Transport {
+	if o.Transport == nil {
+		return http.DefaultTransport.(*http.Transport).Clone()
+	}
+	return o.Transport.Clone()
+}
+
+// dialer returns the dialer the clients of the checks connect with.
+func (o Options) dialer() *net.Dialer {
+	if o.Dialer == nil {
+		return &net.Dialer{Timeout: o.Timeout}
+	}
+	return o.Dialer
+}
+
+// resolver returns the configured resolver or the system default, answering for the
+// hosts in Hosts itself.
+func (o Options) resolver() dnscheck.Resolver {
+	resolver := o.Resolver
+	if resolver == nil {
+		resolver = dnscheck.DefaultResolver
+	}
+	return o.Hosts.Resolver(resolver)
+}
+
+// lookupIP returns the addresses of host in the given family ("ip", "ip4" or "ip6"),
+// from Hosts if it maps host and from the resolver otherwise.
+func (o Options) lookupIP(ctx context.Context, family, host string) ([]net.IP, error) {
+	return dnscheck.LookupIP(ctx, o.resolver(), family, host)
+}
+
+// Diagnose runs the diagnostic checks selected by opts against the .well-known/webauthn
+// endpoint for the given domain.
+func Diagnose(domain string, opts Options) (*Report, error) {
+	wellKnownURL, err := counter.WellKnownURL(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	client := NewClient(opts)
+	report := &Report{URL: wellKnownURL}
+	report.Checks = append(report.Checks, CheckContentNegotiation(client, wellKnownURL))
+	report.Checks = append(report.Checks, CheckMethods(client, wellKnownURL))
+	if opts.CheckDualStack {
+		report.Checks = append(report.Checks, CheckDualStack(opts, wellKnownURL))
+	}
+	if opts.CheckAllIPs {
+		report.Checks = append(report.Checks, CheckAllIPs(opts, wellKnownURL))
+	}
+
+	return report, nil
+}
+
+// FormatReport formats a diagnostic report into a human-readable string.
+func FormatReport(report *Report) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("URL: %s\n", report.URL))
+	for _, check := range report.Checks {
+		sb.WriteString(fmt.Sprintf("[%s] %s: %s\n", check.Status, check.Name, check.Summary))
+		for _, detail := range check.Details {
+			sb.WriteString(fmt.Sprintf("    %s\n", detail))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,230 @@
+package doctor
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCheckContentNegotiation tests the CheckContentNegotiation function.
+func TestCheckContentNegotiation(t *testing.T) {
+	t.Run("Static document", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"origins": ["https://example.com"]}`))
+		}))
+		defer server.Close()
+
+		check := CheckContentNegotiation(server.Client(), server.URL+"/.well-known/webauthn")
+		if check.Status != CheckPass {
+			t.Errorf("Expected %s, got %s: %s", CheckPass, check.Status, check.Summary)
+		}
+	})
+
+	t.Run("Response varies on Accept", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Vary", "Accept")
+			if strings.Contains(r.Header.Get("Accept"), "text/html") {
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte(`<!doctype html><html></html>`))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"origins": ["https://example.com"]}`))
+		}))
+		defer server.Close()
+
+		check := CheckContentNegotiation(server.Client(), server.URL+"/.well-known/webauthn")
+		if check.Status != CheckWarn {
+			t.Errorf("Expected %s, got %s", CheckWarn, check.Status)
+		}
+		if !strings.Contains(check.Summary, "accept-html") {
+			t.Errorf("Expected summary to mention accept-html, got %s", check.Summary)
+		}
+	})
+
+	t.Run("Vary header without divergence", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Vary", "Accept-Encoding, Cookie")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"origins": ["https://example.com"]}`))
+		}))
+		defer server.Close()
+
+		check := CheckContentNegotiation(server.Client(), server.URL+"/.well-known/webauthn")
+		if check.Status != CheckWarn {
+			t.Errorf("Expected %s, got %s", CheckWarn, check.Status)
+		}
+		if !strings.Contains(check.Summary, "Cookie") {
+			t.Errorf("Expected summary to mention Cookie, got %s", check.Summary)
+		}
+	})
+}
+
+// TestCheckMethods tests the CheckMethods function.
+func TestCheckMethods(t *testing.T) {
+	t.Run("Static file server", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"origins": ["https://example.com"]}`))
+		}))
+		defer server.Close()
+
+		check := CheckMethods(server.Client(), server.URL+"/.well-known/webauthn")
+		if check.Status != CheckPass {
+			t.Errorf("Expected %s, got %s: %s", CheckPass, check.Status, check.Summary)
+		}
+	})
+
+	t.Run("SPA catch-all", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"origins": ["https://example.com"]}`))
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<!doctype html><html></html>`))
+		}))
+		defer server.Close()
+
+		check := CheckMethods(server.Client(), server.URL+"/.well-known/webauthn")
+		if check.Status != CheckWarn {
+			t.Errorf("Expected %s, got %s", CheckWarn, check.Status)
+		}
+		if !strings.Contains(check.Summary, "SPA catch-all") {
+			t.Errorf("Expected summary to mention SPA catch-all, got %s", check.Summary)
+		}
+	})
+}
+
+// TestDivergentSnapshots tests the divergentSnapshots function.
+func TestDivergentSnapshots(t *testing.T) {
+	good := Snapshot{StatusCode: 200, ContentType: "application/json", BodyHash: "a"}
+	bad := Snapshot{StatusCode: 404, ContentType: "text/html", BodyHash: "b"}
+
+	tests := []struct {
+		name      string
+		snapshots []NamedSnapshot
+		baseline  string
+		divergent int
+	}{
+		{
+			name:      "Identical responses",
+			snapshots: []NamedSnapshot{{Name: "IPv4", Snapshot: good}, {Name: "IPv6", Snapshot: good}},
+			baseline:  "IPv4",
+			divergent: 0,
+		},
+		{
+			name:      "Different responses",
+			snapshots: []NamedSnapshot{{Name: "IPv4", Snapshot: good}, {Name: "IPv6", Snapshot: bad}},
+			baseline:  "IPv4",
+			divergent: 1,
+		},
+		{
+			name:      "First request failed",
+			snapshots: []NamedSnapshot{{Name: "IPv4", Err: errors.New("refused")}, {Name: "IPv6", Snapshot: good}},
+			baseline:  "IPv6",
+			divergent: 1,
+		},
+		{
+			name:      "All requests failed",
+			snapshots: []NamedSnapshot{{Name: "IPv4", Err: errors.New("refused")}, {Name: "IPv6", Err: errors.New("refused")}},
+			baseline:  "",
+			divergent: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseline, divergent := divergentSnapshots(tt.snapshots)
+			if baseline != tt.baseline {
+				t.Errorf("Expected baseline %q, got %q", tt.baseline, baseline)
+			}
+			if len(divergent) != tt.divergent {
+				t.Errorf("Expected %d divergent snapshots, got %v", tt.divergent, divergent)
+			}
+		})
+	}
+}
+
+// TestCheckReplicas tests the checkReplicas function using two loopback addresses on the same port.
+func TestCheckReplicas(t *testing.T) {
+	serve := func(addr, body string) *httptest.Server {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Skipf("Cannot listen on %s: %v", addr, err)
+		}
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}))
+		server.Listener = listener
+		server.Start()
+		return server
+	}
+
+	primary := serve("127.0.0.1:0", `{"origins": ["https://example.com"]}`)
+	defer primary.Close()
+	_, port, _ := net.SplitHostPort(primary.Listener.Addr().String())
+	stale := serve(net.JoinHostPort("127.0.0.2", port), `{"origins": []}`)
+	defer stale.Close()
+
+	targetURL := "http://rp.test:" + port + "/.well-known/webauthn"
+	opts := Options{Timeout: 5 * time.Second}
+
+	check := checkReplicas(opts, targetURL, []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.1")})
+	if check.Status != CheckPass {
+		t.Errorf("Expected %s for identical replicas, got %s: %s", CheckPass, check.Status, check.Summary)
+	}
+
+	check = checkReplicas(opts, targetURL, []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2")})
+	if check.Status != CheckFail {
+		t.Errorf("Expected %s for divergent replicas, got %s", CheckFail, check.Status)
+	}
+	if !strings.Contains(check.Summary, "127.0.0.2") {
+		t.Errorf("Expected summary to name the divergent replica, got %s", check.Summary)
+	}
+}
+
+// TestClientTransport tests that the clients of the checks keep the settings of the
+// configured transport, and only dial their own way.
+func TestClientTransport(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"origins": []}`))
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	base := &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS13},
+	}
+	opts := Options{Timeout: 5 * time.Second, Transport: base}
+
+	client := NewClient(opts)
+	if transport := client.Transport.(*http.Transport); transport == base || transport.TLSClientConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Expected a clone of the transport with its TLS settings, got %+v", transport.TLSClientConfig)
+	}
+	snapshot, err := Fetch(client, http.MethodGet, "http://rp.test/.well-known/webauthn", nil)
+	if err != nil {
+		t.Fatalf("Fetch returned an error: %v", err)
+	}
+	if snapshot.StatusCode != http.StatusOK || len(proxied) != 1 || proxied[0] != "http://rp.test/.well-known/webauthn" {
+		t.Errorf("Expected the request to go through the proxy, got status %d and %v", snapshot.StatusCode, proxied)
+	}
+}
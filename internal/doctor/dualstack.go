@@ -0,0 +1,135 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/dnscheck"
+)
+
+// NamedSnapshot is a Snapshot labelled with the vantage point it was fetched from.
+type NamedSnapshot struct {
+	Name     string
+	Snapshot Snapshot
+	Err      error
+}
+
+// divergentSnapshots returns the names of the snapshots that failed or differ from the first
+// successful snapshot, along with the name of that baseline snapshot.
+func divergentSnapshots(snapshots []NamedSnapshot) (string, []string) {
+	baseline := -1
+	for i, named := range snapshots {
+		if named.Err == nil {
+			baseline = i
+			break
+		}
+	}
+
+	var divergent []string
+	for i, named := range snapshots {
+		if i == baseline {
+			continue
+		}
+		if named.Err != nil || baseline == -1 || !named.Snapshot.Equal(snapshots[baseline].Snapshot) {
+			divergent = append(divergent, named.Name)
+		}
+	}
+
+	if baseline == -1 {
+		return "", divergent
+	}
+	return snapshots[baseline].Name, divergent
+}
+
+// NewNetworkClient returns an HTTP client that only dials using the given network,
+// such as "tcp4" or "tcp6".
+func NewNetworkClient(opts Options, network string) *http.Client {
+	dial := dnscheck.DialContext(opts.resolver(), opts.dialer())
+	transport := opts.transport()
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dial(ctx, network, addr)
+	}
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: transport,
+	}
+}
+
+// addressFamilies are the address families compared by CheckDualStack.
+var addressFamilies = []struct {
+	name     string
+	network  string
+	ipFamily string
+}{
+	{name: "IPv4", network: "tcp4", ipFamily: "ip4"},
+	{name: "IPv6", network: "tcp6", ipFamily: "ip6"},
+}
+
+// CheckDualStack fetches the URL over IPv4 and IPv6 separately and compares the responses.
+// Browsers pick an address family per connection, so a document that is only served
+// correctly over one family fails intermittently depending on the user's network.
+func CheckDualStack(opts Options, targetURL string) Check {
+	check := Check{Name: "dualstack"}
+
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		check.Status = CheckFail
+		check.Summary = fmt.Sprintf("invalid URL: %s", err)
+		return check
+	}
+
+	var snapshots []NamedSnapshot
+	for _, family := range addressFamilies {
+		// Skip families the host has no addresses for; browsers will not use them either
+		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+		ips, err := opts.lookupIP(ctx, family.ipFamily, parsedURL.Hostname())
+		cancel()
+		if err != nil || len(ips) == 0 {
+			check.Details = append(check.Details, fmt.Sprintf("%s: no addresses", family.name))
+			continue
+		}
+
+		client := NewNetworkClient(opts, family.network)
+		snapshot, err := Fetch(client, http.MethodGet, targetURL, BrowserHeaders.Headers)
+		if err != nil {
+			check.Details = append(check.Details, fmt.Sprintf("%s: %s", family.name, err))
+		} else {
+			check.Details = append(check.Details, fmt.Sprintf("%s: %s", family.name, snapshot))
+		}
+		snapshots = append(snapshots, NamedSnapshot{Name: family.name, Snapshot: snapshot, Err: err})
+	}
+
+	switch len(snapshots) {
+	case 0:
+		check.Status = CheckFail
+		check.Summary = "host has no IPv4 or IPv6 addresses"
+		return check
+	case 1:
+		if snapshots[0].Err != nil {
+			check.Status = CheckFail
+			check.Summary = fmt.Sprintf("host is only reachable over %s and the request failed", snapshots[0].Name)
+			return check
+		}
+		check.Status = CheckPass
+		check.Summary = fmt.Sprintf("host is only served over %s", snapshots[0].Name)
+		return check
+	}
+
+	baseline, divergent := divergentSnapshots(snapshots)
+	switch {
+	case baseline == "":
+		check.Status = CheckFail
+		check.Summary = "requests failed over both IPv4 and IPv6"
+	case len(divergent) > 0:
+		check.Status = CheckFail
+		check.Summary = fmt.Sprintf("%s serves a different response than %s; browsers will see different documents depending on the user's network", strings.Join(divergent, ", "), baseline)
+	default:
+		check.Status = CheckPass
+		check.Summary = "IPv4 and IPv6 serve identical responses"
+	}
+	return check
+}
@@ -0,0 +1,82 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NewAddressClient returns an HTTP client that connects to the given IP address for every
+// request, while keeping the URL's host name for the Host header and TLS server name.
+func NewAddressClient(opts Options, ip net.IP) *http.Client {
+	dialer := opts.dialer()
+	transport := opts.transport()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+	}
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: transport,
+	}
+}
+
+// CheckAllIPs resolves the URL's host and fetches the document from every A and AAAA address
+// separately, verifying that all backends serve identical content. Multi-CDN and multi-region
+// deployments can leave a single replica serving a stale or broken document.
+func CheckAllIPs(opts Options, targetURL string) Check {
+	check := Check{Name: "replicas"}
+
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		check.Status = CheckFail
+		check.Summary = fmt.Sprintf("invalid URL: %s", err)
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	ips, err := opts.lookupIP(ctx, "ip", parsedURL.Hostname())
+	cancel()
+	if err != nil || len(ips) == 0 {
+		check.Status = CheckFail
+		check.Summary = fmt.Sprintf("failed to resolve %s: %v", parsedURL.Hostname(), err)
+		return check
+	}
+	return checkReplicas(opts, targetURL, ips)
+}
+
+// checkReplicas fetches the URL from each of the given addresses and compares the responses.
+func checkReplicas(opts Options, targetURL string, ips []net.IP) Check {
+	check := Check{Name: "replicas"}
+
+	var snapshots []NamedSnapshot
+	for _, ip := range ips {
+		snapshot, err := Fetch(NewAddressClient(opts, ip), http.MethodGet, targetURL, BrowserHeaders.Headers)
+		if err != nil {
+			check.Details = append(check.Details, fmt.Sprintf("%s: %s", ip, err))
+		} else {
+			check.Details = append(check.Details, fmt.Sprintf("%s: %s", ip, snapshot))
+		}
+		snapshots = append(snapshots, NamedSnapshot{Name: ip.String(), Snapshot: snapshot, Err: err})
+	}
+
+	baseline, divergent := divergentSnapshots(snapshots)
+	switch {
+	case baseline == "":
+		check.Status = CheckFail
+		check.Summary = fmt.Sprintf("requests failed for all %d addresses", len(ips))
+	case len(divergent) > 0:
+		check.Status = CheckFail
+		check.Summary = fmt.Sprintf("divergent replicas: %s serve a different response than %s", strings.Join(divergent, ", "), baseline)
+	default:
+		check.Status = CheckPass
+		check.Summary = fmt.Sprintf("all %d addresses serve identical responses", len(ips))
+	}
+	return check
+}
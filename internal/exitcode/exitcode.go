@@ -0,0 +1,89 @@
+// Package exitcode decides the exit status of a run from the kinds of findings it made,
+// so that CI jobs can choose which findings block a pipeline.
+//
+// The exit codes are stable:
+//
+//	0  nothing blocking was found
+//	1  a document could not be fetched or parsed, or the run failed or was cut short
+//	2  a document has more labels than the limit
+//	3  a caller origin is not authorized, or lint, a policy or a diff found errors
+//	4  a warning, such as a document served with the wrong content type
+//
+// When a run makes findings of several kinds, it exits with the first blocking one in
+// the order 1, 3, 2, 4.
+package exitcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Exit codes of the kinds of findings.
+const (
+	OK      = 0
+	Error   = 1
+	Limit   = 2
+	Invalid = 3
+	Warning = 4
+)
+
+// Kind names of findings, as used in --fail-on.
+const (
+	KindError   = "error"
+	KindLimit   = "limit"
+	KindInvalid = "invalid"
+	KindWarn    = "warn"
+)
+
+// DefaultFailOn are the kinds of findings that block a run by default.
+var DefaultFailOn = []string{KindError, KindLimit, KindInvalid}
+
+// Findings are the kinds of findings a run made.
+type Findings struct {
+	Error   bool
+	Limit   bool
+	Invalid bool
+	Warn    bool
+}
+
+// Policy marks the kinds of findings that block a run.
+type Policy Findings
+
+// ParsePolicy returns the policy that blocks on the named kinds of findings, and on
+// warnings as well if warningsAsErrors is set.
+func ParsePolicy(failOn []string, warningsAsErrors bool) (Policy, error) {
+	p := Policy{Warn: warningsAsErrors}
+	for _, kind := range failOn {
+		switch strings.ToLower(strings.TrimSpace(kind)) {
+		case KindError:
+			p.Error = true
+		case KindLimit:
+			p.Limit = true
+		case KindInvalid:
+			p.Invalid = true
+		case KindWarn, "warning", "warnings":
+			p.Warn = true
+		case "none", "":
+		default:
+			return p, fmt.Errorf("unknown finding kind %q: expected %s, %s, %s, %s or none", kind, KindError, KindLimit, KindInvalid, KindWarn)
+		}
+	}
+	return p, nil
+}
+
+// Code returns the exit code of a run that made found: the code of its most severe
+// blocking finding, or OK if none of them block.
+func (p Policy) Code(found Findings) int {
+	switch {
+	case p.Error && found.Error:
+		return Error
+	case p.Invalid && found.Invalid:
+		return Invalid
+	case p.Limit && found.Limit:
+		return Limit
+	case p.Warn && found.Warn:
+		return Warning
+	default:
+		return OK
+	}
+}
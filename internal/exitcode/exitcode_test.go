@@ -0,0 +1,39 @@
+package exitcode
+
+import "testing"
+
+// TestPolicy tests choosing exit codes from findings.
+func TestPolicy(t *testing.T) {
+	all := Findings{Error: true, Limit: true, Invalid: true, Warn: true}
+	tests := []struct {
+		name             string
+		failOn           []string
+		warningsAsErrors bool
+		found            Findings
+		code             int
+	}{
+		{"Default error first", DefaultFailOn, false, all, Error},
+		{"Default invalid before limit", DefaultFailOn, false, Findings{Limit: true, Invalid: true}, Invalid},
+		{"Default ignores warnings", DefaultFailOn, false, Findings{Warn: true}, OK},
+		{"Warnings as errors", DefaultFailOn, true, Findings{Warn: true}, Warning},
+		{"Fail on warn", []string{"warn"}, false, all, Warning},
+		{"Limit only", []string{"limit"}, false, Findings{Error: true, Limit: true}, Limit},
+		{"None", []string{"none"}, false, all, OK},
+		{"Nothing found", DefaultFailOn, true, Findings{}, OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePolicy(tt.failOn, tt.warningsAsErrors)
+			if err != nil {
+				t.Fatalf("ParsePolicy returned an error: %v", err)
+			}
+			if code := p.Code(tt.found); code != tt.code {
+				t.Errorf("Expected exit code %d, got %d", tt.code, code)
+			}
+		})
+	}
+
+	if _, err := ParsePolicy([]string{"lint"}, false); err == nil {
+		t.Error("Expected an error for an unknown kind")
+	}
+}
@@ -0,0 +1,242 @@
+// Package fixpr proposes the fixes of lint --fix as pull requests against the repository
+// that owns a .well-known/webauthn source file.
+//
+// The fix is committed on a new branch in a temporary worktree, so that the checkout it
+// is run from is left untouched, and the branch is pushed and proposed with the findings
+// it fixes as the description. Branches are named after the fixed content, so that
+// proposing the same fix twice is refused instead of opening a duplicate pull request.
+package fixpr
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/generate"
+	"github.com/developmeh/passkey-origin-validator/internal/issues"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+)
+
+// BranchPrefix is the prefix of the branches fixes are committed on.
+const BranchPrefix = "passkey-origin-validator/fix-"
+
+// Host opens pull requests. *issues.GitHub implements it.
+type Host interface {
+	// OpenPullRequest opens a pull request and returns its URL.
+	OpenPullRequest(ctx context.Context, pr issues.PullRequest) (string, error)
+}
+
+// Options configures a proposal.
+type Options struct {
+	// Repo is the path of the local Git repository.
+	Repo string
+	// Path is the path of the .well-known/webauthn source file, relative to Repo.
+	Path string
+	// Remote is the Git remote the branch is pushed to. If empty, "origin" is used.
+	Remote string
+	// Base is the branch the pull request targets. If empty, the current branch is used.
+	Base string
+	// Branch is the branch the fix is committed on. If empty, a branch named after the
+	// fixed content is used.
+	Branch string
+	// CallerOrigins are origins the document must authorize; findings about them are
+	// listed as left to fix by hand.
+	CallerOrigins []string
+	// MaxLabels is the number of unique labels counted before origins are ignored. If
+	// zero, counter.MaxLabels is used.
+	MaxLabels int
+}
+
+// Result is the outcome of a proposal.
+type Result struct {
+	// Changed is false when the file is already in canonical form, in which case no
+	// branch or pull request is made.
+	Changed bool
+	Branch  string
+	Base    string
+	// URL is the URL of the pull request.
+	URL string
+	// Fixed are the findings the fix resolves, and Remaining those left to fix by hand.
+	Fixed     []lint.Finding
+	Remaining []lint.Finding
+}
+
+// Propose fixes the source file of opts in canonical form, commits the fix on a new
+// branch, pushes it and opens a pull request for it with host.
+func Propose(ctx context.Context, host Host, opts Options) (*Result, error) {
+	if opts.Remote == "" {
+		opts.Remote = "origin"
+	}
+	path := filepath.ToSlash(filepath.Clean(opts.Path))
+	if filepath.IsAbs(opts.Path) || path == "." || strings.HasPrefix(path, "../") {
+		return nil, fmt.Errorf("invalid path %q: expected a file inside the repository", opts.Path)
+	}
+
+	document, err := os.ReadFile(filepath.Join(opts.Repo, path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source file: %w", err)
+	}
+	fixed, sorted, err := generate.FixWithMaxLabels(document, opts.MaxLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Base: opts.Base, Branch: opts.Branch}
+	lintOpts := lint.Options{CallerOrigins: opts.CallerOrigins, Source: path, MaxLabels: opts.MaxLabels}
+	result.Fixed, result.Remaining = partition(lint.Check(document, lintOpts), lint.Check(fixed.JSON, lintOpts))
+	if bytes.Equal(document, fixed.JSON) {
+		return result, nil
+	}
+	result.Changed = true
+
+	if result.Base == "" {
+		if result.Base, err = git(ctx, opts.Repo, "rev-parse", "--abbrev-ref", "HEAD"); err != nil {
+			return nil, err
+		}
+		if result.Base == "HEAD" {
+			return nil, errors.New("the repository has no current branch; set the base branch")
+		}
+	}
+	if result.Branch == "" {
+		sum := sha256.Sum256(fixed.JSON)
+		result.Branch = BranchPrefix + hex.EncodeToString(sum[:4])
+	}
+	if _, err := git(ctx, opts.Repo, "rev-parse", "--verify", "--quiet", "refs/heads/"+result.Branch); err == nil {
+		return nil, fmt.Errorf("branch %s already exists; this fix was probably proposed before", result.Branch)
+	}
+
+	// Commit in a temporary worktree, so that the current checkout is left alone
+	worktree, err := os.MkdirTemp("", "passkey-origin-validator-fix-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(worktree)
+	if _, err := git(ctx, opts.Repo, "worktree", "add", "-b", result.Branch, worktree, result.Base); err != nil {
+		return nil, err
+	}
+	defer git(context.Background(), opts.Repo, "worktree", "remove", "--force", worktree)
+
+	target := filepath.Join(worktree, path)
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find source file on %s: %w", result.Base, err)
+	}
+	if err := os.WriteFile(target, fixed.JSON, info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("failed to write fixed document: %w", err)
+	}
+	title := fmt.Sprintf("Fix %s with passkey-origin-validator lint --fix", path)
+	for _, args := range [][]string{
+		{"add", "--", path},
+		{"commit", "-m", title},
+		{"push", opts.Remote, result.Branch},
+	} {
+		if _, err := git(ctx, worktree, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	result.URL, err = host.OpenPullRequest(ctx, issues.PullRequest{
+		Title: title,
+		Body:  description(path, fixed, sorted, result),
+		Head:  result.Branch,
+		Base:  result.Base,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pull request: %w", err)
+	}
+	return result, nil
+}
+
+// partition splits the findings of a document into those its fix resolves and those that
+// remain, which are the findings of the fixed document.
+func partition(before, after []lint.Finding) (fixed, remaining []lint.Finding) {
+	left := make(map[string]bool, len(after))
+	for _, finding := range after {
+		left[finding.Fingerprint] = true
+	}
+	for _, finding := range before {
+		if !left[finding.Fingerprint] {
+			fixed = append(fixed, finding)
+		}
+	}
+	return fixed, after
+}
+
+// description returns the body of the pull request for the fix of the file at path.
+func description(path string, fixed *generate.Result, sorted bool, result *Result) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("This pull request rewrites `%s` in canonical form, as `passkey-origin-validator lint --fix` does.\n", path))
+
+	sb.WriteString("\n### Changes\n\n")
+	for _, rewrite := range fixed.Rewritten {
+		sb.WriteString(fmt.Sprintf("- Normalized `%s` to `%s`\n", rewrite.From, rewrite.To))
+	}
+	for _, duplicate := range fixed.Duplicates {
+		sb.WriteString(fmt.Sprintf("- Removed duplicate `%s`\n", duplicate))
+	}
+	if sorted {
+		sb.WriteString("- Sorted the origins\n")
+	} else {
+		sb.WriteString("- Kept the order of the origins, since they exceed the label limit and their order decides which are ignored\n")
+	}
+
+	if len(result.Fixed) > 0 {
+		sb.WriteString("\n### Findings fixed\n\n```\n")
+		sb.WriteString(lint.FormatFindings(result.Fixed))
+		sb.WriteString("```\n")
+	}
+	if len(result.Remaining) > 0 {
+		sb.WriteString("\n### Findings left to fix by hand\n\n```\n")
+		sb.WriteString(lint.FormatFindings(result.Remaining))
+		sb.WriteString("```\n")
+	}
+	return sb.String()
+}
+
+// git runs git with args in dir and returns its trimmed output.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s failed: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// RemoteRepo returns the GitHub repository, as "owner/repo", that a Git remote of repo
+// points to, for remotes such as https://github.com/owner/repo.git or
+// git@github.com:owner/repo.git.
+func RemoteRepo(ctx context.Context, repo, remote string) (string, error) {
+	if remote == "" {
+		remote = "origin"
+	}
+	remoteURL, err := git(ctx, repo, "remote", "get-url", remote)
+	if err != nil {
+		return "", err
+	}
+
+	path := ""
+	if u, err := url.Parse(remoteURL); err == nil && u.Host != "" {
+		path = u.Path
+	} else if _, p, ok := strings.Cut(remoteURL, ":"); ok && strings.Contains(remoteURL, "@") {
+		path = p
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if owner, name, ok := strings.Cut(path, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("cannot tell the GitHub repository of remote %s (%s); set it explicitly", remote, remoteURL)
+	}
+	return path, nil
+}
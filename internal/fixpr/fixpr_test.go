@@ -0,0 +1,175 @@
+package fixpr
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/issues"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+)
+
+// fakeHost records the pull requests opened with it.
+type fakeHost struct {
+	opened []issues.PullRequest
+}
+
+func (f *fakeHost) OpenPullRequest(ctx context.Context, pr issues.PullRequest) (string, error) {
+	f.opened = append(f.opened, pr)
+	return "https://github.com/owner/repo/pull/1", nil
+}
+
+// newRepo returns a repository on branch main, with a bare repository as its origin
+// remote, in which path holds document.
+func newRepo(t *testing.T, path, document string) (repo, remote string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(key, "Test")
+	}
+	for _, key := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(key, "test@example.com")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	ctx := context.Background()
+	remote = t.TempDir()
+	repo = t.TempDir()
+	run := func(dir string, args ...string) {
+		t.Helper()
+		if _, err := git(ctx, dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run(remote, "init", "--bare", "--initial-branch=main")
+	run(repo, "init", "--initial-branch=main")
+	run(repo, "remote", "add", "origin", remote)
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(repo, path)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, path), []byte(document), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(repo, "add", ".")
+	run(repo, "commit", "-m", "Add document")
+	return repo, remote
+}
+
+// TestPropose tests committing, pushing and proposing a fix.
+func TestPropose(t *testing.T) {
+	const path = "public/.well-known/webauthn"
+	document := `{"origins": ["https://b.com", "https://A.com/login", "https://b.com", "http://c.com"]}`
+	repo, remote := newRepo(t, path, document)
+	ctx := context.Background()
+
+	host := &fakeHost{}
+	result, err := Propose(ctx, host, Options{Repo: repo, Path: path})
+	if err != nil {
+		t.Fatalf("Propose returned an error: %v", err)
+	}
+	if !result.Changed || result.Base != "main" || !strings.HasPrefix(result.Branch, BranchPrefix) || result.URL == "" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	// The fix resolves the path and the duplicate, but not the scheme
+	var fixed, remaining []string
+	for _, finding := range result.Fixed {
+		fixed = append(fixed, finding.Rule)
+	}
+	for _, finding := range result.Remaining {
+		remaining = append(remaining, finding.Rule)
+	}
+	if strings.Join(fixed, ",") != "origin-path,duplicate-origin" || strings.Join(remaining, ",") != lint.RuleInsecureScheme {
+		t.Errorf("Expected fixed origin-path,duplicate-origin and remaining insecure-scheme, got %v and %v", fixed, remaining)
+	}
+
+	// The pull request proposes the pushed branch
+	if len(host.opened) != 1 {
+		t.Fatalf("Expected one pull request, got %d", len(host.opened))
+	}
+	pr := host.opened[0]
+	if pr.Head != result.Branch || pr.Base != "main" {
+		t.Errorf("Expected %s into main, got %s into %s", result.Branch, pr.Head, pr.Base)
+	}
+	for _, want := range []string{"Normalized `https://A.com/login` to `https://a.com`", "Removed duplicate `https://b.com`", "### Findings fixed", "### Findings left to fix by hand", "insecure-scheme"} {
+		if !strings.Contains(pr.Body, want) {
+			t.Errorf("Expected the description to contain %q, got:\n%s", want, pr.Body)
+		}
+	}
+	pushed, err := git(ctx, remote, "show", result.Branch+":"+path)
+	if err != nil {
+		t.Fatalf("Expected the branch to be pushed: %v", err)
+	}
+	if !strings.Contains(pushed, `"https://a.com"`) || strings.Contains(pushed, "login") {
+		t.Errorf("Expected the fixed document on the branch, got %s", pushed)
+	}
+
+	// The checkout is left as it was
+	if current, _ := git(ctx, repo, "rev-parse", "--abbrev-ref", "HEAD"); current != "main" {
+		t.Errorf("Expected the checkout to stay on main, got %s", current)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo, path)); string(data) != document {
+		t.Errorf("Expected the checkout's file to be unchanged, got %s", data)
+	}
+
+	// The same fix is not proposed twice
+	if _, err := Propose(ctx, host, Options{Repo: repo, Path: path}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected an error for a fix that was already proposed, got %v", err)
+	}
+}
+
+// TestProposeUnchanged tests that a file in canonical form is not proposed.
+func TestProposeUnchanged(t *testing.T) {
+	const path = "webauthn.json"
+	repo, _ := newRepo(t, path, "{\n  \"origins\": [\n    \"https://a.com\",\n    \"https://b.com\"\n  ]\n}\n")
+
+	host := &fakeHost{}
+	result, err := Propose(context.Background(), host, Options{Repo: repo, Path: path})
+	if err != nil {
+		t.Fatalf("Propose returned an error: %v", err)
+	}
+	if result.Changed || len(host.opened) != 0 {
+		t.Errorf("Expected nothing to be proposed, got %+v and %v", result, host.opened)
+	}
+
+	if _, err := Propose(context.Background(), host, Options{Repo: repo, Path: "../webauthn.json"}); err == nil {
+		t.Error("Expected an error for a path outside the repository")
+	}
+}
+
+// TestRemoteRepo tests finding the GitHub repository of a remote.
+func TestRemoteRepo(t *testing.T) {
+	repo, _ := newRepo(t, "webauthn.json", `{"origins": []}`)
+	ctx := context.Background()
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://github.com/owner/repo.git", "owner/repo"},
+		{"https://github.com/owner/repo", "owner/repo"},
+		{"git@github.com:owner/repo.git", "owner/repo"},
+		{"ssh://git@github.com/owner/repo.git", "owner/repo"},
+		{"/srv/git/repo.git", ""},
+	}
+	for _, tt := range tests {
+		if _, err := git(ctx, repo, "remote", "set-url", "origin", tt.url); err != nil {
+			t.Fatal(err)
+		}
+		got, err := RemoteRepo(ctx, repo, "")
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("RemoteRepo(%s) = %q, want an error", tt.url, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("RemoteRepo(%s) = %q, %v; want %s", tt.url, got, err, tt.expected)
+		}
+	}
+}
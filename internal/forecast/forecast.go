@@ -0,0 +1,154 @@
+// Package forecast projects when a .well-known/webauthn document will reach the label
+// limit, or grow past the response size browsers read, from assumptions about how fast
+// its origins grow, so that capacity planning happens before browsers start ignoring
+// origins.
+//
+// Growth is declared in the forecast section of the configuration file:
+//
+//	forecast:
+//	  origins_per_quarter: 4
+//	  new_labels_per_quarter: 0.5
+//	  horizon_quarters: 8
+package forecast
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// DefaultHorizon is the number of quarters projected when none is configured.
+const DefaultHorizon = 8
+
+// entrySize is the assumed size in bytes of an origin added to a document that has none
+// to estimate it from, such as "https://login.example.com" and its separator.
+const entrySize = 30
+
+// Growth is how fast the origins of documents are expected to grow.
+type Growth struct {
+	// OriginsPerQuarter is the number of origins added to a document each quarter.
+	OriginsPerQuarter float64 `mapstructure:"origins_per_quarter"`
+	// NewLabelsPerQuarter is the number of those origins that bring a label the
+	// document does not use yet, such as a new country domain.
+	NewLabelsPerQuarter float64 `mapstructure:"new_labels_per_quarter"`
+	// Horizon is the number of quarters projected. If zero, DefaultHorizon is used.
+	Horizon int `mapstructure:"horizon_quarters"`
+}
+
+// Enabled reports whether any growth is configured.
+func (g Growth) Enabled() bool {
+	return g.OriginsPerQuarter > 0 || g.NewLabelsPerQuarter > 0
+}
+
+// Validate checks that the growth is consistent.
+func (g Growth) Validate() error {
+	if g.OriginsPerQuarter < 0 || g.NewLabelsPerQuarter < 0 || g.Horizon < 0 {
+		return fmt.Errorf("invalid forecast: growth and horizon cannot be negative")
+	}
+	if g.NewLabelsPerQuarter > g.OriginsPerQuarter {
+		return fmt.Errorf("invalid forecast: %g new labels per quarter need at least as many new origins, not %g",
+			g.NewLabelsPerQuarter, g.OriginsPerQuarter)
+	}
+	return nil
+}
+
+// horizon returns the number of quarters projected.
+func (g Growth) horizon() int {
+	if g.Horizon == 0 {
+		return DefaultHorizon
+	}
+	return g.Horizon
+}
+
+// String describes the growth, such as "4 origins and 0.5 new labels per quarter".
+func (g Growth) String() string {
+	return fmt.Sprintf("%g origins and %g new labels per quarter", g.OriginsPerQuarter, g.NewLabelsPerQuarter)
+}
+
+// Never is the number of quarters of a limit that is not reached within the horizon.
+const Never = -1
+
+// Projection is when a document reaches each limit, in quarters from now: 0 if it
+// already has, or Never if it does not within the horizon.
+type Projection struct {
+	// LabelLimit is when the document uses every label, so that browsers ignore the
+	// origins of any further site.
+	LabelLimit int
+	// SizeLimit is when the document grows past counter.MaxBodySize, so that browsers
+	// cannot read it.
+	SizeLimit int
+}
+
+// Reached reports whether the document reaches a limit within the horizon.
+func (p Projection) Reached() bool {
+	return p.LabelLimit != Never || p.SizeLimit != Never
+}
+
+// Project projects when a document with origins and labels distinct labels reaches the
+// label limit maxLabels (counter.MaxLabels if zero) and the size limit. Its size is
+// estimated from its origins, as it would be written by the fix command.
+func Project(origins []string, labels, maxLabels int, growth Growth) Projection {
+	if maxLabels == 0 {
+		maxLabels = counter.MaxLabels
+	}
+	projection := Projection{LabelLimit: Never, SizeLimit: Never}
+
+	switch {
+	case labels >= maxLabels:
+		projection.LabelLimit = 0
+	case growth.NewLabelsPerQuarter > 0:
+		projection.LabelLimit = int(math.Ceil(float64(maxLabels-labels) / growth.NewLabelsPerQuarter))
+	}
+
+	size, perOrigin := estimateSize(origins)
+	switch {
+	case size > counter.MaxBodySize:
+		projection.SizeLimit = 0
+	case growth.OriginsPerQuarter > 0:
+		projection.SizeLimit = int(math.Floor(float64(counter.MaxBodySize-size)/(growth.OriginsPerQuarter*perOrigin))) + 1
+	}
+
+	if projection.LabelLimit > growth.horizon() {
+		projection.LabelLimit = Never
+	}
+	if projection.SizeLimit > growth.horizon() {
+		projection.SizeLimit = Never
+	}
+	return projection
+}
+
+// estimateSize returns the size in bytes of a document of origins and the average size
+// of one of its entries.
+func estimateSize(origins []string) (int, float64) {
+	if origins == nil {
+		origins = []string{}
+	}
+	data, _ := json.MarshalIndent(map[string][]string{"origins": origins}, "", "  ")
+	if len(origins) == 0 {
+		return len(data), entrySize
+	}
+	empty, _ := json.MarshalIndent(map[string][]string{"origins": {}}, "", "  ")
+	return len(data), float64(len(data)-len(empty)) / float64(len(origins))
+}
+
+// Quarter returns the calendar quarter that is quarters after the one of t, such as
+// "2027 Q1".
+func Quarter(t time.Time, quarters int) string {
+	index := t.Year()*4 + (int(t.Month())-1)/3 + quarters
+	return fmt.Sprintf("%d Q%d", index/4, index%4+1)
+}
+
+// When describes when a limit is reached, such as "now" or "2027 Q1 (in 2 quarters)".
+func When(t time.Time, quarters int) string {
+	switch quarters {
+	case 0:
+		return "now"
+	case 1:
+		return fmt.Sprintf("%s (in 1 quarter)", Quarter(t, quarters))
+	default:
+		return fmt.Sprintf("%s (in %d quarters)", Quarter(t, quarters), quarters)
+	}
+}
@@ -0,0 +1,75 @@
+package forecast
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestProject tests projecting when documents reach each limit.
+func TestProject(t *testing.T) {
+	origins := []string{"https://a.com", "https://b.com", "https://c.com"}
+	tests := []struct {
+		name      string
+		origins   []string
+		labels    int
+		maxLabels int
+		growth    Growth
+		expected  Projection
+	}{
+		{"No growth", origins, 3, 0, Growth{}, Projection{LabelLimit: Never, SizeLimit: Never}},
+		{"New labels", origins, 3, 0, Growth{OriginsPerQuarter: 2, NewLabelsPerQuarter: 1}, Projection{LabelLimit: 2, SizeLimit: Never}},
+		{"Fractional new labels", origins, 3, 0, Growth{OriginsPerQuarter: 2, NewLabelsPerQuarter: 0.5}, Projection{LabelLimit: 4, SizeLimit: Never}},
+		{"Beyond the horizon", origins, 3, 0, Growth{OriginsPerQuarter: 1, NewLabelsPerQuarter: 0.2}, Projection{LabelLimit: Never, SizeLimit: Never}},
+		{"Longer horizon", origins, 3, 0, Growth{OriginsPerQuarter: 1, NewLabelsPerQuarter: 0.2, Horizon: 12}, Projection{LabelLimit: 10, SizeLimit: Never}},
+		{"At the limit", origins, 3, 3, Growth{}, Projection{LabelLimit: 0, SizeLimit: Never}},
+		{"Size limit", origins, 3, 0, Growth{OriginsPerQuarter: 5000}, Projection{LabelLimit: Never, SizeLimit: 3}},
+		{"Size limit of an empty document", nil, 0, 0, Growth{OriginsPerQuarter: 5000}, Projection{LabelLimit: Never, SizeLimit: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if projection := Project(tt.origins, tt.labels, tt.maxLabels, tt.growth); projection != tt.expected {
+				t.Errorf("Project() = %+v, want %+v", projection, tt.expected)
+			}
+		})
+	}
+
+	// A document already past the size limit has reached it
+	large := make([]string, 10000)
+	for i := range large {
+		large[i] = fmt.Sprintf("https://login%d.example.com", i)
+	}
+	if projection := Project(large, 1, 0, Growth{}); projection.SizeLimit != 0 || !projection.Reached() {
+		t.Errorf("Expected a document past the size limit to have reached it, got %+v", projection)
+	}
+}
+
+// TestGrowth tests validating and describing growth.
+func TestGrowth(t *testing.T) {
+	if (Growth{}).Enabled() {
+		t.Error("Expected no growth to be disabled")
+	}
+	if err := (Growth{OriginsPerQuarter: 1, NewLabelsPerQuarter: 2}).Validate(); err == nil {
+		t.Error("Expected an error for more new labels than new origins")
+	}
+	if err := (Growth{OriginsPerQuarter: -1}).Validate(); err == nil {
+		t.Error("Expected an error for negative growth")
+	}
+	if got := (Growth{OriginsPerQuarter: 4, NewLabelsPerQuarter: 0.5}).String(); got != "4 origins and 0.5 new labels per quarter" {
+		t.Errorf("Unexpected description %q", got)
+	}
+}
+
+// TestWhen tests naming the quarter a limit is reached in.
+func TestWhen(t *testing.T) {
+	start := time.Date(2026, time.November, 15, 0, 0, 0, 0, time.UTC)
+	var got []string
+	for _, quarters := range []int{0, 1, 2, 5} {
+		got = append(got, When(start, quarters))
+	}
+	expected := "now, 2027 Q1 (in 1 quarter), 2027 Q2 (in 2 quarters), 2028 Q1 (in 5 quarters)"
+	if strings.Join(got, ", ") != expected {
+		t.Errorf("Expected %s, got %s", expected, strings.Join(got, ", "))
+	}
+}
@@ -0,0 +1,68 @@
+package generate
+
+import (
+	"fmt"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// Budget tracks the labels used by a list of origins as it grows, for building a list
+// one origin at a time.
+type Budget struct {
+	labels    []string
+	seen      map[string]bool
+	maxLabels int
+}
+
+// NewBudget returns a Budget with the labels of origins already used.
+func NewBudget(origins []string) *Budget {
+	return NewBudgetWithMaxLabels(origins, counter.MaxLabels)
+}
+
+// NewBudgetWithMaxLabels is like NewBudget but allows maxLabels labels instead of
+// MaxLabels. A maxLabels that is not positive means MaxLabels.
+func NewBudgetWithMaxLabels(origins []string, maxLabels int) *Budget {
+	if maxLabels <= 0 {
+		maxLabels = counter.MaxLabels
+	}
+	b := &Budget{seen: make(map[string]bool), maxLabels: maxLabels}
+	for _, origin := range origins {
+		b.Add(origin)
+	}
+	return b
+}
+
+// Check returns the label that origin counts towards and whether adding it would use a
+// label beyond the limit, which browsers ignore. ok is false when origin has no label.
+func (b *Budget) Check(origin string) (label string, overLimit bool, ok bool) {
+	label, ok = counter.OriginLabel(origin)
+	if !ok {
+		return "", false, false
+	}
+	return label, !b.seen[label] && len(b.labels) >= b.maxLabels, true
+}
+
+// Add records origin's label as used.
+func (b *Budget) Add(origin string) {
+	label, ok := counter.OriginLabel(origin)
+	if !ok || b.seen[label] {
+		return
+	}
+	b.seen[label] = true
+	b.labels = append(b.labels, label)
+}
+
+// Labels returns the labels used, in the order they were added.
+func (b *Budget) Labels() []string {
+	return append([]string(nil), b.labels...)
+}
+
+// MaxLabels returns the number of labels the budget allows.
+func (b *Budget) MaxLabels() int {
+	return b.maxLabels
+}
+
+// String returns the budget as "n of m labels used".
+func (b *Budget) String() string {
+	return fmt.Sprintf("%d of %d labels used", len(b.labels), b.maxLabels)
+}
@@ -0,0 +1,68 @@
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// Fix rewrites a document in canonical form: every origin is normalized, duplicates are
+// dropped, and the origins are sorted, so that the file is stable to commit and review.
+// Members other than origins are kept. The origins are only sorted when they are within
+// MaxLabels, since the order of a longer list decides which origins browsers ignore;
+// sorted reports whether they were.
+func Fix(jsonData []byte) (result *Result, sorted bool, err error) {
+	return FixWithMaxLabels(jsonData, counter.MaxLabels)
+}
+
+// FixWithMaxLabels is like Fix but only sorts origins within maxLabels labels instead of
+// MaxLabels. A maxLabels that is not positive means MaxLabels.
+func FixWithMaxLabels(jsonData []byte, maxLabels int) (result *Result, sorted bool, err error) {
+	if maxLabels <= 0 {
+		maxLabels = counter.MaxLabels
+	}
+	// Keep every member, not just origins
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(jsonData, &members); err != nil {
+		return nil, false, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	var origins []string
+	raw, ok := members["origins"]
+	if !ok {
+		return nil, false, errors.New("failed to parse JSON: missing origins array")
+	}
+	if err := json.Unmarshal(raw, &origins); err != nil {
+		return nil, false, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	result, err = Generate(origins)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(GroupByLabel(result.Origins)) <= maxLabels {
+		sort.Strings(result.Origins)
+		sorted = true
+	}
+
+	// Write the members back with the fixed origins; map keys are sorted when encoded
+	fixed, err := json.Marshal(result.Origins)
+	if err != nil {
+		return nil, false, err
+	}
+	members["origins"] = fixed
+	body, err := json.Marshal(members)
+	if err != nil {
+		return nil, false, err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		return nil, false, err
+	}
+	indented.WriteByte('\n')
+	result.JSON = indented.Bytes()
+	return result, sorted, nil
+}
@@ -0,0 +1,85 @@
+// Package generate builds canonical .well-known/webauthn documents from lists of origins.
+package generate
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// Normalize returns the serialized form of an origin, as a browser would compare it:
+// a lowercase scheme and host without a default port, path, query or fragment. An
+// origin given without a scheme is assumed to be https. An Android app origin is returned
+// as is, once its hash is checked.
+func Normalize(origin string) (string, error) {
+	origin = strings.TrimSpace(origin)
+	if counter.IsAndroidOrigin(origin) {
+		return counter.SerializeOrigin(origin)
+	}
+	if !strings.Contains(origin, "://") {
+		origin = "https://" + origin
+	}
+
+	return counter.SerializeOrigin(origin)
+}
+
+// Rewrite is an input origin and the normalized form it was replaced with.
+type Rewrite struct {
+	From string
+	To   string
+}
+
+// Result is a generated document together with how the input was changed.
+type Result struct {
+	// JSON is the canonical document.
+	JSON []byte
+	// Origins are the normalized, deduplicated origins, in input order.
+	Origins []string
+	// Rewritten lists the input origins whose normalized form differs, in input order.
+	Rewritten []Rewrite
+	// Duplicates are the input origins dropped because an equal origin came earlier.
+	Duplicates []string
+	// Invalid are the input origins that could not be normalized. They are kept as
+	// given, so that the document's checks report them.
+	Invalid []string
+}
+
+// Generate normalizes and deduplicates origins, keeping the first occurrence of each so
+// that the order, which decides which origins fall within the label limit, is preserved.
+// The document is indented with two spaces and ends with a newline.
+func Generate(origins []string) (*Result, error) {
+	result := &Result{
+		Origins: []string{},
+	}
+	seen := make(map[string]bool)
+
+	for _, origin := range origins {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+
+		normalized, err := Normalize(origin)
+		if err != nil {
+			result.Invalid = append(result.Invalid, origin)
+			normalized = origin
+		} else if normalized != origin {
+			result.Rewritten = append(result.Rewritten, Rewrite{From: origin, To: normalized})
+		}
+
+		if seen[normalized] {
+			result.Duplicates = append(result.Duplicates, origin)
+			continue
+		}
+		seen[normalized] = true
+		result.Origins = append(result.Origins, normalized)
+	}
+
+	body, err := json.MarshalIndent(counter.WebAuthnResponse{Origins: result.Origins}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	result.JSON = append(body, '\n')
+	return result, nil
+}
@@ -0,0 +1,260 @@
+package generate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestNormalize tests the serialized form of origins.
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://example.com", "https://example.com"},
+		{"  HTTPS://Example.COM/  ", "https://example.com"},
+		{"example.com", "https://example.com"},
+		{"https://example.com:443/login?next=/", "https://example.com"},
+		{"https://example.com:8443", "https://example.com:8443"},
+		{"http://example.com:80", "http://example.com"},
+		{"https://[::1]:443", "https://[::1]"},
+		{"https://[::1]:8443", "https://[::1]:8443"},
+		{" android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI ", "android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Normalize(tt.input)
+			if err != nil {
+				t.Fatalf("Normalize returned an error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err := Normalize("https://"); err == nil {
+		t.Error("Expected an error for an origin without a host")
+	}
+}
+
+// TestGenerate tests deduplication and the canonical document.
+func TestGenerate(t *testing.T) {
+	result, err := Generate([]string{
+		"https://example.com",
+		"",
+		"https://Example.com/",
+		"example.co.uk",
+		"https://example.com",
+		"https://",
+	})
+	if err != nil {
+		t.Fatalf("Generate returned an error: %v", err)
+	}
+
+	expected := "{\n  \"origins\": [\n    \"https://example.com\",\n    \"https://example.co.uk\",\n    \"https://\"\n  ]\n}\n"
+	if string(result.JSON) != expected {
+		t.Errorf("Expected document:\n%s\ngot:\n%s", expected, result.JSON)
+	}
+	if strings.Join(result.Duplicates, ",") != "https://Example.com/,https://example.com" {
+		t.Errorf("Unexpected duplicates %v", result.Duplicates)
+	}
+	if len(result.Rewritten) != 2 || result.Rewritten[1].From != "example.co.uk" {
+		t.Errorf("Unexpected rewrites %v", result.Rewritten)
+	}
+	if len(result.Invalid) != 1 || result.Invalid[0] != "https://" {
+		t.Errorf("Unexpected invalid origins %v", result.Invalid)
+	}
+
+	// An empty list still produces a valid document
+	empty, err := Generate(nil)
+	if err != nil {
+		t.Fatalf("Generate returned an error: %v", err)
+	}
+	if string(empty.JSON) != "{\n  \"origins\": []\n}\n" {
+		t.Errorf("Unexpected empty document %q", empty.JSON)
+	}
+}
+
+// TestSuggest tests the suggestions for lists over the label limit.
+func TestSuggest(t *testing.T) {
+	within := []string{"https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com"}
+	if suggestions := Suggest(within); suggestions != nil {
+		t.Errorf("Expected no suggestions within the limit, got %v", suggestions)
+	}
+
+	over := []string{
+		"https://a.com", "https://www.a.com",
+		"https://b.com", "https://b.co.uk",
+		"https://c.com", "https://d.com",
+		"https://e.com", "https://f.com",
+		"https://g.com",
+	}
+	groups := GroupByLabel(over)
+	if len(groups) != 7 || groups[4].Position != 5 || groups[5].Position != 6 {
+		t.Fatalf("Unexpected groups %+v", groups)
+	}
+	if len(groups[0].Origins) != 2 {
+		t.Errorf("Expected a.com and www.a.com to share a label, got %v", groups[0].Origins)
+	}
+	if len(groups[1].Origins) != 2 {
+		t.Errorf("Expected b.com and b.co.uk to share a label, got %v", groups[1].Origins)
+	}
+
+	suggestions := Suggest(over)
+	if len(suggestions) != 2 {
+		t.Fatalf("Expected 2 suggestions, got %v", suggestions)
+	}
+	if !strings.HasPrefix(suggestions[0], "removing https://g.com, https://f.com would") {
+		t.Errorf("Expected the last single-origin labels to be removed, got %q", suggestions[0])
+	}
+	if !strings.Contains(suggestions[1], "browsers ignore https://f.com, https://g.com") {
+		t.Errorf("Expected the ignored origins, got %q", suggestions[1])
+	}
+
+	if suggestions := SuggestWithMaxLabels(over, 7); suggestions != nil {
+		t.Errorf("Expected no suggestions within a limit of 7, got %v", suggestions)
+	}
+	suggestions = SuggestWithMaxLabels(over, 6)
+	if len(suggestions) != 2 || !strings.HasSuffix(suggestions[0], "within the limit of 6 labels") ||
+		!strings.Contains(suggestions[1], "browsers ignore https://g.com;") {
+		t.Errorf("Expected suggestions for a limit of 6, got %v", suggestions)
+	}
+}
+
+// TestBudget tests the running label budget.
+func TestBudget(t *testing.T) {
+	budget := NewBudget([]string{"https://a.com", "https://b.com", "https://c.com", "https://d.com"})
+	if _, over, _ := budget.Check("https://e.com"); over {
+		t.Error("Expected the fifth label to be within the limit")
+	}
+	budget.Add("https://e.com")
+	if budget.String() != "5 of 5 labels used" || budget.MaxLabels() != 5 {
+		t.Errorf("Unexpected budget %q", budget.String())
+	}
+
+	if label, over, ok := budget.Check("https://f.com"); !ok || !over || label != "f" {
+		t.Errorf("Expected f to be over the limit, got %q, %v, %v", label, over, ok)
+	}
+	if _, over, _ := budget.Check("https://a.com:8443"); over {
+		t.Error("Expected an existing label to be within the limit")
+	}
+	if _, _, ok := budget.Check("localhost"); ok {
+		t.Error("Expected an origin without a label to be reported")
+	}
+}
+
+// TestFix tests rewriting documents in canonical form.
+func TestFix(t *testing.T) {
+	t.Run("Within the limit", func(t *testing.T) {
+		doc := `{"origins":["https://Example.co.uk:443/login","https://example.com","https://example.com/"],"comment":"kept"}`
+		result, sorted, err := Fix([]byte(doc))
+		if err != nil {
+			t.Fatalf("Fix returned an error: %v", err)
+		}
+		expected := "{\n  \"comment\": \"kept\",\n  \"origins\": [\n    \"https://example.co.uk\",\n    \"https://example.com\"\n  ]\n}\n"
+		if !sorted || string(result.JSON) != expected {
+			t.Errorf("Expected sorted document:\n%s\ngot (sorted=%v):\n%s", expected, sorted, result.JSON)
+		}
+		if len(result.Duplicates) != 1 || len(result.Rewritten) != 2 {
+			t.Errorf("Unexpected changes: duplicates %v, rewrites %v", result.Duplicates, result.Rewritten)
+		}
+
+		// Fixing a fixed document changes nothing
+		again, _, err := Fix(result.JSON)
+		if err != nil || string(again.JSON) != string(result.JSON) {
+			t.Errorf("Expected Fix to be idempotent, got:\n%s", again.JSON)
+		}
+	})
+
+	t.Run("Over the limit keeps the order", func(t *testing.T) {
+		doc := `{"origins":["https://f.com","https://e.com","https://d.com","https://c.com","https://b.com","https://a.com"]}`
+		result, sorted, err := Fix([]byte(doc))
+		if err != nil {
+			t.Fatalf("Fix returned an error: %v", err)
+		}
+		if sorted || result.Origins[0] != "https://f.com" {
+			t.Errorf("Expected the order to be kept, got %v", result.Origins)
+		}
+
+		// Within a larger limit, the origins are sorted
+		result, sorted, err = FixWithMaxLabels([]byte(doc), 6)
+		if err != nil {
+			t.Fatalf("FixWithMaxLabels returned an error: %v", err)
+		}
+		if !sorted || result.Origins[0] != "https://a.com" {
+			t.Errorf("Expected the origins to be sorted within a limit of 6, got %v", result.Origins)
+		}
+	})
+
+	for _, doc := range []string{`{"origins": [`, `{"other": []}`, `{"origins": "x"}`} {
+		if _, _, err := Fix([]byte(doc)); err == nil {
+			t.Errorf("Fix(%s) succeeded, want an error", doc)
+		}
+	}
+}
+
+// TestOptimize tests bringing a list within the label budget while keeping required
+// callers matched.
+func TestOptimize(t *testing.T) {
+	origins := []string{
+		"https://a.com", "https://b.com", "https://c.com", "https://d.com",
+		"https://e.com", "https://f.com", "https://g.com", "https://b.co.uk",
+	}
+
+	t.Run("Drops optional labels", func(t *testing.T) {
+		opt, err := Optimize(origins, []string{"https://g.com", "https://b.com"}, "", 0)
+		if err != nil {
+			t.Fatalf("Optimize returned an error: %v", err)
+		}
+		expected := []string{"https://b.com", "https://g.com", "https://b.co.uk", "https://a.com", "https://c.com", "https://d.com"}
+		if !reflect.DeepEqual(opt.Origins, expected) {
+			t.Errorf("Expected origins %v, got %v", expected, opt.Origins)
+		}
+		if opt.LabelsBefore != 7 || len(opt.Labels) != 5 || !opt.Reordered || len(opt.Unmatched) != 0 {
+			t.Errorf("Unexpected optimization %+v", opt)
+		}
+		var dropped []string
+		for _, change := range opt.Changes {
+			if change.Kind == ChangeDrop {
+				dropped = append(dropped, change.Origin)
+			}
+		}
+		if !reflect.DeepEqual(dropped, []string{"https://e.com", "https://f.com"}) {
+			t.Errorf("Expected e.com and f.com to be dropped, got %v", dropped)
+		}
+	})
+
+	t.Run("Adds and consolidates", func(t *testing.T) {
+		required := []string{"https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://h.com", "https://login.example.com"}
+		opt, err := Optimize(origins, required, "example.com", 0)
+		if err != nil {
+			t.Fatalf("Optimize returned an error: %v", err)
+		}
+		kinds := make(map[string]Change)
+		for _, change := range opt.Changes {
+			kinds[change.Origin] = change
+		}
+		if kinds["https://login.example.com"].Kind != ChangeRPID {
+			t.Errorf("Expected login.example.com to use the RP ID, got %+v", kinds["https://login.example.com"])
+		}
+		if change := kinds["https://h.com"]; change.Kind != ChangeConsolidate || change.Suggestion != "https://h.example.com" {
+			t.Errorf("Expected h.com to be consolidated under the RP ID, got %+v", change)
+		}
+		if !reflect.DeepEqual(opt.Unmatched, []string{"https://h.com"}) {
+			t.Errorf("Expected h.com to stay unmatched, got %v", opt.Unmatched)
+		}
+	})
+
+	t.Run("Within the limit", func(t *testing.T) {
+		opt, err := Optimize([]string{"https://a.com", "https://b.com"}, []string{"https://c.com"}, "", 0)
+		if err != nil {
+			t.Fatalf("Optimize returned an error: %v", err)
+		}
+		if !reflect.DeepEqual(opt.Origins, []string{"https://c.com", "https://a.com", "https://b.com"}) || opt.Reordered {
+			t.Errorf("Unexpected optimization %+v", opt)
+		}
+	})
+}
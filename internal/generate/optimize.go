@@ -0,0 +1,277 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/rpid"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Kinds of change an optimization makes to a list of origins.
+const (
+	// ChangeKeep keeps a listed origin.
+	ChangeKeep = "keep"
+	// ChangeDrop removes a listed origin.
+	ChangeDrop = "drop"
+	// ChangeAdd adds a required caller origin that is not listed.
+	ChangeAdd = "add"
+	// ChangeConsolidate is a required caller origin that cannot fit in the label budget
+	// as it is served, and should move to a host that needs no label of its own.
+	ChangeConsolidate = "consolidate"
+	// ChangeRPID is a required caller origin that needs no entry, since it can use the RP
+	// ID on its own.
+	ChangeRPID = "rp-id"
+)
+
+// Change is what an optimization does with one origin.
+type Change struct {
+	Kind   string `json:"kind"`
+	Origin string `json:"origin"`
+	// Label is the label the origin counts towards, if it has one.
+	Label    string `json:"label,omitempty"`
+	Required bool   `json:"required,omitempty"`
+	Reason   string `json:"reason"`
+	// Suggestion is a host the origin could be served from instead, for a consolidation.
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// Optimization is a list of origins brought within the label budget, keeping the required
+// caller origins matched.
+type Optimization struct {
+	// LabelsBefore is the number of unique labels of the original list.
+	LabelsBefore int `json:"labels_before"`
+	MaxLabels    int `json:"max_labels"`
+	// Origins is the optimized list: the origins of the required callers' labels first,
+	// then the others that fit.
+	Origins []string `json:"origins"`
+	// Labels are the labels of the optimized list, in the order browsers count them.
+	Labels []string `json:"labels"`
+	// Changes are the changes to every listed origin, in list order, followed by those to
+	// the required callers that are not listed.
+	Changes []Change `json:"changes"`
+	// Reordered reports whether the kept origins are in another order than they were listed.
+	Reordered bool `json:"reordered"`
+	// Unmatched are the required callers the optimized list does not authorize.
+	Unmatched []string `json:"unmatched"`
+	// JSON is the optimized document.
+	JSON []byte `json:"-"`
+}
+
+// Optimize brings origins within maxLabels unique labels (MaxLabels if zero) while keeping
+// every required caller origin authorized, and reports how. A required caller that can
+// use rpID on its own needs no entry. The others are kept, or added if not listed, and
+// their labels placed first, so that later additions cannot push them out of the budget.
+// When they need more labels than the budget, the labels of the most callers are kept
+// and the remaining callers are to be consolidated onto a host that needs no label of
+// its own. The labels left are filled with the other listed origins, in list order, and
+// the origins that do not fit are dropped, as are origins without a label, which
+// browsers ignore anyway. Origins that share a kept label are always kept.
+func Optimize(origins, required []string, rpID string, maxLabels int) (*Optimization, error) {
+	if maxLabels <= 0 {
+		maxLabels = counter.MaxLabels
+	}
+	listed, err := Generate(origins)
+	if err != nil {
+		return nil, err
+	}
+	opt := &Optimization{
+		LabelsBefore: len(GroupByLabel(listed.Origins)),
+		MaxLabels:    maxLabels,
+		Origins:      []string{},
+		Labels:       []string{},
+		Unmatched:    []string{},
+	}
+
+	// Find the required callers that need an entry, and the labels they need
+	isListed := make(map[string]bool)
+	for _, origin := range listed.Origins {
+		isListed[origin] = true
+	}
+	isRequired := make(map[string]bool)
+	viaRPID := make(map[string]bool)
+	var needed []string
+	var extra []Change
+	for _, caller := range required {
+		normalized, err := Normalize(caller)
+		if err != nil {
+			return nil, fmt.Errorf("invalid caller origin %q: %w", caller, err)
+		}
+		if isRequired[normalized] || viaRPID[normalized] {
+			continue
+		}
+		if rpID != "" && rpid.Check(normalized, rpID).Status == rpid.Valid {
+			viaRPID[normalized] = true
+			if !isListed[normalized] {
+				extra = append(extra, Change{Kind: ChangeRPID, Origin: normalized, Required: true,
+					Reason: fmt.Sprintf("can use RP ID %s on its own, so it needs no entry", rpID)})
+			}
+			continue
+		}
+		if _, ok := counter.OriginLabel(normalized); !ok && !counter.IsAndroidOrigin(normalized) {
+			return nil, fmt.Errorf("caller origin %s has no registrable domain, so no document can authorize it", normalized)
+		}
+		isRequired[normalized] = true
+		needed = append(needed, normalized)
+	}
+
+	// Rank the needed labels by their number of callers, then by where they are listed
+	position := make(map[string]int)
+	for _, group := range GroupByLabel(append(append([]string(nil), listed.Origins...), needed...)) {
+		position[group.Label] = group.Position
+	}
+	callers := make(map[string]int)
+	var labels []string
+	for _, caller := range needed {
+		if label, ok := counter.OriginLabel(caller); ok {
+			if callers[label] == 0 {
+				labels = append(labels, label)
+			}
+			callers[label]++
+		}
+	}
+	sort.SliceStable(labels, func(i, j int) bool {
+		if callers[labels[i]] != callers[labels[j]] {
+			return callers[labels[i]] > callers[labels[j]]
+		}
+		return position[labels[i]] < position[labels[j]]
+	})
+	kept := make(map[string]bool)
+	for i, label := range labels {
+		if i < maxLabels {
+			kept[label] = true
+		}
+	}
+
+	// Keep the origins of the kept labels first, in list order, then add the callers
+	// that are not listed
+	changes := make(map[string]Change)
+	var first, rest []string
+	for _, origin := range listed.Origins {
+		label, ok := counter.OriginLabel(origin)
+		switch {
+		case counter.IsAndroidOrigin(origin):
+			first = append(first, origin)
+			changes[origin] = Change{Kind: ChangeKeep, Origin: origin, Required: isRequired[origin],
+				Reason: "Android app origins use no label"}
+		case !ok:
+			changes[origin] = Change{Kind: ChangeDrop, Origin: origin,
+				Reason: "has no registrable domain, so browsers ignore it"}
+		case kept[label]:
+			first = append(first, origin)
+			reason := fmt.Sprintf("required caller, label %q", label)
+			if !isRequired[origin] {
+				reason = fmt.Sprintf("shares label %q with a required caller", label)
+			}
+			changes[origin] = Change{Kind: ChangeKeep, Origin: origin, Label: label, Required: isRequired[origin], Reason: reason}
+		default:
+			rest = append(rest, origin)
+		}
+	}
+	for _, caller := range needed {
+		label, _ := counter.OriginLabel(caller)
+		if isListed[caller] || (label != "" && !kept[label]) {
+			continue
+		}
+		first = append(first, caller)
+		extra = append(extra, Change{Kind: ChangeAdd, Origin: caller, Label: label, Required: true,
+			Reason: "required caller that is not listed"})
+	}
+	budget := NewBudgetWithMaxLabels(first, maxLabels)
+
+	// Fill the labels left with the other origins, in list order
+	for _, origin := range rest {
+		label, overLimit, _ := budget.Check(origin)
+		switch {
+		case isRequired[origin]:
+			changes[origin] = consolidate(origin, label, rpID, first, fmt.Sprintf(
+				"required caller, but label %q does not fit in the budget after the labels of more callers", label))
+		case overLimit:
+			changes[origin] = Change{Kind: ChangeDrop, Origin: origin, Label: label,
+				Reason: fmt.Sprintf("%s, and label %q does not fit in the budget of %d labels", optional(origin, viaRPID, rpID), label, maxLabels)}
+		default:
+			budget.Add(origin)
+			opt.Origins = append(opt.Origins, origin)
+			changes[origin] = Change{Kind: ChangeKeep, Origin: origin, Label: label,
+				Reason: fmt.Sprintf("%s, but label %q fits in the budget", optional(origin, viaRPID, rpID), label)}
+		}
+	}
+	for _, caller := range needed {
+		if label, _ := counter.OriginLabel(caller); !isListed[caller] && label != "" && !kept[label] {
+			extra = append(extra, consolidate(caller, label, rpID, first, fmt.Sprintf(
+				"required caller that is not listed, and label %q does not fit in the budget after the labels of more callers", label)))
+		}
+	}
+	opt.Origins = append(first, opt.Origins...)
+	opt.Labels = budget.Labels()
+
+	for _, origin := range listed.Origins {
+		opt.Changes = append(opt.Changes, changes[origin])
+	}
+	opt.Changes = append(opt.Changes, extra...)
+
+	// The kept origins were reordered if they are not in the order they were listed
+	var keptListed []string
+	for _, origin := range opt.Origins {
+		if isListed[origin] {
+			keptListed = append(keptListed, origin)
+		}
+	}
+	i := 0
+	for _, origin := range listed.Origins {
+		if i < len(keptListed) && keptListed[i] == origin {
+			i++
+		}
+	}
+	opt.Reordered = i < len(keptListed)
+
+	body, err := json.MarshalIndent(counter.WebAuthnResponse{Origins: opt.Origins}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	opt.JSON = append(body, '\n')
+	compiled, err := counter.CompileWithMaxLabels(opt.JSON, maxLabels)
+	if err != nil {
+		return nil, err
+	}
+	for _, caller := range needed {
+		if compiled.Validate(caller) != counter.StatusSuccess {
+			opt.Unmatched = append(opt.Unmatched, caller)
+		}
+	}
+	return opt, nil
+}
+
+// optional explains why a listed origin is not required.
+func optional(origin string, viaRPID map[string]bool, rpID string) string {
+	if viaRPID[origin] {
+		return fmt.Sprintf("required caller that can use RP ID %s on its own", rpID)
+	}
+	return "not required"
+}
+
+// consolidate returns the change that moves a required caller whose label does not fit
+// onto a host that needs no label of its own: a subdomain of the RP ID, which needs no
+// entry at all, or else of the registrable domain of a kept origin, which shares its label.
+func consolidate(origin, label, rpID string, kept []string, reason string) Change {
+	change := Change{Kind: ChangeConsolidate, Origin: origin, Label: label, Required: true, Reason: reason}
+	host := rpID
+	if host == "" {
+		for _, k := range kept {
+			if u, err := url.Parse(k); err == nil && u.Scheme == "https" {
+				if domain, err := publicsuffix.EffectiveTLDPlusOne(u.Hostname()); err == nil {
+					host = domain
+					break
+				}
+			}
+		}
+	}
+	if host != "" {
+		change.Suggestion = "https://" + strings.ToLower(label) + "." + host
+	}
+	return change
+}
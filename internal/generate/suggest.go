@@ -0,0 +1,89 @@
+package generate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// LabelGroup is a label and the origins that count towards it.
+type LabelGroup struct {
+	Label string
+	// Origins are the origins with this label, in input order.
+	Origins []string
+	// Position is the order in which browsers see the label, starting at 1.
+	Position int
+}
+
+// GroupByLabel groups origins by label, in the order browsers see the labels. Origins
+// without a label are left out.
+func GroupByLabel(origins []string) []LabelGroup {
+	var groups []LabelGroup
+	index := make(map[string]int)
+	for _, origin := range origins {
+		label, ok := counter.OriginLabel(origin)
+		if !ok {
+			continue
+		}
+		i, seen := index[label]
+		if !seen {
+			i = len(groups)
+			index[label] = i
+			groups = append(groups, LabelGroup{Label: label, Position: i + 1})
+		}
+		groups[i].Origins = append(groups[i].Origins, origin)
+	}
+	return groups
+}
+
+// Suggest returns suggestions for bringing origins within MaxLabels: the labels whose
+// removal drops the fewest origins, and the origins browsers ignore as ordered. It
+// returns nil when the origins are within the limit.
+func Suggest(origins []string) []string {
+	return SuggestWithMaxLabels(origins, counter.MaxLabels)
+}
+
+// SuggestWithMaxLabels is like Suggest but brings origins within maxLabels labels instead
+// of MaxLabels. A maxLabels that is not positive means MaxLabels.
+func SuggestWithMaxLabels(origins []string, maxLabels int) []string {
+	if maxLabels <= 0 {
+		maxLabels = counter.MaxLabels
+	}
+	groups := GroupByLabel(origins)
+	excess := len(groups) - maxLabels
+	if excess <= 0 {
+		return nil
+	}
+
+	var suggestions []string
+
+	// Removing the labels with the fewest origins drops the fewest origins; among equals,
+	// prefer the labels browsers already ignore
+	candidates := append([]LabelGroup(nil), groups...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if len(candidates[i].Origins) != len(candidates[j].Origins) {
+			return len(candidates[i].Origins) < len(candidates[j].Origins)
+		}
+		return candidates[i].Position > candidates[j].Position
+	})
+	var removals []string
+	for _, group := range candidates[:excess] {
+		removals = append(removals, group.Origins...)
+	}
+	suggestions = append(suggestions, fmt.Sprintf(
+		"removing %s would bring the list within the limit of %d labels", strings.Join(removals, ", "), maxLabels))
+
+	// Browsers keep the first labels they see, so order decides what is ignored
+	var ignored []string
+	for _, group := range groups {
+		if group.Position > maxLabels {
+			ignored = append(ignored, group.Origins...)
+		}
+	}
+	suggestions = append(suggestions, fmt.Sprintf(
+		"as ordered, browsers ignore %s; move the origins that matter most to the top of the list", strings.Join(ignored, ", ")))
+
+	return suggestions
+}
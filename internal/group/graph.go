@@ -0,0 +1,141 @@
+package group
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// Colors of the members and edges of a graph, by how much of the label budget the RP ID's
+// document consumes.
+const (
+	// colorRoom is used while the document has more than one label to spare.
+	colorRoom = "#2e7d32"
+	// colorLastLabel is used when the document has one label left.
+	colorLastLabel = "#ef6c00"
+	// colorFull is used when the document has no label left.
+	colorFull = "#c62828"
+	// colorNone is used for an unreadable document and for edges that use no label.
+	colorNone = "#9e9e9e"
+)
+
+// edge is a relationship that authorizes its origin, from the RP ID to the member.
+type edge struct {
+	from, to int
+	label    string
+	suffix   bool
+	color    string
+}
+
+// budgetColor returns the color of an RP ID by how much of the label budget its document
+// consumes.
+func (r *Report) budgetColor(rpID string) string {
+	labels, ok := r.Labels[rpID]
+	switch {
+	case !ok:
+		return colorNone
+	case len(labels) >= r.MaxLabels:
+		return colorFull
+	case len(labels) == r.MaxLabels-1:
+		return colorLastLabel
+	default:
+		return colorRoom
+	}
+}
+
+// nodeLabel returns the text of a member in a graph: its domain, and the labels its
+// document consumes.
+func (r *Report) nodeLabel(domain, newline string) string {
+	labels, ok := r.Labels[domain]
+	if !ok {
+		return domain + newline + "unreadable"
+	}
+	return fmt.Sprintf("%s%s%d/%d labels", domain, newline, len(labels), r.MaxLabels)
+}
+
+// edges returns the relationships that authorize their origin, in the order of the
+// relationships. A listed origin's edge is labeled with the label it counts towards and
+// colored as its RP ID; one that can use the RP ID as a suffix consumes no label.
+func (r *Report) edges() []edge {
+	index := make(map[string]int, len(r.Domains))
+	for i, domain := range r.Domains {
+		index[domain] = i
+	}
+	var edges []edge
+	for _, rel := range r.Relationships {
+		if !rel.Authorized() {
+			continue
+		}
+		e := edge{from: index[rel.RPID], to: index[strings.TrimPrefix(rel.Origin, "https://")]}
+		if rel.Status == StatusRPIDSuffix {
+			e.label, e.suffix, e.color = "RP ID suffix", true, colorNone
+		} else {
+			e.label, _ = counter.OriginLabel(rel.Origin)
+			e.color = r.budgetColor(rel.RPID)
+		}
+		edges = append(edges, e)
+	}
+	return edges
+}
+
+// DOT returns the authorization graph as a Graphviz digraph, with an edge from each RP ID
+// to every member whose origin can use it. Members are colored by how much of the label
+// budget their document consumes, and the canonical RP ID is drawn with a double border.
+func (r *Report) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph passkeys {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box, style=\"rounded,filled\", fontcolor=white];\n")
+	for _, domain := range r.Domains {
+		fmt.Fprintf(&sb, "  %s [label=%s, fillcolor=\"%s\"", dotQuote(domain), dotQuote(r.nodeLabel(domain, "\n")), r.budgetColor(domain))
+		if domain == r.Canonical {
+			sb.WriteString(", peripheries=2")
+		}
+		sb.WriteString("];\n")
+	}
+	for _, e := range r.edges() {
+		fmt.Fprintf(&sb, "  %s -> %s [label=%s, color=\"%s\"", dotQuote(r.Domains[e.from]), dotQuote(r.Domains[e.to]), dotQuote(e.label), e.color)
+		if e.suffix {
+			sb.WriteString(", style=dashed")
+		}
+		sb.WriteString("];\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// dotQuote returns s as a DOT string, in which a newline starts a new line of a label.
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+// Mermaid returns the authorization graph as a Mermaid flowchart, drawn as DOT draws it.
+// Members are named n0, n1 and so on in the order of the domains.
+func (r *Report) Mermaid() string {
+	var sb strings.Builder
+	sb.WriteString("graph LR\n")
+	for i, domain := range r.Domains {
+		fmt.Fprintf(&sb, "  n%d[\"%s\"]\n", i, r.nodeLabel(domain, "<br/>"))
+	}
+	edges := r.edges()
+	for _, e := range edges {
+		arrow := "-->"
+		if e.suffix {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&sb, "  n%d %s|%s| n%d\n", e.from, arrow, e.label, e.to)
+	}
+	for i, domain := range r.Domains {
+		fmt.Fprintf(&sb, "  style n%d fill:%s,color:#fff", i, r.budgetColor(domain))
+		if domain == r.Canonical {
+			sb.WriteString(",stroke:#000,stroke-width:3px")
+		}
+		sb.WriteString("\n")
+	}
+	for i, e := range edges {
+		fmt.Fprintf(&sb, "  linkStyle %d stroke:%s\n", i, e.color)
+	}
+	return sb.String()
+}
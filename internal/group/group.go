@@ -0,0 +1,218 @@
+// Package group checks that a group of domains meant to share passkeys authorize each
+// other as intended.
+//
+// A domain's origin can use the passkeys of another domain's RP ID when the RP ID is a
+// registrable domain suffix of its host, or when the RP ID's .well-known/webauthn
+// document lists it. A group usually shares passkeys in one of two ways: every member
+// uses a canonical RP ID, whose document lists all of them, or every member's document
+// lists all the others, so that each can use any member's RP ID. The group package
+// builds the authorization graph of the members' documents and reports the
+// relationships that are missing, or that only go one way.
+package group
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+	"github.com/developmeh/passkey-origin-validator/internal/rpid"
+)
+
+// Relationship statuses, which tell whether the origin of one member can use the RP ID of
+// another.
+const (
+	// StatusListed means the RP ID's document authorizes the origin.
+	StatusListed = "listed"
+	// StatusRPIDSuffix means the origin can use the RP ID without its document, since the
+	// RP ID is a registrable domain suffix of its host.
+	StatusRPIDSuffix = "rp-id-suffix"
+	// StatusNotAuthorized means the RP ID's document does not authorize the origin.
+	StatusNotAuthorized = "not-authorized"
+	// StatusUnreadable means the RP ID's document could not be fetched or parsed.
+	StatusUnreadable = "unreadable"
+)
+
+// Document is the .well-known/webauthn document of a member of the group.
+type Document struct {
+	// Domain is the member, as a host name.
+	Domain string
+	// URL is where the document was fetched from.
+	URL string
+	// JSON is the document, or nil if it could not be read.
+	JSON []byte
+	// Error is why the document could not be read, if it could not.
+	Error string
+}
+
+// Options configures a check.
+type Options struct {
+	// Canonical is the RP ID every member is meant to use. If empty, every member is
+	// meant to be able to use the RP ID of every other.
+	Canonical string
+	// MaxLabels is the number of unique labels counted before origins are ignored. If
+	// zero, counter.MaxLabels is used.
+	MaxLabels int
+}
+
+// Relationship is whether the origin of one member can use the RP ID of another.
+type Relationship struct {
+	RPID   string `json:"rp_id"`
+	Origin string `json:"origin"`
+	Status string `json:"status"`
+	// Detail is the validation status of an origin that is not authorized, or why the
+	// RP ID's document could not be read.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Authorized reports whether the origin can use the RP ID.
+func (r Relationship) Authorized() bool {
+	return r.Status == StatusListed || r.Status == StatusRPIDSuffix
+}
+
+// Report is the authorization graph of a group and its problems.
+type Report struct {
+	Canonical string   `json:"canonical,omitempty"`
+	Domains   []string `json:"domains"`
+	// Relationships are those of every ordered pair of members, by RP ID and then origin,
+	// in the order of the domains.
+	Relationships []Relationship `json:"relationships"`
+	// Labels are the labels the document of each readable member counts towards
+	// MaxLabels, by domain.
+	Labels    map[string][]string `json:"labels"`
+	MaxLabels int                 `json:"max_labels"`
+	Findings  []lint.Finding      `json:"findings"`
+}
+
+// Relationship returns the relationship of the origin of member to the RP ID of rpID.
+func (r *Report) Relationship(rpID, member string) (Relationship, bool) {
+	origin := "https://" + member
+	for _, rel := range r.Relationships {
+		if rel.RPID == rpID && rel.Origin == origin {
+			return rel, true
+		}
+	}
+	return Relationship{}, false
+}
+
+// Check builds the authorization graph of docs, one for each member of the group in
+// order, and reports the relationships the group is meant to have but does not. With a
+// canonical RP ID, a member whose origin cannot use it is reported as
+// lint.RuleGroupMissing. Otherwise, every pair of members is meant to authorize each
+// other: a pair where neither does is reported as lint.RuleGroupMissing for both, and a
+// pair where only one does as lint.RuleGroupOneWay.
+func Check(docs []Document, opts Options) *Report {
+	report := &Report{
+		Canonical:     opts.Canonical,
+		Domains:       []string{},
+		Relationships: []Relationship{},
+		Labels:        make(map[string][]string),
+		MaxLabels:     opts.MaxLabels,
+		Findings:      []lint.Finding{},
+	}
+	if report.MaxLabels <= 0 {
+		report.MaxLabels = counter.MaxLabels
+	}
+	byDomain := make(map[string]Document)
+	for _, doc := range docs {
+		report.Domains = append(report.Domains, doc.Domain)
+		byDomain[doc.Domain] = doc
+		if compiled, err := counter.CompileWithMaxLabels(doc.JSON, report.MaxLabels); doc.JSON != nil && err == nil {
+			report.Labels[doc.Domain] = compiled.Labels()
+		}
+	}
+	for _, rpID := range report.Domains {
+		for _, member := range report.Domains {
+			if member != rpID {
+				report.Relationships = append(report.Relationships, relate(byDomain[rpID], member, opts.MaxLabels))
+			}
+		}
+	}
+
+	if opts.Canonical != "" {
+		canonical := byDomain[opts.Canonical]
+		for _, member := range report.Domains {
+			if member == opts.Canonical {
+				continue
+			}
+			rel, _ := report.Relationship(opts.Canonical, member)
+			if rel.Authorized() {
+				continue
+			}
+			message := fmt.Sprintf("%s cannot use canonical RP ID %s: %s", rel.Origin, opts.Canonical, describe(rel))
+			// A member that lists the canonical RP instead has the relationship backwards
+			if back, ok := report.Relationship(member, opts.Canonical); ok && back.Status == StatusListed {
+				message += fmt.Sprintf("; %s lists https://%s instead, which only lets the canonical RP use %s's RP ID", member, opts.Canonical, member)
+			}
+			report.add(lint.RuleGroupMissing, lint.SeverityError, canonical, rel, message)
+		}
+		return report
+	}
+
+	for i, a := range report.Domains {
+		for _, b := range report.Domains[i+1:] {
+			ab, _ := report.Relationship(a, b)
+			ba, _ := report.Relationship(b, a)
+			switch {
+			case ab.Authorized() && ba.Authorized():
+			case ab.Authorized():
+				report.add(lint.RuleGroupOneWay, lint.SeverityWarning, byDomain[b], ba,
+					fmt.Sprintf("%s can use RP ID %s, but %s cannot use RP ID %s: %s", ab.Origin, a, ba.Origin, b, describe(ba)))
+			case ba.Authorized():
+				report.add(lint.RuleGroupOneWay, lint.SeverityWarning, byDomain[a], ab,
+					fmt.Sprintf("%s can use RP ID %s, but %s cannot use RP ID %s: %s", ba.Origin, b, ab.Origin, a, describe(ab)))
+			default:
+				report.add(lint.RuleGroupMissing, lint.SeverityError, byDomain[a], ab,
+					fmt.Sprintf("%s cannot use RP ID %s: %s", ab.Origin, a, describe(ab)))
+				report.add(lint.RuleGroupMissing, lint.SeverityError, byDomain[b], ba,
+					fmt.Sprintf("%s cannot use RP ID %s: %s", ba.Origin, b, describe(ba)))
+			}
+		}
+	}
+	return report
+}
+
+// relate returns the relationship of the origin of member to the RP ID of doc.
+func relate(doc Document, member string, maxLabels int) Relationship {
+	rel := Relationship{RPID: doc.Domain, Origin: "https://" + member}
+	switch {
+	case rpid.Check(rel.Origin, doc.Domain).Status == rpid.Valid:
+		rel.Status = StatusRPIDSuffix
+	case doc.JSON == nil:
+		rel.Status, rel.Detail = StatusUnreadable, doc.Error
+	default:
+		status := counter.ValidateWellKnownJSONWithMaxLabels(rel.Origin, doc.JSON, maxLabels)
+		if status == counter.StatusSuccess {
+			rel.Status = StatusListed
+		} else {
+			rel.Status, rel.Detail = StatusNotAuthorized, status.String()
+		}
+	}
+	return rel
+}
+
+// describe explains why a relationship does not authorize its origin.
+func describe(rel Relationship) string {
+	if rel.Status == StatusUnreadable {
+		return fmt.Sprintf("its document could not be read (%s)", rel.Detail)
+	}
+	return fmt.Sprintf("its document does not authorize the origin (%s)", rel.Detail)
+}
+
+// add reports a finding about the relationship of an origin to the RP ID of doc, which
+// adding the origin to doc fixes.
+func (r *Report) add(rule string, severity lint.Severity, doc Document, rel Relationship, message string) {
+	source := doc.URL
+	if source == "" {
+		source = "https://" + strings.ToLower(doc.Domain) + "/.well-known/webauthn"
+	}
+	r.Findings = append(r.Findings, lint.Finding{
+		Rule:        rule,
+		Severity:    severity,
+		Index:       -1,
+		Origin:      rel.Origin,
+		Message:     message,
+		Fingerprint: lint.Fingerprint(rule, source, rel.Origin),
+		Remediation: &lint.Remediation{Action: lint.ActionAddOrigin, Target: source, Value: rel.Origin},
+	})
+}
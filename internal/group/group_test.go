@@ -0,0 +1,189 @@
+package group
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+)
+
+// doc returns the document of domain listing origins.
+func doc(domain string, origins ...string) Document {
+	quoted := make([]string, len(origins))
+	for i, origin := range origins {
+		quoted[i] = `"` + origin + `"`
+	}
+	return Document{
+		Domain: domain,
+		URL:    "https://" + domain + "/.well-known/webauthn",
+		JSON:   []byte(`{"origins": [` + strings.Join(quoted, ", ") + `]}`),
+	}
+}
+
+// rules returns the rule and origin of each finding.
+func rules(findings []lint.Finding) []string {
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.Rule+" "+f.Origin)
+	}
+	return got
+}
+
+// TestCheckCanonical tests a group whose members are all meant to use one RP ID.
+func TestCheckCanonical(t *testing.T) {
+	tests := []struct {
+		name string
+		docs []Document
+		want []string
+	}{
+		{
+			"all listed",
+			[]Document{
+				doc("example.com", "https://example.com", "https://example.co.uk", "https://example.de"),
+				doc("example.co.uk"),
+				doc("example.de"),
+			},
+			nil,
+		},
+		{
+			"member missing",
+			[]Document{
+				doc("example.com", "https://example.com", "https://example.co.uk"),
+				doc("example.co.uk"),
+				doc("example.de"),
+			},
+			[]string{"group-missing https://example.de"},
+		},
+		{
+			"subdomain needs no entry",
+			[]Document{
+				doc("example.com", "https://example.com"),
+				doc("login.example.com"),
+			},
+			nil,
+		},
+		{
+			"canonical unreadable",
+			[]Document{
+				{Domain: "example.com", Error: "HTTP status 404"},
+				doc("example.co.uk"),
+			},
+			[]string{"group-missing https://example.co.uk"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Check(tt.docs, Options{Canonical: "example.com"})
+			if got := rules(report.Findings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() findings = %v, want %v", got, tt.want)
+			}
+			for _, f := range report.Findings {
+				if f.Remediation == nil || f.Remediation.Target != "https://example.com/.well-known/webauthn" || f.Remediation.Value != f.Origin {
+					t.Errorf("Unexpected remediation %+v of %s", f.Remediation, f.Origin)
+				}
+			}
+		})
+	}
+}
+
+// TestCheckBackwards tests that a member listing the canonical RP instead is pointed out.
+func TestCheckBackwards(t *testing.T) {
+	report := Check([]Document{
+		doc("example.com", "https://example.com"),
+		doc("example.de", "https://example.com"),
+	}, Options{Canonical: "example.com"})
+	if len(report.Findings) != 1 || !strings.Contains(report.Findings[0].Message, "instead") {
+		t.Fatalf("Expected one finding about the backwards relationship, got %+v", report.Findings)
+	}
+	if rel, ok := report.Relationship("example.de", "example.com"); !ok || rel.Status != StatusListed {
+		t.Errorf("Relationship() = %+v, %v, want %s", rel, ok, StatusListed)
+	}
+}
+
+// TestCheckMesh tests a group whose members are all meant to use each other's RP ID.
+func TestCheckMesh(t *testing.T) {
+	tests := []struct {
+		name string
+		docs []Document
+		want []string
+	}{
+		{
+			"reciprocal",
+			[]Document{
+				doc("example.com", "https://example.co.uk"),
+				doc("example.co.uk", "https://example.com"),
+			},
+			nil,
+		},
+		{
+			"one way",
+			[]Document{
+				doc("example.com", "https://example.co.uk"),
+				doc("example.co.uk"),
+			},
+			[]string{"group-one-way https://example.com"},
+		},
+		{
+			"neither way",
+			[]Document{
+				doc("example.com"),
+				doc("example.co.uk"),
+			},
+			[]string{"group-missing https://example.co.uk", "group-missing https://example.com"},
+		},
+		{
+			"subdomain",
+			[]Document{
+				doc("example.com", "https://login.example.com"),
+				doc("login.example.com"),
+			},
+			[]string{"group-one-way https://example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Check(tt.docs, Options{})
+			if got := rules(report.Findings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() findings = %v, want %v", got, tt.want)
+			}
+			if want := len(tt.docs) * (len(tt.docs) - 1); len(report.Relationships) != want {
+				t.Errorf("Check() returned %d relationships, want %d", len(report.Relationships), want)
+			}
+		})
+	}
+}
+
+// TestGraphs tests the DOT and Mermaid diagrams of a group.
+func TestGraphs(t *testing.T) {
+	report := Check([]Document{
+		doc("example.com", "https://example.com", "https://example.co.uk", "https://example.de", "https://shop.net", "https://brand.com", "https://other.org"),
+		doc("example.co.uk"),
+		doc("login.example.com"),
+		{Domain: "example.de", Error: "HTTP status 404"},
+	}, Options{Canonical: "example.com", MaxLabels: 5})
+
+	dot := report.DOT()
+	for _, want := range []string{
+		`"example.com" [label="example.com\n4/5 labels", fillcolor="` + colorLastLabel + `", peripheries=2];`,
+		`"example.de" [label="example.de\nunreadable", fillcolor="` + colorNone + `"];`,
+		`"example.com" -> "example.co.uk" [label="example", color="` + colorLastLabel + `"];`,
+		`"example.com" -> "login.example.com" [label="RP ID suffix", color="` + colorNone + `", style=dashed];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT() is missing %s, got:\n%s", want, dot)
+		}
+	}
+
+	mermaid := report.Mermaid()
+	for _, want := range []string{
+		`n0["example.com<br/>4/5 labels"]`,
+		`n0 -->|example| n1`,
+		`n0 -.->|RP ID suffix| n2`,
+		`style n0 fill:` + colorLastLabel + `,color:#fff,stroke:#000,stroke-width:3px`,
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Mermaid() is missing %s, got:\n%s", want, mermaid)
+		}
+	}
+}
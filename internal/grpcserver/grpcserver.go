@@ -0,0 +1,173 @@
+// Package grpcserver exposes the validation API over gRPC.
+//
+// The gRPC service shares its document cache, negative cache and circuit breaker with the
+// REST server, so both interfaces can be served side by side from one process. Deadlines
+// set by gRPC clients are propagated: a request returns DEADLINE_EXCEEDED as soon as its
+// deadline passes, while the upstream fetch it started completes in the background and is
+// cached for later requests.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/developmeh/passkey-origin-validator/internal/server"
+	"github.com/developmeh/passkey-origin-validator/pkg/validatorpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultBatchConcurrency is the number of requests of one BatchValidate stream that are
+// validated in parallel.
+const DefaultBatchConcurrency = 16
+
+// Service implements validatorpb.ValidatorServiceServer on top of a REST server's state.
+type Service struct {
+	validatorpb.UnimplementedValidatorServiceServer
+
+	server *server.Server
+	// batchConcurrency is the number of requests of one stream validated in parallel.
+	batchConcurrency int
+}
+
+// New returns a Service that answers requests using s.
+func New(s *server.Server) *Service {
+	return &Service{
+		server:           s,
+		batchConcurrency: DefaultBatchConcurrency,
+	}
+}
+
+// Register registers a Service backed by s with the gRPC server g.
+func Register(g *grpc.Server, s *server.Server) {
+	validatorpb.RegisterValidatorServiceServer(g, New(s))
+}
+
+// contextError converts a context error into the matching gRPC status error.
+func contextError(err error) error {
+	return status.FromContextError(err).Err()
+}
+
+// ValidateOrigin validates a caller origin against a domain's document. Upstream fetch
+// failures are reported in the response's error field, not as a gRPC error.
+func (s *Service) ValidateOrigin(ctx context.Context, req *validatorpb.ValidateOriginRequest) (*validatorpb.ValidateOriginResponse, error) {
+	if req.GetDomain() == "" || req.GetOrigin() == "" {
+		return nil, status.Error(codes.InvalidArgument, "the domain and origin are required")
+	}
+	resp, err := s.server.Validate(ctx, req.GetDomain(), req.GetOrigin())
+	if err != nil {
+		return nil, contextError(err)
+	}
+	return toValidateResponse(resp), nil
+}
+
+// CountLabels counts the unique labels in a domain's document. Upstream fetch failures
+// are reported in the response's error field, not as a gRPC error.
+func (s *Service) CountLabels(ctx context.Context, req *validatorpb.CountLabelsRequest) (*validatorpb.CountLabelsResponse, error) {
+	if req.GetDomain() == "" {
+		return nil, status.Error(codes.InvalidArgument, "the domain is required")
+	}
+	resp, err := s.server.Count(ctx, req.GetDomain())
+	if err != nil {
+		return nil, contextError(err)
+	}
+	return &validatorpb.CountLabelsResponse{
+		Domain:       resp.Domain,
+		Url:          resp.URL,
+		LabelCount:   int32(resp.LabelCount),
+		Labels:       resp.Labels,
+		ExceedsLimit: resp.ExceedsLimit,
+		Warnings:     resp.Warnings,
+		Error:        resp.Error,
+		CircuitOpen:  resp.CircuitOpen,
+		Cached:       resp.Cached,
+	}, nil
+}
+
+// BatchValidate validates every request received on the stream, in parallel, and sends
+// one response per request in completion order. Invalid requests are answered with a
+// response describing the problem rather than ending the stream.
+func (s *Service) BatchValidate(stream validatorpb.ValidatorService_BatchValidateServer) error {
+	ctx := stream.Context()
+
+	var (
+		wg      sync.WaitGroup
+		sendMu  sync.Mutex
+		sendErr error
+	)
+	// send serializes responses, since a stream must not be written concurrently
+	send := func(resp *validatorpb.ValidateOriginResponse) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		if sendErr == nil {
+			sendErr = stream.Send(resp)
+		}
+	}
+
+	slots := make(chan struct{}, s.batchConcurrency)
+	var recvErr error
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				recvErr = err
+			}
+			break
+		}
+
+		if req.GetDomain() == "" || req.GetOrigin() == "" {
+			send(&validatorpb.ValidateOriginResponse{
+				Domain: req.GetDomain(),
+				Origin: req.GetOrigin(),
+				Error:  "the domain and origin are required",
+			})
+			continue
+		}
+
+		// Bound the number of validations in flight for this stream
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return contextError(ctx.Err())
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			resp, err := s.server.Validate(ctx, req.GetDomain(), req.GetOrigin())
+			if err != nil {
+				return
+			}
+			send(toValidateResponse(resp))
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+	if recvErr != nil {
+		return recvErr
+	}
+	return sendErr
+}
+
+// toValidateResponse converts a REST validation response into its gRPC message.
+func toValidateResponse(resp server.ValidateResponse) *validatorpb.ValidateOriginResponse {
+	return &validatorpb.ValidateOriginResponse{
+		Domain:      resp.Domain,
+		Url:         resp.URL,
+		Origin:      resp.Origin,
+		Status:      resp.Status,
+		Authorized:  resp.Authorized,
+		LabelCount:  int32(resp.LabelCount),
+		Labels:      resp.Labels,
+		Error:       resp.Error,
+		CircuitOpen: resp.CircuitOpen,
+		Cached:      resp.Cached,
+	}
+}
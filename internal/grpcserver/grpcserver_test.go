@@ -0,0 +1,134 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/server"
+	"github.com/developmeh/passkey-origin-validator/pkg/validatorpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newClient starts a gRPC server backed by a fresh REST server on an in-memory listener
+// and returns a client connected to it.
+func newClient(t *testing.T) validatorpb.ValidatorServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	Register(g, server.New(server.DefaultOptions()))
+	go g.Serve(listener)
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return validatorpb.NewValidatorServiceClient(conn)
+}
+
+// TestService tests the gRPC service.
+func TestService(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"origins": ["https://example.com", "https://login.example.org"]}`))
+	}))
+	defer upstream.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	client := newClient(t)
+	ctx := context.Background()
+
+	t.Run("ValidateOrigin", func(t *testing.T) {
+		resp, err := client.ValidateOrigin(ctx, &validatorpb.ValidateOriginRequest{Domain: upstream.URL, Origin: "https://example.com"})
+		if err != nil {
+			t.Fatalf("ValidateOrigin returned an error: %v", err)
+		}
+		if !resp.GetAuthorized() || resp.GetStatus() != "SUCCESS" {
+			t.Errorf("Unexpected response %v", resp)
+		}
+	})
+
+	t.Run("CountLabels", func(t *testing.T) {
+		resp, err := client.CountLabels(ctx, &validatorpb.CountLabelsRequest{Domain: upstream.URL})
+		if err != nil {
+			t.Fatalf("CountLabels returned an error: %v", err)
+		}
+		if resp.GetLabelCount() != 1 || resp.GetExceedsLimit() {
+			t.Errorf("Unexpected response %v", resp)
+		}
+	})
+
+	t.Run("Invalid argument", func(t *testing.T) {
+		_, err := client.ValidateOrigin(ctx, &validatorpb.ValidateOriginRequest{Domain: upstream.URL})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Expected InvalidArgument, got %v", err)
+		}
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		_, err := client.ValidateOrigin(short, &validatorpb.ValidateOriginRequest{Domain: slow.URL, Origin: "https://example.com"})
+		if status.Code(err) != codes.DeadlineExceeded {
+			t.Errorf("Expected DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("BatchValidate", func(t *testing.T) {
+		stream, err := client.BatchValidate(ctx)
+		if err != nil {
+			t.Fatalf("BatchValidate returned an error: %v", err)
+		}
+		origins := []string{"https://example.com", "https://login.example.org", "https://evil.example.net", ""}
+		for _, origin := range origins {
+			if err := stream.Send(&validatorpb.ValidateOriginRequest{Domain: upstream.URL, Origin: origin}); err != nil {
+				t.Fatalf("Send returned an error: %v", err)
+			}
+		}
+		stream.CloseSend()
+
+		results := make(map[string]*validatorpb.ValidateOriginResponse)
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Recv returned an error: %v", err)
+			}
+			results[resp.GetOrigin()] = resp
+		}
+
+		if len(results) != len(origins) {
+			t.Fatalf("Expected %d responses, got %d", len(origins), len(results))
+		}
+		if !results["https://example.com"].GetAuthorized() || !results["https://login.example.org"].GetAuthorized() {
+			t.Errorf("Expected the listed origins to be authorized, got %v", results)
+		}
+		if results["https://evil.example.net"].GetAuthorized() {
+			t.Errorf("Expected the unlisted origin to be rejected")
+		}
+		if results[""].GetError() == "" {
+			t.Errorf("Expected the invalid request to be answered with an error")
+		}
+	})
+}
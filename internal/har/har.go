@@ -0,0 +1,446 @@
+// Package har records the HTTP exchanges of a run as an HTTP Archive (HAR 1.2): the
+// headers and body of every request and response, and the time spent in each phase of
+// the exchange, so that what a server sent can be attached to a ticket as evidence and
+// opened in any HAR viewer, such as the network panel of browser developer tools.
+//
+// Exchanges are recorded as the transport sends them, redirects included, each as an
+// entry of its own. A request that received no response, because of a connection or TLS
+// failure, is recorded with status 0 and the failure in the custom _error field. Headers
+// the standard transport adds on the wire, such as User-Agent and Accept-Encoding when
+// the request does not set them, are not part of the recorded request.
+package har
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// Version is the version of the HAR format written.
+	Version = "1.2"
+	// MaxBodySize is the number of bytes of a response body that are recorded; the rest
+	// is still passed on, but left out of the archive.
+	MaxBodySize = 4 << 20
+)
+
+// HAR is an HTTP Archive.
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+// Log is the root of an HTTP Archive.
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+}
+
+// Creator is the application that recorded an archive.
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry is one recorded HTTP exchange.
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	// Time is the total time of the exchange in milliseconds.
+	Time     float64  `json:"time"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+	// Cache is always empty: the recorder sits below the response cache.
+	Cache   struct{} `json:"cache"`
+	Timings Timings  `json:"timings"`
+	// ServerIPAddress is the address of the server the request was sent to, if the
+	// connection was made.
+	ServerIPAddress string `json:"serverIPAddress,omitempty"`
+	// Error is why no response was received, such as a connection or TLS failure.
+	Error string `json:"_error,omitempty"`
+}
+
+// NameValue is a header, a query string parameter or a cookie.
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Request is the request of an entry.
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	// HeadersSize and BodySize are -1 when unknown.
+	HeadersSize int   `json:"headersSize"`
+	BodySize    int64 `json:"bodySize"`
+}
+
+// Response is the response of an entry, with status 0 if none was received.
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	// HeadersSize and BodySize are -1 when unknown. BodySize is the size on the wire,
+	// which is unknown when the transport decompressed the body.
+	HeadersSize int   `json:"headersSize"`
+	BodySize    int64 `json:"bodySize"`
+}
+
+// Content is the body of a response.
+type Content struct {
+	// Size is the length of the body, decompressed.
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	// Text is the body, encoded as base64 if Encoding is "base64" because it is not
+	// valid UTF-8.
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	// Comment notes a body recorded partly, such as one longer than MaxBodySize.
+	Comment string `json:"comment,omitempty"`
+}
+
+// Timings is the time, in milliseconds, spent in each phase of an exchange, or -1 for
+// a phase that did not take place, such as dns and connect when a connection was reused.
+// As in every HAR, connect includes ssl.
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// Recorder records the exchanges made through its transports.
+type Recorder struct {
+	// OnEntry, if set, is called with each entry once it is recorded. Calls are not
+	// concurrent, even when requests are.
+	OnEntry func(Entry)
+
+	creator Creator
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries []Entry
+	// notify serializes the calls to OnEntry
+	notify sync.Mutex
+}
+
+// New returns a recorder that names the application name at version as the creator of
+// its archive.
+func New(name, version string) *Recorder {
+	return &Recorder{creator: Creator{Name: name, Version: version}, now: time.Now}
+}
+
+// add records an entry and passes it to OnEntry.
+func (r *Recorder) add(entry Entry) {
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.mu.Unlock()
+
+	if r.OnEntry != nil {
+		r.notify.Lock()
+		defer r.notify.Unlock()
+		r.OnEntry(entry)
+	}
+}
+
+// Entries returns the entries recorded so far, in the order they completed.
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry{}, r.entries...)
+}
+
+// HAR returns the archive of the entries recorded so far.
+func (r *Recorder) HAR() HAR {
+	return HAR{Log: Log{Version: Version, Creator: r.creator, Entries: r.Entries()}}
+}
+
+// Write writes the archive of the entries recorded so far as indented JSON.
+func (r *Recorder) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode HAR: %w", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write HAR: %w", err)
+	}
+	return nil
+}
+
+// Save writes the archive of the entries recorded so far to a file, replacing it.
+func (r *Recorder) Save(path string) error {
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write HAR: %w", err)
+	}
+	return nil
+}
+
+// Transport returns a transport that sends requests with next and records every exchange.
+func (r *Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return transport{recorder: r, next: next}
+}
+
+// transport records every exchange made through it.
+type transport struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+// RoundTrip sends the request with the next transport, timing each phase, and records
+// the exchange once the response body, up to MaxBodySize bytes, is read.
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clock := &clock{now: t.recorder.now}
+	clock.start = clock.now()
+	entry := Entry{StartedDateTime: clock.start, Request: request(req)}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), clock.trace()))
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		entry.Error = err.Error()
+		entry.Response = Response{Cookies: []NameValue{}, Headers: []NameValue{}, HeadersSize: -1, BodySize: -1}
+		clock.finish(&entry, clock.now())
+		t.recorder.add(entry)
+		return nil, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxBodySize+1))
+	end := clock.now()
+	entry.Request.HTTPVersion = resp.Proto
+	entry.Response = response(resp, body)
+	switch {
+	case err != nil:
+		entry.Response.Content.Comment = fmt.Sprintf("failed to read response body: %s", err)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+	case len(body) > MaxBodySize:
+		// Pass the whole body on, and only leave the rest out of the archive
+		entry.Response.Content.Comment = fmt.Sprintf("only the first %d bytes of the body are recorded", MaxBodySize)
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	default:
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	clock.finish(&entry, end)
+	t.recorder.add(entry)
+	return resp, nil
+}
+
+// request returns the recorded request of req.
+func request(req *http.Request) Request {
+	recorded := Request{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     []NameValue{},
+		Headers:     []NameValue{},
+		QueryString: []NameValue{},
+		HeadersSize: -1,
+		BodySize:    req.ContentLength,
+	}
+	if recorded.HTTPVersion == "" {
+		recorded.HTTPVersion = "HTTP/1.1"
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		recorded.BodySize = 0
+	} else if recorded.BodySize <= 0 {
+		recorded.BodySize = -1
+	}
+	if req.Header.Get("Host") == "" {
+		host := req.Host
+		if host == "" {
+			host = req.URL.Host
+		}
+		recorded.Headers = append(recorded.Headers, NameValue{Name: "Host", Value: host})
+	}
+	recorded.Headers = append(recorded.Headers, headers(req.Header)...)
+	for _, cookie := range req.Cookies() {
+		recorded.Cookies = append(recorded.Cookies, NameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			recorded.QueryString = append(recorded.QueryString, NameValue{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(recorded.QueryString, func(a, b int) bool { return recorded.QueryString[a].Name < recorded.QueryString[b].Name })
+	return recorded
+}
+
+// response returns the recorded response of resp, with the body read from it.
+func response(resp *http.Response, body []byte) Response {
+	recorded := Response{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Cookies:     []NameValue{},
+		Headers:     headers(resp.Header),
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	if resp.ContentLength >= 0 && !resp.Uncompressed {
+		recorded.BodySize = resp.ContentLength
+	}
+	for _, cookie := range resp.Cookies() {
+		recorded.Cookies = append(recorded.Cookies, NameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	body = body[:min(len(body), MaxBodySize)]
+	recorded.Content = Content{Size: len(body), MimeType: resp.Header.Get("Content-Type")}
+	if utf8.Valid(body) {
+		recorded.Content.Text = string(body)
+	} else {
+		recorded.Content.Text = base64.StdEncoding.EncodeToString(body)
+		recorded.Content.Encoding = "base64"
+	}
+	return recorded
+}
+
+// headers returns header as name-value pairs sorted by name, one per value.
+func headers(header http.Header) []NameValue {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := []NameValue{}
+	for _, name := range names {
+		for _, value := range header[name] {
+			pairs = append(pairs, NameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+// clock times the phases of an exchange with the events of an httptrace.ClientTrace,
+// which may be delivered from other goroutines.
+type clock struct {
+	now func() time.Time
+
+	mu                        sync.Mutex
+	start                     time.Time
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	gotConn, wrote, firstByte time.Time
+	remoteAddr                string
+}
+
+// trace returns the client trace that records the events of the exchange.
+func (c *clock) trace() *httptrace.ClientTrace {
+	at := func(t *time.Time) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if t.IsZero() {
+			*t = c.now()
+		}
+	}
+	// A dial may try several addresses, so that a connection is done once the last one is
+	last := func(t *time.Time) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		*t = c.now()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { at(&c.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { last(&c.dnsDone) },
+		ConnectStart:         func(string, string) { at(&c.connectStart) },
+		ConnectDone:          func(string, string, error) { last(&c.connectDone) },
+		TLSHandshakeStart:    func() { at(&c.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { last(&c.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { at(&c.wrote) },
+		GotFirstResponseByte: func() { at(&c.firstByte) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			at(&c.gotConn)
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if info.Conn != nil {
+				c.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+		},
+	}
+}
+
+// finish sets the timings of an entry that ended at end. The time not accounted for by
+// any phase, such as waiting for a connection, is blocked.
+func (c *clock) finish(entry *Entry, end time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.Time = ms(c.start, end)
+	entry.ServerIPAddress = c.remoteAddr
+	entry.Timings = Timings{
+		DNS:     ms(c.dnsStart, c.dnsDone),
+		Connect: ms(c.connectStart, latest(c.connectDone, c.tlsDone)),
+		SSL:     ms(c.tlsStart, c.tlsDone),
+		Send:    ms(latest(c.gotConn, c.start), c.wrote),
+		Wait:    ms(c.wrote, c.firstByte),
+		Receive: ms(c.firstByte, end),
+	}
+
+	accounted := 0.0
+	for _, phase := range []float64{entry.Timings.DNS, entry.Timings.Connect, entry.Timings.Send, entry.Timings.Wait, entry.Timings.Receive} {
+		accounted += max(phase, 0)
+	}
+	entry.Timings.Blocked = max(entry.Time-accounted, 0)
+}
+
+// ms returns the milliseconds from start to end, or -1 if either did not happen.
+func ms(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return -1
+	}
+	return float64(end.Sub(start).Microseconds()) / 1000
+}
+
+// latest returns the later of two times, ignoring one that did not happen.
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// readCloser reads from one reader and closes another.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// errReader fails every read with its error.
+type errReader struct {
+	err error
+}
+
+// Read returns the error.
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,133 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTransport tests recording an exchange and a request that received no response.
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(`{"origins": ["https://a.com"]}`))
+	}))
+	defer server.Close()
+
+	recorder := New("passkey-origin-validator", "dev")
+	var notified []Entry
+	recorder.OnEntry = func(entry Entry) { notified = append(notified, entry) }
+	client := &http.Client{Transport: recorder.Transport(nil)}
+
+	resp, err := client.Get(server.URL + "/.well-known/webauthn?v=1")
+	if err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+	body := new(bytes.Buffer)
+	body.ReadFrom(resp.Body)
+	resp.Body.Close()
+	if body.String() != `{"origins": ["https://a.com"]}` {
+		t.Errorf("Expected the body to be passed on, got %q", body)
+	}
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	if _, err := client.Get(unreachable.URL); err == nil {
+		t.Fatal("Expected an error from a closed server")
+	}
+
+	entries := recorder.Entries()
+	if len(entries) != 2 || len(notified) != 2 {
+		t.Fatalf("Expected 2 entries and 2 notifications, got %d and %d", len(entries), len(notified))
+	}
+
+	entry := entries[0]
+	if entry.Request.Method != "GET" || entry.Request.Headers[0] != (NameValue{Name: "Host", Value: strings.TrimPrefix(server.URL, "http://")}) {
+		t.Errorf("Unexpected request %+v", entry.Request)
+	}
+	if len(entry.Request.QueryString) != 1 || entry.Request.QueryString[0] != (NameValue{Name: "v", Value: "1"}) {
+		t.Errorf("Expected the query string v=1, got %+v", entry.Request.QueryString)
+	}
+	if entry.Response.Status != 200 || entry.Response.StatusText != "OK" || entry.Response.HTTPVersion != "HTTP/1.1" {
+		t.Errorf("Unexpected status line %d %q %q", entry.Response.Status, entry.Response.StatusText, entry.Response.HTTPVersion)
+	}
+	if content := entry.Response.Content; content.Text != body.String() || content.MimeType != "application/json" || content.Size != body.Len() {
+		t.Errorf("Unexpected content %+v", content)
+	}
+	if entry.ServerIPAddress == "" {
+		t.Error("Expected the server address to be recorded")
+	}
+	if entry.Timings.Wait < 0 || entry.Timings.Connect < 0 || entry.Timings.SSL != -1 {
+		t.Errorf("Expected wait and connect timings without ssl, got %+v", entry.Timings)
+	}
+
+	failed := entries[1]
+	if failed.Error == "" || failed.Response.Status != 0 {
+		t.Errorf("Expected a failed entry, got %+v", failed)
+	}
+}
+
+// TestWrite tests that an archive is a HAR 1.2 log, with an empty list of entries rather
+// than null.
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	if err := New("passkey-origin-validator", "dev").Write(&buf); err != nil {
+		t.Fatalf("Write returned an error: %v", err)
+	}
+	var archive map[string]map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &archive); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if archive["log"]["version"] != "1.2" {
+		t.Errorf("Expected version 1.2, got %v", archive["log"]["version"])
+	}
+	if entries, ok := archive["log"]["entries"].([]any); !ok || len(entries) != 0 {
+		t.Errorf("Expected an empty list of entries, got %v", archive["log"]["entries"])
+	}
+}
+
+// TestWriteTranscript tests the raw transcript of an exchange.
+func TestWriteTranscript(t *testing.T) {
+	entry := Entry{
+		StartedDateTime: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Time:            12.5,
+		Request: Request{
+			Method:      "GET",
+			URL:         "https://example.com/.well-known/webauthn",
+			HTTPVersion: "HTTP/2.0",
+			Headers:     []NameValue{{Name: "Host", Value: "example.com"}},
+		},
+		Response: Response{
+			Status:      200,
+			StatusText:  "OK",
+			HTTPVersion: "HTTP/2.0",
+			Headers:     []NameValue{{Name: "Content-Type", Value: "application/json"}},
+			Content:     Content{Text: `{"origins": []}`},
+		},
+		Timings:         Timings{Blocked: 0.5, DNS: -1, Connect: -1, SSL: -1, Send: 1, Wait: 10, Receive: 1},
+		ServerIPAddress: "192.0.2.1:443",
+	}
+	var buf bytes.Buffer
+	if err := WriteTranscript(&buf, entry); err != nil {
+		t.Fatalf("WriteTranscript returned an error: %v", err)
+	}
+	expected := `* GET https://example.com/.well-known/webauthn (192.0.2.1:443) at 2026-01-02T03:04:05.000Z
+> GET /.well-known/webauthn HTTP/2.0
+> Host: example.com
+>
+< HTTP/2.0 200 OK
+< Content-Type: application/json
+<
+{"origins": []}
+* 12.5 ms: blocked 0.5, dns -, connect - (ssl -), send 1.0, wait 10.0, receive 1.0
+
+`
+	if buf.String() != expected {
+		t.Errorf("Expected transcript:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
@@ -0,0 +1,69 @@
+package har
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// WriteTranscript writes an entry as a raw HTTP transcript, in the manner of curl
+// --verbose: the request line and headers prefixed with "> ", the status line and
+// headers with "< ", the body, and lines prefixed with "* " for the exchange itself and
+// its timings.
+func WriteTranscript(w io.Writer, e Entry) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "* %s %s", e.Request.Method, e.Request.URL)
+	if e.ServerIPAddress != "" {
+		fmt.Fprintf(&b, " (%s)", e.ServerIPAddress)
+	}
+	fmt.Fprintf(&b, " at %s\n", e.StartedDateTime.Format("2006-01-02T15:04:05.000Z07:00"))
+
+	target := e.Request.URL
+	if u, err := url.Parse(e.Request.URL); err == nil {
+		target = u.RequestURI()
+	}
+	fmt.Fprintf(&b, "> %s %s %s\n", e.Request.Method, target, e.Request.HTTPVersion)
+	for _, header := range e.Request.Headers {
+		fmt.Fprintf(&b, "> %s: %s\n", header.Name, header.Value)
+	}
+	b.WriteString(">\n")
+
+	if e.Error != "" {
+		fmt.Fprintf(&b, "* no response: %s\n", e.Error)
+	} else {
+		fmt.Fprintf(&b, "< %s %d %s\n", e.Response.HTTPVersion, e.Response.Status, e.Response.StatusText)
+		for _, header := range e.Response.Headers {
+			fmt.Fprintf(&b, "< %s: %s\n", header.Name, header.Value)
+		}
+		b.WriteString("<\n")
+		switch content := e.Response.Content; {
+		case content.Encoding == "base64":
+			fmt.Fprintf(&b, "* %d bytes of binary content\n", content.Size)
+		case content.Text != "":
+			b.WriteString(content.Text)
+			if !strings.HasSuffix(content.Text, "\n") {
+				b.WriteByte('\n')
+			}
+		}
+		if e.Response.Content.Comment != "" {
+			fmt.Fprintf(&b, "* %s\n", e.Response.Content.Comment)
+		}
+	}
+
+	t := e.Timings
+	fmt.Fprintf(&b, "* %s ms: blocked %s, dns %s, connect %s (ssl %s), send %s, wait %s, receive %s\n\n",
+		duration(e.Time), duration(t.Blocked), duration(t.DNS), duration(t.Connect), duration(t.SSL),
+		duration(t.Send), duration(t.Wait), duration(t.Receive))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// duration formats a timing in milliseconds, or "-" for a phase that did not take place.
+func duration(ms float64) string {
+	if ms < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", ms)
+}
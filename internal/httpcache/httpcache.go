@@ -0,0 +1,255 @@
+// Package httpcache provides a persistent on-disk HTTP response cache.
+//
+// Repeated runs and batch scans fetch the same .well-known/webauthn documents over and
+// over. The cache stores responses keyed by URL, honors Cache-Control max-age, and
+// revalidates stale entries with conditional GETs (If-None-Match / If-Modified-Since)
+// so that unchanged documents cost the origin server a 304 instead of a full response.
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheStatusHeader is added to responses served by the cache transport to describe
+// how the cache was used: "HIT", "REVALIDATED" or "MISS".
+const CacheStatusHeader = "X-Passkey-Origin-Validator-Cache"
+
+// MaxEntrySize is the largest response body stored in the cache.
+const MaxEntrySize = 4 << 20 // 4MB
+
+// entry is a cached response as stored on disk.
+type entry struct {
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	StoredAt   time.Time   `json:"stored_at"`
+	Expires    time.Time   `json:"expires"`
+}
+
+// Cache is a directory of cached responses.
+type Cache struct {
+	dir string
+	now func() time.Time
+}
+
+// DefaultDir returns the default cache directory in the user's cache directory.
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "passkey-origin-validator")
+}
+
+// New returns a Cache that stores entries in dir, creating it if necessary.
+func New(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Cache{dir: dir, now: time.Now}, nil
+}
+
+// path returns the file path of the entry for the given URL.
+func (c *Cache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load returns the cached entry for url, or nil if there is none.
+func (c *Cache) load(url string) *entry {
+	data, err := os.ReadFile(c.path(url))
+	if err != nil {
+		return nil
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil || e.URL != url {
+		return nil
+	}
+	return &e
+}
+
+// store writes the entry to disk atomically.
+func (c *Cache) store(e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	tmp.Close()
+	return os.Rename(tmp.Name(), c.path(e.URL))
+}
+
+// cacheControl parses a Cache-Control header into its directives.
+func cacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// expiry computes when a response stops being fresh, based on Cache-Control max-age and Expires.
+// Responses without freshness information expire immediately and must be revalidated.
+func expiry(header http.Header, now time.Time) time.Time {
+	directives := cacheControl(header)
+	if _, ok := directives["no-cache"]; ok {
+		return now
+	}
+	if maxAge, ok := directives["max-age"]; ok {
+		if seconds, err := strconv.Atoi(maxAge); err == nil {
+			return now.Add(time.Duration(seconds) * time.Second)
+		}
+	}
+	if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		return expires
+	}
+	return now
+}
+
+// cacheable reports whether a response may be stored.
+func cacheable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	if _, ok := cacheControl(resp.Header)["no-store"]; ok {
+		return false
+	}
+	// Without validators or freshness there is nothing to gain from storing the response
+	hasValidator := resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+	hasFreshness := resp.Header.Get("Expires") != "" || cacheControl(resp.Header)["max-age"] != ""
+	return hasValidator || hasFreshness
+}
+
+// response builds an *http.Response for the request from a cached entry.
+func (e *entry) response(req *http.Request, status string) *http.Response {
+	header := e.Header.Clone()
+	header.Set(CacheStatusHeader, status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// Transport returns an http.RoundTripper that serves GET requests from the cache and
+// stores cacheable responses. If base is nil, http.DefaultTransport is used.
+func (c *Cache) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{cache: c, base: base}
+}
+
+// transport is the caching http.RoundTripper.
+type transport struct {
+	cache *Cache
+	base  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	cached := t.cache.load(key)
+	now := t.cache.now()
+
+	// Serve fresh entries without contacting the server
+	if cached != nil && now.Before(cached.Expires) {
+		return cached.response(req, "HIT"), nil
+	}
+
+	// Revalidate stale entries with a conditional GET
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		// Refresh the stored headers and freshness from the 304 response
+		for name, values := range resp.Header {
+			cached.Header[name] = values
+		}
+		cached.Expires = expiry(resp.Header, now)
+		t.cache.store(cached)
+		return cached.response(req, "REVALIDATED"), nil
+	}
+
+	if !cacheable(resp) {
+		resp.Header.Set(CacheStatusHeader, "MISS")
+		return resp, nil
+	}
+
+	// Buffer the body so it can be stored, unless it is too large to be worth caching
+	reader := bufio.NewReader(resp.Body)
+	body, err := io.ReadAll(io.LimitReader(reader, MaxEntrySize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > MaxEntrySize {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), reader), resp.Body}
+		resp.Header.Set(CacheStatusHeader, "MISS")
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	stored := &entry{
+		URL:        key,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		StoredAt:   now,
+		Expires:    expiry(resp.Header, now),
+	}
+	t.cache.store(stored)
+	return stored.response(req, "MISS"), nil
+}
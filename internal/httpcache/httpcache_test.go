@@ -0,0 +1,102 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// TestTransport tests the caching transport against servers with different caching headers.
+func TestTransport(t *testing.T) {
+	get := func(t *testing.T, client *http.Client, url string) (string, string) {
+		t.Helper()
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("Request returned an error: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.Header.Get(CacheStatusHeader)
+	}
+
+	t.Run("Fresh entries are served from the cache", func(t *testing.T) {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Cache-Control", "max-age=300")
+			w.Write([]byte(`{"origins": []}`))
+		}))
+		defer server.Close()
+
+		cache, err := New(t.TempDir())
+		if err != nil {
+			t.Fatalf("New returned an error: %v", err)
+		}
+		client := &http.Client{Transport: cache.Transport(nil)}
+
+		if _, status := get(t, client, server.URL); status != "MISS" {
+			t.Errorf("Expected MISS, got %s", status)
+		}
+		body, status := get(t, client, server.URL)
+		if status != "HIT" || body != `{"origins": []}` {
+			t.Errorf("Expected HIT with cached body, got %s %q", status, body)
+		}
+		if hits.Load() != 1 {
+			t.Errorf("Expected 1 request to the server, got %d", hits.Load())
+		}
+	})
+
+	t.Run("Stale entries are revalidated with ETag", func(t *testing.T) {
+		var full atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Cache-Control", "no-cache")
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			full.Add(1)
+			w.Write([]byte(`{"origins": ["https://example.com"]}`))
+		}))
+		defer server.Close()
+
+		cache, err := New(t.TempDir())
+		if err != nil {
+			t.Fatalf("New returned an error: %v", err)
+		}
+		client := &http.Client{Transport: cache.Transport(nil)}
+
+		get(t, client, server.URL)
+		body, status := get(t, client, server.URL)
+		if status != "REVALIDATED" || body != `{"origins": ["https://example.com"]}` {
+			t.Errorf("Expected REVALIDATED with cached body, got %s %q", status, body)
+		}
+		if full.Load() != 1 {
+			t.Errorf("Expected 1 full response, got %d", full.Load())
+		}
+	})
+
+	t.Run("Uncacheable responses are not stored", func(t *testing.T) {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.Header().Set("Cache-Control", "no-store, max-age=300")
+			w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+
+		cache, err := New(t.TempDir())
+		if err != nil {
+			t.Fatalf("New returned an error: %v", err)
+		}
+		client := &http.Client{Transport: cache.Transport(nil)}
+
+		get(t, client, server.URL)
+		get(t, client, server.URL)
+		if hits.Load() != 2 {
+			t.Errorf("Expected 2 requests to the server, got %d", hits.Load())
+		}
+	})
+}
@@ -0,0 +1,168 @@
+// Package httpconfig holds the transport-level HTTP settings of the configuration file,
+// which apply to the connections every command makes to the documents it fetches.
+//
+// Settings are read once when a command starts and never changed afterwards, so the
+// transports built from them, including those built concurrently by batch workers, all
+// see the same values.
+package httpconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ProxyNone is the proxy setting that connects directly, ignoring the proxy
+// environment variables.
+const ProxyNone = "none"
+
+// Settings are the http section of the configuration file. Zero values keep the
+// defaults of Go's http.DefaultTransport, and of the --timeout flag for dialing.
+type Settings struct {
+	// MaxIdleConns is the maximum number of idle connections kept across all hosts.
+	MaxIdleConns int `mapstructure:"max_idle_conns"`
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept to each host.
+	MaxIdleConnsPerHost int `mapstructure:"max_idle_conns_per_host"`
+	// MaxConnsPerHost limits the connections to each host, including those in use.
+	MaxConnsPerHost int `mapstructure:"max_conns_per_host"`
+	// IdleConnTimeout is how long an idle connection is kept before it is closed.
+	IdleConnTimeout time.Duration `mapstructure:"idle_conn_timeout"`
+	// DialTimeout is how long connecting to a host may take.
+	DialTimeout time.Duration `mapstructure:"dial_timeout"`
+	// TLSHandshakeTimeout is how long the TLS handshake may take.
+	TLSHandshakeTimeout time.Duration `mapstructure:"tls_handshake_timeout"`
+	// ResponseHeaderTimeout is how long the response headers may take to arrive once
+	// the request is sent.
+	ResponseHeaderTimeout time.Duration `mapstructure:"response_header_timeout"`
+	// TLSMinVersion is the lowest TLS version negotiated, such as "1.2".
+	TLSMinVersion string `mapstructure:"tls_min_version"`
+	// TLSMaxVersion is the highest TLS version negotiated, such as "1.3".
+	TLSMaxVersion string `mapstructure:"tls_max_version"`
+	// Proxy is the URL of the proxy every request goes through, ProxyNone to connect
+	// directly, or "" to use the proxy environment variables.
+	Proxy string `mapstructure:"proxy"`
+}
+
+// tlsVersions maps the TLS versions accepted in Settings to their crypto/tls constants.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Validate checks that the settings are consistent.
+func (s Settings) Validate() error {
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{
+		{"max_idle_conns", s.MaxIdleConns},
+		{"max_idle_conns_per_host", s.MaxIdleConnsPerHost},
+		{"max_conns_per_host", s.MaxConnsPerHost},
+	} {
+		if limit.value < 0 {
+			return fmt.Errorf("invalid http.%s %d: must not be negative", limit.name, limit.value)
+		}
+	}
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"idle_conn_timeout", s.IdleConnTimeout},
+		{"dial_timeout", s.DialTimeout},
+		{"tls_handshake_timeout", s.TLSHandshakeTimeout},
+		{"response_header_timeout", s.ResponseHeaderTimeout},
+	} {
+		if timeout.value < 0 {
+			return fmt.Errorf("invalid http.%s %s: must not be negative", timeout.name, timeout.value)
+		}
+	}
+
+	minVersion, err := parseTLSVersion("tls_min_version", s.TLSMinVersion)
+	if err != nil {
+		return err
+	}
+	maxVersion, err := parseTLSVersion("tls_max_version", s.TLSMaxVersion)
+	if err != nil {
+		return err
+	}
+	if minVersion != 0 && maxVersion != 0 && minVersion > maxVersion {
+		return fmt.Errorf("invalid http.tls_min_version %s: it is above http.tls_max_version %s", s.TLSMinVersion, s.TLSMaxVersion)
+	}
+
+	if s.Proxy != "" && s.Proxy != ProxyNone {
+		proxyURL, err := url.Parse(s.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return fmt.Errorf("invalid http.proxy %q: expected a URL such as http://proxy.example.com:3128, or %q", s.Proxy, ProxyNone)
+		}
+	}
+	return nil
+}
+
+// parseTLSVersion returns the crypto/tls constant of a TLS version setting, or 0 if it
+// is not set.
+func parseTLSVersion(name, version string) (uint16, error) {
+	if version == "" {
+		return 0, nil
+	}
+	value, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(version), "tls")]
+	if !ok {
+		return 0, fmt.Errorf("invalid http.%s %q: expected 1.0, 1.1, 1.2 or 1.3", name, version)
+	}
+	return value, nil
+}
+
+// ProxySet reports whether the settings send requests through a proxy of their own,
+// rather than the one of the environment or none.
+func (s Settings) ProxySet() bool {
+	return s.Proxy != "" && s.Proxy != ProxyNone
+}
+
+// Apply sets the settings on transport, and the dial timeout on dialer, which the caller
+// then dials the transport's connections with. The settings must be valid.
+func (s Settings) Apply(transport *http.Transport, dialer *net.Dialer) {
+	if s.MaxIdleConns > 0 {
+		transport.MaxIdleConns = s.MaxIdleConns
+	}
+	if s.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = s.MaxIdleConnsPerHost
+	}
+	if s.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = s.MaxConnsPerHost
+	}
+	if s.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = s.IdleConnTimeout
+	}
+	if s.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = s.TLSHandshakeTimeout
+	}
+	if s.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = s.ResponseHeaderTimeout
+	}
+	if s.DialTimeout > 0 {
+		dialer.Timeout = s.DialTimeout
+	}
+
+	minVersion, _ := parseTLSVersion("tls_min_version", s.TLSMinVersion)
+	maxVersion, _ := parseTLSVersion("tls_max_version", s.TLSMaxVersion)
+	if minVersion != 0 || maxVersion != 0 {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.MinVersion = minVersion
+		transport.TLSClientConfig.MaxVersion = maxVersion
+	}
+
+	switch {
+	case s.Proxy == ProxyNone:
+		transport.Proxy = nil
+	case s.Proxy != "":
+		proxyURL, _ := url.Parse(s.Proxy)
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+}
@@ -0,0 +1,66 @@
+package httpconfig
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestValidate tests the checks of the settings.
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		wantErr  bool
+	}{
+		{"empty", Settings{}, false},
+		{"all set", Settings{MaxIdleConns: 100, MaxConnsPerHost: 4, DialTimeout: 5 * time.Second, TLSMinVersion: "1.2", TLSMaxVersion: "TLS1.3", Proxy: "http://proxy.example.com:3128"}, false},
+		{"no proxy", Settings{Proxy: ProxyNone}, false},
+		{"negative connections", Settings{MaxIdleConnsPerHost: -1}, true},
+		{"negative timeout", Settings{ResponseHeaderTimeout: -time.Second}, true},
+		{"unknown TLS version", Settings{TLSMinVersion: "1.4"}, true},
+		{"TLS versions reversed", Settings{TLSMinVersion: "1.3", TLSMaxVersion: "1.2"}, true},
+		{"proxy without host", Settings{Proxy: "proxy.example.com"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.settings.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestApply tests setting the settings on a transport and dialer, and keeping the
+// defaults of those that are not set.
+func TestApply(t *testing.T) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	Settings{
+		MaxConnsPerHost: 4,
+		DialTimeout:     3 * time.Second,
+		TLSMinVersion:   "1.3",
+		Proxy:           "http://proxy.example.com:3128",
+	}.Apply(transport, dialer)
+
+	if transport.MaxConnsPerHost != 4 || dialer.Timeout != 3*time.Second {
+		t.Errorf("MaxConnsPerHost = %d, dial timeout = %s; want 4 and 3s", transport.MaxConnsPerHost, dialer.Timeout)
+	}
+	if want := http.DefaultTransport.(*http.Transport).MaxIdleConns; transport.MaxIdleConns != want {
+		t.Errorf("MaxIdleConns = %d, want the default %d", transport.MaxIdleConns, want)
+	}
+	if transport.TLSClientConfig == nil || transport.TLSClientConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("TLSClientConfig = %+v, want a minimum version of TLS 1.3", transport.TLSClientConfig)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/.well-known/webauthn", nil)
+	if proxyURL, err := transport.Proxy(req); err != nil || proxyURL == nil || proxyURL.Host != "proxy.example.com:3128" {
+		t.Errorf("Proxy() = %v, %v; want proxy.example.com:3128", proxyURL, err)
+	}
+
+	Settings{Proxy: ProxyNone}.Apply(transport, dialer)
+	if transport.Proxy != nil {
+		t.Errorf("Proxy is set after applying %q", ProxyNone)
+	}
+}
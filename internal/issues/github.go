@@ -0,0 +1,164 @@
+package issues
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GitHubAPI is the base URL of the GitHub REST API.
+const GitHubAPI = "https://api.github.com"
+
+// GitHub is a Tracker that keeps issues in a GitHub repository. Issues are labeled with
+// Label, and their fingerprint is recorded in the issue body.
+type GitHub struct {
+	client  *http.Client
+	baseURL string
+	repo    string
+	token   string
+}
+
+// NewGitHub returns a Tracker for the repository "owner/repo" on the GitHub API at baseURL.
+func NewGitHub(client *http.Client, baseURL, repo, token string) (*GitHub, error) {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid GitHub repository %q: expected owner/repo", repo)
+	}
+	return &GitHub{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		repo:    repo,
+		token:   token,
+	}, nil
+}
+
+// githubIssue is the part of a GitHub issue that the tracker reads.
+type githubIssue struct {
+	Number      int             `json:"number"`
+	Body        string          `json:"body"`
+	PullRequest json.RawMessage `json:"pull_request,omitempty"`
+}
+
+// Find returns the number of the open issue whose body records fingerprint.
+func (g *GitHub) Find(ctx context.Context, fingerprint string) (string, error) {
+	marker := "fingerprint: " + fingerprint
+	for page := 1; ; page++ {
+		var found []githubIssue
+		path := fmt.Sprintf("/repos/%s/issues?state=open&labels=%s&per_page=100&page=%d", g.repo, Label, page)
+		if err := g.do(ctx, http.MethodGet, path, nil, &found); err != nil {
+			return "", err
+		}
+		for _, issue := range found {
+			if issue.PullRequest == nil && strings.Contains(issue.Body, marker) {
+				return strconv.Itoa(issue.Number), nil
+			}
+		}
+		if len(found) < 100 {
+			return "", nil
+		}
+	}
+}
+
+// Open opens an issue labeled with Label and returns its number.
+func (g *GitHub) Open(ctx context.Context, issue Issue) (string, error) {
+	request := map[string]any{
+		"title":  issue.Title,
+		"body":   issue.Body,
+		"labels": []string{Label},
+	}
+	var created githubIssue
+	if err := g.do(ctx, http.MethodPost, "/repos/"+g.repo+"/issues", request, &created); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(created.Number), nil
+}
+
+// Comment adds a comment to the issue with number id.
+func (g *GitHub) Comment(ctx context.Context, id, body string) error {
+	return g.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/issues/%s/comments", g.repo, id), map[string]string{"body": body}, nil)
+}
+
+// Close comments on the issue with number id and closes it as completed.
+func (g *GitHub) Close(ctx context.Context, id, comment string) error {
+	if err := g.Comment(ctx, id, comment); err != nil {
+		return err
+	}
+	request := map[string]string{"state": "closed", "state_reason": "completed"}
+	return g.do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/issues/%s", g.repo, id), request, nil)
+}
+
+// PullRequest is a pull request to open in a GitHub repository.
+type PullRequest struct {
+	Title string
+	Body  string
+	// Head is the branch with the changes, and Base the branch they are to be merged into.
+	Head string
+	Base string
+}
+
+// OpenPullRequest opens a pull request labeled with Label and returns its URL.
+func (g *GitHub) OpenPullRequest(ctx context.Context, pr PullRequest) (string, error) {
+	request := map[string]string{
+		"title": pr.Title,
+		"body":  pr.Body,
+		"head":  pr.Head,
+		"base":  pr.Base,
+	}
+	var created struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := g.do(ctx, http.MethodPost, "/repos/"+g.repo+"/pulls", request, &created); err != nil {
+		return "", err
+	}
+	// Pull requests share their numbers and labels with issues
+	labels := map[string][]string{"labels": {Label}}
+	if err := g.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/labels", g.repo, created.Number), labels, nil); err != nil {
+		return "", fmt.Errorf("failed to label pull request %d: %w", created.Number, err)
+	}
+	return created.HTMLURL, nil
+}
+
+// do sends a request to the GitHub API, encoding in as the body and decoding the
+// response into out when they are not nil.
+func (g *GitHub) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GitHub request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("GitHub returned status code: %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse GitHub response: %w", err)
+	}
+	return nil
+}
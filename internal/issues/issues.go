@@ -0,0 +1,247 @@
+// Package issues turns persistent findings into issues in an issue tracker.
+//
+// Every record of a monitored domain is reduced to lint findings. A finding that is
+// reported by enough consecutive checks gets an issue, which is commented on when the
+// finding's message changes and closed once a check no longer reports it. Issues are
+// keyed by the finding's fingerprint, so that a restarted monitor reuses the issues it
+// opened before instead of opening duplicates.
+package issues
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+)
+
+// Label is the label put on every issue this package opens.
+const Label = "passkey-origin-validator"
+
+// RuleCheckFailed reports a domain whose document could not be fetched or parsed.
+const RuleCheckFailed = "check-failed"
+
+// DefaultPersist is the default number of consecutive checks a finding must be reported
+// by before an issue is opened for it.
+const DefaultPersist = 2
+
+// Issue is the content of an issue about a finding.
+type Issue struct {
+	Fingerprint string
+	Title       string
+	Body        string
+}
+
+// Tracker is an issue tracker. Implementations find open issues by the fingerprint that
+// Open recorded in them.
+type Tracker interface {
+	// Find returns the ID of the open issue for fingerprint, or "" if there is none.
+	Find(ctx context.Context, fingerprint string) (string, error)
+	// Open opens an issue and returns its ID.
+	Open(ctx context.Context, issue Issue) (string, error)
+	// Comment adds a comment to an issue.
+	Comment(ctx context.Context, id, body string) error
+	// Close closes an issue with a comment.
+	Close(ctx context.Context, id, comment string) error
+}
+
+// Credentials are the credentials used to authenticate with a tracker.
+type Credentials struct {
+	// GitHubToken is a token allowed to read and write the repository's issues.
+	GitHubToken string
+	// JiraEmail and JiraToken are the user and API token used with Jira.
+	JiraEmail string
+	JiraToken string
+}
+
+// NewTracker returns the tracker described by spec: "github:owner/repo" for a GitHub
+// repository, or "jira:https://example.atlassian.net/KEY" for the Jira project KEY.
+func NewTracker(client *http.Client, spec string, creds Credentials) (Tracker, error) {
+	kind, target, ok := strings.Cut(spec, ":")
+	if !ok || target == "" {
+		return nil, fmt.Errorf("invalid issue tracker %q: expected github:owner/repo or jira:URL/PROJECT", spec)
+	}
+	switch kind {
+	case "github":
+		if creds.GitHubToken == "" {
+			return nil, fmt.Errorf("a GitHub token is required to open issues in %s", target)
+		}
+		return NewGitHub(client, GitHubAPI, target, creds.GitHubToken)
+	case "jira":
+		if creds.JiraEmail == "" || creds.JiraToken == "" {
+			return nil, fmt.Errorf("a Jira email and API token are required to open issues in %s", target)
+		}
+		i := strings.LastIndex(target, "/")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid Jira project %q: expected URL/PROJECT", target)
+		}
+		return NewJira(client, target[:i], target[i+1:], creds.JiraEmail, creds.JiraToken)
+	default:
+		return nil, fmt.Errorf("unknown issue tracker %q: expected github or jira", kind)
+	}
+}
+
+// Findings returns the findings of a record: a RuleCheckFailed finding if the check
+// failed, or the lint findings of the document it saw, including whether it authorizes
+// the record's caller origin. Skipped records have no findings.
+func Findings(record batch.Record) []lint.Finding {
+	if record.Skipped {
+		return nil
+	}
+	if record.Failed() {
+		return []lint.Finding{{
+			Rule:        RuleCheckFailed,
+			Severity:    lint.SeverityError,
+			Index:       -1,
+			Message:     record.Error,
+			Fingerprint: lint.Fingerprint(RuleCheckFailed, record.Domain, ""),
+		}}
+	}
+
+	// The record keeps the document's origins, which is all that lint looks at
+	origins := record.Origins
+	if origins == nil {
+		origins = []string{}
+	}
+	document, err := json.Marshal(counter.WebAuthnResponse{Origins: origins})
+	if err != nil {
+		return nil
+	}
+	var callerOrigins []string
+	if record.Origin != "" {
+		callerOrigins = []string{record.Origin}
+	}
+	return lint.Check(document, lint.Options{CallerOrigins: callerOrigins, Source: record.URL, MaxLabels: record.MaxLabels})
+}
+
+// tracked is a finding that was reported by the most recent check of its domain.
+type tracked struct {
+	finding lint.Finding
+	// seen is the number of consecutive checks that reported the finding.
+	seen int
+	// id is the ID of the finding's issue, once it has one.
+	id string
+}
+
+// Syncer keeps the issues of a tracker in step with the findings of each check.
+// A Syncer is not safe for concurrent use.
+type Syncer struct {
+	tracker Tracker
+	persist int
+	// domains maps each domain to its findings, by fingerprint.
+	domains map[string]map[string]*tracked
+}
+
+// NewSyncer returns a Syncer that opens an issue once a finding has been reported by
+// persist consecutive checks.
+func NewSyncer(tracker Tracker, persist int) *Syncer {
+	if persist < 1 {
+		persist = 1
+	}
+	return &Syncer{
+		tracker: tracker,
+		persist: persist,
+		domains: make(map[string]map[string]*tracked),
+	}
+}
+
+// Observe updates the issues for the domain of record. A failed check says nothing about
+// the document, so it does not close issues about the document's findings.
+func (s *Syncer) Observe(ctx context.Context, record batch.Record) error {
+	if record.Skipped {
+		return nil
+	}
+
+	previous := s.domains[record.Domain]
+	current := make(map[string]*tracked)
+	var errs []error
+
+	// Open or update the issues of the findings that are still reported
+	for _, finding := range Findings(record) {
+		t, ok := previous[finding.Fingerprint]
+		if !ok {
+			t = &tracked{}
+		}
+		changed := ok && t.finding.Message != finding.Message
+		t.finding = finding
+		t.seen++
+		current[finding.Fingerprint] = t
+
+		if t.seen < s.persist {
+			continue
+		}
+		if err := s.sync(ctx, record.Domain, t, changed); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Close the issues of the findings that are gone
+	for fingerprint, t := range previous {
+		if _, ok := current[fingerprint]; ok {
+			continue
+		}
+		if record.Failed() && t.finding.Rule != RuleCheckFailed {
+			current[fingerprint] = t
+			continue
+		}
+		if t.id == "" {
+			continue
+		}
+		comment := fmt.Sprintf("Resolved: %s no longer reports this finding.", record.Domain)
+		if err := s.tracker.Close(ctx, t.id, comment); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close issue %s: %w", t.id, err))
+			current[fingerprint] = t
+		}
+	}
+
+	s.domains[record.Domain] = current
+	return errors.Join(errs...)
+}
+
+// sync makes sure that a persistent finding has an issue, and comments on the issue when
+// the finding's message changed.
+func (s *Syncer) sync(ctx context.Context, domain string, t *tracked, changed bool) error {
+	if t.id == "" {
+		// Reuse an issue opened by an earlier run
+		id, err := s.tracker.Find(ctx, t.finding.Fingerprint)
+		if err != nil {
+			return fmt.Errorf("failed to find issue for %s: %w", t.finding.Fingerprint, err)
+		}
+		if id == "" {
+			id, err = s.tracker.Open(ctx, newIssue(domain, t.finding))
+			if err != nil {
+				return fmt.Errorf("failed to open issue for %s: %w", t.finding.Fingerprint, err)
+			}
+			t.id = id
+			return nil
+		}
+		t.id = id
+		changed = true
+	}
+
+	if changed {
+		if err := s.tracker.Comment(ctx, t.id, "Still reported: "+t.finding.Message); err != nil {
+			return fmt.Errorf("failed to update issue %s: %w", t.id, err)
+		}
+	}
+	return nil
+}
+
+// newIssue returns the issue for a finding about domain.
+func newIssue(domain string, finding lint.Finding) Issue {
+	title := fmt.Sprintf("%s: %s", domain, finding.Rule)
+	if finding.Origin != "" {
+		title += " " + finding.Origin
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("The .well-known/webauthn document of %s has a persistent %s finding:\n\n", domain, finding.Severity))
+	sb.WriteString(lint.FormatFindings([]lint.Finding{finding}))
+	sb.WriteString(fmt.Sprintf("\nThis issue is closed automatically once the finding is no longer reported.\n\nfingerprint: %s\n", finding.Fingerprint))
+	return Issue{Fingerprint: finding.Fingerprint, Title: title, Body: sb.String()}
+}
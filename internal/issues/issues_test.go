@@ -0,0 +1,189 @@
+package issues
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+)
+
+// fakeTracker records the calls made to it.
+type fakeTracker struct {
+	open  map[string]string
+	calls []string
+	next  int
+}
+
+func (f *fakeTracker) Find(ctx context.Context, fingerprint string) (string, error) {
+	return f.open[fingerprint], nil
+}
+
+func (f *fakeTracker) Open(ctx context.Context, issue Issue) (string, error) {
+	f.next++
+	id := fmt.Sprint(f.next)
+	f.open[issue.Fingerprint] = id
+	f.calls = append(f.calls, "open "+id+" "+issue.Title)
+	return id, nil
+}
+
+func (f *fakeTracker) Comment(ctx context.Context, id, body string) error {
+	f.calls = append(f.calls, "comment "+id)
+	return nil
+}
+
+func (f *fakeTracker) Close(ctx context.Context, id, comment string) error {
+	for fingerprint, open := range f.open {
+		if open == id {
+			delete(f.open, fingerprint)
+		}
+	}
+	f.calls = append(f.calls, "close "+id)
+	return nil
+}
+
+// TestFindings tests reducing records to findings.
+func TestFindings(t *testing.T) {
+	findings := Findings(batch.Record{
+		Domain:  "example.com",
+		URL:     "https://example.com/.well-known/webauthn",
+		Origins: []string{"https://a.com", "http://b.com"},
+		Origin:  "https://c.com",
+	})
+	var rules []string
+	for _, finding := range findings {
+		rules = append(rules, finding.Rule)
+	}
+	if strings.Join(rules, ",") != "insecure-scheme,not-authorized" {
+		t.Errorf("Unexpected findings %v", rules)
+	}
+
+	failed := Findings(batch.Record{Domain: "example.com", Error: "HTTP request failed with status code: 404"})
+	if len(failed) != 1 || failed[0].Rule != RuleCheckFailed || failed[0].Fingerprint == "" {
+		t.Errorf("Expected a check-failed finding, got %+v", failed)
+	}
+
+	if skipped := Findings(batch.Record{Domain: "example.com", Error: "budget exhausted", Skipped: true}); skipped != nil {
+		t.Errorf("Expected no findings for a skipped record, got %+v", skipped)
+	}
+}
+
+// TestSyncer tests opening, updating and closing issues as findings come and go.
+func TestSyncer(t *testing.T) {
+	tracker := &fakeTracker{open: make(map[string]string)}
+	syncer := NewSyncer(tracker, 2)
+	ctx := context.Background()
+
+	insecure := batch.Record{Domain: "example.com", URL: "https://example.com/.well-known/webauthn", Origins: []string{"http://a.com"}}
+	failed := batch.Record{Domain: "example.com", Error: "connection refused"}
+	fixed := batch.Record{Domain: "example.com", URL: "https://example.com/.well-known/webauthn", Origins: []string{"https://a.com"}}
+
+	steps := []struct {
+		name     string
+		record   batch.Record
+		expected []string
+	}{
+		{"First sighting", insecure, nil},
+		{"Persistent", insecure, []string{"open 1 example.com: insecure-scheme http://a.com"}},
+		{"Unchanged", insecure, nil},
+		{"Failure keeps document issues open", failed, nil},
+		{"Persistent failure", failed, []string{"open 2 example.com: check-failed"}},
+		{"Fixed", fixed, []string{"close 1", "close 2"}},
+		{"Skipped", batch.Record{Domain: "example.com", Skipped: true}, nil},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			tracker.calls = nil
+			if err := syncer.Observe(ctx, step.record); err != nil {
+				t.Fatalf("Observe returned an error: %v", err)
+			}
+			// Issues are closed in no particular order
+			sort.Strings(tracker.calls)
+			if strings.Join(tracker.calls, "\n") != strings.Join(step.expected, "\n") {
+				t.Errorf("Expected calls %v, got %v", step.expected, tracker.calls)
+			}
+		})
+	}
+
+	t.Run("Restart reuses open issues", func(t *testing.T) {
+		tracker.open[lint.Fingerprint(RuleCheckFailed, "example.com", "")] = "7"
+		tracker.calls = nil
+		restarted := NewSyncer(tracker, 1)
+		if err := restarted.Observe(ctx, failed); err != nil {
+			t.Fatalf("Observe returned an error: %v", err)
+		}
+		if strings.Join(tracker.calls, ",") != "comment 7" {
+			t.Errorf("Expected the existing issue to be updated, got %v", tracker.calls)
+		}
+	})
+}
+
+// TestGitHub tests the GitHub tracker against a fake API.
+func TestGitHub(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"number": 3, "body": "fingerprint: aaaa", "pull_request": map[string]any{}},
+				{"number": 4, "body": "fingerprint: aaaa"},
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/owner/repo/issues":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"number": 5}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/owner/repo/pulls":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"number": 6, "html_url": "https://github.com/owner/repo/pull/6"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	tracker, err := NewGitHub(server.Client(), server.URL, "owner/repo", "token")
+	if err != nil {
+		t.Fatalf("NewGitHub returned an error: %v", err)
+	}
+	ctx := context.Background()
+
+	if id, err := tracker.Find(ctx, "aaaa"); err != nil || id != "4" {
+		t.Errorf("Find = %q, %v; want 4", id, err)
+	}
+	if id, err := tracker.Find(ctx, "bbbb"); err != nil || id != "" {
+		t.Errorf("Find = %q, %v; want no issue", id, err)
+	}
+	if id, err := tracker.Open(ctx, Issue{Fingerprint: "bbbb", Title: "title", Body: "fingerprint: bbbb"}); err != nil || id != "5" {
+		t.Errorf("Open = %q, %v; want 5", id, err)
+	}
+	requests = nil
+	if err := tracker.Close(ctx, "5", "resolved"); err != nil {
+		t.Errorf("Close returned an error: %v", err)
+	}
+	if strings.Join(requests, ",") != "POST /repos/owner/repo/issues/5/comments,PATCH /repos/owner/repo/issues/5" {
+		t.Errorf("Unexpected requests %v", requests)
+	}
+
+	requests = nil
+	url, err := tracker.OpenPullRequest(ctx, PullRequest{Title: "title", Body: "body", Head: "fix", Base: "main"})
+	if err != nil || url != "https://github.com/owner/repo/pull/6" {
+		t.Errorf("OpenPullRequest = %q, %v; want the pull request URL", url, err)
+	}
+	if strings.Join(requests, ",") != "POST /repos/owner/repo/pulls,POST /repos/owner/repo/issues/6/labels" {
+		t.Errorf("Unexpected requests %v", requests)
+	}
+
+	if _, err := NewGitHub(server.Client(), server.URL, "owner", "token"); err == nil {
+		t.Error("Expected an error for a repository without an owner")
+	}
+}
@@ -0,0 +1,156 @@
+package issues
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// JiraIssueType is the type of the issues opened in Jira.
+const JiraIssueType = "Task"
+
+// Jira is a Tracker that keeps issues in a Jira project. Issues are labeled with Label,
+// and their fingerprint is recorded as a second label so that it can be searched for.
+type Jira struct {
+	client  *http.Client
+	baseURL string
+	project string
+	email   string
+	token   string
+}
+
+// NewJira returns a Tracker for the project with key project on the Jira site at baseURL.
+func NewJira(client *http.Client, baseURL, project, email, token string) (*Jira, error) {
+	if _, err := url.ParseRequestURI(baseURL); err != nil || project == "" {
+		return nil, fmt.Errorf("invalid Jira project %s/%s: expected URL/PROJECT", baseURL, project)
+	}
+	return &Jira{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		project: project,
+		email:   email,
+		token:   token,
+	}, nil
+}
+
+// fingerprintLabel returns the label that records a fingerprint.
+func fingerprintLabel(fingerprint string) string {
+	return Label + "-" + fingerprint
+}
+
+// Find returns the key of the unresolved issue labeled with fingerprint.
+func (j *Jira) Find(ctx context.Context, fingerprint string) (string, error) {
+	jql := fmt.Sprintf(`project = "%s" AND labels = "%s" AND statusCategory != Done`, j.project, fingerprintLabel(fingerprint))
+	query := url.Values{"jql": {jql}, "fields": {"key"}, "maxResults": {"1"}}.Encode()
+
+	var found struct {
+		Issues []struct {
+			Key string `json:"key"`
+		} `json:"issues"`
+	}
+	if err := j.do(ctx, http.MethodGet, "/rest/api/2/search?"+query, nil, &found); err != nil {
+		return "", err
+	}
+	if len(found.Issues) == 0 {
+		return "", nil
+	}
+	return found.Issues[0].Key, nil
+}
+
+// Open opens an issue and returns its key.
+func (j *Jira) Open(ctx context.Context, issue Issue) (string, error) {
+	request := map[string]any{
+		"fields": map[string]any{
+			"project":     map[string]string{"key": j.project},
+			"issuetype":   map[string]string{"name": JiraIssueType},
+			"summary":     issue.Title,
+			"description": issue.Body,
+			"labels":      []string{Label, fingerprintLabel(issue.Fingerprint)},
+		},
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := j.do(ctx, http.MethodPost, "/rest/api/2/issue", request, &created); err != nil {
+		return "", err
+	}
+	return created.Key, nil
+}
+
+// Comment adds a comment to the issue with key id.
+func (j *Jira) Comment(ctx context.Context, id, body string) error {
+	return j.do(ctx, http.MethodPost, "/rest/api/2/issue/"+id+"/comment", map[string]string{"body": body}, nil)
+}
+
+// Close comments on the issue with key id and moves it to the first status in the Done
+// category that its workflow allows.
+func (j *Jira) Close(ctx context.Context, id, comment string) error {
+	if err := j.Comment(ctx, id, comment); err != nil {
+		return err
+	}
+
+	var transitions struct {
+		Transitions []struct {
+			ID string `json:"id"`
+			To struct {
+				StatusCategory struct {
+					Key string `json:"key"`
+				} `json:"statusCategory"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	if err := j.do(ctx, http.MethodGet, "/rest/api/2/issue/"+id+"/transitions", nil, &transitions); err != nil {
+		return err
+	}
+	for _, transition := range transitions.Transitions {
+		if transition.To.StatusCategory.Key == "done" {
+			request := map[string]any{"transition": map[string]string{"id": transition.ID}}
+			return j.do(ctx, http.MethodPost, "/rest/api/2/issue/"+id+"/transitions", request, nil)
+		}
+	}
+	return fmt.Errorf("issue %s has no transition to a done status", id)
+}
+
+// do sends a request to the Jira API, encoding in as the body and decoding the response
+// into out when they are not nil.
+func (j *Jira) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, j.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(j.email, j.token)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Jira request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Jira returned status code: %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse Jira response: %w", err)
+	}
+	return nil
+}
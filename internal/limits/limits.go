@@ -0,0 +1,115 @@
+// Package limits enforces per-run resource limits on outgoing HTTP requests.
+//
+// Batch and research modes can be pointed at very large or malformed domain lists.
+// A Budget caps the total number of requests, the total number of bytes downloaded
+// and the total runtime, so a single run cannot turn into an accidental network hog.
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBudgetExhausted is returned (wrapped) when a request would exceed the run budget.
+var ErrBudgetExhausted = errors.New("run budget exhausted")
+
+// Limits are the caps applied to a run. A zero value for any field means no limit.
+type Limits struct {
+	// MaxRequests is the maximum number of HTTP requests made during the run.
+	MaxRequests int64
+	// MaxBytes is the maximum number of response body bytes downloaded during the run.
+	MaxBytes int64
+	// MaxRuntime is the maximum wall-clock duration of the run.
+	MaxRuntime time.Duration
+}
+
+// Budget tracks resource usage against Limits. It is safe for concurrent use.
+type Budget struct {
+	limits   Limits
+	start    time.Time
+	requests atomic.Int64
+	bytes    atomic.Int64
+}
+
+// NewBudget returns a Budget for the given limits, starting the runtime clock now.
+func NewBudget(limits Limits) *Budget {
+	return &Budget{
+		limits: limits,
+		start:  time.Now(),
+	}
+}
+
+// Exhausted returns an error wrapping ErrBudgetExhausted if any limit has been reached, or nil.
+func (b *Budget) Exhausted() error {
+	if b.limits.MaxRequests > 0 && b.requests.Load() >= b.limits.MaxRequests {
+		return fmt.Errorf("%w: reached the maximum of %d requests", ErrBudgetExhausted, b.limits.MaxRequests)
+	}
+	if b.limits.MaxBytes > 0 && b.bytes.Load() >= b.limits.MaxBytes {
+		return fmt.Errorf("%w: reached the maximum of %d bytes downloaded", ErrBudgetExhausted, b.limits.MaxBytes)
+	}
+	if b.limits.MaxRuntime > 0 && time.Since(b.start) >= b.limits.MaxRuntime {
+		return fmt.Errorf("%w: reached the maximum runtime of %s", ErrBudgetExhausted, b.limits.MaxRuntime)
+	}
+	return nil
+}
+
+// Usage returns a human-readable summary of the resources used so far.
+func (b *Budget) Usage() string {
+	return fmt.Sprintf("%d requests, %d bytes downloaded, %s elapsed",
+		b.requests.Load(), b.bytes.Load(), time.Since(b.start).Round(time.Millisecond))
+}
+
+// Transport wraps base so that every request is charged against the budget.
+// If base is nil, http.DefaultTransport is used.
+func (b *Budget) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{budget: b, base: base}
+}
+
+// transport is an http.RoundTripper that enforces a Budget.
+type transport struct {
+	budget *Budget
+	base   http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.budget.Exhausted(); err != nil {
+		return nil, err
+	}
+	t.budget.requests.Add(1)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &countingBody{budget: t.budget, body: resp.Body}
+	return resp, nil
+}
+
+// countingBody charges bytes read from a response body against a Budget.
+type countingBody struct {
+	budget *Budget
+	body   io.ReadCloser
+}
+
+// Read implements io.Reader, failing once the byte limit is exceeded.
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.body.Read(p)
+	total := c.budget.bytes.Add(int64(n))
+	if max := c.budget.limits.MaxBytes; max > 0 && total > max {
+		return n, fmt.Errorf("%w: exceeded the maximum of %d bytes downloaded", ErrBudgetExhausted, max)
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (c *countingBody) Close() error {
+	return c.body.Close()
+}
@@ -0,0 +1,76 @@
+package limits
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBudget tests that the budget transport enforces each limit.
+func TestBudget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer server.Close()
+
+	get := func(client *http.Client) error {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		return err
+	}
+
+	t.Run("Max requests", func(t *testing.T) {
+		budget := NewBudget(Limits{MaxRequests: 2})
+		client := &http.Client{Transport: budget.Transport(nil)}
+
+		for i := 0; i < 2; i++ {
+			if err := get(client); err != nil {
+				t.Fatalf("Request %d returned an error: %v", i, err)
+			}
+		}
+		if err := get(client); !errors.Is(err, ErrBudgetExhausted) {
+			t.Errorf("Expected ErrBudgetExhausted, got %v", err)
+		}
+	})
+
+	t.Run("Max bytes", func(t *testing.T) {
+		budget := NewBudget(Limits{MaxBytes: 150})
+		client := &http.Client{Transport: budget.Transport(nil)}
+
+		if err := get(client); err != nil {
+			t.Fatalf("First request returned an error: %v", err)
+		}
+		if err := get(client); !errors.Is(err, ErrBudgetExhausted) {
+			t.Errorf("Expected ErrBudgetExhausted while reading, got %v", err)
+		}
+		if err := budget.Exhausted(); err == nil {
+			t.Errorf("Expected the budget to be exhausted")
+		}
+	})
+
+	t.Run("Max runtime", func(t *testing.T) {
+		budget := NewBudget(Limits{MaxRuntime: time.Millisecond})
+		time.Sleep(5 * time.Millisecond)
+		if err := budget.Exhausted(); !errors.Is(err, ErrBudgetExhausted) {
+			t.Errorf("Expected ErrBudgetExhausted, got %v", err)
+		}
+	})
+
+	t.Run("No limits", func(t *testing.T) {
+		budget := NewBudget(Limits{})
+		client := &http.Client{Transport: budget.Transport(nil)}
+		for i := 0; i < 5; i++ {
+			if err := get(client); err != nil {
+				t.Fatalf("Request %d returned an error: %v", i, err)
+			}
+		}
+	})
+}
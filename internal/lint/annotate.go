@@ -0,0 +1,100 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Annotate reprints a document with each finding as a comment at the end of the line
+// of the origin it is about, so that a large file can be reviewed without counting
+// array indexes. Findings about the whole document or about caller origins are printed
+// as comments before it. A document that does not have one origin per line is
+// pretty-printed first so that every origin has a line of its own.
+func Annotate(jsonData []byte, findings []Finding) string {
+	lines, ok := originLines(jsonData)
+	if !ok {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, jsonData, "", "  "); err == nil {
+			jsonData = indented.Bytes()
+			lines, _ = originLines(jsonData)
+		}
+	}
+
+	// Collect the annotations of each line
+	var header []string
+	annotations := make(map[int][]string)
+	for _, finding := range findings {
+		text := fmt.Sprintf("%s[%s]: %s (%s)", finding.Severity, finding.Rule, finding.Message, finding.Fingerprint)
+		line, ok := lines[finding.Index]
+		if finding.Index < 0 || !ok {
+			if finding.Origin != "" {
+				text = fmt.Sprintf("%s[%s] %s: %s (%s)", finding.Severity, finding.Rule, finding.Origin, finding.Message, finding.Fingerprint)
+			}
+			header = append(header, text)
+			continue
+		}
+		annotations[line] = append(annotations[line], text)
+	}
+
+	var sb strings.Builder
+	for _, text := range header {
+		sb.WriteString(fmt.Sprintf("// %s\n", text))
+	}
+	for i, line := range strings.Split(strings.TrimRight(string(jsonData), "\n"), "\n") {
+		sb.WriteString(strings.TrimRight(line, " \t\r"))
+		if texts := annotations[i]; len(texts) > 0 {
+			sb.WriteString("  // " + strings.Join(texts, "; "))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// originLines maps the index of each entry of the origins array to the 0-based line it
+// ends on. ok is false when the document cannot be tokenized or two entries share a line.
+func originLines(jsonData []byte) (lines map[int]int, ok bool) {
+	lines = make(map[int]int)
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return lines, false
+	}
+
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return lines, false
+		}
+		if key != "origins" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return lines, false
+			}
+			continue
+		}
+
+		// Like encoding/json, use the last origins key if there are several
+		lines = make(map[int]int)
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+			return lines, false
+		}
+		used := make(map[int]bool)
+		for i := 0; dec.More(); i++ {
+			var entry json.RawMessage
+			if err := dec.Decode(&entry); err != nil {
+				return lines, false
+			}
+			line := bytes.Count(jsonData[:dec.InputOffset()], []byte("\n"))
+			if used[line] {
+				return lines, false
+			}
+			used[line] = true
+			lines[i] = line
+		}
+		if _, err := dec.Token(); err != nil {
+			return lines, false
+		}
+	}
+	return lines, true
+}
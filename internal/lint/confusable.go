@@ -0,0 +1,126 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+)
+
+// lookalikes maps letters of other scripts, and uncommon Latin letters, to the ASCII
+// letters they are commonly mistaken for in host names.
+var lookalikes = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'с': 'c', 'ԁ': 'd', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j', 'ӏ': 'l',
+	'о': 'o', 'р': 'p', 'ԛ': 'q', 'ѕ': 's', 'ԝ': 'w', 'х': 'x', 'у': 'y', 'ү': 'y',
+	// Greek
+	'α': 'a', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'υ': 'u',
+	// Armenian
+	'ո': 'n', 'օ': 'o', 'ս': 'u',
+	// Latin
+	'ı': 'i', 'ɩ': 'i', 'ɑ': 'a', 'ɡ': 'g',
+}
+
+// allowedScripts are the combinations of scripts that may be mixed in one label, as in
+// Japanese, Chinese and Korean domain names, following the IDN policy of browsers.
+var allowedScripts = [][]string{
+	{"Han", "Hiragana", "Katakana", "Latin"},
+	{"Han", "Bopomofo", "Latin"},
+	{"Han", "Hangul", "Latin"},
+}
+
+// confusable reports whether host, in ASCII or Unicode form, contains a label that
+// mixes scripts or that looks like an ASCII label, and explains why. Such hosts may be
+// look-alikes of another domain that slipped into the document.
+func confusable(host string) (string, bool) {
+	unicodeHost, err := idna.ToUnicode(strings.ToLower(host))
+	if err != nil {
+		unicodeHost = strings.ToLower(host)
+	}
+	if isASCII(unicodeHost) {
+		return "", false
+	}
+
+	var reasons []string
+	labels := strings.Split(unicodeHost, ".")
+	skeleton := make([]string, len(labels))
+	for i, label := range labels {
+		if scripts := labelScripts(label); !allowedMix(scripts) {
+			reasons = append(reasons, fmt.Sprintf("label %q mixes the %s scripts", label, strings.Join(scripts, " and ")))
+		}
+		skeleton[i] = strings.Map(func(r rune) rune {
+			if ascii, ok := lookalikes[r]; ok {
+				return ascii
+			}
+			return r
+		}, label)
+	}
+	if looksLike := strings.Join(skeleton, "."); isASCII(looksLike) {
+		reasons = append(reasons, fmt.Sprintf("it looks like %s", looksLike))
+	}
+	if len(reasons) == 0 {
+		return "", false
+	}
+
+	shown := unicodeHost
+	if unicodeHost != strings.ToLower(host) {
+		shown = fmt.Sprintf("%s (%s)", unicodeHost, host)
+	}
+	return fmt.Sprintf("host %s may be a look-alike of another domain: %s", shown, strings.Join(reasons, "; ")), true
+}
+
+// labelScripts returns the scripts of the letters of label, sorted, without the Common
+// and Inherited scripts that digits, hyphens and combining marks belong to.
+func labelScripts(label string) []string {
+	seen := make(map[string]bool)
+	for _, r := range label {
+		if r <= unicode.MaxASCII && !unicode.IsLetter(r) {
+			continue
+		}
+		for name, table := range unicode.Scripts {
+			if name != "Common" && name != "Inherited" && unicode.Is(table, r) {
+				seen[name] = true
+				break
+			}
+		}
+	}
+	scripts := make([]string, 0, len(seen))
+	for name := range seen {
+		scripts = append(scripts, name)
+	}
+	sort.Strings(scripts)
+	return scripts
+}
+
+// allowedMix reports whether scripts may appear together in one label.
+func allowedMix(scripts []string) bool {
+	if len(scripts) <= 1 {
+		return true
+	}
+	for _, allowed := range allowedScripts {
+		subset := true
+		for _, script := range scripts {
+			found := false
+			for _, name := range allowed {
+				found = found || name == script
+			}
+			subset = subset && found
+		}
+		if subset {
+			return true
+		}
+	}
+	return false
+}
+
+// isASCII reports whether s contains only ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
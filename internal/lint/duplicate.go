@@ -0,0 +1,63 @@
+package lint
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// diffTrailingDot is the difference of entries whose hosts differ by a trailing dot.
+const diffTrailingDot = "a trailing dot on the host"
+
+// duplicateKey returns the form in which entries that are the same origin, or differ
+// only by a trailing dot on the host, are equal: serialized as browsers compare origins,
+// and without the trailing dot. An entry that cannot be serialized is its own key.
+func duplicateKey(origin string) string {
+	serialized, err := counter.SerializeOrigin(origin)
+	if err != nil {
+		return origin
+	}
+	u, err := url.Parse(serialized)
+	if err != nil || !strings.HasSuffix(u.Hostname(), ".") {
+		return serialized
+	}
+	host := strings.TrimSuffix(u.Hostname(), ".")
+	if port := u.Port(); port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return u.Scheme + "://" + host
+}
+
+// differences returns how two entries with the same duplicateKey are written
+// differently, such as "the case of the host" or "a default port".
+func differences(a, b string) []string {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return nil
+	}
+	var diffs []string
+	schemeA, _, _ := strings.Cut(a, ":")
+	schemeB, _, _ := strings.Cut(b, ":")
+	if schemeA != schemeB {
+		diffs = append(diffs, "the case of the scheme")
+	}
+	hostA, hostB := ua.Hostname(), ub.Hostname()
+	if strings.HasSuffix(hostA, ".") != strings.HasSuffix(hostB, ".") {
+		diffs = append(diffs, diffTrailingDot)
+	}
+	if strings.TrimSuffix(hostA, ".") != strings.TrimSuffix(hostB, ".") {
+		diffs = append(diffs, "the case of the host")
+	}
+	if ua.Port() != ub.Port() {
+		diffs = append(diffs, "a default port")
+	}
+	if ua.Path+ua.RawQuery+ua.Fragment != ub.Path+ub.RawQuery+ub.Fragment {
+		diffs = append(diffs, "a path, query or fragment")
+	}
+	return diffs
+}
@@ -0,0 +1,654 @@
+// Package lint checks .well-known/webauthn documents for problems that make browsers
+// ignore origins or reject the document, and for entries that are likely mistakes.
+package lint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/generate"
+	"github.com/developmeh/passkey-origin-validator/internal/rpid"
+	"github.com/developmeh/passkey-origin-validator/internal/schema"
+)
+
+// Severity is how serious a finding is. Severities are ordered, so that findings can be
+// compared with a threshold.
+type Severity int
+
+const (
+	// SeverityInfo indicates a finding that needs no action. No rule reports it by
+	// default; it is chosen for a rule with an override.
+	SeverityInfo Severity = iota
+	// SeverityWarning indicates an entry that works but is likely a mistake.
+	SeverityWarning
+	// SeverityError indicates an entry or document that browsers ignore or reject.
+	SeverityError
+)
+
+// String returns a string representation of the Severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("UNKNOWN_SEVERITY(%d)", s)
+	}
+}
+
+// MarshalText encodes the Severity as its string representation.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Rule identifiers reported in findings.
+const (
+	// RuleInvalidJSON reports a document that is not valid JSON.
+	RuleInvalidJSON = "invalid-json"
+	// RuleSchema reports a value that does not match the schema of the document, at its
+	// JSON Pointer: an error for a value browsers reject the document for, such as a
+	// missing origins array or an entry that is not a string, and a warning for an
+	// unknown member, which browsers ignore.
+	RuleSchema = "schema"
+	// RuleInvalidOrigin reports an origin that browsers cannot parse or take a label from.
+	RuleInvalidOrigin = "invalid-origin"
+	// RuleInsecureScheme reports an origin that is not https.
+	RuleInsecureScheme = "insecure-scheme"
+	// RuleOriginPath reports an origin with a path, query or fragment, which browsers discard.
+	RuleOriginPath = "origin-path"
+	// RuleDuplicateOrigin reports an origin listed more than once, written the same way.
+	RuleDuplicateOrigin = "duplicate-origin"
+	// RuleNearDuplicateOrigin reports an origin that an earlier entry already lists in
+	// another form: the same once the scheme and host are lowercased, a default port is
+	// dropped or a trailing dot is removed from the host.
+	RuleNearDuplicateOrigin = "near-duplicate-origin"
+	// RuleLabelLimit reports an origin ignored because MaxLabels labels were already seen.
+	RuleLabelLimit = "label-limit"
+	// RuleNotAuthorized reports a caller origin that the document does not authorize.
+	RuleNotAuthorized = "not-authorized"
+	// RuleInsecureCaller reports a caller origin that is not a secure context, for which
+	// browsers refuse WebAuthn requests before consulting the document.
+	RuleInsecureCaller = "insecure-caller"
+	// RuleLoopbackCaller reports a caller origin on a loopback host, such as
+	// http://localhost:8080, which browsers never authorize as a related origin.
+	RuleLoopbackCaller = "loopback-caller"
+	// RuleServing reports a document served in a way that some browsers reject or
+	// truncate, such as with the wrong content type.
+	RuleServing = "serving"
+	// RuleNonCanonicalOrigin reports an origin that browsers only match after
+	// normalizing it, such as one with an uppercase host or a default port. It is only
+	// reported in strict mode.
+	RuleNonCanonicalOrigin = "non-canonical-origin"
+	// RuleConfusableOrigin reports an origin whose host mixes scripts or looks like an
+	// ASCII host, which may be a look-alike of another domain.
+	RuleConfusableOrigin = "confusable-origin"
+	// RuleRedundantOrigin reports an origin that can use the RP ID without related
+	// origins, because it is a registrable domain suffix of its host. The RP's own
+	// origin is exempt, since RuleSelfOriginMissing expects it to be listed.
+	RuleRedundantOrigin = "redundant-origin"
+	// RuleUnnecessaryDocument reports a document whose origins can all use the RP ID
+	// without related origins, because it is a registrable domain suffix of each.
+	RuleUnnecessaryDocument = "unnecessary-document"
+	// RulePolicy reports a violation of a Rego policy run with the document, such as
+	// one of a --policy-bundle.
+	RulePolicy = "policy"
+	// RuleAppRPMismatch reports an origin whose host shares credentials with apps, in its
+	// apple-app-site-association or assetlinks.json file, that the RP ID does not.
+	RuleAppRPMismatch = "app-rp-mismatch"
+	// RuleAppOriginMissing reports a domain that shares apps or credentials with the RP ID
+	// but whose origin the document does not list.
+	RuleAppOriginMissing = "app-origin-missing"
+	// RuleAppSiteAssociation reports an apple-app-site-association file that cannot be
+	// fetched or parsed, or lists an invalid app ID.
+	RuleAppSiteAssociation = "app-site-association"
+	// RuleAssetLinks reports an assetlinks.json file that cannot be fetched or parsed, or
+	// lists an invalid package, certificate fingerprint or web target.
+	RuleAssetLinks = "asset-links"
+	// RuleAndroidOriginUnlinked reports an Android app origin whose certificate the RP
+	// ID's assetlinks.json does not share credentials with.
+	RuleAndroidOriginUnlinked = "android-origin-unlinked"
+	// RuleSelfOriginMissing reports a document that does not list the RP's own origin,
+	// https:// followed by the RP ID, which only works without the document by virtue
+	// of matching the RP ID.
+	RuleSelfOriginMissing = "self-origin-missing"
+	// RuleThirdPartyOnly reports a document none of whose origins is the RP ID or a
+	// subdomain of it, so that it only authorizes third-party domains.
+	RuleThirdPartyOnly = "third-party-only"
+	// RuleGroupMissing reports a member of a group of domains meant to share passkeys
+	// whose origin cannot use the RP ID it is meant to use.
+	RuleGroupMissing = "group-missing"
+	// RuleGroupOneWay reports a pair of members of a group of domains meant to share
+	// passkeys where only one can use the other's RP ID.
+	RuleGroupOneWay = "group-one-way"
+)
+
+// Finding is a single problem found in a document.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	// Index is the position of the origin in the origins array, or -1 for findings
+	// about the whole document or about a caller origin.
+	Index int `json:"index"`
+	// Origin is the origin the finding is about, if any.
+	Origin string `json:"origin,omitempty"`
+	// Pointer is the JSON Pointer of the value a RuleSchema finding is about, such as
+	// "/origins/2".
+	Pointer string `json:"pointer,omitempty"`
+	Message string `json:"message"`
+	// Fingerprint identifies the finding across runs. It is derived from the rule, the
+	// document's source and the normalized origin, and not from the origin's position,
+	// so it does not change when other entries are added or removed.
+	Fingerprint string `json:"fingerprint"`
+	// Remediation is the change that fixes the finding, if there is one.
+	Remediation *Remediation `json:"remediation,omitempty"`
+}
+
+// Report is the findings of a document, as printed by --output json.
+type Report struct {
+	// Source is where the document came from: its URL, or the path of a local file.
+	Source string `json:"source"`
+	// ModelVersion names the browser behavior model the findings were made with, such
+	// as "chromium-128".
+	ModelVersion string    `json:"model_version,omitempty"`
+	Findings     []Finding `json:"findings"`
+	// Counts is the number of findings of each rule, such as how many entries are
+	// duplicates of others.
+	Counts map[string]int `json:"counts,omitempty"`
+}
+
+// CountRules returns the number of findings of each rule.
+func CountRules(findings []Finding) map[string]int {
+	counts := make(map[string]int)
+	for _, finding := range findings {
+		counts[finding.Rule]++
+	}
+	return counts
+}
+
+// Options configures a check.
+type Options struct {
+	// CallerOrigins are origins that the document must authorize.
+	CallerOrigins []string
+	// Source is where the document came from, such as its URL or file path. It is part
+	// of each finding's fingerprint.
+	Source string
+	// MaxLabels is the number of unique labels counted before origins are ignored. If
+	// zero, counter.MaxLabels is used.
+	MaxLabels int
+	// RPID is the RP ID the document is served for, such as the host it was fetched
+	// from. If set, a document that none of its origins need is reported, and so is one
+	// that does not list the RP's own origin.
+	RPID string
+	// StrictOrigins reports origins, and caller origins, that are only matched after
+	// browsers normalize them.
+	StrictOrigins bool
+	// SecureOrigins are http caller origins that browsers are configured to treat as
+	// secure contexts, besides those on loopback hosts.
+	SecureOrigins []string
+}
+
+// Check checks a .well-known/webauthn document and returns its findings, in the order
+// of the origins array followed by any caller origins that are not authorized.
+func Check(jsonData []byte, opts Options) []Finding {
+	findings := check(jsonData, opts)
+	source := normalizeSource(opts.Source)
+	for i := range findings {
+		key := findings[i].Origin
+		if key == "" {
+			key = findings[i].Pointer
+		}
+		findings[i].Fingerprint = Fingerprint(findings[i].Rule, source, key)
+		findings[i].Remediation = remediate(findings[i], opts.Source)
+	}
+	return findings
+}
+
+// check returns the findings of a document without fingerprints.
+func check(jsonData []byte, opts Options) []Finding {
+	// Validate the document against its schema, which browsers reject the document for
+	// violating, except for unknown members
+	violations, err := schema.Validate(jsonData)
+	if err != nil {
+		message := err.Error()
+		if located := counter.LocateParseError(jsonData, err); located != nil {
+			message = fmt.Sprintf("failed to parse JSON: %s", located)
+		}
+		return []Finding{{
+			Rule:     RuleInvalidJSON,
+			Severity: SeverityError,
+			Index:    -1,
+			Message:  message,
+		}}
+	}
+	var findings []Finding
+	rejected := false
+	for _, violation := range violations {
+		severity := SeverityWarning
+		if !violation.Unknown() {
+			severity = SeverityError
+			rejected = true
+		}
+		findings = append(findings, Finding{
+			Rule:     RuleSchema,
+			Severity: severity,
+			Index:    -1,
+			Pointer:  violation.Pointer,
+			Message:  violation.String(),
+		})
+	}
+	if rejected {
+		return findings
+	}
+
+	// Parse the JSON
+	var webAuthnResp counter.WebAuthnResponse
+	if err := json.Unmarshal(jsonData, &webAuthnResp); err != nil {
+		return []Finding{{
+			Rule:     RuleInvalidJSON,
+			Severity: SeverityError,
+			Index:    -1,
+			Message:  fmt.Sprintf("failed to parse JSON: %s", err),
+		}}
+	}
+
+	maxLabels := opts.MaxLabels
+	if maxLabels <= 0 {
+		maxLabels = counter.MaxLabels
+	}
+
+	seen := make(map[string]int)
+	labels := make(map[string]bool)
+	redundant := redundantOrigins(webAuthnResp.Origins, opts.RPID)
+
+	for i, originStr := range webAuthnResp.Origins {
+		// Entries that differ only in case, a default port or a trailing dot are written
+		// for the same origin
+		key := duplicateKey(originStr)
+		if first, ok := seen[key]; ok && originStr == webAuthnResp.Origins[first] {
+			findings = append(findings, Finding{
+				Rule:     RuleDuplicateOrigin,
+				Severity: SeverityWarning,
+				Index:    i,
+				Origin:   originStr,
+				Message:  fmt.Sprintf("duplicate of origins[%d]", first),
+			})
+			continue
+		} else if ok {
+			diffs := differences(webAuthnResp.Origins[first], originStr)
+			message := fmt.Sprintf("same origin as origins[%d] %s once normalized; it differs in %s", first,
+				webAuthnResp.Origins[first], strings.Join(diffs, " and "))
+			if slices.Contains(diffs, diffTrailingDot) {
+				message += "; browsers keep a trailing dot, so only callers that use it match that form"
+			}
+			findings = append(findings, Finding{
+				Rule:     RuleNearDuplicateOrigin,
+				Severity: SeverityWarning,
+				Index:    i,
+				Origin:   originStr,
+				Message:  message,
+			})
+			continue
+		}
+		seen[key] = i
+
+		// Android app origins have no label, and only authorize the app they name
+		if counter.IsAndroidOrigin(originStr) {
+			if _, err := counter.ParseAndroidOrigin(originStr); err != nil {
+				findings = append(findings, Finding{
+					Rule:     RuleInvalidOrigin,
+					Severity: SeverityError,
+					Index:    i,
+					Origin:   originStr,
+					Message:  fmt.Sprintf("%v; clients ignore it", err),
+				})
+			}
+			continue
+		}
+
+		label, ok := counter.OriginLabel(originStr)
+		if !ok {
+			message := "not a valid origin with a registrable domain; browsers ignore it"
+			if originURL, err := url.Parse(originStr); err == nil && rpid.Loopback(originURL.Hostname()) {
+				message = "a loopback origin has no registrable domain, so browsers ignore it; local development needs no entry, " +
+					"since a loopback caller can only use the RP ID localhost"
+			}
+			findings = append(findings, Finding{
+				Rule:     RuleInvalidOrigin,
+				Severity: SeverityError,
+				Index:    i,
+				Origin:   originStr,
+				Message:  message,
+			})
+			continue
+		}
+
+		// Apply the label limit in document order, as a browser would
+		if !labels[label] {
+			if len(labels) >= maxLabels {
+				findings = append(findings, Finding{
+					Rule:     RuleLabelLimit,
+					Severity: SeverityError,
+					Index:    i,
+					Origin:   originStr,
+					Message: fmt.Sprintf("label %q would be label %d, over the limit of %d; browsers ignore this origin",
+						label, len(labels)+1, maxLabels),
+				})
+				continue
+			}
+			labels[label] = true
+		}
+
+		originURL, err := url.Parse(originStr)
+		if err != nil {
+			continue
+		}
+		if originURL.Scheme != "https" {
+			findings = append(findings, Finding{
+				Rule:     RuleInsecureScheme,
+				Severity: SeverityWarning,
+				Index:    i,
+				Origin:   originStr,
+				Message:  fmt.Sprintf("scheme %q is not https; passkeys are only available in secure contexts", originURL.Scheme),
+			})
+		}
+		if strings.TrimSuffix(originURL.Path, "/") != "" || originURL.RawQuery != "" || originURL.Fragment != "" {
+			findings = append(findings, Finding{
+				Rule:     RuleOriginPath,
+				Severity: SeverityWarning,
+				Index:    i,
+				Origin:   originStr,
+				Message:  fmt.Sprintf("browsers only compare %s://%s; the rest is ignored", originURL.Scheme, originURL.Host),
+			})
+		}
+		if opts.StrictOrigins {
+			if changes := counter.OriginChanges(originStr); len(changes) > 0 {
+				findings = append(findings, Finding{
+					Rule:     RuleNonCanonicalOrigin,
+					Severity: SeverityWarning,
+					Index:    i,
+					Origin:   originStr,
+					Message:  fmt.Sprintf("browsers normalize this origin before matching it: %s", strings.Join(changes, ", ")),
+				})
+			}
+		}
+		if message, ok := confusable(originURL.Hostname()); ok {
+			findings = append(findings, Finding{
+				Rule:     RuleConfusableOrigin,
+				Severity: SeverityWarning,
+				Index:    i,
+				Origin:   originStr,
+				Message:  message,
+			})
+		}
+
+		// Entries the RP ID covers on its own are reported once for the whole document
+		// when there are only such entries
+		if redundant.origins[i] && !redundant.all && i != redundant.self {
+			message := fmt.Sprintf("can use RP ID %s without related origins, since it is a registrable domain suffix of %s; browsers never need this entry",
+				opts.RPID, originURL.Hostname())
+			if !redundant.neededLabels[label] {
+				message += fmt.Sprintf(", and removing the entries the RP ID covers frees label %q for a cross-site origin", label)
+			}
+			findings = append(findings, Finding{
+				Rule:     RuleRedundantOrigin,
+				Severity: SeverityWarning,
+				Index:    i,
+				Origin:   originStr,
+				Message:  message,
+			})
+		}
+	}
+
+	// A document is only consulted for callers that cannot use the RP ID on their own
+	if redundant.all {
+		findings = append(findings, Finding{
+			Rule:     RuleUnnecessaryDocument,
+			Severity: SeverityWarning,
+			Index:    -1,
+			Message: fmt.Sprintf("every origin can use RP ID %s without related origins, since it is a registrable domain suffix of their hosts; "+
+				"browsers never consult this document, so it can be removed", opts.RPID),
+		})
+	}
+
+	findings = append(findings, selfOriginFindings(webAuthnResp.Origins, opts.RPID, redundant.self)...)
+
+	// Check that every caller origin is authorized
+	for _, callerOrigin := range opts.CallerOrigins {
+		if opts.StrictOrigins {
+			if changes := counter.OriginChanges(callerOrigin); len(changes) > 0 {
+				findings = append(findings, Finding{
+					Rule:     RuleNonCanonicalOrigin,
+					Severity: SeverityWarning,
+					Index:    -1,
+					Origin:   callerOrigin,
+					Message:  fmt.Sprintf("caller origin is only matched after normalizing it: %s", strings.Join(changes, ", ")),
+				})
+			}
+		}
+		// Browsers refuse insecure and loopback callers before matching them against
+		// the document, or without a chance of a match
+		if callerURL, err := url.Parse(callerOrigin); err == nil && callerURL.Host != "" {
+			if !rpid.SecureContext(callerOrigin, opts.SecureOrigins) {
+				findings = append(findings, Finding{
+					Rule:     RuleInsecureCaller,
+					Severity: SeverityError,
+					Index:    -1,
+					Origin:   callerOrigin,
+					Message: "caller origin is not a secure context; browsers only allow WebAuthn on https, or on http for a loopback host, " +
+						"and refuse the request before consulting the document",
+				})
+				continue
+			}
+			if rpid.Loopback(callerURL.Hostname()) {
+				// A loopback caller that can use the RP ID never needs the document
+				if opts.RPID != "" && rpid.Check(callerOrigin, opts.RPID).Status == rpid.Valid {
+					continue
+				}
+				findings = append(findings, Finding{
+					Rule:     RuleLoopbackCaller,
+					Severity: SeverityError,
+					Index:    -1,
+					Origin:   callerOrigin,
+					Message: fmt.Sprintf("a loopback host has no registrable domain, so browsers never authorize %s as a related origin; "+
+						"it can only use the RP ID %s", callerOrigin, strings.ToLower(callerURL.Hostname())),
+				})
+				continue
+			}
+		}
+		status := counter.ValidateWellKnownJSONWithMaxLabels(callerOrigin, jsonData, opts.MaxLabels)
+		if status != counter.StatusSuccess {
+			findings = append(findings, Finding{
+				Rule:     RuleNotAuthorized,
+				Severity: SeverityError,
+				Index:    -1,
+				Origin:   callerOrigin,
+				Message:  fmt.Sprintf("caller origin is not authorized: %s", status),
+			})
+		}
+	}
+
+	return findings
+}
+
+// redundancy records which origins of a document can use the RP ID without related
+// origins.
+type redundancy struct {
+	// origins reports, for each entry, whether the RP ID is valid for it on its own.
+	origins []bool
+	// all is true when every entry is redundant, so the document is unnecessary.
+	all bool
+	// neededLabels are the labels of the entries that are not redundant, and of the
+	// RP's own origin if it is listed.
+	neededLabels map[string]bool
+	// self is the index of the RP's own origin, or -1 if it is not listed.
+	self int
+}
+
+// redundantOrigins checks each origin against rpID under the standard WebAuthn rules. An
+// empty rpID makes no origin redundant.
+func redundantOrigins(origins []string, rpID string) redundancy {
+	r := redundancy{origins: make([]bool, len(origins)), neededLabels: make(map[string]bool), self: -1}
+	if rpID == "" {
+		return r
+	}
+	r.all = len(origins) > 0
+	self := "https://" + strings.ToLower(strings.TrimSuffix(rpID, "."))
+	for i, origin := range origins {
+		r.origins[i] = rpid.Check(origin, rpID).Status == rpid.Valid
+		if serialized, err := counter.SerializeOrigin(origin); err == nil && serialized == self && r.self < 0 {
+			r.self = i
+		}
+		if !r.origins[i] {
+			r.all = false
+		}
+		if !r.origins[i] || i == r.self {
+			if label, ok := counter.OriginLabel(origin); ok {
+				r.neededLabels[label] = true
+			}
+		}
+	}
+	return r
+}
+
+// selfOriginFindings reports a document that does not list the RP's own origin, or that
+// only lists origins outside the RP ID, given the index of the RP's own origin among
+// origins. An RP ID without a label of its own, such as localhost, is not checked, and
+// neither is a document without web origins.
+func selfOriginFindings(origins []string, rpID string, self int) []Finding {
+	if rpID == "" || self >= 0 {
+		return nil
+	}
+	rpID = strings.ToLower(strings.TrimSuffix(rpID, "."))
+	selfOrigin := "https://" + rpID
+	if _, ok := counter.OriginLabel(selfOrigin); !ok {
+		return nil
+	}
+
+	webOrigins, firstParty := 0, false
+	for _, origin := range origins {
+		if counter.IsAndroidOrigin(origin) {
+			continue
+		}
+		if _, ok := counter.OriginLabel(origin); !ok {
+			continue
+		}
+		originURL, err := url.Parse(origin)
+		if err != nil {
+			continue
+		}
+		webOrigins++
+		host := strings.ToLower(originURL.Hostname())
+		if host == rpID || strings.HasSuffix(host, "."+rpID) {
+			firstParty = true
+		}
+	}
+	switch {
+	case webOrigins == 0:
+		return nil
+	case !firstParty:
+		return []Finding{{
+			Rule:     RuleThirdPartyOnly,
+			Severity: SeverityWarning,
+			Index:    -1,
+			Origin:   selfOrigin,
+			Message: fmt.Sprintf("no origin is RP ID %s or a subdomain of it, so the document only authorizes third-party domains; "+
+				"check that it is served for the right RP ID, and list the RP's own origin %s", rpID, selfOrigin),
+		}}
+	default:
+		return []Finding{{
+			Rule:     RuleSelfOriginMissing,
+			Severity: SeverityWarning,
+			Index:    -1,
+			Origin:   selfOrigin,
+			Message: fmt.Sprintf("the RP's own origin %s is not listed; it only works because it matches RP ID %s, "+
+				"and stops working if the document is reused for another RP ID", selfOrigin, rpID),
+		}}
+	}
+}
+
+// ServingFindings returns a finding for each warning about how the document from source
+// was served, such as the Warnings of a counter.LabelCount.
+func ServingFindings(warnings []string, source string) []Finding {
+	var findings []Finding
+	for _, warning := range warnings {
+		finding := Finding{
+			Rule:        RuleServing,
+			Severity:    SeverityWarning,
+			Index:       -1,
+			Message:     warning,
+			Fingerprint: Fingerprint(RuleServing, normalizeSource(source), warning),
+		}
+		finding.Remediation = remediate(finding, source)
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// HasErrors reports whether any finding has SeverityError.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// HasWarnings reports whether any finding has SeverityWarning.
+func HasWarnings(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityWarning {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatFindings formats findings as one human-readable line each.
+func FormatFindings(findings []Finding) string {
+	var sb strings.Builder
+	for _, finding := range findings {
+		location := "document"
+		switch {
+		case finding.Index >= 0:
+			location = fmt.Sprintf("origins[%d] %s", finding.Index, finding.Origin)
+		case finding.Origin != "":
+			location = finding.Origin
+		}
+		sb.WriteString(fmt.Sprintf("%s[%s] %s: %s (%s)\n", finding.Severity, finding.Rule, location, finding.Message, finding.Fingerprint))
+	}
+	return sb.String()
+}
+
+// Fingerprint returns a stable identifier for a finding of rule about origin in the
+// document at source. Origins are compared in their normalized form, so that rewriting
+// an origin without changing it, such as lowercasing its host, keeps its fingerprint.
+func Fingerprint(rule, source, origin string) string {
+	if normalized, err := generate.Normalize(origin); err == nil && origin != "" {
+		origin = normalized
+	}
+	sum := sha256.Sum256([]byte(rule + "\x00" + source + "\x00" + origin))
+	return hex.EncodeToString(sum[:8])
+}
+
+// normalizeSource returns a canonical form of a document's source: the lowercase host
+// and path of a URL, or the cleaned path of a file.
+func normalizeSource(source string) string {
+	if sourceURL, err := url.Parse(source); err == nil && sourceURL.Host != "" {
+		return strings.ToLower(sourceURL.Host) + sourceURL.Path
+	}
+	if source == "" {
+		return ""
+	}
+	return filepath.ToSlash(filepath.Clean(source))
+}
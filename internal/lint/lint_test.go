@@ -0,0 +1,525 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestCheck tests the rules reported for documents and caller origins.
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		json          string
+		callerOrigins []string
+		maxLabels     int
+		rpID          string
+		strict        bool
+		secure        []string
+		// expected lists each finding as "rule@index"
+		expected []string
+	}{
+		{
+			name:     "Clean document",
+			json:     `{"origins": ["https://example.com", "https://example.co.uk"]}`,
+			expected: nil,
+		},
+		{
+			name:     "Invalid JSON",
+			json:     `{"origins": [`,
+			expected: []string{"invalid-json@-1"},
+		},
+		{
+			name:     "Missing origins array",
+			json:     `{"other": []}`,
+			expected: []string{"schema@-1", "schema@-1"},
+		},
+		{
+			name:     "Entry that is not a string",
+			json:     `{"origins": ["https://example.com", null, "localhost"]}`,
+			expected: []string{"schema@-1"},
+		},
+		{
+			name:     "Unknown member",
+			json:     `{"origins": ["https://example.com", "localhost"], "comment": "kept"}`,
+			expected: []string{"schema@-1", "invalid-origin@1"},
+		},
+		{
+			name:     "Entry problems",
+			json:     `{"origins": ["https://example.com", "http://example.org", "https://example.net/login", "localhost", "https://example.com"]}`,
+			expected: []string{"insecure-scheme@1", "origin-path@2", "invalid-origin@3", "duplicate-origin@4"},
+		},
+		{
+			name: "Sixth label",
+			json: `{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com",
+				"http://a.com", "https://f.com"]}`,
+			expected: []string{"insecure-scheme@5", "label-limit@6"},
+		},
+		{
+			name:          "Configured label limit",
+			json:          `{"origins": ["https://a.com", "https://b.com", "https://c.com"]}`,
+			callerOrigins: []string{"https://c.com"},
+			maxLabels:     2,
+			expected:      []string{"label-limit@2", "not-authorized@-1"},
+		},
+		{
+			name:          "Caller origins",
+			json:          `{"origins": ["https://example.com"]}`,
+			callerOrigins: []string{"https://example.com", "https://example.org"},
+			expected:      []string{"not-authorized@-1"},
+		},
+		{
+			name:     "Origins under the RP ID",
+			json:     `{"origins": ["https://example.com", "https://login.example.com:8443"]}`,
+			rpID:     "example.com",
+			expected: []string{"unnecessary-document@-1"},
+		},
+		{
+			name:     "Related origin under another site",
+			json:     `{"origins": ["https://login.example.com", "https://example.co.uk", "https://other.com"]}`,
+			rpID:     "example.com",
+			expected: []string{"redundant-origin@0", "self-origin-missing@-1"},
+		},
+		{
+			name:     "RP's own origin",
+			json:     `{"origins": ["https://example.com", "https://example.co.uk"]}`,
+			rpID:     "example.com",
+			expected: nil,
+		},
+		{
+			name:     "Third-party origins only",
+			json:     `{"origins": ["https://example.co.uk", "https://other.com", "android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI"]}`,
+			rpID:     "example.com",
+			expected: []string{"third-party-only@-1"},
+		},
+		{
+			name:     "Origin that is not a secure context",
+			json:     `{"origins": ["http://login.example.com"]}`,
+			rpID:     "example.com",
+			expected: []string{"insecure-scheme@0", "self-origin-missing@-1"},
+		},
+		{
+			name:          "Origins that need normalizing",
+			json:          `{"origins": ["https://Example.com:443", "https://example.org:8443", "https://example.com"]}`,
+			callerOrigins: []string{"HTTPS://example.org:8443"},
+			expected:      []string{"near-duplicate-origin@2"},
+		},
+		{
+			name:     "Exact and near duplicates",
+			json:     `{"origins": ["https://example.com", "https://example.com.", "https://example.com", "https://login.example.com.:443", "https://login.example.com"]}`,
+			expected: []string{"near-duplicate-origin@1", "duplicate-origin@2", "near-duplicate-origin@4"},
+		},
+		{
+			name:          "Strict origins",
+			json:          `{"origins": ["https://Example.com:443", "https://example.org:8443", "https://example.net/"]}`,
+			callerOrigins: []string{"HTTPS://example.org:8443", "https://example.com"},
+			strict:        true,
+			expected:      []string{"non-canonical-origin@0", "non-canonical-origin@-1"},
+		},
+		{
+			name:          "Insecure and loopback callers",
+			json:          `{"origins": ["https://example.com", "http://localhost:3000"]}`,
+			callerOrigins: []string{"http://example.com", "http://localhost:8080", "http://127.0.0.1:8080", "http://staging.example.com:8080"},
+			secure:        []string{"http://staging.example.com:8080"},
+			expected:      []string{"invalid-origin@1", "insecure-caller@-1", "loopback-caller@-1", "loopback-caller@-1", "not-authorized@-1"},
+		},
+		{
+			name:          "Loopback caller under the RP ID",
+			json:          `{"origins": ["https://example.com"]}`,
+			callerOrigins: []string{"http://localhost:8080"},
+			rpID:          "localhost",
+			expected:      nil,
+		},
+		{
+			name:          "Android app origins",
+			json:          `{"origins": ["https://example.com", "android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI", "android:apk-key-hash:abc=", "android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI"]}`,
+			callerOrigins: []string{"android:apk-key-hash:BimEMugGayniIjvMI6qVBLVq5Qj6vzQ1UIhpucMZDiI"},
+			rpID:          "example.com",
+			expected:      []string{"invalid-origin@2", "duplicate-origin@3"},
+		},
+		{
+			name:     "Empty origins",
+			json:     `{"origins": []}`,
+			rpID:     "example.com",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := Check([]byte(tt.json), Options{CallerOrigins: tt.callerOrigins, MaxLabels: tt.maxLabels, RPID: tt.rpID, StrictOrigins: tt.strict,
+				SecureOrigins: tt.secure})
+
+			var got []string
+			for _, finding := range findings {
+				got = append(got, finding.Rule+"@"+strconv.Itoa(finding.Index))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected findings %v, got %v\n%s", tt.expected, got, FormatFindings(findings))
+			}
+		})
+	}
+}
+
+// TestHasErrors tests that only error findings fail a document.
+func TestHasErrors(t *testing.T) {
+	warnings := Check([]byte(`{"origins": ["http://example.com"]}`), Options{})
+	if len(warnings) != 1 || HasErrors(warnings) || !HasWarnings(warnings) {
+		t.Errorf("Expected a single warning, got %v", warnings)
+	}
+
+	errors := Check([]byte(`{"origins": ["localhost"]}`), Options{})
+	if !HasErrors(errors) {
+		t.Errorf("Expected an error, got %v", errors)
+	}
+	if !strings.HasPrefix(FormatFindings(errors), "error[invalid-origin] origins[0] localhost: ") {
+		t.Errorf("Unexpected format %q", FormatFindings(errors))
+	}
+}
+
+// TestCountRules tests counting findings by rule.
+func TestCountRules(t *testing.T) {
+	doc := `{"origins": ["https://example.com", "https://Example.com", "https://example.com", "https://example.com.", "http://example.org"]}`
+	counts := CountRules(Check([]byte(doc), Options{}))
+	if counts[RuleDuplicateOrigin] != 1 || counts[RuleNearDuplicateOrigin] != 2 || counts[RuleInsecureScheme] != 1 || len(counts) != 3 {
+		t.Errorf("Unexpected counts %v", counts)
+	}
+}
+
+// TestAnnotate tests that findings are printed next to the origins they are about.
+func TestAnnotate(t *testing.T) {
+	t.Run("One origin per line", func(t *testing.T) {
+		doc := "{\n  \"origins\": [\n    \"https://example.com\",\n    \"http://example.org\",\n    \"https://example.com\"\n  ]\n}\n"
+		findings := Check([]byte(doc), Options{CallerOrigins: []string{"https://example.net"}})
+		expected := "// error[not-authorized] https://example.net: caller origin is not authorized: BAD_RELYING_PARTY_ID_NO_JSON_MATCH (" + findings[2].Fingerprint + ")\n" +
+			"{\n" +
+			"  \"origins\": [\n" +
+			"    \"https://example.com\",\n" +
+			"    \"http://example.org\",  // warning[insecure-scheme]: scheme \"http\" is not https; passkeys are only available in secure contexts (" + findings[0].Fingerprint + ")\n" +
+			"    \"https://example.com\"  // warning[duplicate-origin]: duplicate of origins[0] (" + findings[1].Fingerprint + ")\n" +
+			"  ]\n" +
+			"}\n"
+		if got := Annotate([]byte(doc), findings); got != expected {
+			t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+		}
+	})
+
+	t.Run("Minified", func(t *testing.T) {
+		doc := `{"origins":["https://example.com","https://example.com"]}`
+		got := Annotate([]byte(doc), Check([]byte(doc), Options{}))
+		if !strings.Contains(got, "    \"https://example.com\"  // warning[duplicate-origin]: duplicate of origins[0] (") {
+			t.Errorf("Expected the document to be pretty-printed and annotated, got:\n%s", got)
+		}
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		doc := `{"origins": [`
+		got := Annotate([]byte(doc), Check([]byte(doc), Options{}))
+		if !strings.HasPrefix(got, "// error[invalid-json]: ") || !strings.HasSuffix(got, doc+"\n") {
+			t.Errorf("Expected the finding before the document, got:\n%s", got)
+		}
+	})
+}
+
+// TestFingerprint tests that fingerprints survive unrelated edits to a document.
+func TestFingerprint(t *testing.T) {
+	opts := Options{Source: "https://Example.com/.well-known/webauthn"}
+	before := Check([]byte(`{"origins": ["https://example.com", "http://example.org"]}`), opts)
+	after := Check([]byte(`{"origins": ["https://example.net", "http://Example.org", "https://example.com"]}`), opts)
+	if len(before) != 1 || len(after) != 1 {
+		t.Fatalf("Expected one finding each, got %v and %v", before, after)
+	}
+	if before[0].Fingerprint == "" || before[0].Fingerprint != after[0].Fingerprint {
+		t.Errorf("Expected the fingerprint to survive reordering and case changes, got %q and %q",
+			before[0].Fingerprint, after[0].Fingerprint)
+	}
+
+	other := Check([]byte(`{"origins": ["http://example.org"]}`), Options{Source: "https://example.net/.well-known/webauthn"})
+	if other[0].Fingerprint == before[0].Fingerprint {
+		t.Error("Expected documents from different sources to have different fingerprints")
+	}
+	if Fingerprint(RuleInsecureScheme, "", "http://example.org") == Fingerprint(RuleOriginPath, "", "http://example.org") {
+		t.Error("Expected different rules to have different fingerprints")
+	}
+}
+
+// TestRedundantOrigin tests reporting the labels that redundant entries would free. The
+// RP's own origin is not redundant, and keeps its label.
+func TestRedundantOrigin(t *testing.T) {
+	doc := `{"origins": ["https://example.com", "https://www.shop.com", "https://shop.com", "https://login.shop.com", "https://example.co.uk"]}`
+	findings := Check([]byte(doc), Options{RPID: "shop.com"})
+
+	var messages []string
+	for _, finding := range findings {
+		if finding.Rule != RuleRedundantOrigin || finding.Remediation == nil || finding.Remediation.Action != ActionRemoveOrigin {
+			t.Fatalf("Unexpected finding %+v", finding)
+		}
+		messages = append(messages, fmt.Sprintf("%d %s", finding.Index, finding.Message))
+	}
+	expected := []string{
+		`1 can use RP ID shop.com without related origins, since it is a registrable domain suffix of www.shop.com; browsers never need this entry`,
+		`3 can use RP ID shop.com without related origins, since it is a registrable domain suffix of login.shop.com; browsers never need this entry`,
+	}
+	if strings.Join(messages, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected findings:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(messages, "\n"))
+	}
+
+	// Without the RP's own origin, removing the entries the RP ID covers frees its label
+	findings = Check([]byte(`{"origins": ["https://example.com", "https://www.shop.com", "https://example.co.uk"]}`), Options{RPID: "shop.com"})
+	if len(findings) != 2 || findings[1].Rule != RuleSelfOriginMissing ||
+		!strings.HasSuffix(findings[0].Message, `frees label "shop" for a cross-site origin`) {
+		t.Errorf("Expected a redundant entry that frees its label and a missing own origin, got %v", findings)
+	}
+
+	// An entry whose label a cross-site origin also needs frees no label
+	findings = Check([]byte(`{"origins": ["https://example.com", "https://login.example.com", "https://example.co.uk"]}`), Options{RPID: "example.com"})
+	if len(findings) != 1 || strings.Contains(findings[0].Message, "frees") {
+		t.Errorf("Expected a redundant entry that frees no label, got %v", findings)
+	}
+}
+
+// TestConfusable tests warnings about look-alike hosts.
+func TestConfusable(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"example.com", ""},
+		{"bücher.de", ""},
+		{"xn--bcher-kva.de", ""},
+		{"日本語.jp", ""},
+		{"ソニー.jp", ""},
+		{"한국.kr", ""},
+		{"пример.рф", ""},
+		{"аррӏе.com", "host аррӏе.com may be a look-alike of another domain: it looks like apple.com"},
+		{"xn--80ak6aa92e.com", "host аррӏе.com (xn--80ak6aa92e.com) may be a look-alike of another domain: it looks like apple.com"},
+		{"pаypal.com", `host pаypal.com may be a look-alike of another domain: label "pаypal" mixes the Cyrillic and Latin scripts; it looks like paypal.com`},
+		{"gοοgle.com", `host gοοgle.com may be a look-alike of another domain: label "gοοgle" mixes the Greek and Latin scripts; it looks like google.com`},
+		{"Examplе.COM", `host examplе.com may be a look-alike of another domain: label "examplе" mixes the Cyrillic and Latin scripts; it looks like example.com`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			message, ok := confusable(tt.host)
+			if ok != (tt.expected != "") || message != tt.expected {
+				t.Errorf("confusable(%q) = %q, %v; want %q", tt.host, message, ok, tt.expected)
+			}
+		})
+	}
+
+	findings := Check([]byte(`{"origins": ["https://example.com", "https://xn--80ak6aa92e.com"]}`), Options{})
+	if len(findings) != 1 || findings[0].Rule != RuleConfusableOrigin || findings[0].Index != 1 || findings[0].Severity != SeverityWarning {
+		t.Errorf("Expected a confusable-origin warning for origins[1], got %v", findings)
+	}
+}
+
+// TestRemediation tests the remediations attached to findings.
+func TestRemediation(t *testing.T) {
+	const source = "https://example.com/.well-known/webauthn"
+	doc := `{"origins": ["https://a.com", "http://A.com/login", "b.com", "localhost", "https://a.com", "https://c.com", "https://d.com", "https://e.com", "https://f.com", "https://h.com"]}`
+	findings := Check([]byte(doc), Options{CallerOrigins: []string{"https://G.com:443"}, Source: source})
+	findings = append(findings, ServingFindings([]string{"content type mismatch: json body served as text/plain; accepted by the lenient content type policy, but browsers will reject it"}, source)...)
+
+	expected := []string{
+		"insecure-scheme@1 replace-origin http://A.com/login https://a.com",
+		"origin-path@1 replace-origin http://A.com/login https://a.com",
+		"invalid-origin@2 replace-origin b.com https://b.com",
+		"invalid-origin@3 remove-origin localhost ",
+		"duplicate-origin@4 remove-origin https://a.com ",
+		"label-limit@9 move-origin https://h.com ",
+		"not-authorized@-1 add-origin " + source + " https://g.com",
+		"serving@-1 fix-serving " + source + " application/json",
+	}
+	var got []string
+	for _, finding := range findings {
+		if finding.Remediation == nil {
+			t.Fatalf("Expected a remediation for %+v", finding)
+		}
+		r := finding.Remediation
+		got = append(got, fmt.Sprintf("%s@%d %s %s %s", finding.Rule, finding.Index, r.Action, r.Target, r.Value))
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected remediations:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	// Remediations are part of the JSON form of a finding
+	data, err := json.Marshal(findings[0])
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"remediation":{"action":"replace-origin","target":"http://A.com/login","value":"https://a.com"}`) {
+		t.Errorf("Expected the remediation in %s", data)
+	}
+
+	// A value browsers reject the document for is pointed at, and an unknown member is
+	// left alone
+	findings = Check([]byte(`{"origins": ["https://a.com", 42], "comment": ""}`), Options{Source: source})
+	if len(findings) != 2 || findings[0].Pointer != "/comment" || findings[0].Remediation != nil ||
+		findings[1].Pointer != "/origins/1" || findings[1].Remediation == nil || findings[1].Remediation.Value != "/origins/1" {
+		t.Errorf("Unexpected schema findings %+v", findings)
+	}
+}
+
+// TestSARIF tests SARIF output for local files and fetched documents.
+func TestSARIF(t *testing.T) {
+	type result struct {
+		RuleID    string `json:"ruleId"`
+		RuleIndex int    `json:"ruleIndex"`
+		Level     string `json:"level"`
+		Locations []struct {
+			PhysicalLocation struct {
+				ArtifactLocation struct {
+					URI       string `json:"uri"`
+					URIBaseID string `json:"uriBaseId"`
+				} `json:"artifactLocation"`
+				Region *struct {
+					StartLine int `json:"startLine"`
+				} `json:"region"`
+			} `json:"physicalLocation"`
+		} `json:"locations"`
+		PartialFingerprints map[string]string `json:"partialFingerprints"`
+	}
+	type log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []result `json:"results"`
+		} `json:"runs"`
+	}
+	decode := func(t *testing.T, data []byte) log {
+		t.Helper()
+		var l log
+		if err := json.Unmarshal(data, &l); err != nil {
+			t.Fatalf("Failed to decode SARIF: %v", err)
+		}
+		if l.Version != "2.1.0" || len(l.Runs) != 1 {
+			t.Fatalf("Unexpected SARIF log %+v", l)
+		}
+		return l
+	}
+	doc := "{\n  \"origins\": [\n    \"https://example.com\",\n    \"http://example.org\"\n  ]\n}\n"
+
+	t.Run("Local file", func(t *testing.T) {
+		findings := Check([]byte(doc), Options{CallerOrigins: []string{"https://example.net"}, Source: "./well-known/webauthn.json"})
+		data, err := SARIF(findings, SARIFOptions{Source: "./well-known/webauthn.json", Document: []byte(doc)})
+		if err != nil {
+			t.Fatalf("SARIF returned an error: %v", err)
+		}
+		l := decode(t, data)
+		results := l.Runs[0].Results
+		if len(results) != 2 {
+			t.Fatalf("Expected 2 results, got %+v", results)
+		}
+
+		insecure := results[0]
+		if insecure.RuleID != RuleInsecureScheme || insecure.Level != "warning" || l.Runs[0].Tool.Driver.Rules[insecure.RuleIndex].ID != RuleInsecureScheme {
+			t.Errorf("Unexpected result %+v", insecure)
+		}
+		location := insecure.Locations[0].PhysicalLocation
+		if location.ArtifactLocation.URI != "well-known/webauthn.json" || location.ArtifactLocation.URIBaseID != "%SRCROOT%" {
+			t.Errorf("Unexpected artifact location %+v", location.ArtifactLocation)
+		}
+		if location.Region == nil || location.Region.StartLine != 4 {
+			t.Errorf("Expected the finding on line 4, got %+v", location.Region)
+		}
+		if insecure.PartialFingerprints[fingerprintKey] != findings[0].Fingerprint {
+			t.Errorf("Expected the finding's fingerprint, got %v", insecure.PartialFingerprints)
+		}
+
+		notAuthorized := results[1]
+		if notAuthorized.Level != "error" || notAuthorized.Locations[0].PhysicalLocation.Region.StartLine != 1 {
+			t.Errorf("Unexpected result %+v", notAuthorized)
+		}
+	})
+
+	t.Run("Fetched document", func(t *testing.T) {
+		source := "https://example.com/.well-known/webauthn"
+		data, err := SARIF(Check([]byte(doc), Options{Source: source}), SARIFOptions{Source: source, Document: []byte(doc)})
+		if err != nil {
+			t.Fatalf("SARIF returned an error: %v", err)
+		}
+		location := decode(t, data).Runs[0].Results[0].Locations[0].PhysicalLocation
+		if location.ArtifactLocation.URI != source || location.Region != nil {
+			t.Errorf("Expected the finding at the URL, got %+v", location)
+		}
+	})
+
+	t.Run("No findings", func(t *testing.T) {
+		data, err := SARIF(nil, SARIFOptions{Source: "webauthn.json"})
+		if err != nil {
+			t.Fatalf("SARIF returned an error: %v", err)
+		}
+		if !strings.Contains(string(data), `"results": []`) {
+			t.Errorf("Expected an empty results array, got %s", data)
+		}
+		if strings.Contains(string(data), `"properties"`) {
+			t.Errorf("Expected no properties without a model version, got %s", data)
+		}
+	})
+
+	t.Run("Model version", func(t *testing.T) {
+		data, err := SARIF(nil, SARIFOptions{Source: "webauthn.json", ModelVersion: "chromium-128"})
+		if err != nil {
+			t.Fatalf("SARIF returned an error: %v", err)
+		}
+		if !strings.Contains(string(data), `"modelVersion": "chromium-128"`) {
+			t.Errorf("Expected the model version in the run's properties, got %s", data)
+		}
+	})
+}
+
+// TestApply tests overriding the severity of rules and filtering findings by severity.
+func TestApply(t *testing.T) {
+	findings := Check([]byte(`{"origins": ["http://example.com", "https://example.com/login"]}`), Options{CallerOrigins: []string{"https://other.com"}})
+	findings = append(findings, ServingFindings([]string{"served as text/plain"}, "https://example.com/.well-known/webauthn")...)
+
+	overrides, err := ParseOverrides(map[string]string{"insecure-scheme": "error", "origin-path": "info"})
+	if err != nil {
+		t.Fatalf("ParseOverrides returned an error: %v", err)
+	}
+	severities := func(findings []Finding) map[string]Severity {
+		result := make(map[string]Severity)
+		for _, finding := range findings {
+			result[finding.Rule] = finding.Severity
+		}
+		return result
+	}
+
+	all := severities(Apply(findings, overrides, SeverityInfo))
+	expected := map[string]Severity{
+		RuleInsecureScheme: SeverityError,
+		RuleOriginPath:     SeverityInfo,
+		RuleNotAuthorized:  SeverityError,
+		RuleServing:        SeverityWarning,
+	}
+	if len(all) != len(expected) {
+		t.Errorf("Expected %v, got %v", expected, all)
+	}
+	for rule, severity := range expected {
+		if all[rule] != severity {
+			t.Errorf("Expected %s to be %v, got %v", rule, severity, all[rule])
+		}
+	}
+
+	errors := Apply(findings, overrides, SeverityError)
+	if len(errors) != 2 || HasWarnings(errors) {
+		t.Errorf("Expected only the 2 errors, got %v", errors)
+	}
+
+	if _, err := ParseOverrides(map[string]string{"no-such-rule": "error"}); err == nil {
+		t.Error("Expected an error for an unknown rule")
+	}
+	if _, err := ParseOverrides(map[string]string{"serving": "fatal"}); err == nil {
+		t.Error("Expected an error for an unknown severity")
+	}
+	if severity, err := ParseSeverity("warn"); err != nil || severity != SeverityWarning {
+		t.Errorf("ParseSeverity(warn) returned %v, %v", severity, err)
+	}
+}
@@ -0,0 +1,106 @@
+package lint
+
+import (
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/generate"
+)
+
+// Remediation actions, which tell automation what kind of change fixes a finding.
+const (
+	// ActionFixJSON asks for the document to be rewritten as valid JSON with an origins
+	// array. Its target is the document's source, and its value, if any, the JSON
+	// Pointer of the value to fix.
+	ActionFixJSON = "fix-json"
+	// ActionRemoveOrigin asks for the entry of the finding's index to be removed.
+	ActionRemoveOrigin = "remove-origin"
+	// ActionReplaceOrigin asks for the entry of the finding's index to be replaced with
+	// the suggested value.
+	ActionReplaceOrigin = "replace-origin"
+	// ActionAddOrigin asks for the suggested value to be added to the origins array.
+	ActionAddOrigin = "add-origin"
+	// ActionMoveOrigin asks for the entry of the finding's index to be moved before the
+	// origins of a label that can be given up, since the order of the origins decides
+	// which labels browsers count.
+	ActionMoveOrigin = "move-origin"
+	// ActionFixServing asks for the server of the document to change how it serves it,
+	// such as its content type, which is the suggested value if there is one. Its target
+	// is the document's source.
+	ActionFixServing = "fix-serving"
+)
+
+// Remediation is a change that fixes a finding, in a form automation can apply, such as
+// to open a pull request against the repository that owns the document.
+type Remediation struct {
+	// Action is the kind of change, such as ActionReplaceOrigin.
+	Action string `json:"action"`
+	// Target is the origin or document the change applies to.
+	Target string `json:"target"`
+	// Value is the suggested new value, such as the origin to write instead, if the
+	// action takes one.
+	Value string `json:"value,omitempty"`
+}
+
+// remediate returns the remediation of a finding about the document at source, or nil
+// if the finding needs none.
+func remediate(finding Finding, source string) *Remediation {
+	switch finding.Rule {
+	case RuleInvalidJSON:
+		return &Remediation{Action: ActionFixJSON, Target: source}
+	case RuleSchema:
+		// An unknown member does no harm, so only a value browsers reject needs fixing
+		if finding.Severity < SeverityError {
+			return nil
+		}
+		return &Remediation{Action: ActionFixJSON, Target: source, Value: finding.Pointer}
+	case RuleInvalidOrigin:
+		// An origin written without a scheme may still name a host with a label
+		if suggested, ok := suggestOrigin(finding.Origin); ok {
+			if _, ok := counter.OriginLabel(suggested); ok {
+				return &Remediation{Action: ActionReplaceOrigin, Target: finding.Origin, Value: suggested}
+			}
+		}
+		return &Remediation{Action: ActionRemoveOrigin, Target: finding.Origin}
+	case RuleInsecureScheme, RuleOriginPath, RuleNonCanonicalOrigin:
+		// A caller origin is not an entry of the document to replace
+		if finding.Index < 0 {
+			return nil
+		}
+		suggested, ok := suggestOrigin(finding.Origin)
+		if !ok {
+			return nil
+		}
+		return &Remediation{Action: ActionReplaceOrigin, Target: finding.Origin, Value: suggested}
+	case RuleDuplicateOrigin, RuleNearDuplicateOrigin, RuleRedundantOrigin:
+		return &Remediation{Action: ActionRemoveOrigin, Target: finding.Origin}
+	case RuleLabelLimit:
+		return &Remediation{Action: ActionMoveOrigin, Target: finding.Origin}
+	case RuleNotAuthorized, RuleSelfOriginMissing, RuleThirdPartyOnly:
+		value := finding.Origin
+		if normalized, err := generate.Normalize(finding.Origin); err == nil {
+			value = normalized
+		}
+		return &Remediation{Action: ActionAddOrigin, Target: source, Value: value}
+	case RuleServing:
+		remediation := &Remediation{Action: ActionFixServing, Target: source}
+		if strings.Contains(finding.Message, "content type") {
+			remediation.Value = "application/json"
+		}
+		return remediation
+	default:
+		return nil
+	}
+}
+
+// suggestOrigin returns origin as it should be written: normalized, and with https as
+// its scheme. Every finding about an entry suggests the same replacement, so that
+// automation fixing several findings at once does not make conflicting changes.
+func suggestOrigin(origin string) (string, bool) {
+	normalized, err := generate.Normalize(origin)
+	if err != nil {
+		return "", false
+	}
+	_, host, _ := strings.Cut(normalized, "://")
+	return "https://" + host, true
+}
@@ -0,0 +1,225 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// sarifSchema is the JSON schema of the SARIF version written by SARIF.
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// fingerprintKey is the key of findings' fingerprints in SARIF partialFingerprints.
+const fingerprintKey = "passkeyOriginValidator/v1"
+
+// rules describes every rule, in the order they are listed in SARIF output.
+var rules = []struct {
+	ID          string
+	Severity    Severity
+	Description string
+}{
+	{RuleInvalidJSON, SeverityError, "The document is not valid JSON"},
+	{RuleSchema, SeverityError, "A value of the document does not match its schema, such as an entry that is not a string"},
+	{RuleInvalidOrigin, SeverityError, "The origin cannot be parsed or has no registrable domain, so browsers ignore it"},
+	{RuleLabelLimit, SeverityError, "The origin would add a label beyond the label limit, so browsers ignore it"},
+	{RuleNotAuthorized, SeverityError, "A caller origin is not authorized by the document"},
+	{RuleInsecureCaller, SeverityError, "A caller origin is not a secure context, so browsers refuse its WebAuthn requests"},
+	{RuleLoopbackCaller, SeverityError, "A caller origin is on a loopback host, which browsers never authorize as a related origin"},
+	{RuleInsecureScheme, SeverityWarning, "The origin is not https"},
+	{RuleOriginPath, SeverityWarning, "The origin has a path, query or fragment, which browsers discard"},
+	{RuleDuplicateOrigin, SeverityWarning, "The origin is listed more than once"},
+	{RuleNearDuplicateOrigin, SeverityWarning, "The origin is already listed in another form, such as with another case or a default port"},
+	{RuleNonCanonicalOrigin, SeverityWarning, "The origin only matches after browsers normalize its case or default port"},
+	{RuleServing, SeverityWarning, "The document is served in a way that some browsers reject or truncate"},
+	{RuleConfusableOrigin, SeverityWarning, "The origin's host mixes scripts or looks like another domain"},
+	{RuleRedundantOrigin, SeverityWarning, "The origin can use the RP ID without related origins, so its entry is unnecessary"},
+	{RuleUnnecessaryDocument, SeverityWarning, "Every origin can use the RP ID without related origins, so the document is unnecessary"},
+	{RulePolicy, SeverityError, "The document violates a rule of a Rego policy"},
+	{RuleAppRPMismatch, SeverityWarning, "The origin's host shares credentials with apps that the RP ID does not"},
+	{RuleAppOriginMissing, SeverityWarning, "A domain shares apps or credentials with the RP ID, but its origin is not listed"},
+	{RuleAppSiteAssociation, SeverityWarning, "An apple-app-site-association file cannot be fetched or parsed, or lists an invalid app ID"},
+	{RuleAssetLinks, SeverityWarning, "An assetlinks.json file cannot be fetched or parsed, or lists an invalid package, fingerprint or site"},
+	{RuleAndroidOriginUnlinked, SeverityWarning, "The Android app origin's certificate is not linked to the RP ID in its assetlinks.json"},
+	{RuleSelfOriginMissing, SeverityWarning, "The RP's own origin is not listed in its document"},
+	{RuleThirdPartyOnly, SeverityWarning, "The document only authorizes domains outside the RP ID"},
+	{RuleGroupMissing, SeverityError, "A member of a domain group cannot use the RP ID it is meant to use"},
+	{RuleGroupOneWay, SeverityWarning, "Only one of a pair of members of a domain group can use the other's RP ID"},
+}
+
+// SARIFOptions configures SARIF.
+type SARIFOptions struct {
+	// ToolVersion is the version of this tool reported in the log.
+	ToolVersion string
+	// Source is where the document came from: its URL, or the path of a local file.
+	Source string
+	// Document is the content of the document, used to locate findings on the lines of
+	// a local file.
+	Document []byte
+	// ModelVersion, if set, names the browser behavior model the findings were made
+	// with, such as "chromium-128". It is reported in the run's properties.
+	ModelVersion string
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool         `json:"tool"`
+	Results    []sarifResult     `json:"results"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// SARIF formats findings as a SARIF 2.1.0 log, for GitHub Code Scanning and other
+// SARIF consumers. Each result carries its rule, severity and fingerprint. Findings in
+// a local file are located on the line of the origin they are about, or on the first
+// line for findings about the whole document or a caller origin; findings in a fetched
+// document are located at its URL.
+func SARIF(findings []Finding, opts SARIFOptions) ([]byte, error) {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "passkey-origin-validator",
+			Version:        opts.ToolVersion,
+			InformationURI: "https://github.com/developmeh/passkey-origin-validator",
+		}},
+		Results: []sarifResult{},
+	}
+	if opts.ModelVersion != "" {
+		run.Properties = map[string]string{"modelVersion": opts.ModelVersion}
+	}
+	ruleIndex := make(map[string]int)
+	for i, rule := range rules {
+		ruleIndex[rule.ID] = i
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:                   rule.ID,
+			ShortDescription:     sarifMessage{Text: rule.Description},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(rule.Severity)},
+		})
+	}
+
+	artifact, local := sarifArtifact(opts.Source)
+	var lines map[int]int
+	if local {
+		lines, _ = originLines(opts.Document)
+	}
+
+	for _, finding := range findings {
+		index, ok := ruleIndex[finding.Rule]
+		if !ok {
+			return nil, fmt.Errorf("unknown rule %q", finding.Rule)
+		}
+		location := sarifPhysicalLocation{ArtifactLocation: artifact}
+		if local {
+			line := 1
+			if l, ok := lines[finding.Index]; ok && finding.Index >= 0 {
+				line = l + 1
+			}
+			location.Region = &sarifRegion{StartLine: line}
+		}
+
+		message := finding.Message
+		switch {
+		case finding.Index >= 0:
+			message = fmt.Sprintf("origins[%d] %s: %s", finding.Index, finding.Origin, finding.Message)
+		case finding.Origin != "":
+			message = fmt.Sprintf("%s: %s", finding.Origin, finding.Message)
+		}
+
+		run.Results = append(run.Results, sarifResult{
+			RuleID:              finding.Rule,
+			RuleIndex:           index,
+			Level:               sarifLevel(finding.Severity),
+			Message:             sarifMessage{Text: message},
+			Locations:           []sarifLocation{{PhysicalLocation: location}},
+			PartialFingerprints: map[string]string{fingerprintKey: finding.Fingerprint},
+		})
+	}
+
+	data, err := json.MarshalIndent(sarifLog{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// sarifLevel returns the SARIF level of a severity.
+func sarifLevel(severity Severity) string {
+	switch severity {
+	case SeverityError:
+		return "error"
+	case SeverityInfo:
+		return "note"
+	default:
+		return "warning"
+	}
+}
+
+// sarifArtifact returns the artifact location of a document's source, and whether it is
+// a local file. Relative paths are resolved against the source root, so that they match
+// the files of a checked-out repository.
+func sarifArtifact(source string) (sarifArtifactLocation, bool) {
+	if sourceURL, err := url.Parse(source); err == nil && sourceURL.Host != "" {
+		return sarifArtifactLocation{URI: source}, false
+	}
+	path := filepath.ToSlash(filepath.Clean(source))
+	if filepath.IsAbs(source) {
+		return sarifArtifactLocation{URI: (&url.URL{Scheme: "file", Path: path}).String()}, true
+	}
+	return sarifArtifactLocation{URI: strings.TrimPrefix(path, "./"), URIBaseID: "%SRCROOT%"}, true
+}
@@ -0,0 +1,59 @@
+package lint
+
+import "fmt"
+
+// ParseSeverity returns the Severity named name. "warn" is accepted for SeverityWarning.
+func ParseSeverity(name string) (Severity, error) {
+	switch name {
+	case "info":
+		return SeverityInfo, nil
+	case "warn", "warning":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	default:
+		return 0, fmt.Errorf("unknown severity %q: expected info, warn or error", name)
+	}
+}
+
+// ParseOverrides parses per-rule severity overrides, such as those of a config file,
+// from rule names to severity names.
+func ParseOverrides(overrides map[string]string) (map[string]Severity, error) {
+	parsed := make(map[string]Severity, len(overrides))
+	for rule, name := range overrides {
+		if !knownRule(rule) {
+			return nil, fmt.Errorf("unknown rule %q in severity overrides", rule)
+		}
+		severity, err := ParseSeverity(name)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule, err)
+		}
+		parsed[rule] = severity
+	}
+	return parsed, nil
+}
+
+// knownRule reports whether rule is the identifier of a rule.
+func knownRule(rule string) bool {
+	for _, r := range rules {
+		if r.ID == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the findings with the severities of their rules overridden by
+// overrides, without those below min.
+func Apply(findings []Finding, overrides map[string]Severity, min Severity) []Finding {
+	var applied []Finding
+	for _, finding := range findings {
+		if severity, ok := overrides[finding.Rule]; ok {
+			finding.Severity = severity
+		}
+		if finding.Severity >= min {
+			applied = append(applied, finding)
+		}
+	}
+	return applied
+}
@@ -0,0 +1,122 @@
+// Package listen opens the listeners the serve command accepts connections on: TCP
+// addresses, Unix domain sockets, and listeners inherited from a parent process such as
+// systemd, so that the service can run as a sidecar that does not expose a TCP port.
+package listen
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// sdListenFDsStart is the first file descriptor passed by systemd socket activation.
+const sdListenFDsStart = 3
+
+// Listen opens the listener described by spec:
+//
+//	host:port       a TCP address, such as ":8080" or "127.0.0.1:8080"
+//	unix:path       a Unix domain socket at path
+//	fd:N            the listening socket inherited as file descriptor N
+//	systemd         the only socket passed by systemd socket activation
+//	systemd:name    the socket named name (FileDescriptorName=) by systemd
+//
+// A stale Unix socket left behind by a previous process is removed; any other file at
+// the path is an error.
+func Listen(spec string) (net.Listener, error) {
+	kind, value, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "unix":
+		return listenUnix(value)
+	case "fd":
+		fd, err := strconv.Atoi(value)
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid listener %q: expected fd:N", spec)
+		}
+		return fileListener(fd)
+	case "systemd":
+		fd, err := systemdFD(value, os.Getenv, os.Getpid())
+		if err != nil {
+			return nil, err
+		}
+		return fileListener(fd)
+	default:
+		return net.Listen("tcp", spec)
+	}
+}
+
+// listenUnix listens on a Unix domain socket at path.
+func listenUnix(path string) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("invalid listener \"unix:\": expected unix:path")
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("cannot listen on %s: the file exists and is not a socket", path)
+		}
+		// Nothing is listening on a socket that refuses connections
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("cannot listen on %s: another process is listening on it", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// fileListener returns a listener for the inherited socket fd.
+func fileListener(fd int) (net.Listener, error) {
+	f := os.NewFile(uintptr(fd), "fd:"+strconv.Itoa(fd))
+	if f == nil {
+		return nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	defer f.Close()
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("file descriptor %d is not a listening socket: %w", fd, err)
+	}
+	return listener, nil
+}
+
+// systemdFD returns the file descriptor of the socket passed by systemd socket activation
+// with the given name, or of the only socket when name is empty. It follows the
+// sd_listen_fds protocol: LISTEN_PID must be this process and LISTEN_FDS the number of
+// sockets, named in order by LISTEN_FDNAMES.
+func systemdFD(name string, getenv func(string) string, pid int) (int, error) {
+	if getenv("LISTEN_PID") != strconv.Itoa(pid) {
+		return 0, errors.New("no sockets were passed by systemd (LISTEN_PID is not this process)")
+	}
+	count, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return 0, errors.New("no sockets were passed by systemd (LISTEN_FDS is not set)")
+	}
+
+	if name == "" {
+		if count != 1 {
+			return 0, fmt.Errorf("systemd passed %d sockets; select one with systemd:name", count)
+		}
+		return sdListenFDsStart, nil
+	}
+	names := strings.Split(getenv("LISTEN_FDNAMES"), ":")
+	for i := 0; i < count && i < len(names); i++ {
+		if names[i] == name {
+			return sdListenFDsStart + i, nil
+		}
+	}
+	return 0, fmt.Errorf("systemd passed no socket named %q", name)
+}
+
+// Describe returns a description of a listener for logs: its address, with the network
+// for anything other than TCP.
+func Describe(listener net.Listener) string {
+	addr := listener.Addr()
+	if addr.Network() == "tcp" {
+		return addr.String()
+	}
+	return addr.Network() + ":" + addr.String()
+}
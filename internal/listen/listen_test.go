@@ -0,0 +1,116 @@
+package listen
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestListen tests opening TCP, Unix and inherited listeners.
+func TestListen(t *testing.T) {
+	t.Run("TCP", func(t *testing.T) {
+		listener, err := Listen("127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen returned an error: %v", err)
+		}
+		defer listener.Close()
+		if !strings.HasPrefix(Describe(listener), "127.0.0.1:") {
+			t.Errorf("Unexpected description %q", Describe(listener))
+		}
+	})
+
+	t.Run("Unix", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "api.sock")
+		listener, err := Listen("unix:" + path)
+		if err != nil {
+			t.Fatalf("Listen returned an error: %v", err)
+		}
+		if Describe(listener) != "unix:"+path {
+			t.Errorf("Unexpected description %q", Describe(listener))
+		}
+
+		// A socket in use is not taken over
+		if _, err := Listen("unix:" + path); err == nil {
+			t.Error("Expected an error for a socket another listener is using")
+		}
+		listener.Close()
+
+		// A stale socket is replaced
+		stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+		if err != nil {
+			t.Fatalf("ListenUnix returned an error: %v", err)
+		}
+		stale.SetUnlinkOnClose(false)
+		stale.Close()
+		listener, err = Listen("unix:" + path)
+		if err != nil {
+			t.Fatalf("Listen returned an error for a stale socket: %v", err)
+		}
+		listener.Close()
+
+		// Other files are never removed
+		file := filepath.Join(t.TempDir(), "file")
+		os.WriteFile(file, nil, 0o600)
+		if _, err := Listen("unix:" + file); err == nil {
+			t.Error("Expected an error for a regular file")
+		}
+	})
+
+	t.Run("Inherited", func(t *testing.T) {
+		tcp, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen returned an error: %v", err)
+		}
+		defer tcp.Close()
+		f, err := tcp.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatalf("File returned an error: %v", err)
+		}
+		defer f.Close()
+
+		listener, err := Listen(fmt.Sprintf("fd:%d", f.Fd()))
+		if err != nil {
+			t.Fatalf("Listen returned an error: %v", err)
+		}
+		defer listener.Close()
+		if listener.Addr().String() != tcp.Addr().String() {
+			t.Errorf("Expected the inherited listener on %s, got %s", tcp.Addr(), listener.Addr())
+		}
+
+		if _, err := Listen("fd:x"); err == nil {
+			t.Error("Expected an error for an invalid file descriptor")
+		}
+	})
+}
+
+// TestSystemdFD tests selecting sockets passed by systemd socket activation.
+func TestSystemdFD(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+	two := env(map[string]string{"LISTEN_PID": "42", "LISTEN_FDS": "2", "LISTEN_FDNAMES": "rest:grpc"})
+
+	tests := []struct {
+		name     string
+		getenv   func(string) string
+		expected int
+		ok       bool
+	}{
+		{"", env(map[string]string{"LISTEN_PID": "42", "LISTEN_FDS": "1"}), 3, true},
+		{"grpc", two, 4, true},
+		{"rest", two, 3, true},
+		{"", two, 0, false},
+		{"admin", two, 0, false},
+		{"", env(map[string]string{"LISTEN_PID": "7", "LISTEN_FDS": "1"}), 0, false},
+		{"", env(map[string]string{"LISTEN_PID": "42"}), 0, false},
+	}
+	for i, tt := range tests {
+		fd, err := systemdFD(tt.name, tt.getenv, 42)
+		if (err == nil) != tt.ok || fd != tt.expected {
+			t.Errorf("Case %d: systemdFD(%q) = %d, %v; want %d", i, tt.name, fd, err, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+// Command openapigen generates the schema types and operation methods of the Go client in
+// pkg/client from the OpenAPI document embedded in the server, so that the client cannot
+// drift from the API it calls. Run it with "make generate" or "go generate ./pkg/client".
+//
+// It supports the subset of OpenAPI the API uses: GET operations with string query
+// parameters, and object schemas whose properties are strings, integers, booleans,
+// string arrays or references to other schemas.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/developmeh/passkey-origin-validator/internal/server"
+)
+
+// Document is the part of an OpenAPI 3 document the generator reads.
+type Document struct {
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// Operation is an OpenAPI operation.
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Description string              `json:"description"`
+	Parameters  []Parameter         `json:"parameters"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter is an OpenAPI operation parameter.
+type Parameter struct {
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required"`
+	Schema   Schema `json:"schema"`
+}
+
+// Response is an OpenAPI response.
+type Response struct {
+	Content map[string]struct {
+		Schema Schema `json:"schema"`
+	} `json:"content"`
+}
+
+// Schema is an OpenAPI schema.
+type Schema struct {
+	Ref         string     `json:"$ref"`
+	Type        string     `json:"type"`
+	Description string     `json:"description"`
+	Required    []string   `json:"required"`
+	Properties  Properties `json:"properties"`
+	Items       *Schema    `json:"items"`
+	Enum        []string   `json:"enum"`
+}
+
+// Property is a property of an object schema.
+type Property struct {
+	Name   string
+	Schema Schema
+}
+
+// Properties are the properties of an object schema, in the order the document lists
+// them, so that the fields of generated structs follow the document.
+type Properties []Property
+
+// UnmarshalJSON decodes a properties object, keeping the order of its members.
+func (p *Properties) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name, _ := key.(string)
+		var schema Schema
+		if err := dec.Decode(&schema); err != nil {
+			return err
+		}
+		*p = append(*p, Property{Name: name, Schema: schema})
+	}
+	return nil
+}
+
+// schemaPrefix is the prefix of references to component schemas.
+const schemaPrefix = "#/components/schemas/"
+
+// initialisms are the words written in upper case in Go identifiers.
+var initialisms = map[string]bool{"API": true, "HTTP": true, "ID": true, "JSON": true, "OK": true, "URL": true}
+
+func main() {
+	pkg := flag.String("package", "client", "Package name of the generated file")
+	out := flag.String("out", "api.gen.go", "File to write")
+	flag.Parse()
+
+	src, err := Generate(server.OpenAPI, *pkg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Generate returns the Go source of the client types and methods for the OpenAPI document
+// doc, in package pkg. The methods call the get method of the hand-written Client.
+func Generate(doc []byte, pkg string) ([]byte, error) {
+	var d Document
+	if err := json.Unmarshal(doc, &d); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+
+	var b bytes.Buffer
+
+	for _, name := range sortedKeys(d.Components.Schemas) {
+		if err := writeSchema(&b, name, d.Components.Schemas[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	operations, err := operations(d)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range operations {
+		if err := writeOperation(&b, op); err != nil {
+			return nil, err
+		}
+	}
+
+	// Import only the packages the methods use
+	var imports []string
+	if len(operations) > 0 {
+		imports = append(imports, `"context"`)
+	}
+	if bytes.Contains(b.Bytes(), []byte("url.Values")) {
+		imports = append(imports, `"net/url"`)
+	}
+	var header bytes.Buffer
+	fmt.Fprintf(&header, "// Code generated by openapigen from internal/server/openapi.json. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&header, "package %s\n", pkg)
+	if len(imports) > 0 {
+		fmt.Fprintf(&header, "\nimport (\n\t%s\n)\n", strings.Join(imports, "\n\t"))
+	}
+
+	src, err := format.Source(append(header.Bytes(), b.Bytes()...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// operation is an operation with the path and method it is served at.
+type operation struct {
+	Operation
+	Path   string
+	Method string
+	// Result is the name of the schema of the successful response.
+	Result string
+}
+
+// operations returns the operations that have a schema for their successful response,
+// sorted by operation ID. Others, such as the OpenAPI document itself, are skipped.
+func operations(d Document) ([]operation, error) {
+	var ops []operation
+	for path, methods := range d.Paths {
+		for method, op := range methods {
+			ref := op.Responses["200"].Content["application/json"].Schema.Ref
+			if ref == "" {
+				continue
+			}
+			if method != "get" {
+				return nil, fmt.Errorf("%s %s: only GET operations are supported", strings.ToUpper(method), path)
+			}
+			if op.OperationID == "" {
+				return nil, fmt.Errorf("GET %s: missing operationId", path)
+			}
+			ops = append(ops, operation{Operation: op, Path: path, Method: method, Result: strings.TrimPrefix(ref, schemaPrefix)})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].OperationID < ops[j].OperationID })
+	return ops, nil
+}
+
+// writeSchema writes the struct type for an object schema, and constants for the values
+// of its enum properties.
+func writeSchema(b *bytes.Buffer, name string, schema Schema) error {
+	if schema.Type != "object" {
+		return fmt.Errorf("schema %s: only object schemas are supported", name)
+	}
+	required := make(map[string]bool)
+	for _, prop := range schema.Required {
+		required[prop] = true
+	}
+
+	fmt.Fprintf(b, "\n")
+	if schema.Description != "" {
+		writeComment(b, "", name+" is "+lowerFirst(schema.Description)+".")
+	} else {
+		writeComment(b, "", fmt.Sprintf("%s is the %s schema of the API.", name, name))
+	}
+	fmt.Fprintf(b, "type %s struct {\n", name)
+	var enums []string
+	for _, property := range schema.Properties {
+		prop, propSchema := property.Name, property.Schema
+		goType, err := goType(propSchema)
+		if err != nil {
+			return fmt.Errorf("schema %s property %s: %w", name, prop, err)
+		}
+		field := exportedName(prop)
+		if propSchema.Description != "" {
+			writeComment(b, "\t", field+" is "+lowerFirst(propSchema.Description)+".")
+		}
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(b, "\t%s %s `json:%q`\n", field, goType, tag)
+
+		for _, value := range propSchema.Enum {
+			enums = append(enums, fmt.Sprintf("\t%s%s = %q\n", field, exportedName(value), value))
+		}
+	}
+	fmt.Fprintf(b, "}\n")
+
+	if len(enums) > 0 {
+		fmt.Fprintf(b, "\n// Values of the enum properties of %s.\nconst (\n%s)\n", name, strings.Join(enums, ""))
+	}
+	return nil
+}
+
+// writeOperation writes the Client method that calls an operation.
+func writeOperation(b *bytes.Buffer, op operation) error {
+	name := exportedName(op.OperationID)
+	params := []string{"ctx context.Context"}
+	var query strings.Builder
+	for _, param := range op.Parameters {
+		if param.In != "query" || param.Schema.Type != "string" {
+			return fmt.Errorf("operation %s parameter %s: only string query parameters are supported", op.OperationID, param.Name)
+		}
+		arg := paramName(param.Name)
+		params = append(params, arg+" string")
+		if param.Required {
+			fmt.Fprintf(&query, "\tquery.Set(%q, %s)\n", param.Name, arg)
+		} else {
+			fmt.Fprintf(&query, "\tif %s != \"\" {\n\t\tquery.Set(%q, %s)\n\t}\n", arg, param.Name, arg)
+		}
+	}
+
+	fmt.Fprintf(b, "\n")
+	comment := fmt.Sprintf("%s calls %s %s to %s.", name, strings.ToUpper(op.Method), op.Path, lowerFirst(op.Summary))
+	if op.Description != "" {
+		comment += "\n" + op.Description
+	}
+	writeComment(b, "", comment)
+	fmt.Fprintf(b, "func (c *Client) %s(%s) (*%s, error) {\n", name, strings.Join(params, ", "), op.Result)
+	fmt.Fprintf(b, "\tvar resp %s\n", op.Result)
+	queryArg := "nil"
+	if query.Len() > 0 {
+		fmt.Fprintf(b, "\tquery := url.Values{}\n%s", query.String())
+		queryArg = "query"
+	}
+	fmt.Fprintf(b, "\tif err := c.get(ctx, %q, %s, &resp); err != nil {\n\t\treturn nil, err\n\t}\n", op.Path, queryArg)
+	fmt.Fprintf(b, "\treturn &resp, nil\n}\n")
+	return nil
+}
+
+// goType returns the Go type of a schema.
+func goType(schema Schema) (string, error) {
+	if schema.Ref != "" {
+		return strings.TrimPrefix(schema.Ref, schemaPrefix), nil
+	}
+	switch schema.Type {
+	case "string":
+		return "string", nil
+	case "integer":
+		return "int", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if schema.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := goType(*schema.Items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	}
+	return "", fmt.Errorf("unsupported type %q", schema.Type)
+}
+
+// writeComment writes text as a Go comment at the given indentation, wrapping it at
+// about 90 columns. Lines in text start new paragraphs.
+func writeComment(b *bytes.Buffer, indent, text string) {
+	for i, paragraph := range strings.Split(text, "\n") {
+		if i > 0 {
+			fmt.Fprintf(b, "%s//\n", indent)
+		}
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && len(indent)+len(line)+len(word) > 86 {
+				fmt.Fprintf(b, "%s// %s\n", indent, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		fmt.Fprintf(b, "%s// %s\n", indent, line)
+	}
+}
+
+// exportedName converts a snake_case, kebab-case or SCREAMING_CASE name to an exported Go
+// identifier, such as "label_count" to "LabelCount" and "JSON_PARSE_ERROR" to
+// "JSONParseError".
+func exportedName(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		upper := strings.ToUpper(word)
+		if initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(upper[:1] + strings.ToLower(word[1:]))
+	}
+	return b.String()
+}
+
+// paramName converts a parameter name to an unexported Go identifier.
+func paramName(name string) string {
+	exported := exportedName(name)
+	param := strings.ToLower(exported[:1]) + exported[1:]
+	if initialisms[exported] {
+		param = strings.ToLower(exported)
+	}
+	if token.IsKeyword(param) {
+		param += "Param"
+	}
+	return param
+}
+
+// lowerFirst lower-cases the first letter of a sentence, unless it starts an acronym.
+func lowerFirst(s string) string {
+	if len(s) > 1 && unicode.IsUpper(rune(s[1])) {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
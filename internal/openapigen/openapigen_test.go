@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/server"
+)
+
+// TestGenerate tests generating types and methods from an OpenAPI document.
+func TestGenerate(t *testing.T) {
+	t.Run("Generated client is current", func(t *testing.T) {
+		src, err := Generate(server.OpenAPI, "client")
+		if err != nil {
+			t.Fatalf("Generate returned an error: %v", err)
+		}
+		current, err := os.ReadFile("../../pkg/client/api.gen.go")
+		if err != nil {
+			t.Fatalf("Failed to read the generated client: %v", err)
+		}
+		if !bytes.Equal(src, current) {
+			t.Error("pkg/client/api.gen.go is out of date with internal/server/openapi.json; run make generate")
+		}
+	})
+
+	t.Run("Document", func(t *testing.T) {
+		doc := `{
+			"paths": {
+				"/v1/lookup": {"get": {
+					"operationId": "lookup_item",
+					"summary": "Look up an item",
+					"parameters": [
+						{"name": "item_id", "in": "query", "required": true, "schema": {"type": "string"}},
+						{"name": "type", "in": "query", "schema": {"type": "string"}}
+					],
+					"responses": {"200": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Item"}}}}}
+				}}
+			},
+			"components": {"schemas": {
+				"Item": {"type": "object", "required": ["id"], "properties": {
+					"id": {"type": "string", "description": "The item's ID"},
+					"size": {"type": "integer"},
+					"kind": {"type": "string", "enum": ["SMALL_BOX"]},
+					"parts": {"type": "array", "items": {"$ref": "#/components/schemas/Part"}}
+				}},
+				"Part": {"type": "object", "properties": {"ok": {"type": "boolean"}}}
+			}}
+		}`
+		src, err := Generate([]byte(doc), "items")
+		if err != nil {
+			t.Fatalf("Generate returned an error: %v", err)
+		}
+		for _, want := range []string{
+			"package items",
+			"// ID is the item's ID.",
+			"ID    string `json:\"id\"`",
+			"OK bool `json:\"ok,omitempty\"`",
+			"Size  int    `json:\"size,omitempty\"`",
+			"Parts []Part `json:\"parts,omitempty\"`",
+			`KindSmallBox = "SMALL_BOX"`,
+			"// LookupItem calls GET /v1/lookup to look up an item.",
+			"func (c *Client) LookupItem(ctx context.Context, itemID string, typeParam string) (*Item, error) {",
+			`query.Set("item_id", itemID)`,
+			"if typeParam != \"\" {",
+		} {
+			if !strings.Contains(string(src), want) {
+				t.Errorf("Expected the generated code to contain %q, got:\n%s", want, src)
+			}
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		for _, doc := range []string{
+			`{"paths": {"/x": {"post": {"operationId": "x", "responses": {"200": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/X"}}}}}}}}}`,
+			`{"components": {"schemas": {"X": {"type": "object", "properties": {"n": {"type": "number"}}}}}}`,
+			`{"components": {"schemas": {"X": {"type": "string"}}}}`,
+			`not json`,
+		} {
+			if _, err := Generate([]byte(doc), "x"); err == nil {
+				t.Errorf("Expected an error for %s", doc)
+			}
+		}
+	})
+}
@@ -0,0 +1,287 @@
+// Package orgs rolls up the results of many relying party domains to the organizations
+// that own them, for governance reports that look at properties as a whole.
+//
+// An organizations file declares which domains belong to which organization:
+//
+//	organizations:
+//	  - name: Example Corp
+//	    domains:
+//	      - example.com
+//	      - example.co.uk
+//	      - login.example.net
+//
+// A domain belongs to at most one organization. Organizations files may be written in
+// YAML or JSON.
+package orgs
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+	"github.com/developmeh/passkey-origin-validator/internal/forecast"
+	"gopkg.in/yaml.v3"
+)
+
+// Organizations is a set of organizations and the domains they own.
+type Organizations struct {
+	Organizations []Organization `yaml:"organizations"`
+}
+
+// Organization is one owner of relying party domains.
+type Organization struct {
+	Name    string   `yaml:"name"`
+	Domains []string `yaml:"domains"`
+}
+
+// Load reads an organizations file.
+func Load(path string) (*Organizations, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read organizations: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses organizations written in YAML or JSON.
+func Parse(data []byte) (*Organizations, error) {
+	var o Organizations
+	if err := yaml.Unmarshal(data, &o); err != nil {
+		return nil, fmt.Errorf("failed to parse organizations: %w", err)
+	}
+	owners := make(map[string]string)
+	for i, org := range o.Organizations {
+		if org.Name == "" {
+			return nil, fmt.Errorf("failed to parse organizations: organizations[%d] has no name", i)
+		}
+		for _, domain := range org.Domains {
+			key := domainKey(domain)
+			if owner, ok := owners[key]; ok {
+				return nil, fmt.Errorf("failed to parse organizations: %s belongs to both %q and %q", domain, owner, org.Name)
+			}
+			owners[key] = org.Name
+		}
+	}
+	return &o, nil
+}
+
+// domainKey returns the form domains are matched in, the way results are merged.
+func domainKey(domain string) string {
+	return strings.ToLower(strings.TrimSpace(domain))
+}
+
+// Report is the rollup of the results of one organization's domains.
+type Report struct {
+	Name string
+	// Domains is the number of domains the organization declares.
+	Domains int
+	// Checked is the number of its domains whose documents were fetched.
+	Checked      int
+	ExceedsLimit int
+	Invalid      int
+	Failed       int
+	// Missing is the number of declared domains without a result, including skipped ones.
+	Missing int
+	// TotalLabels is the sum of the label counts of the documents that were checked.
+	TotalLabels int
+	// Labels are the distinct labels used across all of the organization's documents.
+	Labels []string
+	// SharedOrigins are the origins listed in the documents of more than one of the
+	// organization's domains.
+	SharedOrigins []SharedOrigin
+	// Forecast is when the organization's documents reach a limit, if a growth forecast
+	// is configured.
+	Forecast *Forecast
+}
+
+// Forecast is when the documents of an organization reach a limit, in quarters from Start.
+type Forecast struct {
+	Start time.Time
+	// Domains are the domains whose document reaches a limit within the horizon, soonest
+	// first.
+	Domains []DomainForecast
+}
+
+// DomainForecast is when the document of a domain reaches each limit.
+type DomainForecast struct {
+	Domain string
+	forecast.Projection
+}
+
+// soonest returns the number of quarters until the first limit is reached.
+func (d DomainForecast) soonest() int {
+	if d.LabelLimit == forecast.Never || (d.SizeLimit != forecast.Never && d.SizeLimit < d.LabelLimit) {
+		return d.SizeLimit
+	}
+	return d.LabelLimit
+}
+
+// SharedOrigin is an origin listed by several domains of the same organization.
+type SharedOrigin struct {
+	Origin  string
+	Domains []string
+}
+
+// Rollup collects the latest record of every domain that belongs to an organization.
+// It holds one record per declared domain, however many records are added.
+type Rollup struct {
+	orgs *Organizations
+	// records maps the key of each declared domain to its latest record, if any.
+	records map[string]*batch.Record
+	// ungrouped holds the keys of the domains added that belong to no organization.
+	ungrouped map[string]bool
+	// growth and start configure the forecast, if growth is set.
+	growth *forecast.Growth
+	start  time.Time
+}
+
+// NewRollup returns an empty Rollup for orgs.
+func NewRollup(orgs *Organizations) *Rollup {
+	r := &Rollup{orgs: orgs, records: make(map[string]*batch.Record), ungrouped: make(map[string]bool)}
+	for _, org := range orgs.Organizations {
+		for _, domain := range org.Domains {
+			r.records[domainKey(domain)] = nil
+		}
+	}
+	return r
+}
+
+// Add adds a record. When a domain has several records, a checked record supersedes one
+// that was skipped, and otherwise the latest one is kept.
+func (r *Rollup) Add(record batch.Record) {
+	key := domainKey(record.Domain)
+	existing, ok := r.records[key]
+	if !ok {
+		r.ungrouped[key] = true
+		return
+	}
+	if existing == nil || (existing.Skipped && !record.Skipped) ||
+		(existing.Skipped == record.Skipped && record.Timestamp.After(existing.Timestamp)) {
+		r.records[key] = &record
+	}
+}
+
+// Forecast makes reports include when each organization's documents reach a limit with
+// growth, in quarters from start.
+func (r *Rollup) Forecast(growth forecast.Growth, start time.Time) {
+	r.growth = &growth
+	r.start = start
+}
+
+// Ungrouped returns the number of distinct domains added that belong to no organization.
+func (r *Rollup) Ungrouped() int {
+	return len(r.ungrouped)
+}
+
+// Reports returns the report of every organization, in the order they were declared.
+func (r *Rollup) Reports() []Report {
+	reports := make([]Report, 0, len(r.orgs.Organizations))
+	for _, org := range r.orgs.Organizations {
+		report := Report{Name: org.Name, Domains: len(org.Domains)}
+		if r.growth != nil {
+			report.Forecast = &Forecast{Start: r.start}
+		}
+		labels := make(map[string]bool)
+		listedBy := make(map[string][]string)
+		for _, domain := range org.Domains {
+			record := r.records[domainKey(domain)]
+			switch {
+			case record == nil || record.Skipped:
+				report.Missing++
+				continue
+			case record.Failed():
+				report.Failed++
+				continue
+			}
+			report.Checked++
+			if record.ExceedsLimit {
+				report.ExceedsLimit++
+			}
+			if record.Invalid() {
+				report.Invalid++
+			}
+			report.TotalLabels += record.Count
+			if report.Forecast != nil {
+				projection := forecast.Project(record.Origins, record.Count, record.MaxLabels, *r.growth)
+				if projection.Reached() {
+					report.Forecast.Domains = append(report.Forecast.Domains, DomainForecast{Domain: domain, Projection: projection})
+				}
+			}
+			for _, label := range record.Labels {
+				labels[label] = true
+			}
+			// Count each origin once per domain, even if a document lists it twice
+			seen := make(map[string]bool)
+			for _, origin := range record.Origins {
+				if !seen[origin] {
+					seen[origin] = true
+					listedBy[origin] = append(listedBy[origin], domain)
+				}
+			}
+		}
+
+		for label := range labels {
+			report.Labels = append(report.Labels, label)
+		}
+		sort.Strings(report.Labels)
+		for origin, domains := range listedBy {
+			if len(domains) > 1 {
+				report.SharedOrigins = append(report.SharedOrigins, SharedOrigin{Origin: origin, Domains: domains})
+			}
+		}
+		sort.Slice(report.SharedOrigins, func(i, j int) bool {
+			return report.SharedOrigins[i].Origin < report.SharedOrigins[j].Origin
+		})
+		if report.Forecast != nil {
+			sort.SliceStable(report.Forecast.Domains, func(i, j int) bool {
+				return report.Forecast.Domains[i].soonest() < report.Forecast.Domains[j].soonest()
+			})
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// FormatReports formats organization reports into a human-readable string.
+func FormatReports(reports []Report) string {
+	var sb strings.Builder
+	for _, report := range reports {
+		sb.WriteString(fmt.Sprintf("%s: %d domains, %d checked\n", report.Name, report.Domains, report.Checked))
+		sb.WriteString(fmt.Sprintf("  Exceeds limit: %d\n", report.ExceedsLimit))
+		sb.WriteString(fmt.Sprintf("  Invalid origin: %d\n", report.Invalid))
+		sb.WriteString(fmt.Sprintf("  Failed: %d\n", report.Failed))
+		sb.WriteString(fmt.Sprintf("  Missing: %d\n", report.Missing))
+		sb.WriteString(fmt.Sprintf("  Labels: %d in total, %d distinct", report.TotalLabels, len(report.Labels)))
+		if len(report.Labels) > 0 {
+			sb.WriteString(fmt.Sprintf(" (%s)", strings.Join(report.Labels, ", ")))
+		}
+		sb.WriteString("\n")
+		if len(report.SharedOrigins) > 0 {
+			sb.WriteString("  Shared origins:\n")
+			for _, shared := range report.SharedOrigins {
+				sb.WriteString(fmt.Sprintf("    %s: %s\n", shared.Origin, strings.Join(shared.Domains, ", ")))
+			}
+		}
+		if f := report.Forecast; f != nil {
+			if len(f.Domains) == 0 {
+				sb.WriteString("  Forecast: no domain reaches a limit\n")
+				continue
+			}
+			sb.WriteString("  Forecast:\n")
+			for _, domain := range f.Domains {
+				var limits []string
+				if domain.LabelLimit != forecast.Never {
+					limits = append(limits, "label limit "+forecast.When(f.Start, domain.LabelLimit))
+				}
+				if domain.SizeLimit != forecast.Never {
+					limits = append(limits, "size limit "+forecast.When(f.Start, domain.SizeLimit))
+				}
+				sb.WriteString(fmt.Sprintf("    %s: %s\n", domain.Domain, strings.Join(limits, ", ")))
+			}
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,104 @@
+package orgs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+	"github.com/developmeh/passkey-origin-validator/internal/forecast"
+)
+
+// TestParse tests reading organizations files.
+func TestParse(t *testing.T) {
+	o, err := Parse([]byte(`
+organizations:
+  - name: Example Corp
+    domains: [example.com, example.co.uk]
+  - name: Other
+    domains: [other.com]
+`))
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+	if len(o.Organizations) != 2 || len(o.Organizations[0].Domains) != 2 {
+		t.Errorf("Unexpected organizations %+v", o)
+	}
+
+	if _, err := Parse([]byte(`{"organizations": [{"domains": ["a.com"]}]}`)); err == nil {
+		t.Error("Expected an error for an organization without a name")
+	}
+	_, err = Parse([]byte(`{"organizations": [{"name": "A", "domains": ["a.com"]}, {"name": "B", "domains": ["A.com"]}]}`))
+	if err == nil || !strings.Contains(err.Error(), `both "A" and "B"`) {
+		t.Errorf("Expected an error for a domain in two organizations, got %v", err)
+	}
+}
+
+// TestRollup tests rolling up records to their organizations.
+func TestRollup(t *testing.T) {
+	o, _ := Parse([]byte(`
+organizations:
+  - name: Example Corp
+    domains: [example.com, example.co.uk, example.net, example.org]
+  - name: Empty
+    domains: [empty.com]
+`))
+	at := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	r := NewRollup(o)
+	for _, record := range []batch.Record{
+		{Domain: "example.com", Timestamp: at, Count: 2, Labels: []string{"example.", "shop."},
+			Origins: []string{"https://example.co.uk", "https://shop.com"}},
+		{Domain: "Example.co.uk", Timestamp: at, Count: 6, ExceedsLimit: true, Labels: []string{"example.", "a.", "b.", "c.", "d.", "e."},
+			Origins: []string{"https://example.co.uk", "https://shop.com", "https://shop.com"}, Origin: "https://x.com", Status: "BAD_RELYING_PARTY_ID_NO_JSON_MATCH"},
+		// The later record of a domain wins
+		{Domain: "example.net", Timestamp: at, Count: 1, Labels: []string{"example."}},
+		{Domain: "example.net", Timestamp: at.Add(time.Hour), Error: "timeout"},
+		{Domain: "example.org", Timestamp: at, Error: "budget exhausted", Skipped: true},
+		{Domain: "unknown.com", Timestamp: at},
+	} {
+		r.Add(record)
+	}
+
+	reports := r.Reports()
+	if len(reports) != 2 {
+		t.Fatalf("Expected 2 reports, got %d", len(reports))
+	}
+	report := reports[0]
+	if report.Domains != 4 || report.Checked != 2 || report.ExceedsLimit != 1 || report.Invalid != 1 ||
+		report.Failed != 1 || report.Missing != 1 || report.TotalLabels != 8 || len(report.Labels) != 7 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if len(report.SharedOrigins) != 2 || report.SharedOrigins[1].Origin != "https://shop.com" ||
+		strings.Join(report.SharedOrigins[1].Domains, ",") != "example.com,example.co.uk" {
+		t.Errorf("Unexpected shared origins %+v", report.SharedOrigins)
+	}
+	if reports[1].Missing != 1 || reports[1].Checked != 0 {
+		t.Errorf("Unexpected report %+v", reports[1])
+	}
+	if r.Ungrouped() != 1 {
+		t.Errorf("Expected 1 ungrouped domain, got %d", r.Ungrouped())
+	}
+
+	text := FormatReports(reports)
+	for _, line := range []string{
+		"Example Corp: 4 domains, 2 checked\n",
+		"  Labels: 8 in total, 7 distinct (a., b., c., d., e., example., shop.)\n",
+		"    https://shop.com: example.com, example.co.uk\n",
+		"Empty: 1 domains, 0 checked\n",
+	} {
+		if !strings.Contains(text, line) {
+			t.Errorf("Expected report to contain %q, got:\n%s", line, text)
+		}
+	}
+	// With a forecast, documents that reach a limit are listed soonest first
+	r.Forecast(forecast.Growth{OriginsPerQuarter: 2, NewLabelsPerQuarter: 1}, at)
+	text = FormatReports(r.Reports())
+	for _, line := range []string{
+		"  Forecast:\n    example.co.uk: label limit now\n    example.com: label limit 2024 Q4 (in 3 quarters)\n",
+		"Empty: 1 domains, 0 checked\n  Exceeds limit: 0\n  Invalid origin: 0\n  Failed: 0\n  Missing: 1\n  Labels: 0 in total, 0 distinct\n  Forecast: no domain reaches a limit\n",
+	} {
+		if !strings.Contains(text, line) {
+			t.Errorf("Expected report to contain %q, got:\n%s", line, text)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Package policy evaluates .well-known/webauthn documents against a declared expected state.
+//
+// A policy file lists domains and what each one's document must look like:
+//
+//	domains:
+//	  - domain: example.com
+//	    origins:
+//	      - https://example.com
+//	      - https://example.co.uk
+//	    authorize:
+//	      - https://example.co.uk
+//	    max_labels: 5
+//	    content_type: application/json
+//
+// Every check is optional. Policy files may be written in YAML or JSON.
+package policy
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/generate"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is the expected state of a set of domains.
+type Policy struct {
+	Domains []Domain `yaml:"domains"`
+}
+
+// Domain is the expected state of one domain's document.
+type Domain struct {
+	Domain string `yaml:"domain"`
+	// Origins, if set, is exactly the set of origins the document must list.
+	Origins []string `yaml:"origins"`
+	// Authorize are caller origins the document must authorize.
+	Authorize []string `yaml:"authorize"`
+	// MaxLabels, if set, is the maximum number of unique labels.
+	MaxLabels *int `yaml:"max_labels"`
+	// ContentType, if set, is the media type the document must be served with.
+	ContentType string `yaml:"content_type"`
+}
+
+// Check names reported in violations.
+const (
+	CheckFetch       = "fetch"
+	CheckOrigins     = "origins"
+	CheckAuthorize   = "authorize"
+	CheckMaxLabels   = "max_labels"
+	CheckContentType = "content_type"
+)
+
+// Violation is a difference between a domain's document and its policy.
+type Violation struct {
+	Domain  string `json:"domain"`
+	Check   string `json:"check"`
+	Message string `json:"message"`
+	// Missing are expected origins that the document does not list.
+	Missing []string `json:"missing,omitempty"`
+	// Unexpected are origins that the document lists but the policy does not.
+	Unexpected []string `json:"unexpected,omitempty"`
+}
+
+// Load reads a policy file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses a policy written in YAML or JSON.
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	for i, d := range p.Domains {
+		if d.Domain == "" {
+			return nil, fmt.Errorf("failed to parse policy: domains[%d] has no domain", i)
+		}
+	}
+	return &p, nil
+}
+
+// Evaluate compares a fetched document with the domain's policy and returns every
+// violation. A document that could not be fetched or parsed violates the fetch check,
+// and only its content type is evaluated further.
+func Evaluate(d Domain, result *counter.LabelCount) []Violation {
+	var violations []Violation
+	add := func(v Violation) {
+		v.Domain = d.Domain
+		violations = append(violations, v)
+	}
+
+	// Compare media types, ignoring parameters such as charset
+	if d.ContentType != "" && result.ContentType != "" && mediaType(result.ContentType) != mediaType(d.ContentType) {
+		add(Violation{
+			Check:   CheckContentType,
+			Message: fmt.Sprintf("served as %q, expected %q", result.ContentType, d.ContentType),
+		})
+	}
+
+	if result.ErrorMessage != "" {
+		add(Violation{Check: CheckFetch, Message: result.ErrorMessage})
+		return violations
+	}
+
+	if d.Origins != nil {
+		missing, unexpected := diff(d.Origins, result.Origins)
+		if len(missing) > 0 || len(unexpected) > 0 {
+			add(Violation{
+				Check:      CheckOrigins,
+				Message:    fmt.Sprintf("%d origins missing, %d unexpected", len(missing), len(unexpected)),
+				Missing:    missing,
+				Unexpected: unexpected,
+			})
+		}
+	}
+
+	for _, callerOrigin := range d.Authorize {
+		status := counter.ValidateWellKnownJSONWithMaxLabels(callerOrigin, []byte(result.RawJSON), result.MaxLabels)
+		if status != counter.StatusSuccess {
+			add(Violation{
+				Check:   CheckAuthorize,
+				Message: fmt.Sprintf("%s is not authorized: %s", callerOrigin, status),
+			})
+		}
+	}
+
+	if d.MaxLabels != nil && result.Count > *d.MaxLabels {
+		add(Violation{
+			Check:   CheckMaxLabels,
+			Message: fmt.Sprintf("%d labels, expected at most %d (%s)", result.Count, *d.MaxLabels, strings.Join(result.LabelsFound, ", ")),
+		})
+	}
+
+	return violations
+}
+
+// diff returns the expected origins the document does not list and the listed origins
+// that are not expected, comparing the serialized forms browsers use.
+func diff(expected, listed []string) (missing, unexpected []string) {
+	want := make(map[string]bool)
+	for _, origin := range expected {
+		want[normalize(origin)] = true
+	}
+	have := make(map[string]bool)
+	for _, origin := range listed {
+		have[normalize(origin)] = true
+	}
+
+	for origin := range want {
+		if !have[origin] {
+			missing = append(missing, origin)
+		}
+	}
+	for origin := range have {
+		if !want[origin] {
+			unexpected = append(unexpected, origin)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	return missing, unexpected
+}
+
+// normalize returns the serialized form of origin, or origin itself if it is not valid.
+func normalize(origin string) string {
+	if normalized, err := generate.Normalize(origin); err == nil {
+		return normalized
+	}
+	return origin
+}
+
+// mediaType returns the lowercased media type of a Content-Type value, without parameters.
+func mediaType(contentType string) string {
+	parsed, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		parsed, _, _ = strings.Cut(contentType, ";")
+	}
+	return strings.ToLower(strings.TrimSpace(parsed))
+}
+
+// FormatViolations formats the violations of one domain as a human-readable diff.
+func FormatViolations(violations []Violation) string {
+	var sb strings.Builder
+	for _, v := range violations {
+		sb.WriteString(fmt.Sprintf("  %s: %s\n", v.Check, v.Message))
+		for _, origin := range v.Missing {
+			sb.WriteString(fmt.Sprintf("    - %s\n", origin))
+		}
+		for _, origin := range v.Unexpected {
+			sb.WriteString(fmt.Sprintf("    + %s\n", origin))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,109 @@
+package policy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// TestParse tests reading policies written in YAML and JSON.
+func TestParse(t *testing.T) {
+	yamlPolicy := `
+domains:
+  - domain: example.com
+    origins: [https://example.com]
+    max_labels: 3
+    content_type: application/json
+`
+	p, err := Parse([]byte(yamlPolicy))
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+	if len(p.Domains) != 1 || p.Domains[0].MaxLabels == nil || *p.Domains[0].MaxLabels != 3 {
+		t.Errorf("Unexpected policy %+v", p)
+	}
+
+	jsonPolicy := `{"domains": [{"domain": "example.com", "authorize": ["https://example.co.uk"]}]}`
+	p, err = Parse([]byte(jsonPolicy))
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+	if len(p.Domains[0].Authorize) != 1 || p.Domains[0].Origins != nil {
+		t.Errorf("Unexpected policy %+v", p)
+	}
+
+	if _, err := Parse([]byte("domains:\n  - origins: []\n")); err == nil {
+		t.Error("Expected an error for a domain without a name")
+	}
+}
+
+// TestEvaluate tests comparing live documents with a policy.
+func TestEvaluate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"origins": ["https://example.com", "https://example.co.uk/", "https://other.org"]}`))
+	}))
+	defer server.Close()
+	result, err := counter.CountLabels(server.URL)
+	if err != nil {
+		t.Fatalf("CountLabels returned an error: %v", err)
+	}
+
+	one := 1
+	t.Run("Matching policy", func(t *testing.T) {
+		violations := Evaluate(Domain{
+			Domain:      server.URL,
+			Origins:     []string{"https://other.org", "https://example.co.uk", "https://example.com"},
+			Authorize:   []string{"https://example.co.uk"},
+			ContentType: "application/json",
+		}, result)
+		if len(violations) != 0 {
+			t.Errorf("Expected no violations, got:\n%s", FormatViolations(violations))
+		}
+	})
+
+	t.Run("Drift", func(t *testing.T) {
+		violations := Evaluate(Domain{
+			Domain:      server.URL,
+			Origins:     []string{"https://example.com", "https://example.de"},
+			Authorize:   []string{"https://example.de"},
+			MaxLabels:   &one,
+			ContentType: "text/plain",
+		}, result)
+
+		var checks []string
+		for _, v := range violations {
+			checks = append(checks, v.Check)
+		}
+		if strings.Join(checks, ",") != "content_type,origins,authorize,max_labels" {
+			t.Errorf("Unexpected violations %v", checks)
+		}
+		expected := "  origins: 1 origins missing, 2 unexpected\n" +
+			"    - https://example.de\n" +
+			"    + https://example.co.uk\n" +
+			"    + https://other.org\n"
+		if got := FormatViolations(violations[1:2]); got != expected {
+			t.Errorf("Expected diff:\n%s\ngot:\n%s", expected, got)
+		}
+	})
+
+	t.Run("Label limit of the count", func(t *testing.T) {
+		labels := &counter.LabelCount{
+			RawJSON:   `{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://f.com"]}`,
+			MaxLabels: 6,
+		}
+		if violations := Evaluate(Domain{Domain: "example.com", Authorize: []string{"https://f.com"}}, labels); len(violations) != 0 {
+			t.Errorf("Expected f.com to be authorized within a limit of 6, got:\n%s", FormatViolations(violations))
+		}
+	})
+
+	t.Run("Fetch failure", func(t *testing.T) {
+		violations := Evaluate(Domain{Domain: "example.com", Origins: []string{}}, &counter.LabelCount{ErrorMessage: "HTTP request failed with status code: 404"})
+		if len(violations) != 1 || violations[0].Check != CheckFetch {
+			t.Errorf("Expected a fetch violation, got %+v", violations)
+		}
+	})
+}
@@ -0,0 +1,279 @@
+// Package rego runs Rego policies against .well-known/webauthn documents and reports
+// their violations as lint findings.
+//
+// Policies follow the conventions of conftest: the rules of a namespace, "main" unless
+// configured otherwise, named deny or violation report errors and those named warn
+// report warnings. Each rule yields a message, or an object with a msg and optionally
+// the origin it is about:
+//
+//	package main
+//
+//	deny contains msg if {
+//		some origin in input.document.origins
+//		startswith(origin, "http://")
+//		msg := sprintf("%s is not https", [origin])
+//	}
+//
+//	warn contains {"msg": "more than 3 labels", "origin": ""} if {
+//		input.label_count > 3
+//	}
+//
+// The input is the parsed document together with how it was fetched; see Input.
+// Policies are evaluated in-process by the Open Policy Agent library, so that no opa
+// command needs to be installed.
+package rego
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+	oparego "github.com/open-policy-agent/opa/v1/rego"
+)
+
+// DefaultNamespace is the package the rules are read from when none is configured.
+const DefaultNamespace = "main"
+
+// Evaluator evaluates Rego queries. OPA implements it.
+type Evaluator interface {
+	// Eval evaluates query against the policies of bundle with input, and returns the
+	// value of the query as JSON, or nil if it is undefined.
+	Eval(ctx context.Context, bundle, query string, input []byte) (json.RawMessage, error)
+}
+
+// OPA evaluates queries in-process with the Open Policy Agent library.
+type OPA struct {
+	// DenyNetwork refuses the builtins that reach the network, such as http.send, so
+	// that policies only see their input.
+	DenyNetwork bool
+}
+
+// networkBuiltins are the builtins that reach the network.
+var networkBuiltins = map[string]struct{}{
+	"http.send":          {},
+	"net.lookup_ip_addr": {},
+}
+
+// Eval loads bundle and evaluates query against it.
+func (o OPA) Eval(ctx context.Context, bundle, query string, input []byte) (json.RawMessage, error) {
+	var value any
+	if err := json.Unmarshal(input, &value); err != nil {
+		return nil, fmt.Errorf("failed to read policy input: %w", err)
+	}
+
+	options := []func(*oparego.Rego){oparego.Query(query), oparego.LoadBundle(bundle)}
+	if o.DenyNetwork {
+		options = append(options, oparego.UnsafeBuiltins(networkBuiltins))
+	}
+	prepared, err := oparego.New(options...).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policy bundle %s: %w", bundle, err)
+	}
+	results, err := prepared.Eval(ctx, oparego.EvalInput(value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate policy bundle %s: %w", bundle, err)
+	}
+	if len(results) == 0 || len(results[0].Expressions) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(results[0].Expressions[0].Value)
+}
+
+// Options configures an evaluation.
+type Options struct {
+	// Bundle is the directory of the policies and their data.
+	Bundle string
+	// Namespace is the package the rules are read from. If empty, DefaultNamespace is used.
+	Namespace string
+	// Evaluator evaluates the policies. If nil, OPA is used.
+	Evaluator Evaluator
+}
+
+// Input is what policies see as input.
+type Input struct {
+	// Source is where the document came from: its URL, or the path of a local file.
+	Source string `json:"source"`
+	// Document is the parsed document, or nil if it is not valid JSON.
+	Document any `json:"document"`
+	// Raw is the document as it was read.
+	Raw string `json:"raw"`
+	// Labels are the distinct labels of the origins, in the order browsers see them.
+	Labels     []string `json:"labels"`
+	LabelCount int      `json:"label_count"`
+	MaxLabels  int      `json:"max_labels"`
+	// Fetch is how the document was served.
+	Fetch Fetch `json:"fetch"`
+}
+
+// Fetch is how a document was served.
+type Fetch struct {
+	URL string `json:"url"`
+	// ContentType is the Content-Type header, or "" for a local file.
+	ContentType string `json:"content_type"`
+	// SniffedFormat is the format detected from the body itself, such as "json".
+	SniffedFormat string `json:"sniffed_format"`
+	// Size is the number of bytes read.
+	Size int `json:"size"`
+	// Warnings are problems with how the document was served.
+	Warnings []string `json:"warnings"`
+}
+
+// NewInput returns the input of the document of result.
+func NewInput(result *counter.LabelCount) Input {
+	input := Input{
+		Source:     result.URL,
+		Raw:        result.RawJSON,
+		Labels:     result.LabelsFound,
+		LabelCount: result.Count,
+		MaxLabels:  result.MaxLabels,
+		Fetch: Fetch{
+			URL:           result.URL,
+			ContentType:   result.ContentType,
+			SniffedFormat: result.SniffedFormat,
+			Size:          len(result.RawJSON),
+			Warnings:      result.Warnings,
+		},
+	}
+	if input.MaxLabels == 0 {
+		input.MaxLabels = counter.MaxLabels
+	}
+	if input.Labels == nil {
+		input.Labels = []string{}
+	}
+	if input.Fetch.Warnings == nil {
+		input.Fetch.Warnings = []string{}
+	}
+	// A document that is not valid JSON is left for the policies to inspect as raw text
+	if err := json.Unmarshal([]byte(result.RawJSON), &input.Document); err != nil {
+		input.Document = nil
+	}
+	return input
+}
+
+// severities maps the conftest rule names to the severity of their findings.
+var severities = map[string]lint.Severity{
+	"deny":      lint.SeverityError,
+	"violation": lint.SeverityError,
+	"warn":      lint.SeverityWarning,
+}
+
+// Evaluate runs the policies of opts against the document of result and returns their
+// violations as findings of lint.RulePolicy: errors for deny and violation rules, and
+// warnings for warn rules.
+func Evaluate(ctx context.Context, result *counter.LabelCount, opts Options) ([]lint.Finding, error) {
+	if _, err := os.Stat(opts.Bundle); err != nil {
+		return nil, fmt.Errorf("failed to read policy bundle: %w", err)
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = DefaultNamespace
+	}
+	if opts.Evaluator == nil {
+		opts.Evaluator = OPA{}
+	}
+
+	input, err := json.Marshal(NewInput(result))
+	if err != nil {
+		return nil, err
+	}
+	value, err := opts.Evaluator.Eval(ctx, opts.Bundle, "data."+opts.Namespace, input)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, nil
+	}
+	var rules map[string]json.RawMessage
+	if err := json.Unmarshal(value, &rules); err != nil {
+		return nil, fmt.Errorf("policy namespace %s is not a package", opts.Namespace)
+	}
+
+	// Report errors before warnings, and each rule's messages in a stable order
+	var names []string
+	for name := range rules {
+		if _, ok := severities[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if severities[names[i]] != severities[names[j]] {
+			return severities[names[i]] > severities[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	var findings []lint.Finding
+	for _, name := range names {
+		messages, err := decodeMessages(rules[name])
+		if err != nil {
+			return nil, fmt.Errorf("rule %s.%s: %w", opts.Namespace, name, err)
+		}
+		for _, m := range messages {
+			findings = append(findings, lint.Finding{
+				Rule:     lint.RulePolicy,
+				Severity: severities[name],
+				Index:    originIndex(result.Origins, m.Origin),
+				Origin:   m.Origin,
+				Message:  fmt.Sprintf("%s.%s: %s", opts.Namespace, name, m.Msg),
+				// Several messages may be about the document as a whole, so the message
+				// stands in for the origin they lack
+				Fingerprint: lint.Fingerprint(lint.RulePolicy, result.URL, firstNonEmpty(m.Origin, m.Msg)),
+			})
+		}
+	}
+	return findings, nil
+}
+
+// message is a violation reported by a rule.
+type message struct {
+	Msg    string `json:"msg"`
+	Origin string `json:"origin"`
+}
+
+// decodeMessages decodes the value of a rule: a set of messages or of objects with a
+// msg, sorted by message.
+func decodeMessages(value json.RawMessage) ([]message, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(value, &items); err != nil {
+		return nil, errors.New("expected a set of messages")
+	}
+	var messages []message
+	for _, item := range items {
+		var m message
+		if err := json.Unmarshal(item, &m.Msg); err != nil {
+			if err := json.Unmarshal(item, &m); err != nil || m.Msg == "" {
+				return nil, fmt.Errorf("expected a message or an object with a msg, got %s", item)
+			}
+		}
+		messages = append(messages, m)
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].Msg < messages[j].Msg })
+	return messages, nil
+}
+
+// originIndex returns the position of origin in origins, or -1 if it is not listed.
+func originIndex(origins []string, origin string) int {
+	if origin == "" {
+		return -1
+	}
+	for i, entry := range origins {
+		if entry == origin {
+			return i
+		}
+	}
+	return -1
+}
+
+// firstNonEmpty returns the first of values that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,182 @@
+package rego
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+)
+
+// fakeEvaluator returns a fixed value and records the query and input it was given.
+type fakeEvaluator struct {
+	value string
+	query string
+	input Input
+}
+
+func (f *fakeEvaluator) Eval(ctx context.Context, bundle, query string, input []byte) (json.RawMessage, error) {
+	f.query = query
+	if err := json.Unmarshal(input, &f.input); err != nil {
+		return nil, err
+	}
+	if f.value == "" {
+		return nil, nil
+	}
+	return json.RawMessage(f.value), nil
+}
+
+// newResult returns the result of reading document from a file.
+func newResult(t *testing.T, document string) *counter.LabelCount {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "webauthn.json")
+	if err := os.WriteFile(path, []byte(document), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := counter.CountLabelsFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// TestEvaluate tests turning the rules of a namespace into findings.
+func TestEvaluate(t *testing.T) {
+	result := newResult(t, `{"origins": ["https://a.com", "http://b.com"]}`)
+	evaluator := &fakeEvaluator{value: `{
+		"warn": [{"msg": "more than 1 label", "origin": ""}],
+		"deny": ["http://b.com is not https", {"msg": "b.com is not allowed", "origin": "http://b.com"}],
+		"allow": true
+	}`}
+
+	findings, err := Evaluate(context.Background(), result, Options{Bundle: t.TempDir(), Evaluator: evaluator})
+	if err != nil {
+		t.Fatalf("Evaluate returned an error: %v", err)
+	}
+	if evaluator.query != "data.main" {
+		t.Errorf("Expected the main namespace to be queried, got %s", evaluator.query)
+	}
+	if evaluator.input.LabelCount != 2 || evaluator.input.MaxLabels != counter.MaxLabels || evaluator.input.Document == nil {
+		t.Errorf("Unexpected input %+v", evaluator.input)
+	}
+
+	var got []string
+	for _, finding := range findings {
+		if finding.Rule != lint.RulePolicy || finding.Fingerprint == "" {
+			t.Errorf("Unexpected finding %+v", finding)
+		}
+		got = append(got, strings.TrimSpace(lint.FormatFindings([]lint.Finding{{Severity: finding.Severity, Rule: finding.Rule, Index: finding.Index, Origin: finding.Origin, Message: finding.Message}})))
+	}
+	expected := []string{
+		"error[policy] origins[1] http://b.com: main.deny: b.com is not allowed ()",
+		"error[policy] document: main.deny: http://b.com is not https ()",
+		"warning[policy] document: main.warn: more than 1 label ()",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected findings:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+	if findings[1].Fingerprint == findings[2].Fingerprint {
+		t.Error("Expected findings about the document to have different fingerprints")
+	}
+
+	// An undefined namespace has no findings
+	if findings, err := Evaluate(context.Background(), result, Options{Bundle: t.TempDir(), Namespace: "passkeys", Evaluator: &fakeEvaluator{}}); err != nil || len(findings) != 0 {
+		t.Errorf("Expected no findings for an undefined namespace, got %v, %v", findings, err)
+	}
+
+	// Rules must yield messages
+	if _, err := Evaluate(context.Background(), result, Options{Bundle: t.TempDir(), Evaluator: &fakeEvaluator{value: `{"deny": [1]}`}}); err == nil {
+		t.Error("Expected an error for a rule that yields no messages")
+	}
+	if _, err := Evaluate(context.Background(), result, Options{Bundle: filepath.Join(t.TempDir(), "missing"), Evaluator: evaluator}); err == nil {
+		t.Error("Expected an error for a missing bundle")
+	}
+}
+
+// TestNewInput tests the input of a document that is not valid JSON.
+func TestNewInput(t *testing.T) {
+	input := NewInput(&counter.LabelCount{URL: "https://example.com/.well-known/webauthn", RawJSON: "{", ContentType: "application/json"})
+	if input.Document != nil || input.Raw != "{" || input.Fetch.ContentType != "application/json" || input.Fetch.Size != 1 {
+		t.Errorf("Unexpected input %+v", input)
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"labels":[]`) || !strings.Contains(string(data), `"warnings":[]`) {
+		t.Errorf("Expected empty lists rather than null, got %s", data)
+	}
+}
+
+// TestOPA tests evaluating a bundle in-process.
+func TestOPA(t *testing.T) {
+	bundle := t.TempDir()
+	policy := `package main
+
+import rego.v1
+
+deny contains msg if {
+	some origin in input.document.origins
+	startswith(origin, "http://")
+	msg := sprintf("%s is not https", [origin])
+}
+
+warn contains msg if {
+	input.label_count > data.limits.labels
+	msg := sprintf("more than %d labels", [data.limits.labels])
+}
+`
+	if err := os.WriteFile(filepath.Join(bundle, "policy.rego"), []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(bundle, "limits"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bundle, "limits", "data.json"), []byte(`{"labels": 1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := newResult(t, `{"origins": ["https://a.com", "http://b.com"]}`)
+	findings, err := Evaluate(context.Background(), result, Options{Bundle: bundle})
+	if err != nil {
+		t.Fatalf("Evaluate returned an error: %v", err)
+	}
+	if len(findings) != 2 || findings[0].Message != "main.deny: http://b.com is not https" || findings[1].Message != "main.warn: more than 1 labels" {
+		t.Errorf("Unexpected findings %+v", findings)
+	}
+
+	// A policy that does not compile is reported
+	if err := os.WriteFile(filepath.Join(bundle, "broken.rego"), []byte("package main\n\ndeny contains"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Evaluate(context.Background(), result, Options{Bundle: bundle}); err == nil {
+		t.Error("Expected an error for a policy that does not compile")
+	}
+}
+
+// TestOPADenyNetwork tests refusing the builtins that reach the network.
+func TestOPADenyNetwork(t *testing.T) {
+	bundle := t.TempDir()
+	policy := `package main
+
+import rego.v1
+
+deny contains msg if {
+	resp := http.send({"method": "GET", "url": "http://127.0.0.1:1"})
+	msg := sprintf("status %v", [resp.status_code])
+}
+`
+	if err := os.WriteFile(filepath.Join(bundle, "policy.rego"), []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := newResult(t, `{"origins": ["https://a.com"]}`)
+	_, err := Evaluate(context.Background(), result, Options{Bundle: bundle, Evaluator: OPA{DenyNetwork: true}})
+	if err == nil || !strings.Contains(err.Error(), "http.send") {
+		t.Errorf("Expected http.send to be refused, got %v", err)
+	}
+}
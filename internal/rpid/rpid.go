@@ -0,0 +1,254 @@
+// Package rpid checks whether an RP ID is valid for a caller origin under the standard
+// WebAuthn rules, without related origins: the RP ID must be the caller's effective
+// domain or a registrable domain suffix of it, as defined by the HTML specification and
+// the Public Suffix List. A caller that fails this check can only use the RP ID if the
+// RP's .well-known/webauthn document lists it as a related origin, which Decide falls
+// back to.
+package rpid
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Status is the outcome of checking an RP ID for a caller origin.
+type Status int
+
+const (
+	// Valid means the RP ID is the caller's effective domain or a registrable domain
+	// suffix of it, so no .well-known/webauthn document is needed.
+	Valid Status = iota
+	// InvalidOrigin means the caller origin is not a URL with a host.
+	InvalidOrigin
+	// InsecureOrigin means the caller origin is not a secure context: it is neither https
+	// nor http on localhost.
+	InsecureOrigin
+	// InvalidRPID means the RP ID is not a domain, such as one with a scheme or port.
+	InvalidRPID
+	// PublicSuffix means the RP ID is a public suffix, such as com or github.io, which no
+	// site can claim.
+	PublicSuffix
+	// NotSuffix means the RP ID is another site than the caller's, so the caller can only
+	// use it as a related origin listed in the RP's .well-known/webauthn document.
+	NotSuffix
+)
+
+// String returns the name of the status.
+func (s Status) String() string {
+	switch s {
+	case Valid:
+		return "VALID"
+	case InvalidOrigin:
+		return "INVALID_ORIGIN"
+	case InsecureOrigin:
+		return "INSECURE_ORIGIN"
+	case InvalidRPID:
+		return "INVALID_RP_ID"
+	case PublicSuffix:
+		return "PUBLIC_SUFFIX"
+	case NotSuffix:
+		return "NOT_A_REGISTRABLE_SUFFIX"
+	default:
+		return fmt.Sprintf("UNKNOWN_STATUS(%d)", s)
+	}
+}
+
+// Result is the outcome of checking an RP ID for a caller origin, with the domains the
+// decision was made on.
+type Result struct {
+	Status Status
+	// Reason explains the status in a sentence.
+	Reason string
+	// EffectiveDomain is the host of the caller origin, without its port.
+	EffectiveDomain string
+	// RegistrableDomain is the eTLD+1 of the effective domain, or "" for IP addresses and
+	// public suffixes.
+	RegistrableDomain string
+	// PublicSuffix is the public suffix of the effective domain, or "" for IP addresses.
+	PublicSuffix string
+}
+
+// Check checks whether rpID is valid for callerOrigin under the standard WebAuthn rules.
+func Check(callerOrigin, rpID string) Result {
+	originURL, err := url.Parse(callerOrigin)
+	if err != nil || originURL.Host == "" {
+		return Result{Status: InvalidOrigin, Reason: fmt.Sprintf("%q is not an origin with a host", callerOrigin)}
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(originURL.Hostname(), "."))
+	result := Result{EffectiveDomain: host}
+	if net.ParseIP(host) == nil {
+		result.PublicSuffix, _ = publicsuffix.PublicSuffix(host)
+		result.RegistrableDomain, _ = publicsuffix.EffectiveTLDPlusOne(host)
+	}
+
+	if !secureContext(originURL.Scheme, host) {
+		result.Status = InsecureOrigin
+		result.Reason = fmt.Sprintf("%s is not a secure context; WebAuthn requires https, or http on localhost", callerOrigin)
+		return result
+	}
+
+	rpID = strings.ToLower(strings.TrimSuffix(rpID, "."))
+	if rpID == "" || strings.ContainsAny(rpID, ":/?#@ ") {
+		result.Status = InvalidRPID
+		result.Reason = fmt.Sprintf("%q is not a domain; an RP ID has no scheme, port or path", rpID)
+		return result
+	}
+
+	// The effective domain itself is always a valid RP ID
+	if rpID == host {
+		result.Status = Valid
+		result.Reason = fmt.Sprintf("%s is the caller's effective domain", rpID)
+		return result
+	}
+
+	// An IP address can only be its own RP ID
+	if net.ParseIP(host) != nil || net.ParseIP(rpID) != nil {
+		result.Status = NotSuffix
+		result.Reason = fmt.Sprintf("%s is not %s; an IP address is only a suffix of itself", rpID, host)
+		return result
+	}
+
+	if !strings.HasSuffix(host, "."+rpID) {
+		result.Status = NotSuffix
+		result.Reason = fmt.Sprintf("%s is not a suffix of %s", rpID, host)
+		return result
+	}
+
+	// The RP ID may not be a public suffix, nor lie within the caller's public suffix
+	if suffix, _ := publicsuffix.PublicSuffix(rpID); suffix == rpID || strings.HasSuffix(result.PublicSuffix, "."+rpID) {
+		result.Status = PublicSuffix
+		result.Reason = fmt.Sprintf("%s is a public suffix, which no site can use as its RP ID", rpID)
+		return result
+	}
+
+	result.Status = Valid
+	result.Reason = fmt.Sprintf("%s is a registrable domain suffix of %s", rpID, host)
+	return result
+}
+
+// Path is the rule under which a caller origin may use an RP ID.
+type Path int
+
+const (
+	// PathNone means the caller origin may not use the RP ID.
+	PathNone Path = iota
+	// PathRPID means the RP ID is valid for the caller under the standard rules.
+	PathRPID
+	// PathRelatedOrigins means the RP ID's .well-known/webauthn document lists the caller.
+	PathRelatedOrigins
+)
+
+// String returns the name of the path.
+func (p Path) String() string {
+	switch p {
+	case PathNone:
+		return "none"
+	case PathRPID:
+		return "rp-id"
+	case PathRelatedOrigins:
+		return "related-origins"
+	default:
+		return fmt.Sprintf("unknown(%d)", p)
+	}
+}
+
+// Decision is whether a caller origin may use an RP ID, and under which rule.
+type Decision struct {
+	Allowed bool
+	Path    Path
+	// RPID is the outcome of the standard RP ID rules.
+	RPID Result
+	// RelatedOriginsChecked is true when the RP ID's .well-known/webauthn document was
+	// consulted, which is only the case for an RP ID of another site.
+	RelatedOriginsChecked bool
+	// RelatedOrigins is the status of the caller origin in the document, if it was checked.
+	RelatedOrigins counter.AuthenticatorStatus
+}
+
+// RelatedOriginsFunc validates callerOrigin against the .well-known/webauthn document of
+// rpID. It returns an error when the document cannot be fetched.
+type RelatedOriginsFunc func(rpID, callerOrigin string) (counter.AuthenticatorStatus, error)
+
+// Decide answers whether callerOrigin may use rpID, as a browser does: first under the
+// standard RP ID rules, then, for an RP ID of another site, by validating the caller
+// against the RP ID's .well-known/webauthn document with related. It returns related's
+// error, with the decision so far, when the document cannot be fetched.
+func Decide(callerOrigin, rpID string, related RelatedOriginsFunc) (Decision, error) {
+	decision := Decision{RPID: Check(callerOrigin, rpID)}
+	switch decision.RPID.Status {
+	case Valid:
+		decision.Allowed = true
+		decision.Path = PathRPID
+		return decision, nil
+	case NotSuffix:
+		// Only another site's RP ID can be reached through related origins
+	default:
+		return decision, nil
+	}
+
+	status, err := related(strings.ToLower(strings.TrimSuffix(rpID, ".")), callerOrigin)
+	if err != nil {
+		return decision, err
+	}
+	decision.RelatedOriginsChecked = true
+	decision.RelatedOrigins = status
+	if status == counter.StatusSuccess {
+		decision.Allowed = true
+		decision.Path = PathRelatedOrigins
+	}
+	return decision, nil
+}
+
+// secureContext reports whether an origin with scheme and host is potentially
+// trustworthy: https, or http on a loopback host.
+func secureContext(scheme, host string) bool {
+	switch scheme {
+	case "https":
+		return true
+	case "http":
+		return Loopback(host)
+	default:
+		return false
+	}
+}
+
+// Loopback reports whether host, without a port, is a loopback host: localhost, a
+// subdomain of it, or a loopback IP address.
+func Loopback(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// SecureContext reports whether callerOrigin is a secure context, where browsers allow
+// WebAuthn requests: https, http on a loopback host, or one of trusted, origins that a
+// browser was configured to treat as secure, as with Chrome's
+// --unsafely-treat-insecure-origin-as-secure. Origins are compared in serialized form.
+func SecureContext(callerOrigin string, trusted []string) bool {
+	originURL, err := url.Parse(callerOrigin)
+	if err != nil || originURL.Host == "" {
+		return false
+	}
+	if secureContext(strings.ToLower(originURL.Scheme), originURL.Hostname()) {
+		return true
+	}
+	serialized, err := counter.SerializeOrigin(callerOrigin)
+	if err != nil {
+		return false
+	}
+	for _, origin := range trusted {
+		if other, err := counter.SerializeOrigin(origin); err == nil && other == serialized {
+			return true
+		}
+	}
+	return false
+}
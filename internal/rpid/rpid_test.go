@@ -0,0 +1,133 @@
+package rpid
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// TestCheck tests RP IDs against caller origins under the standard rules.
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin string
+		rpID   string
+		status Status
+	}{
+		{"Effective domain", "https://example.com", "example.com", Valid},
+		{"Registrable suffix", "https://login.example.com", "example.com", Valid},
+		{"Port is ignored", "https://login.example.com:8443", "example.com", Valid},
+		{"Case is ignored", "https://Login.Example.com", "EXAMPLE.com", Valid},
+		{"Multi-label public suffix", "https://shop.example.co.uk", "example.co.uk", Valid},
+		{"Subdomain of the caller", "https://example.com", "login.example.com", NotSuffix},
+		{"Other site", "https://example.co.uk", "example.com", NotSuffix},
+		{"Partial label", "https://myexample.com", "example.com", NotSuffix},
+		{"Public suffix", "https://example.com", "com", PublicSuffix},
+		{"Private public suffix", "https://site.github.io", "github.io", PublicSuffix},
+		{"Within the caller's public suffix", "https://bucket.s3.amazonaws.com", "amazonaws.com", PublicSuffix},
+		{"Localhost", "http://localhost:3000", "localhost", Valid},
+		{"Insecure", "http://example.com", "example.com", InsecureOrigin},
+		{"IP address", "https://192.0.2.1", "192.0.2.1", Valid},
+		{"IP address suffix", "https://192.0.2.1", "2.1", NotSuffix},
+		{"RP ID with scheme", "https://example.com", "https://example.com", InvalidRPID},
+		{"Empty RP ID", "https://example.com", "", InvalidRPID},
+		{"Not an origin", "example.com", "example.com", InvalidOrigin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Check(tt.origin, tt.rpID)
+			if result.Status != tt.status {
+				t.Errorf("Check(%q, %q) = %v (%s), expected %v", tt.origin, tt.rpID, result.Status, result.Reason, tt.status)
+			}
+			if result.Reason == "" {
+				t.Errorf("Check(%q, %q) has no reason", tt.origin, tt.rpID)
+			}
+		})
+	}
+
+	result := Check("https://login.example.co.uk:8443", "example.co.uk")
+	if result.EffectiveDomain != "login.example.co.uk" || result.RegistrableDomain != "example.co.uk" || result.PublicSuffix != "co.uk" {
+		t.Errorf("Unexpected domains %+v", result)
+	}
+}
+
+// TestDecide tests falling back from the standard rules to related origins.
+func TestDecide(t *testing.T) {
+	document := []byte(`{"origins": ["https://example.co.uk"]}`)
+	var fetched []string
+	related := func(rpID, callerOrigin string) (counter.AuthenticatorStatus, error) {
+		fetched = append(fetched, rpID)
+		if rpID == "unreachable.com" {
+			return 0, errors.New("failed to fetch")
+		}
+		return counter.ValidateWellKnownJSON(callerOrigin, document), nil
+	}
+
+	tests := []struct {
+		name    string
+		origin  string
+		rpID    string
+		allowed bool
+		path    Path
+		fetch   bool
+	}{
+		{"Standard rules", "https://login.example.com", "example.com", true, PathRPID, false},
+		{"Related origin", "https://example.co.uk", "Example.com.", true, PathRelatedOrigins, true},
+		{"Not listed", "https://example.de", "example.com", false, PathNone, true},
+		{"Public suffix is never fetched", "https://example.com", "com", false, PathNone, false},
+		{"Insecure origin is never fetched", "http://example.co.uk", "example.com", false, PathNone, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetched = nil
+			decision, err := Decide(tt.origin, tt.rpID, related)
+			if err != nil {
+				t.Fatalf("Decide returned an error: %v", err)
+			}
+			if decision.Allowed != tt.allowed || decision.Path != tt.path {
+				t.Errorf("Expected allowed=%v through %v, got %+v", tt.allowed, tt.path, decision)
+			}
+			if decision.RelatedOriginsChecked != tt.fetch || (len(fetched) > 0) != tt.fetch {
+				t.Errorf("Expected the document to be checked=%v, got %+v after fetching %v", tt.fetch, decision, fetched)
+			}
+			if tt.fetch && fetched[0] != "example.com" {
+				t.Errorf("Expected the normalized RP ID to be fetched, got %q", fetched[0])
+			}
+		})
+	}
+
+	if _, err := Decide("https://example.co.uk", "unreachable.com", related); err == nil {
+		t.Error("Expected an error for a document that cannot be fetched")
+	}
+}
+
+// TestSecureContext tests which caller origins browsers allow WebAuthn requests from.
+func TestSecureContext(t *testing.T) {
+	trusted := []string{"http://staging.example.com:8080"}
+	tests := []struct {
+		origin string
+		secure bool
+	}{
+		{"https://example.com", true},
+		{"https://example.com:8443", true},
+		{"http://localhost:3000", true},
+		{"http://app.localhost:3000", true},
+		{"http://LOCALHOST.:3000", true},
+		{"http://127.0.0.1:8080", true},
+		{"http://[::1]:8080", true},
+		{"http://example.com", false},
+		{"http://192.0.2.1", false},
+		{"http://staging.example.com:8080", true},
+		{"HTTP://Staging.Example.com:8080/", true},
+		{"http://staging.example.com", false},
+		{"http://staging.example.com:8081", false},
+		{"ftp://example.com", false},
+		{"example.com", false},
+	}
+	for _, tt := range tests {
+		if secure := SecureContext(tt.origin, trusted); secure != tt.secure {
+			t.Errorf("SecureContext(%q) = %v, expected %v", tt.origin, secure, tt.secure)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package sandbox confines a run to reading, for audits of third-party relying parties
+// under restrictive rules of engagement: it only lets GET requests through, never follows
+// a redirect to another host, and only lets files be written under one directory.
+//
+// The sandbox guards the requests made through its Transport and the paths checked with
+// Writable; the commands that use it refuse the features that would go around it, such
+// as webhooks and issue trackers.
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrRefused is returned, wrapped, for a request or write the sandbox does not allow.
+var ErrRefused = errors.New("refused by the sandbox")
+
+// Sandbox is the policy of a sandboxed run.
+type Sandbox struct {
+	// dir is the absolute path of the only directory files may be written under, with
+	// symbolic links resolved, or "" if nothing may be written.
+	dir string
+}
+
+// New returns a Sandbox that lets files be written under dir, which must exist, or
+// nothing be written if dir is empty.
+func New(dir string) (*Sandbox, error) {
+	if dir == "" {
+		return &Sandbox{}, nil
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sandbox directory: %w", err)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sandbox directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("invalid sandbox directory: %s is not a directory", dir)
+	}
+	abs, err := filepath.Abs(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sandbox directory: %w", err)
+	}
+	return &Sandbox{dir: abs}, nil
+}
+
+// Dir returns the directory files may be written under, or "" if nothing may be written.
+func (s *Sandbox) Dir() string {
+	return s.dir
+}
+
+// Writable returns an error wrapping ErrRefused unless path is under the directory of
+// the sandbox. Symbolic links in the part of path that exists are resolved first, so
+// that a link cannot point a write outside the directory.
+func (s *Sandbox) Writable(path string) error {
+	if s.dir == "" {
+		return fmt.Errorf("%w: writing %s (no sandbox directory is set)", ErrRefused, path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("%w: writing %s: %v", ErrRefused, path, err)
+	}
+	resolved := resolveExisting(abs)
+	if resolved != s.dir && !strings.HasPrefix(resolved, s.dir+string(filepath.Separator)) {
+		return fmt.Errorf("%w: writing %s, which is outside the sandbox directory %s", ErrRefused, path, s.dir)
+	}
+	return nil
+}
+
+// resolveExisting resolves the symbolic links of the longest part of path that exists,
+// and appends the rest of path to it.
+func resolveExisting(path string) string {
+	var rest []string
+	for current := path; ; current = filepath.Dir(current) {
+		if resolved, err := filepath.EvalSymlinks(current); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		if parent := filepath.Dir(current); parent == current {
+			return path
+		}
+		rest = append([]string{filepath.Base(current)}, rest...)
+	}
+}
+
+// Transport returns rt wrapped so that only GET requests are sent, and a redirect is only
+// followed to the host that sent it.
+func (s *Sandbox) Transport(rt http.RoundTripper) http.RoundTripper {
+	return &transport{base: rt}
+}
+
+// transport enforces the request policy of a Sandbox.
+type transport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip sends req if the sandbox allows it.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return nil, fmt.Errorf("%w: %s request to %s; only GET requests are sent", ErrRefused, req.Method, req.URL.Redacted())
+	}
+	// The response is set on requests made to follow a redirect
+	if req.Response != nil && req.Response.Request != nil && req.Response.Request.URL.Host != req.URL.Host {
+		return nil, fmt.Errorf("%w: redirect from %s to another host, %s", ErrRefused, req.Response.Request.URL.Host, req.URL.Host)
+	}
+	return t.base.RoundTrip(req)
+}
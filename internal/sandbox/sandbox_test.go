@@ -0,0 +1,102 @@
+package sandbox
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestTransport tests that only GET requests and same-host redirects are sent.
+func TestTransport(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("other"))
+	}))
+	defer other.Close()
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/same":
+			http.Redirect(w, r, "/.well-known/webauthn", http.StatusFound)
+		case "/other":
+			http.Redirect(w, r, other.URL, http.StatusFound)
+		default:
+			w.Write([]byte(`{"origins": []}`))
+		}
+	}))
+	defer server.Close()
+
+	s, err := New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: s.Transport(http.DefaultTransport)}
+
+	resp, err := client.Get(server.URL + "/same")
+	if err != nil {
+		t.Fatalf("Expected a redirect to the same host to be followed, got %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := client.Get(server.URL + "/other"); !errors.Is(err, ErrRefused) {
+		t.Errorf("Expected a redirect to another host to be refused, got %v", err)
+	}
+	if _, err := client.Post(server.URL+"/", "application/json", strings.NewReader("{}")); !errors.Is(err, ErrRefused) {
+		t.Errorf("Expected a POST request to be refused, got %v", err)
+	}
+	if _, err := client.Head(server.URL + "/"); !errors.Is(err, ErrRefused) {
+		t.Errorf("Expected a HEAD request to be refused, got %v", err)
+	}
+
+	expected := "GET /same,GET /.well-known/webauthn,GET /other"
+	if strings.Join(requests, ",") != expected {
+		t.Errorf("Expected requests %s, got %s", expected, strings.Join(requests, ","))
+	}
+}
+
+// TestWritable tests that files are only written under the sandbox directory.
+func TestWritable(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "out")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(root, filepath.Join(dir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{filepath.Join(dir, "results.jsonl"), true},
+		{filepath.Join(dir, "new", "results.jsonl"), true},
+		{dir, true},
+		{filepath.Join(root, "results.jsonl"), false},
+		{filepath.Join(dir, "..", "results.jsonl"), false},
+		{filepath.Join(dir, "escape", "results.jsonl"), false},
+		{root + "/out-other/results.jsonl", false},
+	}
+	for _, tt := range tests {
+		if err := s.Writable(tt.path); (err == nil) != tt.allowed {
+			t.Errorf("Writable(%s) = %v, allowed %v", tt.path, err, tt.allowed)
+		}
+	}
+
+	// Without a directory nothing may be written
+	none, _ := New("")
+	if err := none.Writable(filepath.Join(dir, "results.jsonl")); !errors.Is(err, ErrRefused) {
+		t.Errorf("Expected writes to be refused without a directory, got %v", err)
+	}
+	if _, err := New(filepath.Join(root, "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
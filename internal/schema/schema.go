@@ -0,0 +1,222 @@
+// Package schema validates .well-known/webauthn documents against an embedded JSON
+// Schema, and reports each violation at the JSON Pointer of the value it is about, so
+// that a document browsers cannot parse says why instead of failing as a whole.
+//
+// The validator implements the keywords the embedded schema uses: type, properties,
+// required, additionalProperties and items, with boolean schemas.
+package schema
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// WellKnown is the JSON Schema of the .well-known/webauthn document.
+//
+//go:embed webauthn.schema.json
+var WellKnown []byte
+
+// Keywords of the violations Validate reports.
+const (
+	// KeywordType reports a value of the wrong type, such as null or an origins member
+	// that is not an array.
+	KeywordType = "type"
+	// KeywordRequired reports a missing member, such as origins.
+	KeywordRequired = "required"
+	// KeywordAdditionalProperties reports an unknown member, which browsers ignore.
+	KeywordAdditionalProperties = "additionalProperties"
+)
+
+// Violation is a value of a document that does not match the schema.
+type Violation struct {
+	// Pointer is the JSON Pointer of the value, such as "/origins/2", or "" for the
+	// whole document.
+	Pointer string `json:"pointer"`
+	// Keyword is the schema keyword the value violates, such as KeywordType.
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+// String returns the violation as its pointer followed by its message, or only its
+// message for a violation of the whole document.
+func (v Violation) String() string {
+	if v.Pointer == "" {
+		return v.Message
+	}
+	return fmt.Sprintf("%s: %s", v.Pointer, v.Message)
+}
+
+// Unknown reports whether the violation is only a member the schema does not define,
+// which browsers ignore.
+func (v Violation) Unknown() bool {
+	return v.Keyword == KeywordAdditionalProperties
+}
+
+// node is a compiled schema. A boolean schema compiles to a node that accepts anything,
+// or with never set, nothing.
+type node struct {
+	Type                 types            `json:"type"`
+	Properties           map[string]*node `json:"properties"`
+	Required             []string         `json:"required"`
+	AdditionalProperties *node            `json:"additionalProperties"`
+	Items                *node            `json:"items"`
+	never                bool
+}
+
+// UnmarshalJSON compiles a schema object or a boolean schema.
+func (n *node) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		n.never = !b
+		return nil
+	}
+	type plain node
+	return json.Unmarshal(data, (*plain)(n))
+}
+
+// types is the value of the type keyword, which is a name or an array of names.
+type types []string
+
+// UnmarshalJSON reads a type name or an array of them.
+func (t *types) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = types{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	*t = names
+	return nil
+}
+
+// wellKnown is the compiled WellKnown schema.
+var wellKnown = mustCompile(WellKnown)
+
+// mustCompile compiles a schema, and panics if it is invalid.
+func mustCompile(schema []byte) *node {
+	n, err := compile(schema)
+	if err != nil {
+		panic(fmt.Sprintf("schema: %v", err))
+	}
+	return n
+}
+
+// compile compiles a schema.
+func compile(schema []byte) (*node, error) {
+	var n node
+	if err := json.Unmarshal(schema, &n); err != nil {
+		return nil, fmt.Errorf("failed to compile schema: %w", err)
+	}
+	return &n, nil
+}
+
+// Validate validates a .well-known/webauthn document against WellKnown, and returns its
+// violations in document order, with the members of an object sorted. It returns an
+// error when the document is not valid JSON.
+func Validate(jsonData []byte) ([]Violation, error) {
+	var value any
+	if err := json.Unmarshal(jsonData, &value); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	return wellKnown.validate(value, "", nil), nil
+}
+
+// validate appends the violations of value, at pointer, to violations.
+func (n *node) validate(value any, pointer string, violations []Violation) []Violation {
+	if n.never {
+		return append(violations, Violation{Pointer: pointer, Keyword: "false", Message: "no value is allowed here"})
+	}
+	if len(n.Type) > 0 && !n.Type.match(value) {
+		return append(violations, Violation{
+			Pointer: pointer,
+			Keyword: KeywordType,
+			Message: fmt.Sprintf("expected %s, got %s", strings.Join(n.Type, " or "), typeOf(value)),
+		})
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		for _, name := range n.Required {
+			if _, ok := value[name]; !ok {
+				violations = append(violations, Violation{
+					Pointer: pointer,
+					Keyword: KeywordRequired,
+					Message: fmt.Sprintf("missing required member %q", name),
+				})
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			member := pointer + "/" + escape(name)
+			switch {
+			case n.Properties[name] != nil:
+				violations = n.Properties[name].validate(value[name], member, violations)
+			case n.AdditionalProperties != nil && n.AdditionalProperties.never:
+				violations = append(violations, Violation{
+					Pointer: member,
+					Keyword: KeywordAdditionalProperties,
+					Message: fmt.Sprintf("unknown member %q, which browsers ignore", name),
+				})
+			case n.AdditionalProperties != nil:
+				violations = n.AdditionalProperties.validate(value[name], member, violations)
+			}
+		}
+	case []any:
+		if n.Items != nil {
+			for i, item := range value {
+				violations = n.Items.validate(item, fmt.Sprintf("%s/%d", pointer, i), violations)
+			}
+		}
+	}
+	return violations
+}
+
+// match reports whether value is of one of the types.
+func (t types) match(value any) bool {
+	actual := typeOf(value)
+	for _, name := range t {
+		if name == actual || name == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON Schema type name of a decoded value.
+func typeOf(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// escape escapes a member name as a JSON Pointer reference token.
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
@@ -0,0 +1,88 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestValidate tests the violations reported for documents.
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		// expected lists each violation as "pointer keyword"
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name: "Valid document",
+			json: `{"origins": ["https://example.com", "android:apk-key-hash:AAAA"]}`,
+		},
+		{
+			name:    "Invalid JSON",
+			json:    `{"origins": [`,
+			wantErr: true,
+		},
+		{
+			name:     "Not an object",
+			json:     `["https://example.com"]`,
+			expected: []string{" type"},
+		},
+		{
+			name:     "Missing origins",
+			json:     `{"origin": ["https://example.com"]}`,
+			expected: []string{" required", "/origin additionalProperties"},
+		},
+		{
+			name:     "Null origins",
+			json:     `{"origins": null}`,
+			expected: []string{"/origins type"},
+		},
+		{
+			name:     "Origins not an array",
+			json:     `{"origins": "https://example.com"}`,
+			expected: []string{"/origins type"},
+		},
+		{
+			name:     "Entries of the wrong type",
+			json:     `{"origins": ["https://example.com", null, 42, {"url": "https://example.org"}]}`,
+			expected: []string{"/origins/1 type", "/origins/2 type", "/origins/3 type"},
+		},
+		{
+			name:     "Unknown members",
+			json:     `{"origins": [], "comment": "kept", "a/b~c": 1}`,
+			expected: []string{"/a~1b~0c additionalProperties", "/comment additionalProperties"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := Validate([]byte(tt.json))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, v := range violations {
+				got = append(got, v.Pointer+" "+v.Keyword)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected violations %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestViolationString tests the messages of violations.
+func TestViolationString(t *testing.T) {
+	violations, err := Validate([]byte(`{"origins": ["https://example.com", null]}`))
+	if err != nil {
+		t.Fatalf("Validate returned an error: %v", err)
+	}
+	if len(violations) != 1 || violations[0].String() != "/origins/1: expected string, got null" {
+		t.Errorf("Unexpected violations %v", violations)
+	}
+
+	violations, _ = Validate([]byte(`42`))
+	if len(violations) != 1 || violations[0].String() != "expected object, got integer" {
+		t.Errorf("Unexpected violations %v", violations)
+	}
+}
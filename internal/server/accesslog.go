@@ -0,0 +1,127 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Redacted replaces the values of redacted query parameters and headers in access logs.
+const Redacted = "REDACTED"
+
+// DefaultRedactedParams are query parameters that commonly carry credentials. Their
+// values are never logged.
+var DefaultRedactedParams = []string{"api_key", "apikey", "key", "token", "access_token", "auth", "password", "secret"}
+
+// DefaultRedactedHeaders are headers that carry credentials. Their values are never
+// logged, even when they are listed in AccessLogOptions.Headers.
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// AccessLogOptions configures AccessLog.
+type AccessLogOptions struct {
+	// Headers are request headers whose values are logged, such as X-Request-Id.
+	Headers []string
+	// RedactParams are query parameters whose values are replaced with Redacted, in
+	// addition to DefaultRedactedParams. Names are compared case-insensitively.
+	RedactParams []string
+	// RedactHeaders are headers whose values are replaced with Redacted, in addition to
+	// DefaultRedactedHeaders.
+	RedactHeaders []string
+}
+
+// statusRecorder captures the status code and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(data)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// AccessLog wraps next so that every request is logged to logger as one structured
+// record, with the method, path, query, status, response size, duration, client address
+// and user agent. Credentials in the query and headers are redacted before they are
+// logged.
+func AccessLog(next http.Handler, logger *slog.Logger, opts AccessLogOptions) http.Handler {
+	redactParams := make(map[string]bool)
+	for _, name := range append(append([]string{}, DefaultRedactedParams...), opts.RedactParams...) {
+		redactParams[strings.ToLower(name)] = true
+	}
+	redactHeaders := make(map[string]bool)
+	for _, name := range append(append([]string{}, DefaultRedactedHeaders...), opts.RedactHeaders...) {
+		redactHeaders[http.CanonicalHeaderKey(name)] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("query", redactQuery(r.URL.RawQuery, redactParams)),
+			slog.Int("status", rec.status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("user_agent", r.UserAgent()),
+		}
+		if len(opts.Headers) > 0 {
+			var headers []any
+			for _, name := range opts.Headers {
+				value := r.Header.Get(name)
+				if value == "" {
+					continue
+				}
+				if redactHeaders[http.CanonicalHeaderKey(name)] {
+					value = Redacted
+				}
+				headers = append(headers, slog.String(http.CanonicalHeaderKey(name), value))
+			}
+			if len(headers) > 0 {
+				attrs = append(attrs, slog.Group("headers", headers...))
+			}
+		}
+		logger.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+	})
+}
+
+// redactQuery returns rawQuery with the values of the redacted parameters replaced.
+// A query that cannot be parsed is redacted as a whole, since it cannot be checked.
+func redactQuery(rawQuery string, redact map[string]bool) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Redacted
+	}
+	for name := range values {
+		if redact[strings.ToLower(name)] {
+			for i := range values[name] {
+				values[name][i] = Redacted
+			}
+		}
+	}
+	return values.Encode()
+}
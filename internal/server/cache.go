@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// document is the outcome of fetching one domain's .well-known/webauthn document.
+type document struct {
+	// result is the label count result; nil when the fetch itself failed.
+	result *counter.LabelCount
+	// compiled is the indexed document; nil when the fetch or parse failed.
+	compiled *counter.CompiledWellKnown
+	// err describes why the document could not be fetched or parsed.
+	err string
+	// parseFailed is true when the document was fetched but is not a valid document.
+	parseFailed bool
+	// circuitOpen is true when the fetch was refused because the domain's circuit is open.
+	circuitOpen bool
+	// fetchedAt is when the document was fetched.
+	fetchedAt time.Time
+}
+
+// failed reports whether the document could not be fetched or parsed.
+func (d *document) failed() bool {
+	return d.compiled == nil
+}
+
+// call is an in-flight fetch that concurrent requests for the same domain wait on.
+type call struct {
+	done chan struct{}
+	doc  *document
+}
+
+// cache holds fetched documents and recent negative validation outcomes.
+//
+// Successful fetches are kept for the success TTL. Failed fetches, and caller origins
+// that a document did not authorize, are kept for the shorter negative TTL, so that a
+// client repeating a bad request does not cause repeated upstream fetches.
+type cache struct {
+	mu       sync.Mutex
+	docs     map[string]*document
+	inflight map[string]*call
+	// negative maps domain and caller origin to when the NO_JSON_MATCH outcome was cached.
+	negative map[negativeKey]time.Time
+
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+	fetch       func(domain string) *document
+}
+
+// negativeKey identifies a negative validation outcome.
+type negativeKey struct {
+	domain string
+	origin string
+}
+
+// newCache returns an empty cache that fetches documents with fetch.
+func newCache(ttl, negativeTTL time.Duration, fetch func(domain string) *document) *cache {
+	return &cache{
+		docs:        make(map[string]*document),
+		inflight:    make(map[string]*call),
+		negative:    make(map[negativeKey]time.Time),
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+		fetch:       fetch,
+	}
+}
+
+// fresh reports whether a cached document may still be served.
+func (c *cache) fresh(doc *document) bool {
+	ttl := c.ttl
+	if doc.failed() {
+		ttl = c.negativeTTL
+	}
+	return c.now().Sub(doc.fetchedAt) < ttl
+}
+
+// get returns the document for domain and whether it came from the cache. When force is
+// true, a cached document is ignored and the domain is fetched again. Concurrent requests
+// for the same domain share a single fetch, which runs to completion even if the request
+// that started it gives up, so that its result is still cached. get returns ctx.Err() if
+// ctx is done before the document is available.
+func (c *cache) get(ctx context.Context, domain string, force bool) (*document, bool, error) {
+	c.mu.Lock()
+	if doc, ok := c.docs[domain]; ok && !force && c.fresh(doc) {
+		c.mu.Unlock()
+		return doc, true, nil
+	}
+	inflight, ok := c.inflight[domain]
+	if !ok {
+		inflight = &call{done: make(chan struct{})}
+		c.inflight[domain] = inflight
+		go c.run(domain, inflight)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-inflight.done:
+		return inflight.doc, false, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// run fetches domain, caches the document and releases the requests waiting on inflight.
+func (c *cache) run(domain string, inflight *call) {
+	doc := c.fetch(domain)
+	doc.fetchedAt = c.now()
+
+	c.mu.Lock()
+	c.docs[domain] = doc
+	delete(c.inflight, domain)
+	c.mu.Unlock()
+	c.prune()
+
+	inflight.doc = doc
+	close(inflight.done)
+}
+
+// negativeHit reports whether origin was recently found not to be authorized by domain.
+func (c *cache) negativeHit(domain, origin string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := negativeKey{domain: domain, origin: origin}
+	cachedAt, ok := c.negative[key]
+	if !ok {
+		return false
+	}
+	if c.now().Sub(cachedAt) >= c.negativeTTL {
+		delete(c.negative, key)
+		return false
+	}
+	return true
+}
+
+// storeNegative records that origin is not authorized by domain.
+func (c *cache) storeNegative(domain, origin string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.negative[negativeKey{domain: domain, origin: origin}] = c.now()
+}
+
+// prune removes expired documents and negative outcomes.
+func (c *cache) prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for domain, doc := range c.docs {
+		if !c.fresh(doc) {
+			delete(c.docs, domain)
+		}
+	}
+	for key, cachedAt := range c.negative {
+		if c.now().Sub(cachedAt) >= c.negativeTTL {
+			delete(c.negative, key)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Package server provides a REST API for validating caller origins and counting labels.
+//
+// Fetched documents are cached in memory. Successful fetches are kept for the success
+// TTL, while fetch failures and caller origins that a document does not authorize are
+// kept for a separate, shorter negative TTL.
+package server
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/breaker"
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+const (
+	// DefaultTTL is how long a successfully fetched document is cached.
+	DefaultTTL = 5 * time.Minute
+	// DefaultNegativeTTL is how long fetch failures and unauthorized origins are cached.
+	DefaultNegativeTTL = 30 * time.Second
+)
+
+// OpenAPI is the OpenAPI 3 document describing the API, served at /openapi.json.
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+// Options configures a Server.
+type Options struct {
+	// Fetch configures how documents are fetched.
+	Fetch counter.Options
+	// TTL is how long a successfully fetched document is cached.
+	TTL time.Duration
+	// NegativeTTL is how long fetch failures and unauthorized origins are cached.
+	NegativeTTL time.Duration
+	// Breaker, if set, stops fetching domains that fail repeatedly until a cooldown has passed.
+	Breaker *breaker.Breaker
+}
+
+// DefaultOptions returns the Options used when none are configured.
+func DefaultOptions() Options {
+	return Options{
+		Fetch:       counter.DefaultOptions(),
+		TTL:         DefaultTTL,
+		NegativeTTL: DefaultNegativeTTL,
+	}
+}
+
+// ValidateResponse is the response of the /v1/validate endpoint.
+type ValidateResponse struct {
+	Domain     string   `json:"domain"`
+	URL        string   `json:"url,omitempty"`
+	Origin     string   `json:"origin"`
+	Status     string   `json:"status,omitempty"`
+	Authorized bool     `json:"authorized"`
+	LabelCount int      `json:"label_count"`
+	Labels     []string `json:"labels,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	// CircuitOpen is true when the domain was not fetched because it has been failing repeatedly.
+	CircuitOpen bool `json:"circuit_open,omitempty"`
+	Cached      bool `json:"cached"`
+}
+
+// CountResponse is the response of the /v1/count endpoint.
+type CountResponse struct {
+	Domain       string   `json:"domain"`
+	URL          string   `json:"url,omitempty"`
+	LabelCount   int      `json:"label_count"`
+	Labels       []string `json:"labels,omitempty"`
+	ExceedsLimit bool     `json:"exceeds_limit"`
+	Warnings     []string `json:"warnings,omitempty"`
+	Error        string   `json:"error,omitempty"`
+	// CircuitOpen is true when the domain was not fetched because it has been failing repeatedly.
+	CircuitOpen bool `json:"circuit_open,omitempty"`
+	Cached      bool `json:"cached"`
+}
+
+// ErrorResponse is the response for requests that could not be handled.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server serves the REST API.
+type Server struct {
+	opts  Options
+	cache *cache
+	mux   *http.ServeMux
+}
+
+// New returns a Server with an empty cache.
+func New(opts Options) *Server {
+	s := &Server{opts: opts}
+	s.cache = newCache(opts.TTL, opts.NegativeTTL, s.fetchDocument)
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("GET /v1/validate", s.handleValidate)
+	s.mux.HandleFunc("GET /v1/count", s.handleCount)
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
+	return s
+}
+
+// ServeHTTP dispatches a request to the API endpoints.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// fetchDocument fetches the document for domain through the circuit breaker.
+func (s *Server) fetchDocument(domain string) *document {
+	if err := s.opts.Breaker.Allow(domain); err != nil {
+		return &document{err: err.Error(), circuitOpen: true}
+	}
+
+	doc := s.compileDocument(domain)
+	if doc.failed() {
+		s.opts.Breaker.Failure(domain)
+	} else {
+		s.opts.Breaker.Success(domain)
+	}
+	return doc
+}
+
+// compileDocument fetches and compiles the document for domain.
+func (s *Server) compileDocument(domain string) *document {
+	result, err := counter.CountLabelsWithOptions(domain, s.opts.Fetch)
+	if err != nil {
+		return &document{err: err.Error()}
+	}
+
+	doc := &document{result: result}
+	if result.ErrorMessage != "" {
+		doc.err = result.ErrorMessage
+		doc.parseFailed = result.RawJSON != ""
+		return doc
+	}
+
+	compiled, err := counter.CompileWithMaxLabels([]byte(result.RawJSON), s.opts.Fetch.MaxLabels)
+	if err != nil {
+		doc.err = err.Error()
+		doc.parseFailed = true
+		return doc
+	}
+	doc.compiled = compiled
+	return doc
+}
+
+// Validate reports whether origin is authorized by domain's document. A document that
+// does not authorize origin is fetched again, in case it changed since it was cached,
+// unless the same outcome was seen within the negative TTL.
+//
+// Validate returns ctx.Err() if ctx is done before the document is available.
+func (s *Server) Validate(ctx context.Context, domain, origin string) (ValidateResponse, error) {
+	doc, cached, err := s.cache.get(ctx, domain, false)
+	if err != nil {
+		return ValidateResponse{}, err
+	}
+	status := s.status(doc, origin)
+
+	if status != counter.StatusSuccess && !doc.failed() {
+		if s.cache.negativeHit(domain, origin) {
+			return s.validateResponse(domain, origin, doc, status, true), nil
+		}
+		if cached {
+			doc, cached, err = s.cache.get(ctx, domain, true)
+			if err != nil {
+				return ValidateResponse{}, err
+			}
+			status = s.status(doc, origin)
+		}
+		if status != counter.StatusSuccess && !doc.failed() {
+			s.cache.storeNegative(domain, origin)
+		}
+	}
+
+	return s.validateResponse(domain, origin, doc, status, cached), nil
+}
+
+// status validates origin against doc. Documents that failed to parse report
+// StatusBadRelyingPartyIDJSONParseError, as a browser would.
+func (s *Server) status(doc *document, origin string) counter.AuthenticatorStatus {
+	if doc.failed() {
+		return counter.StatusBadRelyingPartyIDJSONParseError
+	}
+	return doc.compiled.Validate(origin)
+}
+
+// validateResponse builds the response for a validation.
+func (s *Server) validateResponse(domain, origin string, doc *document, status counter.AuthenticatorStatus, cached bool) ValidateResponse {
+	resp := ValidateResponse{
+		Domain:      domain,
+		Origin:      origin,
+		Error:       doc.err,
+		CircuitOpen: doc.circuitOpen,
+		Cached:      cached,
+	}
+	if doc.result != nil {
+		resp.URL = doc.result.URL
+	}
+	if doc.compiled != nil {
+		resp.Labels = doc.compiled.Labels()
+		resp.LabelCount = len(resp.Labels)
+	}
+	// Fetch failures have no browser status; only parsed documents do
+	if !doc.failed() || doc.parseFailed {
+		resp.Status = status.String()
+		resp.Authorized = status == counter.StatusSuccess
+	}
+	return resp
+}
+
+// Count returns the label count for domain's document. It returns ctx.Err() if ctx is
+// done before the document is available.
+func (s *Server) Count(ctx context.Context, domain string) (CountResponse, error) {
+	doc, cached, err := s.cache.get(ctx, domain, false)
+	if err != nil {
+		return CountResponse{}, err
+	}
+	resp := CountResponse{
+		Domain:      domain,
+		Error:       doc.err,
+		CircuitOpen: doc.circuitOpen,
+		Cached:      cached,
+	}
+	if doc.result != nil {
+		resp.URL = doc.result.URL
+		resp.Warnings = doc.result.Warnings
+		resp.LabelCount = doc.result.Count
+		resp.Labels = doc.result.LabelsFound
+		resp.ExceedsLimit = doc.result.ExceedsLimit
+	}
+	return resp, nil
+}
+
+// handleValidate handles GET /v1/validate?domain=...&origin=...
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	domain := r.URL.Query().Get("domain")
+	origin := r.URL.Query().Get("origin")
+	if domain == "" || origin == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "the domain and origin query parameters are required"})
+		return
+	}
+
+	resp, err := s.Validate(r.Context(), domain, origin)
+	if err != nil {
+		writeJSON(w, http.StatusGatewayTimeout, ErrorResponse{Error: err.Error()})
+		return
+	}
+	code := http.StatusOK
+	switch {
+	case resp.CircuitOpen:
+		code = http.StatusServiceUnavailable
+	case resp.Status == "":
+		code = http.StatusBadGateway
+	}
+	writeJSON(w, code, resp)
+}
+
+// handleCount handles GET /v1/count?domain=...
+func (s *Server) handleCount(w http.ResponseWriter, r *http.Request) {
+	domain := r.URL.Query().Get("domain")
+	if domain == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "the domain query parameter is required"})
+		return
+	}
+
+	resp, err := s.Count(r.Context(), domain)
+	if err != nil {
+		writeJSON(w, http.StatusGatewayTimeout, ErrorResponse{Error: err.Error()})
+		return
+	}
+	code := http.StatusOK
+	switch {
+	case resp.CircuitOpen:
+		code = http.StatusServiceUnavailable
+	case resp.Error != "":
+		code = http.StatusBadGateway
+	}
+	writeJSON(w, code, resp)
+}
+
+// handleHealth handles GET /healthz.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleOpenAPI handles GET /openapi.json.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(OpenAPI)
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/breaker"
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// newUpstream returns a test server that serves body with the given status code and
+// counts the requests it receives.
+func newUpstream(code int, body string, hits *atomic.Int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}))
+}
+
+// newTestServer returns a Server whose cache uses a clock controlled by the returned function.
+func newTestServer() (*Server, func(time.Duration)) {
+	s := New(DefaultOptions())
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	s.cache.now = func() time.Time { return now }
+	return s, func(d time.Duration) { now = now.Add(d) }
+}
+
+// TestValidate tests the caching behavior of Validate.
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Success is cached for the TTL", func(t *testing.T) {
+		var hits atomic.Int64
+		upstream := newUpstream(http.StatusOK, `{"origins": ["https://example.com"]}`, &hits)
+		defer upstream.Close()
+		s, advance := newTestServer()
+
+		first, _ := s.Validate(ctx, upstream.URL, "https://example.com")
+		if !first.Authorized || first.Cached {
+			t.Errorf("Expected an uncached authorized response, got %+v", first)
+		}
+		second, _ := s.Validate(ctx, upstream.URL, "https://example.com")
+		if !second.Authorized || !second.Cached {
+			t.Errorf("Expected a cached authorized response, got %+v", second)
+		}
+		if hits.Load() != 1 {
+			t.Errorf("Expected 1 upstream request, got %d", hits.Load())
+		}
+
+		advance(DefaultTTL)
+		s.Validate(ctx, upstream.URL, "https://example.com")
+		if hits.Load() != 2 {
+			t.Errorf("Expected 2 upstream requests after the TTL, got %d", hits.Load())
+		}
+	})
+
+	t.Run("Unauthorized origin is cached for the negative TTL", func(t *testing.T) {
+		var hits atomic.Int64
+		upstream := newUpstream(http.StatusOK, `{"origins": ["https://example.com"]}`, &hits)
+		defer upstream.Close()
+		s, advance := newTestServer()
+
+		for i := 0; i < 5; i++ {
+			resp, _ := s.Validate(ctx, upstream.URL, "https://evil.example.net")
+			if resp.Status != counter.StatusBadRelyingPartyIDNoJSONMatch.String() {
+				t.Fatalf("Expected %v, got %+v", counter.StatusBadRelyingPartyIDNoJSONMatch, resp)
+			}
+		}
+		if hits.Load() != 1 {
+			t.Errorf("Expected 1 upstream request, got %d", hits.Load())
+		}
+
+		// Once the negative outcome expires, the cached document is refreshed once
+		advance(DefaultNegativeTTL)
+		s.Validate(ctx, upstream.URL, "https://evil.example.net")
+		s.Validate(ctx, upstream.URL, "https://evil.example.net")
+		if hits.Load() != 2 {
+			t.Errorf("Expected 2 upstream requests after the negative TTL, got %d", hits.Load())
+		}
+
+		// Authorized origins are still answered from the cached document
+		if resp, _ := s.Validate(ctx, upstream.URL, "https://example.com"); !resp.Authorized || !resp.Cached {
+			t.Errorf("Expected a cached authorized response, got %+v", resp)
+		}
+	})
+
+	t.Run("Fetch failure is cached for the negative TTL", func(t *testing.T) {
+		var hits atomic.Int64
+		upstream := newUpstream(http.StatusInternalServerError, "", &hits)
+		defer upstream.Close()
+		s, advance := newTestServer()
+
+		for i := 0; i < 3; i++ {
+			if resp, _ := s.Validate(ctx, upstream.URL, "https://example.com"); resp.Error == "" || resp.Status != "" {
+				t.Fatalf("Expected a fetch failure, got %+v", resp)
+			}
+		}
+		if hits.Load() != 1 {
+			t.Errorf("Expected 1 upstream request, got %d", hits.Load())
+		}
+
+		advance(DefaultNegativeTTL)
+		s.Validate(ctx, upstream.URL, "https://example.com")
+		if hits.Load() != 2 {
+			t.Errorf("Expected 2 upstream requests after the negative TTL, got %d", hits.Load())
+		}
+	})
+
+	t.Run("Circuit opens after repeated failures", func(t *testing.T) {
+		var hits atomic.Int64
+		upstream := newUpstream(http.StatusInternalServerError, "", &hits)
+		defer upstream.Close()
+		opts := DefaultOptions()
+		opts.Breaker = breaker.New(2, time.Hour)
+		s := New(opts)
+		now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+		s.cache.now = func() time.Time { return now }
+
+		for i := 0; i < 4; i++ {
+			resp, _ := s.Validate(ctx, upstream.URL, "https://example.com")
+			if resp.CircuitOpen != (i >= 2) {
+				t.Errorf("Attempt %d: expected circuit open to be %v, got %+v", i, i >= 2, resp)
+			}
+			now = now.Add(DefaultNegativeTTL)
+		}
+		if hits.Load() != 2 {
+			t.Errorf("Expected 2 upstream requests, got %d", hits.Load())
+		}
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		release := make(chan struct{})
+		var hits atomic.Int64
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			<-release
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"origins": ["https://example.com"]}`))
+		}))
+		defer upstream.Close()
+		s, _ := newTestServer()
+
+		short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := s.Validate(short, upstream.URL, "https://example.com"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+		}
+
+		// The abandoned fetch completes in the background and is shared with later requests
+		close(release)
+		resp, err := s.Validate(ctx, upstream.URL, "https://example.com")
+		if err != nil || !resp.Authorized {
+			t.Errorf("Expected an authorized response, got %+v, %v", resp, err)
+		}
+		if hits.Load() != 1 {
+			t.Errorf("Expected 1 upstream request, got %d", hits.Load())
+		}
+	})
+
+	t.Run("Invalid document", func(t *testing.T) {
+		var hits atomic.Int64
+		upstream := newUpstream(http.StatusOK, `{"origins": "bar"}`, &hits)
+		defer upstream.Close()
+		s, _ := newTestServer()
+
+		resp, _ := s.Validate(ctx, upstream.URL, "https://example.com")
+		if resp.Status != counter.StatusBadRelyingPartyIDJSONParseError.String() {
+			t.Errorf("Expected %v, got %+v", counter.StatusBadRelyingPartyIDJSONParseError, resp)
+		}
+	})
+
+	t.Run("Label limit follows the fetch options", func(t *testing.T) {
+		var hits atomic.Int64
+		upstream := newUpstream(http.StatusOK, `{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://f.com"]}`, &hits)
+		defer upstream.Close()
+
+		s, _ := newTestServer()
+		if resp, _ := s.Validate(ctx, upstream.URL, "https://f.com"); resp.Authorized {
+			t.Errorf("Expected the sixth label to be beyond the default limit, got %+v", resp)
+		}
+
+		opts := DefaultOptions()
+		opts.Fetch.MaxLabels = 8
+		if resp, _ := New(opts).Validate(ctx, upstream.URL, "https://f.com"); !resp.Authorized {
+			t.Errorf("Expected the sixth label to be within a limit of 8, got %+v", resp)
+		}
+	})
+}
+
+// TestServeHTTP tests the HTTP endpoints.
+func TestServeHTTP(t *testing.T) {
+	var hits atomic.Int64
+	upstream := newUpstream(http.StatusOK, `{"origins": ["https://example.com", "https://example.org"]}`, &hits)
+	defer upstream.Close()
+	failing := newUpstream(http.StatusNotFound, "", &hits)
+	defer failing.Close()
+
+	s, _ := newTestServer()
+	api := httptest.NewServer(s)
+	defer api.Close()
+
+	get := func(t *testing.T, path string, query url.Values, v any) int {
+		t.Helper()
+		resp, err := http.Get(api.URL + path + "?" + query.Encode())
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	t.Run("Validate", func(t *testing.T) {
+		var resp ValidateResponse
+		code := get(t, "/v1/validate", url.Values{"domain": {upstream.URL}, "origin": {"https://example.org"}}, &resp)
+		if code != http.StatusOK || !resp.Authorized || resp.LabelCount != 1 {
+			t.Errorf("Unexpected response %d %+v", code, resp)
+		}
+	})
+
+	t.Run("Count", func(t *testing.T) {
+		var resp CountResponse
+		code := get(t, "/v1/count", url.Values{"domain": {upstream.URL}}, &resp)
+		if code != http.StatusOK || resp.LabelCount != 1 {
+			t.Errorf("Unexpected response %d %+v", code, resp)
+		}
+	})
+
+	t.Run("Upstream failure", func(t *testing.T) {
+		var resp ValidateResponse
+		code := get(t, "/v1/validate", url.Values{"domain": {failing.URL}, "origin": {"https://example.org"}}, &resp)
+		if code != http.StatusBadGateway || resp.Error == "" {
+			t.Errorf("Unexpected response %d %+v", code, resp)
+		}
+	})
+
+	t.Run("OpenAPI document", func(t *testing.T) {
+		var doc struct {
+			OpenAPI string                     `json:"openapi"`
+			Paths   map[string]json.RawMessage `json:"paths"`
+		}
+		code := get(t, "/openapi.json", nil, &doc)
+		if code != http.StatusOK || doc.OpenAPI == "" {
+			t.Fatalf("Unexpected response %d %+v", code, doc)
+		}
+		for _, path := range []string{"/v1/validate", "/v1/count", "/healthz", "/openapi.json"} {
+			if _, ok := doc.Paths[path]; !ok {
+				t.Errorf("Expected the OpenAPI document to describe %s", path)
+			}
+		}
+	})
+
+	t.Run("Missing parameters", func(t *testing.T) {
+		var resp ErrorResponse
+		code := get(t, "/v1/validate", url.Values{"domain": {upstream.URL}}, &resp)
+		if code != http.StatusBadRequest || resp.Error == "" {
+			t.Errorf("Unexpected response %d %+v", code, resp)
+		}
+	})
+}
+
+// TestAccessLog tests that requests are logged with credentials redacted.
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	handler := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}), logger, AccessLogOptions{
+		Headers:      []string{"X-Request-Id", "authorization"},
+		RedactParams: []string{"Session"},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/validate?domain=a.com&api_key=secret1&session=secret2&origin=https://a.com", nil)
+	req.Header.Set("X-Request-Id", "abc")
+	req.Header.Set("Authorization", "Bearer secret3")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("Expected credentials to be redacted, got %s", buf.String())
+	}
+	var entry struct {
+		Method  string            `json:"method"`
+		Path    string            `json:"path"`
+		Query   string            `json:"query"`
+		Status  int               `json:"status"`
+		Bytes   int               `json:"bytes"`
+		Headers map[string]string `json:"headers"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to decode log entry: %v", err)
+	}
+	if entry.Method != http.MethodGet || entry.Path != "/v1/validate" || entry.Status != http.StatusTeapot || entry.Bytes != 15 {
+		t.Errorf("Unexpected log entry %+v", entry)
+	}
+	query, _ := url.ParseQuery(entry.Query)
+	if query.Get("api_key") != Redacted || query.Get("session") != Redacted || query.Get("domain") != "a.com" {
+		t.Errorf("Unexpected query %q", entry.Query)
+	}
+	if entry.Headers["X-Request-Id"] != "abc" || entry.Headers["Authorization"] != Redacted {
+		t.Errorf("Unexpected headers %v", entry.Headers)
+	}
+}
@@ -0,0 +1,284 @@
+// Package simulate applies proposed edits to a .well-known/webauthn document in memory,
+// and reports what they would change before the served file is touched: the labels the
+// document counts, the caller origins that would be authorized or broken, and what each
+// browser would decide for them.
+package simulate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/browser"
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/docdiff"
+	"github.com/developmeh/passkey-origin-validator/internal/generate"
+)
+
+// Edit is a set of proposed changes to the origins of a document.
+type Edit struct {
+	// Add are origins appended to the end of the list, in order.
+	Add []string
+	// Remove are origins taken out of the list, wherever they are listed.
+	Remove []string
+}
+
+// ParsePatch reads an edit from r, one origin per line: "+origin" adds it and "-origin"
+// removes it. Blank lines and lines starting with '#' are skipped. An origin may be
+// quoted and followed by a comma, and the "+++" and "---" headers of a unified diff are
+// skipped, so that a diff of the document itself can be used as a patch.
+func ParsePatch(r io.Reader) (Edit, error) {
+	var edit Edit
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") || strings.HasPrefix(line, "@@"):
+			continue
+		case line[0] != '+' && line[0] != '-':
+			// Context lines of a diff are indented
+			if strings.HasPrefix(scanner.Text(), " ") {
+				continue
+			}
+			return Edit{}, fmt.Errorf("line %d: expected +origin or -origin, got %q", n, line)
+		}
+
+		// Skip the lines of a diff that are not origins, such as the origins key
+		origin := strings.Trim(strings.TrimSpace(line[1:]), `",`)
+		if origin == "" || strings.ContainsAny(origin, "\"[]{} ") {
+			continue
+		}
+		if line[0] == '+' {
+			edit.Add = append(edit.Add, origin)
+		} else {
+			edit.Remove = append(edit.Remove, origin)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Edit{}, fmt.Errorf("failed to read patch: %w", err)
+	}
+	return edit, nil
+}
+
+// Applied is a document with an edit applied to it.
+type Applied struct {
+	// JSON is the edited document, indented with two spaces. Members other than origins
+	// are kept.
+	JSON []byte
+	// Added are the origins that were added, normalized.
+	Added []string
+	// Removed are the entries that were removed, as they were listed.
+	Removed []string
+	// NotListed are the origins to remove that no entry matched.
+	NotListed []string
+	// AlreadyListed are the origins to add that an entry already matched.
+	AlreadyListed []string
+}
+
+// Apply applies an edit to a document. An origin matches an entry when they are equal
+// once normalized, so that "example.com" removes "https://example.com". It returns an
+// error when the document is not valid JSON or has no origins array.
+func Apply(document []byte, edit Edit) (*Applied, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(document, &members); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	raw, ok := members["origins"]
+	if !ok {
+		return nil, errors.New("failed to parse JSON: missing origins array")
+	}
+	var origins []string
+	if err := json.Unmarshal(raw, &origins); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	applied := &Applied{}
+	remove := make(map[string]bool)
+	for _, origin := range edit.Remove {
+		remove[key(origin)] = true
+	}
+	matched := make(map[string]bool)
+	var kept []string
+	for _, origin := range origins {
+		if k := key(origin); remove[k] {
+			matched[k] = true
+			applied.Removed = append(applied.Removed, origin)
+			continue
+		}
+		kept = append(kept, origin)
+	}
+	for _, origin := range edit.Remove {
+		if !matched[key(origin)] {
+			applied.NotListed = append(applied.NotListed, origin)
+		}
+	}
+
+	listed := make(map[string]bool)
+	for _, origin := range kept {
+		listed[key(origin)] = true
+	}
+	for _, origin := range edit.Add {
+		if k := key(origin); listed[k] {
+			applied.AlreadyListed = append(applied.AlreadyListed, origin)
+			continue
+		}
+		normalized, err := generate.Normalize(origin)
+		if err != nil {
+			// Keep it as given, so that the simulation shows browsers ignoring it
+			normalized = origin
+		}
+		listed[key(origin)] = true
+		kept = append(kept, normalized)
+		applied.Added = append(applied.Added, normalized)
+	}
+
+	if kept == nil {
+		kept = []string{}
+	}
+	fixed, err := json.Marshal(kept)
+	if err != nil {
+		return nil, err
+	}
+	members["origins"] = fixed
+	body, err := json.Marshal(members)
+	if err != nil {
+		return nil, err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		return nil, err
+	}
+	indented.WriteByte('\n')
+	applied.JSON = indented.Bytes()
+	return applied, nil
+}
+
+// key returns the form origins are matched by: normalized if possible, as given otherwise.
+func key(origin string) string {
+	if normalized, err := generate.Normalize(origin); err == nil {
+		return normalized
+	}
+	return strings.TrimSpace(origin)
+}
+
+// Outcomes are what each browser decides for a caller origin before and after an edit.
+type Outcomes struct {
+	Origin string
+	Before []browser.Outcome
+	After  []browser.Outcome
+}
+
+// Changed reports whether any browser decides differently after the edit.
+func (o Outcomes) Changed() bool {
+	for i := range o.Before {
+		if o.Before[i].String() != o.After[i].String() {
+			return true
+		}
+	}
+	return false
+}
+
+// Result is what an edit would change.
+type Result struct {
+	Applied *Applied
+	// Before and After are the document as it is and as it would be.
+	Before *counter.LabelCount
+	After  *counter.LabelCount
+	// Diff is the semantic difference between the documents, including the caller
+	// origins whose status changes.
+	Diff *docdiff.Diff
+	// Callers are the outcomes of each browser for the given caller origins, followed by
+	// those of every other origin whose outcome changes in some browser, in sorted order.
+	Callers []Outcomes
+}
+
+// Simulate applies an edit to the document of base, and compares the document as it is
+// with the document as it would be, for callers and every origin either lists, with
+// each of profiles. The edited document is evaluated as if it were served the same way
+// as base.
+func Simulate(base *counter.LabelCount, edit Edit, callers []string, profiles []browser.Profile) (*Result, error) {
+	applied, err := Apply([]byte(base.RawJSON), edit)
+	if err != nil {
+		return nil, err
+	}
+	after, err := recount(base, applied.JSON)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := docdiff.CompareWithMaxLabels([]byte(base.RawJSON), applied.JSON, callers, base.MaxLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Applied: applied, Before: base, After: after, Diff: diff}
+	evaluate := func(origin string) Outcomes {
+		return Outcomes{
+			Origin: origin,
+			Before: browser.Evaluate(profiles, origin, base),
+			After:  browser.Evaluate(profiles, origin, after),
+		}
+	}
+	seen := make(map[string]bool)
+	for _, caller := range callers {
+		if !seen[caller] {
+			seen[caller] = true
+			result.Callers = append(result.Callers, evaluate(caller))
+		}
+	}
+	var others []string
+	for _, list := range [][]string{base.Origins, after.Origins} {
+		for _, origin := range list {
+			if !seen[origin] {
+				seen[origin] = true
+				others = append(others, origin)
+			}
+		}
+	}
+	sort.Strings(others)
+	for _, origin := range others {
+		if outcomes := evaluate(origin); outcomes.Changed() {
+			result.Callers = append(result.Callers, outcomes)
+		}
+	}
+	return result, nil
+}
+
+// recount returns the label count of document as if it were served the way base was.
+func recount(base *counter.LabelCount, document []byte) (*counter.LabelCount, error) {
+	var webAuthnResp counter.WebAuthnResponse
+	if err := json.Unmarshal(document, &webAuthnResp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	after := *base
+	after.RawJSON = string(document)
+	after.Origins = webAuthnResp.Origins
+	after.UniqueLabels = make(map[string]bool)
+	after.LabelsFound = nil
+	after.AndroidOrigins = nil
+	for _, origin := range webAuthnResp.Origins {
+		if counter.IsAndroidOrigin(origin) {
+			if _, err := counter.ParseAndroidOrigin(origin); err == nil {
+				after.AndroidOrigins = append(after.AndroidOrigins, origin)
+			}
+			continue
+		}
+		if label, ok := counter.OriginLabel(origin); ok && !after.UniqueLabels[label] {
+			after.UniqueLabels[label] = true
+			after.LabelsFound = append(after.LabelsFound, label)
+		}
+	}
+	after.Count = len(after.UniqueLabels)
+	maxLabels := after.MaxLabels
+	if maxLabels <= 0 {
+		maxLabels = counter.MaxLabels
+	}
+	after.ExceedsLimit = after.Count > maxLabels
+	return &after, nil
+}
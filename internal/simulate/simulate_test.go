@@ -0,0 +1,104 @@
+package simulate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/browser"
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// TestParsePatch tests reading edits from a list and from a diff of a document.
+func TestParsePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		want    Edit
+		wantErr bool
+	}{
+		{
+			"list",
+			"# Move the shop\n+https://shop.example.fr\n-https://shop.example.net\n\n",
+			Edit{Add: []string{"https://shop.example.fr"}, Remove: []string{"https://shop.example.net"}},
+			false,
+		},
+		{
+			"diff",
+			"--- a/webauthn.json\n+++ b/webauthn.json\n@@ -1,4 +1,4 @@\n {\n   \"origins\": [\n-    \"https://a.com\",\n+    \"https://b.com\",\n     \"https://c.com\"\n   ]\n",
+			Edit{Add: []string{"https://b.com"}, Remove: []string{"https://a.com"}},
+			false,
+		},
+		{"invalid", "https://a.com\n", Edit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePatch(strings.NewReader(tt.patch))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePatch() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestApply tests editing a document in memory.
+func TestApply(t *testing.T) {
+	document := []byte(`{"origins": ["https://a.com", "https://B.com:443"], "comment": "kept"}`)
+	applied, err := Apply(document, Edit{Add: []string{"c.com", "https://a.com"}, Remove: []string{"https://b.com", "https://d.com"}})
+	if err != nil {
+		t.Fatalf("Apply returned an error: %v", err)
+	}
+	expected := "{\n  \"comment\": \"kept\",\n  \"origins\": [\n    \"https://a.com\",\n    \"https://c.com\"\n  ]\n}\n"
+	if string(applied.JSON) != expected {
+		t.Errorf("Expected document:\n%s\ngot:\n%s", expected, applied.JSON)
+	}
+	if !reflect.DeepEqual(applied.Removed, []string{"https://B.com:443"}) || !reflect.DeepEqual(applied.NotListed, []string{"https://d.com"}) ||
+		!reflect.DeepEqual(applied.AlreadyListed, []string{"https://a.com"}) {
+		t.Errorf("Unexpected edits %+v", applied)
+	}
+
+	if _, err := Apply([]byte(`{}`), Edit{}); err == nil {
+		t.Error("Expected an error for a document without origins")
+	}
+}
+
+// TestSimulate tests the outcomes of an edit that adds a sixth label.
+func TestSimulate(t *testing.T) {
+	base := &counter.LabelCount{
+		URL:         "https://example.com/.well-known/webauthn",
+		RawJSON:     `{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com"]}`,
+		Origins:     []string{"https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com"},
+		Count:       5,
+		MaxLabels:   counter.MaxLabels,
+		ContentType: "application/json",
+	}
+	profiles, err := browser.Parse([]string{"chromium", "firefox"})
+	if err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+
+	sim, err := Simulate(base, Edit{Add: []string{"https://f.com"}, Remove: []string{"https://e.com"}}, []string{"https://a.com"}, profiles)
+	if err != nil {
+		t.Fatalf("Simulate returned an error: %v", err)
+	}
+	if sim.After.Count != 5 || sim.After.ExceedsLimit {
+		t.Errorf("Expected 5 labels after the edit, got %d", sim.After.Count)
+	}
+	if !sim.Diff.Regressed() {
+		t.Error("Expected removing e.com to take its authorization away")
+	}
+
+	var origins []string
+	for _, outcomes := range sim.Callers {
+		origins = append(origins, outcomes.Origin)
+	}
+	if !reflect.DeepEqual(origins, []string{"https://a.com", "https://e.com", "https://f.com"}) {
+		t.Errorf("Expected the caller and the changed origins, got %v", origins)
+	}
+	if f := sim.Callers[2]; f.Before[0].Allowed() || !f.After[0].Allowed() || f.After[1].Supported {
+		t.Errorf("Expected f.com to become allowed in chromium only, got %+v", f)
+	}
+}
@@ -0,0 +1,293 @@
+// Package snapshot stores fetched .well-known/webauthn documents over time, so that the
+// history of a relying party's origins can be reviewed and a regression traced back to
+// the fetch that first saw it.
+//
+// A store is a directory. Documents are content-addressed files under objects/, named by
+// the SHA-256 of their bytes, so that a document that does not change between fetches is
+// stored once. Each domain has a JSON Lines index under snapshots/ with one entry per
+// fetch, holding its time, fetch metadata and the digest of the document it saw.
+package snapshot
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/docdiff"
+)
+
+// Snapshot is one fetch of a domain's document.
+type Snapshot struct {
+	Domain      string    `json:"domain"`
+	URL         string    `json:"url,omitempty"`
+	Time        time.Time `json:"time"`
+	ContentType string    `json:"content_type,omitempty"`
+	// Error is why the document could not be fetched or parsed, if it could not.
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Digest is the hex SHA-256 of the document, or empty if no document was received.
+	Digest string `json:"digest,omitempty"`
+	Size   int    `json:"size,omitempty"`
+}
+
+// ShortDigest returns the first 12 characters of the snapshot's digest.
+func (s Snapshot) ShortDigest() string {
+	if len(s.Digest) > 12 {
+		return s.Digest[:12]
+	}
+	return s.Digest
+}
+
+// Store is a directory of snapshots.
+type Store struct {
+	dir string
+}
+
+// DefaultDir returns the default store directory in the user's configuration directory.
+func DefaultDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "passkey-origin-validator", "snapshots")
+}
+
+// Open returns the Store in dir, creating it if necessary.
+func Open(dir string) (*Store, error) {
+	for _, sub := range []string{"objects", "snapshots"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create snapshot store: %w", err)
+		}
+	}
+	return &Store{dir: dir}, nil
+}
+
+// key returns the name a domain's snapshots are stored under: its lowercase host and port.
+func key(domain string) string {
+	if !strings.HasPrefix(domain, "https://") && !strings.HasPrefix(domain, "http://") {
+		domain = "https://" + domain
+	}
+	if parsedURL, err := url.Parse(domain); err == nil && parsedURL.Host != "" {
+		domain = parsedURL.Host
+	}
+	return strings.ToLower(domain)
+}
+
+// indexPath returns the path of the index of a domain's snapshots.
+func (s *Store) indexPath(domain string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(key(domain))
+	return filepath.Join(s.dir, "snapshots", name+".jsonl")
+}
+
+// objectPath returns the path of the document with the given digest.
+func (s *Store) objectPath(digest string) string {
+	return filepath.Join(s.dir, "objects", digest)
+}
+
+// Save stores the result of fetching domain at the given time and returns its snapshot.
+func (s *Store) Save(domain string, result *counter.LabelCount, at time.Time) (Snapshot, error) {
+	snap := Snapshot{
+		Domain:      key(domain),
+		URL:         result.URL,
+		Time:        at.UTC(),
+		ContentType: result.ContentType,
+		Error:       result.ErrorMessage,
+		Warnings:    result.Warnings,
+	}
+
+	// Store the document once, under its digest
+	if result.RawJSON != "" {
+		sum := sha256.Sum256([]byte(result.RawJSON))
+		snap.Digest = hex.EncodeToString(sum[:])
+		snap.Size = len(result.RawJSON)
+		if err := s.writeObject(snap.Digest, []byte(result.RawJSON)); err != nil {
+			return Snapshot{}, err
+		}
+	}
+
+	// Append the snapshot to the domain's index
+	line, err := json.Marshal(snap)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	f, err := os.OpenFile(s.indexPath(domain), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to open snapshot index: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to write snapshot index: %w", err)
+	}
+	return snap, nil
+}
+
+// writeObject writes a document unless it is already stored.
+func (s *Store) writeObject(digest string, data []byte) error {
+	path := s.objectPath(digest)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	// Write to a temporary file first so that a partial object is never visible
+	tmp, err := os.CreateTemp(filepath.Dir(path), digest+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// History returns the snapshots of domain, oldest first. A domain that was never saved
+// has no snapshots.
+func (s *Store) History(domain string) ([]Snapshot, error) {
+	f, err := os.Open(s.indexPath(domain))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot index: %w", err)
+	}
+	defer f.Close()
+
+	var snaps []Snapshot
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &snap); err != nil {
+			return nil, fmt.Errorf("snapshot index line %d: %w", lineNum, err)
+		}
+		snaps = append(snaps, snap)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot index: %w", err)
+	}
+	return snaps, nil
+}
+
+// Document returns the document a snapshot saw.
+func (s *Store) Document(snap Snapshot) ([]byte, error) {
+	if snap.Digest == "" {
+		return nil, fmt.Errorf("the snapshot of %s at %s has no document", snap.Domain, snap.Time.Format(time.RFC3339))
+	}
+	data, err := os.ReadFile(s.objectPath(snap.Digest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return data, nil
+}
+
+// Change is a difference between two consecutive snapshots of a domain.
+type Change struct {
+	From Snapshot
+	To   Snapshot
+	// Diff is the semantic difference between the documents, or nil when either
+	// snapshot has no document.
+	Diff *docdiff.Diff
+}
+
+// Regressed reports whether the change took any origin's authorization away, including
+// the document becoming unavailable.
+func (c Change) Regressed() bool {
+	if c.Diff == nil {
+		return c.From.Digest != "" && c.To.Digest == ""
+	}
+	return c.Diff.Regressed()
+}
+
+// Changes returns the changes between consecutive snapshots of domain, oldest first.
+// Snapshots that saw the same document, or failed the same way, as the one before them
+// are not changes. Validation outcomes are compared for the given caller origins as well
+// as for every listed origin, with a limit of maxLabels labels, or counter.MaxLabels if
+// it is not positive.
+func (s *Store) Changes(domain string, callerOrigins []string, maxLabels int) ([]Change, error) {
+	snaps, err := s.History(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for i := 1; i < len(snaps); i++ {
+		from, to := snaps[i-1], snaps[i]
+		if from.Digest == to.Digest && (from.Digest != "" || from.Error == to.Error) {
+			continue
+		}
+
+		change := Change{From: from, To: to}
+		if from.Digest != "" && to.Digest != "" {
+			before, err := s.Document(from)
+			if err != nil {
+				return nil, err
+			}
+			after, err := s.Document(to)
+			if err != nil {
+				return nil, err
+			}
+			// A document that is not valid JSON has no origins to compare
+			if d, err := docdiff.CompareWithMaxLabels(before, after, callerOrigins, maxLabels); err == nil {
+				if d.Empty() {
+					continue
+				}
+				change.Diff = d
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// FormatChanges formats changes as a human-readable history.
+func FormatChanges(changes []Change) string {
+	var sb strings.Builder
+	for _, change := range changes {
+		sb.WriteString(fmt.Sprintf("%s (%s) -> %s (%s)", change.From.Time.Format(time.RFC3339), describe(change.From),
+			change.To.Time.Format(time.RFC3339), describe(change.To)))
+		if change.Regressed() {
+			sb.WriteString(" REGRESSION")
+		}
+		sb.WriteString("\n")
+
+		switch {
+		case change.Diff != nil:
+			sb.WriteString(docdiff.Format(change.Diff))
+		case change.To.Digest == "":
+			sb.WriteString(fmt.Sprintf("  fetch failed: %s\n", change.To.Error))
+		case change.From.Digest == "":
+			sb.WriteString("  fetch recovered\n")
+		default:
+			sb.WriteString("  the document changed but could not be compared\n")
+		}
+	}
+	return sb.String()
+}
+
+// describe returns a short description of a snapshot's document.
+func describe(snap Snapshot) string {
+	if snap.Digest == "" {
+		return "failed"
+	}
+	return snap.ShortDigest()
+}
@@ -0,0 +1,85 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// TestStore tests saving snapshots and reviewing their history.
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+
+	start := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	results := []*counter.LabelCount{
+		{URL: "https://example.com/.well-known/webauthn", RawJSON: `{"origins": ["https://a.com", "https://b.com"]}`},
+		{URL: "https://example.com/.well-known/webauthn", RawJSON: `{"origins": ["https://a.com", "https://b.com"]}`},
+		{URL: "https://example.com/.well-known/webauthn", RawJSON: `{"origins": ["https://b.com", "https://a.com"]}`},
+		{URL: "https://example.com/.well-known/webauthn", RawJSON: `{"origins": ["https://a.com"]}`},
+		{URL: "https://example.com/.well-known/webauthn", ErrorMessage: "HTTP request failed with status code: 404"},
+		{URL: "https://example.com/.well-known/webauthn", RawJSON: `{"origins": ["https://a.com"]}`},
+	}
+	for i, result := range results {
+		// Domains are stored by their lowercase host
+		domain := "example.com"
+		if i%2 == 1 {
+			domain = "https://Example.com/"
+		}
+		if _, err := store.Save(domain, result, start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatalf("Save returned an error: %v", err)
+		}
+	}
+
+	snaps, err := store.History("example.com")
+	if err != nil {
+		t.Fatalf("History returned an error: %v", err)
+	}
+	if len(snaps) != 6 || snaps[0].Digest != snaps[1].Digest || snaps[4].Digest != "" {
+		t.Fatalf("Unexpected history %+v", snaps)
+	}
+
+	// Identical documents are stored once
+	objects, err := os.ReadDir(filepath.Join(dir, "objects"))
+	if err != nil {
+		t.Fatalf("ReadDir returned an error: %v", err)
+	}
+	if len(objects) != 3 {
+		t.Errorf("Expected 3 stored documents, got %d", len(objects))
+	}
+
+	changes, err := store.Changes("example.com", nil, 0)
+	if err != nil {
+		t.Fatalf("Changes returned an error: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changes, got %d:\n%s", len(changes), FormatChanges(changes))
+	}
+	if !changes[0].Regressed() || changes[0].Diff.OriginsRemoved[0] != "https://b.com" || !changes[0].From.Time.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("Expected the removal of https://b.com to be a regression, got %+v", changes[0])
+	}
+	if !changes[1].Regressed() || changes[1].Diff != nil {
+		t.Errorf("Expected the failure to be a regression, got %+v", changes[1])
+	}
+	if changes[2].Regressed() {
+		t.Errorf("Expected the recovery not to be a regression, got %+v", changes[2])
+	}
+
+	formatted := FormatChanges(changes)
+	for _, expected := range []string{"REGRESSION", "  - https://b.com", "fetch failed: HTTP request failed with status code: 404", "fetch recovered"} {
+		if !strings.Contains(formatted, expected) {
+			t.Errorf("Expected the history to contain %q, got:\n%s", expected, formatted)
+		}
+	}
+
+	if snaps, err := store.History("other.com"); err != nil || snaps != nil {
+		t.Errorf("Expected no history for a domain that was never saved, got %v, %v", snaps, err)
+	}
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the size in bytes of the key of an encrypted store.
+const KeySize = 32
+
+// sealedPrefix marks an encrypted value and the version of its format.
+const sealedPrefix = "enc:v1:"
+
+// keyCheckText is encrypted into the meta table, so that a wrong key is detected when
+// the store is opened rather than when its results are read.
+const keyCheckText = "passkey-origin-validator store"
+
+// ParseKey decodes a base64-encoded store key, such as the output of
+// "openssl rand -base64 32".
+func ParseKey(text string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid store key: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid store key: expected %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// sealer encrypts and decrypts the values of an encrypted store with AES-256-GCM. Each
+// value is bound to its column, so that values cannot be moved between columns.
+type sealer struct {
+	aead cipher.AEAD
+	// nonceKey derives the nonces of deterministically encrypted values.
+	nonceKey []byte
+}
+
+// newSealer returns a sealer for key. The encryption and nonce keys are derived from it
+// separately.
+func newSealer(key []byte) (*sealer, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid store key: expected %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(deriveKey(key, "encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead, nonceKey: deriveKey(key, "nonce")}, nil
+}
+
+// deriveKey derives a subkey for purpose from key.
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("passkey-origin-validator store " + purpose))
+	return mac.Sum(nil)
+}
+
+// seal encrypts the value of column with a random nonce.
+func (s *sealer) seal(column, value string) string {
+	nonce := make([]byte, s.aead.NonceSize())
+	rand.Read(nonce)
+	return s.sealWithNonce(column, value, nonce)
+}
+
+// sealDeterministic encrypts the value of column with a nonce derived from the value, so
+// that equal values encrypt equally and can be looked up. It reveals which values are
+// equal, and nothing else.
+func (s *sealer) sealDeterministic(column, value string) string {
+	mac := hmac.New(sha256.New, s.nonceKey)
+	mac.Write([]byte(column + "\x00" + value))
+	return s.sealWithNonce(column, value, mac.Sum(nil)[:s.aead.NonceSize()])
+}
+
+func (s *sealer) sealWithNonce(column, value string, nonce []byte) string {
+	sealed := s.aead.Seal(nonce, nonce, []byte(value), []byte(column))
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed)
+}
+
+// open decrypts a value of column.
+func (s *sealer) open(column, value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return "", errors.New("value is not encrypted")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return "", errors.New("value is not encrypted")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, []byte(column))
+	if err != nil {
+		return "", errors.New("value cannot be decrypted with this key")
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+)
+
+// ExportedResult is a result as it is exported, with the ID of its scan.
+type ExportedResult struct {
+	ScanID int64 `json:"scan_id"`
+	batch.Record
+}
+
+// ExportResult reports what Export wrote.
+type ExportResult struct {
+	Results int
+	Daily   int
+	// Partitions is the number of files written, one per table and day.
+	Partitions int
+}
+
+// Export writes the results recorded since the start of the UTC day of since, and the
+// daily summaries of those days, as newline-delimited JSON partitioned by day:
+//
+//	dir/results/date=YYYY-MM-DD/results.jsonl
+//	dir/daily/date=YYYY-MM-DD/daily.jsonl
+//
+// This is the Hive partitioning layout that BigQuery, Athena and Spark load directly.
+// A zero since exports everything. Exporting a day again replaces its files, so a daily
+// job can export the previous day and re-exports are idempotent. Encrypted values are
+// decrypted.
+func (s *Store) Export(dir string, since time.Time) (ExportResult, error) {
+	var result ExportResult
+	day := ""
+	if !since.IsZero() {
+		day = since.UTC().Format(dayFormat)
+	}
+
+	results := &partitionWriter{dir: filepath.Join(dir, "results"), name: "results.jsonl"}
+	defer results.close()
+	rows, err := s.db.Query(`SELECT `+resultColumns+`
+		FROM results WHERE timestamp >= ? ORDER BY timestamp, id`, day)
+	if err != nil {
+		return result, fmt.Errorf("failed to query results: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		scanID, record, err := s.scanResult(rows)
+		if err != nil {
+			return result, err
+		}
+		if err := results.write(record.Timestamp.UTC().Format(dayFormat), ExportedResult{scanID, record}); err != nil {
+			return result, err
+		}
+		result.Results++
+	}
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("failed to read result: %w", err)
+	}
+	if err := results.close(); err != nil {
+		return result, err
+	}
+	rows.Close()
+
+	daily := &partitionWriter{dir: filepath.Join(dir, "daily"), name: "daily.jsonl"}
+	defer daily.close()
+	rows, err = s.db.Query(`SELECT domain, day, checks, passed, exceeds_limit, invalid, failed, skipped,
+		min_label_count, max_label_count FROM daily WHERE day >= ? ORDER BY day`, day)
+	if err != nil {
+		return result, fmt.Errorf("failed to query daily summaries: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d DailySummary
+		if err := rows.Scan(&d.Domain, &d.Day, &d.Checks, &d.Passed, &d.ExceedsLimit, &d.Invalid, &d.Failed,
+			&d.Skipped, &d.MinLabelCount, &d.MaxLabelCount); err != nil {
+			return result, fmt.Errorf("failed to read daily summary: %w", err)
+		}
+		if d.Domain, err = s.open("domain", d.Domain); err != nil {
+			return result, fmt.Errorf("failed to read daily summary: %w", err)
+		}
+		if err := daily.write(d.Day, d); err != nil {
+			return result, err
+		}
+		result.Daily++
+	}
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("failed to read daily summary: %w", err)
+	}
+	if err := daily.close(); err != nil {
+		return result, err
+	}
+
+	result.Partitions = results.partitions + daily.partitions
+	return result, nil
+}
+
+// partitionWriter writes JSON lines to one file per day. Lines must be written in order
+// of day, so that only one file is open at a time.
+type partitionWriter struct {
+	dir  string
+	name string
+	// day is the day of the open file, if any.
+	day        string
+	file       *os.File
+	encoder    *json.Encoder
+	partitions int
+}
+
+// write writes v as a line of the file of day.
+func (p *partitionWriter) write(day string, v any) error {
+	if p.file == nil || day != p.day {
+		if err := p.close(); err != nil {
+			return err
+		}
+		partition := filepath.Join(p.dir, "date="+day)
+		if err := os.MkdirAll(partition, 0755); err != nil {
+			return fmt.Errorf("failed to create partition: %w", err)
+		}
+		file, err := os.Create(filepath.Join(partition, p.name))
+		if err != nil {
+			return fmt.Errorf("failed to create partition: %w", err)
+		}
+		p.day, p.file, p.encoder = day, file, json.NewEncoder(file)
+		p.partitions++
+	}
+	if err := p.encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s: %w", p.file.Name(), err)
+	}
+	return nil
+}
+
+// close closes the open file, if any.
+func (p *partitionWriter) close() error {
+	if p.file == nil {
+		return nil
+	}
+	err := p.file.Close()
+	p.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to write partition: %w", err)
+	}
+	return nil
+}
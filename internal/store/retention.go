@@ -0,0 +1,194 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+)
+
+// dayFormat is the format of the days of daily summaries, in UTC.
+const dayFormat = "2006-01-02"
+
+// DailySummary counts the results of one domain on one day, in the categories of a batch
+// summary. Results older than the retention period are rolled up into daily summaries.
+type DailySummary struct {
+	Domain string `json:"domain"`
+	// Day is the UTC day, as YYYY-MM-DD.
+	Day          string `json:"day"`
+	Checks       int    `json:"checks"`
+	Passed       int    `json:"passed"`
+	ExceedsLimit int    `json:"exceeds_limit"`
+	Invalid      int    `json:"invalid"`
+	Failed       int    `json:"failed"`
+	Skipped      int    `json:"skipped"`
+	// MinLabelCount and MaxLabelCount are the range of label counts of the results that
+	// were checked, or zero if none were.
+	MinLabelCount int `json:"min_label_count"`
+	MaxLabelCount int `json:"max_label_count"`
+}
+
+// add counts record in the summary.
+func (d *DailySummary) add(record batch.Record) {
+	d.Checks++
+	switch {
+	case record.Skipped:
+		d.Skipped++
+	case record.Failed():
+		d.Failed++
+	case record.Invalid():
+		d.Invalid++
+	case record.ExceedsLimit:
+		d.ExceedsLimit++
+	default:
+		d.Passed++
+	}
+	if record.Skipped || record.Failed() {
+		return
+	}
+	if d.Checks-d.Skipped-d.Failed == 1 || record.Count < d.MinLabelCount {
+		d.MinLabelCount = record.Count
+	}
+	if record.Count > d.MaxLabelCount {
+		d.MaxLabelCount = record.Count
+	}
+}
+
+// PruneResult reports what Prune did.
+type PruneResult struct {
+	// Results is the number of results that were rolled up and deleted.
+	Results int
+	// Days is the number of daily summaries the results were rolled up into.
+	Days int
+	// Scans is the number of finished scans that were deleted because none of their
+	// results were left.
+	Scans int
+}
+
+// RetentionCutoff returns the time before which results are rolled up to keep keepDays
+// days of results: the start of the UTC day keepDays days before now, so that whole days
+// are rolled up at once.
+func RetentionCutoff(now time.Time, keepDays int) time.Time {
+	day := now.UTC().AddDate(0, 0, -keepDays)
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Prune rolls up the results recorded before cutoff into daily summaries of each domain,
+// then deletes them, along with the finished scans that have no results left. It runs in
+// one transaction, so results are never lost or counted twice.
+func (s *Store) Prune(cutoff time.Time) (PruneResult, error) {
+	var result PruneResult
+	tx, err := s.db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to prune store: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Aggregate the old results by their stored domain, which stays the same in an
+	// encrypted store
+	rows, err := tx.Query(`SELECT domain, timestamp, label_count, exceeds_limit, status, error, skipped
+		FROM results WHERE timestamp < ?`, formatTime(cutoff))
+	if err != nil {
+		return result, fmt.Errorf("failed to prune store: %w", err)
+	}
+	type key struct{ domain, day string }
+	summaries := make(map[key]*DailySummary)
+	var order []key
+	for rows.Next() {
+		var record batch.Record
+		var domain, timestamp string
+		if err := rows.Scan(&domain, &timestamp, &record.Count, &record.ExceedsLimit, &record.Status,
+			&record.Error, &record.Skipped); err != nil {
+			rows.Close()
+			return result, fmt.Errorf("failed to read result: %w", err)
+		}
+		for _, text := range []struct {
+			column string
+			value  *string
+		}{{"status", &record.Status}, {"error", &record.Error}} {
+			if *text.value, err = s.open(text.column, *text.value); err != nil {
+				rows.Close()
+				return result, fmt.Errorf("failed to read result: %w", err)
+			}
+		}
+		at, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			rows.Close()
+			return result, fmt.Errorf("failed to read result: %w", err)
+		}
+
+		k := key{domain, at.UTC().Format(dayFormat)}
+		if summaries[k] == nil {
+			summaries[k] = &DailySummary{Domain: domain, Day: k.day}
+			order = append(order, k)
+		}
+		summaries[k].add(record)
+		result.Results++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("failed to read result: %w", err)
+	}
+
+	// Add to the summaries of days that were partly rolled up before
+	for _, k := range order {
+		d := summaries[k]
+		_, err := tx.Exec(`INSERT INTO daily (domain, day, checks, passed, exceeds_limit, invalid, failed, skipped,
+			min_label_count, max_label_count) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (domain, day) DO UPDATE SET
+				checks = checks + excluded.checks,
+				passed = passed + excluded.passed,
+				exceeds_limit = exceeds_limit + excluded.exceeds_limit,
+				invalid = invalid + excluded.invalid,
+				failed = failed + excluded.failed,
+				skipped = skipped + excluded.skipped,
+				min_label_count = CASE
+					WHEN checks - skipped - failed = 0 THEN excluded.min_label_count
+					WHEN excluded.checks - excluded.skipped - excluded.failed = 0 THEN min_label_count
+					ELSE MIN(min_label_count, excluded.min_label_count) END,
+				max_label_count = MAX(max_label_count, excluded.max_label_count)`,
+			d.Domain, d.Day, d.Checks, d.Passed, d.ExceedsLimit, d.Invalid, d.Failed, d.Skipped,
+			d.MinLabelCount, d.MaxLabelCount)
+		if err != nil {
+			return result, fmt.Errorf("failed to save daily summary: %w", err)
+		}
+	}
+	result.Days = len(order)
+
+	if _, err := tx.Exec(`DELETE FROM results WHERE timestamp < ?`, formatTime(cutoff)); err != nil {
+		return result, fmt.Errorf("failed to prune store: %w", err)
+	}
+	deleted, err := tx.Exec(`DELETE FROM scans WHERE finished_at IS NOT NULL AND finished_at < ?
+		AND NOT EXISTS (SELECT 1 FROM results WHERE results.scan_id = scans.id)`, formatTime(cutoff))
+	if err != nil {
+		return result, fmt.Errorf("failed to prune store: %w", err)
+	}
+	scans, _ := deleted.RowsAffected()
+	result.Scans = int(scans)
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to prune store: %w", err)
+	}
+	return result, nil
+}
+
+// Daily returns the daily summaries of domain, oldest first.
+func (s *Store) Daily(domain string) ([]DailySummary, error) {
+	rows, err := s.db.Query(`SELECT day, checks, passed, exceeds_limit, invalid, failed, skipped,
+		min_label_count, max_label_count FROM daily WHERE domain = ? ORDER BY day`, s.domainValue(domain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []DailySummary
+	for rows.Next() {
+		d := DailySummary{Domain: domain}
+		if err := rows.Scan(&d.Day, &d.Checks, &d.Passed, &d.ExceedsLimit, &d.Invalid, &d.Failed, &d.Skipped,
+			&d.MinLabelCount, &d.MaxLabelCount); err != nil {
+			return nil, fmt.Errorf("failed to read daily summary: %w", err)
+		}
+		summaries = append(summaries, d)
+	}
+	return summaries, rows.Err()
+}
@@ -0,0 +1,349 @@
+// Package store persists scan results in a database, so that the history of many
+// domains can be queried, turned into trend reports and fed to dashboards without
+// re-parsing output or results files.
+//
+// The only backend is SQLite. A database holds one row per scan in the scans table and
+// one row per record in the results table, along with daily summaries and metadata:
+//
+//	scans(id, command, started_at, finished_at)
+//	results(scan_id, domain, url, timestamp, label_count, labels, origins, exceeds_limit,
+//	        origin, status, error, warnings, skipped, circuit_open)
+//	daily(domain, day, checks, passed, exceeds_limit, invalid, failed, skipped,
+//	      min_label_count, max_label_count)
+//	meta(name, value)
+//
+// Times are stored as RFC 3339 text in UTC with nanoseconds, so that they sort in text
+// order, and lists as JSON arrays. Prune rolls results older than a retention period up
+// into one row per domain and day in the daily table, so that the database of a
+// long-running monitor does not grow without bound.
+//
+// A store opened with a key is encrypted: the text and list columns of results, which
+// enumerate the domains and origins that were scanned, are encrypted with AES-256-GCM,
+// while times, counts and flags stay readable. Domains are encrypted deterministically
+// so that a domain's history can still be looked up. The meta table records whether a
+// store is encrypted, and a store cannot be opened without its key or with another one.
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+
+	// Register the pure Go SQLite driver
+	_ "modernc.org/sqlite"
+)
+
+// schema creates the tables of a new database and leaves an existing one unchanged.
+const schema = `
+CREATE TABLE IF NOT EXISTS scans (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	command     TEXT NOT NULL,
+	started_at  TEXT NOT NULL,
+	finished_at TEXT
+);
+CREATE TABLE IF NOT EXISTS results (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	scan_id       INTEGER NOT NULL REFERENCES scans(id),
+	domain        TEXT NOT NULL,
+	url           TEXT,
+	timestamp     TEXT NOT NULL,
+	label_count   INTEGER NOT NULL,
+	labels        TEXT,
+	origins       TEXT,
+	exceeds_limit INTEGER NOT NULL,
+	origin        TEXT,
+	status        TEXT,
+	error         TEXT,
+	warnings      TEXT,
+	skipped       INTEGER NOT NULL,
+	circuit_open  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS results_domain_timestamp ON results(domain, timestamp);
+CREATE INDEX IF NOT EXISTS results_scan ON results(scan_id);
+CREATE INDEX IF NOT EXISTS results_timestamp ON results(timestamp);
+CREATE TABLE IF NOT EXISTS daily (
+	domain          TEXT NOT NULL,
+	day             TEXT NOT NULL,
+	checks          INTEGER NOT NULL,
+	passed          INTEGER NOT NULL,
+	exceeds_limit   INTEGER NOT NULL,
+	invalid         INTEGER NOT NULL,
+	failed          INTEGER NOT NULL,
+	skipped         INTEGER NOT NULL,
+	min_label_count INTEGER NOT NULL,
+	max_label_count INTEGER NOT NULL,
+	PRIMARY KEY (domain, day)
+);
+CREATE TABLE IF NOT EXISTS meta (
+	name  TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`
+
+// Store is a results database.
+type Store struct {
+	db *sql.DB
+	// sealer encrypts the results of an encrypted store; it is nil otherwise.
+	sealer *sealer
+}
+
+// Options configures how a store is opened.
+type Options struct {
+	// Key is the KeySize-byte key of an encrypted store. A new store opened with a key is
+	// encrypted; an existing store must be opened with the key it was created with.
+	Key []byte
+}
+
+// Open opens the database described by spec, creating it if necessary. The only
+// supported form is "sqlite:path".
+func Open(spec string) (*Store, error) {
+	return OpenWithOptions(spec, Options{})
+}
+
+// OpenWithOptions opens the database described by spec with opts, creating it if
+// necessary.
+func OpenWithOptions(spec string, opts Options) (*Store, error) {
+	kind, path, ok := strings.Cut(spec, ":")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("invalid store %q: expected sqlite:path", spec)
+	}
+	if kind != "sqlite" {
+		return nil, fmt.Errorf("unknown store %q: expected sqlite", kind)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	// SQLite allows one writer at a time; a single connection avoids busy errors
+	db.SetMaxOpenConns(1)
+
+	// Write through a journal that readers such as dashboards do not block
+	for _, pragma := range []string{"PRAGMA journal_mode = WAL", "PRAGMA synchronous = NORMAL", "PRAGMA busy_timeout = 5000"} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to open store: %w", err)
+		}
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create store schema: %w", err)
+	}
+
+	s := &Store{db: db}
+	if opts.Key != nil {
+		if s.sealer, err = newSealer(opts.Key); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if err := s.checkKey(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// checkKey checks that the store is opened with the key it was created with, or without
+// one if it is not encrypted. A new store opened with a key records that it is encrypted.
+func (s *Store) checkKey() error {
+	var keyCheck string
+	err := s.db.QueryRow(`SELECT value FROM meta WHERE name = 'key_check'`).Scan(&keyCheck)
+	switch {
+	case err == sql.ErrNoRows:
+		if s.sealer == nil {
+			return nil
+		}
+		var results int
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM results`).Scan(&results); err != nil {
+			return fmt.Errorf("failed to open store: %w", err)
+		}
+		if results > 0 {
+			return errors.New("the store is not encrypted; open it without a key, or start a new store")
+		}
+		_, err = s.db.Exec(`INSERT INTO meta (name, value) VALUES ('key_check', ?)`, s.sealer.seal("key_check", keyCheckText))
+		if err != nil {
+			return fmt.Errorf("failed to open store: %w", err)
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to open store: %w", err)
+	case s.sealer == nil:
+		return errors.New("the store is encrypted; a key is required")
+	}
+	if text, err := s.sealer.open("key_check", keyCheck); err != nil || text != keyCheckText {
+		return errors.New("the store was encrypted with a different key")
+	}
+	return nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// StartScan records the start of a scan by command and returns its ID.
+func (s *Store) StartScan(command string, at time.Time) (int64, error) {
+	result, err := s.db.Exec(`INSERT INTO scans (command, started_at) VALUES (?, ?)`, command, formatTime(at))
+	if err != nil {
+		return 0, fmt.Errorf("failed to record scan: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// FinishScan records the end of a scan.
+func (s *Store) FinishScan(scanID int64, at time.Time) error {
+	if _, err := s.db.Exec(`UPDATE scans SET finished_at = ? WHERE id = ?`, formatTime(at), scanID); err != nil {
+		return fmt.Errorf("failed to record scan: %w", err)
+	}
+	return nil
+}
+
+// Save records one result of a scan.
+func (s *Store) Save(scanID int64, record batch.Record) error {
+	_, err := s.db.Exec(`INSERT INTO results (scan_id, domain, url, timestamp, label_count, labels, origins,
+		exceeds_limit, origin, status, error, warnings, skipped, circuit_open)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		scanID, s.domainValue(record.Domain), s.seal("url", record.URL), formatTime(record.Timestamp), record.Count,
+		s.seal("labels", formatList(record.Labels)), s.seal("origins", formatList(record.Origins)), record.ExceedsLimit,
+		s.seal("origin", record.Origin), s.seal("status", record.Status), s.seal("error", record.Error),
+		s.seal("warnings", formatList(record.Warnings)), record.Skipped, record.CircuitOpen)
+	if err != nil {
+		return fmt.Errorf("failed to save result: %w", err)
+	}
+	return nil
+}
+
+// History returns the results recorded for domain, oldest first.
+func (s *Store) History(domain string) ([]batch.Record, error) {
+	rows, err := s.db.Query(`SELECT `+resultColumns+`
+		FROM results WHERE domain = ? ORDER BY timestamp, id`, s.domainValue(domain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query results: %w", err)
+	}
+	defer rows.Close()
+
+	var records []batch.Record
+	for rows.Next() {
+		_, record, err := s.scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// Contains reports whether a result for the same domain, timestamp and caller origin as
+// record is already recorded, such as when a results file is imported twice.
+func (s *Store) Contains(record batch.Record) (bool, error) {
+	rows, err := s.db.Query(`SELECT origin FROM results WHERE domain = ? AND timestamp = ?`,
+		s.domainValue(record.Domain), formatTime(record.Timestamp))
+	if err != nil {
+		return false, fmt.Errorf("failed to query results: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var origin string
+		if err := rows.Scan(&origin); err != nil {
+			return false, fmt.Errorf("failed to read result: %w", err)
+		}
+		// Origins are encrypted with random nonces, so they are compared once decrypted
+		if origin, err = s.open("origin", origin); err != nil {
+			return false, fmt.Errorf("failed to read result: %w", err)
+		}
+		if origin == record.Origin {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// resultColumns are the columns of results read by scanResult, in order.
+const resultColumns = `scan_id, domain, url, timestamp, label_count, labels, origins, exceeds_limit,
+		origin, status, error, warnings, skipped, circuit_open`
+
+// scanResult reads the resultColumns of the current row into a record, and returns it
+// with the ID of its scan.
+func (s *Store) scanResult(rows *sql.Rows) (int64, batch.Record, error) {
+	var scanID int64
+	var record batch.Record
+	var timestamp, labels, origins, warnings string
+	if err := rows.Scan(&scanID, &record.Domain, &record.URL, &timestamp, &record.Count, &labels, &origins,
+		&record.ExceedsLimit, &record.Origin, &record.Status, &record.Error, &warnings,
+		&record.Skipped, &record.CircuitOpen); err != nil {
+		return 0, record, fmt.Errorf("failed to read result: %w", err)
+	}
+	// Results are stored in the current format
+	record.SchemaVersion = batch.SchemaVersion
+	var err error
+	record.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return 0, record, fmt.Errorf("failed to read result: %w", err)
+	}
+	for _, text := range []struct {
+		column string
+		value  *string
+	}{{"domain", &record.Domain}, {"url", &record.URL}, {"labels", &labels}, {"origins", &origins},
+		{"origin", &record.Origin}, {"status", &record.Status}, {"error", &record.Error}, {"warnings", &warnings}} {
+		if *text.value, err = s.open(text.column, *text.value); err != nil {
+			return 0, record, fmt.Errorf("failed to read result: %w", err)
+		}
+	}
+	for _, list := range []struct {
+		text string
+		dest *[]string
+	}{{labels, &record.Labels}, {origins, &record.Origins}, {warnings, &record.Warnings}} {
+		if err := json.Unmarshal([]byte(list.text), list.dest); err != nil {
+			return 0, record, fmt.Errorf("failed to read result: %w", err)
+		}
+	}
+	return scanID, record, nil
+}
+
+// seal returns the stored form of a value of column: the value itself in a store that
+// is not encrypted, and the encrypted value otherwise.
+func (s *Store) seal(column, value string) string {
+	if s.sealer == nil {
+		return value
+	}
+	return s.sealer.seal(column, value)
+}
+
+// domainValue returns the stored form of a domain, which is the same every time it is
+// stored so that a domain's results can be looked up.
+func (s *Store) domainValue(domain string) string {
+	if s.sealer == nil {
+		return domain
+	}
+	return s.sealer.sealDeterministic("domain", domain)
+}
+
+// open returns the value of column from its stored form.
+func (s *Store) open(column, value string) (string, error) {
+	if s.sealer == nil {
+		return value, nil
+	}
+	return s.sealer.open(column, value)
+}
+
+// timeFormat is RFC 3339 with a fixed number of fractional digits, so that stored times
+// sort in text order.
+const timeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// formatTime formats a time the way it is stored.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// formatList formats a list the way it is stored. A nil list is stored as null.
+func formatList(list []string) string {
+	data, _ := json.Marshal(list)
+	return string(data)
+}
@@ -0,0 +1,296 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+)
+
+// TestStore tests saving scan results and reading them back.
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	s, err := Open("sqlite:" + path)
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+
+	start := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	scanID, err := s.StartScan("batch", start)
+	if err != nil {
+		t.Fatalf("StartScan returned an error: %v", err)
+	}
+	records := []batch.Record{
+		{Domain: "example.com", Timestamp: start.Add(time.Second), Count: 6, Labels: []string{"a."}, Origins: []string{"https://a.com"}, ExceedsLimit: true, Origin: "https://a.com", Status: "SUCCESS"},
+		{Domain: "example.com", Timestamp: start.Add(500 * time.Millisecond), Error: "HTTP request failed with status code: 404", Warnings: []string{"slow"}},
+		{Domain: "example.org", Timestamp: start, Skipped: true, Error: "budget exhausted"},
+	}
+	for _, record := range records {
+		if err := s.Save(scanID, record); err != nil {
+			t.Fatalf("Save returned an error: %v", err)
+		}
+	}
+	if err := s.FinishScan(scanID, start.Add(time.Minute)); err != nil {
+		t.Fatalf("FinishScan returned an error: %v", err)
+	}
+	s.Close()
+
+	// The results survive reopening the database
+	s, err = Open("sqlite:" + path)
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	defer s.Close()
+
+	history, err := s.History("example.com")
+	if err != nil {
+		t.Fatalf("History returned an error: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(history))
+	}
+	if !history[0].Timestamp.Equal(start.Add(500*time.Millisecond)) || history[0].Warnings[0] != "slow" || history[0].Labels != nil {
+		t.Errorf("Unexpected first result %+v", history[0])
+	}
+	if !history[1].ExceedsLimit || history[1].Count != 6 || history[1].Origins[0] != "https://a.com" || history[1].Status != "SUCCESS" {
+		t.Errorf("Unexpected second result %+v", history[1])
+	}
+
+	// A result is found again by its domain, timestamp and caller origin
+	for i, record := range records {
+		contains, err := s.Contains(record)
+		if err != nil || !contains {
+			t.Errorf("Contains(%d) returned %v, %v", i, contains, err)
+		}
+	}
+	if contains, _ := s.Contains(batch.Record{Domain: "example.com", Timestamp: start.Add(time.Second), Origin: "https://b.com"}); contains {
+		t.Error("Expected no result for another caller origin")
+	}
+
+	for _, spec := range []string{"results.db", "postgres:results", "sqlite:"} {
+		if _, err := Open(spec); err == nil {
+			t.Errorf("Open(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+// TestEncryptedStore tests that an encrypted store hides its results and needs its key.
+func TestEncryptedStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	key := bytes.Repeat([]byte{7}, KeySize)
+	s, err := OpenWithOptions("sqlite:"+path, Options{Key: key})
+	if err != nil {
+		t.Fatalf("OpenWithOptions returned an error: %v", err)
+	}
+	start := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	scanID, _ := s.StartScan("batch", start)
+	for i, record := range []batch.Record{
+		{Domain: "secret.example.com", Timestamp: start, Count: 1, Origins: []string{"https://internal.example.com"}, Status: "SUCCESS"},
+		{Domain: "secret.example.com", Timestamp: start.Add(time.Second), Error: "connection refused"},
+		{Domain: "other.example.com", Timestamp: start},
+	} {
+		if err := s.Save(scanID, record); err != nil {
+			t.Fatalf("Save %d returned an error: %v", i, err)
+		}
+	}
+
+	history, err := s.History("secret.example.com")
+	if err != nil {
+		t.Fatalf("History returned an error: %v", err)
+	}
+	if len(history) != 2 || history[0].Domain != "secret.example.com" || history[0].Origins[0] != "https://internal.example.com" ||
+		history[0].Status != "SUCCESS" || history[1].Error != "connection refused" {
+		t.Errorf("Unexpected history %+v", history)
+	}
+	s.Close()
+
+	// Nothing that was scanned is stored in the clear
+	for _, file := range []string{path, path + "-wal"} {
+		data, _ := os.ReadFile(file)
+		for _, secret := range []string{"secret.example.com", "internal.example.com", "connection refused"} {
+			if bytes.Contains(data, []byte(secret)) {
+				t.Errorf("Found %q unencrypted in %s", secret, filepath.Base(file))
+			}
+		}
+	}
+
+	if _, err := Open("sqlite:" + path); err == nil {
+		t.Error("Expected an error opening an encrypted store without a key")
+	}
+	if _, err := OpenWithOptions("sqlite:"+path, Options{Key: bytes.Repeat([]byte{8}, KeySize)}); err == nil {
+		t.Error("Expected an error opening an encrypted store with another key")
+	}
+
+	// A store with unencrypted results cannot be opened with a key
+	plain := filepath.Join(t.TempDir(), "plain.db")
+	s, _ = Open("sqlite:" + plain)
+	s.Save(scanID, batch.Record{Domain: "example.com", Timestamp: start})
+	s.Close()
+	if _, err := OpenWithOptions("sqlite:"+plain, Options{Key: key}); err == nil {
+		t.Error("Expected an error opening an unencrypted store with a key")
+	}
+}
+
+// TestParseKey tests decoding store keys.
+func TestParseKey(t *testing.T) {
+	key, err := ParseKey(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, KeySize)) + "\n")
+	if err != nil || len(key) != KeySize {
+		t.Errorf("ParseKey returned %v, %v", key, err)
+	}
+	for _, text := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := ParseKey(text); err == nil {
+			t.Errorf("ParseKey(%q) succeeded, want an error", text)
+		}
+	}
+}
+
+// TestPrune tests rolling up old results into daily summaries.
+func TestPrune(t *testing.T) {
+	for _, key := range [][]byte{nil, bytes.Repeat([]byte{7}, KeySize)} {
+		t.Run(fmt.Sprintf("Encrypted %v", key != nil), func(t *testing.T) {
+			s, err := OpenWithOptions("sqlite:"+filepath.Join(t.TempDir(), "results.db"), Options{Key: key})
+			if err != nil {
+				t.Fatalf("OpenWithOptions returned an error: %v", err)
+			}
+			defer s.Close()
+
+			day1 := time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)
+			day2 := day1.AddDate(0, 0, 1)
+			now := day1.AddDate(0, 0, 10)
+			oldScan, _ := s.StartScan("batch", day1)
+			s.FinishScan(oldScan, day2)
+			newScan, _ := s.StartScan("watch", now)
+			for _, saved := range []struct {
+				scan   int64
+				record batch.Record
+			}{
+				{oldScan, batch.Record{Domain: "a.com", Timestamp: day1, Count: 3, Origin: "https://a.com", Status: "SUCCESS"}},
+				{oldScan, batch.Record{Domain: "a.com", Timestamp: day1.Add(time.Hour), Count: 6, ExceedsLimit: true}},
+				{oldScan, batch.Record{Domain: "a.com", Timestamp: day1.Add(2 * time.Hour), Error: "timeout"}},
+				{oldScan, batch.Record{Domain: "a.com", Timestamp: day2, Count: 2, Origin: "https://b.com", Status: "BAD_RELYING_PARTY_ID_NO_JSON_MATCH"}},
+				{oldScan, batch.Record{Domain: "b.com", Timestamp: day2, Skipped: true, Error: "budget exhausted"}},
+				{newScan, batch.Record{Domain: "a.com", Timestamp: now, Count: 1}},
+			} {
+				if err := s.Save(saved.scan, saved.record); err != nil {
+					t.Fatalf("Save returned an error: %v", err)
+				}
+			}
+
+			cutoff := RetentionCutoff(now, 7)
+			if !cutoff.Equal(time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("Unexpected cutoff %s", cutoff)
+			}
+			result, err := s.Prune(cutoff)
+			if err != nil {
+				t.Fatalf("Prune returned an error: %v", err)
+			}
+			if result.Results != 5 || result.Days != 3 || result.Scans != 1 {
+				t.Errorf("Unexpected prune result %+v", result)
+			}
+
+			daily, err := s.Daily("a.com")
+			if err != nil {
+				t.Fatalf("Daily returned an error: %v", err)
+			}
+			expected := []DailySummary{
+				{Domain: "a.com", Day: "2024-03-01", Checks: 3, Passed: 1, ExceedsLimit: 1, Failed: 1, MinLabelCount: 3, MaxLabelCount: 6},
+				{Domain: "a.com", Day: "2024-03-02", Checks: 1, Invalid: 1, MinLabelCount: 2, MaxLabelCount: 2},
+			}
+			if len(daily) != len(expected) || daily[0] != expected[0] || daily[1] != expected[1] {
+				t.Errorf("Expected daily summaries %+v, got %+v", expected, daily)
+			}
+			history, _ := s.History("a.com")
+			if len(history) != 1 || history[0].Count != 1 {
+				t.Errorf("Expected only the recent result to be kept, got %+v", history)
+			}
+
+			// A late result for a rolled up day is added to its summary
+			s.Save(newScan, batch.Record{Domain: "a.com", Timestamp: day1.Add(3 * time.Hour), Count: 1})
+			if _, err := s.Prune(cutoff); err != nil {
+				t.Fatalf("Prune returned an error: %v", err)
+			}
+			daily, _ = s.Daily("a.com")
+			if daily[0].Checks != 4 || daily[0].Passed != 2 || daily[0].MinLabelCount != 1 || daily[0].MaxLabelCount != 6 {
+				t.Errorf("Unexpected daily summary after a second prune %+v", daily[0])
+			}
+		})
+	}
+}
+
+// TestExport tests exporting results and daily summaries partitioned by day.
+func TestExport(t *testing.T) {
+	s, err := OpenWithOptions("sqlite:"+filepath.Join(t.TempDir(), "results.db"), Options{Key: bytes.Repeat([]byte{7}, KeySize)})
+	if err != nil {
+		t.Fatalf("OpenWithOptions returned an error: %v", err)
+	}
+	defer s.Close()
+
+	day1 := time.Date(2024, time.March, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+	day3 := time.Date(2024, time.March, 3, 10, 0, 0, 0, time.UTC)
+	scanID, _ := s.StartScan("watch", day1)
+	for _, record := range []batch.Record{
+		{Domain: "a.com", Timestamp: day1, Count: 3, Origins: []string{"https://a.com"}},
+		{Domain: "a.com", Timestamp: day2, Count: 4},
+		{Domain: "b.com", Timestamp: day2, Error: "timeout"},
+		{Domain: "a.com", Timestamp: day3, Count: 5},
+	} {
+		if err := s.Save(scanID, record); err != nil {
+			t.Fatalf("Save returned an error: %v", err)
+		}
+	}
+	if _, err := s.Prune(RetentionCutoff(day3, 0)); err != nil {
+		t.Fatalf("Prune returned an error: %v", err)
+	}
+	s.Save(scanID, batch.Record{Domain: "a.com", Timestamp: day3.Add(time.Hour), Count: 6})
+
+	dir := t.TempDir()
+	result, err := s.Export(dir, time.Time{})
+	if err != nil {
+		t.Fatalf("Export returned an error: %v", err)
+	}
+	if result.Results != 2 || result.Daily != 3 || result.Partitions != 3 {
+		t.Errorf("Unexpected export result %+v", result)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "results", "date=2024-03-03", "results.jsonl"))
+	if err != nil {
+		t.Fatalf("Failed to read results partition: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], `{"scan_id":1,"schema_version":1,"domain":"a.com",`) || !strings.Contains(lines[1], `"label_count":6`) {
+		t.Errorf("Unexpected results partition:\n%s", data)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "daily", "date=2024-03-02", "daily.jsonl"))
+	if err != nil {
+		t.Fatalf("Failed to read daily partition: %v", err)
+	}
+	// Domains are decrypted, and in no particular order within a day
+	for _, line := range []string{
+		`{"domain":"a.com","day":"2024-03-02","checks":1,"passed":1,"exceeds_limit":0,"invalid":0,"failed":0,"skipped":0,"min_label_count":4,"max_label_count":4}`,
+		`{"domain":"b.com","day":"2024-03-02","checks":1,"passed":0,"exceeds_limit":0,"invalid":0,"failed":1,"skipped":0,"min_label_count":0,"max_label_count":0}`,
+	} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("Expected daily partition to contain %s, got:\n%s", line, data)
+		}
+	}
+
+	// Exporting since a day leaves earlier partitions out
+	dir = t.TempDir()
+	result, err = s.Export(dir, day2)
+	if err != nil {
+		t.Fatalf("Export returned an error: %v", err)
+	}
+	if result.Results != 2 || result.Daily != 2 {
+		t.Errorf("Unexpected export result %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "daily", "date=2024-03-01")); !os.IsNotExist(err) {
+		t.Errorf("Expected no partition before the day of since, got %v", err)
+	}
+}
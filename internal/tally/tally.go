@@ -0,0 +1,90 @@
+// Package tally counts the outcomes of a run over many targets, such as the domains of a
+// batch, into the summary block printed at the end of the run, so that people and
+// dashboards get the headline without reading every row.
+package tally
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Outcome is the most severe result of checking one target.
+type Outcome int
+
+const (
+	// Passed means the target was checked and nothing was found.
+	Passed Outcome = iota
+	// Warned means the worst finding about the target is a warning.
+	Warned
+	// Errored means the target could not be checked, or an error was found, such as a
+	// document over the label limit or a caller origin that is not authorized.
+	Errored
+	// Skipped means the target was not checked, such as once the run's budget was
+	// exhausted.
+	Skipped
+)
+
+// Tally counts the outcomes of a run. Every target scanned is counted in exactly one of
+// Passed, Warnings, Errors and Skipped.
+type Tally struct {
+	Scanned  int `json:"scanned"`
+	Passed   int `json:"passed"`
+	Warnings int `json:"warnings"`
+	Errors   int `json:"errors"`
+	Skipped  int `json:"skipped"`
+	// DurationSeconds is how long the run took, from Start to Finish.
+	DurationSeconds float64 `json:"duration_seconds"`
+
+	start time.Time
+}
+
+// Start returns a Tally for a run that starts at start.
+func Start(start time.Time) *Tally {
+	return &Tally{start: start}
+}
+
+// Add counts the outcome of one target.
+func (t *Tally) Add(outcome Outcome) {
+	t.Scanned++
+	switch outcome {
+	case Passed:
+		t.Passed++
+	case Warned:
+		t.Warnings++
+	case Errored:
+		t.Errors++
+	case Skipped:
+		t.Skipped++
+	}
+}
+
+// Finish records that the run ended at end.
+func (t *Tally) Finish(end time.Time) {
+	t.DurationSeconds = end.Sub(t.start).Seconds()
+}
+
+// Duration returns how long the run took, rounded for display.
+func (t *Tally) Duration() time.Duration {
+	d := time.Duration(t.DurationSeconds * float64(time.Second))
+	if d >= time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Millisecond)
+}
+
+// Format formats the tally as the summary block of a run, with noun naming the targets,
+// such as "domains".
+func (t *Tally) Format(noun string) string {
+	var sb strings.Builder
+	sb.WriteString("Run summary:\n")
+	sb.WriteString(fmt.Sprintf("  %s scanned: %d\n", strings.ToUpper(noun[:1])+noun[1:], t.Scanned))
+	sb.WriteString(fmt.Sprintf("  Passed: %d\n", t.Passed))
+	sb.WriteString(fmt.Sprintf("  Warnings: %d\n", t.Warnings))
+	sb.WriteString(fmt.Sprintf("  Errors: %d\n", t.Errors))
+	if t.Skipped > 0 {
+		sb.WriteString(fmt.Sprintf("  Skipped: %d\n", t.Skipped))
+	}
+	sb.WriteString(fmt.Sprintf("  Duration: %s\n", t.Duration()))
+	return sb.String()
+}
@@ -0,0 +1,41 @@
+package tally
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestTally tests counting outcomes and formatting the summary block.
+func TestTally(t *testing.T) {
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tally := Start(start)
+	for _, outcome := range []Outcome{Passed, Passed, Warned, Errored, Passed} {
+		tally.Add(outcome)
+	}
+	tally.Finish(start.Add(42*time.Second + 123*time.Millisecond))
+
+	expected := "Run summary:\n" +
+		"  Domains scanned: 5\n" +
+		"  Passed: 3\n" +
+		"  Warnings: 1\n" +
+		"  Errors: 1\n" +
+		"  Duration: 42.1s\n"
+	if got := tally.Format("domains"); got != expected {
+		t.Errorf("Unexpected summary block:\n%s\nexpected:\n%s", got, expected)
+	}
+
+	data, err := json.Marshal(tally)
+	if err != nil {
+		t.Fatalf("Failed to marshal the tally: %v", err)
+	}
+	if string(data) != `{"scanned":5,"passed":3,"warnings":1,"errors":1,"skipped":0,"duration_seconds":42.123}` {
+		t.Errorf("Unexpected JSON %s", data)
+	}
+
+	// Skipped targets are only listed when there are some
+	tally.Add(Skipped)
+	if got := tally.Format("domains"); got == expected {
+		t.Errorf("Expected the skipped target to be listed, got:\n%s", got)
+	}
+}
@@ -0,0 +1,160 @@
+// Package vantage fetches a .well-known/webauthn endpoint from several vantage points
+// and compares what each one sees.
+//
+// Related-origin rollouts often break only behind certain CDNs or in certain regions.
+// Each vantage point is an HTTP proxy (typically one per region); the same URL is
+// fetched through every proxy and the results are compared.
+package vantage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// Vantage is a named vantage point reached through an HTTP proxy.
+// An empty ProxyURL means a direct connection from the local host.
+type Vantage struct {
+	Name     string
+	ProxyURL string
+}
+
+// ParseVantage parses a vantage point specification of the form "name=proxy-url" or "proxy-url".
+// When no name is given, the proxy host is used as the name.
+func ParseVantage(spec string) (Vantage, error) {
+	name, proxyURL, found := strings.Cut(spec, "=")
+	if !found {
+		proxyURL = spec
+		name = ""
+	}
+
+	parsed, err := url.Parse(proxyURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return Vantage{}, fmt.Errorf("invalid proxy URL %q", proxyURL)
+	}
+	if name == "" {
+		name = parsed.Host
+	}
+
+	return Vantage{Name: name, ProxyURL: proxyURL}, nil
+}
+
+// Result is the outcome of fetching the endpoint from a single vantage point.
+type Result struct {
+	Vantage Vantage
+	Count   *counter.LabelCount
+	Err     error
+}
+
+// Outcome returns a short description of what the vantage point saw, used to compare results.
+func (r Result) Outcome() string {
+	if r.Err != nil {
+		return fmt.Sprintf("error: %s", r.Err)
+	}
+	if r.Count.ErrorMessage != "" {
+		return fmt.Sprintf("error: %s", r.Count.ErrorMessage)
+	}
+	sum := sha256.Sum256([]byte(r.Count.RawJSON))
+	return fmt.Sprintf("labels=%d sha256:%s", r.Count.Count, hex.EncodeToString(sum[:])[:12])
+}
+
+// Report is the comparison of an endpoint across vantage points.
+type Report struct {
+	URL          string
+	Results      []Result
+	Inconsistent []string
+}
+
+// Consistent reports whether every vantage point saw the same outcome.
+func (r *Report) Consistent() bool {
+	return len(r.Inconsistent) == 0
+}
+
+// TransportFactory builds the transport for a vantage point, given the proxy function
+// that routes its requests. It lets callers keep their own dialing and budget settings.
+type TransportFactory func(proxy func(*http.Request) (*url.URL, error)) http.RoundTripper
+
+// defaultTransportFactory clones http.DefaultTransport with the given proxy function.
+func defaultTransportFactory(proxy func(*http.Request) (*url.URL, error)) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	return transport
+}
+
+// transportFor returns a transport that sends requests through the vantage point's proxy.
+// Direct vantage points explicitly bypass any proxy configured in the environment.
+func transportFor(v Vantage, factory TransportFactory) (http.RoundTripper, error) {
+	if v.ProxyURL == "" {
+		return factory(nil), nil
+	}
+
+	proxyURL, err := url.Parse(v.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", v.ProxyURL, err)
+	}
+	return factory(http.ProxyURL(proxyURL)), nil
+}
+
+// Compare fetches the .well-known/webauthn endpoint for domain through each vantage point
+// and reports the vantage points whose outcome differs from the first one. Transports are
+// built with factory, or from http.DefaultTransport when factory is nil.
+func Compare(domain string, vantages []Vantage, opts counter.Options, factory TransportFactory) (*Report, error) {
+	if factory == nil {
+		factory = defaultTransportFactory
+	}
+
+	wellKnownURL, err := counter.WellKnownURL(domain)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{URL: wellKnownURL}
+
+	for _, v := range vantages {
+		transport, err := transportFor(v, factory)
+		if err != nil {
+			return nil, err
+		}
+
+		vantageOpts := opts
+		vantageOpts.Transport = transport
+		count, err := counter.CountLabelsWithOptions(domain, vantageOpts)
+		report.Results = append(report.Results, Result{Vantage: v, Count: count, Err: err})
+	}
+
+	// Compare every outcome against the first vantage point
+	if len(report.Results) > 0 {
+		baseline := report.Results[0].Outcome()
+		for _, result := range report.Results[1:] {
+			if result.Outcome() != baseline {
+				report.Inconsistent = append(report.Inconsistent, result.Vantage.Name)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// FormatReport formats a vantage report into a human-readable string.
+func FormatReport(report *Report) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("URL: %s\n", report.URL))
+	for _, result := range report.Results {
+		via := "direct"
+		if result.Vantage.ProxyURL != "" {
+			via = result.Vantage.ProxyURL
+		}
+		sb.WriteString(fmt.Sprintf("- %s (%s): %s\n", result.Vantage.Name, via, result.Outcome()))
+	}
+
+	if report.Consistent() {
+		sb.WriteString("All vantage points see the same document.\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("WARNING: Inconsistent results from: %s\n", strings.Join(report.Inconsistent, ", ")))
+	}
+	return sb.String()
+}
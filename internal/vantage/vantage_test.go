@@ -0,0 +1,80 @@
+package vantage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// newProxy returns a test server that answers every proxied request with the given document.
+func newProxy(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+}
+
+// TestCompare tests the Compare function.
+func TestCompare(t *testing.T) {
+	current := newProxy(`{"origins": ["https://example.com", "https://example.org"]}`)
+	defer current.Close()
+	stale := newProxy(`{"origins": ["https://example.com"]}`)
+	defer stale.Close()
+
+	t.Run("Consistent vantage points", func(t *testing.T) {
+		vantages := []Vantage{
+			{Name: "us", ProxyURL: current.URL},
+			{Name: "eu", ProxyURL: current.URL},
+		}
+		report, err := Compare("http://rp.test", vantages, counter.DefaultOptions(), nil)
+		if err != nil {
+			t.Fatalf("Compare returned an error: %v", err)
+		}
+		if !report.Consistent() {
+			t.Errorf("Expected consistent results, got %v", report.Inconsistent)
+		}
+	})
+
+	t.Run("Inconsistent vantage point", func(t *testing.T) {
+		vantages := []Vantage{
+			{Name: "us", ProxyURL: current.URL},
+			{Name: "eu", ProxyURL: stale.URL},
+		}
+		report, err := Compare("http://rp.test", vantages, counter.DefaultOptions(), nil)
+		if err != nil {
+			t.Fatalf("Compare returned an error: %v", err)
+		}
+		if len(report.Inconsistent) != 1 || report.Inconsistent[0] != "eu" {
+			t.Errorf("Expected eu to be inconsistent, got %v", report.Inconsistent)
+		}
+		if !strings.Contains(FormatReport(report), "WARNING") {
+			t.Errorf("Expected report to contain a warning, got %s", FormatReport(report))
+		}
+	})
+}
+
+// TestParseVantage tests the ParseVantage function.
+func TestParseVantage(t *testing.T) {
+	v, err := ParseVantage("eu-west=http://proxy.eu.example:3128")
+	if err != nil {
+		t.Fatalf("ParseVantage returned an error: %v", err)
+	}
+	if v.Name != "eu-west" || v.ProxyURL != "http://proxy.eu.example:3128" {
+		t.Errorf("Unexpected vantage %+v", v)
+	}
+
+	v, err = ParseVantage("http://proxy.us.example:3128")
+	if err != nil {
+		t.Fatalf("ParseVantage returned an error: %v", err)
+	}
+	if v.Name != "proxy.us.example:3128" {
+		t.Errorf("Expected name to default to the proxy host, got %s", v.Name)
+	}
+
+	if _, err := ParseVantage("not a url"); err == nil {
+		t.Errorf("Expected an error for an invalid proxy URL")
+	}
+}
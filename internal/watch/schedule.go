@@ -0,0 +1,179 @@
+package watch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when the next check runs.
+type Schedule interface {
+	// Next returns the first time after t at which a check should run.
+	Next(t time.Time) time.Time
+}
+
+// intervalSchedule runs checks at a fixed interval.
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+// Next returns t plus the interval.
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+// String returns the interval as a duration string.
+func (s intervalSchedule) String() string {
+	return "every " + s.interval.String()
+}
+
+// cronField is the set of allowed values of one cron field, as a bit set.
+type cronField uint64
+
+// has reports whether v is in the set.
+func (f cronField) has(v int) bool {
+	return f&(1<<uint(v)) != 0
+}
+
+// cronSchedule runs checks on a standard five-field cron schedule.
+type cronSchedule struct {
+	spec                          string
+	minute, hour, dom, month, dow cronField
+	// domAny and dowAny record whether the day fields were "*", which changes how
+	// the two day fields combine.
+	domAny, dowAny bool
+}
+
+// String returns the cron expression.
+func (s *cronSchedule) String() string {
+	return s.spec
+}
+
+// dayMatches reports whether the day of t is allowed. As in cron, when both day fields
+// are restricted a day matching either one is allowed.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom.has(t.Day())
+	dow := s.dow.has(int(t.Weekday()))
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first minute after t that matches the schedule, or the zero time if
+// none matches within five years.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	// Skip ahead a whole month, day or hour at a time when that unit does not match
+	for t.Before(limit) {
+		switch {
+		case !s.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !s.hour.has(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !s.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseCronField parses one comma-separated cron field whose values lie in [min, max].
+// Each element is "*", a value, or a range, optionally followed by "/step".
+func parseCronField(field string, min, max int) (cronField, error) {
+	var set cronField
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+			n, err := strconv.Atoi(first)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", first)
+			}
+			lo, hi = n, n
+			if isRange {
+				if hi, err = strconv.Atoi(last); err != nil {
+					return 0, fmt.Errorf("invalid value %q", last)
+				}
+			} else if hasStep {
+				// "5/15" means every 15 starting at 5
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// parseCron parses a five-field cron expression: minute, hour, day of month, month and
+// day of week.
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", spec, len(fields))
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	names := [5]string{"minute", "hour", "day of month", "month", "day of week"}
+	var sets [5]cronField
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %s field: %w", spec, names[i], err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday may be written as 0 or 7
+	if sets[4].has(7) {
+		sets[4] |= 1
+	}
+
+	return &cronSchedule{
+		spec:   spec,
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}, nil
+}
+
+// ParseSchedule parses a schedule given either as a duration, such as "5m" or "@every 1h",
+// or as a five-field cron expression, such as "*/15 * * * *".
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every"))); err == nil {
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid interval %q: must be positive", spec)
+		}
+		return intervalSchedule{interval: d}, nil
+	}
+	return parseCron(spec)
+}
@@ -0,0 +1,332 @@
+// Package watch re-validates a set of domains on a schedule and reports what changed.
+//
+// Each check runs the domains through the batch package and compares every record with
+// the record from the previous check. Differences are reported as transitions, such as
+// origins being added or removed, the caller origin's status flipping, or the label count
+// crossing MaxLabels.
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+	"github.com/developmeh/passkey-origin-validator/pkg/webhook"
+)
+
+// TransitionKind identifies what changed between two checks of a domain.
+type TransitionKind int
+
+const (
+	// TransitionOriginsAdded indicates that origins were added to the document.
+	TransitionOriginsAdded TransitionKind = iota
+	// TransitionOriginsRemoved indicates that origins were removed from the document.
+	TransitionOriginsRemoved
+	// TransitionStatusChanged indicates that the caller origin's validation status changed.
+	TransitionStatusChanged
+	// TransitionLimitExceeded indicates that the label count rose above MaxLabels.
+	TransitionLimitExceeded
+	// TransitionLimitRestored indicates that the label count fell back to MaxLabels or fewer.
+	TransitionLimitRestored
+	// TransitionFailed indicates that the document could no longer be fetched or parsed.
+	TransitionFailed
+	// TransitionRecovered indicates that the document can be fetched and parsed again.
+	TransitionRecovered
+	// TransitionCircuitOpened indicates that checks of the domain are paused after repeated failures.
+	TransitionCircuitOpened
+)
+
+// String returns a string representation of the TransitionKind.
+func (k TransitionKind) String() string {
+	switch k {
+	case TransitionOriginsAdded:
+		return "origins_added"
+	case TransitionOriginsRemoved:
+		return "origins_removed"
+	case TransitionStatusChanged:
+		return "status_changed"
+	case TransitionLimitExceeded:
+		return "limit_exceeded"
+	case TransitionLimitRestored:
+		return "limit_restored"
+	case TransitionFailed:
+		return "failed"
+	case TransitionRecovered:
+		return "recovered"
+	case TransitionCircuitOpened:
+		return "circuit_opened"
+	default:
+		return fmt.Sprintf("UNKNOWN_TRANSITION(%d)", k)
+	}
+}
+
+// MarshalText encodes the kind as its string representation.
+func (k TransitionKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Severity is how urgently a transition needs attention, for routing alerts.
+type Severity int
+
+const (
+	// SeverityInfo indicates a transition that needs no action, such as a recovery.
+	SeverityInfo Severity = iota
+	// SeverityWarning indicates a change that may be intended, such as origins being added.
+	SeverityWarning
+	// SeverityError indicates a transition that breaks passkeys for some users.
+	SeverityError
+)
+
+// String returns a string representation of the Severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("UNKNOWN_SEVERITY(%d)", s)
+	}
+}
+
+// MarshalText encodes the Severity as its string representation.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity returns the Severity named name.
+func ParseSeverity(name string) (Severity, error) {
+	for _, s := range []Severity{SeverityInfo, SeverityWarning, SeverityError} {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q: expected info, warning or error", name)
+}
+
+// Transition is a change in a domain's result between two consecutive checks.
+type Transition struct {
+	Domain   string         `json:"domain"`
+	Time     time.Time      `json:"time"`
+	Kind     TransitionKind `json:"kind"`
+	Severity Severity       `json:"severity"`
+	Message  string         `json:"message"`
+	// Previous and Current are the records the transition was derived from.
+	Previous batch.Record `json:"previous"`
+	Current  batch.Record `json:"current"`
+}
+
+// String formats the transition as one human-readable line.
+func (t Transition) String() string {
+	return fmt.Sprintf("%s %s: %s", t.Time.Format(time.RFC3339), t.Domain, t.Message)
+}
+
+// difference returns the values in a that are not in b, in the order they appear in a.
+func difference(a, b []string) []string {
+	var diff []string
+	for _, v := range a {
+		if !slices.Contains(b, v) {
+			diff = append(diff, v)
+		}
+	}
+	return diff
+}
+
+// Diff compares two consecutive records for the same domain and returns the transitions
+// between them. Skipped records carry no information and produce no transitions.
+func Diff(prev, cur batch.Record) []Transition {
+	if prev.Skipped || cur.Skipped {
+		return nil
+	}
+
+	var transitions []Transition
+	add := func(kind TransitionKind, format string, args ...any) {
+		transitions = append(transitions, Transition{
+			Domain:   cur.Domain,
+			Time:     cur.Timestamp,
+			Kind:     kind,
+			Severity: severity(kind, cur),
+			Message:  fmt.Sprintf(format, args...),
+			Previous: prev,
+			Current:  cur,
+		})
+	}
+
+	// An open circuit is reported separately from the failures that opened it
+	if cur.CircuitOpen {
+		if !prev.CircuitOpen {
+			add(TransitionCircuitOpened, "%s", cur.Error)
+		}
+		return transitions
+	}
+
+	// A failure hides everything else about the document
+	switch {
+	case prev.Error == "" && cur.Error != "":
+		add(TransitionFailed, "check failed: %s", cur.Error)
+		return transitions
+	case prev.Error != "" && cur.Error == "":
+		// There is nothing to compare the recovered document with
+		add(TransitionRecovered, "check recovered (previously: %s)", prev.Error)
+		return transitions
+	case cur.Error != "":
+		return nil
+	}
+
+	if added := difference(cur.Origins, prev.Origins); len(added) > 0 {
+		add(TransitionOriginsAdded, "origins added: %s", strings.Join(added, ", "))
+	}
+	if removed := difference(prev.Origins, cur.Origins); len(removed) > 0 {
+		add(TransitionOriginsRemoved, "origins removed: %s", strings.Join(removed, ", "))
+	}
+
+	if prev.Status != cur.Status && prev.Status != "" && cur.Status != "" {
+		add(TransitionStatusChanged, "%s status changed from %s to %s", cur.Origin, prev.Status, cur.Status)
+	}
+
+	switch {
+	case !prev.ExceedsLimit && cur.ExceedsLimit:
+		add(TransitionLimitExceeded, "label count rose to %d, exceeding the limit of %d", cur.Count, cur.LabelLimit())
+	case prev.ExceedsLimit && !cur.ExceedsLimit:
+		add(TransitionLimitRestored, "label count fell to %d, within the limit of %d", cur.Count, cur.LabelLimit())
+	}
+
+	return transitions
+}
+
+// severity returns the severity of a transition of kind to the record cur. A status
+// change is an error unless the caller origin became authorized.
+func severity(kind TransitionKind, cur batch.Record) Severity {
+	switch kind {
+	case TransitionFailed, TransitionCircuitOpened, TransitionLimitExceeded:
+		return SeverityError
+	case TransitionStatusChanged:
+		if cur.Invalid() {
+			return SeverityError
+		}
+		return SeverityInfo
+	case TransitionOriginsAdded, TransitionOriginsRemoved:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+// Notifier is called for every transition. Notifier is never called concurrently.
+type Notifier func(Transition) error
+
+// WebhookNotifier returns a Notifier that POSTs each transition as JSON to url.
+// If secret is not empty, each request is signed with it, with the headers of package
+// webhook, so that the receiver can authenticate it. A non-2xx response is reported as
+// an error.
+func WebhookNotifier(client *http.Client, url string, secret []byte) Notifier {
+	return func(t Transition) error {
+		body, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to send webhook: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if len(secret) > 0 {
+			webhook.Sign(req.Header, secret, time.Now(), body)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send webhook: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("webhook returned status code: %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// Options configures a watch.
+type Options struct {
+	// Schedule decides when each check after the first runs.
+	Schedule Schedule
+	// Batch configures how each check fetches and validates the domains.
+	Batch batch.Options
+	// OnRecord, if set, is called with every record of every check.
+	OnRecord func(batch.Record)
+	// Notify is called for every transition.
+	Notify Notifier
+	// OnError, if set, is called when a notification fails. The watch continues.
+	OnError func(error)
+}
+
+// Watcher keeps the most recent record for each watched domain.
+type Watcher struct {
+	opts Options
+	last map[string]batch.Record
+}
+
+// New returns a Watcher with no previous results.
+func New(opts Options) *Watcher {
+	return &Watcher{
+		opts: opts,
+		last: make(map[string]batch.Record),
+	}
+}
+
+// Check runs one check of every domain and notifies about transitions since the
+// previous check. The first check of a domain establishes its baseline and produces
+// no transitions.
+func (w *Watcher) Check(ctx context.Context, domains []string) error {
+	return batch.Run(ctx, domains, w.opts.Batch, func(record batch.Record) error {
+		if w.opts.OnRecord != nil {
+			w.opts.OnRecord(record)
+		}
+
+		prev, seen := w.last[record.Domain]
+		if record.Skipped {
+			// Keep the previous record so the next real result is compared against it
+			return nil
+		}
+		w.last[record.Domain] = record
+		if !seen {
+			return nil
+		}
+
+		for _, t := range Diff(prev, record) {
+			if err := w.opts.Notify(t); err != nil && w.opts.OnError != nil {
+				w.opts.OnError(err)
+			}
+		}
+		return nil
+	})
+}
+
+// Run checks the domains immediately and then on the schedule until ctx is done.
+func (w *Watcher) Run(ctx context.Context, domains []string) error {
+	for {
+		if err := w.Check(ctx, domains); err != nil {
+			return err
+		}
+
+		next := w.opts.Schedule.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("schedule %v never runs again", w.opts.Schedule)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
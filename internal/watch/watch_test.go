@@ -0,0 +1,230 @@
+package watch
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/batch"
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/pkg/webhook"
+)
+
+// TestParseSchedule tests the ParseSchedule function.
+func TestParseSchedule(t *testing.T) {
+	start := time.Date(2024, time.March, 15, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"5m", start.Add(5 * time.Minute)},
+		{"@every 1h", start.Add(time.Hour)},
+		{"*/15 * * * *", time.Date(2024, time.March, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2024, time.March, 16, 9, 0, 0, 0, time.UTC)},
+		{"30 2 1 * *", time.Date(2024, time.April, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2024, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,20 * 1", time.Date(2024, time.March, 18, 12, 0, 0, 0, time.UTC)},
+		{"5/20 10-11 * * *", time.Date(2024, time.March, 15, 10, 25, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q) returned an error: %v", tt.spec, err)
+			}
+			if next := schedule.Next(start); !next.Equal(tt.expected) {
+				t.Errorf("Next(%v) = %v, want %v", start, next, tt.expected)
+			}
+		})
+	}
+
+	for _, spec := range []string{"", "-5m", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *"} {
+		t.Run("Invalid "+spec, func(t *testing.T) {
+			if _, err := ParseSchedule(spec); err == nil {
+				t.Errorf("ParseSchedule(%q) succeeded, want an error", spec)
+			}
+		})
+	}
+}
+
+// TestDiff tests the Diff function.
+func TestDiff(t *testing.T) {
+	base := batch.Record{
+		Domain:  "example.com",
+		Origin:  "https://login.example.com",
+		Count:   1,
+		Origins: []string{"https://example.com"},
+		Status:  "BAD_RELYING_PARTY_ID_NO_JSON_MATCH",
+	}
+
+	kinds := func(transitions []Transition) []TransitionKind {
+		var result []TransitionKind
+		for _, t := range transitions {
+			result = append(result, t.Kind)
+		}
+		return result
+	}
+
+	t.Run("No change", func(t *testing.T) {
+		if transitions := Diff(base, base); len(transitions) != 0 {
+			t.Errorf("Expected no transitions, got %v", transitions)
+		}
+	})
+
+	t.Run("Origins and status", func(t *testing.T) {
+		cur := base
+		cur.Origins = []string{"https://login.example.com"}
+		cur.Status = "SUCCESS"
+		got := kinds(Diff(base, cur))
+		want := []TransitionKind{TransitionOriginsAdded, TransitionOriginsRemoved, TransitionStatusChanged}
+		if len(got) != len(want) {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Expected %v, got %v", want, got)
+			}
+		}
+	})
+
+	t.Run("Limit crossed", func(t *testing.T) {
+		cur := base
+		cur.Count = 6
+		cur.ExceedsLimit = true
+		if got := kinds(Diff(base, cur)); len(got) != 1 || got[0] != TransitionLimitExceeded {
+			t.Errorf("Expected limit_exceeded, got %v", got)
+		}
+		if got := kinds(Diff(cur, base)); len(got) != 1 || got[0] != TransitionLimitRestored {
+			t.Errorf("Expected limit_restored, got %v", got)
+		}
+	})
+
+	t.Run("Failure and recovery", func(t *testing.T) {
+		failed := batch.Record{Domain: "example.com", Error: "HTTP request failed with status code: 500"}
+		if got := kinds(Diff(base, failed)); len(got) != 1 || got[0] != TransitionFailed {
+			t.Errorf("Expected failed, got %v", got)
+		}
+		if got := kinds(Diff(failed, failed)); len(got) != 0 {
+			t.Errorf("Expected no transitions while failing, got %v", got)
+		}
+		if got := kinds(Diff(failed, base)); len(got) != 1 || got[0] != TransitionRecovered {
+			t.Errorf("Expected recovered, got %v", got)
+		}
+	})
+
+	t.Run("Circuit opened", func(t *testing.T) {
+		failed := batch.Record{Domain: "example.com", Error: "HTTP request failed with status code: 500"}
+		open := batch.Record{Domain: "example.com", Error: "circuit open after 5 consecutive failures; retrying in 1m0s", CircuitOpen: true}
+		if got := kinds(Diff(failed, open)); len(got) != 1 || got[0] != TransitionCircuitOpened {
+			t.Errorf("Expected circuit_opened, got %v", got)
+		}
+		if got := kinds(Diff(open, open)); len(got) != 0 {
+			t.Errorf("Expected no transitions while the circuit stays open, got %v", got)
+		}
+		if got := kinds(Diff(open, base)); len(got) != 1 || got[0] != TransitionRecovered {
+			t.Errorf("Expected recovered, got %v", got)
+		}
+	})
+
+	t.Run("Severity", func(t *testing.T) {
+		fixed := base
+		fixed.Status = "SUCCESS"
+		broken := fixed
+		broken.Status = "BAD_RELYING_PARTY_ID_NO_JSON_MATCH"
+		failed := batch.Record{Domain: "example.com", Error: "timeout"}
+		tests := []struct {
+			prev, cur batch.Record
+			severity  Severity
+		}{
+			{base, fixed, SeverityInfo},
+			{fixed, broken, SeverityError},
+			{base, failed, SeverityError},
+			{failed, base, SeverityInfo},
+		}
+		for _, tt := range tests {
+			transitions := Diff(tt.prev, tt.cur)
+			if len(transitions) != 1 || transitions[0].Severity != tt.severity {
+				t.Errorf("Expected one %v transition, got %v", tt.severity, transitions)
+			}
+		}
+		if s, err := ParseSeverity("warning"); err != nil || s != SeverityWarning {
+			t.Errorf("ParseSeverity returned %v, %v", s, err)
+		}
+	})
+
+	t.Run("Skipped", func(t *testing.T) {
+		skipped := batch.Record{Domain: "example.com", Error: "budget exhausted", Skipped: true}
+		if got := Diff(base, skipped); len(got) != 0 {
+			t.Errorf("Expected no transitions for a skipped record, got %v", got)
+		}
+	})
+}
+
+// TestWatcherCheck tests that consecutive checks report transitions.
+func TestWatcherCheck(t *testing.T) {
+	var document atomic.Value
+	document.Store(`{"origins": ["https://example.com"]}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(document.Load().(string)))
+	}))
+	defer server.Close()
+
+	var transitions []Transition
+	watcher := New(Options{
+		Batch: batch.Options{Concurrency: 1, Origin: "https://login.example.com", Fetch: counter.DefaultOptions()},
+		Notify: func(t Transition) error {
+			transitions = append(transitions, t)
+			return nil
+		},
+	})
+
+	// The first check only establishes the baseline
+	if err := watcher.Check(context.Background(), []string{server.URL}); err != nil {
+		t.Fatalf("Check returned an error: %v", err)
+	}
+	if len(transitions) != 0 {
+		t.Fatalf("Expected no transitions after the first check, got %v", transitions)
+	}
+
+	document.Store(`{"origins": ["https://example.com", "https://login.example.com"]}`)
+	if err := watcher.Check(context.Background(), []string{server.URL}); err != nil {
+		t.Fatalf("Check returned an error: %v", err)
+	}
+	if len(transitions) != 2 || transitions[0].Kind != TransitionOriginsAdded || transitions[1].Kind != TransitionStatusChanged {
+		t.Errorf("Expected origins_added and status_changed, got %v", transitions)
+	}
+}
+
+// TestWebhookNotifier tests that webhook requests are signed when a secret is set.
+func TestWebhookNotifier(t *testing.T) {
+	secret := []byte("shared secret")
+	var verified atomic.Int64
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify(secret, r.Header, body, time.Now(), webhook.DefaultTolerance); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		verified.Add(1)
+	}))
+	defer receiver.Close()
+
+	transition := Transition{Domain: "example.com", Kind: TransitionOriginsAdded}
+	if err := WebhookNotifier(receiver.Client(), receiver.URL, secret)(transition); err != nil {
+		t.Errorf("Signed webhook returned an error: %v", err)
+	}
+	if verified.Load() != 1 {
+		t.Errorf("Expected the receiver to verify the request")
+	}
+	if err := WebhookNotifier(receiver.Client(), receiver.URL, nil)(transition); err == nil {
+		t.Error("Expected an unsigned webhook to be rejected")
+	}
+}
@@ -0,0 +1,85 @@
+// Package client is a typed Go client for the passkey-origin-validator REST API.
+//
+// The API is served by "passkey-origin-validator serve" and described by the OpenAPI
+// document at /openapi.json. The schema types and the methods that call each operation
+// are generated from that document into api.gen.go; regenerate them with "make generate"
+// after changing it.
+package client
+
+//go:generate go run ../../internal/openapigen -package client -out api.gen.go
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// APIError is returned when the API rejects a request.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error returns the status code and message of the error.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed with status code %d: %s", e.StatusCode, e.Message)
+}
+
+// Client calls the REST API at a base URL.
+type Client struct {
+	// BaseURL is the URL of the server, such as "http://127.0.0.1:8080".
+	BaseURL string
+	// HTTPClient is used for requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// New returns a Client for the server at baseURL.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// get sends a GET request and decodes the JSON response into v. Responses with status
+// 502 and 503 carry a result describing the upstream failure, so they are decoded too.
+func (c *Client) get(ctx context.Context, path string, query url.Values, v any) error {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusBadGateway, http.StatusServiceUnavailable:
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		return nil
+	}
+
+	// Other responses carry an error message
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+		message = errResp.Error
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: message}
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/server"
+)
+
+// TestClient tests the client against a real server.
+func TestClient(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"origins": ["https://example.com"]}`))
+	}))
+	defer upstream.Close()
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	api := httptest.NewServer(server.New(server.DefaultOptions()))
+	defer api.Close()
+	c := New(api.URL + "/")
+	ctx := context.Background()
+
+	t.Run("Validate", func(t *testing.T) {
+		resp, err := c.Validate(ctx, upstream.URL, "https://example.com")
+		if err != nil {
+			t.Fatalf("Validate returned an error: %v", err)
+		}
+		if !resp.Authorized || resp.Status != StatusSuccess || resp.LabelCount != 1 {
+			t.Errorf("Unexpected response %+v", resp)
+		}
+	})
+
+	t.Run("Count", func(t *testing.T) {
+		resp, err := c.Count(ctx, upstream.URL)
+		if err != nil {
+			t.Fatalf("Count returned an error: %v", err)
+		}
+		if resp.LabelCount != 1 || resp.ExceedsLimit {
+			t.Errorf("Unexpected response %+v", resp)
+		}
+	})
+
+	t.Run("Upstream failure", func(t *testing.T) {
+		resp, err := c.Validate(ctx, missing.URL, "https://example.com")
+		if err != nil {
+			t.Fatalf("Validate returned an error: %v", err)
+		}
+		if resp.Error == "" || resp.Authorized {
+			t.Errorf("Expected an upstream failure, got %+v", resp)
+		}
+	})
+
+	t.Run("API error", func(t *testing.T) {
+		_, err := c.Validate(ctx, upstream.URL, "")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected a 400 APIError, got %v", err)
+		}
+	})
+
+	t.Run("Health", func(t *testing.T) {
+		resp, err := c.Health(ctx)
+		if err != nil {
+			t.Fatalf("Health returned an error: %v", err)
+		}
+		if resp.Status != "ok" {
+			t.Errorf("Unexpected response %+v", resp)
+		}
+	})
+}
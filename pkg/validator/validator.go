@@ -0,0 +1,246 @@
+// Package validator lets relying-party backends check related origins at runtime.
+//
+// A WebAuthn assertion made from a related origin names an RP ID whose
+// .well-known/webauthn document must list the origin. An OriginChecker fetches and
+// caches those documents, so that verifying an assertion does not cost a request to the
+// RP ID's host each time:
+//
+//	checker := validator.New(validator.DefaultOptions())
+//	allowed, err := checker.IsOriginAllowed(ctx, "example.com", clientData.Origin)
+//
+// Documents are validated with the same rules as browsers, including the limit on the
+// number of unique eTLD+1 labels.
+//
+// Decide answers the whole question a browser asks of an assertion: whether the origin
+// may use the RP ID at all, under the standard RP ID rules or, failing those, through
+// the RP ID's related origins. The document is only fetched for an RP ID of another site:
+//
+//	decision, err := checker.Decide(ctx, "example.com", clientData.Origin)
+//	if err == nil && decision.Allowed {
+//		log.Printf("allowed through %s", decision.Path)
+//	}
+package validator
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/dnscheck"
+	"github.com/developmeh/passkey-origin-validator/internal/rpid"
+)
+
+const (
+	// DefaultTTL is how long a fetched document is cached.
+	DefaultTTL = 5 * time.Minute
+	// DefaultNegativeTTL is how long a failure to fetch or parse a document is cached.
+	DefaultNegativeTTL = 30 * time.Second
+)
+
+// Options configures an OriginChecker.
+type Options struct {
+	// TTL is how long a fetched document is cached. If zero, documents are not cached;
+	// DefaultOptions sets DefaultTTL.
+	TTL time.Duration
+	// NegativeTTL is how long a failure to fetch or parse a document is cached. If zero,
+	// failures are not cached; DefaultOptions sets DefaultNegativeTTL.
+	NegativeTTL time.Duration
+	// Timeout is the timeout for fetching a document. If not positive, counter.Timeout
+	// applies.
+	Timeout time.Duration
+	// MaxLabels is the number of unique labels counted before further origins are
+	// ignored. If zero, counter.MaxLabels, the limit browsers use, applies.
+	MaxLabels int
+	// Transport is the HTTP transport used for requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+	// Resolver resolves the host names of RP IDs. If nil, the system resolver is used. It
+	// only applies when Transport is nil, since a custom Transport does its own dialing.
+	Resolver Resolver
+}
+
+// DefaultOptions returns the Options used when none are configured.
+func DefaultOptions() Options {
+	return Options{
+		TTL:         DefaultTTL,
+		NegativeTTL: DefaultNegativeTTL,
+		Timeout:     counter.Timeout,
+	}
+}
+
+// Resolver resolves host names, such as through service discovery or a fake in tests.
+// *net.Resolver implements it.
+type Resolver = dnscheck.Resolver
+
+// Decision is whether an origin may use an RP ID, and under which rule: the standard
+// RP ID rules (rp-id) or the RP ID's related origins (related-origins).
+type Decision = rpid.Decision
+
+// entry is the outcome of fetching one RP ID's document.
+type entry struct {
+	// compiled is the indexed document; nil when err is set.
+	compiled *counter.CompiledWellKnown
+	// err describes why the document could not be fetched or parsed.
+	err error
+	// fetchedAt is when the document was fetched.
+	fetchedAt time.Time
+}
+
+// call is an in-flight fetch that concurrent checks for the same RP ID wait on.
+type call struct {
+	done  chan struct{}
+	entry *entry
+}
+
+// OriginChecker fetches, caches and validates .well-known/webauthn documents. Concurrent
+// checks for an RP ID that is not cached share a single fetch. An OriginChecker is safe
+// for concurrent use.
+type OriginChecker struct {
+	opts Options
+	now  func() time.Time
+	// fetch fetches and compiles the document for an RP ID.
+	fetch func(rpID string) *entry
+
+	mu       sync.Mutex
+	entries  map[string]*entry
+	inflight map[string]*call
+}
+
+// New returns an OriginChecker with an empty cache.
+func New(opts Options) *OriginChecker {
+	c := &OriginChecker{
+		opts:     opts,
+		now:      time.Now,
+		entries:  make(map[string]*entry),
+		inflight: make(map[string]*call),
+	}
+	if opts.Transport == nil && opts.Resolver != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = dnscheck.DialContext(opts.Resolver, &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		})
+		c.opts.Transport = transport
+	}
+	c.fetch = c.fetchEntry
+	return c
+}
+
+// IsOriginAllowed reports whether origin is listed by the .well-known/webauthn document
+// of rpID. It returns an error, and false, when the document could not be fetched or is
+// not a valid document, or when ctx is done before the document is available. A fetch
+// started by a check whose ctx is done still completes and is cached.
+func (c *OriginChecker) IsOriginAllowed(ctx context.Context, rpID, origin string) (bool, error) {
+	e, err := c.get(ctx, rpID)
+	if err != nil {
+		return false, err
+	}
+	if e.err != nil {
+		return false, e.err
+	}
+	return e.compiled.Validate(origin) == counter.StatusSuccess, nil
+}
+
+// Decide reports whether origin may use rpID, first under the standard RP ID rules, then
+// through the related origins of rpID's cached .well-known/webauthn document. It returns
+// an error, with Allowed false, when the document is needed but could not be fetched or
+// is not a valid document, or when ctx is done before it is available.
+func (c *OriginChecker) Decide(ctx context.Context, rpID, origin string) (Decision, error) {
+	return rpid.Decide(origin, rpID, func(rpID, origin string) (counter.AuthenticatorStatus, error) {
+		e, err := c.get(ctx, rpID)
+		if err != nil {
+			return 0, err
+		}
+		if e.err != nil {
+			return 0, e.err
+		}
+		return e.compiled.Validate(origin), nil
+	})
+}
+
+// Invalidate removes the cached document for rpID, so that the next check fetches it again.
+func (c *OriginChecker) Invalidate(rpID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, rpID)
+}
+
+// fresh reports whether a cached entry may still be used. The caller must hold c.mu.
+func (c *OriginChecker) fresh(e *entry) bool {
+	ttl := c.opts.TTL
+	if e.err != nil {
+		ttl = c.opts.NegativeTTL
+	}
+	return c.now().Sub(e.fetchedAt) < ttl
+}
+
+// get returns the entry for rpID, fetching it if it is not cached.
+func (c *OriginChecker) get(ctx context.Context, rpID string) (*entry, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[rpID]; ok && c.fresh(e) {
+		c.mu.Unlock()
+		return e, nil
+	}
+	inflight, ok := c.inflight[rpID]
+	if !ok {
+		inflight = &call{done: make(chan struct{})}
+		c.inflight[rpID] = inflight
+		go c.run(rpID, inflight)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-inflight.done:
+		return inflight.entry, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run fetches rpID, caches the entry and releases the checks waiting on inflight.
+func (c *OriginChecker) run(rpID string, inflight *call) {
+	e := c.fetch(rpID)
+	e.fetchedAt = c.now()
+
+	c.mu.Lock()
+	c.entries[rpID] = e
+	delete(c.inflight, rpID)
+	for key, cached := range c.entries {
+		if !c.fresh(cached) {
+			delete(c.entries, key)
+		}
+	}
+	c.mu.Unlock()
+
+	inflight.entry = e
+	close(inflight.done)
+}
+
+// fetchEntry fetches and compiles the document for rpID.
+func (c *OriginChecker) fetchEntry(rpID string) *entry {
+	opts := counter.DefaultOptions()
+	if c.opts.Timeout > 0 {
+		opts.Timeout = c.opts.Timeout
+	}
+	opts.Transport = c.opts.Transport
+	if c.opts.MaxLabels > 0 {
+		opts.MaxLabels = c.opts.MaxLabels
+	}
+
+	result, err := counter.CountLabelsWithOptions(rpID, opts)
+	if err != nil {
+		return &entry{err: err}
+	}
+	if result.ErrorMessage != "" {
+		return &entry{err: errors.New(result.ErrorMessage)}
+	}
+
+	compiled, err := counter.CompileWithMaxLabels([]byte(result.RawJSON), opts.MaxLabels)
+	if err != nil {
+		return &entry{err: err}
+	}
+	return &entry{compiled: compiled}
+}
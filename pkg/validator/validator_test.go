@@ -0,0 +1,222 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// TestOriginChecker tests validation, caching and fetch deduplication.
+func TestOriginChecker(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"origins": ["https://example.com", "https://example.co.uk"]}`))
+	}))
+	defer upstream.Close()
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	checker := New(DefaultOptions())
+	checker.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	t.Run("Concurrent checks share a fetch", func(t *testing.T) {
+		var wg sync.WaitGroup
+		results := make([]bool, 10)
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				allowed, err := checker.IsOriginAllowed(ctx, upstream.URL, "https://example.co.uk")
+				if err != nil {
+					t.Errorf("IsOriginAllowed returned an error: %v", err)
+				}
+				results[i] = allowed
+			}()
+		}
+		// Wait until the first fetch has reached the upstream before releasing it
+		for requests.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+		wg.Wait()
+
+		for i, allowed := range results {
+			if !allowed {
+				t.Errorf("Check %d: expected the origin to be allowed", i)
+			}
+		}
+		if got := requests.Load(); got != 1 {
+			t.Errorf("Expected 1 upstream request, got %d", got)
+		}
+	})
+
+	t.Run("Unlisted origin", func(t *testing.T) {
+		allowed, err := checker.IsOriginAllowed(ctx, upstream.URL, "https://example.org")
+		if err != nil {
+			t.Fatalf("IsOriginAllowed returned an error: %v", err)
+		}
+		if allowed {
+			t.Error("Expected the origin not to be allowed")
+		}
+		if got := requests.Load(); got != 1 {
+			t.Errorf("Expected the cached document to be used, got %d upstream requests", got)
+		}
+	})
+
+	t.Run("Refetches after the TTL", func(t *testing.T) {
+		now = now.Add(DefaultTTL)
+		if _, err := checker.IsOriginAllowed(ctx, upstream.URL, "https://example.com"); err != nil {
+			t.Fatalf("IsOriginAllowed returned an error: %v", err)
+		}
+		if got := requests.Load(); got != 2 {
+			t.Errorf("Expected 2 upstream requests, got %d", got)
+		}
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		checker.Invalidate(upstream.URL)
+		if _, err := checker.IsOriginAllowed(ctx, upstream.URL, "https://example.com"); err != nil {
+			t.Fatalf("IsOriginAllowed returned an error: %v", err)
+		}
+		if got := requests.Load(); got != 3 {
+			t.Errorf("Expected 3 upstream requests, got %d", got)
+		}
+	})
+
+	t.Run("Fetch failure", func(t *testing.T) {
+		allowed, err := checker.IsOriginAllowed(ctx, missing.URL, "https://example.com")
+		if err == nil || allowed {
+			t.Errorf("Expected an error, got allowed=%v, err=%v", allowed, err)
+		}
+	})
+
+	t.Run("Label limit", func(t *testing.T) {
+		labels := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"origins": ["https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://f.com"]}`))
+		}))
+		defer labels.Close()
+
+		if allowed, err := New(DefaultOptions()).IsOriginAllowed(ctx, labels.URL, "https://f.com"); err != nil || allowed {
+			t.Errorf("Expected the sixth label to be beyond the default limit, got allowed=%v, err=%v", allowed, err)
+		}
+		opts := DefaultOptions()
+		opts.MaxLabels = 8
+		if allowed, err := New(opts).IsOriginAllowed(ctx, labels.URL, "https://f.com"); err != nil || !allowed {
+			t.Errorf("Expected the sixth label to be within a limit of 8, got allowed=%v, err=%v", allowed, err)
+		}
+	})
+
+	t.Run("Zero-value options", func(t *testing.T) {
+		if allowed, err := New(Options{}).IsOriginAllowed(ctx, upstream.URL, "https://example.com"); err != nil || !allowed {
+			t.Errorf("Expected zero-value options to fetch with the default timeout, got allowed=%v, err=%v", allowed, err)
+		}
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		blocked := make(chan struct{})
+		defer close(blocked)
+		checker.fetch = func(rpID string) *entry {
+			<-blocked
+			return &entry{}
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := checker.IsOriginAllowed(ctx, "slow.example", "https://example.com")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
+
+// TestDecide tests falling back from the standard RP ID rules to related origins.
+func TestDecide(t *testing.T) {
+	compiled, err := counter.Compile([]byte(`{"origins": ["https://example.co.uk"]}`))
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	var fetches atomic.Int32
+	checker := New(DefaultOptions())
+	checker.fetch = func(rpID string) *entry {
+		fetches.Add(1)
+		if rpID != "example.com" {
+			return &entry{err: errors.New("HTTP request failed with status code: 404")}
+		}
+		return &entry{compiled: compiled}
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		rpID    string
+		origin  string
+		allowed bool
+		path    string
+		fetches int32
+	}{
+		{"example.com", "https://login.example.com", true, "rp-id", 0},
+		{"example.com", "https://example.co.uk", true, "related-origins", 1},
+		{"example.com", "https://example.de", false, "none", 1},
+	}
+	for _, tt := range tests {
+		fetches.Store(0)
+		decision, err := checker.Decide(ctx, tt.rpID, tt.origin)
+		if err != nil {
+			t.Fatalf("Decide(%q, %q) returned an error: %v", tt.rpID, tt.origin, err)
+		}
+		if decision.Allowed != tt.allowed || decision.Path.String() != tt.path {
+			t.Errorf("Decide(%q, %q) = %+v, expected allowed=%v through %s", tt.rpID, tt.origin, decision, tt.allowed, tt.path)
+		}
+		// The document is cached after the first fetch
+		if got := fetches.Load(); got > tt.fetches {
+			t.Errorf("Decide(%q, %q) fetched %d times, expected at most %d", tt.rpID, tt.origin, got, tt.fetches)
+		}
+	}
+
+	if decision, err := checker.Decide(ctx, "example.org", "https://example.co.uk"); err == nil || decision.Allowed {
+		t.Errorf("Expected an error for a document that cannot be fetched, got %+v, %v", decision, err)
+	}
+}
+
+// hostResolver resolves every name to the loopback address.
+type hostResolver struct{}
+
+func (hostResolver) LookupIPAddr(context.Context, string) ([]net.IPAddr, error) {
+	return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+}
+
+func (hostResolver) LookupCNAME(_ context.Context, host string) (string, error) {
+	return host + ".", nil
+}
+
+// TestResolver tests fetching documents with a custom resolver.
+func TestResolver(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"origins": ["https://example.co.uk"]}`))
+	}))
+	defer upstream.Close()
+	_, port, _ := net.SplitHostPort(upstream.Listener.Addr().String())
+
+	opts := DefaultOptions()
+	opts.Resolver = hostResolver{}
+	checker := New(opts)
+	allowed, err := checker.IsOriginAllowed(context.Background(), "http://rp.internal:"+port, "https://example.co.uk")
+	if err != nil || !allowed {
+		t.Errorf("Expected the origin to be allowed through the resolver, got %v, %v", allowed, err)
+	}
+}
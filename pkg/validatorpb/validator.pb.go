@@ -0,0 +1,453 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: validator/v1/validator.proto
+
+package validatorpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ValidateOriginRequest identifies a relying party domain and a caller origin.
+type ValidateOriginRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// domain is the relying party domain or URL.
+	Domain string `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	// origin is the caller origin to validate.
+	Origin        string `protobuf:"bytes,2,opt,name=origin,proto3" json:"origin,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidateOriginRequest) Reset() {
+	*x = ValidateOriginRequest{}
+	mi := &file_validator_v1_validator_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidateOriginRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidateOriginRequest) ProtoMessage() {}
+
+func (x *ValidateOriginRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_validator_v1_validator_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidateOriginRequest.ProtoReflect.Descriptor instead.
+func (*ValidateOriginRequest) Descriptor() ([]byte, []int) {
+	return file_validator_v1_validator_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ValidateOriginRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *ValidateOriginRequest) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+// ValidateOriginResponse is the result of validating a caller origin.
+type ValidateOriginResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Domain string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	// url is the .well-known/webauthn URL that was fetched.
+	Url    string `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Origin string `protobuf:"bytes,3,opt,name=origin,proto3" json:"origin,omitempty"`
+	// status is the browser validation status, such as "SUCCESS". It is empty when the
+	// document could not be fetched.
+	Status     string   `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	Authorized bool     `protobuf:"varint,5,opt,name=authorized,proto3" json:"authorized,omitempty"`
+	LabelCount int32    `protobuf:"varint,6,opt,name=label_count,json=labelCount,proto3" json:"label_count,omitempty"`
+	Labels     []string `protobuf:"bytes,7,rep,name=labels,proto3" json:"labels,omitempty"`
+	Error      string   `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	// circuit_open is true when the domain was not fetched because it has been failing repeatedly.
+	CircuitOpen   bool `protobuf:"varint,9,opt,name=circuit_open,json=circuitOpen,proto3" json:"circuit_open,omitempty"`
+	Cached        bool `protobuf:"varint,10,opt,name=cached,proto3" json:"cached,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidateOriginResponse) Reset() {
+	*x = ValidateOriginResponse{}
+	mi := &file_validator_v1_validator_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidateOriginResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidateOriginResponse) ProtoMessage() {}
+
+func (x *ValidateOriginResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_validator_v1_validator_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidateOriginResponse.ProtoReflect.Descriptor instead.
+func (*ValidateOriginResponse) Descriptor() ([]byte, []int) {
+	return file_validator_v1_validator_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ValidateOriginResponse) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *ValidateOriginResponse) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *ValidateOriginResponse) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+func (x *ValidateOriginResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ValidateOriginResponse) GetAuthorized() bool {
+	if x != nil {
+		return x.Authorized
+	}
+	return false
+}
+
+func (x *ValidateOriginResponse) GetLabelCount() int32 {
+	if x != nil {
+		return x.LabelCount
+	}
+	return 0
+}
+
+func (x *ValidateOriginResponse) GetLabels() []string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *ValidateOriginResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ValidateOriginResponse) GetCircuitOpen() bool {
+	if x != nil {
+		return x.CircuitOpen
+	}
+	return false
+}
+
+func (x *ValidateOriginResponse) GetCached() bool {
+	if x != nil {
+		return x.Cached
+	}
+	return false
+}
+
+// CountLabelsRequest identifies a relying party domain.
+type CountLabelsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// domain is the relying party domain or URL.
+	Domain        string `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountLabelsRequest) Reset() {
+	*x = CountLabelsRequest{}
+	mi := &file_validator_v1_validator_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountLabelsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountLabelsRequest) ProtoMessage() {}
+
+func (x *CountLabelsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_validator_v1_validator_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountLabelsRequest.ProtoReflect.Descriptor instead.
+func (*CountLabelsRequest) Descriptor() ([]byte, []int) {
+	return file_validator_v1_validator_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CountLabelsRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+// CountLabelsResponse is the result of counting the labels in a document.
+type CountLabelsResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Domain string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	// url is the .well-known/webauthn URL that was fetched.
+	Url          string   `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	LabelCount   int32    `protobuf:"varint,3,opt,name=label_count,json=labelCount,proto3" json:"label_count,omitempty"`
+	Labels       []string `protobuf:"bytes,4,rep,name=labels,proto3" json:"labels,omitempty"`
+	ExceedsLimit bool     `protobuf:"varint,5,opt,name=exceeds_limit,json=exceedsLimit,proto3" json:"exceeds_limit,omitempty"`
+	Warnings     []string `protobuf:"bytes,6,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	Error        string   `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+	// circuit_open is true when the domain was not fetched because it has been failing repeatedly.
+	CircuitOpen   bool `protobuf:"varint,8,opt,name=circuit_open,json=circuitOpen,proto3" json:"circuit_open,omitempty"`
+	Cached        bool `protobuf:"varint,9,opt,name=cached,proto3" json:"cached,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountLabelsResponse) Reset() {
+	*x = CountLabelsResponse{}
+	mi := &file_validator_v1_validator_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountLabelsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountLabelsResponse) ProtoMessage() {}
+
+func (x *CountLabelsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_validator_v1_validator_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountLabelsResponse.ProtoReflect.Descriptor instead.
+func (*CountLabelsResponse) Descriptor() ([]byte, []int) {
+	return file_validator_v1_validator_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CountLabelsResponse) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *CountLabelsResponse) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *CountLabelsResponse) GetLabelCount() int32 {
+	if x != nil {
+		return x.LabelCount
+	}
+	return 0
+}
+
+func (x *CountLabelsResponse) GetLabels() []string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *CountLabelsResponse) GetExceedsLimit() bool {
+	if x != nil {
+		return x.ExceedsLimit
+	}
+	return false
+}
+
+func (x *CountLabelsResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *CountLabelsResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *CountLabelsResponse) GetCircuitOpen() bool {
+	if x != nil {
+		return x.CircuitOpen
+	}
+	return false
+}
+
+func (x *CountLabelsResponse) GetCached() bool {
+	if x != nil {
+		return x.Cached
+	}
+	return false
+}
+
+var File_validator_v1_validator_proto protoreflect.FileDescriptor
+
+const file_validator_v1_validator_proto_rawDesc = "" +
+	"\n" +
+	"\x1cvalidator/v1/validator.proto\x12\fvalidator.v1\"G\n" +
+	"\x15ValidateOriginRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x16\n" +
+	"\x06origin\x18\x02 \x01(\tR\x06origin\"\x9c\x02\n" +
+	"\x16ValidateOriginResponse\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x16\n" +
+	"\x06origin\x18\x03 \x01(\tR\x06origin\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x12\x1e\n" +
+	"\n" +
+	"authorized\x18\x05 \x01(\bR\n" +
+	"authorized\x12\x1f\n" +
+	"\vlabel_count\x18\x06 \x01(\x05R\n" +
+	"labelCount\x12\x16\n" +
+	"\x06labels\x18\a \x03(\tR\x06labels\x12\x14\n" +
+	"\x05error\x18\b \x01(\tR\x05error\x12!\n" +
+	"\fcircuit_open\x18\t \x01(\bR\vcircuitOpen\x12\x16\n" +
+	"\x06cached\x18\n" +
+	" \x01(\bR\x06cached\",\n" +
+	"\x12CountLabelsRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\"\x8a\x02\n" +
+	"\x13CountLabelsResponse\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x1f\n" +
+	"\vlabel_count\x18\x03 \x01(\x05R\n" +
+	"labelCount\x12\x16\n" +
+	"\x06labels\x18\x04 \x03(\tR\x06labels\x12#\n" +
+	"\rexceeds_limit\x18\x05 \x01(\bR\fexceedsLimit\x12\x1a\n" +
+	"\bwarnings\x18\x06 \x03(\tR\bwarnings\x12\x14\n" +
+	"\x05error\x18\a \x01(\tR\x05error\x12!\n" +
+	"\fcircuit_open\x18\b \x01(\bR\vcircuitOpen\x12\x16\n" +
+	"\x06cached\x18\t \x01(\bR\x06cached2\xa3\x02\n" +
+	"\x10ValidatorService\x12[\n" +
+	"\x0eValidateOrigin\x12#.validator.v1.ValidateOriginRequest\x1a$.validator.v1.ValidateOriginResponse\x12R\n" +
+	"\vCountLabels\x12 .validator.v1.CountLabelsRequest\x1a!.validator.v1.CountLabelsResponse\x12^\n" +
+	"\rBatchValidate\x12#.validator.v1.ValidateOriginRequest\x1a$.validator.v1.ValidateOriginResponse(\x010\x01BLZJgithub.com/developmeh/passkey-origin-validator/pkg/validatorpb;validatorpbb\x06proto3"
+
+var (
+	file_validator_v1_validator_proto_rawDescOnce sync.Once
+	file_validator_v1_validator_proto_rawDescData []byte
+)
+
+func file_validator_v1_validator_proto_rawDescGZIP() []byte {
+	file_validator_v1_validator_proto_rawDescOnce.Do(func() {
+		file_validator_v1_validator_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_validator_v1_validator_proto_rawDesc), len(file_validator_v1_validator_proto_rawDesc)))
+	})
+	return file_validator_v1_validator_proto_rawDescData
+}
+
+var file_validator_v1_validator_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_validator_v1_validator_proto_goTypes = []any{
+	(*ValidateOriginRequest)(nil),  // 0: validator.v1.ValidateOriginRequest
+	(*ValidateOriginResponse)(nil), // 1: validator.v1.ValidateOriginResponse
+	(*CountLabelsRequest)(nil),     // 2: validator.v1.CountLabelsRequest
+	(*CountLabelsResponse)(nil),    // 3: validator.v1.CountLabelsResponse
+}
+var file_validator_v1_validator_proto_depIdxs = []int32{
+	0, // 0: validator.v1.ValidatorService.ValidateOrigin:input_type -> validator.v1.ValidateOriginRequest
+	2, // 1: validator.v1.ValidatorService.CountLabels:input_type -> validator.v1.CountLabelsRequest
+	0, // 2: validator.v1.ValidatorService.BatchValidate:input_type -> validator.v1.ValidateOriginRequest
+	1, // 3: validator.v1.ValidatorService.ValidateOrigin:output_type -> validator.v1.ValidateOriginResponse
+	3, // 4: validator.v1.ValidatorService.CountLabels:output_type -> validator.v1.CountLabelsResponse
+	1, // 5: validator.v1.ValidatorService.BatchValidate:output_type -> validator.v1.ValidateOriginResponse
+	3, // [3:6] is the sub-list for method output_type
+	0, // [0:3] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_validator_v1_validator_proto_init() }
+func file_validator_v1_validator_proto_init() {
+	if File_validator_v1_validator_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_validator_v1_validator_proto_rawDesc), len(file_validator_v1_validator_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_validator_v1_validator_proto_goTypes,
+		DependencyIndexes: file_validator_v1_validator_proto_depIdxs,
+		MessageInfos:      file_validator_v1_validator_proto_msgTypes,
+	}.Build()
+	File_validator_v1_validator_proto = out.File
+	file_validator_v1_validator_proto_goTypes = nil
+	file_validator_v1_validator_proto_depIdxs = nil
+}
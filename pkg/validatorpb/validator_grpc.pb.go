@@ -0,0 +1,206 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: validator/v1/validator.proto
+
+package validatorpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ValidatorService_ValidateOrigin_FullMethodName = "/validator.v1.ValidatorService/ValidateOrigin"
+	ValidatorService_CountLabels_FullMethodName    = "/validator.v1.ValidatorService/CountLabels"
+	ValidatorService_BatchValidate_FullMethodName  = "/validator.v1.ValidatorService/BatchValidate"
+)
+
+// ValidatorServiceClient is the client API for ValidatorService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ValidatorService validates caller origins against relying parties' .well-known/webauthn
+// documents and counts their unique eTLD+1 labels, following the same constraints as browsers.
+type ValidatorServiceClient interface {
+	// ValidateOrigin validates a caller origin against a domain's document.
+	ValidateOrigin(ctx context.Context, in *ValidateOriginRequest, opts ...grpc.CallOption) (*ValidateOriginResponse, error)
+	// CountLabels counts the unique labels in a domain's document.
+	CountLabels(ctx context.Context, in *CountLabelsRequest, opts ...grpc.CallOption) (*CountLabelsResponse, error)
+	// BatchValidate validates a stream of requests, returning one response per request in
+	// completion order. Each response echoes the domain and origin it answers.
+	BatchValidate(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ValidateOriginRequest, ValidateOriginResponse], error)
+}
+
+type validatorServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewValidatorServiceClient(cc grpc.ClientConnInterface) ValidatorServiceClient {
+	return &validatorServiceClient{cc}
+}
+
+func (c *validatorServiceClient) ValidateOrigin(ctx context.Context, in *ValidateOriginRequest, opts ...grpc.CallOption) (*ValidateOriginResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ValidateOriginResponse)
+	err := c.cc.Invoke(ctx, ValidatorService_ValidateOrigin_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *validatorServiceClient) CountLabels(ctx context.Context, in *CountLabelsRequest, opts ...grpc.CallOption) (*CountLabelsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CountLabelsResponse)
+	err := c.cc.Invoke(ctx, ValidatorService_CountLabels_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *validatorServiceClient) BatchValidate(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ValidateOriginRequest, ValidateOriginResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ValidatorService_ServiceDesc.Streams[0], ValidatorService_BatchValidate_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ValidateOriginRequest, ValidateOriginResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ValidatorService_BatchValidateClient = grpc.BidiStreamingClient[ValidateOriginRequest, ValidateOriginResponse]
+
+// ValidatorServiceServer is the server API for ValidatorService service.
+// All implementations must embed UnimplementedValidatorServiceServer
+// for forward compatibility.
+//
+// ValidatorService validates caller origins against relying parties' .well-known/webauthn
+// documents and counts their unique eTLD+1 labels, following the same constraints as browsers.
+type ValidatorServiceServer interface {
+	// ValidateOrigin validates a caller origin against a domain's document.
+	ValidateOrigin(context.Context, *ValidateOriginRequest) (*ValidateOriginResponse, error)
+	// CountLabels counts the unique labels in a domain's document.
+	CountLabels(context.Context, *CountLabelsRequest) (*CountLabelsResponse, error)
+	// BatchValidate validates a stream of requests, returning one response per request in
+	// completion order. Each response echoes the domain and origin it answers.
+	BatchValidate(grpc.BidiStreamingServer[ValidateOriginRequest, ValidateOriginResponse]) error
+	mustEmbedUnimplementedValidatorServiceServer()
+}
+
+// UnimplementedValidatorServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedValidatorServiceServer struct{}
+
+func (UnimplementedValidatorServiceServer) ValidateOrigin(context.Context, *ValidateOriginRequest) (*ValidateOriginResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ValidateOrigin not implemented")
+}
+func (UnimplementedValidatorServiceServer) CountLabels(context.Context, *CountLabelsRequest) (*CountLabelsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CountLabels not implemented")
+}
+func (UnimplementedValidatorServiceServer) BatchValidate(grpc.BidiStreamingServer[ValidateOriginRequest, ValidateOriginResponse]) error {
+	return status.Error(codes.Unimplemented, "method BatchValidate not implemented")
+}
+func (UnimplementedValidatorServiceServer) mustEmbedUnimplementedValidatorServiceServer() {}
+func (UnimplementedValidatorServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeValidatorServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ValidatorServiceServer will
+// result in compilation errors.
+type UnsafeValidatorServiceServer interface {
+	mustEmbedUnimplementedValidatorServiceServer()
+}
+
+func RegisterValidatorServiceServer(s grpc.ServiceRegistrar, srv ValidatorServiceServer) {
+	// If the following call panics, it indicates UnimplementedValidatorServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ValidatorService_ServiceDesc, srv)
+}
+
+func _ValidatorService_ValidateOrigin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateOriginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServiceServer).ValidateOrigin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ValidatorService_ValidateOrigin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServiceServer).ValidateOrigin(ctx, req.(*ValidateOriginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ValidatorService_CountLabels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CountLabelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidatorServiceServer).CountLabels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ValidatorService_CountLabels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidatorServiceServer).CountLabels(ctx, req.(*CountLabelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ValidatorService_BatchValidate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ValidatorServiceServer).BatchValidate(&grpc.GenericServerStream[ValidateOriginRequest, ValidateOriginResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ValidatorService_BatchValidateServer = grpc.BidiStreamingServer[ValidateOriginRequest, ValidateOriginResponse]
+
+// ValidatorService_ServiceDesc is the grpc.ServiceDesc for ValidatorService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ValidatorService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "validator.v1.ValidatorService",
+	HandlerType: (*ValidatorServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ValidateOrigin",
+			Handler:    _ValidatorService_ValidateOrigin_Handler,
+		},
+		{
+			MethodName: "CountLabels",
+			Handler:    _ValidatorService_CountLabels_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BatchValidate",
+			Handler:       _ValidatorService_BatchValidate_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "validator/v1/validator.proto",
+}
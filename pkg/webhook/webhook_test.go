@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestVerify tests verifying signed requests.
+func TestVerify(t *testing.T) {
+	secret := []byte("shared secret")
+	body := []byte(`{"domain":"example.com"}`)
+	sent := time.Unix(1700000000, 0)
+	signed := func() http.Header {
+		header := make(http.Header)
+		Sign(header, secret, sent, body)
+		return header
+	}
+
+	if got := signed().Get(TimestampHeader); got != "1700000000" {
+		t.Errorf("Expected the timestamp in seconds, got %q", got)
+	}
+	if got := signed().Get(SignatureHeader); !strings.HasPrefix(got, "sha256=") || len(got) != len("sha256=")+64 {
+		t.Errorf("Unexpected signature %q", got)
+	}
+
+	tests := []struct {
+		name   string
+		secret []byte
+		header http.Header
+		body   []byte
+		now    time.Time
+		ok     bool
+	}{
+		{"Valid", secret, signed(), body, sent.Add(time.Minute), true},
+		{"Clock behind", secret, signed(), body, sent.Add(-time.Minute), true},
+		{"Wrong secret", []byte("other"), signed(), body, sent, false},
+		{"Modified body", secret, signed(), []byte(`{"domain":"example.org"}`), sent, false},
+		{"Replayed", secret, signed(), body, sent.Add(DefaultTolerance + time.Second), false},
+		{"Unsigned", secret, http.Header{}, body, sent, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, tt.now, DefaultTolerance)
+			if (err == nil) != tt.ok {
+				t.Errorf("Verify returned %v, expected ok=%v", err, tt.ok)
+			}
+		})
+	}
+
+	// A changed timestamp invalidates the signature
+	header := signed()
+	header.Set(TimestampHeader, "1700000060")
+	if err := Verify(secret, header, body, sent, DefaultTolerance); err == nil {
+		t.Error("Expected an error for a changed timestamp")
+	}
+}
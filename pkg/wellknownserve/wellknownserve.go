@@ -0,0 +1,115 @@
+// Package wellknownserve serves a relying party's .well-known/webauthn document.
+//
+// A Handler is built from a list of origins, which is checked up front with the same
+// rules as the lint command, so that a document that browsers would partly ignore is
+// refused when the server starts rather than discovered in production:
+//
+//	h, err := wellknownserve.New([]string{"https://example.co.uk", "https://example.de"}, wellknownserve.Options{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	http.Handle(wellknownserve.Path, h)
+//
+// The document is served with a JSON content type, an ETag and a Cache-Control header.
+package wellknownserve
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+	"github.com/developmeh/passkey-origin-validator/internal/lint"
+)
+
+// Path is the path the document is served at.
+const Path = counter.WellKnownPath
+
+// DefaultMaxAge is how long clients may cache the document when Options.MaxAge is zero.
+const DefaultMaxAge = 5 * time.Minute
+
+// Options configures a Handler.
+type Options struct {
+	// MaxAge is the max-age of the Cache-Control header. If zero, DefaultMaxAge is used.
+	MaxAge time.Duration
+}
+
+// Handler serves a fixed .well-known/webauthn document. It responds to GET and HEAD
+// requests, answers conditional requests with 304 Not Modified, and rejects other methods.
+type Handler struct {
+	body         []byte
+	etag         string
+	cacheControl string
+}
+
+// New returns a Handler that serves origins. It returns an error listing the findings
+// when browsers would ignore any of the origins, for example because they add a label
+// beyond the limit of counter.MaxLabels.
+func New(origins []string, opts Options) (*Handler, error) {
+	body, err := json.Marshal(counter.WebAuthnResponse{Origins: append([]string{}, origins...)})
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse a document that would not work as intended
+	findings := lint.Check(body, lint.Options{})
+	if lint.HasErrors(findings) {
+		return nil, fmt.Errorf("refusing to serve an invalid .well-known/webauthn document:\n%s",
+			strings.TrimSuffix(lint.FormatFindings(findings), "\n"))
+	}
+
+	maxAge := opts.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultMaxAge
+	}
+	sum := sha256.Sum256(body)
+	return &Handler{
+		body:         body,
+		etag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+		cacheControl: fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())),
+	}, nil
+}
+
+// ServeHTTP serves the document.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodOptions:
+		w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", h.cacheControl)
+	w.Header().Set("ETag", h.etag)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(h.body))
+}
+
+// Middleware returns middleware that serves origins at Path and passes every other
+// request to the next handler. It returns the same errors as New.
+func Middleware(origins []string, opts Options) (func(http.Handler) http.Handler, error) {
+	h, err := New(origins, opts)
+	if err != nil {
+		return nil, err
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == Path {
+				h.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
@@ -0,0 +1,104 @@
+package wellknownserve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/developmeh/passkey-origin-validator/internal/counter"
+)
+
+// TestHandler tests that the served document passes the tool's own checks.
+func TestHandler(t *testing.T) {
+	h, err := New([]string{"https://example.com", "https://example.co.uk"}, Options{})
+	if err != nil {
+		t.Fatalf("New returned an error: %v", err)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	middleware, err := Middleware([]string{"https://example.com"}, Options{})
+	if err != nil {
+		t.Fatalf("Middleware returned an error: %v", err)
+	}
+	server := httptest.NewServer(middleware(next))
+	defer server.Close()
+	direct := httptest.NewServer(h)
+	defer direct.Close()
+
+	t.Run("Fetched document", func(t *testing.T) {
+		result, err := counter.CountLabels(direct.URL)
+		if err != nil {
+			t.Fatalf("CountLabels returned an error: %v", err)
+		}
+		if result.ErrorMessage != "" || len(result.Warnings) > 0 {
+			t.Fatalf("Expected a clean result, got error %q and warnings %v", result.ErrorMessage, result.Warnings)
+		}
+		status := counter.ValidateWellKnownJSON("https://example.co.uk", []byte(result.RawJSON))
+		if status != counter.StatusSuccess {
+			t.Errorf("Expected %v, got %v", counter.StatusSuccess, status)
+		}
+	})
+
+	t.Run("Headers and conditional requests", func(t *testing.T) {
+		resp, err := http.Get(direct.URL + Path)
+		if err != nil {
+			t.Fatalf("GET returned an error: %v", err)
+		}
+		resp.Body.Close()
+		etag := resp.Header.Get("ETag")
+		if etag == "" || resp.Header.Get("Cache-Control") != "public, max-age=300" {
+			t.Errorf("Unexpected headers %v", resp.Header)
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, direct.URL+Path, nil)
+		req.Header.Set("If-None-Match", etag)
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET returned an error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotModified {
+			t.Errorf("Expected 304, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Methods", func(t *testing.T) {
+		resp, err := http.Post(direct.URL+Path, "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatalf("POST returned an error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("Expected 405, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Middleware", func(t *testing.T) {
+		resp, err := http.Get(server.URL + Path)
+		if err != nil {
+			t.Fatalf("GET returned an error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected 200 at %s, got %d", Path, resp.StatusCode)
+		}
+
+		resp, err = http.Get(server.URL + "/other")
+		if err != nil {
+			t.Fatalf("GET returned an error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusTeapot {
+			t.Errorf("Expected other paths to reach the next handler, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Refuses too many labels", func(t *testing.T) {
+		_, err := New([]string{"https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://f.com"}, Options{})
+		if err == nil || !strings.Contains(err.Error(), "label-limit") {
+			t.Errorf("Expected a label-limit error, got %v", err)
+		}
+	})
+}